@@ -6,12 +6,21 @@ import axios from 'axios';
 
 
 interface AccessKeyDetails {
-    Key: string;
+    KeyPrefix: string;
     MaxRequests: number;
     GlobalCounter: number;
     KeyCounter: number;
     Username: string;
     IsAdmin: boolean;
+    ExpiresAt: number;
+    RotatedTo: string;
+    GraceCounter: number;
+    ID: string;
+    Label: string;
+    CreatedAt: number;
+    LastUsedAt: number;
+    LastIP: string;
+    Restrictions: { AllowedCIDRs?: string[]; AllowedOrigins?: string[] };
 }
 
 interface UserDetails {
@@ -84,6 +93,9 @@ export const Dashboard = () => {
     // Key Modal State
     const [showKeyModal, setShowKeyModal] = useState(false);
     const [newKeyVal, setNewKeyVal] = useState('');
+    const [newKeyLabel, setNewKeyLabel] = useState('');
+    const [newKeyCIDRs, setNewKeyCIDRs] = useState('');
+    const [newKeyOrigins, setNewKeyOrigins] = useState('');
     const [managingKeysForUser, setManagingKeysForUser] = useState<UserDetails | null>(null);
 
     // User Modal State
@@ -311,9 +323,9 @@ export const Dashboard = () => {
             const headers = { Authorization: `Bearer ${token}` };
 
             // Fetch Keys (Everyone)
-            // Note: Endpoint returns map[string]AccessKeyDetails
+            // Note: Endpoint returns AccessKeyDetails[] ordered by ID
             const keysRes = await axios.get('/api/admin-access-keys', { headers });
-            setKeys(keysRes.data || {});
+            setKeys(Object.fromEntries((keysRes.data || []).map((k: AccessKeyDetails) => [k.ID, k])));
 
             // Fetch Users
             if (isAdmin) {
@@ -372,17 +384,28 @@ export const Dashboard = () => {
     const handleCreateKey = async (e: React.FormEvent) => {
         e.preventDefault();
         try {
-            const payload: any = { key: newKeyVal };
+            const payload: any = { key: newKeyVal, label: newKeyLabel };
+            const splitList = (value: string) => value.split(',').map(item => item.trim()).filter(item => item.length > 0);
+            const allowedCIDRs = splitList(newKeyCIDRs);
+            const allowedOrigins = splitList(newKeyOrigins);
+            if (allowedCIDRs.length > 0 || allowedOrigins.length > 0) {
+                payload.restrictions = { AllowedCIDRs: allowedCIDRs, AllowedOrigins: allowedOrigins };
+            }
             if (managingKeysForUser) {
                 payload.username = managingKeysForUser.Username;
             }
-            await axios.post('/api/admin-access-keys', payload, {
+            const res = await axios.post('/api/admin-access-keys', payload, {
                 headers: { Authorization: `Bearer ${getAccessKey()}` }
             });
+            // only the key's hash is stored, so the full key is shown this one time
+            window.prompt('Copy the new key now, it will not be shown again', res.data.key);
             if (!managingKeysForUser) {
                 setShowKeyModal(false);
             }
             setNewKeyVal('');
+            setNewKeyLabel('');
+            setNewKeyCIDRs('');
+            setNewKeyOrigins('');
             fetchData(user?.is_admin || false);
         } catch (e: any) {
             const msg = e.response?.data ? String(e.response.data).trim() : 'Failed to create key';
@@ -390,6 +413,24 @@ export const Dashboard = () => {
         }
     };
 
+    const handleRotateKey = async (key: string, username?: string) => {
+        if (!confirm('Issue a successor for this key? The current key stays valid only during the grace period.')) return;
+        try {
+            const payload: any = { rotated_key: key };
+            if (username) {
+                payload.username = username;
+            }
+            const res = await axios.post('/api/admin-access-keys', payload, {
+                headers: { Authorization: `Bearer ${getAccessKey()}` }
+            });
+            window.prompt('Copy the new key now, it will not be shown again', res.data.key);
+            fetchData(user?.is_admin || false);
+        } catch (e: any) {
+            const msg = e.response?.data ? String(e.response.data).trim() : 'Failed to rotate key';
+            alert(msg);
+        }
+    };
+
     const handleDeleteKey = async (key: string, username?: string) => {
         if (!confirm('Revoke this key?')) return;
         try {
@@ -486,7 +527,7 @@ export const Dashboard = () => {
     };
 
     const sortedKeys = Object.entries(keys)
-        .map(([k, details]) => ({ ...details, ActualKey: k })) // Ensure we have the key string
+        .map(([k, details]) => ({ ...details, ActualKey: k })) // the map key is the key's ID, used to revoke it
         .sort((a, b) => {
             if (sortConfig.type !== 'keys' || !sortConfig.key) return 0;
             const valA = String((a as any)[sortConfig.key]).toLowerCase();
@@ -886,10 +927,10 @@ export const Dashboard = () => {
                                     <tr className="border-b border-white/10 text-slate-400 text-sm uppercase">
                                         <th
                                             className="py-3 px-4 cursor-pointer hover:text-white transition-colors group"
-                                            onClick={() => handleSort('keys', 'ActualKey')}
+                                            onClick={() => handleSort('keys', 'KeyPrefix')}
                                         >
                                             <div className="flex items-center gap-1">
-                                                Key Value {getSortIcon('keys', 'ActualKey')}
+                                                Key Value {getSortIcon('keys', 'KeyPrefix')}
                                             </div>
                                         </th>
                                         {user.is_admin && (
@@ -911,13 +952,25 @@ export const Dashboard = () => {
                                         <tr key={details.ActualKey} className="border-b border-white/5 hover:bg-white/5 transition-colors">
                                             <td className="py-3 px-4 font-mono text-sm text-indigo-200">
                                                 <div className="flex items-center gap-2">
-                                                    {details.ActualKey}
-                                                    <Copy
-                                                        size={14}
-                                                        className="cursor-pointer text-slate-500 hover:text-white"
-                                                        onClick={() => copyToClipboard(details.ActualKey)}
-                                                    />
+                                                    {details.KeyPrefix}…
+                                                    {details.Label && <span className="font-sans text-slate-300">{details.Label}</span>}
+                                                </div>
+                                                <div className="text-xs text-slate-500">
+                                                    {details.LastUsedAt > 0
+                                                        ? `Last used ${new Date(details.LastUsedAt * 1000).toLocaleString()} from ${details.LastIP}`
+                                                        : 'Never used'}
                                                 </div>
+                                                {(details.Restrictions?.AllowedCIDRs?.length || details.Restrictions?.AllowedOrigins?.length) ? (
+                                                    <div className="text-xs text-slate-500">
+                                                        Allowed from {[...(details.Restrictions.AllowedCIDRs || []), ...(details.Restrictions.AllowedOrigins || [])].join(', ')}
+                                                    </div>
+                                                ) : null}
+                                                {details.ExpiresAt > 0 && (
+                                                    <div className="text-xs text-slate-500">
+                                                        {details.RotatedTo ? 'Rotated, valid until' : 'Expires'} {new Date(details.ExpiresAt * 1000).toLocaleString()}
+                                                        {details.RotatedTo && ` (${details.GraceCounter} requests since rotation)`}
+                                                    </div>
+                                                )}
                                             </td>
                                             {user.is_admin && (
                                                 <td className="py-3 px-4 text-slate-300">
@@ -928,6 +981,15 @@ export const Dashboard = () => {
                                                 {details.KeyCounter}
                                             </td>
                                             <td className="py-3 px-4 text-right">
+                                                {!details.RotatedTo && (
+                                                    <button
+                                                        onClick={() => handleRotateKey(details.ActualKey, details.Username)}
+                                                        className="text-indigo-400 hover:text-indigo-300 p-1 rounded hover:bg-indigo-400/10 transition-colors"
+                                                        title="Rotate Key"
+                                                    >
+                                                        <RotateCcw size={16} />
+                                                    </button>
+                                                )}
                                                 <button
                                                     onClick={() => handleDeleteKey(details.ActualKey, details.Username)}
                                                     className="text-red-400 hover:text-red-300 p-1 rounded hover:bg-red-400/10 transition-colors"
@@ -1227,8 +1289,39 @@ export const Dashboard = () => {
                                         onChange={e => setNewKeyVal(e.target.value)}
                                     />
                                 </div>
+                                <div className="mb-4">
+                                    <label className="block text-sm text-slate-400 mb-1">Label (Optional)</label>
+                                    <input
+                                        type="text"
+                                        maxLength={64}
+                                        className="w-full bg-slate-800 border border-slate-700 rounded p-2 text-slate-200 focus:ring-2 focus:ring-indigo-500 focus:outline-none"
+                                        placeholder="e.g. indexer, wallet backend"
+                                        value={newKeyLabel}
+                                        onChange={e => setNewKeyLabel(e.target.value)}
+                                    />
+                                </div>
+                                <div className="mb-4">
+                                    <label className="block text-sm text-slate-400 mb-1">Allowed IPs / CIDRs (Optional)</label>
+                                    <input
+                                        type="text"
+                                        className="w-full bg-slate-800 border border-slate-700 rounded p-2 text-slate-200 focus:ring-2 focus:ring-indigo-500 focus:outline-none"
+                                        placeholder="e.g. 203.0.113.7, 10.0.0.0/8"
+                                        value={newKeyCIDRs}
+                                        onChange={e => setNewKeyCIDRs(e.target.value)}
+                                    />
+                                </div>
+                                <div className="mb-4">
+                                    <label className="block text-sm text-slate-400 mb-1">Allowed Origins (Optional)</label>
+                                    <input
+                                        type="text"
+                                        className="w-full bg-slate-800 border border-slate-700 rounded p-2 text-slate-200 focus:ring-2 focus:ring-indigo-500 focus:outline-none"
+                                        placeholder="e.g. https://app.example.com, https://*.example.org"
+                                        value={newKeyOrigins}
+                                        onChange={e => setNewKeyOrigins(e.target.value)}
+                                    />
+                                </div>
                                 <div className="flex justify-end gap-3 mt-6">
-                                    <button type="button" onClick={() => { setShowKeyModal(false); setNewKeyVal(''); }} className="px-4 py-2 hover:bg-white/5 rounded text-slate-300">Cancel</button>
+                                    <button type="button" onClick={() => { setShowKeyModal(false); setNewKeyVal(''); setNewKeyLabel(''); setNewKeyCIDRs(''); setNewKeyOrigins(''); }} className="px-4 py-2 hover:bg-white/5 rounded text-slate-300">Cancel</button>
                                     <button type="submit" className="px-4 py-2 bg-indigo-600 hover:bg-indigo-500 rounded text-white">Create</button>
                                 </div>
                             </form>
@@ -1355,12 +1448,7 @@ export const Dashboard = () => {
                                                 <tr key={k} className="border-b border-white/5 hover:bg-white/5 transition-colors">
                                                     <td className="py-2 px-4 font-mono text-sm text-indigo-200">
                                                         <div className="flex items-center gap-2">
-                                                            {k}
-                                                            <Copy
-                                                                size={12}
-                                                                className="cursor-pointer text-slate-500 hover:text-white"
-                                                                onClick={() => copyToClipboard(k)}
-                                                            />
+                                                            {details.KeyPrefix}…
                                                         </div>
                                                     </td>
                                                     <td className="py-2 px-4 text-slate-300">
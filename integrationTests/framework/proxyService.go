@@ -13,6 +13,7 @@ import (
 
 const (
 	jwtKey                  = "jwt-key"
+	keysHashKey             = "keys-hash-key"
 	emailTemplateFile       = "activation_email.html"
 	emailChangeTemplateFile = "change_email.html"
 )
@@ -106,8 +107,10 @@ func (ps *ProxyService) CreateService(cryptoPaymentURL string) {
 	ps.EmailSender = &MockEmailSender{}
 	ps.Components, err = factory.NewComponentsHandler(
 		cfg,
+		"",
 		dbPath,
 		jwtKey,
+		keysHashKey,
 		emailConfigs,
 		"v1.0.0",
 		GetProxyRootPath("swagger"),
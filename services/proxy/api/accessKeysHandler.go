@@ -2,21 +2,33 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/multiversx/mx-chain-core-go/core/check"
 )
 
+const latestEpoch = "latest"
+const maxKeyLabelLength = 64
+const maxKeyRestrictionEntries = 32
+
 // accessKeysHandler handles requests for managing access keys
 type accessKeysHandler struct {
 	keyAccessProvider KeyAccessProvider
 	auth              Authenticator
+	gracePeriod       time.Duration
 }
 
-// NewAccessKeysHandler creates a new AccessKeysHandler
-func NewAccessKeysHandler(keyAccessProvider KeyAccessProvider, auth Authenticator) (*accessKeysHandler, error) {
+// NewAccessKeysHandler creates a new AccessKeysHandler. The keys rotated through it stay allowed for the configured
+// grace period.
+func NewAccessKeysHandler(keyAccessProvider KeyAccessProvider, auth Authenticator, keysRotation config.KeysRotationConfig) (*accessKeysHandler, error) {
 	if check.IfNil(keyAccessProvider) {
 		return nil, errNilKeyAccessProvider
 	}
@@ -27,6 +39,7 @@ func NewAccessKeysHandler(keyAccessProvider KeyAccessProvider, auth Authenticato
 	return &accessKeysHandler{
 		keyAccessProvider: keyAccessProvider,
 		auth:              auth,
+		gracePeriod:       time.Duration(keysRotation.GracePeriodInSeconds) * time.Second,
 	}, nil
 }
 
@@ -43,6 +56,10 @@ func (handler *accessKeysHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 		handler.handleGet(w, r, claims)
 	case http.MethodPost:
 		handler.handlePost(w, r, claims)
+	case http.MethodPut:
+		handler.handlePut(w, r, claims)
+	case http.MethodPatch:
+		handler.handlePatch(w, r, claims)
 	case http.MethodDelete:
 		handler.handleDelete(w, r, claims)
 	default:
@@ -50,7 +67,14 @@ func (handler *accessKeysHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 	}
 }
 
-func (handler *accessKeysHandler) handleGet(w http.ResponseWriter, _ *http.Request, claims *common.Claims) {
+// handleGet lists the keys as an array ordered by the requested sort field, by their IDs if no sort field is requested
+func (handler *accessKeysHandler) handleGet(w http.ResponseWriter, r *http.Request, claims *common.Claims) {
+	query, err := parseKeysQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	username := claims.Username
 	if claims.IsAdmin {
 		username = "" // Get all keys
@@ -60,14 +84,20 @@ func (handler *accessKeysHandler) handleGet(w http.ResponseWriter, _ *http.Reque
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	keys = query.filter(keys)
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(keys)
+	_ = json.NewEncoder(w).Encode(query.sort(keys))
 }
 
 type addKeyRequest struct {
-	Key      string `json:"key"`
-	Username string `json:"username,omitempty"`
+	Key          string                  `json:"key"`
+	Username     string                  `json:"username,omitempty"`
+	Scope        common.KeyScope         `json:"scope"`
+	ExpiresAt    *int64                  `json:"expires_at,omitempty"`
+	RotatedKey   string                  `json:"rotated_key,omitempty"`
+	Label        *string                 `json:"label,omitempty"`
+	Restrictions *common.KeyRestrictions `json:"restrictions,omitempty"`
 }
 
 func (handler *accessKeysHandler) handlePost(w http.ResponseWriter, r *http.Request, claims *common.Claims) {
@@ -87,13 +117,120 @@ func (handler *accessKeysHandler) handlePost(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if !req.Scope.IsEmpty() && !claims.IsAdmin {
+		http.Error(w, "Forbidden: Only admins can set the key scope", http.StatusForbidden)
+		return
+	}
+	scope, err := normalizeKeyScope(req.Scope)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	err = checkKeyExpiry(req.ExpiresAt, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	label, err := normalizeKeyLabel(req.Label)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	restrictions, err := normalizeKeyRestrictions(req.Restrictions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	targetUser := claims.Username
 	if claims.IsAdmin && req.Username != "" {
 		targetUser = req.Username
 	}
 
-	// Add key
-	err = handler.keyAccessProvider.AddKey(targetUser, strings.ToLower(req.Key))
+	settings := common.KeySettings{
+		Scope: scope,
+	}
+	if req.ExpiresAt != nil {
+		settings.ExpiresAt = *req.ExpiresAt
+	}
+	// a rotated key's successor inherits its scope, label and restrictions, unless they are provided
+	if req.Label != nil {
+		settings.Label = &label
+	}
+	if req.Restrictions != nil {
+		settings.Restrictions = &restrictions
+	}
+
+	key := strings.ToLower(req.Key)
+	if req.RotatedKey != "" {
+		// the new key is the successor of the rotated one, which stays allowed during the grace period
+		graceExpiresAt := now.Add(handler.gracePeriod).Unix()
+		err = handler.keyAccessProvider.RotateKey(targetUser, strings.ToLower(req.RotatedKey), key, graceExpiresAt, settings)
+	} else {
+		err = handler.keyAccessProvider.AddKeyWithSettings(targetUser, key, settings)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// only the key's hash is stored, so this is the only time the full key is shown
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":        key,
+		"key_prefix": common.KeyPrefix(key),
+	})
+}
+
+type updateKeyRequest struct {
+	Key       string           `json:"key"`
+	Scope     *common.KeyScope `json:"scope,omitempty"`
+	ExpiresAt *int64           `json:"expires_at,omitempty"`
+}
+
+// handlePut replaces the scope and/or the expiry of a key, identified by its value or by its ID, in a single storage
+// call. Only the provided fields are changed: an empty scope removes the key's restrictions and a 0 expiry removes it.
+func (handler *accessKeysHandler) handlePut(w http.ResponseWriter, r *http.Request, claims *common.Claims) {
+	if !claims.IsAdmin {
+		http.Error(w, "Forbidden: Only admins can set the key scope", http.StatusForbidden)
+		return
+	}
+
+	var req updateKeyRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+	if req.Scope == nil && req.ExpiresAt == nil {
+		http.Error(w, "scope or expires_at are required", http.StatusBadRequest)
+		return
+	}
+	err = checkKeyExpiry(req.ExpiresAt, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	update := common.KeyUpdate{
+		ExpiresAt: req.ExpiresAt,
+	}
+	if req.Scope != nil {
+		scope, errScope := normalizeKeyScope(*req.Scope)
+		if errScope != nil {
+			http.Error(w, errScope.Error(), http.StatusBadRequest)
+			return
+		}
+		update.Scope = &scope
+	}
+
+	err = handler.keyAccessProvider.UpdateKey(strings.ToLower(req.Key), update)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -102,6 +239,58 @@ func (handler *accessKeysHandler) handlePost(w http.ResponseWriter, r *http.Requ
 	w.WriteHeader(http.StatusOK)
 }
 
+// handlePatch sets the label and/or the client restrictions of a key, identified by its value or by its ID. Empty
+// restrictions remove the key's restrictions.
+func (handler *accessKeysHandler) handlePatch(w http.ResponseWriter, r *http.Request, claims *common.Claims) {
+	var req addKeyRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+	if req.Label == nil && req.Restrictions == nil {
+		http.Error(w, "label or restrictions are required", http.StatusBadRequest)
+		return
+	}
+	label, err := normalizeKeyLabel(req.Label)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	restrictions, err := normalizeKeyRestrictions(req.Restrictions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	targetUser := claims.Username
+	if claims.IsAdmin && req.Username != "" {
+		targetUser = req.Username
+	}
+
+	if req.Label != nil {
+		err = handler.keyAccessProvider.SetKeyLabel(targetUser, strings.ToLower(req.Key), label)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.Restrictions != nil {
+		err = handler.keyAccessProvider.SetKeyRestrictions(targetUser, strings.ToLower(req.Key), restrictions)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 func (handler *accessKeysHandler) handleDelete(w http.ResponseWriter, r *http.Request, claims *common.Claims) {
 	key := r.URL.Query().Get("key")
 	if key == "" {
@@ -123,3 +312,145 @@ func (handler *accessKeysHandler) handleDelete(w http.ResponseWriter, r *http.Re
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// checkKeyExpiry checks that the provided expiry, a unix timestamp in seconds, is either missing, 0 (no expiry) or in the
+// future
+func checkKeyExpiry(expiresAt *int64, now time.Time) error {
+	if expiresAt == nil || *expiresAt == 0 {
+		return nil
+	}
+	if *expiresAt <= now.Unix() {
+		return fmt.Errorf("expires_at must be a future unix timestamp")
+	}
+
+	return nil
+}
+
+// normalizeKeyLabel returns the provided label without the surrounding spaces, a missing label being empty
+func normalizeKeyLabel(label *string) (string, error) {
+	if label == nil {
+		return "", nil
+	}
+
+	result := strings.TrimSpace(*label)
+	if len(result) > maxKeyLabelLength {
+		return "", fmt.Errorf("label must be at most %d characters long", maxKeyLabelLength)
+	}
+
+	return result, nil
+}
+
+// normalizeKeyRestrictions checks the provided restrictions and returns them with the single IPs turned into CIDRs
+// and the origins reduced to their lower case scheme://host[:port] form, missing restrictions being empty
+func normalizeKeyRestrictions(restrictions *common.KeyRestrictions) (common.KeyRestrictions, error) {
+	if restrictions == nil {
+		return common.KeyRestrictions{}, nil
+	}
+	if len(restrictions.AllowedCIDRs) > maxKeyRestrictionEntries || len(restrictions.AllowedOrigins) > maxKeyRestrictionEntries {
+		return common.KeyRestrictions{}, fmt.Errorf("the key restrictions can have at most %d CIDRs and %d origins", maxKeyRestrictionEntries, maxKeyRestrictionEntries)
+	}
+
+	result := common.KeyRestrictions{}
+	for _, cidr := range restrictions.AllowedCIDRs {
+		normalizedCIDR, err := normalizeCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return common.KeyRestrictions{}, err
+		}
+
+		result.AllowedCIDRs = append(result.AllowedCIDRs, normalizedCIDR)
+	}
+
+	for _, origin := range restrictions.AllowedOrigins {
+		normalizedOrigin, err := normalizeOrigin(strings.ToLower(strings.TrimSpace(origin)))
+		if err != nil {
+			return common.KeyRestrictions{}, err
+		}
+
+		result.AllowedOrigins = append(result.AllowedOrigins, normalizedOrigin)
+	}
+
+	return result, nil
+}
+
+// normalizeCIDR returns the provided CIDR without the host bits, a single IP becoming a /32 or a /128 CIDR
+func normalizeCIDR(cidr string) (string, error) {
+	if !strings.Contains(cidr, "/") {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return "", fmt.Errorf("invalid IP in the key restrictions: %s", cidr)
+		}
+		addr = addr.Unmap()
+
+		return netip.PrefixFrom(addr, addr.BitLen()).String(), nil
+	}
+
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", fmt.Errorf("invalid CIDR in the key restrictions: %s", cidr)
+	}
+
+	return prefix.Masked().String(), nil
+}
+
+// normalizeOrigin checks that the provided origin is an http or https scheme://host[:port] pattern, the host being
+// allowed to start with a *. wildcard
+func normalizeOrigin(origin string) (string, error) {
+	originURL, err := url.Parse(strings.TrimSuffix(origin, "/"))
+	if err != nil || (originURL.Scheme != "http" && originURL.Scheme != "https") {
+		return "", fmt.Errorf("invalid origin in the key restrictions: %s", origin)
+	}
+	if len(originURL.Path) > 0 || len(originURL.RawQuery) > 0 || len(originURL.Fragment) > 0 || originURL.User != nil {
+		return "", fmt.Errorf("the origin in the key restrictions should not have a path: %s", origin)
+	}
+
+	domain := strings.TrimPrefix(originURL.Hostname(), "*.")
+	if len(domain) == 0 || strings.Contains(domain, "*") {
+		return "", fmt.Errorf("invalid origin host in the key restrictions: %s", origin)
+	}
+
+	return originURL.Scheme + "://" + originURL.Host, nil
+}
+
+// normalizeKeyScope checks the provided scope and returns it with the routes starting with a slash, the methods in
+// upper case and the epochs without spaces
+func normalizeKeyScope(scope common.KeyScope) (common.KeyScope, error) {
+	result := common.KeyScope{
+		EpochStart: strings.TrimSpace(scope.EpochStart),
+		EpochEnd:   strings.ToLower(strings.TrimSpace(scope.EpochEnd)),
+	}
+
+	for _, route := range scope.Routes {
+		route = strings.Trim(strings.TrimSpace(route), "/")
+		if len(route) == 0 {
+			return common.KeyScope{}, fmt.Errorf("empty route in the key scope")
+		}
+
+		result.Routes = append(result.Routes, "/"+route)
+	}
+
+	for _, method := range scope.Methods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if !common.IsKnownHTTPMethod(method) {
+			return common.KeyScope{}, fmt.Errorf("unknown method in the key scope: %s", method)
+		}
+
+		result.Methods = append(result.Methods, method)
+	}
+
+	epochStart := uint64(0)
+	if len(result.EpochStart) > 0 {
+		var err error
+		epochStart, err = strconv.ParseUint(result.EpochStart, 10, 64)
+		if err != nil {
+			return common.KeyScope{}, fmt.Errorf("invalid start epoch in the key scope: %s", result.EpochStart)
+		}
+	}
+	if len(result.EpochEnd) > 0 && result.EpochEnd != latestEpoch {
+		epochEnd, err := strconv.ParseUint(result.EpochEnd, 10, 64)
+		if err != nil || epochEnd < epochStart {
+			return common.KeyScope{}, fmt.Errorf("invalid end epoch in the key scope: %s", result.EpochEnd)
+		}
+	}
+
+	return result, nil
+}
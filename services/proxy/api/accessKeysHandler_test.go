@@ -3,12 +3,16 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -18,19 +22,19 @@ func TestNewAccessKeysHandler(t *testing.T) {
 	t.Parallel()
 
 	t.Run("nil provider", func(t *testing.T) {
-		handler, err := NewAccessKeysHandler(nil, &testscommon.AuthenticatorStub{})
+		handler, err := NewAccessKeysHandler(nil, &testscommon.AuthenticatorStub{}, config.KeysRotationConfig{})
 		assert.Nil(t, handler)
 		assert.Equal(t, errNilKeyAccessProvider, err)
 	})
 
 	t.Run("nil authenticator", func(t *testing.T) {
-		handler, err := NewAccessKeysHandler(&testscommon.StorerStub{}, nil)
+		handler, err := NewAccessKeysHandler(&testscommon.StorerStub{}, nil, config.KeysRotationConfig{})
 		assert.Nil(t, handler)
 		assert.Equal(t, errNilAuthenticator, err)
 	})
 
 	t.Run("ok", func(t *testing.T) {
-		handler, err := NewAccessKeysHandler(&testscommon.StorerStub{}, &testscommon.AuthenticatorStub{})
+		handler, err := NewAccessKeysHandler(&testscommon.StorerStub{}, &testscommon.AuthenticatorStub{}, config.KeysRotationConfig{})
 		assert.NotNil(t, handler)
 		assert.Nil(t, err)
 	})
@@ -45,7 +49,7 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 		username := "user1"
 		token, _ := auth.GenerateToken(username, false)
 
-		handler, _ := NewAccessKeysHandler(&testscommon.StorerStub{}, auth)
+		handler, _ := NewAccessKeysHandler(&testscommon.StorerStub{}, auth, config.KeysRotationConfig{})
 		req := httptest.NewRequest(http.MethodTrace, "/api/admin-access-keys", nil)
 		resp := httptest.NewRecorder()
 
@@ -56,7 +60,7 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 	})
 
 	t.Run("unauthorized - no token", func(t *testing.T) {
-		handler, _ := NewAccessKeysHandler(&testscommon.StorerStub{}, auth)
+		handler, _ := NewAccessKeysHandler(&testscommon.StorerStub{}, auth, config.KeysRotationConfig{})
 		req := httptest.NewRequest(http.MethodGet, "/api/admin-access-keys", nil)
 		resp := httptest.NewRecorder()
 
@@ -72,11 +76,12 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 			GetAllKeysHandler: func(usr string) (map[string]common.AccessKeyDetails, error) {
 				assert.Equal(t, username, usr)
 				return map[string]common.AccessKeyDetails{
-					"key1": {MaxRequests: 100},
+					"id2": {ID: "id2", MaxRequests: 100},
+					"id1": {ID: "id1", MaxRequests: 100},
 				}, nil
 			},
 		}
-		handler, _ := NewAccessKeysHandler(provider, auth)
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
 		req := httptest.NewRequest(http.MethodGet, "/api/admin-access-keys", nil)
 		req.Header.Set("Authorization", "Bearer "+token)
 		resp := httptest.NewRecorder()
@@ -84,10 +89,13 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 		handler.ServeHTTP(resp, req)
 		assert.Equal(t, http.StatusOK, resp.Code)
 
-		var keys map[string]common.AccessKeyDetails
+		// the keys are listed as an array ordered by their IDs, even if no sort field is requested
+		var keys []common.AccessKeyDetails
 		err := json.NewDecoder(resp.Body).Decode(&keys)
 		assert.Nil(t, err)
-		assert.Len(t, keys, 1)
+		require.Len(t, keys, 2)
+		assert.Equal(t, "id1", keys[0].ID)
+		assert.Equal(t, "id2", keys[1].ID)
 	})
 
 	t.Run("authorized - get keys as admin", func(t *testing.T) {
@@ -102,7 +110,7 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 				}, nil
 			},
 		}
-		handler, _ := NewAccessKeysHandler(provider, auth)
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
 		req := httptest.NewRequest(http.MethodGet, "/api/admin-access-keys", nil)
 		req.Header.Set("Authorization", "Bearer "+token)
 		resp := httptest.NewRecorder()
@@ -110,12 +118,58 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 		handler.ServeHTTP(resp, req)
 		assert.Equal(t, http.StatusOK, resp.Code)
 
-		var keys map[string]common.AccessKeyDetails
+		var keys []common.AccessKeyDetails
 		err := json.NewDecoder(resp.Body).Decode(&keys)
 		assert.Nil(t, err)
 		assert.Len(t, keys, 1)
 	})
 
+	t.Run("authorized - get sorted and filtered keys", func(t *testing.T) {
+		token, _ := auth.GenerateToken("user1", false)
+
+		provider := &testscommon.StorerStub{
+			GetAllKeysHandler: func(usr string) (map[string]common.AccessKeyDetails, error) {
+				return map[string]common.AccessKeyDetails{
+					"id1": {ID: "id1", Label: "indexer", CreatedAt: 200},
+					"id2": {ID: "id2", Label: "wallet", CreatedAt: 100},
+					"id3": {ID: "id3", Label: "indexer backup", CreatedAt: 100},
+				}, nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+		req := httptest.NewRequest(http.MethodGet, "/api/admin-access-keys?label=indexer&sort=created", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var keys []common.AccessKeyDetails
+		err := json.NewDecoder(resp.Body).Decode(&keys)
+		assert.Nil(t, err)
+		require.Len(t, keys, 2)
+		assert.Equal(t, "id3", keys[0].ID)
+		assert.Equal(t, "id1", keys[1].ID)
+	})
+
+	t.Run("get keys with invalid query - bad request", func(t *testing.T) {
+		token, _ := auth.GenerateToken("user1", false)
+
+		provider := &testscommon.StorerStub{
+			GetAllKeysHandler: func(usr string) (map[string]common.AccessKeyDetails, error) {
+				assert.Fail(t, "should not be called")
+				return nil, nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+		req := httptest.NewRequest(http.MethodGet, "/api/admin-access-keys?sort=unknown", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+
 	t.Run("post - success", func(t *testing.T) {
 		t.Parallel()
 
@@ -124,14 +178,14 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 		token, _ := auth.GenerateToken(expectedUsername, true)
 
 		provider := &testscommon.StorerStub{
-			AddKeyHandler: func(username string, key string) error {
+			AddKeyWithSettingsHandler: func(username string, key string, settings common.KeySettings) error {
 				assert.Equal(t, expectedUsername, username)
 				assert.Equal(t, expectedKey, key)
 				return nil
 			},
 		}
 
-		handler, err := NewAccessKeysHandler(provider, auth)
+		handler, err := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
 		require.Nil(t, err)
 
 		reqBody := addKeyRequest{
@@ -147,6 +201,34 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 	})
 
+	t.Run("post should return the full key once", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("user1", false)
+
+		handler, err := NewAccessKeysHandler(&testscommon.StorerStub{}, auth, config.KeysRotationConfig{})
+		require.Nil(t, err)
+
+		reqBody := addKeyRequest{
+			Key: "KEY1_longer_than_12_chars",
+		}
+		bodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var resp map[string]string
+		err = json.Unmarshal(w.Body.Bytes(), &resp)
+		require.Nil(t, err)
+		assert.Equal(t, "key1_longer_than_12_chars", resp["key"])
+		assert.Equal(t, "key1_l", resp["key_prefix"])
+	})
+
 	t.Run("post as admin - success", func(t *testing.T) {
 		t.Parallel()
 
@@ -156,14 +238,14 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 		token, _ := auth.GenerateToken(admin, true)
 
 		provider := &testscommon.StorerStub{
-			AddKeyHandler: func(username string, key string) error {
+			AddKeyWithSettingsHandler: func(username string, key string, settings common.KeySettings) error {
 				assert.Equal(t, expectedUsername, username)
 				assert.Equal(t, expectedKey, key)
 				return nil
 			},
 		}
 
-		handler, err := NewAccessKeysHandler(provider, auth)
+		handler, err := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
 		require.Nil(t, err)
 
 		reqBody := addKeyRequest{
@@ -187,14 +269,14 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 		token, _ := auth.GenerateToken(expectedUsername, true)
 
 		provider := &testscommon.StorerStub{
-			AddKeyHandler: func(username string, key string) error {
+			AddKeyWithSettingsHandler: func(username string, key string, settings common.KeySettings) error {
 				assert.Equal(t, expectedUsername, username)
 				assert.Len(t, key, 32)
 				return nil
 			},
 		}
 
-		handler, err := NewAccessKeysHandler(provider, auth)
+		handler, err := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
 		require.Nil(t, err)
 
 		reqBody := addKeyRequest{Key: ""}
@@ -212,7 +294,7 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 		t.Parallel()
 
 		token, _ := auth.GenerateToken("admin", true)
-		handler, _ := NewAccessKeysHandler(&testscommon.StorerStub{}, auth)
+		handler, _ := NewAccessKeysHandler(&testscommon.StorerStub{}, auth, config.KeysRotationConfig{})
 
 		reqBody := addKeyRequest{Key: "short"}
 		bodyBytes, _ := json.Marshal(reqBody)
@@ -226,6 +308,556 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "key must be at least 12 characters long")
 	})
 
+	t.Run("post with scope as admin - success", func(t *testing.T) {
+		t.Parallel()
+
+		expectedKey := "key1_longer_than_12_chars"
+		token, _ := auth.GenerateToken("admin", true)
+
+		var providedScope common.KeyScope
+		provider := &testscommon.StorerStub{
+			AddKeyWithSettingsHandler: func(username string, key string, settings common.KeySettings) error {
+				assert.Equal(t, "partner", username)
+				assert.Equal(t, expectedKey, key)
+				providedScope = settings.Scope
+				return nil
+			},
+		}
+
+		handler, err := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+		require.Nil(t, err)
+
+		reqBody := addKeyRequest{
+			Key:      expectedKey,
+			Username: "partner",
+			Scope: common.KeyScope{
+				Routes:     []string{"address/", " /network "},
+				Methods:    []string{"get"},
+				EpochStart: "0",
+				EpochEnd:   "1000",
+			},
+		}
+		bodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		expectedScope := common.KeyScope{
+			Routes:     []string{"/address", "/network"},
+			Methods:    []string{http.MethodGet},
+			EpochStart: "0",
+			EpochEnd:   "1000",
+		}
+		assert.Equal(t, expectedScope, providedScope)
+	})
+
+	t.Run("post with scope as user - forbidden", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("user1", false)
+		provider := &testscommon.StorerStub{
+			AddKeyWithSettingsHandler: func(username string, key string, settings common.KeySettings) error {
+				assert.Fail(t, "should not be called")
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		reqBody := addKeyRequest{
+			Key:   "key1_longer_than_12_chars",
+			Scope: common.KeyScope{Methods: []string{http.MethodGet}},
+		}
+		bodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("post with invalid scope - bad request", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("admin", true)
+		provider := &testscommon.StorerStub{
+			AddKeyWithSettingsHandler: func(username string, key string, settings common.KeySettings) error {
+				assert.Fail(t, "should not be called")
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		reqBody := addKeyRequest{
+			Key:   "key1_longer_than_12_chars",
+			Scope: common.KeyScope{Methods: []string{"FETCH"}},
+		}
+		bodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unknown method in the key scope: FETCH")
+	})
+
+	t.Run("put as user - forbidden", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("user1", false)
+		provider := &testscommon.StorerStub{
+			UpdateKeyHandler: func(key string, update common.KeyUpdate) error {
+				assert.Fail(t, "should not be called")
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		bodyBytes := []byte(`{"key": "key1_longer_than_12_chars", "scope": {}}`)
+		req := httptest.NewRequest(http.MethodPut, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("put fails if no key is provided", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("admin", true)
+		handler, _ := NewAccessKeysHandler(&testscommon.StorerStub{}, auth, config.KeysRotationConfig{})
+
+		bodyBytes := []byte(`{"scope": {}}`)
+		req := httptest.NewRequest(http.MethodPut, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("put fails if neither the scope nor the expiry is provided", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("admin", true)
+		provider := &testscommon.StorerStub{
+			UpdateKeyHandler: func(key string, update common.KeyUpdate) error {
+				assert.Fail(t, "should not be called")
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		bodyBytes := []byte(`{"key": "key1_longer_than_12_chars"}`)
+		req := httptest.NewRequest(http.MethodPut, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "scope or expires_at are required")
+	})
+
+	t.Run("put - success", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("admin", true)
+		var providedUpdates []common.KeyUpdate
+		provider := &testscommon.StorerStub{
+			UpdateKeyHandler: func(key string, update common.KeyUpdate) error {
+				assert.Equal(t, "key1_longer_than_12_chars", key)
+				providedUpdates = append(providedUpdates, update)
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		bodyBytes := []byte(`{"key": "KEY1_longer_than_12_chars", "scope": {"Methods": ["GET", "post"], "EpochEnd": "Latest"}}`)
+		req := httptest.NewRequest(http.MethodPut, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// an empty scope removes the restrictions
+		bodyBytes = []byte(`{"key": "key1_longer_than_12_chars", "scope": {}}`)
+		req = httptest.NewRequest(http.MethodPut, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		expectedUpdates := []common.KeyUpdate{
+			{Scope: &common.KeyScope{Methods: []string{http.MethodGet, http.MethodPost}, EpochEnd: "latest"}},
+			{Scope: &common.KeyScope{}},
+		}
+		assert.Equal(t, expectedUpdates, providedUpdates)
+	})
+
+	t.Run("put with expiry - success", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("admin", true)
+		expiresAt := time.Now().Add(time.Hour).Unix()
+		var providedUpdates []common.KeyUpdate
+		provider := &testscommon.StorerStub{
+			UpdateKeyHandler: func(key string, update common.KeyUpdate) error {
+				assert.Equal(t, "key1_longer_than_12_chars", key)
+				providedUpdates = append(providedUpdates, update)
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		bodyBytes := []byte(fmt.Sprintf(`{"key": "KEY1_longer_than_12_chars", "expires_at": %d}`, expiresAt))
+		req := httptest.NewRequest(http.MethodPut, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// a 0 expiry removes it
+		bodyBytes = []byte(`{"key": "key1_longer_than_12_chars", "expires_at": 0}`)
+		req = httptest.NewRequest(http.MethodPut, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// the scope is not provided, so it is not changed
+		noExpiry := int64(0)
+		expectedUpdates := []common.KeyUpdate{
+			{ExpiresAt: &expiresAt},
+			{ExpiresAt: &noExpiry},
+		}
+		assert.Equal(t, expectedUpdates, providedUpdates)
+	})
+
+	t.Run("put with scope and expiry - storage failure", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("admin", true)
+		expiresAt := time.Now().Add(time.Hour).Unix()
+		numCalls := 0
+		provider := &testscommon.StorerStub{
+			UpdateKeyHandler: func(key string, update common.KeyUpdate) error {
+				numCalls++
+				assert.Equal(t, &common.KeyScope{Methods: []string{http.MethodGet}}, update.Scope)
+				assert.Equal(t, &expiresAt, update.ExpiresAt)
+				return errors.New("failed to update the key")
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		bodyBytes := []byte(fmt.Sprintf(`{"key": "key1_longer_than_12_chars", "scope": {"Methods": ["GET"]}, "expires_at": %d}`, expiresAt))
+		req := httptest.NewRequest(http.MethodPut, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "failed to update the key")
+		assert.Equal(t, 1, numCalls)
+	})
+
+	t.Run("post with expiry - success", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("user1", false)
+		expiresAt := time.Now().Add(time.Hour).Unix()
+		addKeyCalled := false
+		provider := &testscommon.StorerStub{
+			AddKeyWithSettingsHandler: func(username string, key string, settings common.KeySettings) error {
+				assert.Equal(t, "key1_longer_than_12_chars", key)
+				assert.Equal(t, common.KeySettings{ExpiresAt: expiresAt}, settings)
+				addKeyCalled = true
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		bodyBytes := []byte(fmt.Sprintf(`{"key": "KEY1_longer_than_12_chars", "expires_at": %d}`, expiresAt))
+		req := httptest.NewRequest(http.MethodPost, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, addKeyCalled)
+	})
+
+	t.Run("post with past expiry - bad request", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("user1", false)
+		provider := &testscommon.StorerStub{
+			AddKeyWithSettingsHandler: func(username string, key string, settings common.KeySettings) error {
+				assert.Fail(t, "should not be called")
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		bodyBytes := []byte(`{"key": "key1_longer_than_12_chars", "expires_at": 1000}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("post with rotated key - success", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("user1", false)
+		gracePeriod := time.Hour
+		provider := &testscommon.StorerStub{
+			AddKeyWithSettingsHandler: func(username string, key string, settings common.KeySettings) error {
+				assert.Fail(t, "should not be called")
+				return nil
+			},
+			RotateKeyHandler: func(username string, key string, newKey string, graceExpiresAt int64, settings common.KeySettings) error {
+				assert.Equal(t, "user1", username)
+				assert.Equal(t, "old_key_longer_than_12_chars", key)
+				assert.Len(t, newKey, 32)
+				assert.InDelta(t, time.Now().Add(gracePeriod).Unix(), graceExpiresAt, 5)
+				assert.Equal(t, common.KeySettings{}, settings)
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{
+			GracePeriodInSeconds: uint64(gracePeriod.Seconds()),
+		})
+
+		bodyBytes := []byte(`{"rotated_key": "OLD_key_longer_than_12_chars"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]string
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		require.Nil(t, err)
+		assert.Len(t, resp["key"], 32)
+	})
+
+	t.Run("post with rotated key - rotation failure", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("user1", false)
+		provider := &testscommon.StorerStub{
+			RotateKeyHandler: func(username string, key string, newKey string, graceExpiresAt int64, settings common.KeySettings) error {
+				return errors.New("key not found")
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		bodyBytes := []byte(`{"rotated_key": "old_key_longer_than_12_chars"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "key not found")
+	})
+
+	t.Run("post with label - success", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("user1", false)
+		addKeyCalled := false
+		provider := &testscommon.StorerStub{
+			AddKeyWithSettingsHandler: func(username string, key string, settings common.KeySettings) error {
+				assert.Equal(t, "user1", username)
+				assert.Equal(t, "key1_longer_than_12_chars", key)
+				require.NotNil(t, settings.Label)
+				assert.Equal(t, "indexer", *settings.Label)
+				assert.Nil(t, settings.Restrictions)
+				addKeyCalled = true
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		bodyBytes := []byte(`{"key": "key1_longer_than_12_chars", "label": " indexer "}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, addKeyCalled)
+	})
+
+	t.Run("patch - success", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("user1", false)
+		setKeyLabelCalled := false
+		provider := &testscommon.StorerStub{
+			SetKeyLabelHandler: func(username string, key string, label string) error {
+				assert.Equal(t, "user1", username)
+				assert.Equal(t, "key_id", key)
+				assert.Empty(t, label)
+				setKeyLabelCalled = true
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		// the username is ignored for the non-admin users
+		bodyBytes := []byte(`{"key": "KEY_id", "label": "", "username": "user2"}`)
+		req := httptest.NewRequest(http.MethodPatch, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, setKeyLabelCalled)
+	})
+
+	t.Run("patch restrictions - success", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("admin", true)
+		setKeyRestrictionsCalled := false
+		provider := &testscommon.StorerStub{
+			SetKeyLabelHandler: func(username string, key string, label string) error {
+				assert.Fail(t, "should not be called")
+				return nil
+			},
+			SetKeyRestrictionsHandler: func(username string, key string, restrictions common.KeyRestrictions) error {
+				assert.Equal(t, "user2", username)
+				assert.Equal(t, "key_id", key)
+				assert.Equal(t, common.KeyRestrictions{
+					AllowedCIDRs:   []string{"10.0.0.1/32"},
+					AllowedOrigins: []string{"https://*.example.com"},
+				}, restrictions)
+				setKeyRestrictionsCalled = true
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		bodyBytes := []byte(`{"key": "key_id", "username": "user2", "restrictions": {"AllowedCIDRs": ["10.0.0.1"], "AllowedOrigins": ["https://*.Example.com/"]}}`)
+		req := httptest.NewRequest(http.MethodPatch, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, setKeyRestrictionsCalled)
+	})
+
+	t.Run("post with restrictions - success", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("user1", false)
+		addKeyCalled := false
+		provider := &testscommon.StorerStub{
+			AddKeyWithSettingsHandler: func(username string, key string, settings common.KeySettings) error {
+				assert.Equal(t, "user1", username)
+				assert.Equal(t, "key1_longer_than_12_chars", key)
+				require.NotNil(t, settings.Restrictions)
+				assert.Equal(t, common.KeyRestrictions{AllowedCIDRs: []string{"10.0.0.0/8"}}, *settings.Restrictions)
+				assert.Nil(t, settings.Label)
+				addKeyCalled = true
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		bodyBytes := []byte(`{"key": "key1_longer_than_12_chars", "restrictions": {"AllowedCIDRs": ["10.1.2.3/8"]}}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, addKeyCalled)
+	})
+
+	t.Run("post with settings - storage failure should not write the settings separately", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("admin", true)
+		provider := &testscommon.StorerStub{
+			AddKeyWithSettingsHandler: func(username string, key string, settings common.KeySettings) error {
+				return errors.New("failed to insert key")
+			},
+			UpdateKeyHandler: func(key string, update common.KeyUpdate) error {
+				assert.Fail(t, "should not be called")
+				return nil
+			},
+			SetKeyLabelHandler: func(username string, key string, label string) error {
+				assert.Fail(t, "should not be called")
+				return nil
+			},
+			SetKeyRestrictionsHandler: func(username string, key string, restrictions common.KeyRestrictions) error {
+				assert.Fail(t, "should not be called")
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		bodyBytes := []byte(fmt.Sprintf(`{"key": "key1_longer_than_12_chars", "label": "indexer", "expires_at": %d, `+
+			`"restrictions": {"AllowedCIDRs": ["10.0.0.0/8"]}, "scope": {"Methods": ["GET"]}}`, time.Now().Add(time.Hour).Unix()))
+		req := httptest.NewRequest(http.MethodPost, "/api/admin-access-keys", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "failed to insert key")
+	})
+
+	t.Run("patch with invalid values - bad request", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("user1", false)
+		provider := &testscommon.StorerStub{
+			SetKeyLabelHandler: func(username string, key string, label string) error {
+				assert.Fail(t, "should not be called")
+				return nil
+			},
+		}
+		handler, _ := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
+
+		invalidBodies := []string{
+			`{"label": "indexer"}`,
+			`{"key": "key_id"}`,
+			`{"key": "key_id", "label": "` + strings.Repeat("a", maxKeyLabelLength+1) + `"}`,
+			`{"key": "key_id", "restrictions": {"AllowedCIDRs": ["10.0.0.256"]}}`,
+			`{"key": "key_id", "restrictions": {"AllowedOrigins": ["example.com"]}}`,
+		}
+		for _, body := range invalidBodies {
+			req := httptest.NewRequest(http.MethodPatch, "/api/admin-access-keys", bytes.NewBufferString(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+
 	t.Run("delete fails if no key is provided", func(t *testing.T) {
 		t.Parallel()
 
@@ -239,7 +871,7 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 			},
 		}
 
-		handler, err := NewAccessKeysHandler(provider, auth)
+		handler, err := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
 		require.Nil(t, err)
 
 		req := httptest.NewRequest(http.MethodDelete, "/api/admin-access-keys?key=", nil)
@@ -266,7 +898,7 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 			},
 		}
 
-		handler, err := NewAccessKeysHandler(provider, auth)
+		handler, err := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
 		require.Nil(t, err)
 
 		req := httptest.NewRequest(http.MethodDelete, "/api/admin-access-keys?key="+expectedKey, nil)
@@ -294,7 +926,7 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 			},
 		}
 
-		handler, err := NewAccessKeysHandler(provider, auth)
+		handler, err := NewAccessKeysHandler(provider, auth, config.KeysRotationConfig{})
 		require.Nil(t, err)
 
 		req := httptest.NewRequest(http.MethodDelete, "/api/admin-access-keys?key="+expectedKey+"&username="+expectedUsername, nil)
@@ -306,3 +938,108 @@ func TestAccessKeysHandler_ServeHTTP(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 	})
 }
+
+func TestCheckKeyExpiry(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	expiry := func(value int64) *int64 {
+		return &value
+	}
+
+	assert.Nil(t, checkKeyExpiry(nil, now))
+	assert.Nil(t, checkKeyExpiry(expiry(0), now))
+	assert.Nil(t, checkKeyExpiry(expiry(1001), now))
+	assert.NotNil(t, checkKeyExpiry(expiry(1000), now))
+	assert.NotNil(t, checkKeyExpiry(expiry(999), now))
+	assert.NotNil(t, checkKeyExpiry(expiry(-1), now))
+}
+
+func TestNormalizeKeyRestrictions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing restrictions should be empty", func(t *testing.T) {
+		t.Parallel()
+
+		restrictions, err := normalizeKeyRestrictions(nil)
+		assert.Nil(t, err)
+		assert.True(t, restrictions.IsEmpty())
+	})
+	t.Run("invalid values should error", func(t *testing.T) {
+		t.Parallel()
+
+		invalidRestrictions := []common.KeyRestrictions{
+			{AllowedCIDRs: []string{"10.0.0.0/33"}},
+			{AllowedCIDRs: []string{"localhost"}},
+			{AllowedCIDRs: make([]string, maxKeyRestrictionEntries+1)},
+			{AllowedOrigins: []string{"ftp://example.com"}},
+			{AllowedOrigins: []string{"https://"}},
+			{AllowedOrigins: []string{"https://*"}},
+			{AllowedOrigins: []string{"https://a.*.example.com"}},
+			{AllowedOrigins: []string{"https://example.com/path"}},
+			{AllowedOrigins: []string{"https://example.com?a=b"}},
+			{AllowedOrigins: []string{"https://user@example.com"}},
+		}
+		for _, restrictions := range invalidRestrictions {
+			_, err := normalizeKeyRestrictions(&restrictions)
+			assert.NotNil(t, err, restrictions)
+		}
+	})
+	t.Run("should normalize the values", func(t *testing.T) {
+		t.Parallel()
+
+		restrictions, err := normalizeKeyRestrictions(&common.KeyRestrictions{
+			AllowedCIDRs:   []string{" 192.168.1.7 ", "10.1.2.3/8", "2001:db8::1", "::ffff:10.0.0.1"},
+			AllowedOrigins: []string{" HTTPS://App.Example.com/ ", "http://*.example.org:8080"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, common.KeyRestrictions{
+			AllowedCIDRs:   []string{"192.168.1.7/32", "10.0.0.0/8", "2001:db8::1/128", "10.0.0.1/32"},
+			AllowedOrigins: []string{"https://app.example.com", "http://*.example.org:8080"},
+		}, restrictions)
+	})
+}
+
+func TestNormalizeKeyScope(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty scope should work", func(t *testing.T) {
+		t.Parallel()
+
+		scope, err := normalizeKeyScope(common.KeyScope{})
+		assert.Nil(t, err)
+		assert.True(t, scope.IsEmpty())
+	})
+	t.Run("invalid values should error", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := normalizeKeyScope(common.KeyScope{Routes: []string{" / "}})
+		assert.ErrorContains(t, err, "empty route in the key scope")
+
+		_, err = normalizeKeyScope(common.KeyScope{EpochStart: "a"})
+		assert.ErrorContains(t, err, "invalid start epoch in the key scope: a")
+
+		_, err = normalizeKeyScope(common.KeyScope{EpochEnd: "-1"})
+		assert.ErrorContains(t, err, "invalid end epoch in the key scope: -1")
+
+		_, err = normalizeKeyScope(common.KeyScope{EpochStart: "100", EpochEnd: "99"})
+		assert.ErrorContains(t, err, "invalid end epoch in the key scope: 99")
+	})
+	t.Run("should normalize the values", func(t *testing.T) {
+		t.Parallel()
+
+		scope, err := normalizeKeyScope(common.KeyScope{
+			Routes:     []string{"transaction/send"},
+			Methods:    []string{" post"},
+			EpochStart: " 100 ",
+			EpochEnd:   " LATEST",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, common.KeyScope{
+			Routes:     []string{"/transaction/send"},
+			Methods:    []string{http.MethodPost},
+			EpochStart: "100",
+			EpochEnd:   "latest",
+		}, scope)
+	})
+}
@@ -2,18 +2,24 @@ package api
 
 // Constants for endpoint namings
 const (
-	EndpointApiAccessKeys         = "/api/admin-access-keys"
-	EndpointApiAdminUsers         = "/api/admin-users"
-	EndpointApiLogin              = "/api/login"
-	EndpointApiRegister           = "/api/register"
-	EndpointApiActivate           = "/api/activate"
-	EndpointCaptchaMultiple       = "/api/captcha/"
-	EndpointCaptchaSingle         = "/api/captcha"
-	EndpointAppInfo               = "/api/app-info"
-	EndpointApiPerformance        = "/api/performance"
-	EndpointApiChangePassword     = "/api/change-password"
-	EndpointApiRequestEmailChange = "/api/request-email-change"
-	EndpointApiConfirmEmailChange = "/api/confirm-email-change"
+	EndpointApiAccessKeys          = "/api/admin-access-keys"
+	EndpointApiAdminUsers          = "/api/admin-users"
+	EndpointApiLogin               = "/api/login"
+	EndpointApiRegister            = "/api/register"
+	EndpointApiActivate            = "/api/activate"
+	EndpointCaptchaMultiple        = "/api/captcha/"
+	EndpointCaptchaSingle          = "/api/captcha"
+	EndpointAppInfo                = "/api/app-info"
+	EndpointApiPerformance         = "/api/performance"
+	EndpointApiAdminGatewaysHealth = "/api/admin-gateways-health"
+	EndpointApiAdminReloadGateways = "/api/admin-reload-gateways"
+	EndpointApiAdminProxyMetrics   = "/api/admin-proxy-metrics"
+	EndpointApiAdminGatewaysRanges = "/api/admin-gateways-ranges"
+	EndpointApiAdminResponseCache  = "/api/admin-response-cache"
+	EndpointApiAdminKeyViolations  = "/api/admin-key-violations"
+	EndpointApiChangePassword      = "/api/change-password"
+	EndpointApiRequestEmailChange  = "/api/request-email-change"
+	EndpointApiConfirmEmailChange  = "/api/confirm-email-change"
 
 	EndpointApiCryptoPaymentConfig        = "/api/crypto-payment/config"
 	EndpointApiCryptoPaymentCreateAddress = "/api/crypto-payment/create-address"
@@ -8,5 +8,13 @@ var errNilEmailSender = errors.New("nil email sender")
 var errEmptyHTMLTemplate = errors.New("empty HTML template")
 var errNilCaptchaHandler = errors.New("nil captcha handler")
 var errNilAuthenticator = errors.New("nil authenticator")
+var errNilClientIPResolver = errors.New("nil client IP resolver")
 var errNilCryptoPaymentClient = errors.New("nil crypto payment client")
 var errNilMutexHandler = errors.New("nil mutex handler")
+var errUnexpectedGatewayStatus = errors.New("unexpected gateway status code")
+var errNilGatewaysHealthProvider = errors.New("nil gateways health provider")
+var errNilKeyViolationsProvider = errors.New("nil key violations provider")
+var errNilGatewaysReloader = errors.New("nil gateways reloader")
+var errNilMetricsProvider = errors.New("nil metrics provider")
+var errNilGatewaysDiscoveryReportProvider = errors.New("nil gateways discovery report provider")
+var errNilResponseCachePurger = errors.New("nil response cache purger")
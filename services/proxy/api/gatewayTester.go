@@ -1,9 +1,12 @@
 package api
 
 import (
+	"context"
+	"fmt"
 	"net/http"
-	"net/url"
+	"time"
 
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	logger "github.com/multiversx/mx-chain-logger-go"
 )
@@ -12,39 +15,85 @@ const configRoute = "/network/config"
 
 var log = logger.GetOrCreate("api")
 
+// ArgsGatewayTester is the DTO used to create a new gateway tester
+type ArgsGatewayTester struct {
+	TestTimeout  time.Duration
+	ProbeTimeout time.Duration
+}
+
 type gatewayTester struct {
+	testHttpClient  *http.Client
+	probeHttpClient *http.Client
 }
 
-// NewGatewayTester returns an instance of type gatewayTester
-func NewGatewayTester() *gatewayTester {
-	return &gatewayTester{}
+// NewGatewayTester returns an instance of type gatewayTester. The test timeout is applied on each request sent by
+// TestGateways and the probe timeout on each request sent by ProbeGateway, a 0 value meaning no timeout.
+func NewGatewayTester(args ArgsGatewayTester) *gatewayTester {
+	return &gatewayTester{
+		testHttpClient: &http.Client{
+			Timeout: args.TestTimeout,
+		},
+		probeHttpClient: &http.Client{
+			Timeout: args.ProbeTimeout,
+		},
+	}
 }
 
-// TestGateways will probe the provided gateways and return an error if one gateway does not respond
+// TestGateways will probe the provided gateways (including their replicas) and return an error if one gateway does not
+// respond. The gateways responding with any status code are considered running.
 func (tester *gatewayTester) TestGateways(gateways []config.GatewayConfig) error {
 	for _, gateway := range gateways {
-		log.Debug("probing gateway...", "URL", gateway.URL)
-		err := tester.testGateway(gateway)
-		if err != nil {
-			return err
+		upstreams := []config.GatewayConfig{gateway}
+		for _, replica := range gateway.Replicas {
+			upstream := gateway
+			upstream.URL = replica.URL
+			upstreams = append(upstreams, upstream)
 		}
 
-		log.Info("Gateway running", "URL", gateway.URL)
+		for _, upstream := range upstreams {
+			log.Debug("probing gateway...", "URL", upstream.URL)
+			_, err := tester.get(tester.testHttpClient, upstream)
+			if err != nil {
+				return err
+			}
+
+			log.Info("Gateway running", "URL", upstream.URL)
+		}
 	}
 
 	return nil
 }
 
-func (tester *gatewayTester) testGateway(gateway config.GatewayConfig) error {
-	fullURL, err := url.JoinPath(gateway.URL, configRoute)
+// ProbeGateway will probe the provided gateway upstream and return an error if the gateway does not respond with a
+// 200 OK status. The path rewrites, upstream headers and credentials of the gateway are applied on the probe request.
+func (tester *gatewayTester) ProbeGateway(upstream config.GatewayConfig) error {
+	resp, err := tester.get(tester.probeHttpClient, upstream)
 	if err != nil {
 		return err
 	}
 
-	resp, err := http.Get(fullURL)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %d", errUnexpectedGatewayStatus, resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (tester *gatewayTester) get(httpClient *http.Client, upstream config.GatewayConfig) (*http.Response, error) {
+	request, err := common.NewUpstreamRequest(context.Background(), http.MethodGet, upstream, configRoute, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(request)
 	if resp != nil && resp.Body != nil {
 		_ = resp.Body.Close()
 	}
 
-	return err
+	return resp, err
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (tester *gatewayTester) IsInterfaceNil() bool {
+	return tester == nil
 }
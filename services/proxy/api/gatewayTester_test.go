@@ -3,7 +3,9 @@ package api
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
@@ -13,14 +15,26 @@ import (
 func TestNewGatewayTester(t *testing.T) {
 	t.Parallel()
 
-	instance := NewGatewayTester()
+	instance := NewGatewayTester(ArgsGatewayTester{
+		TestTimeout:  time.Second * 2,
+		ProbeTimeout: time.Second,
+	})
 	assert.NotNil(t, instance)
+	assert.Equal(t, time.Second*2, instance.testHttpClient.Timeout)
+	assert.Equal(t, time.Second, instance.probeHttpClient.Timeout)
+}
+
+func createTestGatewayTester() *gatewayTester {
+	return NewGatewayTester(ArgsGatewayTester{
+		TestTimeout:  time.Second,
+		ProbeTimeout: time.Second,
+	})
 }
 
 func TestGatewayTester_TestGateways(t *testing.T) {
 	t.Parallel()
 
-	tester := NewGatewayTester()
+	tester := createTestGatewayTester()
 	t.Run("wrong URL should error", func(t *testing.T) {
 		t.Parallel()
 
@@ -43,6 +57,38 @@ func TestGatewayTester_TestGateways(t *testing.T) {
 		assert.NotNil(t, err)
 		assert.Contains(t, err.Error(), "unsupported protocol")
 	})
+	t.Run("non 200 status code should not error", func(t *testing.T) {
+		t.Parallel()
+
+		serverA := createTestHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		defer serverA.Close()
+
+		serverB := createTestHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		defer serverB.Close()
+
+		err := tester.TestGateways([]config.GatewayConfig{{URL: serverA.URL}, {URL: serverB.URL}})
+		assert.Nil(t, err)
+	})
+	t.Run("timeout should error", func(t *testing.T) {
+		t.Parallel()
+
+		server := createTestHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(time.Millisecond * 200)
+			w.WriteHeader(http.StatusOK)
+		})
+		defer server.Close()
+
+		fastTester := NewGatewayTester(ArgsGatewayTester{
+			TestTimeout:  time.Millisecond * 50,
+			ProbeTimeout: time.Second,
+		})
+		err := fastTester.TestGateways([]config.GatewayConfig{{URL: server.URL}})
+		assert.NotNil(t, err)
+	})
 	t.Run("should work", func(t *testing.T) {
 		t.Parallel()
 
@@ -77,6 +123,143 @@ func TestGatewayTester_TestGateways(t *testing.T) {
 		assert.True(t, handlerAWasCalled)
 		assert.True(t, handlerBWasCalled)
 	})
+	t.Run("should probe the replicas", func(t *testing.T) {
+		t.Parallel()
+
+		handlerA := func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}
+		serverA := createTestHTTPServer(handlerA)
+		defer serverA.Close()
+
+		handlerReplicaWasCalled := false
+		handlerReplica := func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			handlerReplicaWasCalled = true
+		}
+		serverReplica := createTestHTTPServer(handlerReplica)
+		defer serverReplica.Close()
+
+		gateways := []config.GatewayConfig{
+			{
+				URL: serverA.URL,
+				Replicas: []config.ReplicaConfig{
+					{
+						URL: serverReplica.URL,
+					},
+				},
+			},
+		}
+
+		err := tester.TestGateways(gateways)
+		assert.Nil(t, err)
+		assert.True(t, handlerReplicaWasCalled)
+
+		gateways[0].Replicas = append(gateways[0].Replicas, config.ReplicaConfig{URL: ""})
+		err = tester.TestGateways(gateways)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "unsupported protocol")
+	})
+}
+
+func TestGatewayTester_ProbeGateway(t *testing.T) {
+	t.Parallel()
+
+	tester := createTestGatewayTester()
+	t.Run("empty URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		err := tester.ProbeGateway(config.GatewayConfig{})
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "unsupported protocol")
+	})
+	t.Run("non 200 status code should error", func(t *testing.T) {
+		t.Parallel()
+
+		server := createTestHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		})
+		defer server.Close()
+
+		err := tester.ProbeGateway(config.GatewayConfig{URL: server.URL})
+		assert.ErrorIs(t, err, errUnexpectedGatewayStatus)
+		assert.Contains(t, err.Error(), "502")
+	})
+	t.Run("timeout should error", func(t *testing.T) {
+		t.Parallel()
+
+		server := createTestHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(time.Millisecond * 200)
+			w.WriteHeader(http.StatusOK)
+		})
+		defer server.Close()
+
+		fastTester := NewGatewayTester(ArgsGatewayTester{
+			TestTimeout:  time.Second,
+			ProbeTimeout: time.Millisecond * 50,
+		})
+		err := fastTester.ProbeGateway(config.GatewayConfig{URL: server.URL})
+		assert.NotNil(t, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		calledPath := ""
+		server := createTestHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+			calledPath = r.URL.Path
+			w.WriteHeader(http.StatusOK)
+		})
+		defer server.Close()
+
+		err := tester.ProbeGateway(config.GatewayConfig{URL: server.URL})
+		assert.Nil(t, err)
+		assert.Equal(t, configRoute, calledPath)
+	})
+	t.Run("should send the upstream headers and credentials", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedHeader http.Header
+		server := createTestHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+			receivedHeader = r.Header.Clone()
+			username, password, _ := r.BasicAuth()
+			if username != "user" || password != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+		defer server.Close()
+
+		err := tester.ProbeGateway(config.GatewayConfig{
+			URL:               server.URL,
+			Headers:           map[string]string{"X-Squad-Token": "token"},
+			BasicAuthUsername: "user",
+			BasicAuthPassword: "pass",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "token", receivedHeader.Get("X-Squad-Token"))
+	})
+	t.Run("should apply the path rewrites", func(t *testing.T) {
+		t.Parallel()
+
+		calledPath := ""
+		server := createTestHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+			calledPath = r.URL.Path
+			if !strings.HasPrefix(r.URL.Path, "/mainnet/") {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		})
+		defer server.Close()
+
+		err := tester.ProbeGateway(config.GatewayConfig{
+			URL:          server.URL,
+			PathRewrites: []config.PathRewriteConfig{{Prefix: "/", Replacement: "/mainnet/"}},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "/mainnet"+configRoute, calledPath)
+	})
 }
 
 func createTestHTTPServer(handler func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
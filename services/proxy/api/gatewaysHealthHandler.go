@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// gatewaysHealthHandler handles requests for the gateways' health status
+type gatewaysHealthHandler struct {
+	healthProvider GatewaysHealthProvider
+	auth           Authenticator
+}
+
+// NewGatewaysHealthHandler creates a new gatewaysHealthHandler instance
+func NewGatewaysHealthHandler(healthProvider GatewaysHealthProvider, auth Authenticator) (*gatewaysHealthHandler, error) {
+	if check.IfNil(healthProvider) {
+		return nil, errNilGatewaysHealthProvider
+	}
+	if check.IfNil(auth) {
+		return nil, errNilAuthenticator
+	}
+
+	return &gatewaysHealthHandler{
+		healthProvider: healthProvider,
+		auth:           auth,
+	}, nil
+}
+
+// ServeHTTP implements http.Handler interface
+func (handler *gatewaysHealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	claims, err := handler.auth.CheckAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if !claims.IsAdmin {
+		http.Error(w, "Forbidden: Only admins can view the gateways health", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(handler.healthProvider.GetHealthStatus())
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGatewaysHealthHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil health provider", func(t *testing.T) {
+		handler, err := NewGatewaysHealthHandler(nil, &testscommon.AuthenticatorStub{})
+		assert.Equal(t, errNilGatewaysHealthProvider, err)
+		assert.Nil(t, handler)
+	})
+
+	t.Run("nil authenticator", func(t *testing.T) {
+		handler, err := NewGatewaysHealthHandler(&testscommon.GatewaysHealthProviderStub{}, nil)
+		assert.Equal(t, errNilAuthenticator, err)
+		assert.Nil(t, handler)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		handler, err := NewGatewaysHealthHandler(&testscommon.GatewaysHealthProviderStub{}, &testscommon.AuthenticatorStub{})
+		assert.Nil(t, err)
+		assert.NotNil(t, handler)
+	})
+}
+
+func TestGatewaysHealthHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	auth := NewJWTAuthenticator("test_key")
+
+	t.Run("unauthorized - no token", func(t *testing.T) {
+		handler, _ := NewGatewaysHealthHandler(&testscommon.GatewaysHealthProviderStub{}, auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminGatewaysHealth, nil)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("forbidden - not admin", func(t *testing.T) {
+		token, err := auth.GenerateToken("user", false)
+		require.Nil(t, err)
+
+		handler, _ := NewGatewaysHealthHandler(&testscommon.GatewaysHealthProviderStub{}, auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminGatewaysHealth, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		token, err := auth.GenerateToken("admin", true)
+		require.Nil(t, err)
+
+		handler, _ := NewGatewaysHealthHandler(&testscommon.GatewaysHealthProviderStub{}, auth)
+		req := httptest.NewRequest(http.MethodPost, EndpointApiAdminGatewaysHealth, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	})
+
+	t.Run("success - admin", func(t *testing.T) {
+		token, err := auth.GenerateToken("admin", true)
+		require.Nil(t, err)
+
+		status := []common.GatewayHealthStatus{
+			{
+				Name:                "gateway",
+				URL:                 "http://gateway",
+				EpochStart:          "0",
+				EpochEnd:            "latest",
+				IsHealthy:           false,
+				ConsecutiveFailures: 3,
+				LastError:           "connection refused",
+				LastCheckTimestamp:  1700000000,
+			},
+		}
+		provider := &testscommon.GatewaysHealthProviderStub{
+			GetHealthStatusCalled: func() []common.GatewayHealthStatus {
+				return status
+			},
+		}
+
+		handler, _ := NewGatewaysHealthHandler(provider, auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminGatewaysHealth, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var decoded []common.GatewayHealthStatus
+		err = json.NewDecoder(resp.Body).Decode(&decoded)
+		assert.Nil(t, err)
+		assert.Equal(t, status, decoded)
+	})
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// gatewaysRangesHandler handles requests for the gateways ranges table and the issues found while building it
+type gatewaysRangesHandler struct {
+	reportProvider GatewaysDiscoveryReportProvider
+	auth           Authenticator
+}
+
+// NewGatewaysRangesHandler creates a new gatewaysRangesHandler instance
+func NewGatewaysRangesHandler(reportProvider GatewaysDiscoveryReportProvider, auth Authenticator) (*gatewaysRangesHandler, error) {
+	if check.IfNil(reportProvider) {
+		return nil, errNilGatewaysDiscoveryReportProvider
+	}
+	if check.IfNil(auth) {
+		return nil, errNilAuthenticator
+	}
+
+	return &gatewaysRangesHandler{
+		reportProvider: reportProvider,
+		auth:           auth,
+	}, nil
+}
+
+// ServeHTTP implements http.Handler interface
+func (handler *gatewaysRangesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	claims, err := handler.auth.CheckAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if !claims.IsAdmin {
+		http.Error(w, "Forbidden: Only admins can view the gateways ranges", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(handler.reportProvider.GetDiscoveryReport())
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGatewaysRangesHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil report provider", func(t *testing.T) {
+		handler, err := NewGatewaysRangesHandler(nil, &testscommon.AuthenticatorStub{})
+		assert.Equal(t, errNilGatewaysDiscoveryReportProvider, err)
+		assert.Nil(t, handler)
+	})
+
+	t.Run("nil authenticator", func(t *testing.T) {
+		handler, err := NewGatewaysRangesHandler(&testscommon.GatewaysDiscovererStub{}, nil)
+		assert.Equal(t, errNilAuthenticator, err)
+		assert.Nil(t, handler)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		handler, err := NewGatewaysRangesHandler(&testscommon.GatewaysDiscovererStub{}, &testscommon.AuthenticatorStub{})
+		assert.Nil(t, err)
+		assert.NotNil(t, handler)
+	})
+}
+
+func TestGatewaysRangesHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	auth := NewJWTAuthenticator("test_key")
+
+	t.Run("unauthorized - no token", func(t *testing.T) {
+		handler, _ := NewGatewaysRangesHandler(&testscommon.GatewaysDiscovererStub{}, auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminGatewaysRanges, nil)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("forbidden - not admin", func(t *testing.T) {
+		token, err := auth.GenerateToken("user", false)
+		require.Nil(t, err)
+
+		handler, _ := NewGatewaysRangesHandler(&testscommon.GatewaysDiscovererStub{}, auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminGatewaysRanges, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		token, err := auth.GenerateToken("admin", true)
+		require.Nil(t, err)
+
+		handler, _ := NewGatewaysRangesHandler(&testscommon.GatewaysDiscovererStub{}, auth)
+		req := httptest.NewRequest(http.MethodPost, EndpointApiAdminGatewaysRanges, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	})
+
+	t.Run("success - admin", func(t *testing.T) {
+		token, err := auth.GenerateToken("admin", true)
+		require.Nil(t, err)
+
+		report := common.GatewaysDiscoveryReport{
+			Timestamp: 1700000000,
+			Gateways: []config.GatewayConfig{
+				{URL: "http://old", Name: "old", EpochStart: "0", EpochEnd: "99", NonceStart: "0", NonceEnd: "99999"},
+				{URL: "http://live", Name: "live", EpochStart: "100", EpochEnd: "latest", NonceStart: "100000", NonceEnd: "latest"},
+			},
+			Issues: []common.GatewayRangeIssue{
+				{
+					Type:    "gap",
+					Gateway: "live",
+					Details: "epochs 100 - 119 are not held by any gateway, routed to old",
+				},
+			},
+		}
+		provider := &testscommon.GatewaysDiscovererStub{
+			GetDiscoveryReportCalled: func() common.GatewaysDiscoveryReport {
+				return report
+			},
+		}
+
+		handler, _ := NewGatewaysRangesHandler(provider, auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminGatewaysRanges, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var decoded common.GatewaysDiscoveryReport
+		err = json.NewDecoder(resp.Body).Decode(&decoded)
+		assert.Nil(t, err)
+		assert.Equal(t, report, decoded)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 )
 
 // KeyAccessProvider can decide if a provided key has or not query access
@@ -12,13 +13,20 @@ type KeyAccessProvider interface {
 	AddUser(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, isActive bool, activationToken string) error
 	ActivateUser(token string) error
 	GetAllUsers() (map[string]common.UsersDetails, error)
-	IsKeyAllowed(key string) (string, common.AccountType, error)
+	GetKeyAccess(key string) (common.KeyAccess, error)
+	ConsumeKeyCredits(access common.KeyAccess, cost uint64, clientIP string)
 	CheckUserCredentials(username string, password string) (*common.UsersDetails, error)
 	GetAllKeys(username string) (map[string]common.AccessKeyDetails, error)
 	AddKey(username string, key string) error
+	AddKeyWithSettings(username string, key string, settings common.KeySettings) error
 	RemoveKey(username string, key string) error
 	RemoveUser(username string) error
-	UpdateUser(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool) error
+	UpdateUser(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, tier *string) error
+	SetUserTier(username string, tier string) error
+	UpdateKey(key string, update common.KeyUpdate) error
+	SetKeyLabel(username string, key string, label string) error
+	SetKeyRestrictions(username string, key string, restrictions common.KeyRestrictions) error
+	RotateKey(username string, key string, newKey string, graceExpiresAt int64, settings common.KeySettings) error
 	GetUser(username string) (*common.UsersDetails, error)
 	GetPerformanceMetrics() (map[string]uint64, error)
 	UpdatePassword(username string, password string) error
@@ -44,6 +52,13 @@ type CaptchaHandler interface {
 	IsInterfaceNil() bool
 }
 
+// ClientIPResolver defines the operations supported by a component able to resolve the IP of the client that sent
+// a request
+type ClientIPResolver interface {
+	ResolveClientIP(request *http.Request) string
+	IsInterfaceNil() bool
+}
+
 // Authenticator defines the behavior for authentication
 type Authenticator interface {
 	GenerateToken(username string, isAdmin bool) (string, error)
@@ -65,3 +80,41 @@ type MutexHandler interface {
 	Unlock(username string)
 	IsInterfaceNil() bool
 }
+
+// KeyViolationsProvider defines the operations supported by a component able to provide the requests rejected by
+// the restrictions of their access keys
+type KeyViolationsProvider interface {
+	GetViolations() []common.KeyViolation
+	IsInterfaceNil() bool
+}
+
+// GatewaysHealthProvider defines the operations supported by a component able to provide the gateways' health status
+type GatewaysHealthProvider interface {
+	GetHealthStatus() []common.GatewayHealthStatus
+	IsInterfaceNil() bool
+}
+
+// GatewaysReloader defines the operations supported by a component able to reload the gateways configuration
+type GatewaysReloader interface {
+	Reload() ([]config.GatewayConfig, error)
+	IsInterfaceNil() bool
+}
+
+// MetricsProvider defines the operations supported by a component able to provide its internal metrics
+type MetricsProvider interface {
+	GetMetrics() map[string]uint64
+	IsInterfaceNil() bool
+}
+
+// GatewaysDiscoveryReportProvider defines the operations supported by a component able to provide the result of the
+// gateways ranges discovery
+type GatewaysDiscoveryReportProvider interface {
+	GetDiscoveryReport() common.GatewaysDiscoveryReport
+	IsInterfaceNil() bool
+}
+
+// ResponseCachePurger defines the operations supported by a component able to remove all the cached responses
+type ResponseCachePurger interface {
+	Purge() int
+	IsInterfaceNil() bool
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// keyViolationsHandler handles requests for the requests rejected by the access keys restrictions
+type keyViolationsHandler struct {
+	violationsProvider KeyViolationsProvider
+	auth               Authenticator
+}
+
+// NewKeyViolationsHandler creates a new keyViolationsHandler instance
+func NewKeyViolationsHandler(violationsProvider KeyViolationsProvider, auth Authenticator) (*keyViolationsHandler, error) {
+	if check.IfNil(violationsProvider) {
+		return nil, errNilKeyViolationsProvider
+	}
+	if check.IfNil(auth) {
+		return nil, errNilAuthenticator
+	}
+
+	return &keyViolationsHandler{
+		violationsProvider: violationsProvider,
+		auth:               auth,
+	}, nil
+}
+
+// ServeHTTP implements http.Handler interface
+func (handler *keyViolationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	claims, err := handler.auth.CheckAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if !claims.IsAdmin {
+		http.Error(w, "Forbidden: Only admins can view the key violations", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(handler.violationsProvider.GetViolations())
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyViolationsHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil violations provider", func(t *testing.T) {
+		handler, err := NewKeyViolationsHandler(nil, &testscommon.AuthenticatorStub{})
+		assert.Equal(t, errNilKeyViolationsProvider, err)
+		assert.Nil(t, handler)
+	})
+
+	t.Run("nil authenticator", func(t *testing.T) {
+		handler, err := NewKeyViolationsHandler(&testscommon.KeyViolationsRecorderStub{}, nil)
+		assert.Equal(t, errNilAuthenticator, err)
+		assert.Nil(t, handler)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		handler, err := NewKeyViolationsHandler(&testscommon.KeyViolationsRecorderStub{}, &testscommon.AuthenticatorStub{})
+		assert.Nil(t, err)
+		assert.NotNil(t, handler)
+	})
+}
+
+func TestKeyViolationsHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	auth := NewJWTAuthenticator("test_key")
+
+	t.Run("unauthorized - no token", func(t *testing.T) {
+		handler, _ := NewKeyViolationsHandler(&testscommon.KeyViolationsRecorderStub{}, auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminKeyViolations, nil)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("forbidden - not admin", func(t *testing.T) {
+		token, err := auth.GenerateToken("user", false)
+		require.Nil(t, err)
+
+		handler, _ := NewKeyViolationsHandler(&testscommon.KeyViolationsRecorderStub{}, auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminKeyViolations, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		token, err := auth.GenerateToken("admin", true)
+		require.Nil(t, err)
+
+		handler, _ := NewKeyViolationsHandler(&testscommon.KeyViolationsRecorderStub{}, auth)
+		req := httptest.NewRequest(http.MethodPost, EndpointApiAdminKeyViolations, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	})
+
+	t.Run("success - admin", func(t *testing.T) {
+		token, err := auth.GenerateToken("admin", true)
+		require.Nil(t, err)
+
+		violations := []common.KeyViolation{
+			{
+				Timestamp: 1700000000,
+				Username:  "user",
+				KeyPrefix: "e05d2c",
+				ClientIP:  "198.51.100.7",
+				Origin:    "https://evil.com",
+				Reason:    "request rejected by the key restrictions: the origin https://evil.com is not allowed",
+			},
+		}
+		provider := &testscommon.KeyViolationsRecorderStub{
+			GetViolationsCalled: func() []common.KeyViolation {
+				return violations
+			},
+		}
+
+		handler, _ := NewKeyViolationsHandler(provider, auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminKeyViolations, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var decoded []common.KeyViolation
+		err = json.NewDecoder(resp.Body).Decode(&decoded)
+		assert.Nil(t, err)
+		assert.Equal(t, violations, decoded)
+	})
+}
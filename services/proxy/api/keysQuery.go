@@ -0,0 +1,126 @@
+package api
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+)
+
+const (
+	sortKeysByLabel    = "label"
+	sortKeysByCreated  = "created"
+	sortKeysByLastUsed = "last_used"
+	sortKeysByRequests = "requests"
+	sortKeysByOwner    = "owner"
+	sortOrderAsc       = "asc"
+	sortOrderDesc      = "desc"
+)
+
+// keysQuery holds the filters and the sorting requested for the listed access keys
+type keysQuery struct {
+	label       string
+	owner       string
+	unusedSince int64
+	sortBy      string
+	descending  bool
+}
+
+// parseKeysQuery reads the keys query from the label, owner, unused_since, sort and order URL parameters
+func parseKeysQuery(values url.Values) (keysQuery, error) {
+	query := keysQuery{
+		label:  strings.ToLower(strings.TrimSpace(values.Get("label"))),
+		owner:  strings.TrimSpace(values.Get("owner")),
+		sortBy: strings.ToLower(strings.TrimSpace(values.Get("sort"))),
+	}
+
+	unusedSince := strings.TrimSpace(values.Get("unused_since"))
+	if len(unusedSince) > 0 {
+		var err error
+		query.unusedSince, err = strconv.ParseInt(unusedSince, 10, 64)
+		if err != nil || query.unusedSince <= 0 {
+			return keysQuery{}, fmt.Errorf("unused_since must be a unix timestamp")
+		}
+	}
+
+	switch query.sortBy {
+	case "", sortKeysByLabel, sortKeysByCreated, sortKeysByLastUsed, sortKeysByRequests, sortKeysByOwner:
+	default:
+		return keysQuery{}, fmt.Errorf("unknown sort field %s", query.sortBy)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(values.Get("order"))) {
+	case "", sortOrderAsc:
+	case sortOrderDesc:
+		query.descending = true
+	default:
+		return keysQuery{}, fmt.Errorf("order must be %s or %s", sortOrderAsc, sortOrderDesc)
+	}
+
+	return query, nil
+}
+
+// filter returns the keys matching the query's label, owner and unused_since filters
+func (query keysQuery) filter(keys map[string]common.AccessKeyDetails) map[string]common.AccessKeyDetails {
+	result := make(map[string]common.AccessKeyDetails, len(keys))
+	for id, details := range keys {
+		if len(query.label) > 0 && !strings.Contains(strings.ToLower(details.Label), query.label) {
+			continue
+		}
+		if len(query.owner) > 0 && details.Username != query.owner {
+			continue
+		}
+		// the keys never used are also unused since the provided timestamp
+		if query.unusedSince > 0 && details.LastUsedAt >= query.unusedSince {
+			continue
+		}
+
+		result[id] = details
+	}
+
+	return result
+}
+
+// sort returns the keys ordered by the query's sort field, the keys with equal values, or all of them if no sort field
+// was requested, being ordered by their IDs
+func (query keysQuery) sort(keys map[string]common.AccessKeyDetails) []common.AccessKeyDetails {
+	result := make([]common.AccessKeyDetails, 0, len(keys))
+	for _, details := range keys {
+		result = append(result, details)
+	}
+
+	slices.SortFunc(result, func(first common.AccessKeyDetails, second common.AccessKeyDetails) int {
+		comparison := query.compare(first, second)
+		if comparison == 0 {
+			return strings.Compare(first.ID, second.ID)
+		}
+		if query.descending {
+			return -comparison
+		}
+
+		return comparison
+	})
+
+	return result
+}
+
+func (query keysQuery) compare(first common.AccessKeyDetails, second common.AccessKeyDetails) int {
+	switch query.sortBy {
+	case sortKeysByLabel:
+		return strings.Compare(strings.ToLower(first.Label), strings.ToLower(second.Label))
+	case sortKeysByCreated:
+		return cmp.Compare(first.CreatedAt, second.CreatedAt)
+	case sortKeysByLastUsed:
+		return cmp.Compare(first.LastUsedAt, second.LastUsedAt)
+	case sortKeysByRequests:
+		return cmp.Compare(first.KeyCounter, second.KeyCounter)
+	case sortKeysByOwner:
+		return strings.Compare(first.Username, second.Username)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestKeysForQuery() map[string]common.AccessKeyDetails {
+	return map[string]common.AccessKeyDetails{
+		"id1": {ID: "id1", Label: "Indexer", Username: "user1", CreatedAt: 300, LastUsedAt: 0, KeyCounter: 10},
+		"id2": {ID: "id2", Label: "wallet backend", Username: "user2", CreatedAt: 100, LastUsedAt: 2000, KeyCounter: 30},
+		"id3": {ID: "id3", Label: "", Username: "user1", CreatedAt: 200, LastUsedAt: 1000, KeyCounter: 20},
+	}
+}
+
+func TestParseKeysQuery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid values should error", func(t *testing.T) {
+		t.Parallel()
+
+		invalidQueries := []string{
+			"sort=unknown",
+			"order=random",
+			"unused_since=yesterday",
+			"unused_since=-1",
+		}
+		for _, invalidQuery := range invalidQueries {
+			values, _ := url.ParseQuery(invalidQuery)
+			_, err := parseKeysQuery(values)
+			assert.NotNil(t, err, invalidQuery)
+		}
+	})
+	t.Run("empty query should not filter nor sort", func(t *testing.T) {
+		t.Parallel()
+
+		query, err := parseKeysQuery(url.Values{})
+		assert.Nil(t, err)
+		assert.Empty(t, query.sortBy)
+		assert.Equal(t, createTestKeysForQuery(), query.filter(createTestKeysForQuery()))
+	})
+	t.Run("should parse the values", func(t *testing.T) {
+		t.Parallel()
+
+		values, _ := url.ParseQuery("label=%20Wallet%20&owner=user2&unused_since=1500&sort=Last_Used&order=DESC")
+		query, err := parseKeysQuery(values)
+		assert.Nil(t, err)
+
+		expectedQuery := keysQuery{
+			label:       "wallet",
+			owner:       "user2",
+			unusedSince: 1500,
+			sortBy:      sortKeysByLastUsed,
+			descending:  true,
+		}
+		assert.Equal(t, expectedQuery, query)
+	})
+}
+
+func TestKeysQuery_Filter(t *testing.T) {
+	t.Parallel()
+
+	keys := createTestKeysForQuery()
+
+	t.Run("by label", func(t *testing.T) {
+		t.Parallel()
+
+		result := keysQuery{label: "index"}.filter(keys)
+		assert.Equal(t, map[string]common.AccessKeyDetails{"id1": keys["id1"]}, result)
+	})
+	t.Run("by owner", func(t *testing.T) {
+		t.Parallel()
+
+		result := keysQuery{owner: "user1"}.filter(keys)
+		assert.Equal(t, map[string]common.AccessKeyDetails{"id1": keys["id1"], "id3": keys["id3"]}, result)
+	})
+	t.Run("unused since should include the keys never used", func(t *testing.T) {
+		t.Parallel()
+
+		result := keysQuery{unusedSince: 1500}.filter(keys)
+		assert.Equal(t, map[string]common.AccessKeyDetails{"id1": keys["id1"], "id3": keys["id3"]}, result)
+	})
+}
+
+func TestKeysQuery_Sort(t *testing.T) {
+	t.Parallel()
+
+	keys := createTestKeysForQuery()
+	getIDs := func(sorted []common.AccessKeyDetails) []string {
+		ids := make([]string, 0, len(sorted))
+		for _, details := range sorted {
+			ids = append(ids, details.ID)
+		}
+
+		return ids
+	}
+
+	assert.Equal(t, []string{"id1", "id2", "id3"}, getIDs(keysQuery{}.sort(keys)))
+	assert.Equal(t, []string{"id3", "id1", "id2"}, getIDs(keysQuery{sortBy: sortKeysByLabel}.sort(keys)))
+	assert.Equal(t, []string{"id2", "id3", "id1"}, getIDs(keysQuery{sortBy: sortKeysByCreated}.sort(keys)))
+	assert.Equal(t, []string{"id2", "id3", "id1"}, getIDs(keysQuery{sortBy: sortKeysByLastUsed, descending: true}.sort(keys)))
+	assert.Equal(t, []string{"id2", "id3", "id1"}, getIDs(keysQuery{sortBy: sortKeysByRequests, descending: true}.sort(keys)))
+	// the keys of the same owner are ordered by their IDs
+	assert.Equal(t, []string{"id1", "id3", "id2"}, getIDs(keysQuery{sortBy: sortKeysByOwner}.sort(keys)))
+}
@@ -10,20 +10,25 @@ import (
 type loginHandler struct {
 	keyAccessProvider KeyAccessProvider
 	auth              Authenticator
+	clientIPResolver  ClientIPResolver
 }
 
-// NewLoginHandler creates a new login handler
-func NewLoginHandler(provider KeyAccessProvider, auth Authenticator) (*loginHandler, error) {
+// NewLoginHandler creates a new login handler. The login attempts are logged together with the IP of the client.
+func NewLoginHandler(provider KeyAccessProvider, auth Authenticator, clientIPResolver ClientIPResolver) (*loginHandler, error) {
 	if check.IfNil(provider) {
 		return nil, errNilKeyAccessProvider
 	}
 	if check.IfNil(auth) {
 		return nil, errNilAuthenticator
 	}
+	if check.IfNil(clientIPResolver) {
+		return nil, errNilClientIPResolver
+	}
 
 	return &loginHandler{
 		keyAccessProvider: provider,
 		auth:              auth,
+		clientIPResolver:  clientIPResolver,
 	}, nil
 }
 
@@ -44,13 +49,16 @@ func (h *loginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	clientIP := h.clientIPResolver.ResolveClientIP(r)
 	details, err := h.keyAccessProvider.CheckUserCredentials(creds.Username, creds.Password)
 	if err != nil {
+		log.Debug("login failed", "username", creds.Username, "client IP", clientIP, "error", err)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
 	if !details.IsActive {
+		log.Debug("login of an inactive account", "username", details.Username, "client IP", clientIP)
 		http.Error(w, "Account not activated. Please check your email.", http.StatusForbidden)
 		return
 	}
@@ -61,6 +69,7 @@ func (h *loginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	log.Debug("user logged in", "username", details.Username, "client IP", clientIP)
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]interface{}{
 		"token":    token,
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -20,7 +21,7 @@ func TestNewLoginHandler(t *testing.T) {
 	t.Run("nil access provider should error", func(t *testing.T) {
 		t.Parallel()
 
-		handler, err := NewAccessKeysHandler(nil, &testscommon.AuthenticatorStub{})
+		handler, err := NewAccessKeysHandler(nil, &testscommon.AuthenticatorStub{}, config.KeysRotationConfig{})
 		assert.Nil(t, handler)
 		assert.Equal(t, errNilKeyAccessProvider, err)
 	})
@@ -28,15 +29,23 @@ func TestNewLoginHandler(t *testing.T) {
 	t.Run("nil authenticator should error", func(t *testing.T) {
 		t.Parallel()
 
-		handler, err := NewAccessKeysHandler(&testscommon.StorerStub{}, nil)
+		handler, err := NewAccessKeysHandler(&testscommon.StorerStub{}, nil, config.KeysRotationConfig{})
 		assert.Nil(t, handler)
 		assert.Equal(t, errNilAuthenticator, err)
 	})
 
+	t.Run("nil client IP resolver should error", func(t *testing.T) {
+		t.Parallel()
+
+		handler, err := NewLoginHandler(&testscommon.StorerStub{}, &testscommon.AuthenticatorStub{}, nil)
+		assert.Nil(t, handler)
+		assert.Equal(t, errNilClientIPResolver, err)
+	})
+
 	t.Run("success", func(t *testing.T) {
 		t.Parallel()
 
-		handler, err := NewAccessKeysHandler(&testscommon.StorerStub{}, &testscommon.AuthenticatorStub{})
+		handler, err := NewAccessKeysHandler(&testscommon.StorerStub{}, &testscommon.AuthenticatorStub{}, config.KeysRotationConfig{})
 		assert.NotNil(t, handler)
 		assert.Nil(t, err)
 	})
@@ -46,7 +55,7 @@ func TestLoginHandler(t *testing.T) {
 	auth := NewJWTAuthenticator("test_key")
 
 	t.Run("ServeHTTP non-POST method", func(t *testing.T) {
-		handler, _ := NewLoginHandler(&testscommon.StorerStub{}, auth)
+		handler, _ := NewLoginHandler(&testscommon.StorerStub{}, auth, &testscommon.ClientIPResolverStub{})
 		req := httptest.NewRequest(http.MethodGet, "/login", nil)
 		resp := httptest.NewRecorder()
 
@@ -55,7 +64,7 @@ func TestLoginHandler(t *testing.T) {
 	})
 
 	t.Run("ServeHTTP bad request body", func(t *testing.T) {
-		handler, _ := NewLoginHandler(&testscommon.StorerStub{}, auth)
+		handler, _ := NewLoginHandler(&testscommon.StorerStub{}, auth, &testscommon.ClientIPResolverStub{})
 		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBufferString("invalid json"))
 		resp := httptest.NewRecorder()
 
@@ -69,7 +78,14 @@ func TestLoginHandler(t *testing.T) {
 				return nil, errors.New("invalid credentials")
 			},
 		}
-		handler, _ := NewLoginHandler(storer, auth)
+		resolveCalled := false
+		resolver := &testscommon.ClientIPResolverStub{
+			ResolveClientIPCalled: func(request *http.Request) string {
+				resolveCalled = true
+				return "203.0.113.9"
+			},
+		}
+		handler, _ := NewLoginHandler(storer, auth, resolver)
 
 		creds := map[string]string{"username": "user", "password": "wrong"}
 		body, _ := json.Marshal(creds)
@@ -78,6 +94,8 @@ func TestLoginHandler(t *testing.T) {
 
 		handler.ServeHTTP(resp, req)
 		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		// the failed attempt is logged with the client IP
+		assert.True(t, resolveCalled)
 	})
 
 	t.Run("ServeHTTP inactive user should error", func(t *testing.T) {
@@ -90,7 +108,7 @@ func TestLoginHandler(t *testing.T) {
 				}, nil
 			},
 		}
-		handler, _ := NewLoginHandler(storer, auth)
+		handler, _ := NewLoginHandler(storer, auth, &testscommon.ClientIPResolverStub{})
 
 		creds := map[string]string{"username": "user", "password": "pass"}
 		body, _ := json.Marshal(creds)
@@ -112,7 +130,7 @@ func TestLoginHandler(t *testing.T) {
 				}, nil
 			},
 		}
-		handler, _ := NewLoginHandler(storer, auth)
+		handler, _ := NewLoginHandler(storer, auth, &testscommon.ClientIPResolverStub{})
 
 		creds := map[string]string{"username": "user", "password": "pass"}
 		body, _ := json.Marshal(creds)
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// proxyMetricsHandler handles requests for the proxy's internal metrics
+type proxyMetricsHandler struct {
+	metricsProviders map[string]MetricsProvider
+	auth             Authenticator
+}
+
+// NewProxyMetricsHandler creates a new proxyMetricsHandler instance. The metrics of each provider are reported
+// under the provider's name.
+func NewProxyMetricsHandler(metricsProviders map[string]MetricsProvider, auth Authenticator) (*proxyMetricsHandler, error) {
+	for name, provider := range metricsProviders {
+		if check.IfNil(provider) {
+			return nil, fmt.Errorf("%w for %s", errNilMetricsProvider, name)
+		}
+	}
+	if check.IfNil(auth) {
+		return nil, errNilAuthenticator
+	}
+
+	return &proxyMetricsHandler{
+		metricsProviders: metricsProviders,
+		auth:             auth,
+	}, nil
+}
+
+// ServeHTTP implements http.Handler interface
+func (handler *proxyMetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	claims, err := handler.auth.CheckAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if !claims.IsAdmin {
+		http.Error(w, "Forbidden: Only admins can view the proxy metrics", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	metrics := make(map[string]map[string]uint64, len(handler.metricsProviders))
+	for name, provider := range handler.metricsProviders {
+		metrics[name] = provider.GetMetrics()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(metrics)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProxyMetricsHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil metrics provider", func(t *testing.T) {
+		providers := map[string]MetricsProvider{
+			"hashIndex": nil,
+		}
+		handler, err := NewProxyMetricsHandler(providers, &testscommon.AuthenticatorStub{})
+		assert.True(t, errors.Is(err, errNilMetricsProvider))
+		assert.Contains(t, err.Error(), "hashIndex")
+		assert.Nil(t, handler)
+	})
+
+	t.Run("nil authenticator", func(t *testing.T) {
+		handler, err := NewProxyMetricsHandler(make(map[string]MetricsProvider), nil)
+		assert.Equal(t, errNilAuthenticator, err)
+		assert.Nil(t, handler)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		providers := map[string]MetricsProvider{
+			"hashIndex": &testscommon.MetricsProviderStub{},
+		}
+		handler, err := NewProxyMetricsHandler(providers, &testscommon.AuthenticatorStub{})
+		assert.Nil(t, err)
+		assert.NotNil(t, handler)
+	})
+}
+
+func TestProxyMetricsHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	auth := NewJWTAuthenticator("test_key")
+
+	t.Run("unauthorized - no token", func(t *testing.T) {
+		handler, _ := NewProxyMetricsHandler(make(map[string]MetricsProvider), auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminProxyMetrics, nil)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("forbidden - not admin", func(t *testing.T) {
+		token, err := auth.GenerateToken("user", false)
+		require.Nil(t, err)
+
+		handler, _ := NewProxyMetricsHandler(make(map[string]MetricsProvider), auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminProxyMetrics, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		token, err := auth.GenerateToken("admin", true)
+		require.Nil(t, err)
+
+		handler, _ := NewProxyMetricsHandler(make(map[string]MetricsProvider), auth)
+		req := httptest.NewRequest(http.MethodPost, EndpointApiAdminProxyMetrics, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	})
+
+	t.Run("success - admin", func(t *testing.T) {
+		token, err := auth.GenerateToken("admin", true)
+		require.Nil(t, err)
+
+		providers := map[string]MetricsProvider{
+			"hashIndex": &testscommon.MetricsProviderStub{
+				GetMetricsCalled: func() map[string]uint64 {
+					return map[string]uint64{
+						"hash_index_hits": 7,
+						"hash_fan_outs":   2,
+					}
+				},
+			},
+		}
+
+		handler, _ := NewProxyMetricsHandler(providers, auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminProxyMetrics, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var decoded map[string]map[string]uint64
+		err = json.NewDecoder(resp.Body).Decode(&decoded)
+		assert.Nil(t, err)
+		expected := map[string]map[string]uint64{
+			"hashIndex": {
+				"hash_index_hits": 7,
+				"hash_fan_outs":   2,
+			},
+		}
+		assert.Equal(t, expected, decoded)
+	})
+}
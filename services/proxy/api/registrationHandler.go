@@ -25,15 +25,18 @@ type registrationHandler struct {
 	emailSender       EmailSender
 	appDomainsConfig  config.AppDomainsConfig
 	captchaHandler    CaptchaHandler
+	clientIPResolver  ClientIPResolver
 	htmlTemplate      string
 }
 
-// NewRegistrationHandler creates a new registrationHandler instance
+// NewRegistrationHandler creates a new registrationHandler instance. The registrations are logged together with the IP
+// of the client.
 func NewRegistrationHandler(
 	keyAccessProvider KeyAccessProvider,
 	emailSender EmailSender,
 	appDomainsConfig config.AppDomainsConfig,
 	captchaHandler CaptchaHandler,
+	clientIPResolver ClientIPResolver,
 	htmlTemplate string,
 ) (*registrationHandler, error) {
 	if check.IfNil(keyAccessProvider) {
@@ -45,6 +48,9 @@ func NewRegistrationHandler(
 	if check.IfNil(captchaHandler) {
 		return nil, errNilCaptchaHandler
 	}
+	if check.IfNil(clientIPResolver) {
+		return nil, errNilClientIPResolver
+	}
 	if len(htmlTemplate) == 0 {
 		return nil, errEmptyHTMLTemplate
 	}
@@ -54,6 +60,7 @@ func NewRegistrationHandler(
 		emailSender:       emailSender,
 		appDomainsConfig:  appDomainsConfig,
 		captchaHandler:    captchaHandler,
+		clientIPResolver:  clientIPResolver,
 		htmlTemplate:      htmlTemplate,
 	}, nil
 }
@@ -93,7 +100,9 @@ func (handler *registrationHandler) handleRegister(w http.ResponseWriter, r *htt
 		return
 	}
 
+	clientIP := handler.clientIPResolver.ResolveClientIP(r)
 	if !handler.captchaHandler.VerifyString(req.CaptchaId, req.CaptchaSolution) {
+		log.Debug("registration with an invalid captcha solution", "client IP", clientIP)
 		http.Error(w, "Invalid captcha solution", http.StatusBadRequest)
 		return
 	}
@@ -128,10 +137,11 @@ func (handler *registrationHandler) handleRegister(w http.ResponseWriter, r *htt
 		http.Error(w, fmt.Sprintf("Failed to register user: %v", err), http.StatusInternalServerError)
 		return
 	}
+	log.Debug("user registered", "username", req.Username, "client IP", clientIP)
 
 	err = handler.sendActivationEmail(req.Username, activationToken)
 	if err != nil {
-		log.Error("Failed to send activation email", "error", err)
+		log.Error("Failed to send activation email", "username", req.Username, "client IP", clientIP, "error", err)
 		// We don't fail the request, just log it. The user might need to contact support or we need a resend mechanism.
 		// For now, let's assume it works or log it.
 	}
@@ -35,6 +35,7 @@ func TestNewRegistrationHandler(t *testing.T) {
 			&testscommon.EmailSenderStub{},
 			testAppDomainsConfig,
 			&testscommon.CaptchaHandlerStub{},
+			&testscommon.ClientIPResolverStub{},
 			testHTMLTemplate,
 		)
 		assert.Equal(t, errNilKeyAccessProvider, err)
@@ -49,6 +50,7 @@ func TestNewRegistrationHandler(t *testing.T) {
 			nil,
 			testAppDomainsConfig,
 			&testscommon.CaptchaHandlerStub{},
+			&testscommon.ClientIPResolverStub{},
 			testHTMLTemplate,
 		)
 		assert.Equal(t, errNilEmailSender, err)
@@ -63,12 +65,28 @@ func TestNewRegistrationHandler(t *testing.T) {
 			&testscommon.EmailSenderStub{},
 			testAppDomainsConfig,
 			nil,
+			&testscommon.ClientIPResolverStub{},
 			testHTMLTemplate,
 		)
 		assert.Equal(t, errNilCaptchaHandler, err)
 		assert.Nil(t, handler)
 	})
 
+	t.Run("nil client IP resolver", func(t *testing.T) {
+		t.Parallel()
+
+		handler, err := NewRegistrationHandler(
+			&testscommon.StorerStub{},
+			&testscommon.EmailSenderStub{},
+			testAppDomainsConfig,
+			&testscommon.CaptchaHandlerStub{},
+			nil,
+			testHTMLTemplate,
+		)
+		assert.Equal(t, errNilClientIPResolver, err)
+		assert.Nil(t, handler)
+	})
+
 	t.Run("empty HTML template", func(t *testing.T) {
 		t.Parallel()
 
@@ -77,6 +95,7 @@ func TestNewRegistrationHandler(t *testing.T) {
 			&testscommon.EmailSenderStub{},
 			testAppDomainsConfig,
 			&testscommon.CaptchaHandlerStub{},
+			&testscommon.ClientIPResolverStub{},
 			"",
 		)
 		assert.Equal(t, errEmptyHTMLTemplate, err)
@@ -91,6 +110,7 @@ func TestNewRegistrationHandler(t *testing.T) {
 			&testscommon.EmailSenderStub{},
 			testAppDomainsConfig,
 			&testscommon.CaptchaHandlerStub{},
+			&testscommon.ClientIPResolverStub{},
 			testHTMLTemplate,
 		)
 		assert.Nil(t, err)
@@ -106,6 +126,7 @@ func TestRegistrationHandler_ServeHTTP_Register(t *testing.T) {
 		&testscommon.EmailSenderStub{},
 		testAppDomainsConfig,
 		&testscommon.CaptchaHandlerStub{},
+		&testscommon.ClientIPResolverStub{},
 		testHTMLTemplate,
 	)
 
@@ -152,6 +173,7 @@ func TestRegistrationHandler_ServeHTTP_Register(t *testing.T) {
 					return false
 				},
 			},
+			&testscommon.ClientIPResolverStub{},
 			testHTMLTemplate,
 		)
 
@@ -176,6 +198,7 @@ func TestRegistrationHandler_ServeHTTP_Register(t *testing.T) {
 			&testscommon.EmailSenderStub{},
 			testAppDomainsConfig,
 			&testscommon.CaptchaHandlerStub{},
+			&testscommon.ClientIPResolverStub{},
 			testHTMLTemplate,
 		)
 
@@ -211,6 +234,7 @@ func TestRegistrationHandler_ServeHTTP_Register(t *testing.T) {
 			&testscommon.EmailSenderStub{},
 			testAppDomainsConfig,
 			&testscommon.CaptchaHandlerStub{},
+			&testscommon.ClientIPResolverStub{},
 			testHTMLTemplate,
 		)
 
@@ -250,6 +274,7 @@ func TestRegistrationHandler_ServeHTTP_Register(t *testing.T) {
 			emailSender,
 			testAppDomainsConfig,
 			&testscommon.CaptchaHandlerStub{},
+			&testscommon.ClientIPResolverStub{},
 			testHTMLTemplate,
 		)
 
@@ -284,6 +309,7 @@ func TestRegistrationHandler_ServeHTTP_Register(t *testing.T) {
 			emailSender,
 			testAppDomainsConfig,
 			&testscommon.CaptchaHandlerStub{},
+			&testscommon.ClientIPResolverStub{},
 			testHTMLTemplate,
 		)
 
@@ -305,6 +331,7 @@ func TestRegistrationHandler_ServeHTTP_Activate(t *testing.T) {
 		&testscommon.EmailSenderStub{},
 		testAppDomainsConfig,
 		&testscommon.CaptchaHandlerStub{},
+		&testscommon.ClientIPResolverStub{},
 		testHTMLTemplate,
 	)
 
@@ -336,6 +363,7 @@ func TestRegistrationHandler_ServeHTTP_Activate(t *testing.T) {
 			&testscommon.EmailSenderStub{},
 			testAppDomainsConfig,
 			&testscommon.CaptchaHandlerStub{},
+			&testscommon.ClientIPResolverStub{},
 			testHTMLTemplate,
 		)
 
@@ -359,6 +387,7 @@ func TestRegistrationHandler_ServeHTTP_Activate(t *testing.T) {
 			&testscommon.EmailSenderStub{},
 			testAppDomainsConfig,
 			&testscommon.CaptchaHandlerStub{},
+			&testscommon.ClientIPResolverStub{},
 			testHTMLTemplate,
 		)
 
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// reloadGatewaysHandler handles requests for reloading the gateways configuration
+type reloadGatewaysHandler struct {
+	reloader GatewaysReloader
+	auth     Authenticator
+}
+
+// NewReloadGatewaysHandler creates a new reloadGatewaysHandler instance
+func NewReloadGatewaysHandler(reloader GatewaysReloader, auth Authenticator) (*reloadGatewaysHandler, error) {
+	if check.IfNil(reloader) {
+		return nil, errNilGatewaysReloader
+	}
+	if check.IfNil(auth) {
+		return nil, errNilAuthenticator
+	}
+
+	return &reloadGatewaysHandler{
+		reloader: reloader,
+		auth:     auth,
+	}, nil
+}
+
+// ServeHTTP implements http.Handler interface
+func (handler *reloadGatewaysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	claims, err := handler.auth.CheckAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if !claims.IsAdmin {
+		http.Error(w, "Forbidden: Only admins can reload the gateways", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	gateways, err := handler.reloader.Reload()
+	if err != nil {
+		log.Warn("gateways reload failed, keeping the old configuration", "error", err)
+		http.Error(w, "Reload failed, the old configuration is kept: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Gateways []config.GatewayConfig `json:"gateways"`
+	}{
+		Gateways: gateways,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
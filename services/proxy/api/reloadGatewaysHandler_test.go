@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReloadGatewaysHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil reloader", func(t *testing.T) {
+		handler, err := NewReloadGatewaysHandler(nil, &testscommon.AuthenticatorStub{})
+		assert.Equal(t, errNilGatewaysReloader, err)
+		assert.Nil(t, handler)
+	})
+
+	t.Run("nil authenticator", func(t *testing.T) {
+		handler, err := NewReloadGatewaysHandler(&testscommon.GatewaysReloaderStub{}, nil)
+		assert.Equal(t, errNilAuthenticator, err)
+		assert.Nil(t, handler)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		handler, err := NewReloadGatewaysHandler(&testscommon.GatewaysReloaderStub{}, &testscommon.AuthenticatorStub{})
+		assert.Nil(t, err)
+		assert.NotNil(t, handler)
+	})
+}
+
+func TestReloadGatewaysHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	auth := NewJWTAuthenticator("test_key")
+	adminToken, err := auth.GenerateToken("admin", true)
+	require.Nil(t, err)
+
+	t.Run("unauthorized - no token", func(t *testing.T) {
+		handler, _ := NewReloadGatewaysHandler(&testscommon.GatewaysReloaderStub{}, auth)
+		req := httptest.NewRequest(http.MethodPost, EndpointApiAdminReloadGateways, nil)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("forbidden - not admin", func(t *testing.T) {
+		token, errGenerate := auth.GenerateToken("user", false)
+		require.Nil(t, errGenerate)
+
+		handler, _ := NewReloadGatewaysHandler(&testscommon.GatewaysReloaderStub{}, auth)
+		req := httptest.NewRequest(http.MethodPost, EndpointApiAdminReloadGateways, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		handler, _ := NewReloadGatewaysHandler(&testscommon.GatewaysReloaderStub{}, auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminReloadGateways, nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	})
+
+	t.Run("reload error", func(t *testing.T) {
+		reloader := &testscommon.GatewaysReloaderStub{
+			ReloadCalled: func() ([]config.GatewayConfig, error) {
+				return nil, errors.New("invalid config")
+			},
+		}
+		handler, _ := NewReloadGatewaysHandler(reloader, auth)
+		req := httptest.NewRequest(http.MethodPost, EndpointApiAdminReloadGateways, nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		assert.Contains(t, resp.Body.String(), "invalid config")
+	})
+
+	t.Run("success - admin", func(t *testing.T) {
+		gateways := []config.GatewayConfig{
+			{
+				URL:        "http://gateway",
+				Name:       "gateway",
+				EpochStart: "0",
+				EpochEnd:   "latest",
+				NonceStart: "0",
+				NonceEnd:   "latest",
+			},
+		}
+		reloader := &testscommon.GatewaysReloaderStub{
+			ReloadCalled: func() ([]config.GatewayConfig, error) {
+				return gateways, nil
+			},
+		}
+		handler, _ := NewReloadGatewaysHandler(reloader, auth)
+		req := httptest.NewRequest(http.MethodPost, EndpointApiAdminReloadGateways, nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var decoded struct {
+			Gateways []config.GatewayConfig `json:"gateways"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&decoded)
+		assert.Nil(t, err)
+		assert.Equal(t, gateways, decoded.Gateways)
+	})
+}
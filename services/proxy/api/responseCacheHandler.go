@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// responseCacheHandler handles requests for purging the response cache
+type responseCacheHandler struct {
+	purger ResponseCachePurger
+	auth   Authenticator
+}
+
+// NewResponseCacheHandler creates a new responseCacheHandler instance
+func NewResponseCacheHandler(purger ResponseCachePurger, auth Authenticator) (*responseCacheHandler, error) {
+	if check.IfNil(purger) {
+		return nil, errNilResponseCachePurger
+	}
+	if check.IfNil(auth) {
+		return nil, errNilAuthenticator
+	}
+
+	return &responseCacheHandler{
+		purger: purger,
+		auth:   auth,
+	}, nil
+}
+
+// ServeHTTP implements http.Handler interface
+func (handler *responseCacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	claims, err := handler.auth.CheckAuth(r)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if !claims.IsAdmin {
+		http.Error(w, "Forbidden: Only admins can purge the response cache", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := struct {
+		PurgedEntries int `json:"purgedEntries"`
+	}{
+		PurgedEntries: handler.purger.Purge(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewResponseCacheHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil purger", func(t *testing.T) {
+		handler, err := NewResponseCacheHandler(nil, &testscommon.AuthenticatorStub{})
+		assert.Equal(t, errNilResponseCachePurger, err)
+		assert.Nil(t, handler)
+	})
+
+	t.Run("nil authenticator", func(t *testing.T) {
+		handler, err := NewResponseCacheHandler(&testscommon.ResponseCacheStub{}, nil)
+		assert.Equal(t, errNilAuthenticator, err)
+		assert.Nil(t, handler)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		handler, err := NewResponseCacheHandler(&testscommon.ResponseCacheStub{}, &testscommon.AuthenticatorStub{})
+		assert.Nil(t, err)
+		assert.NotNil(t, handler)
+	})
+}
+
+func TestResponseCacheHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	auth := NewJWTAuthenticator("test_key")
+
+	t.Run("unauthorized - no token", func(t *testing.T) {
+		handler, _ := NewResponseCacheHandler(&testscommon.ResponseCacheStub{}, auth)
+		req := httptest.NewRequest(http.MethodDelete, EndpointApiAdminResponseCache, nil)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("forbidden - not admin", func(t *testing.T) {
+		token, err := auth.GenerateToken("user", false)
+		require.Nil(t, err)
+
+		purger := &testscommon.ResponseCacheStub{
+			PurgeCalled: func() int {
+				assert.Fail(t, "should have not purged the cache")
+				return 0
+			},
+		}
+		handler, _ := NewResponseCacheHandler(purger, auth)
+		req := httptest.NewRequest(http.MethodDelete, EndpointApiAdminResponseCache, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		token, err := auth.GenerateToken("admin", true)
+		require.Nil(t, err)
+
+		handler, _ := NewResponseCacheHandler(&testscommon.ResponseCacheStub{}, auth)
+		req := httptest.NewRequest(http.MethodGet, EndpointApiAdminResponseCache, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+	})
+
+	t.Run("success - admin", func(t *testing.T) {
+		token, err := auth.GenerateToken("admin", true)
+		require.Nil(t, err)
+
+		numPurgeCalls := 0
+		purger := &testscommon.ResponseCacheStub{
+			PurgeCalled: func() int {
+				numPurgeCalls++
+				return 37
+			},
+		}
+		handler, _ := NewResponseCacheHandler(purger, auth)
+		req := httptest.NewRequest(http.MethodDelete, EndpointApiAdminResponseCache, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, 1, numPurgeCalls)
+
+		decoded := make(map[string]int)
+		err = json.NewDecoder(resp.Body).Decode(&decoded)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]int{"purgedEntries": 37}, decoded)
+	})
+}
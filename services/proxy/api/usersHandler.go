@@ -2,10 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/multiversx/mx-chain-core-go/core/check"
 )
 
@@ -13,10 +15,11 @@ import (
 type usersHandler struct {
 	keyAccessProvider KeyAccessProvider
 	auth              Authenticator
+	tiers             map[string]struct{}
 }
 
-// NewUsersHandler creates a new usersHandler instance
-func NewUsersHandler(keyAccessProvider KeyAccessProvider, auth Authenticator) (*usersHandler, error) {
+// NewUsersHandler creates a new usersHandler instance. The users can only be assigned to the provided tiers.
+func NewUsersHandler(keyAccessProvider KeyAccessProvider, auth Authenticator, tiers []config.TierConfig) (*usersHandler, error) {
 	if check.IfNil(keyAccessProvider) {
 		return nil, errNilKeyAccessProvider
 	}
@@ -24,10 +27,16 @@ func NewUsersHandler(keyAccessProvider KeyAccessProvider, auth Authenticator) (*
 		return nil, errNilAuthenticator
 	}
 
-	return &usersHandler{
+	handler := &usersHandler{
 		keyAccessProvider: keyAccessProvider,
 		auth:              auth,
-	}, nil
+		tiers:             make(map[string]struct{}, len(tiers)),
+	}
+	for _, tier := range tiers {
+		handler.tiers[strings.TrimSpace(tier.Name)] = struct{}{}
+	}
+
+	return handler, nil
 }
 
 // ServeHTTP implements http.Handler interface
@@ -78,9 +87,15 @@ func (handler *usersHandler) handlePut(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "username is required", http.StatusBadRequest)
 		return
 	}
+	err = handler.checkTier(req.Tier)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	isPremium := strings.EqualFold(req.AccountType, string(common.PremiumAccountType))
-	err = handler.keyAccessProvider.UpdateUser(req.Username, req.Password, req.IsAdmin, req.MaxRequests, isPremium)
+	// the user keeps its tier if the request does not provide one
+	err = handler.keyAccessProvider.UpdateUser(req.Username, req.Password, req.IsAdmin, req.MaxRequests, isPremium, req.Tier)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -135,11 +150,12 @@ func (handler *usersHandler) handleGet(w http.ResponseWriter, r *http.Request) {
 }
 
 type addUserRequest struct {
-	MaxRequests uint64 `json:"max_requests"`
-	Username    string `json:"username"`
-	Password    string `json:"password"`
-	IsAdmin     bool   `json:"is_admin"`
-	AccountType string `json:"account_type"`
+	MaxRequests uint64  `json:"max_requests"`
+	Username    string  `json:"username"`
+	Password    string  `json:"password"`
+	IsAdmin     bool    `json:"is_admin"`
+	AccountType string  `json:"account_type"`
+	Tier        *string `json:"tier"`
 }
 
 func (handler *usersHandler) handlePost(w http.ResponseWriter, r *http.Request) {
@@ -155,6 +171,11 @@ func (handler *usersHandler) handlePost(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "username is required", http.StatusBadRequest)
 		return
 	}
+	err = handler.checkTier(req.Tier)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	isPremium := strings.EqualFold(req.AccountType, string(common.PremiumAccountType))
 	err = handler.keyAccessProvider.AddUser(req.Username, req.Password, req.IsAdmin, req.MaxRequests, isPremium, true, "")
@@ -163,5 +184,26 @@ func (handler *usersHandler) handlePost(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if req.Tier != nil && len(*req.Tier) > 0 {
+		err = handler.keyAccessProvider.SetUserTier(req.Username, *req.Tier)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
+
+func (handler *usersHandler) checkTier(tier *string) error {
+	if tier == nil || len(*tier) == 0 {
+		return nil
+	}
+
+	_, found := handler.tiers[*tier]
+	if !found {
+		return fmt.Errorf("unknown tier: %s", *tier)
+	}
+
+	return nil
+}
@@ -9,8 +9,10 @@ import (
 	"testing"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewUsersHandler(t *testing.T) {
@@ -19,7 +21,7 @@ func TestNewUsersHandler(t *testing.T) {
 	t.Run("nil key access provider", func(t *testing.T) {
 		t.Parallel()
 
-		handler, err := NewUsersHandler(nil, &testscommon.AuthenticatorStub{})
+		handler, err := NewUsersHandler(nil, &testscommon.AuthenticatorStub{}, nil)
 		assert.Equal(t, errNilKeyAccessProvider, err)
 		assert.Nil(t, handler)
 	})
@@ -27,7 +29,7 @@ func TestNewUsersHandler(t *testing.T) {
 	t.Run("nil authenticator", func(t *testing.T) {
 		t.Parallel()
 
-		handler, err := NewUsersHandler(&testscommon.StorerStub{}, nil)
+		handler, err := NewUsersHandler(&testscommon.StorerStub{}, nil, nil)
 		assert.Equal(t, errNilAuthenticator, err)
 		assert.Nil(t, handler)
 	})
@@ -36,7 +38,7 @@ func TestNewUsersHandler(t *testing.T) {
 		t.Parallel()
 
 		provider := &testscommon.StorerStub{}
-		handler, err := NewUsersHandler(provider, &testscommon.AuthenticatorStub{})
+		handler, err := NewUsersHandler(provider, &testscommon.AuthenticatorStub{}, nil)
 		assert.Nil(t, err)
 		assert.NotNil(t, handler)
 	})
@@ -52,7 +54,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 
 		token, _ := auth.GenerateToken("admin", true)
 
-		handler, _ := NewUsersHandler(&testscommon.StorerStub{}, auth)
+		handler, _ := NewUsersHandler(&testscommon.StorerStub{}, auth, nil)
 		req := httptest.NewRequest(http.MethodTrace, "/api/admin-users", nil)
 		resp := httptest.NewRecorder()
 		req.Header.Set("Authorization", "Bearer "+token)
@@ -64,7 +66,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 	t.Run("unauthorized - missing token", func(t *testing.T) {
 		t.Parallel()
 
-		handler, _ := NewUsersHandler(&testscommon.StorerStub{}, auth)
+		handler, _ := NewUsersHandler(&testscommon.StorerStub{}, auth, nil)
 		req := httptest.NewRequest(http.MethodGet, "/api/admin-users", nil)
 		resp := httptest.NewRecorder()
 
@@ -78,7 +80,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 		token, _ := auth.GenerateToken("user", false)
 
 		provider := &testscommon.StorerStub{}
-		handler, _ := NewUsersHandler(provider, auth)
+		handler, _ := NewUsersHandler(provider, auth, nil)
 		req := httptest.NewRequest(http.MethodGet, "/api/admin-users", nil)
 		req.Header.Set("Authorization", "Bearer "+token)
 		resp := httptest.NewRecorder()
@@ -93,7 +95,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 		token, _ := auth.GenerateToken("user", false)
 
 		provider := &testscommon.StorerStub{}
-		handler, _ := NewUsersHandler(provider, auth)
+		handler, _ := NewUsersHandler(provider, auth, nil)
 		req := httptest.NewRequest(http.MethodPost, "/api/admin-users", nil)
 		req.Header.Set("Authorization", "Bearer "+token)
 		resp := httptest.NewRecorder()
@@ -108,7 +110,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 		token, _ := auth.GenerateToken("user", false)
 
 		provider := &testscommon.StorerStub{}
-		handler, _ := NewUsersHandler(provider, auth)
+		handler, _ := NewUsersHandler(provider, auth, nil)
 		req := httptest.NewRequest(http.MethodGet, "/api/admin-users?username=user2", nil)
 		req.Header.Set("Authorization", "Bearer "+token)
 		resp := httptest.NewRecorder()
@@ -129,7 +131,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 				}, nil
 			},
 		}
-		handler, _ := NewUsersHandler(provider, auth)
+		handler, _ := NewUsersHandler(provider, auth, nil)
 		req := httptest.NewRequest(http.MethodGet, "/api/admin-users", nil)
 		req.Header.Set("Authorization", "Bearer "+token)
 		resp := httptest.NewRecorder()
@@ -154,7 +156,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 				return &common.UsersDetails{Username: username, IsAdmin: false}, nil
 			},
 		}
-		handler, _ := NewUsersHandler(provider, auth)
+		handler, _ := NewUsersHandler(provider, auth, nil)
 		req := httptest.NewRequest(http.MethodGet, "/api/admin-users?username=user1", nil)
 		req.Header.Set("Authorization", "Bearer "+token)
 		resp := httptest.NewRecorder()
@@ -179,7 +181,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 				return nil, errors.New("db error")
 			},
 		}
-		handler, _ := NewUsersHandler(provider, auth)
+		handler, _ := NewUsersHandler(provider, auth, nil)
 		req := httptest.NewRequest(http.MethodGet, "/api/admin-users", nil)
 		req.Header.Set("Authorization", "Bearer "+token)
 		resp := httptest.NewRecorder()
@@ -206,7 +208,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 				return nil
 			},
 		}
-		handler, _ := NewUsersHandler(provider, auth)
+		handler, _ := NewUsersHandler(provider, auth, nil)
 
 		reqBody := addUserRequest{
 			Username:    expectedUsername,
@@ -229,7 +231,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 		token, _ := auth.GenerateToken("admin", true)
 
 		provider := &testscommon.StorerStub{}
-		handler, _ := NewUsersHandler(provider, auth)
+		handler, _ := NewUsersHandler(provider, auth, nil)
 
 		req := httptest.NewRequest(http.MethodPost, "/api/admin-users", bytes.NewBufferString("invalid json"))
 		req.Header.Set("Authorization", "Bearer "+token)
@@ -245,7 +247,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 		token, _ := auth.GenerateToken("admin", true)
 
 		provider := &testscommon.StorerStub{}
-		handler, _ := NewUsersHandler(provider, auth)
+		handler, _ := NewUsersHandler(provider, auth, nil)
 
 		reqBody := addUserRequest{
 			Password: "pass",
@@ -268,12 +270,12 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 		expectedMaxRequests := uint64(1000)
 
 		provider := &testscommon.StorerStub{
-			UpdateUserHandler: func(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool) error {
+			UpdateUserHandler: func(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, tier *string) error {
 				assert.Fail(t, "should have not called this")
 				return nil
 			},
 		}
-		handler, _ := NewUsersHandler(provider, auth)
+		handler, _ := NewUsersHandler(provider, auth, nil)
 
 		reqBody := addUserRequest{
 			Username:    "",
@@ -300,7 +302,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 		expectedMaxRequests := uint64(1000)
 
 		provider := &testscommon.StorerStub{
-			UpdateUserHandler: func(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool) error {
+			UpdateUserHandler: func(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, tier *string) error {
 				assert.Equal(t, expectedUsername, username)
 				assert.Equal(t, expectedPassword, password)
 				assert.False(t, isAdmin)
@@ -308,7 +310,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 				return nil
 			},
 		}
-		handler, _ := NewUsersHandler(provider, auth)
+		handler, _ := NewUsersHandler(provider, auth, nil)
 
 		reqBody := addUserRequest{
 			Username:    expectedUsername,
@@ -325,6 +327,131 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 		assert.Equal(t, http.StatusOK, resp.Code)
 	})
 
+	t.Run("post with tier should assign the tier", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("admin", true)
+
+		tierSet := ""
+		provider := &testscommon.StorerStub{
+			SetUserTierHandler: func(username string, tier string) error {
+				assert.Equal(t, "user2", username)
+				tierSet = tier
+				return nil
+			},
+		}
+		handler, _ := NewUsersHandler(provider, auth, []config.TierConfig{{Name: "gold"}})
+
+		tier := "gold"
+		reqBody := addUserRequest{
+			Username: "user2",
+			Password: "password",
+			Tier:     &tier,
+		}
+		bodyBytes, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/api/admin-users", bytes.NewBuffer(bodyBytes))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "gold", tierSet)
+	})
+
+	t.Run("post and put with unknown tier should error", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("admin", true)
+
+		provider := &testscommon.StorerStub{
+			AddUserHandler: func(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, isActive bool, activationToken string) error {
+				assert.Fail(t, "should have not called this")
+				return nil
+			},
+			UpdateUserHandler: func(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, tier *string) error {
+				assert.Fail(t, "should have not called this")
+				return nil
+			},
+		}
+		handler, _ := NewUsersHandler(provider, auth, []config.TierConfig{{Name: "gold"}})
+
+		tier := "platinum"
+		reqBody := addUserRequest{
+			Username: "user2",
+			Tier:     &tier,
+		}
+		bodyBytes, _ := json.Marshal(reqBody)
+		for _, method := range []string{http.MethodPost, http.MethodPut} {
+			req := httptest.NewRequest(method, "/api/admin-users", bytes.NewBuffer(bodyBytes))
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Contains(t, resp.Body.String(), "unknown tier: platinum")
+		}
+	})
+
+	t.Run("put without tier should keep the tier", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("admin", true)
+
+		numCalls := 0
+		provider := &testscommon.StorerStub{
+			UpdateUserHandler: func(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, tier *string) error {
+				assert.Equal(t, "user2", username)
+				assert.Nil(t, tier)
+				numCalls++
+				return nil
+			},
+			SetUserTierHandler: func(username string, tier string) error {
+				assert.Fail(t, "should have not called this")
+				return nil
+			},
+		}
+		handler, _ := NewUsersHandler(provider, auth, []config.TierConfig{{Name: "gold"}})
+
+		req := httptest.NewRequest(http.MethodPut, "/api/admin-users", bytes.NewBufferString(`{"username":"user2","account_type":"premium"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, 1, numCalls)
+	})
+
+	t.Run("put with tier should replace the tier", func(t *testing.T) {
+		t.Parallel()
+
+		token, _ := auth.GenerateToken("admin", true)
+
+		providedTiers := make([]string, 0)
+		provider := &testscommon.StorerStub{
+			UpdateUserHandler: func(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, tier *string) error {
+				assert.Equal(t, "user2", username)
+				require.NotNil(t, tier)
+				providedTiers = append(providedTiers, *tier)
+				return nil
+			},
+			SetUserTierHandler: func(username string, tier string) error {
+				assert.Fail(t, "should have not called this")
+				return nil
+			},
+		}
+		handler, _ := NewUsersHandler(provider, auth, []config.TierConfig{{Name: "gold"}})
+
+		for _, body := range []string{`{"username":"user2","tier":"gold"}`, `{"username":"user2","tier":""}`} {
+			req := httptest.NewRequest(http.MethodPut, "/api/admin-users", bytes.NewBufferString(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusOK, resp.Code)
+		}
+		assert.Equal(t, []string{"gold", ""}, providedTiers)
+	})
+
 	t.Run("delete fails if no username is provided", func(t *testing.T) {
 		t.Parallel()
 
@@ -336,7 +463,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 				return nil
 			},
 		}
-		handler, _ := NewUsersHandler(provider, auth)
+		handler, _ := NewUsersHandler(provider, auth, nil)
 
 		req := httptest.NewRequest(http.MethodDelete, "/api/admin-users?username=", nil)
 		req.Header.Set("Authorization", "Bearer "+token)
@@ -359,7 +486,7 @@ func TestUsersHandler_ServeHTTP(t *testing.T) {
 				return nil
 			},
 		}
-		handler, _ := NewUsersHandler(provider, auth)
+		handler, _ := NewUsersHandler(provider, auth, nil)
 
 		req := httptest.NewRequest(http.MethodDelete, "/api/admin-users?username="+expectedUsername, nil)
 		req.Header.Set("Authorization", "Bearer "+token)
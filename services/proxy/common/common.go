@@ -3,15 +3,22 @@ package common
 import (
 	"context"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
+	"io"
+	"net/http"
 	"strings"
 	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 )
 
 const showFirstLetters = 3
 const showLastLetters = 3
 const additionalLetters = 3
 const apiKeySize = 16
+const keyPrefixLength = 6
+const headerAuthorization = "Authorization"
 
 // AnonymizeKey will anonymize the provided key
 func AnonymizeKey(key string) string {
@@ -23,6 +30,12 @@ func AnonymizeKey(key string) string {
 	return key[:showFirstLetters] + strings.Repeat("*", numHiddenLetters) + key[showFirstLetters+numHiddenLetters:]
 }
 
+// KeyPrefix returns the first characters of the provided key, used to recognize the key once only its hash is stored.
+// At most a quarter of the key is returned.
+func KeyPrefix(key string) string {
+	return key[:min(keyPrefixLength, len(key)/4)]
+}
+
 // GenerateKey will generate a new key
 func GenerateKey() string {
 	buff := make([]byte, apiKeySize)
@@ -31,6 +44,59 @@ func GenerateKey() string {
 	return hex.EncodeToString(buff)
 }
 
+// NewUpstreamRequest creates a request for the provided gateway. The gateway's path rewrites are applied on the request
+// URI and the gateway's static headers and basic auth credentials are set over the provided header, which can be nil.
+// All the requests sent to the gateways should be created here.
+func NewUpstreamRequest(ctx context.Context, method string, gateway config.GatewayConfig, requestURI string, header http.Header, body io.Reader) (*http.Request, error) {
+	upstreamURL := strings.TrimSuffix(gateway.URL, "/") + rewriteUpstreamPath(requestURI, gateway.PathRewrites)
+	request, err := http.NewRequestWithContext(ctx, method, upstreamURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if header != nil {
+		request.Header = header
+	}
+	setUpstreamHeaders(request.Header, gateway)
+
+	return request, nil
+}
+
+// rewriteUpstreamPath replaces the prefix of the request path using the first matching rule
+func rewriteUpstreamPath(requestURI string, pathRewrites []config.PathRewriteConfig) string {
+	for _, pathRewrite := range pathRewrites {
+		if strings.HasPrefix(requestURI, pathRewrite.Prefix) {
+			return pathRewrite.Replacement + strings.TrimPrefix(requestURI, pathRewrite.Prefix)
+		}
+	}
+
+	return requestURI
+}
+
+// setUpstreamHeaders sets the static headers and the basic auth credentials required by the provided gateway on the
+// header of a request sent to it
+func setUpstreamHeaders(header http.Header, gateway config.GatewayConfig) {
+	for key, value := range gateway.Headers {
+		header.Set(key, value)
+	}
+
+	if len(gateway.BasicAuthUsername) > 0 {
+		credentials := gateway.BasicAuthUsername + ":" + gateway.BasicAuthPassword
+		header.Set(headerAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+}
+
+// IsKnownHTTPMethod returns true if the provided upper case method is one of the methods a gateway can serve
+func IsKnownHTTPMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+		http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
 // CronJobStarter is able to start a go routine that periodically calls the provided handler. The time between calls is
 // provided as timeToCall
 func CronJobStarter(ctx context.Context, handler func(), timeToCall time.Duration) {
@@ -51,7 +117,9 @@ func CronJobStarter(ctx context.Context, handler func(), timeToCall time.Duratio
 }
 
 // ProcessUserDetails implements a high-level logic to set 3 fields on the provided user details object: the
-// ProcessedAccountType, CryptoPaymentInitiated and IsUnlimited
+// ProcessedAccountType, CryptoPaymentInitiated and IsUnlimited. A premium account assigned to a tier gets the tier as
+// its account type. The account keeps its credits while GlobalCounter is below MaxRequests, regardless of the cost of
+// the next request, so its last request can exceed MaxRequests by up to its cost minus 1.
 func ProcessUserDetails(userDetails *UsersDetails) {
 	if userDetails == nil {
 		return
@@ -60,7 +128,7 @@ func ProcessUserDetails(userDetails *UsersDetails) {
 	if userDetails.IsPremium {
 		// the account is premium (no heavy throttling) with unlimited request
 		userDetails.IsUnlimited = true
-		userDetails.ProcessedAccountType = PremiumAccountType
+		userDetails.ProcessedAccountType = getPremiumAccountType(userDetails.Tier)
 		userDetails.CryptoPaymentInitiated = false
 
 		return
@@ -71,7 +139,7 @@ func ProcessUserDetails(userDetails *UsersDetails) {
 	if userDetails.MaxRequests > 0 && userDetails.GlobalCounter < userDetails.MaxRequests {
 		// the account is premium (no heavy throttling) with credits still left
 		userDetails.IsUnlimited = false
-		userDetails.ProcessedAccountType = PremiumAccountType
+		userDetails.ProcessedAccountType = getPremiumAccountType(userDetails.Tier)
 
 		return
 	}
@@ -83,3 +151,11 @@ func ProcessUserDetails(userDetails *UsersDetails) {
 	userDetails.IsUnlimited = false
 	userDetails.ProcessedAccountType = FreeAccountType
 }
+
+func getPremiumAccountType(tier string) AccountType {
+	if len(tier) == 0 {
+		return PremiumAccountType
+	}
+
+	return AccountType(tier)
+}
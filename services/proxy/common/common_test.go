@@ -3,10 +3,13 @@ package common
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,6 +20,101 @@ func TestGenerateKey(t *testing.T) {
 	assert.Equal(t, apiKeySize*2, len(key))
 }
 
+func TestSetUpstreamHeaders(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no upstream settings should not change the header", func(t *testing.T) {
+		t.Parallel()
+
+		header := http.Header{"Content-Type": []string{"application/json"}}
+		setUpstreamHeaders(header, config.GatewayConfig{})
+		assert.Equal(t, http.Header{"Content-Type": []string{"application/json"}}, header)
+	})
+	t.Run("should set the static headers", func(t *testing.T) {
+		t.Parallel()
+
+		header := http.Header{"Authorization": []string{"client token"}}
+		setUpstreamHeaders(header, config.GatewayConfig{
+			Headers: map[string]string{
+				"authorization": "Bearer token",
+				"X-Squad":       "archive",
+			},
+		})
+		assert.Equal(t, "Bearer token", header.Get("Authorization"))
+		assert.Equal(t, []string{"Bearer token"}, header.Values("Authorization"))
+		assert.Equal(t, "archive", header.Get("X-Squad"))
+	})
+	t.Run("should set the basic auth credentials", func(t *testing.T) {
+		t.Parallel()
+
+		header := http.Header{}
+		setUpstreamHeaders(header, config.GatewayConfig{
+			BasicAuthUsername: "user",
+			BasicAuthPassword: "pass",
+		})
+
+		request := &http.Request{Header: header}
+		username, password, ok := request.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "pass", password)
+	})
+}
+
+func TestRewriteUpstreamPath(t *testing.T) {
+	t.Parallel()
+
+	pathRewrites := []config.PathRewriteConfig{
+		{
+			Prefix:      "/network/",
+			Replacement: "/mainnet/network/",
+		},
+		{
+			Prefix:      "/v1/",
+			Replacement: "/",
+		},
+	}
+
+	assert.Equal(t, "/address/erd1?withGuardianInfo=true", rewriteUpstreamPath("/address/erd1?withGuardianInfo=true", nil))
+	assert.Equal(t, "/address/erd1", rewriteUpstreamPath("/address/erd1", pathRewrites))
+	assert.Equal(t, "/mainnet/network/config", rewriteUpstreamPath("/network/config", pathRewrites))
+	assert.Equal(t, "/address/erd1?x=1", rewriteUpstreamPath("/v1/address/erd1?x=1", pathRewrites))
+}
+
+func TestNewUpstreamRequest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		request, err := NewUpstreamRequest(context.Background(), http.MethodGet, config.GatewayConfig{URL: string([]byte{0x7f})}, "/network/config", nil, nil)
+		assert.NotNil(t, err)
+		assert.Nil(t, request)
+	})
+	t.Run("should apply the path rewrites, headers and credentials of the gateway", func(t *testing.T) {
+		t.Parallel()
+
+		gateway := config.GatewayConfig{
+			URL:               "https://archive.internal/",
+			Headers:           map[string]string{"X-Squad": "archive"},
+			BasicAuthUsername: "user",
+			BasicAuthPassword: "pass",
+			PathRewrites:      []config.PathRewriteConfig{{Prefix: "/", Replacement: "/mainnet/"}},
+		}
+		header := http.Header{"X-Request-Id": []string{"id"}}
+		request, err := NewUpstreamRequest(context.Background(), http.MethodPost, gateway, "/network/config?x=1", header, strings.NewReader("body"))
+		assert.Nil(t, err)
+		assert.Equal(t, http.MethodPost, request.Method)
+		assert.Equal(t, "https://archive.internal/mainnet/network/config?x=1", request.URL.String())
+		assert.Equal(t, "id", request.Header.Get("X-Request-Id"))
+		assert.Equal(t, "archive", request.Header.Get("X-Squad"))
+		username, password, ok := request.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "pass", password)
+	})
+}
+
 func TestAnonymizeKey(t *testing.T) {
 	t.Parallel()
 
@@ -45,6 +143,21 @@ func TestAnonymizeKey(t *testing.T) {
 	})
 }
 
+func TestKeyPrefix(t *testing.T) {
+	t.Parallel()
+
+	t.Run("small keys should return at most a quarter of the key", func(t *testing.T) {
+		assert.Empty(t, KeyPrefix(""))
+		assert.Empty(t, KeyPrefix("012"))
+		assert.Equal(t, "0", KeyPrefix("0123"))
+		assert.Equal(t, "01", KeyPrefix("01234567"))
+	})
+	t.Run("should work", func(t *testing.T) {
+		key := GenerateKey()
+		assert.Equal(t, key[:6], KeyPrefix(key))
+	})
+}
+
 func TestCronJob(t *testing.T) {
 	t.Parallel()
 
@@ -213,4 +326,45 @@ func TestProcessUserDetails(t *testing.T) {
 		assert.Equal(t, uint64(100), userDetails.GlobalCounter)
 		assert.Equal(t, uint64(1), userDetails.CryptoPaymentID)
 	})
+	t.Run("premium with a tier should use the tier as account type", func(t *testing.T) {
+		userDetails := &UsersDetails{
+			IsPremium: true,
+			Tier:      "gold",
+		}
+
+		ProcessUserDetails(userDetails)
+		assert.Equal(t, AccountType("gold"), userDetails.ProcessedAccountType)
+		assert.True(t, userDetails.IsUnlimited)
+
+		userDetails = &UsersDetails{
+			GlobalCounter: 10,
+			MaxRequests:   100,
+			Tier:          "gold",
+		}
+
+		ProcessUserDetails(userDetails)
+		assert.Equal(t, AccountType("gold"), userDetails.ProcessedAccountType)
+		assert.False(t, userDetails.IsUnlimited)
+	})
+	t.Run("depleted account with a tier should be free", func(t *testing.T) {
+		userDetails := &UsersDetails{
+			GlobalCounter: 100,
+			MaxRequests:   100,
+			Tier:          "gold",
+		}
+
+		ProcessUserDetails(userDetails)
+		assert.Equal(t, FreeAccountType, userDetails.ProcessedAccountType)
+	})
+}
+
+func TestIsKnownHTTPMethod(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, IsKnownHTTPMethod(http.MethodGet))
+	assert.True(t, IsKnownHTTPMethod(http.MethodPost))
+	assert.True(t, IsKnownHTTPMethod(http.MethodOptions))
+	assert.False(t, IsKnownHTTPMethod("get"))
+	assert.False(t, IsKnownHTTPMethod(http.MethodConnect))
+	assert.False(t, IsKnownHTTPMethod(""))
 }
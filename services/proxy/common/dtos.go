@@ -1,6 +1,12 @@
 package common
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
 
 // AccountType describes the account type
 type AccountType string
@@ -11,14 +17,93 @@ const FreeAccountType AccountType = "free"
 // PremiumAccountType defines the premium account type, un-throttled
 const PremiumAccountType AccountType = "premium"
 
-// AccessKeyDetails holds details about an access key
+// AccessKeyDetails holds details about an access key. A rotated key holds the ID of its successor in RotatedTo, and its
+// requests done in the rotation's grace period in GraceCounter. A 0 ExpiresAt means the key does not expire. The
+// timestamps are unix seconds, 0 when unknown (CreatedAt of the keys added before it was recorded) or never used.
 type AccessKeyDetails struct {
+	ID             string
+	KeyPrefix      string
+	Label          string
+	CreatedAt      int64
+	LastUsedAt     int64
+	LastIP         string
 	MaxRequests    uint64
 	GlobalCounter  uint64
 	KeyCounter     uint64
 	Username       string
 	HashedPassword string
 	IsAdmin        bool
+	Scope          KeyScope
+	Restrictions   KeyRestrictions
+	ExpiresAt      int64
+	RotatedTo      string
+	GraceCounter   uint64
+}
+
+// KeyScope holds the restrictions of an access key: the path prefixes and the HTTP methods it can call and the epochs
+// range of the gateways it can reach. The empty fields do not restrict the key, an empty or "latest" EpochEnd allowing
+// the gateways with the latest data.
+type KeyScope struct {
+	Routes     []string `json:"Routes,omitempty"`
+	Methods    []string `json:"Methods,omitempty"`
+	EpochStart string   `json:"EpochStart,omitempty"`
+	EpochEnd   string   `json:"EpochEnd,omitempty"`
+}
+
+// IsEmpty returns true if the scope does not restrict the key
+func (scope KeyScope) IsEmpty() bool {
+	return len(scope.Routes) == 0 && len(scope.Methods) == 0 && len(scope.EpochStart) == 0 && len(scope.EpochEnd) == 0
+}
+
+// KeyRestrictions holds the client restrictions set by the owner of an access key: the CIDRs the client IP should
+// belong to and the origins (scheme://host[:port], the host optionally starting with a *. wildcard) the browser
+// requests should come from. The empty fields do not restrict the key.
+type KeyRestrictions struct {
+	AllowedCIDRs   []string `json:"AllowedCIDRs,omitempty"`
+	AllowedOrigins []string `json:"AllowedOrigins,omitempty"`
+}
+
+// IsEmpty returns true if the restrictions do not restrict the key
+func (restrictions KeyRestrictions) IsEmpty() bool {
+	return len(restrictions.AllowedCIDRs) == 0 && len(restrictions.AllowedOrigins) == 0
+}
+
+// KeySettings holds the settings written together with a new access key: its scope, its expiry (a unix timestamp in
+// seconds, 0 for no expiry), its label and its client restrictions. A rotation's successor inherits the rotated key's
+// scope, label and restrictions that are not provided (empty scope, nil label or restrictions).
+type KeySettings struct {
+	Scope        KeyScope
+	ExpiresAt    int64
+	Label        *string
+	Restrictions *KeyRestrictions
+}
+
+// KeyUpdate holds the changes applied to an existing access key. Only the provided fields are replaced: an empty scope
+// removes the key's restrictions and a 0 expiry removes its expiry.
+type KeyUpdate struct {
+	Scope     *KeyScope
+	ExpiresAt *int64
+}
+
+// KeyAccess holds what is needed to decide if a request can be done with an access key: the key's ID, its owner's
+// account, the key's scope and client restrictions and the credits consumed so far by the owner
+type KeyAccess struct {
+	KeyID         string
+	Username      string
+	AccountType   AccountType
+	Scope         KeyScope
+	Restrictions  KeyRestrictions
+	GlobalCounter uint64
+}
+
+// KeyViolation describes a request rejected as it did not match the restrictions of the used access key
+type KeyViolation struct {
+	Timestamp int64  `json:"Timestamp"`
+	Username  string `json:"Username"`
+	KeyPrefix string `json:"KeyPrefix"`
+	ClientIP  string `json:"ClientIP"`
+	Origin    string `json:"Origin"`
+	Reason    string `json:"Reason"`
 }
 
 // UsersDetails holds details about a user
@@ -28,6 +113,7 @@ type UsersDetails struct {
 	Username               string      `json:"Username"`
 	HashedPassword         string      `json:"HashedPassword"`
 	IsPremium              bool        `json:"IsPremium"`
+	Tier                   string      `json:"Tier"`
 	ProcessedAccountType   AccountType `json:"AccountType"`
 	CryptoPaymentInitiated bool        `json:"CryptoPaymentInitiated"`
 	IsUnlimited            bool        `json:"IsUnlimited"`
@@ -43,3 +129,73 @@ type Claims struct {
 	IsAdmin  bool   `json:"is_admin"`
 	jwt.RegisteredClaims
 }
+
+// GatewayHealthStatus holds the health state of a gateway (or a gateway's replica)
+type GatewayHealthStatus struct {
+	Name                 string `json:"Name"`
+	URL                  string `json:"URL"`
+	EpochStart           string `json:"EpochStart"`
+	EpochEnd             string `json:"EpochEnd"`
+	IsHealthy            bool   `json:"IsHealthy"`
+	ConsecutiveFailures  uint32 `json:"ConsecutiveFailures"`
+	ConsecutiveSuccesses uint32 `json:"ConsecutiveSuccesses"`
+	LastError            string `json:"LastError"`
+	LastCheckTimestamp   int64  `json:"LastCheckTimestamp"`
+}
+
+// EpochStartInfo holds the nonce and timestamp of an epoch's first block, as reported by a gateway
+type EpochStartInfo struct {
+	Gateway   string `json:"Gateway"`
+	Shard     uint32 `json:"Shard"`
+	Epoch     uint64 `json:"Epoch"`
+	Nonce     uint64 `json:"Nonce"`
+	Timestamp int64  `json:"Timestamp"`
+}
+
+// GatewayRangeIssue describes a problem found while building the gateways range table
+type GatewayRangeIssue struct {
+	Type    string `json:"Type"`
+	Gateway string `json:"Gateway"`
+	Details string `json:"Details"`
+}
+
+// GatewaysDiscoveryReport holds the result of the last gateways ranges discovery
+type GatewaysDiscoveryReport struct {
+	Timestamp int64                  `json:"Timestamp"`
+	Gateways  []config.GatewayConfig `json:"Gateways"`
+	Issues    []GatewayRangeIssue    `json:"Issues"`
+}
+
+// CachedResponse holds a gateway response stored in the response cache
+type CachedResponse struct {
+	StatusCode int         `json:"StatusCode"`
+	Header     http.Header `json:"Header"`
+	Body       []byte      `json:"Body"`
+}
+
+// RateLimitResult holds the decision of a rate limiter for one request. An allowed request with a 0 Limit belongs to an
+// account that is not limited.
+// ResetAfter is the time left until the account gets its whole quota back, RetryAfter the time left until the next
+// request is allowed.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      uint64
+	Remaining  uint64
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+// AccessResult holds the outcome of the access check of a request: the request URI without the access key, the
+// account that sent the request, the rate limiter's decision, the number of credits the request costs, the scope of
+// the used key, the client IP resolved behind the trusted proxies and the access of the used key, charged once the
+// request is routed
+type AccessResult struct {
+	RequestURI  string
+	Username    string
+	AccountType AccountType
+	RateLimit   RateLimitResult
+	Cost        uint64
+	Scope       KeyScope
+	ClientIP    string
+	KeyAccess   KeyAccess
+}
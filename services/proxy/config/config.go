@@ -6,20 +6,165 @@ type Config struct {
 	CountersCacheTTLInSeconds uint32
 	UpdateContractDBInSeconds uint32
 	FreeAccount               FreeAccountConfig
+	RateLimiter               RateLimiterConfig
+	SlowLane                  SlowLaneConfig
+	Tiers                     []TierConfig
+	CreditCosts               []CreditCostConfig
+	KeysRotation              KeysRotationConfig
 	Gateways                  []GatewayConfig
+	GatewaysTest              GatewaysTestConfig
+	HealthCheck               HealthCheckConfig
+	GatewaysDiscovery         GatewaysDiscoveryConfig
+	PathRouting               PathRoutingConfig
+	BodyRouting               BodyRoutingConfig
+	HashRouting               HashRoutingConfig
+	TimestampRouting          TimestampRoutingConfig
+	Retry                     RetryConfig
+	Forwarding                ForwardingConfig
+	ResponseCache             ResponseCacheConfig
+	Coalescing                CoalescingConfig
+	Headers                   HeadersConfig
+	ClientIP                  ClientIPConfig
 	ClosedEndpoints           []string
 	AppDomains                AppDomainsConfig
 	CryptoPayment             CryptoPaymentConfig
 }
 
-// GatewayConfig defines a gateway and its set epochs
+// GatewayConfig defines a gateway and its set epochs. The upstream headers, basic auth credentials and path rewrites
+// also apply to the gateway's replicas.
 type GatewayConfig struct {
-	URL        string
-	EpochStart string
-	EpochEnd   string
-	NonceStart string
-	NonceEnd   string
-	Name       string
+	URL               string
+	EpochStart        string
+	EpochEnd          string
+	NonceStart        string
+	NonceEnd          string
+	Name              string
+	Weight            uint64
+	LoadBalancer      string
+	Fallback          string
+	TimeoutInSeconds  uint64
+	Headers           map[string]string `json:"-"`
+	BasicAuthUsername string
+	BasicAuthPassword string `json:"-"`
+	PathRewrites      []PathRewriteConfig
+	Replicas          []ReplicaConfig
+}
+
+// PathRewriteConfig replaces the Prefix of the forwarded request paths with the Replacement value
+type PathRewriteConfig struct {
+	Prefix      string
+	Replacement string
+}
+
+// ReplicaConfig defines an additional upstream that holds the same data as its parent gateway
+type ReplicaConfig struct {
+	URL    string
+	Name   string
+	Weight uint64
+}
+
+// GatewaysTestConfig holds the configuration for the gateways' probing done at startup and on reloads
+type GatewaysTestConfig struct {
+	TimeoutInSeconds uint64
+}
+
+// HealthCheckConfig holds the configuration for the gateways' continuous health checking
+type HealthCheckConfig struct {
+	Enabled            bool
+	IntervalInSeconds  uint64
+	TimeoutInSeconds   uint64
+	UnhealthyThreshold uint32
+	HealthyThreshold   uint32
+}
+
+// GatewaysDiscoveryConfig holds the configuration for the discovery of the ranges of the gateways that only define their URL
+type GatewaysDiscoveryConfig struct {
+	Enabled                 bool
+	ShardID                 uint32
+	IntervalInSeconds       uint64
+	RequestTimeoutInSeconds uint64
+}
+
+// PathRoutingConfig holds the request path patterns used to extract the nonce, epoch or round values for routing
+type PathRoutingConfig struct {
+	RoundsPerEpoch uint64
+	Patterns       []string
+}
+
+// BodyRoutingConfig holds the configuration for the requests that carry the routing values in their JSON body
+type BodyRoutingConfig struct {
+	MaxBodySizeInBytes uint64
+	Paths              []string
+}
+
+// HashRoutingConfig holds the configuration for the routing of the requests that carry a block or transaction hash
+type HashRoutingConfig struct {
+	Enabled                 bool
+	RequestTimeoutInSeconds uint64
+	MaxParallelLookups      uint32
+	MissesCacheTTLInSeconds uint64
+	MissesCacheMaxEntries   int
+}
+
+// TimestampRoutingConfig holds the configuration for the routing of the requests that carry the atTimestamp parameter
+type TimestampRoutingConfig struct {
+	Enabled                 bool
+	ShardID                 uint32
+	LearnIntervalInSeconds  uint64
+	RequestTimeoutInSeconds uint64
+}
+
+// RetryConfig holds the configuration for retrying the failed safe requests on another replica or on the fallback gateway
+type RetryConfig struct {
+	MaxAttempts              uint32
+	BackoffInMilliseconds    uint64
+	MaxBackoffInMilliseconds uint64
+	RetryableStatusCodes     []int
+}
+
+// ForwardingConfig holds the configuration of the HTTP transport used to forward the requests to the gateways
+type ForwardingConfig struct {
+	MaxIdleConns                   int
+	MaxIdleConnsPerHost            int
+	MaxConnsPerHost                int
+	IdleConnTimeoutInSeconds       uint64
+	DialTimeoutInSeconds           uint64
+	TLSHandshakeTimeoutInSeconds   uint64
+	ResponseHeaderTimeoutInSeconds uint64
+	TimeoutInSeconds               uint64
+	CopyBufferSizeInBytes          uint64
+}
+
+// ResponseCacheConfig holds the configuration of the cache of the responses for the pinned historical requests
+type ResponseCacheConfig struct {
+	Enabled              bool
+	MaxMemorySizeInBytes uint64
+	MaxEntrySizeInBytes  uint64
+	DiskPath             string
+	MaxDiskSizeInBytes   uint64
+}
+
+// CoalescingConfig holds the configuration of the merging of the identical requests that are in flight at the same time
+type CoalescingConfig struct {
+	Enabled                bool
+	MaxResponseSizeInBytes uint64
+}
+
+// HeadersConfig holds the lists of headers passed between the clients and the gateways, for each direction. An empty
+// allow list lets all the headers pass, the deny lists take precedence.
+type HeadersConfig struct {
+	RequestAllowList  []string
+	RequestDenyList   []string
+	ResponseAllowList []string
+	ResponseDenyList  []string
+}
+
+// ClientIPConfig defines how the client IP is resolved when the proxy runs behind other proxies. The Headers are
+// read, in order, only from the requests sent by one of the TrustedProxies (IPs or CIDRs), the other requests using
+// the connection's remote address. Empty Headers mean CF-Connecting-IP, X-Forwarded-For and X-Real-IP.
+type ClientIPConfig struct {
+	TrustedProxies []string
+	Headers        []string
 }
 
 // FreeAccountConfig the configuration struct for free accounts
@@ -28,6 +173,59 @@ type FreeAccountConfig struct {
 	ClearPeriodInSeconds uint64
 }
 
+// RateLimiterConfig defines how the requests of the accounts are throttled. The fixed window limiter, the default one,
+// allows FreeAccount.MaxCalls requests for the free accounts in each FreeAccount.ClearPeriodInSeconds period. The token
+// bucket limiter uses one bucket per account, sized by its account type. The account types not listed are not limited.
+type RateLimiterConfig struct {
+	Type         string
+	TokenBuckets []TokenBucketConfig
+}
+
+// TokenBucketConfig defines the token bucket of an account type: the bucket holds at most Burst tokens and is refilled
+// with RatePerSecond tokens each second, each request consuming a token
+type TokenBucketConfig struct {
+	AccountType   string
+	RatePerSecond float64
+	Burst         uint64
+}
+
+// SlowLaneConfig defines the slow lane of the throttled free accounts. Instead of being rejected, the requests wait in a
+// per-user queue and are released at RatePerSecond. A request is only rejected if the user's queue holds
+// MaxQueueSizePerUser requests or if it would wait more than MaxWaitInMilliseconds.
+type SlowLaneConfig struct {
+	Enabled               bool
+	RatePerSecond         float64
+	MaxQueueSizePerUser   uint64
+	MaxWaitInMilliseconds uint64
+}
+
+// TierConfig defines a subscription tier. The users assigned to the tier use its Name as account type while they have
+// credits left (or are unlimited), so the tier can also be sized in the token bucket limiter. A tier sets its own token
+// bucket if RatePerSecond is not 0, caps the concurrent requests of each user if MaxConcurrentRequests is not 0 and
+// restricts the requests to the AllowedEndpoints path prefixes if the list is not empty.
+type TierConfig struct {
+	Name                  string
+	RatePerSecond         float64
+	Burst                 uint64
+	MaxConcurrentRequests uint64
+	AllowedEndpoints      []string
+}
+
+// CreditCostConfig sets the number of credits consumed by the requests matching the path Pattern and the Method (an
+// empty Method matches all the methods). A {name} placeholder in the Pattern matches any path segment. The requests
+// not matching any entry consume 1 credit.
+type CreditCostConfig struct {
+	Pattern string
+	Method  string
+	Cost    uint64
+}
+
+// KeysRotationConfig holds the configuration of the access keys rotation: a rotated key stays allowed for
+// GracePeriodInSeconds after its successor is issued
+type KeysRotationConfig struct {
+	GracePeriodInSeconds uint64
+}
+
 // AppDomainsConfig holds the configuration structs for the application domains
 type AppDomainsConfig struct {
 	Backend  string
@@ -18,7 +18,9 @@ UpdateContractDBInSeconds = 60
 # Gateways defines the list of gateways that will be used by this proxy
 Gateways = [
 	{URL="http://192.168.167.22:8080", EpochStart="0", EpochEnd="1000", NonceStart="0", NonceEnd="14401000", Name="R1"},
-	{URL="http://192.168.167.33:9090", EpochStart="1001", EpochEnd="1400", NonceStart="14401001", NonceEnd="20175801", Name="R2"},
+	{URL="http://192.168.167.33:9090", EpochStart="1001", EpochEnd="1400", NonceStart="14401001", NonceEnd="20175801", Name="R2",
+		Headers={Authorization="Bearer token", X-Squad="archive"}, BasicAuthUsername="user", BasicAuthPassword="pass",
+		PathRewrites=[{Prefix="/", Replacement="/mainnet/"}]},
 	{URL="http://192.168.167.44:9095", EpochStart="1401", EpochEnd="latest", NonceStart="20175802", NonceEnd="latest", Name="R3"},
 ]
 
@@ -29,6 +31,109 @@ ClosedEndpoints = [
     "/transaction/send-user-funds"
 ]
 
+[GatewaysDiscovery]
+    Enabled = true
+    ShardID = 4294967295
+    IntervalInSeconds = 300
+    RequestTimeoutInSeconds = 5
+
+[PathRouting]
+    RoundsPerEpoch = 14400
+    Patterns = [
+        "/block/{shard}/by-nonce/{nonce}",
+        "/blocks/by-round/{round}",
+    ]
+
+[BodyRouting]
+    MaxBodySizeInBytes = 65536
+    Paths = [
+        "/vm-values/query",
+    ]
+
+[HashRouting]
+    Enabled = true
+    RequestTimeoutInSeconds = 5
+
+[TimestampRouting]
+    Enabled = true
+    ShardID = 4294967295
+    LearnIntervalInSeconds = 60
+    RequestTimeoutInSeconds = 5
+
+[Retry]
+    MaxAttempts = 2
+    BackoffInMilliseconds = 50
+    MaxBackoffInMilliseconds = 500
+    RetryableStatusCodes = [502, 503, 504]
+
+[Forwarding]
+    MaxIdleConns = 512
+    MaxIdleConnsPerHost = 64
+    MaxConnsPerHost = 0
+    IdleConnTimeoutInSeconds = 90
+    DialTimeoutInSeconds = 5
+    TLSHandshakeTimeoutInSeconds = 5
+    ResponseHeaderTimeoutInSeconds = 60
+    TimeoutInSeconds = 300
+    CopyBufferSizeInBytes = 32768
+
+[ResponseCache]
+    Enabled = true
+    MaxMemorySizeInBytes = 268435456
+    MaxEntrySizeInBytes = 1048576
+    DiskPath = "./cache"
+    MaxDiskSizeInBytes = 2147483648
+
+[Coalescing]
+    Enabled = true
+    MaxResponseSizeInBytes = 1048576
+
+[Headers]
+    RequestAllowList = ["Content-Type", "Accept-Encoding"]
+    RequestDenyList = ["X-Forwarded-For"]
+    ResponseAllowList = []
+    ResponseDenyList = ["Server"]
+
+[ClientIP]
+    TrustedProxies = ["127.0.0.1", "10.0.0.0/8"]
+    Headers = ["CF-Connecting-IP", "X-Forwarded-For"]
+
+[RateLimiter]
+    Type = "token-bucket"
+    TokenBuckets = [
+        { AccountType = "free", RatePerSecond = 0.5, Burst = 10 },
+        { AccountType = "premium", RatePerSecond = 100.0, Burst = 200 },
+    ]
+
+[SlowLane]
+    Enabled = true
+    RatePerSecond = 0.5
+    MaxQueueSizePerUser = 5
+    MaxWaitInMilliseconds = 10000
+
+[[Tiers]]
+    Name = "starter"
+    RatePerSecond = 5.0
+    Burst = 20
+    MaxConcurrentRequests = 4
+    AllowedEndpoints = ["/address", "/network"]
+
+[[Tiers]]
+    Name = "business"
+    MaxConcurrentRequests = 32
+
+[[CreditCosts]]
+    Pattern = "/address/{address}/keys"
+    Cost = 100
+
+[[CreditCosts]]
+    Pattern = "/transaction/send"
+    Method = "POST"
+    Cost = 5
+
+[KeysRotation]
+    GracePeriodInSeconds = 86400
+
 [CryptoPayment]
     # Enable/disable crypto-payment integration
     Enabled = true
@@ -66,6 +171,18 @@ ClosedEndpoints = [
 				NonceStart: "14401001",
 				NonceEnd:   "20175801",
 				Name:       "R2",
+				Headers: map[string]string{
+					"Authorization": "Bearer token",
+					"X-Squad":       "archive",
+				},
+				BasicAuthUsername: "user",
+				BasicAuthPassword: "pass",
+				PathRewrites: []PathRewriteConfig{
+					{
+						Prefix:      "/",
+						Replacement: "/mainnet/",
+					},
+				},
 			},
 			{
 				URL:        "http://192.168.167.44:9095",
@@ -81,6 +198,121 @@ ClosedEndpoints = [
 			"/transaction/send-multiple",
 			"/transaction/send-user-funds",
 		},
+		GatewaysDiscovery: GatewaysDiscoveryConfig{
+			Enabled:                 true,
+			ShardID:                 4294967295,
+			IntervalInSeconds:       300,
+			RequestTimeoutInSeconds: 5,
+		},
+		PathRouting: PathRoutingConfig{
+			RoundsPerEpoch: 14400,
+			Patterns: []string{
+				"/block/{shard}/by-nonce/{nonce}",
+				"/blocks/by-round/{round}",
+			},
+		},
+		BodyRouting: BodyRoutingConfig{
+			MaxBodySizeInBytes: 65536,
+			Paths: []string{
+				"/vm-values/query",
+			},
+		},
+		HashRouting: HashRoutingConfig{
+			Enabled:                 true,
+			RequestTimeoutInSeconds: 5,
+		},
+		TimestampRouting: TimestampRoutingConfig{
+			Enabled:                 true,
+			ShardID:                 4294967295,
+			LearnIntervalInSeconds:  60,
+			RequestTimeoutInSeconds: 5,
+		},
+		Retry: RetryConfig{
+			MaxAttempts:              2,
+			BackoffInMilliseconds:    50,
+			MaxBackoffInMilliseconds: 500,
+			RetryableStatusCodes:     []int{502, 503, 504},
+		},
+		Forwarding: ForwardingConfig{
+			MaxIdleConns:                   512,
+			MaxIdleConnsPerHost:            64,
+			MaxConnsPerHost:                0,
+			IdleConnTimeoutInSeconds:       90,
+			DialTimeoutInSeconds:           5,
+			TLSHandshakeTimeoutInSeconds:   5,
+			ResponseHeaderTimeoutInSeconds: 60,
+			TimeoutInSeconds:               300,
+			CopyBufferSizeInBytes:          32768,
+		},
+		ResponseCache: ResponseCacheConfig{
+			Enabled:              true,
+			MaxMemorySizeInBytes: 268435456,
+			MaxEntrySizeInBytes:  1048576,
+			DiskPath:             "./cache",
+			MaxDiskSizeInBytes:   2147483648,
+		},
+		Coalescing: CoalescingConfig{
+			Enabled:                true,
+			MaxResponseSizeInBytes: 1048576,
+		},
+		Headers: HeadersConfig{
+			RequestAllowList:  []string{"Content-Type", "Accept-Encoding"},
+			RequestDenyList:   []string{"X-Forwarded-For"},
+			ResponseAllowList: []string{},
+			ResponseDenyList:  []string{"Server"},
+		},
+		ClientIP: ClientIPConfig{
+			TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8"},
+			Headers:        []string{"CF-Connecting-IP", "X-Forwarded-For"},
+		},
+		RateLimiter: RateLimiterConfig{
+			Type: "token-bucket",
+			TokenBuckets: []TokenBucketConfig{
+				{
+					AccountType:   "free",
+					RatePerSecond: 0.5,
+					Burst:         10,
+				},
+				{
+					AccountType:   "premium",
+					RatePerSecond: 100,
+					Burst:         200,
+				},
+			},
+		},
+		SlowLane: SlowLaneConfig{
+			Enabled:               true,
+			RatePerSecond:         0.5,
+			MaxQueueSizePerUser:   5,
+			MaxWaitInMilliseconds: 10000,
+		},
+		Tiers: []TierConfig{
+			{
+				Name:                  "starter",
+				RatePerSecond:         5,
+				Burst:                 20,
+				MaxConcurrentRequests: 4,
+				AllowedEndpoints:      []string{"/address", "/network"},
+			},
+			{
+				Name:                  "business",
+				MaxConcurrentRequests: 32,
+			},
+		},
+		CreditCosts: []CreditCostConfig{
+			{
+				Pattern: "/address/{address}/keys",
+				Cost:    100,
+			},
+			{
+				Pattern: "/transaction/send",
+				Method:  "POST",
+				Cost:    5,
+			},
+		},
+		KeysRotation: KeysRotationConfig{
+			GracePeriodInSeconds: 86400,
+		},
 		CryptoPayment: CryptoPaymentConfig{
 			Enabled:                      true,
 			URL:                          "http://localhost:8081",
@@ -13,19 +13,30 @@ import (
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/process"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/serviceWrappers"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/storage"
+	"github.com/multiversx/mx-chain-core-go/core"
 	"github.com/multiversx/mx-chain-core-go/core/check"
 	logger "github.com/multiversx/mx-chain-logger-go"
 )
 
+const maxKeyViolations = 1000
+
 var log = logger.GetOrCreate("factory")
 
 type componentsHandler struct {
 	config               config.Config
-	hostFinder           process.HostFinder
+	hostFinder           process.SwappableHostFinder
+	gatewaysReloader     GatewaysReloader
 	tester               GatewayTester
+	healthChecker        GatewaysHealthChecker
+	gatewaysDiscoverer   GatewaysDiscoverer
+	timestampResolver    TimestampResolver
 	countersCache        storage.CountersCache
 	sqliteWrapper        SQLiteWrapper
-	keyCounter           process.KeyCounter
+	rateLimiter          process.RateLimiter
+	slowLane             process.SlowLane
+	tiersPolicy          process.TiersPolicy
+	violationsRecorder   process.KeyViolationsRecorder
+	clientIPResolver     process.ClientIPResolver
 	accessChecker        process.AccessChecker
 	requestsProcessor    RequestsProcessor
 	jwtAuthenticator     api.Authenticator
@@ -39,6 +50,12 @@ type componentsHandler struct {
 	usersHandler           http.Handler
 	loginHandler           http.Handler
 	performanceHandler     http.Handler
+	gatewaysHealthHandler  http.Handler
+	keyViolationsHandler   http.Handler
+	reloadGatewaysHandler  http.Handler
+	proxyMetricsHandler    http.Handler
+	gatewaysRangesHandler  http.Handler
+	responseCacheHandler   http.Handler
 	registrationHandler    http.Handler
 	captchaHandler         CaptchaHTTPHandler
 	userCredentialsHandler http.Handler
@@ -49,8 +66,10 @@ type componentsHandler struct {
 // NewComponentsHandler creates a new instance of the components handler holding all high-level components
 func NewComponentsHandler(
 	cfg config.Config,
+	configPath string,
 	sqlitePath string,
 	jwtKey string,
+	keysHashKey string,
 	emailsConfig config.EmailsConfig,
 	appVersion string,
 	swaggerPath string,
@@ -66,6 +85,42 @@ func NewComponentsHandler(
 	if cfg.UpdateContractDBInSeconds == 0 {
 		return nil, fmt.Errorf("can not start as the config contains a 0 value for UpdateContractDBInSeconds")
 	}
+	if cfg.HealthCheck.Enabled && cfg.HealthCheck.IntervalInSeconds == 0 {
+		return nil, fmt.Errorf("can not start as the config contains a 0 value for HealthCheck.IntervalInSeconds")
+	}
+	if cfg.HealthCheck.Enabled && cfg.HealthCheck.TimeoutInSeconds == 0 {
+		return nil, fmt.Errorf("can not start as the config contains a 0 value for HealthCheck.TimeoutInSeconds")
+	}
+	if cfg.GatewaysDiscovery.Enabled && cfg.GatewaysDiscovery.RequestTimeoutInSeconds == 0 {
+		return nil, fmt.Errorf("can not start as the config contains a 0 value for GatewaysDiscovery.RequestTimeoutInSeconds")
+	}
+	if cfg.HashRouting.Enabled && cfg.HashRouting.RequestTimeoutInSeconds == 0 {
+		return nil, fmt.Errorf("can not start as the config contains a 0 value for HashRouting.RequestTimeoutInSeconds")
+	}
+	if cfg.TimestampRouting.Enabled && cfg.TimestampRouting.LearnIntervalInSeconds == 0 {
+		return nil, fmt.Errorf("can not start as the config contains a 0 value for TimestampRouting.LearnIntervalInSeconds")
+	}
+	if cfg.TimestampRouting.Enabled && cfg.TimestampRouting.RequestTimeoutInSeconds == 0 {
+		return nil, fmt.Errorf("can not start as the config contains a 0 value for TimestampRouting.RequestTimeoutInSeconds")
+	}
+	if cfg.ResponseCache.Enabled && cfg.ResponseCache.MaxMemorySizeInBytes == 0 {
+		return nil, fmt.Errorf("can not start as the config contains a 0 value for ResponseCache.MaxMemorySizeInBytes")
+	}
+	if cfg.ResponseCache.Enabled && cfg.ResponseCache.MaxEntrySizeInBytes == 0 {
+		return nil, fmt.Errorf("can not start as the config contains a 0 value for ResponseCache.MaxEntrySizeInBytes")
+	}
+	if cfg.ResponseCache.Enabled && len(cfg.ResponseCache.DiskPath) > 0 && cfg.ResponseCache.MaxDiskSizeInBytes == 0 {
+		return nil, fmt.Errorf("can not start as the config contains a 0 value for ResponseCache.MaxDiskSizeInBytes")
+	}
+	if cfg.Coalescing.Enabled && cfg.Coalescing.MaxResponseSizeInBytes == 0 {
+		return nil, fmt.Errorf("can not start as the config contains a 0 value for Coalescing.MaxResponseSizeInBytes")
+	}
+	if cfg.SlowLane.Enabled && cfg.SlowLane.MaxQueueSizePerUser == 0 {
+		return nil, fmt.Errorf("can not start as the config contains a 0 value for SlowLane.MaxQueueSizePerUser")
+	}
+	if cfg.SlowLane.Enabled && cfg.SlowLane.MaxWaitInMilliseconds == 0 {
+		return nil, fmt.Errorf("can not start as the config contains a 0 value for SlowLane.MaxWaitInMilliseconds")
+	}
 	if check.IfNil(emailSender) {
 		return nil, errNilEmailSender
 	}
@@ -85,7 +140,39 @@ func NewComponentsHandler(
 		}
 	}()
 
-	ch.hostFinder, err = process.NewHostsFinder(cfg.Gateways)
+	ch.tester = api.NewGatewayTester(api.ArgsGatewayTester{
+		TestTimeout:  time.Duration(cfg.GatewaysTest.TimeoutInSeconds) * time.Second,
+		ProbeTimeout: time.Duration(cfg.HealthCheck.TimeoutInSeconds) * time.Second,
+	})
+	ch.healthChecker, err = process.NewGatewaysHealthChecker(process.ArgsGatewaysHealthChecker{
+		Prober:             ch.tester,
+		UnhealthyThreshold: cfg.HealthCheck.UnhealthyThreshold,
+		HealthyThreshold:   cfg.HealthCheck.HealthyThreshold,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch.gatewaysDiscoverer, err = process.NewGatewaysDiscoverer(process.ArgsGatewaysDiscoverer{
+		Enabled:   cfg.GatewaysDiscovery.Enabled,
+		ShardID:   cfg.GatewaysDiscovery.ShardID,
+		Requester: process.NewHttpRequester(time.Duration(cfg.GatewaysDiscovery.RequestTimeoutInSeconds) * time.Second),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	gateways, err := ch.gatewaysDiscoverer.DiscoverRanges(cfg.Gateways)
+	if err != nil {
+		return nil, err
+	}
+
+	hostFinder, err := process.NewHostsFinder(gateways, ch.healthChecker)
+	if err != nil {
+		return nil, err
+	}
+
+	ch.hostFinder, err = process.NewSwitchableHostFinder(hostFinder)
 	if err != nil {
 		return nil, err
 	}
@@ -97,15 +184,33 @@ func NewComponentsHandler(
 			"URL", host.URL,
 			"nonces", fmt.Sprintf("%s - %s", host.NonceStart, host.NonceEnd),
 			"epochs", fmt.Sprintf("%s - %s", host.EpochStart, host.EpochEnd),
+			"num replicas", len(host.Replicas),
+			"load balancer", host.LoadBalancer,
 		)
 	}
 
-	ch.tester = api.NewGatewayTester()
 	err = ch.tester.TestGateways(loadedGateways)
 	if err != nil {
 		return nil, err
 	}
 
+	ch.gatewaysReloader, err = process.NewGatewaysReloader(process.ArgsGatewaysReloader{
+		ConfigLoader: func() (config.Config, error) {
+			newConfig := config.Config{}
+			errLoad := core.LoadTomlFile(&newConfig, configPath)
+
+			return newConfig, errLoad
+		},
+		ConfiguredGateways: cfg.Gateways,
+		HostFinder:         ch.hostFinder,
+		Tester:             ch.tester,
+		HealthProvider:     ch.healthChecker,
+		Discoverer:         ch.gatewaysDiscoverer,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	ch.countersCache, err = storage.NewCountersCache(time.Duration(cfg.CountersCacheTTLInSeconds) * time.Second)
 	if err != nil {
 		return nil, err
@@ -114,45 +219,147 @@ func NewComponentsHandler(
 	ch.sqliteWrapper, err = storage.NewSQLiteWrapper(
 		sqlitePath,
 		ch.countersCache,
+		keysHashKey,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	ch.keyCounter = common.NewKeyCounter()
+	ch.rateLimiter, err = process.NewRateLimiter(process.ArgsRateLimiter{
+		Config:      cfg.RateLimiter,
+		FreeAccount: cfg.FreeAccount,
+		Tiers:       cfg.Tiers,
+		KeyCounter:  common.NewKeyCounter(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch.slowLane, err = process.NewSlowLane(cfg.SlowLane)
+	if err != nil {
+		return nil, err
+	}
 
-	ch.accessChecker, err = process.NewAccessChecker(
-		ch.sqliteWrapper,
-		ch.keyCounter,
-		cfg.FreeAccount.MaxCalls,
-	)
+	ch.tiersPolicy, err = process.NewTiersPolicy(cfg.Tiers)
 	if err != nil {
 		return nil, err
 	}
 
-	ch.requestsProcessor, err = process.NewRequestsProcessor(
-		ch.hostFinder,
-		ch.accessChecker,
-		ch.sqliteWrapper,
-		cfg.ClosedEndpoints,
-	)
+	creditCostTable, err := process.NewCreditCostTable(cfg.CreditCosts)
+	if err != nil {
+		return nil, err
+	}
+
+	ch.violationsRecorder, err = process.NewKeyViolationsRecorder(maxKeyViolations)
+	if err != nil {
+		return nil, err
+	}
+
+	ch.clientIPResolver, err = process.NewClientIPResolver(cfg.ClientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	ch.accessChecker, err = process.NewAccessChecker(process.ArgsAccessChecker{
+		KeyAccessProvider:  ch.sqliteWrapper,
+		RateLimiter:        ch.rateLimiter,
+		SlowLane:           ch.slowLane,
+		TiersPolicy:        ch.tiersPolicy,
+		CreditCostTable:    creditCostTable,
+		ViolationsRecorder: ch.violationsRecorder,
+		ClientIPResolver:   ch.clientIPResolver,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pathValuesExtractor, err := process.NewPathValuesExtractor(cfg.PathRouting)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyValuesExtractor, err := process.NewBodyValuesExtractor(cfg.BodyRouting)
+	if err != nil {
+		return nil, err
+	}
+
+	hashEpochResolver, err := process.NewHashEpochResolver(process.ArgsHashEpochResolver{
+		Enabled:            cfg.HashRouting.Enabled,
+		MaxParallelLookups: cfg.HashRouting.MaxParallelLookups,
+		MissesCacheTTL:     time.Duration(cfg.HashRouting.MissesCacheTTLInSeconds) * time.Second,
+		MissesCacheSize:    cfg.HashRouting.MissesCacheMaxEntries,
+		HostFinder:         ch.hostFinder,
+		HealthProvider:     ch.healthChecker,
+		Storer:             ch.sqliteWrapper,
+		Requester:          process.NewHttpRequester(time.Duration(cfg.HashRouting.RequestTimeoutInSeconds) * time.Second),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch.timestampResolver, err = process.NewTimestampResolver(process.ArgsTimestampResolver{
+		Enabled:    cfg.TimestampRouting.Enabled,
+		ShardID:    cfg.TimestampRouting.ShardID,
+		HostFinder: ch.hostFinder,
+		Storer:     ch.sqliteWrapper,
+		Requester:  process.NewHttpRequester(time.Duration(cfg.TimestampRouting.RequestTimeoutInSeconds) * time.Second),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	retryPolicy, err := process.NewRetryPolicy(cfg.Retry)
+	if err != nil {
+		return nil, err
+	}
+
+	responseCache, err := storage.NewResponseCache(cfg.ResponseCache)
+	if err != nil {
+		return nil, err
+	}
+
+	requestsCoalescer, err := process.NewRequestsCoalescer(cfg.Coalescing)
+	if err != nil {
+		return nil, err
+	}
+
+	headersPolicy, err := process.NewHeadersPolicy(cfg.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	ch.requestsProcessor, err = process.NewRequestsProcessor(process.ArgsRequestsProcessor{
+		HostFinder:          ch.hostFinder,
+		AccessChecker:       ch.accessChecker,
+		PerformanceMonitor:  ch.sqliteWrapper,
+		PathValuesExtractor: pathValuesExtractor,
+		BodyValuesExtractor: bodyValuesExtractor,
+		HashEpochResolver:   hashEpochResolver,
+		TimestampResolver:   ch.timestampResolver,
+		RetryPolicy:         retryPolicy,
+		UpstreamClient:      process.NewUpstreamClient(cfg.Forwarding),
+		ResponseCache:       responseCache,
+		RequestsCoalescer:   requestsCoalescer,
+		HeadersPolicy:       headersPolicy,
+		ClosedEndpoints:     cfg.ClosedEndpoints,
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	ch.jwtAuthenticator = api.NewJWTAuthenticator(jwtKey)
 
-	ch.accessKeysHandler, err = api.NewAccessKeysHandler(ch.sqliteWrapper, ch.jwtAuthenticator)
+	ch.accessKeysHandler, err = api.NewAccessKeysHandler(ch.sqliteWrapper, ch.jwtAuthenticator, cfg.KeysRotation)
 	if err != nil {
 		return nil, err
 	}
 
-	ch.usersHandler, err = api.NewUsersHandler(ch.sqliteWrapper, ch.jwtAuthenticator)
+	ch.usersHandler, err = api.NewUsersHandler(ch.sqliteWrapper, ch.jwtAuthenticator, cfg.Tiers)
 	if err != nil {
 		return nil, err
 	}
 
-	ch.loginHandler, err = api.NewLoginHandler(ch.sqliteWrapper, ch.jwtAuthenticator)
+	ch.loginHandler, err = api.NewLoginHandler(ch.sqliteWrapper, ch.jwtAuthenticator, ch.clientIPResolver)
 	if err != nil {
 		return nil, err
 	}
@@ -162,11 +369,51 @@ func NewComponentsHandler(
 		return nil, err
 	}
 
+	ch.gatewaysHealthHandler, err = api.NewGatewaysHealthHandler(ch.healthChecker, ch.jwtAuthenticator)
+	if err != nil {
+		return nil, err
+	}
+
+	ch.keyViolationsHandler, err = api.NewKeyViolationsHandler(ch.violationsRecorder, ch.jwtAuthenticator)
+	if err != nil {
+		return nil, err
+	}
+
+	ch.reloadGatewaysHandler, err = api.NewReloadGatewaysHandler(ch.gatewaysReloader, ch.jwtAuthenticator)
+	if err != nil {
+		return nil, err
+	}
+
+	ch.gatewaysRangesHandler, err = api.NewGatewaysRangesHandler(ch.gatewaysDiscoverer, ch.jwtAuthenticator)
+	if err != nil {
+		return nil, err
+	}
+
+	ch.responseCacheHandler, err = api.NewResponseCacheHandler(responseCache, ch.jwtAuthenticator)
+	if err != nil {
+		return nil, err
+	}
+
+	ch.proxyMetricsHandler, err = api.NewProxyMetricsHandler(
+		map[string]api.MetricsProvider{
+			"hashIndex":     hashEpochResolver,
+			"responseCache": responseCache,
+			"coalescing":    requestsCoalescer,
+			"slowLane":      ch.slowLane,
+			"tiers":         ch.tiersPolicy,
+		},
+		ch.jwtAuthenticator,
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	ch.registrationHandler, err = api.NewRegistrationHandler(
 		ch.sqliteWrapper,
 		ch.emailSender,
 		cfg.AppDomains,
 		ch.captchaWrapper,
+		ch.clientIPResolver,
 		string(emailsConfig.RegistrationEmailBytes),
 	)
 	if err != nil {
@@ -212,14 +459,20 @@ func NewComponentsHandler(
 	}
 
 	handlers := map[string]http.Handler{
-		api.EndpointApiAccessKeys:         ch.accessKeysHandler,
-		api.EndpointApiAdminUsers:         ch.usersHandler,
-		api.EndpointApiLogin:              ch.loginHandler,
-		api.EndpointApiPerformance:        ch.performanceHandler,
-		api.EndpointApiRegister:           ch.registrationHandler,
-		api.EndpointApiActivate:           ch.registrationHandler,
-		api.EndpointApiChangePassword:     ch.userCredentialsHandler,
-		api.EndpointApiRequestEmailChange: ch.userCredentialsHandler,
+		api.EndpointApiAccessKeys:          ch.accessKeysHandler,
+		api.EndpointApiAdminUsers:          ch.usersHandler,
+		api.EndpointApiLogin:               ch.loginHandler,
+		api.EndpointApiPerformance:         ch.performanceHandler,
+		api.EndpointApiAdminGatewaysHealth: ch.gatewaysHealthHandler,
+		api.EndpointApiAdminReloadGateways: ch.reloadGatewaysHandler,
+		api.EndpointApiAdminProxyMetrics:   ch.proxyMetricsHandler,
+		api.EndpointApiAdminGatewaysRanges: ch.gatewaysRangesHandler,
+		api.EndpointApiAdminResponseCache:  ch.responseCacheHandler,
+		api.EndpointApiAdminKeyViolations:  ch.keyViolationsHandler,
+		api.EndpointApiRegister:            ch.registrationHandler,
+		api.EndpointApiActivate:            ch.registrationHandler,
+		api.EndpointApiChangePassword:      ch.userCredentialsHandler,
+		api.EndpointApiRequestEmailChange:  ch.userCredentialsHandler,
 
 		api.EndpointApiConfirmEmailChange:         ch.userCredentialsHandler,
 		api.EndpointApiCryptoPaymentConfig:        ch.cryptoPaymentHandler,
@@ -261,10 +514,19 @@ func (ch *componentsHandler) StartCronJobs(ctx context.Context) {
 		ch.countersCache.Sweep()
 	}, time.Duration(ch.config.CountersCacheTTLInSeconds)*time.Second)
 
+	common.CronJobStarter(ctx, func() {
+		log.Debug("Writing the access keys usage")
+		err := ch.sqliteWrapper.FlushKeysUsage()
+		if err != nil {
+			log.Warn("failed to write the access keys usage", "error", err)
+		}
+	}, time.Duration(ch.config.CountersCacheTTLInSeconds)*time.Second)
+
 	limitPeriod := time.Duration(ch.config.FreeAccount.ClearPeriodInSeconds) * time.Second
 	common.CronJobStarter(ctx, func() {
-		log.Debug("Clearing the keys counters")
-		ch.keyCounter.Clear()
+		log.Debug("Sweeping the rate limiter and the slow lane")
+		ch.rateLimiter.Sweep()
+		ch.slowLane.Sweep()
 	}, limitPeriod)
 
 	common.CronJobStarter(ctx, func() {
@@ -272,6 +534,36 @@ func (ch *componentsHandler) StartCronJobs(ctx context.Context) {
 		ch.requestsSynchronizer.Process()
 	}, time.Duration(ch.config.UpdateContractDBInSeconds)*time.Second)
 
+	if ch.config.HealthCheck.Enabled {
+		common.CronJobStarter(ctx, func() {
+			log.Debug("Checking the gateways health")
+			ch.healthChecker.CheckGateways(ch.hostFinder.LoadedGateways())
+		}, time.Duration(ch.config.HealthCheck.IntervalInSeconds)*time.Second)
+	}
+
+	if ch.config.GatewaysDiscovery.Enabled && ch.config.GatewaysDiscovery.IntervalInSeconds > 0 {
+		common.CronJobStarter(ctx, func() {
+			log.Debug("Rediscovering the gateways ranges")
+			_, err := ch.gatewaysReloader.Rediscover()
+			if err != nil {
+				log.Warn("gateways rediscovery failed, the current gateways are kept", "error", err)
+			}
+		}, time.Duration(ch.config.GatewaysDiscovery.IntervalInSeconds)*time.Second)
+	}
+
+	if ch.config.TimestampRouting.Enabled {
+		common.CronJobStarter(ctx, func() {
+			log.Debug("Learning the epoch start timestamps")
+			ch.timestampResolver.LearnEpochStarts()
+		}, time.Duration(ch.config.TimestampRouting.LearnIntervalInSeconds)*time.Second)
+	}
+}
+
+// ReloadGateways reloads the gateways from the configuration file. On error, the current gateways are kept
+func (ch *componentsHandler) ReloadGateways() error {
+	_, err := ch.gatewaysReloader.Reload()
+
+	return err
 }
 
 // GetSQLiteWrapper returns the SQLiteWrapper instance
@@ -284,19 +576,25 @@ func (ch *componentsHandler) GetAPIEngine() APIEngine {
 	return ch.apiEngine
 }
 
-// Close closes all the components held by the handler
+// Close closes all the components held by the handler. The API engine is closed first so the access keys usage
+// recorded in memory can be written before the storage is closed.
 func (ch *componentsHandler) Close() {
 	if ch == nil {
 		return
 	}
 
-	if !check.IfNil(ch.sqliteWrapper) {
-		err := ch.sqliteWrapper.Close()
+	if !check.IfNilReflect(ch.apiEngine) {
+		err := ch.apiEngine.Close()
 		log.LogIfError(err)
 	}
 
-	if !check.IfNilReflect(ch.apiEngine) {
-		err := ch.apiEngine.Close()
+	if !check.IfNil(ch.sqliteWrapper) {
+		err := ch.sqliteWrapper.FlushKeysUsage()
+		if err != nil {
+			log.Warn("failed to write the access keys usage on close", "error", err)
+		}
+
+		err = ch.sqliteWrapper.Close()
 		log.LogIfError(err)
 	}
 }
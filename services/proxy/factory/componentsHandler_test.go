@@ -2,12 +2,18 @@ package factory
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/storage"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
 	"github.com/multiversx/mx-chain-core-go/core/check"
 	"github.com/stretchr/testify/assert"
@@ -42,6 +48,7 @@ func TestNewComponentsHandler(t *testing.T) {
 	emailSenderStub := &testscommon.EmailSenderStub{}
 	captchaHandlerStub := &testscommon.CaptchaHandlerStub{}
 	jwtKey := "secret"
+	keysHashKey := "keys secret"
 	appVersion := "v1.0.0"
 	swaggerPath := "swagger"
 
@@ -50,7 +57,7 @@ func TestNewComponentsHandler(t *testing.T) {
 		cfg := createDefaultConfig()
 		cfg.FreeAccount.ClearPeriodInSeconds = 0
 
-		ch, err := NewComponentsHandler(cfg, dbPath, jwtKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
 		assert.Nil(t, ch)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "can not start as the config contains a 0 value for FreeAccount.ClearPeriodInSeconds")
@@ -61,7 +68,7 @@ func TestNewComponentsHandler(t *testing.T) {
 		cfg := createDefaultConfig()
 		cfg.AppDomains.Backend = ""
 
-		ch, err := NewComponentsHandler(cfg, dbPath, jwtKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
 		assert.Nil(t, ch)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "the AppDomains section is not correctly configured")
@@ -72,17 +79,350 @@ func TestNewComponentsHandler(t *testing.T) {
 		cfg := createDefaultConfig()
 		cfg.UpdateContractDBInSeconds = 0
 
-		ch, err := NewComponentsHandler(cfg, dbPath, jwtKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
 		assert.Nil(t, ch)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "can not start as the config contains a 0 value for UpdateContractDBInSeconds")
 	})
 
+	t.Run("invalid health check interval should error", func(t *testing.T) {
+		t.Parallel()
+		cfg := createDefaultConfig()
+		cfg.HealthCheck = config.HealthCheckConfig{
+			Enabled:           true,
+			IntervalInSeconds: 0,
+			TimeoutInSeconds:  5,
+		}
+
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "can not start as the config contains a 0 value for HealthCheck.IntervalInSeconds")
+	})
+
+	t.Run("invalid health check timeout should error", func(t *testing.T) {
+		t.Parallel()
+		cfg := createDefaultConfig()
+		cfg.HealthCheck = config.HealthCheckConfig{
+			Enabled:           true,
+			IntervalInSeconds: 5,
+			TimeoutInSeconds:  0,
+		}
+
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "can not start as the config contains a 0 value for HealthCheck.TimeoutInSeconds")
+	})
+
+	t.Run("invalid gateways discovery timeout should error", func(t *testing.T) {
+		t.Parallel()
+		cfg := createDefaultConfig()
+		cfg.GatewaysDiscovery = config.GatewaysDiscoveryConfig{
+			Enabled:                 true,
+			IntervalInSeconds:       60,
+			RequestTimeoutInSeconds: 0,
+		}
+
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "can not start as the config contains a 0 value for GatewaysDiscovery.RequestTimeoutInSeconds")
+	})
+
+	t.Run("invalid hash routing timeout should error", func(t *testing.T) {
+		t.Parallel()
+		cfg := createDefaultConfig()
+		cfg.HashRouting = config.HashRoutingConfig{
+			Enabled:                 true,
+			RequestTimeoutInSeconds: 0,
+		}
+
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "can not start as the config contains a 0 value for HashRouting.RequestTimeoutInSeconds")
+	})
+
+	t.Run("invalid timestamp routing learn interval should error", func(t *testing.T) {
+		t.Parallel()
+		cfg := createDefaultConfig()
+		cfg.TimestampRouting = config.TimestampRoutingConfig{
+			Enabled:                 true,
+			LearnIntervalInSeconds:  0,
+			RequestTimeoutInSeconds: 5,
+		}
+
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "can not start as the config contains a 0 value for TimestampRouting.LearnIntervalInSeconds")
+	})
+
+	t.Run("invalid timestamp routing timeout should error", func(t *testing.T) {
+		t.Parallel()
+		cfg := createDefaultConfig()
+		cfg.TimestampRouting = config.TimestampRoutingConfig{
+			Enabled:                 true,
+			LearnIntervalInSeconds:  60,
+			RequestTimeoutInSeconds: 0,
+		}
+
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "can not start as the config contains a 0 value for TimestampRouting.RequestTimeoutInSeconds")
+	})
+
+	t.Run("invalid path routing should error", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		cfg := createDefaultConfig()
+		cfg.Gateways = []config.GatewayConfig{
+			{
+				Name:       "test-gateway",
+				URL:        server.URL,
+				NonceStart: "0",
+				NonceEnd:   "latest",
+				EpochStart: "0",
+				EpochEnd:   "latest",
+			},
+		}
+		cfg.PathRouting.Patterns = []string{"/blocks/by-round/{round}"}
+
+		localDbPath := path.Join(t.TempDir(), "test_path_routing.db")
+		ch, err := NewComponentsHandler(cfg, "", localDbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "RoundsPerEpoch")
+	})
+
+	t.Run("invalid retryable status code should error", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		cfg := createDefaultConfig()
+		cfg.Gateways = []config.GatewayConfig{
+			{
+				Name:       "test-gateway",
+				URL:        server.URL,
+				NonceStart: "0",
+				NonceEnd:   "latest",
+				EpochStart: "0",
+				EpochEnd:   "latest",
+			},
+		}
+		cfg.Retry = config.RetryConfig{
+			MaxAttempts:          2,
+			RetryableStatusCodes: []int{1000},
+		}
+
+		localDbPath := path.Join(t.TempDir(), "test_retry.db")
+		ch, err := NewComponentsHandler(cfg, "", localDbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid retryable status code: 1000")
+	})
+
+	t.Run("enabled response cache with 0 memory size should error", func(t *testing.T) {
+		t.Parallel()
+		cfg := createDefaultConfig()
+		cfg.ResponseCache = config.ResponseCacheConfig{
+			Enabled:             true,
+			MaxEntrySizeInBytes: 1024,
+		}
+
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "ResponseCache.MaxMemorySizeInBytes")
+	})
+	t.Run("enabled response cache with a disk path and 0 disk size should error", func(t *testing.T) {
+		t.Parallel()
+		cfg := createDefaultConfig()
+		cfg.ResponseCache = config.ResponseCacheConfig{
+			Enabled:              true,
+			MaxMemorySizeInBytes: 1024,
+			MaxEntrySizeInBytes:  1024,
+			DiskPath:             t.TempDir(),
+		}
+
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "ResponseCache.MaxDiskSizeInBytes")
+	})
+
+	t.Run("enabled coalescing with 0 maximum response size should error", func(t *testing.T) {
+		t.Parallel()
+		cfg := createDefaultConfig()
+		cfg.Coalescing = config.CoalescingConfig{
+			Enabled: true,
+		}
+
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Coalescing.MaxResponseSizeInBytes")
+	})
+
+	t.Run("enabled slow lane with 0 maximum queue size should error", func(t *testing.T) {
+		t.Parallel()
+		cfg := createDefaultConfig()
+		cfg.SlowLane = config.SlowLaneConfig{
+			Enabled:               true,
+			RatePerSecond:         1,
+			MaxWaitInMilliseconds: 1000,
+		}
+
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "SlowLane.MaxQueueSizePerUser")
+	})
+
+	t.Run("enabled slow lane with 0 maximum wait should error", func(t *testing.T) {
+		t.Parallel()
+		cfg := createDefaultConfig()
+		cfg.SlowLane = config.SlowLaneConfig{
+			Enabled:             true,
+			RatePerSecond:       1,
+			MaxQueueSizePerUser: 10,
+		}
+
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "SlowLane.MaxWaitInMilliseconds")
+	})
+
+	t.Run("invalid header name should error", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		cfg := createDefaultConfig()
+		cfg.Gateways = []config.GatewayConfig{
+			{
+				Name:       "test-gateway",
+				URL:        server.URL,
+				NonceStart: "0",
+				NonceEnd:   "latest",
+				EpochStart: "0",
+				EpochEnd:   "latest",
+			},
+		}
+		cfg.Headers = config.HeadersConfig{
+			ResponseDenyList: []string{""},
+		}
+
+		localDbPath := path.Join(t.TempDir(), "test_headers.db")
+		ch, err := NewComponentsHandler(cfg, "", localDbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid header name in the response lists")
+	})
+
+	t.Run("unknown rate limiter should error", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		cfg := createDefaultConfig()
+		cfg.Gateways = []config.GatewayConfig{
+			{
+				Name:       "test-gateway",
+				URL:        server.URL,
+				NonceStart: "0",
+				NonceEnd:   "latest",
+				EpochStart: "0",
+				EpochEnd:   "latest",
+			},
+		}
+		cfg.RateLimiter.Type = "sliding-window"
+
+		localDbPath := path.Join(t.TempDir(), "test_rate_limiter.db")
+		ch, err := NewComponentsHandler(cfg, "", localDbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown rate limiter: sliding-window")
+	})
+
+	t.Run("duplicated tier should error", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		cfg := createDefaultConfig()
+		cfg.Gateways = []config.GatewayConfig{
+			{
+				Name:       "test-gateway",
+				URL:        server.URL,
+				NonceStart: "0",
+				NonceEnd:   "latest",
+				EpochStart: "0",
+				EpochEnd:   "latest",
+			},
+		}
+		cfg.Tiers = []config.TierConfig{{Name: "gold"}, {Name: "gold"}}
+
+		localDbPath := path.Join(t.TempDir(), "test_tiers.db")
+		ch, err := NewComponentsHandler(cfg, "", localDbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "duplicated tier: gold")
+	})
+	t.Run("invalid credit cost should error", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		cfg := createDefaultConfig()
+		cfg.Gateways = []config.GatewayConfig{
+			{
+				Name:       "test-gateway",
+				URL:        server.URL,
+				NonceStart: "0",
+				NonceEnd:   "latest",
+				EpochStart: "0",
+				EpochEnd:   "latest",
+			},
+		}
+		cfg.CreditCosts = []config.CreditCostConfig{{Pattern: "/address/{address}/keys"}}
+
+		localDbPath := path.Join(t.TempDir(), "test_credit_costs.db")
+		ch, err := NewComponentsHandler(cfg, "", localDbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		assert.Nil(t, ch)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid credit cost")
+	})
+
 	t.Run("nil email sender should error", func(t *testing.T) {
 		t.Parallel()
 		cfg := createDefaultConfig()
 
-		ch, err := NewComponentsHandler(cfg, dbPath, jwtKey, config.EmailsConfig{}, appVersion, swaggerPath, nil, captchaHandlerStub)
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, nil, captchaHandlerStub)
 		assert.Nil(t, ch)
 		assert.Equal(t, errNilEmailSender, err)
 	})
@@ -91,11 +431,65 @@ func TestNewComponentsHandler(t *testing.T) {
 		t.Parallel()
 		cfg := createDefaultConfig()
 
-		ch, err := NewComponentsHandler(cfg, dbPath, jwtKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, nil)
+		ch, err := NewComponentsHandler(cfg, "", dbPath, jwtKey, keysHashKey, config.EmailsConfig{}, appVersion, swaggerPath, emailSenderStub, nil)
 		assert.Nil(t, ch)
 		assert.Equal(t, errNilCaptchaWrapper, err)
 	})
 
+	t.Run("should discover the gateways ranges", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/network/config":
+				_, _ = w.Write([]byte("{}"))
+			case r.URL.Path == "/network/status/4294967295":
+				_, _ = w.Write([]byte(`{"data":{"status":{"erd_epoch_number":5,"erd_nonce":5500}}}`))
+			case strings.HasPrefix(r.URL.Path, "/network/epoch-start/4294967295/by-epoch/"):
+				epoch, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/network/epoch-start/4294967295/by-epoch/"))
+				_, _ = fmt.Fprintf(w, `{"data":{"epochStart":{"nonce":%d}}}`, epoch*1000)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		cfg := createDefaultConfig()
+		cfg.GatewaysDiscovery = config.GatewaysDiscoveryConfig{
+			Enabled:                 true,
+			ShardID:                 4294967295,
+			RequestTimeoutInSeconds: 5,
+		}
+		cfg.Gateways = []config.GatewayConfig{
+			{
+				Name: "discovered",
+				URL:  server.URL,
+			},
+		}
+
+		localDbPath := path.Join(t.TempDir(), "test_discovery.db")
+		emailsConfig := config.EmailsConfig{
+			RegistrationEmailBytes: []byte("<html>register</html>"),
+			ChangeEmailBytes:       []byte("<html>change</html>"),
+		}
+
+		ch, err := NewComponentsHandler(cfg, "", localDbPath, jwtKey, keysHashKey, emailsConfig, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		require.NoError(t, err)
+		defer ch.Close()
+
+		expectedGateways := []config.GatewayConfig{
+			{
+				Name:       "discovered",
+				URL:        server.URL,
+				EpochStart: "0",
+				EpochEnd:   "latest",
+				NonceStart: "0",
+				NonceEnd:   "latest",
+			},
+		}
+		assert.Equal(t, expectedGateways, ch.hostFinder.LoadedGateways())
+		assert.Equal(t, expectedGateways, ch.gatewaysDiscoverer.GetDiscoveryReport().Gateways)
+	})
 	t.Run("should create successfully", func(t *testing.T) {
 		t.Parallel()
 
@@ -129,7 +523,8 @@ func TestNewComponentsHandler(t *testing.T) {
 			ChangeEmailBytes:       []byte("<html>change</html>"),
 		}
 
-		ch, err := NewComponentsHandler(cfg, localDbPath, jwtKey, emailsConfig, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
+		configPath := path.Join(t.TempDir(), "config.toml")
+		ch, err := NewComponentsHandler(cfg, configPath, localDbPath, jwtKey, keysHashKey, emailsConfig, appVersion, swaggerPath, emailSenderStub, captchaHandlerStub)
 		require.NoError(t, err)
 		assert.NotNil(t, ch)
 
@@ -138,6 +533,22 @@ func TestNewComponentsHandler(t *testing.T) {
 		assert.False(t, check.IfNil(ch.GetSQLiteWrapper()))
 		assert.NotNil(t, ch.GetAPIEngine())
 
+		// Test ReloadGateways
+		err = ch.ReloadGateways()
+		assert.NotNil(t, err) // missing config file
+
+		invalidConfig := fmt.Sprintf(`Gateways = [{URL="%s", EpochStart="10", EpochEnd="latest", NonceStart="0", NonceEnd="latest", Name="reloaded"}]`, server.URL)
+		require.Nil(t, os.WriteFile(configPath, []byte(invalidConfig), os.ModePerm))
+		err = ch.ReloadGateways()
+		assert.NotNil(t, err)
+		assert.Equal(t, "test-gateway", ch.hostFinder.LoadedGateways()[0].Name)
+
+		validConfig := fmt.Sprintf(`Gateways = [{URL="%s", EpochStart="0", EpochEnd="latest", NonceStart="0", NonceEnd="latest", Name="reloaded"}]`, server.URL)
+		require.Nil(t, os.WriteFile(configPath, []byte(validConfig), os.ModePerm))
+		err = ch.ReloadGateways()
+		assert.Nil(t, err)
+		assert.Equal(t, "reloaded", ch.hostFinder.LoadedGateways()[0].Name)
+
 		// Test StartCronJobs (no panic)
 		assert.NotPanics(t, func() {
 			ch.StartCronJobs(context.Background())
@@ -149,3 +560,57 @@ func TestNewComponentsHandler(t *testing.T) {
 		})
 	})
 }
+
+func TestComponentsHandler_Close(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig()
+	cfg.Gateways = []config.GatewayConfig{
+		{
+			Name:       "test-gateway",
+			URL:        server.URL,
+			NonceStart: "0",
+			NonceEnd:   "latest",
+			EpochStart: "0",
+			EpochEnd:   "latest",
+		},
+	}
+
+	dbPath := path.Join(t.TempDir(), "test_close.db")
+	keysHashKey := "keys secret"
+	emailsConfig := config.EmailsConfig{
+		RegistrationEmailBytes: []byte("<html>register</html>"),
+		ChangeEmailBytes:       []byte("<html>change</html>"),
+	}
+	ch, err := NewComponentsHandler(cfg, "", dbPath, "secret", keysHashKey, emailsConfig, "v1.0.0", "swagger", &testscommon.EmailSenderStub{}, &testscommon.CaptchaHandlerStub{})
+	require.NoError(t, err)
+
+	const key = "e05d2cdbce887650f5f26f770e55570b"
+	require.Nil(t, ch.sqliteWrapper.AddUser("user", "pass", false, 100, false, true, ""))
+	require.Nil(t, ch.sqliteWrapper.AddKey("user", key))
+	access, err := ch.sqliteWrapper.GetKeyAccess(key)
+	require.Nil(t, err)
+	ch.sqliteWrapper.ConsumeKeyCredits(access, 1, "10.0.0.1")
+
+	ch.Close()
+
+	// the key's last use, only kept in memory until flushed, should have been written on close
+	counters, _ := storage.NewCountersCache(time.Minute)
+	wrapper, err := storage.NewSQLiteWrapper(dbPath, counters, keysHashKey)
+	require.Nil(t, err)
+	defer func() {
+		_ = wrapper.Close()
+	}()
+
+	keys, err := wrapper.GetAllKeys("user")
+	require.Nil(t, err)
+	details := keys[access.KeyID]
+	assert.Equal(t, "10.0.0.1", details.LastIP)
+	assert.NotZero(t, details.LastUsedAt)
+}
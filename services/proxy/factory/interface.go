@@ -10,16 +10,57 @@ import (
 // GatewayTester defines the operations for a component able to test (probe) gateways
 type GatewayTester interface {
 	TestGateways(gateways []config.GatewayConfig) error
+	ProbeGateway(upstream config.GatewayConfig) error
+	IsInterfaceNil() bool
+}
+
+// GatewaysReloader defines the operations for a component able to reload the gateways configuration
+type GatewaysReloader interface {
+	Reload() ([]config.GatewayConfig, error)
+	Rediscover() ([]config.GatewayConfig, error)
+	IsInterfaceNil() bool
+}
+
+// GatewaysDiscoverer defines the operations for a component able to discover the gateways' epoch and nonce ranges
+type GatewaysDiscoverer interface {
+	DiscoverRanges(gateways []config.GatewayConfig) ([]config.GatewayConfig, error)
+	GetDiscoveryReport() common.GatewaysDiscoveryReport
+	IsInterfaceNil() bool
+}
+
+// GatewaysHealthChecker defines the operations for a component able to continuously check the gateways' health
+type GatewaysHealthChecker interface {
+	CheckGateways(gateways []config.GatewayConfig)
+	IsHealthy(url string) bool
+	GetHealthStatus() []common.GatewayHealthStatus
+	IsInterfaceNil() bool
+}
+
+// TimestampResolver defines the operations for a component able to convert timestamps into block nonces
+type TimestampResolver interface {
+	LearnEpochStarts()
+	ResolveNonce(timestamp string) (uint64, bool, error)
+	IsInterfaceNil() bool
 }
 
 // SQLiteWrapper defines the operations for a component able to wrap SQLite database
 type SQLiteWrapper interface {
 	AddUser(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, isActive bool, activationToken string) error
 	RemoveUser(username string) error
-	UpdateUser(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool) error
+	UpdateUser(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, tier *string) error
+	SetUserTier(username string, tier string) error
+	SetKeyScope(key string, scope common.KeyScope) error
+	SetKeyExpiry(key string, expiresAt int64) error
+	UpdateKey(key string, update common.KeyUpdate) error
+	SetKeyLabel(username string, key string, label string) error
+	SetKeyRestrictions(username string, key string, restrictions common.KeyRestrictions) error
+	RotateKey(username string, key string, newKey string, graceExpiresAt int64, settings common.KeySettings) error
 	AddKey(username string, key string) error
+	AddKeyWithSettings(username string, key string, settings common.KeySettings) error
 	RemoveKey(username string, key string) error
-	IsKeyAllowed(key string) (string, common.AccountType, error)
+	GetKeyAccess(key string) (common.KeyAccess, error)
+	ConsumeKeyCredits(access common.KeyAccess, cost uint64, clientIP string)
+	FlushKeysUsage() error
 	CheckUserCredentials(username string, password string) (*common.UsersDetails, error)
 	GetUser(username string) (*common.UsersDetails, error)
 	GetAllKeys(username string) (map[string]common.AccessKeyDetails, error)
@@ -33,6 +74,10 @@ type SQLiteWrapper interface {
 	SetCryptoPaymentID(username string, paymentID uint64) error
 	UpdateMaxRequests(username string, maxRequests uint64) error
 	UpdateUserMaxRequestsFromContract(username string, contractMaxRequests uint64) error
+	GetHashEpoch(hash string) (uint64, bool, error)
+	SaveHashEpoch(hash string, epoch uint64) error
+	GetEpochStarts(shard uint32) ([]common.EpochStartInfo, error)
+	SaveEpochStart(info common.EpochStartInfo) error
 	Close() error
 	IsInterfaceNil() bool
 }
@@ -0,0 +1,148 @@
+package integrationTests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/api"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/process"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRejectedRequestsDoNotConsumeCredits(t *testing.T) {
+	tmpfile, err := os.CreateTemp(t.TempDir(), "sqlite.db")
+	require.NoError(t, err)
+	dbPath := tmpfile.Name()
+	_ = tmpfile.Close()
+
+	counters, _ := storage.NewCountersCache(time.Minute)
+	storer, _ := storage.NewSQLiteWrapper(dbPath, counters, keysHashKey)
+	defer func() {
+		_ = storer.Close()
+	}()
+
+	const key = "e05d2cdbce887650f5f26f770e55570b"
+	_ = storer.AddUser("test", "test", false, 100, false, true, "")
+	err = storer.AddKey("test", key)
+	require.Nil(t, err)
+	err = storer.SetKeyRestrictions("test", key, common.KeyRestrictions{AllowedCIDRs: []string{"10.0.0.0/8"}})
+	require.Nil(t, err)
+	err = storer.SetKeyScope(key, common.KeyScope{Routes: []string{"/address"}})
+	require.Nil(t, err)
+
+	rateLimiter, err := process.NewFixedWindowLimiter(common.NewKeyCounter(), 100, time.Minute)
+	require.Nil(t, err)
+
+	slowLane, err := process.NewSlowLane(config.SlowLaneConfig{})
+	require.Nil(t, err)
+
+	tiersPolicy, err := process.NewTiersPolicy(nil)
+	require.Nil(t, err)
+
+	creditCostTable, err := process.NewCreditCostTable(nil)
+	require.Nil(t, err)
+
+	violationsRecorder, err := process.NewKeyViolationsRecorder(10)
+	require.Nil(t, err)
+
+	clientIPResolver, err := process.NewClientIPResolver(config.ClientIPConfig{})
+	require.Nil(t, err)
+
+	accessChecker, err := process.NewAccessChecker(process.ArgsAccessChecker{
+		KeyAccessProvider:  storer,
+		RateLimiter:        rateLimiter,
+		SlowLane:           slowLane,
+		TiersPolicy:        tiersPolicy,
+		CreditCostTable:    creditCostTable,
+		ViolationsRecorder: violationsRecorder,
+		ClientIPResolver:   clientIPResolver,
+	})
+	require.Nil(t, err)
+
+	sendRequest := func(requestPath string, remoteAddr string) error {
+		request := httptest.NewRequest(http.MethodGet, "/v1/"+key+requestPath, nil)
+		request.RemoteAddr = remoteAddr
+
+		result, errCheck := accessChecker.ShouldProcessRequest(request)
+		if errCheck == nil {
+			accessChecker.ConsumeCredits(result)
+			accessChecker.ReleaseRequest(result)
+		}
+
+		return errCheck
+	}
+
+	// the client IP is not allowed by the key's restrictions
+	err = sendRequest("/address/erd1", "198.51.100.7:4321")
+	assert.NotNil(t, err)
+	// the route is not allowed by the key's scope
+	err = sendRequest("/network/config", "10.0.0.1:4321")
+	assert.NotNil(t, err)
+	assert.Len(t, violationsRecorder.GetViolations(), 1)
+
+	time.Sleep(time.Second)
+	require.Nil(t, storer.FlushKeysUsage())
+
+	keys, err := storer.GetAllKeys("test")
+	require.Nil(t, err)
+	details := keys[computeKeyID(key)]
+	assert.Zero(t, details.GlobalCounter)
+	assert.Zero(t, details.KeyCounter)
+	assert.Zero(t, details.LastUsedAt)
+	assert.Empty(t, details.LastIP)
+
+	err = sendRequest("/address/erd1", "10.0.0.1:4321")
+	assert.Nil(t, err)
+
+	time.Sleep(time.Second)
+	require.Nil(t, storer.FlushKeysUsage())
+
+	keys, err = storer.GetAllKeys("test")
+	require.Nil(t, err)
+	details = keys[computeKeyID(key)]
+	assert.Equal(t, uint64(1), details.GlobalCounter)
+	assert.Equal(t, uint64(1), details.KeyCounter)
+	assert.Equal(t, "10.0.0.1", details.LastIP)
+}
+
+func TestUpdatingTheKeyExpiryKeepsTheScope(t *testing.T) {
+	storer := setupStorer(t)
+	defer func() {
+		_ = storer.Close()
+	}()
+
+	const key = "e05d2cdbce887650f5f26f770e55570c"
+	_ = storer.AddUser("test", "test", false, 100, false, true, "")
+	scope := common.KeyScope{Routes: []string{"/address"}}
+	err := storer.AddKeyWithSettings("test", key, common.KeySettings{Scope: scope})
+	require.Nil(t, err)
+
+	auth := api.NewJWTAuthenticator("test_jwt_key")
+	accessKeysHandler, err := api.NewAccessKeysHandler(storer, auth, config.KeysRotationConfig{})
+	require.Nil(t, err)
+
+	token, err := auth.GenerateToken(adminUser, true)
+	require.Nil(t, err)
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	body := fmt.Sprintf(`{"key": "%s", "expires_at": %d}`, key, expiresAt)
+	request := httptest.NewRequest(http.MethodPut, endpointKeys, strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	accessKeysHandler.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	keys, err := storer.GetAllKeys("test")
+	require.Nil(t, err)
+	details := keys[computeKeyID(key)]
+	assert.Equal(t, scope, details.Scope)
+	assert.Equal(t, expiresAt, details.ExpiresAt)
+}
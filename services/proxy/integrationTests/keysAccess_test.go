@@ -2,6 +2,9 @@ package integrationTests
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -11,6 +14,7 @@ import (
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/api"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/process"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/storage"
 	"github.com/stretchr/testify/assert"
@@ -19,6 +23,7 @@ import (
 
 const adminUser = "admin"
 const adminPass = "adminPass"
+const keysHashKey = "keys hash key"
 
 const endpointKeys = "/api/admin-access-keys"
 const endpointUsers = "/api/admin-users"
@@ -29,13 +34,16 @@ func TestKeysAccess(t *testing.T) {
 	ensureAdmin(t, storer)
 	auth := api.NewJWTAuthenticator("test_jwt_key")
 
-	accessKeysHandler, err := api.NewAccessKeysHandler(storer, auth)
+	accessKeysHandler, err := api.NewAccessKeysHandler(storer, auth, config.KeysRotationConfig{})
 	require.Nil(t, err)
 
-	usersHandler, err := api.NewUsersHandler(storer, auth)
+	usersHandler, err := api.NewUsersHandler(storer, auth, nil)
 	require.Nil(t, err)
 
-	loginHandler, err := api.NewLoginHandler(storer, auth)
+	clientIPResolver, err := process.NewClientIPResolver(config.ClientIPConfig{})
+	require.Nil(t, err)
+
+	loginHandler, err := api.NewLoginHandler(storer, auth, clientIPResolver)
 	require.Nil(t, err)
 
 	handlers := map[string]http.Handler{
@@ -131,7 +139,7 @@ func setupStorer(tb testing.TB) api.KeyAccessProvider {
 	_ = tmpfile.Close()
 
 	counters, _ := storage.NewCountersCache(time.Minute)
-	storer, _ := storage.NewSQLiteWrapper(dbPath, counters)
+	storer, _ := storage.NewSQLiteWrapper(dbPath, counters, keysHashKey)
 
 	return storer
 }
@@ -292,18 +300,22 @@ func testGetKeys(tb testing.TB, engineAddress string, username string, password
 		return
 	}
 
-	var keysMap map[string]common.AccessKeyDetails
-	err := json.NewDecoder(resp.Body).Decode(&keysMap)
+	var keysList []common.AccessKeyDetails
+	err := json.NewDecoder(resp.Body).Decode(&keysList)
 	assert.Nil(tb, err)
 
-	assert.Equal(tb, len(keys), len(keysMap))
+	keysMap := make(map[string]common.AccessKeyDetails, len(keysList))
+	for _, details := range keysList {
+		keysMap[details.ID] = details
+	}
+
+	assert.Equal(tb, len(keys), len(keysList))
 	for _, key := range keys {
-		_, ok := keysMap[key]
-		if !ok {
-			// try lower case
-			_, ok = keysMap[key]
-		}
+		// only the keys' hashes are stored, so the keys are listed by their IDs
+		details, ok := keysMap[computeKeyID(key)]
 		assert.True(tb, ok, "key %s not found", key)
+		assert.Equal(tb, common.KeyPrefix(key), details.KeyPrefix)
+		assert.NotContains(tb, keysMap, key)
 	}
 
 	_ = resp.Body.Close()
@@ -340,3 +352,10 @@ func removeKey(tb testing.TB, address string, user, pass, key string, httpCode i
 	assert.Nil(tb, err)
 	assert.Equal(tb, httpCode, resp.StatusCode)
 }
+
+func computeKeyID(key string) string {
+	hasher := hmac.New(sha256.New, []byte(keysHashKey))
+	_, _ = hasher.Write([]byte(key))
+
+	return hex.EncodeToString(hasher.Sum(nil))
+}
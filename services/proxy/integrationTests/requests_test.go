@@ -67,7 +67,7 @@ func TestRequestsArePassedCorrectly(t *testing.T) {
 		},
 	}
 
-	hostsFinder, err := process.NewHostsFinder(gateways)
+	hostsFinder, err := process.NewHostsFinder(gateways, &testscommon.GatewaysHealthProviderStub{})
 	require.Nil(t, err)
 
 	tmpfile, err := os.CreateTemp(t.TempDir(), "sqlite.db")
@@ -76,21 +76,90 @@ func TestRequestsArePassedCorrectly(t *testing.T) {
 	_ = tmpfile.Close()
 
 	counters, _ := storage.NewCountersCache(time.Minute)
-	storer, _ := storage.NewSQLiteWrapper(dbPath, counters)
+	storer, _ := storage.NewSQLiteWrapper(dbPath, counters, keysHashKey)
 	_ = storer.AddUser("test", "test", true, 0, true, true, "")
 	err = storer.AddKey("test", "e05d2cdbce887650f5f26f770e55570b")
 	require.Nil(t, err)
 
-	accessChecker, err := process.NewAccessChecker(storer, common.NewKeyCounter(), 100)
+	rateLimiter, err := process.NewFixedWindowLimiter(common.NewKeyCounter(), 100, time.Minute)
+	require.Nil(t, err)
+
+	slowLane, err := process.NewSlowLane(config.SlowLaneConfig{})
+	require.Nil(t, err)
+
+	tiersPolicy, err := process.NewTiersPolicy(nil)
+	require.Nil(t, err)
+
+	creditCostTable, err := process.NewCreditCostTable(nil)
+	require.Nil(t, err)
+
+	violationsRecorder, err := process.NewKeyViolationsRecorder(10)
+	require.Nil(t, err)
+
+	clientIPResolver, err := process.NewClientIPResolver(config.ClientIPConfig{})
+	require.Nil(t, err)
+
+	accessChecker, err := process.NewAccessChecker(process.ArgsAccessChecker{
+		KeyAccessProvider:  storer,
+		RateLimiter:        rateLimiter,
+		SlowLane:           slowLane,
+		TiersPolicy:        tiersPolicy,
+		CreditCostTable:    creditCostTable,
+		ViolationsRecorder: violationsRecorder,
+		ClientIPResolver:   clientIPResolver,
+	})
+	assert.Nil(t, err)
+
+	pathValuesExtractor, err := process.NewPathValuesExtractor(config.PathRoutingConfig{})
+	assert.Nil(t, err)
+
+	bodyValuesExtractor, err := process.NewBodyValuesExtractor(config.BodyRoutingConfig{})
 	assert.Nil(t, err)
 
-	processor, err := process.NewRequestsProcessor(
-		hostsFinder,
-		accessChecker,
-		storer,
-		[]string{
+	hashEpochResolver, err := process.NewHashEpochResolver(process.ArgsHashEpochResolver{
+		HostFinder:     hostsFinder,
+		HealthProvider: &testscommon.GatewaysHealthProviderStub{},
+		Storer:         storer,
+		Requester:      process.NewHttpRequester(time.Second),
+	})
+	assert.Nil(t, err)
+
+	timestampResolver, err := process.NewTimestampResolver(process.ArgsTimestampResolver{
+		HostFinder: hostsFinder,
+		Storer:     storer,
+		Requester:  process.NewHttpRequester(time.Second),
+	})
+	assert.Nil(t, err)
+
+	retryPolicy, err := process.NewRetryPolicy(config.RetryConfig{})
+	assert.Nil(t, err)
+
+	responseCache, err := storage.NewResponseCache(config.ResponseCacheConfig{})
+	assert.Nil(t, err)
+
+	requestsCoalescer, err := process.NewRequestsCoalescer(config.CoalescingConfig{})
+	assert.Nil(t, err)
+
+	headersPolicy, err := process.NewHeadersPolicy(config.HeadersConfig{})
+	assert.Nil(t, err)
+
+	processor, err := process.NewRequestsProcessor(process.ArgsRequestsProcessor{
+		HostFinder:          hostsFinder,
+		AccessChecker:       accessChecker,
+		PerformanceMonitor:  storer,
+		PathValuesExtractor: pathValuesExtractor,
+		BodyValuesExtractor: bodyValuesExtractor,
+		HashEpochResolver:   hashEpochResolver,
+		TimestampResolver:   timestampResolver,
+		RetryPolicy:         retryPolicy,
+		UpstreamClient:      process.NewUpstreamClient(config.ForwardingConfig{}),
+		ResponseCache:       responseCache,
+		RequestsCoalescer:   requestsCoalescer,
+		HeadersPolicy:       headersPolicy,
+		ClosedEndpoints: []string{
 			"/transaction/send",
-		})
+		},
+	})
 	require.Nil(t, err)
 
 	handlers := map[string]http.Handler{
@@ -145,5 +214,6 @@ func TestRequestsArePassedCorrectly(t *testing.T) {
 	keys, err := storer.GetAllKeys("test")
 	assert.Nil(t, err)
 
-	assert.Equal(t, uint64(6), keys["e05d2cdbce887650f5f26f770e55570b"].GlobalCounter)
+	// the request sent to the closed endpoint did not consume credits
+	assert.Equal(t, uint64(5), keys[computeKeyID("e05d2cdbce887650f5f26f770e55570b")].GlobalCounter)
 }
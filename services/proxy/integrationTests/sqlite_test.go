@@ -28,7 +28,8 @@ func BenchmarkSqlite(b *testing.B) {
 		key := getKey(i)
 		b.StartTimer()
 
-		_, _, _ = wrapper.IsKeyAllowed(key)
+		access, _ := wrapper.GetKeyAccess(key)
+		wrapper.ConsumeKeyCredits(access, 1, "")
 	}
 
 	b.StopTimer()
@@ -42,7 +43,7 @@ func setupBenchmark(tb testing.TB) api.KeyAccessProvider {
 	keys = make([]string, 0, 2000)
 
 	counters, _ := storage.NewCountersCache(time.Minute)
-	keyAccessProvider, err := storage.NewSQLiteWrapper(dbPath, counters)
+	keyAccessProvider, err := storage.NewSQLiteWrapper(dbPath, counters, keysHashKey)
 	require.NoError(tb, err)
 
 	for i := 0; i < 100; i++ {
@@ -12,6 +12,7 @@ import (
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/process"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/storage"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -50,7 +51,7 @@ func TestThrottlingFreeAccounts(t *testing.T) {
 		},
 	}
 
-	hostsFinder, err := process.NewHostsFinder(gateways)
+	hostsFinder, err := process.NewHostsFinder(gateways, &testscommon.GatewaysHealthProviderStub{})
 	require.Nil(t, err)
 
 	tmpfile, err := os.CreateTemp(t.TempDir(), "sqlite.db")
@@ -59,22 +60,90 @@ func TestThrottlingFreeAccounts(t *testing.T) {
 	_ = tmpfile.Close()
 
 	counters, _ := storage.NewCountersCache(time.Minute)
-	storer, _ := storage.NewSQLiteWrapper(dbPath, counters)
+	storer, _ := storage.NewSQLiteWrapper(dbPath, counters, keysHashKey)
 	_ = storer.AddUser("test", "test", true, 0, false, true, "")
 	err = storer.AddKey("test", "e05d2cdbce887650f5f26f770e55570b")
 	require.Nil(t, err)
 
-	keyCounter := common.NewKeyCounter()
-	accessChecker, err := process.NewAccessChecker(storer, keyCounter, 3)
+	rateLimiter, err := process.NewFixedWindowLimiter(common.NewKeyCounter(), 3, time.Minute)
+	require.Nil(t, err)
+
+	slowLane, err := process.NewSlowLane(config.SlowLaneConfig{})
+	require.Nil(t, err)
+
+	tiersPolicy, err := process.NewTiersPolicy(nil)
+	require.Nil(t, err)
+
+	creditCostTable, err := process.NewCreditCostTable(nil)
+	require.Nil(t, err)
+
+	violationsRecorder, err := process.NewKeyViolationsRecorder(10)
+	require.Nil(t, err)
+
+	clientIPResolver, err := process.NewClientIPResolver(config.ClientIPConfig{})
+	require.Nil(t, err)
+
+	accessChecker, err := process.NewAccessChecker(process.ArgsAccessChecker{
+		KeyAccessProvider:  storer,
+		RateLimiter:        rateLimiter,
+		SlowLane:           slowLane,
+		TiersPolicy:        tiersPolicy,
+		CreditCostTable:    creditCostTable,
+		ViolationsRecorder: violationsRecorder,
+		ClientIPResolver:   clientIPResolver,
+	})
 	assert.Nil(t, err)
 
-	processor, err := process.NewRequestsProcessor(
-		hostsFinder,
-		accessChecker,
-		storer,
-		[]string{
+	pathValuesExtractor, err := process.NewPathValuesExtractor(config.PathRoutingConfig{})
+	assert.Nil(t, err)
+
+	bodyValuesExtractor, err := process.NewBodyValuesExtractor(config.BodyRoutingConfig{})
+	assert.Nil(t, err)
+
+	hashEpochResolver, err := process.NewHashEpochResolver(process.ArgsHashEpochResolver{
+		HostFinder:     hostsFinder,
+		HealthProvider: &testscommon.GatewaysHealthProviderStub{},
+		Storer:         storer,
+		Requester:      process.NewHttpRequester(time.Second),
+	})
+	assert.Nil(t, err)
+
+	timestampResolver, err := process.NewTimestampResolver(process.ArgsTimestampResolver{
+		HostFinder: hostsFinder,
+		Storer:     storer,
+		Requester:  process.NewHttpRequester(time.Second),
+	})
+	assert.Nil(t, err)
+
+	retryPolicy, err := process.NewRetryPolicy(config.RetryConfig{})
+	assert.Nil(t, err)
+
+	responseCache, err := storage.NewResponseCache(config.ResponseCacheConfig{})
+	assert.Nil(t, err)
+
+	requestsCoalescer, err := process.NewRequestsCoalescer(config.CoalescingConfig{})
+	assert.Nil(t, err)
+
+	headersPolicy, err := process.NewHeadersPolicy(config.HeadersConfig{})
+	assert.Nil(t, err)
+
+	processor, err := process.NewRequestsProcessor(process.ArgsRequestsProcessor{
+		HostFinder:          hostsFinder,
+		AccessChecker:       accessChecker,
+		PerformanceMonitor:  storer,
+		PathValuesExtractor: pathValuesExtractor,
+		BodyValuesExtractor: bodyValuesExtractor,
+		HashEpochResolver:   hashEpochResolver,
+		TimestampResolver:   timestampResolver,
+		RetryPolicy:         retryPolicy,
+		UpstreamClient:      process.NewUpstreamClient(config.ForwardingConfig{}),
+		ResponseCache:       responseCache,
+		RequestsCoalescer:   requestsCoalescer,
+		HeadersPolicy:       headersPolicy,
+		ClosedEndpoints: []string{
 			"/transaction/send",
-		})
+		},
+	})
 	require.Nil(t, err)
 
 	handlers := map[string]http.Handler{
@@ -103,19 +172,24 @@ func TestThrottlingFreeAccounts(t *testing.T) {
 	url = fmt.Sprintf("http://%s/v1/e05d2cdbce887650f5f26f770e55570b/transaction/8a64d0ad29f70595bf942c8d2e241a21a3988d9712ae268a9e33efbaffc16b3b?withResults=true", engine.Address())
 	resp, _ = http.DefaultClient.Get(url)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0", resp.Header.Get("X-RateLimit-Remaining"))
 
 	// this call errors as the account is throttled
 	url = fmt.Sprintf("http://%s/v1/e05d2cdbce887650f5f26f770e55570b/transaction/8a64d0ad29f70595bf942c8d2e241a21a3988d9712ae268a9e33efbaffc16b3b?withResults=true&blockNonce=10000", engine.Address())
 	resp, _ = http.DefaultClient.Get(url)
-	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "3", resp.Header.Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", resp.Header.Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, resp.Header.Get("X-RateLimit-Reset"))
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
 
 	// this call also errors as the account is throttled
 	url = fmt.Sprintf("http://%s/v1/e05d2cdbce887650f5f26f770e55570b/address/erd1qqqqqqqqqqqqqqqpqqqqqqqqqqqqqqqqqqqqqqqqqqqqpf0llllsccsy0c", engine.Address())
 	resp, _ = http.DefaultClient.Get(url)
-	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
 
 	//resetting the throttling
-	keyCounter.Clear()
+	rateLimiter.Sweep()
 
 	// this call will be ignored
 	url = fmt.Sprintf("http://%s/v1/e05d2cdbce887650f5f26f770e55570b/transaction/send", engine.Address())
@@ -149,5 +223,6 @@ func TestThrottlingFreeAccounts(t *testing.T) {
 	keys, err := storer.GetAllKeys("test")
 	assert.Nil(t, err)
 
-	assert.Equal(t, uint64(8), keys["e05d2cdbce887650f5f26f770e55570b"].GlobalCounter)
+	// the 2 throttled requests and the one sent to the closed endpoint did not consume credits
+	assert.Equal(t, uint64(5), keys[computeKeyID("e05d2cdbce887650f5f26f770e55570b")].GlobalCounter)
 }
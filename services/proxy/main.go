@@ -37,6 +37,7 @@ const (
 	emailChangeTemplateFile    = "./change_email.html"
 	swaggerPath                = "./swagger/"
 	envFileVarJwtKey           = "JWT_KEY"
+	envFileVarKeysHashKey      = "API_KEYS_HASH_KEY"
 	envFileVarInitialAdminUser = "INITIAL_ADMIN_USER"
 	envFileVarInitialAdminPass = "INITIAL_ADMIN_PASSWORD"
 	envFileVarInitialAdminKey  = "INITIAL_ADMIN_KEY"
@@ -94,7 +95,7 @@ VERSION:
 		Value: "",
 	}
 
-	envFileVars     = []string{envFileVarJwtKey, envFileVarInitialAdminUser, envFileVarInitialAdminPass, envFileVarInitialAdminKey, envFileVarSmtpHost, envFileVarSmtpPort, envFileVarSmtpFrom, envFileVarSmtpPassword}
+	envFileVars     = []string{envFileVarJwtKey, envFileVarKeysHashKey, envFileVarInitialAdminUser, envFileVarInitialAdminPass, envFileVarInitialAdminKey, envFileVarSmtpHost, envFileVarSmtpPort, envFileVarSmtpFrom, envFileVarSmtpPassword}
 	envFileContents = make(map[string]string)
 )
 
@@ -197,8 +198,10 @@ func run(ctx *cli.Context) error {
 	sqlitePath := path.Join(workingDir, defaultDataPath, dbFile)
 	components, err := factory.NewComponentsHandler(
 		cfg,
+		configFile,
 		sqlitePath,
 		envFileContents[envFileVarJwtKey],
+		envFileContents[envFileVarKeysHashKey],
 		emailsConfig,
 		appVersion,
 		swaggerPath,
@@ -225,11 +228,23 @@ func run(ctx *cli.Context) error {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
-	<-sigs
-
-	log.Info("application closing, calling Close on all subcomponents...")
-
-	return nil
+	reloadSigs := make(chan os.Signal, 1)
+	signal.Notify(reloadSigs, syscall.SIGHUP)
+
+	for {
+		select {
+		case <-reloadSigs:
+			log.Info("SIGHUP received, reloading the gateways configuration...")
+			err = components.ReloadGateways()
+			if err != nil {
+				log.Error("gateways reload failed, keeping the old configuration", "error", err)
+			}
+		case <-sigs:
+			log.Info("application closing, calling Close on all subcomponents...")
+
+			return nil
+		}
+	}
 }
 
 func attachFileLogger(log logger.Logger, saveLogFile bool, workingDir string) error {
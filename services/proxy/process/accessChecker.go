@@ -1,11 +1,16 @@
 package process
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/multiversx/mx-chain-core-go/core/check"
 )
 
@@ -14,48 +19,147 @@ const uriSeparator = "/"
 
 var allowedVersions = []string{"v1"}
 
+// ArgsAccessChecker is the DTO used to create a new access checker
+type ArgsAccessChecker struct {
+	KeyAccessProvider  KeyAccessProvider
+	RateLimiter        RateLimiter
+	SlowLane           SlowLane
+	TiersPolicy        TiersPolicy
+	CreditCostTable    CreditCostTable
+	ViolationsRecorder KeyViolationsRecorder
+	ClientIPResolver   ClientIPResolver
+}
+
 type accessChecker struct {
-	keyAccessProvider         KeyAccessProvider
-	counter                   KeyCounter
-	maxNumCallsForFreeAccount uint64
+	keyAccessProvider  KeyAccessProvider
+	rateLimiter        RateLimiter
+	slowLane           SlowLane
+	tiersPolicy        TiersPolicy
+	creditCostTable    CreditCostTable
+	violationsRecorder KeyViolationsRecorder
+	clientIPResolver   ClientIPResolver
 }
 
 // NewAccessChecker creates a new instance of type access checker
-func NewAccessChecker(
-	keyAccessProvider KeyAccessProvider,
-	counter KeyCounter,
-	maxNumCallsForFreeAccount uint64,
-) (*accessChecker, error) {
-	if check.IfNil(keyAccessProvider) {
+func NewAccessChecker(args ArgsAccessChecker) (*accessChecker, error) {
+	if check.IfNil(args.KeyAccessProvider) {
 		return nil, errNilKeyAccessChecker
 	}
-	if check.IfNil(counter) {
-		return nil, errNilKeyCounter
+	if check.IfNil(args.RateLimiter) {
+		return nil, errNilRateLimiter
+	}
+	if check.IfNil(args.SlowLane) {
+		return nil, errNilSlowLane
+	}
+	if check.IfNil(args.TiersPolicy) {
+		return nil, errNilTiersPolicy
+	}
+	if check.IfNil(args.CreditCostTable) {
+		return nil, errNilCreditCostTable
+	}
+	if check.IfNil(args.ViolationsRecorder) {
+		return nil, errNilKeyViolationsRecorder
+	}
+	if check.IfNil(args.ClientIPResolver) {
+		return nil, errNilClientIPResolver
 	}
 
 	return &accessChecker{
-		keyAccessProvider:         keyAccessProvider,
-		counter:                   counter,
-		maxNumCallsForFreeAccount: maxNumCallsForFreeAccount,
+		keyAccessProvider:  args.KeyAccessProvider,
+		rateLimiter:        args.RateLimiter,
+		slowLane:           args.SlowLane,
+		tiersPolicy:        args.TiersPolicy,
+		creditCostTable:    args.CreditCostTable,
+		violationsRecorder: args.ViolationsRecorder,
+		clientIPResolver:   args.ClientIPResolver,
 	}, nil
 }
 
-// ShouldProcessRequest returns true if the request is allowed to be processed
-func (checker *accessChecker) ShouldProcessRequest(header http.Header, requestURI string) (string, error) {
-	accessKeyFromURI, processedRequestURI := processRequestURI(requestURI)
-	accessKeyFromHeader := parseHeaderForAccessKey(header)
+// ShouldProcessRequest returns the request URI without the access key, the account and the rate limiter's decision if
+// the request is allowed to be processed. Each allowed request is charged with ConsumeCredits, once routed, and has to
+// be released with ReleaseRequest.
+func (checker *accessChecker) ShouldProcessRequest(request *http.Request) (common.AccessResult, error) {
+	accessKeyFromURI, processedRequestURI := processRequestURI(request.RequestURI)
+	accessKeyFromHeader := parseHeaderForAccessKey(request.Header)
 
 	accessKeys := common.NewKeysQueue(
 		accessKeyFromURI,
 		accessKeyFromHeader,
 	)
 
-	err := checker.atLeastOneKeyIsAllowed(accessKeys.Get())
+	requestPath := policyPath(request, len(accessKeyFromURI) > 0)
+	cost := checker.creditCostTable.GetCost(request.Method, requestPath)
+	clientIP := checker.clientIPResolver.ResolveClientIP(request)
+	result, access, err := checker.atLeastOneKeyIsAllowed(accessKeys.Get(), request, clientIP, requestPath, cost)
 	if err != nil {
-		return "", err
+		result.RateLimit, err = checker.waitInSlowLane(request.Context(), result.RateLimit, err)
+		if err == nil {
+			// the released request did not hold its concurrency slot while waiting
+			err = checker.acquireRequest(result.Username, result.AccountType)
+		}
+	}
+	if err != nil {
+		return common.AccessResult{RateLimit: result.RateLimit, Cost: result.Cost, ClientIP: clientIP}, err
+	}
+
+	result.RequestURI = processedRequestURI
+	result.ClientIP = clientIP
+	result.KeyAccess = access
+
+	return result, nil
+}
+
+// CheckHost returns an error if the provided gateway is outside the scope of the key used by the allowed request
+func (checker *accessChecker) CheckHost(result common.AccessResult, host config.GatewayConfig) error {
+	return checkHostScope(result.Scope, host)
+}
+
+// ConsumeCredits charges the allowed request with its cost. It should only be called once the request passed the
+// routing checks, so the requests that are refused afterward are not charged.
+func (checker *accessChecker) ConsumeCredits(result common.AccessResult) {
+	checker.keyAccessProvider.ConsumeKeyCredits(result.KeyAccess, result.Cost, result.ClientIP)
+}
+
+// ReleaseRequest marks the allowed request as done
+func (checker *accessChecker) ReleaseRequest(result common.AccessResult) {
+	checker.tiersPolicy.ReleaseRequest(result.Username)
+}
+
+// waitInSlowLane returns nil if the throttled request was released by the slow lane, otherwise the provided error.
+// Only the throttled requests of the free accounts wait, if the slow lane is enabled, the other ones being rejected
+// right away. The returned rate limiter's decision reflects the released request.
+func (checker *accessChecker) waitInSlowLane(ctx context.Context, rateLimit common.RateLimitResult, err error) (common.RateLimitResult, error) {
+	throttledErr := &tooManyRequestsError{}
+	if !errors.As(err, &throttledErr) {
+		return rateLimit, err
+	}
+	if throttledErr.accountType != common.FreeAccountType || !checker.slowLane.IsEnabled() {
+		return rateLimit, err
+	}
+
+	waitStart := time.Now()
+	errWait := checker.slowLane.Wait(ctx, throttledErr.username)
+	if errWait != nil {
+		return rateLimit, fmt.Errorf("%w, %s", err, errWait.Error())
 	}
 
-	return processedRequestURI, nil
+	return releasedRateLimit(rateLimit, time.Since(waitStart)), nil
+}
+
+// releasedRateLimit returns the decision for a request admitted by the slow lane: the request is allowed outside the
+// quota, so nothing remains of it, and the quota is given back sooner by the time spent waiting
+func releasedRateLimit(rateLimit common.RateLimitResult, waited time.Duration) common.RateLimitResult {
+	resetAfter := rateLimit.ResetAfter - waited
+	if resetAfter < 0 {
+		resetAfter = 0
+	}
+
+	return common.RateLimitResult{
+		Allowed:    true,
+		Limit:      rateLimit.Limit,
+		Remaining:  0,
+		ResetAfter: resetAfter,
+	}
 }
 
 func processRequestURI(inputRequestURI string) (string, string) {
@@ -72,6 +176,21 @@ func processRequestURI(inputRequestURI string) (string, string) {
 	return strings.ToLower(splt[2]), uriSeparator + strings.Join(splt[3:], uriSeparator)
 }
 
+// policyPath returns the decoded and cleaned request path, as the gateway will see it, without the version and the
+// access key segments if the key was provided in the URL. The credit costs, the key scopes and the tiers endpoints are
+// matched against this path, so an encoded or a dot segment can not change the outcome.
+func policyPath(request *http.Request, hasKeyInURI bool) string {
+	requestPath := request.URL.Path
+	if hasKeyInURI {
+		segments := strings.SplitN(requestPath, uriSeparator, 4)
+		if len(segments) == 4 {
+			requestPath = segments[3]
+		}
+	}
+
+	return path.Clean(uriSeparator + requestPath)
+}
+
 func checkVersion(version string) bool {
 	for _, vers := range allowedVersions {
 		if vers == strings.ToLower(version) {
@@ -91,48 +210,94 @@ func parseHeaderForAccessKey(header http.Header) string {
 	return strings.ToLower(val)
 }
 
-func (checker *accessChecker) atLeastOneKeyIsAllowed(keys []string) error {
+func (checker *accessChecker) atLeastOneKeyIsAllowed(keys []string, request *http.Request, clientIP string, requestPath string, cost uint64) (common.AccessResult, common.KeyAccess, error) {
 	if len(keys) == 0 {
-		return fmt.Errorf("%w: no key provided", errUnauthorized)
+		return common.AccessResult{}, common.KeyAccess{}, fmt.Errorf("%w: no key provided", errUnauthorized)
 	}
 
+	var lastResult common.AccessResult
+	var lastAccess common.KeyAccess
 	var lastErr error
 	for _, key := range keys {
-		err := checker.isKeyAllowed(key)
+		result, access, err := checker.isKeyAllowed(key, request, clientIP, requestPath, cost)
 		if err == nil {
-			return nil
+			return result, access, nil
 		}
 
+		lastResult = result
+		lastAccess = access
 		lastErr = err
 	}
 
-	return lastErr
+	return lastResult, lastAccess, lastErr
 }
 
-func (checker *accessChecker) isKeyAllowed(key string) error {
-	username, accountType, err := checker.keyAccessProvider.IsKeyAllowed(key)
+// isKeyAllowed checks the request against the key's restrictions, recording the rejected requests as key violations,
+// the key's scope and the endpoints and the concurrency cap of the account's tier, before asking the rate limiter. An
+// account that exceeded its quota gets an error matching errTooManyRequests instead of errUnauthorized.
+func (checker *accessChecker) isKeyAllowed(key string, request *http.Request, clientIP string, requestPath string, cost uint64) (common.AccessResult, common.KeyAccess, error) {
+	access, err := checker.keyAccessProvider.GetKeyAccess(key)
 	if err != nil {
 		// error determining if the key is allowed, we should return false
-		return fmt.Errorf("%w: %s", errUnauthorized, err.Error())
+		return common.AccessResult{}, common.KeyAccess{}, fmt.Errorf("%w: %s", errUnauthorized, err.Error())
 	}
 
-	if accountType == common.PremiumAccountType {
-		// the account is premium, no further throttling
-		return nil
+	err = checkKeyRestrictions(access.Restrictions, clientIP, request.Header)
+	if err != nil {
+		checker.violationsRecorder.Record(common.KeyViolation{
+			Timestamp: time.Now().Unix(),
+			Username:  access.Username,
+			KeyPrefix: common.KeyPrefix(key),
+			ClientIP:  clientIP,
+			Origin:    requestOrigin(request.Header),
+			Reason:    err.Error(),
+		})
+
+		return common.AccessResult{Cost: cost}, common.KeyAccess{}, err
 	}
 
-	return checker.isNotThrottled(username)
-}
+	if !isRequestInScope(access.Scope, request.Method, requestPath) {
+		return common.AccessResult{Cost: cost}, common.KeyAccess{}, fmt.Errorf("%w: %s %s", errKeyOutOfScope, request.Method, requestPath)
+	}
+
+	if !checker.tiersPolicy.IsEndpointAllowed(access.AccountType, requestPath) {
+		return common.AccessResult{Cost: cost}, common.KeyAccess{}, fmt.Errorf("%w: %s for %s account", errEndpointNotAllowed, requestPath, access.AccountType)
+	}
+
+	// the concurrency slot is taken first, so a request rejected by the tier's cap does not consume the quota
+	err = checker.acquireRequest(access.Username, access.AccountType)
+	if err != nil {
+		return common.AccessResult{Cost: cost}, common.KeyAccess{}, err
+	}
 
-func (checker *accessChecker) isNotThrottled(username string) error {
-	currentCounter := checker.counter.IncrementReturningCurrent(username)
+	result := common.AccessResult{
+		Username:    access.Username,
+		AccountType: access.AccountType,
+		RateLimit:   checker.rateLimiter.Allow(access.Username, access.AccountType),
+		Cost:        cost,
+		Scope:       access.Scope,
+	}
+	if result.RateLimit.Allowed {
+		return result, access, nil
+	}
+
+	// the throttled request does not hold the slot while it is rejected or waits in the slow lane
+	checker.tiersPolicy.ReleaseRequest(access.Username)
+
+	return result, access, &tooManyRequestsError{
+		username:    access.Username,
+		accountType: access.AccountType,
+		result:      result.RateLimit,
+	}
+}
 
-	if currentCounter <= checker.maxNumCallsForFreeAccount {
-		return nil
+// acquireRequest takes one of the concurrency slots the account's tier allows
+func (checker *accessChecker) acquireRequest(username string, accountType common.AccountType) error {
+	if !checker.tiersPolicy.AcquireRequest(username, accountType) {
+		return fmt.Errorf("%w for %s account", errTooManyConcurrentRequests, accountType)
 	}
 
-	return fmt.Errorf("%w: %s: current counter: %d, maximum per quota: %d",
-		errUnauthorized, errTooManyRequestsForFreeAccount.Error(), currentCounter, checker.maxNumCallsForFreeAccount)
+	return nil
 }
 
 // IsInterfaceNil returns true if the value under the interface is nil
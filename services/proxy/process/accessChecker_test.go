@@ -1,48 +1,127 @@
 package process
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
 	"github.com/stretchr/testify/assert"
 )
 
 func generateTestKeyAccessProviderWith3Keys() KeyAccessProvider {
 	return &testscommon.StorerStub{
-		IsKeyAllowedHandler: func(key string) (string, common.AccountType, error) {
+		GetKeyAccessHandler: func(key string) (common.KeyAccess, error) {
 			if key == "key1" || key == "key2" || key == "key3" {
-				return "user", "free", nil
+				return common.KeyAccess{Username: "user", AccountType: "free"}, nil
 			}
 
-			return "", "", errors.New("not authorized")
+			return common.KeyAccess{}, errors.New("not authorized")
 		},
 	}
 }
 
+func createTestFixedWindowLimiter(counter KeyCounter, maxCalls uint64) RateLimiter {
+	limiter, _ := NewFixedWindowLimiter(counter, maxCalls, time.Minute)
+
+	return limiter
+}
+
+func createTestRequest(ctx context.Context, header http.Header, requestURI string) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, requestURI, nil).WithContext(ctx)
+	request.Header = header
+
+	return request
+}
+
+func createMockArgsAccessChecker() ArgsAccessChecker {
+	return ArgsAccessChecker{
+		KeyAccessProvider:  generateTestKeyAccessProviderWith3Keys(),
+		RateLimiter:        createTestFixedWindowLimiter(&testscommon.KeyCounterStub{}, 10),
+		SlowLane:           &testscommon.SlowLaneStub{},
+		TiersPolicy:        &testscommon.TiersPolicyStub{},
+		CreditCostTable:    &testscommon.CreditCostTableStub{},
+		ViolationsRecorder: &testscommon.KeyViolationsRecorderStub{},
+		ClientIPResolver:   createTestClientIPResolver(),
+	}
+}
+
+func createTestClientIPResolver() ClientIPResolver {
+	resolver, _ := NewClientIPResolver(config.ClientIPConfig{})
+
+	return resolver
+}
+
 func TestNewAccessChecker(t *testing.T) {
 	t.Parallel()
 
 	t.Run("nil keyAccessProvider should error", func(t *testing.T) {
-		checker, err := NewAccessChecker(nil, &testscommon.KeyCounterStub{}, 10)
+		args := createMockArgsAccessChecker()
+		args.KeyAccessProvider = nil
+		checker, err := NewAccessChecker(args)
 
 		assert.Nil(t, checker)
 		assert.True(t, checker.IsInterfaceNil())
 		assert.Equal(t, errNilKeyAccessChecker, err)
 	})
 
-	t.Run("nil keyCounter should error", func(t *testing.T) {
-		checker, err := NewAccessChecker(&testscommon.StorerStub{}, nil, 10)
+	t.Run("nil rate limiter should error", func(t *testing.T) {
+		args := createMockArgsAccessChecker()
+		args.RateLimiter = nil
+		checker, err := NewAccessChecker(args)
+
+		assert.Nil(t, checker)
+		assert.True(t, checker.IsInterfaceNil())
+		assert.Equal(t, errNilRateLimiter, err)
+	})
+
+	t.Run("nil slow lane should error", func(t *testing.T) {
+		args := createMockArgsAccessChecker()
+		args.SlowLane = nil
+		checker, err := NewAccessChecker(args)
+
+		assert.Nil(t, checker)
+		assert.True(t, checker.IsInterfaceNil())
+		assert.Equal(t, errNilSlowLane, err)
+	})
+
+	t.Run("nil tiers policy should error", func(t *testing.T) {
+		args := createMockArgsAccessChecker()
+		args.TiersPolicy = nil
+		checker, err := NewAccessChecker(args)
+
+		assert.Nil(t, checker)
+		assert.True(t, checker.IsInterfaceNil())
+		assert.Equal(t, errNilTiersPolicy, err)
+	})
+
+	t.Run("nil violations recorder should error", func(t *testing.T) {
+		args := createMockArgsAccessChecker()
+		args.ViolationsRecorder = nil
+		checker, err := NewAccessChecker(args)
+
+		assert.Nil(t, checker)
+		assert.True(t, checker.IsInterfaceNil())
+		assert.Equal(t, errNilKeyViolationsRecorder, err)
+	})
+
+	t.Run("nil client IP resolver should error", func(t *testing.T) {
+		args := createMockArgsAccessChecker()
+		args.ClientIPResolver = nil
+		checker, err := NewAccessChecker(args)
 
 		assert.Nil(t, checker)
 		assert.True(t, checker.IsInterfaceNil())
-		assert.Equal(t, errNilKeyCounter, err)
+		assert.Equal(t, errNilClientIPResolver, err)
 	})
 
 	t.Run("should work", func(t *testing.T) {
-		checker, err := NewAccessChecker(&testscommon.StorerStub{}, &testscommon.KeyCounterStub{}, 10)
+		checker, err := NewAccessChecker(createMockArgsAccessChecker())
 
 		assert.NotNil(t, checker)
 		assert.False(t, checker.IsInterfaceNil())
@@ -53,99 +132,171 @@ func TestNewAccessChecker(t *testing.T) {
 func TestAccessChecker_ShouldProcessRequest(t *testing.T) {
 	t.Parallel()
 
-	instanceWithAccessKeys, _ := NewAccessChecker(generateTestKeyAccessProviderWith3Keys(), &testscommon.KeyCounterStub{}, 10)
+	instanceWithAccessKeys, _ := NewAccessChecker(createMockArgsAccessChecker())
 	t.Run("should return true if the correct key is provided", func(t *testing.T) {
 		t.Parallel()
 
 		t.Run("token provided in URL", func(t *testing.T) {
 			t.Parallel()
 
-			uri, err := instanceWithAccessKeys.ShouldProcessRequest(make(http.Header), "/v1/kEy1/a/b/c?withParam=true&nonce=0")
+			result, err := instanceWithAccessKeys.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/kEy1/a/b/c?withParam=true&nonce=0"))
 			assert.Nil(t, err)
-			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", uri)
+			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", result.RequestURI)
+			assert.True(t, result.RateLimit.Allowed)
+			assert.Equal(t, uint64(10), result.RateLimit.Limit)
 		})
 		t.Run("token provided in header", func(t *testing.T) {
 			t.Parallel()
 
 			header := make(http.Header)
 			header[headerApiKey] = []string{"KeY2"}
-			uri, err := instanceWithAccessKeys.ShouldProcessRequest(header, "/a/b/c?withParam=true&nonce=0")
+			result, err := instanceWithAccessKeys.ShouldProcessRequest(createTestRequest(context.Background(), header, "/a/b/c?withParam=true&nonce=0"))
 			assert.Nil(t, err)
-			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", uri)
+			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", result.RequestURI)
 		})
 		t.Run("token provided in both places", func(t *testing.T) {
 			t.Parallel()
 
 			header := make(http.Header)
 			header[headerApiKey] = []string{"kEy3"}
-			uri, err := instanceWithAccessKeys.ShouldProcessRequest(header, "/v1/Key1/a/b/c?withParam=true&nonce=0")
+			result, err := instanceWithAccessKeys.ShouldProcessRequest(createTestRequest(context.Background(), header, "/v1/Key1/a/b/c?withParam=true&nonce=0"))
 			assert.Nil(t, err)
-			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", uri)
+			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", result.RequestURI)
 		})
 		t.Run("same token provided in both places should check only once", func(t *testing.T) {
 			t.Parallel()
 
 			numCalls := 0
-			instance, _ := NewAccessChecker(
-				&testscommon.StorerStub{
-					IsKeyAllowedHandler: func(key string) (string, common.AccountType, error) {
-						numCalls++
-						return "username", common.PremiumAccountType, nil
-					},
+			args := createMockArgsAccessChecker()
+			args.KeyAccessProvider = &testscommon.StorerStub{
+				GetKeyAccessHandler: func(key string) (common.KeyAccess, error) {
+					numCalls++
+					return common.KeyAccess{Username: "username", AccountType: common.PremiumAccountType}, nil
+				},
+			}
+			args.RateLimiter = createTestFixedWindowLimiter(&testscommon.KeyCounterStub{
+				IncrementReturningCurrentHandler: func(key string) uint64 {
+					assert.Fail(t, "should not check for throttling a premium account")
+					return 11
 				},
-				&testscommon.KeyCounterStub{
-					IncrementReturningCurrentHandler: func(key string) uint64 {
-						assert.Fail(t, "should not check for throttling a premium account")
-						return 11
-					},
-				}, 10)
+			}, 10)
+			instance, _ := NewAccessChecker(args)
 
 			header := make(http.Header)
 			header[headerApiKey] = []string{"kEy3"}
-			uri, err := instance.ShouldProcessRequest(header, "/v1/Key1/a/b/c?withParam=true&nonce=0")
+			result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), header, "/v1/Key1/a/b/c?withParam=true&nonce=0"))
 			assert.Nil(t, err)
-			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", uri)
+			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", result.RequestURI)
 			assert.Equal(t, 1, numCalls)
 		})
+		t.Run("should provide the client IP to the key access provider", func(t *testing.T) {
+			t.Parallel()
+
+			providedClientIP := ""
+			args := createMockArgsAccessChecker()
+			args.KeyAccessProvider = &testscommon.StorerStub{
+				GetKeyAccessHandler: func(key string) (common.KeyAccess, error) {
+					return common.KeyAccess{Username: "username", AccountType: common.PremiumAccountType}, nil
+				},
+				ConsumeKeyCreditsHandler: func(access common.KeyAccess, cost uint64, clientIP string) {
+					providedClientIP = clientIP
+				},
+			}
+			instance, _ := NewAccessChecker(args)
+
+			request := createTestRequest(context.Background(), make(http.Header), "/v1/Key1/a/b/c")
+			request.RemoteAddr = "10.0.0.1:4567"
+			result, err := instance.ShouldProcessRequest(request)
+			assert.Nil(t, err)
+			instance.ConsumeCredits(result)
+			assert.Equal(t, "10.0.0.1", providedClientIP)
+		})
+		t.Run("should provide the client IP resolved by the client IP resolver", func(t *testing.T) {
+			t.Parallel()
+
+			providedClientIP := ""
+			args := createMockArgsAccessChecker()
+			args.KeyAccessProvider = &testscommon.StorerStub{
+				GetKeyAccessHandler: func(key string) (common.KeyAccess, error) {
+					return common.KeyAccess{Username: "username", AccountType: common.PremiumAccountType}, nil
+				},
+				ConsumeKeyCreditsHandler: func(access common.KeyAccess, cost uint64, clientIP string) {
+					providedClientIP = clientIP
+				},
+			}
+			args.ClientIPResolver = &testscommon.ClientIPResolverStub{
+				ResolveClientIPCalled: func(request *http.Request) string {
+					return "203.0.113.9"
+				},
+			}
+			instance, _ := NewAccessChecker(args)
+
+			result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/Key1/a/b/c"))
+			assert.Nil(t, err)
+			instance.ConsumeCredits(result)
+			assert.Equal(t, "203.0.113.9", providedClientIP)
+			assert.Equal(t, "203.0.113.9", result.ClientIP)
+		})
+		t.Run("should return the client IP for a rejected request", func(t *testing.T) {
+			t.Parallel()
+
+			args := createMockArgsAccessChecker()
+			args.KeyAccessProvider = &testscommon.StorerStub{
+				GetKeyAccessHandler: func(key string) (common.KeyAccess, error) {
+					return common.KeyAccess{}, errors.New("unknown key")
+				},
+			}
+			args.ClientIPResolver = &testscommon.ClientIPResolverStub{
+				ResolveClientIPCalled: func(request *http.Request) string {
+					return "203.0.113.9"
+				},
+			}
+			instance, _ := NewAccessChecker(args)
+
+			result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/Key1/a/b/c"))
+			assert.ErrorIs(t, err, errUnauthorized)
+			assert.Equal(t, "203.0.113.9", result.ClientIP)
+		})
 		t.Run("wrong token in header values and correct token in URL should return true", func(t *testing.T) {
 			t.Parallel()
 
 			header := make(http.Header)
 			header[headerApiKey] = []string{"kEyX"}
-			uri, err := instanceWithAccessKeys.ShouldProcessRequest(header, "/v1/Key1/a/b/c?withParam=true&nonce=0")
+			result, err := instanceWithAccessKeys.ShouldProcessRequest(createTestRequest(context.Background(), header, "/v1/Key1/a/b/c?withParam=true&nonce=0"))
 			assert.Nil(t, err)
-			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", uri)
+			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", result.RequestURI)
 		})
 		t.Run("correct token in header values and wrong token in URL should return true", func(t *testing.T) {
 			t.Parallel()
 
 			header := make(http.Header)
 			header[headerApiKey] = []string{"kEy1"}
-			uri, err := instanceWithAccessKeys.ShouldProcessRequest(header, "/v1/KeyY/a/b/c?withParam=true&nonce=0")
+			result, err := instanceWithAccessKeys.ShouldProcessRequest(createTestRequest(context.Background(), header, "/v1/KeyY/a/b/c?withParam=true&nonce=0"))
 			assert.Nil(t, err)
-			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", uri)
+			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", result.RequestURI)
 		})
 		t.Run("should return true for a premium account", func(t *testing.T) {
 			t.Parallel()
 
-			instance, _ := NewAccessChecker(
-				&testscommon.StorerStub{
-					IsKeyAllowedHandler: func(key string) (string, common.AccountType, error) {
-						return "username", common.PremiumAccountType, nil
-					},
+			args := createMockArgsAccessChecker()
+			args.KeyAccessProvider = &testscommon.StorerStub{
+				GetKeyAccessHandler: func(key string) (common.KeyAccess, error) {
+					return common.KeyAccess{Username: "username", AccountType: common.PremiumAccountType}, nil
+				},
+			}
+			args.RateLimiter = createTestFixedWindowLimiter(&testscommon.KeyCounterStub{
+				IncrementReturningCurrentHandler: func(key string) uint64 {
+					assert.Fail(t, "should not check for throttling a premium account")
+					return 11
 				},
-				&testscommon.KeyCounterStub{
-					IncrementReturningCurrentHandler: func(key string) uint64 {
-						assert.Fail(t, "should not check for throttling a premium account")
-						return 11
-					},
-				}, 10)
+			}, 10)
+			instance, _ := NewAccessChecker(args)
 
 			header := make(http.Header)
 			header[headerApiKey] = []string{"kEy1"}
-			uri, err := instance.ShouldProcessRequest(header, "/v1/kEy1/a/b/c?withParam=true&nonce=0")
+			result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), header, "/v1/kEy1/a/b/c?withParam=true&nonce=0"))
 			assert.Nil(t, err)
-			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", uri)
+			assert.Equal(t, "/a/b/c?withParam=true&nonce=0", result.RequestURI)
 		})
 	})
 	t.Run("should return false for incorrect key", func(t *testing.T) {
@@ -154,56 +305,636 @@ func TestAccessChecker_ShouldProcessRequest(t *testing.T) {
 		t.Run("no key provided", func(t *testing.T) {
 			t.Parallel()
 
-			uri, err := instanceWithAccessKeys.ShouldProcessRequest(make(http.Header), "/a/b/c?withParam=true&nonce=0")
+			result, err := instanceWithAccessKeys.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/a/b/c?withParam=true&nonce=0"))
 			assert.ErrorIs(t, err, errUnauthorized)
 			assert.Contains(t, err.Error(), "no key provided")
-			assert.Empty(t, uri)
+			assert.Empty(t, result.RequestURI)
 		})
 		t.Run("wrong token provided in URL", func(t *testing.T) {
 			t.Parallel()
 
-			uri, err := instanceWithAccessKeys.ShouldProcessRequest(make(http.Header), "/v1/kEyX/a/b/c?withParam=true&nonce=0")
+			result, err := instanceWithAccessKeys.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/kEyX/a/b/c?withParam=true&nonce=0"))
 			assert.ErrorIs(t, err, errUnauthorized)
-			assert.Empty(t, uri)
+			assert.Empty(t, result.RequestURI)
 		})
 		t.Run("wrong token provided in url values", func(t *testing.T) {
 			t.Parallel()
 
 			header := make(http.Header)
 			header[headerApiKey] = []string{"KeYY"}
-			uri, err := instanceWithAccessKeys.ShouldProcessRequest(header, "/a/b/c?withParam=true&nonce=0")
+			result, err := instanceWithAccessKeys.ShouldProcessRequest(createTestRequest(context.Background(), header, "/a/b/c?withParam=true&nonce=0"))
 			assert.ErrorIs(t, err, errUnauthorized)
-			assert.Empty(t, uri)
+			assert.Empty(t, result.RequestURI)
 		})
 		t.Run("wrong tokens provided in both places", func(t *testing.T) {
 			t.Parallel()
 
 			header := make(http.Header)
 			header[headerApiKey] = []string{"kEyX"}
-			uri, err := instanceWithAccessKeys.ShouldProcessRequest(header, "/v1/KeyY/a/b/c?withParam=true&nonce=0")
+			result, err := instanceWithAccessKeys.ShouldProcessRequest(createTestRequest(context.Background(), header, "/v1/KeyY/a/b/c?withParam=true&nonce=0"))
 			assert.ErrorIs(t, err, errUnauthorized)
-			assert.Empty(t, uri)
+			assert.Empty(t, result.RequestURI)
 		})
 		t.Run("token provided is throttled", func(t *testing.T) {
 			t.Parallel()
 
 			numCalls := 0
-			instance, _ := NewAccessChecker(
-				generateTestKeyAccessProviderWith3Keys(),
-				&testscommon.KeyCounterStub{
-					IncrementReturningCurrentHandler: func(key string) uint64 {
-						numCalls++
-						return 11
-					},
-				}, 10)
+			args := createMockArgsAccessChecker()
+			args.RateLimiter = createTestFixedWindowLimiter(&testscommon.KeyCounterStub{
+				IncrementReturningCurrentHandler: func(key string) uint64 {
+					numCalls++
+					return 11
+				},
+			}, 10)
+			instance, _ := NewAccessChecker(args)
 
 			header := make(http.Header)
 			header[headerApiKey] = []string{"Key1"}
-			uri, err := instance.ShouldProcessRequest(header, "/v1/Key1/a/b/c?withParam=true&nonce=0")
-			assert.ErrorIs(t, err, errUnauthorized)
+			result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), header, "/v1/Key1/a/b/c?withParam=true&nonce=0"))
+			assert.ErrorIs(t, err, errTooManyRequests)
+			assert.NotErrorIs(t, err, errUnauthorized)
 			assert.Contains(t, err.Error(), "too many requests for free account")
-			assert.Empty(t, uri)
+			assert.Empty(t, result.RequestURI)
+			assert.False(t, result.RateLimit.Allowed)
+			assert.Equal(t, uint64(10), result.RateLimit.Limit)
 			assert.Equal(t, 1, numCalls)
 		})
+		t.Run("token provided is throttled by the rate limiter of its account type", func(t *testing.T) {
+			t.Parallel()
+
+			args := createMockArgsAccessChecker()
+			args.KeyAccessProvider = &testscommon.StorerStub{
+				GetKeyAccessHandler: func(key string) (common.KeyAccess, error) {
+					return common.KeyAccess{Username: "username", AccountType: common.PremiumAccountType}, nil
+				},
+			}
+			args.RateLimiter = &testscommon.RateLimiterStub{
+				AllowCalled: func(username string, accountType common.AccountType) common.RateLimitResult {
+					assert.Equal(t, "username", username)
+					assert.Equal(t, common.PremiumAccountType, accountType)
+
+					return common.RateLimitResult{
+						Allowed:    false,
+						Limit:      100,
+						RetryAfter: time.Second,
+					}
+				},
+			}
+			instance, _ := NewAccessChecker(args)
+
+			result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/Key1/a/b/c?withParam=true&nonce=0"))
+			assert.ErrorIs(t, err, errTooManyRequests)
+			assert.Equal(t, "too many requests for premium account: maximum per quota: 100, retry after: 1s", err.Error())
+			assert.Equal(t, uint64(100), result.RateLimit.Limit)
+			assert.Empty(t, result.RequestURI)
+		})
+	})
+}
+
+func TestAccessChecker_ShouldProcessRequestWithSlowLane(t *testing.T) {
+	t.Parallel()
+
+	throttlingLimiter := &testscommon.RateLimiterStub{
+		AllowCalled: func(username string, accountType common.AccountType) common.RateLimitResult {
+			return common.RateLimitResult{
+				Allowed:    false,
+				Limit:      10,
+				ResetAfter: time.Minute,
+				RetryAfter: time.Second,
+			}
+		},
+	}
+	createKeyAccessProvider := func(accountType common.AccountType) KeyAccessProvider {
+		return &testscommon.StorerStub{
+			GetKeyAccessHandler: func(key string) (common.KeyAccess, error) {
+				return common.KeyAccess{Username: "user", AccountType: accountType}, nil
+			},
+		}
+	}
+
+	t.Run("released free account request should be processed", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		numWaitCalls := 0
+		args := createMockArgsAccessChecker()
+		args.KeyAccessProvider = createKeyAccessProvider(common.FreeAccountType)
+		args.RateLimiter = throttlingLimiter
+		args.SlowLane = &testscommon.SlowLaneStub{
+			IsEnabledCalled: func() bool {
+				return true
+			},
+			WaitCalled: func(providedCtx context.Context, username string) error {
+				numWaitCalls++
+				assert.Equal(t, ctx, providedCtx)
+				assert.Equal(t, "user", username)
+				return nil
+			},
+		}
+		instance, _ := NewAccessChecker(args)
+
+		result, err := instance.ShouldProcessRequest(createTestRequest(ctx, make(http.Header), "/v1/key1/a/b/c"))
+		assert.Nil(t, err)
+		assert.Equal(t, "/a/b/c", result.RequestURI)
+		assert.True(t, result.RateLimit.Allowed)
+		assert.Equal(t, uint64(10), result.RateLimit.Limit)
+		assert.Zero(t, result.RateLimit.Remaining)
+		assert.Zero(t, result.RateLimit.RetryAfter)
+		assert.LessOrEqual(t, result.RateLimit.ResetAfter, time.Minute)
+		assert.Greater(t, result.RateLimit.ResetAfter, time.Duration(0))
+		assert.Equal(t, 1, numWaitCalls)
+	})
+	t.Run("released request should take a slot of the tier's cap", func(t *testing.T) {
+		t.Parallel()
+
+		acquired := make([]string, 0)
+		released := make([]string, 0)
+		args := createMockArgsAccessChecker()
+		args.KeyAccessProvider = createKeyAccessProvider(common.FreeAccountType)
+		args.RateLimiter = throttlingLimiter
+		args.SlowLane = &testscommon.SlowLaneStub{
+			IsEnabledCalled: func() bool {
+				return true
+			},
+		}
+		args.TiersPolicy = &testscommon.TiersPolicyStub{
+			AcquireRequestCalled: func(username string, accountType common.AccountType) bool {
+				acquired = append(acquired, username)
+				// the slot is free before the wait but taken when the request is released
+				return len(acquired) == 1
+			},
+			ReleaseRequestCalled: func(username string) {
+				released = append(released, username)
+			},
+		}
+		instance, _ := NewAccessChecker(args)
+
+		_, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/a/b/c"))
+		assert.ErrorIs(t, err, errTooManyConcurrentRequests)
+		assert.Equal(t, []string{"user", "user"}, acquired)
+		// the slot taken before the rate limiter rejected the request was given back before waiting
+		assert.Equal(t, []string{"user"}, released)
+	})
+	t.Run("request rejected by the slow lane should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsAccessChecker()
+		args.KeyAccessProvider = createKeyAccessProvider(common.FreeAccountType)
+		args.RateLimiter = throttlingLimiter
+		args.SlowLane = &testscommon.SlowLaneStub{
+			IsEnabledCalled: func() bool {
+				return true
+			},
+			WaitCalled: func(ctx context.Context, username string) error {
+				return errSlowLaneQueueFull
+			},
+		}
+		instance, _ := NewAccessChecker(args)
+
+		result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/a/b/c"))
+		assert.ErrorIs(t, err, errTooManyRequests)
+		assert.Contains(t, err.Error(), errSlowLaneQueueFull.Error())
+		assert.Empty(t, result.RequestURI)
+	})
+	t.Run("premium account or disabled slow lane should not wait", func(t *testing.T) {
+		t.Parallel()
+
+		slowLane := &testscommon.SlowLaneStub{
+			IsEnabledCalled: func() bool {
+				return true
+			},
+			WaitCalled: func(ctx context.Context, username string) error {
+				assert.Fail(t, "should have not been called")
+				return nil
+			},
+		}
+		args := createMockArgsAccessChecker()
+		args.KeyAccessProvider = createKeyAccessProvider(common.PremiumAccountType)
+		args.RateLimiter = throttlingLimiter
+		args.SlowLane = slowLane
+		instance, _ := NewAccessChecker(args)
+		_, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/a/b/c"))
+		assert.ErrorIs(t, err, errTooManyRequests)
+
+		slowLane.IsEnabledCalled = func() bool {
+			return false
+		}
+		args.KeyAccessProvider = createKeyAccessProvider(common.FreeAccountType)
+		instance, _ = NewAccessChecker(args)
+		_, err = instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/a/b/c"))
+		assert.ErrorIs(t, err, errTooManyRequests)
+	})
+	t.Run("unauthorized key should not wait", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsAccessChecker()
+		args.RateLimiter = throttlingLimiter
+		args.SlowLane = &testscommon.SlowLaneStub{
+			IsEnabledCalled: func() bool {
+				return true
+			},
+			WaitCalled: func(ctx context.Context, username string) error {
+				assert.Fail(t, "should have not been called")
+				return nil
+			},
+		}
+		instance, _ := NewAccessChecker(args)
+
+		_, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/keyX/a/b/c"))
+		assert.ErrorIs(t, err, errUnauthorized)
+	})
+}
+
+func TestAccessChecker_ShouldProcessRequestWithTiers(t *testing.T) {
+	t.Parallel()
+
+	createArgs := func() ArgsAccessChecker {
+		tiersPolicy, _ := NewTiersPolicy([]config.TierConfig{
+			{
+				Name:                  "gold",
+				MaxConcurrentRequests: 1,
+				AllowedEndpoints:      []string{"/address", "network/config"},
+			},
+		})
+
+		args := createMockArgsAccessChecker()
+		args.KeyAccessProvider = &testscommon.StorerStub{
+			GetKeyAccessHandler: func(key string) (common.KeyAccess, error) {
+				return common.KeyAccess{Username: "user", AccountType: "gold"}, nil
+			},
+		}
+		args.RateLimiter = &testscommon.RateLimiterStub{}
+		args.TiersPolicy = tiersPolicy
+
+		return args
+	}
+
+	t.Run("endpoint not allowed for the tier should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createArgs()
+		args.RateLimiter = &testscommon.RateLimiterStub{
+			AllowCalled: func(username string, accountType common.AccountType) common.RateLimitResult {
+				assert.Fail(t, "should have not consumed the quota")
+				return common.RateLimitResult{}
+			},
+		}
+		instance, _ := NewAccessChecker(args)
+
+		result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/transaction/aa?withResults=true"))
+		assert.ErrorIs(t, err, errEndpointNotAllowed)
+		assert.Equal(t, "endpoint not allowed: /transaction/aa for gold account", err.Error())
+		assert.Empty(t, result.RequestURI)
+	})
+	t.Run("allowed endpoint should return the account", func(t *testing.T) {
+		t.Parallel()
+
+		instance, _ := NewAccessChecker(createArgs())
+
+		result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/address/erd1?onFinalBlock=true"))
+		assert.Nil(t, err)
+		assert.Equal(t, common.AccessResult{
+			RequestURI:  "/address/erd1?onFinalBlock=true",
+			Username:    "user",
+			AccountType: "gold",
+			RateLimit:   common.RateLimitResult{Allowed: true},
+			Cost:        1,
+			ClientIP:    "192.0.2.1",
+			KeyAccess:   common.KeyAccess{Username: "user", AccountType: "gold"},
+		}, result)
+	})
+	t.Run("concurrent requests above the tier's cap should error until released", func(t *testing.T) {
+		t.Parallel()
+
+		instance, _ := NewAccessChecker(createArgs())
+
+		result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/network/config"))
+		assert.Nil(t, err)
+
+		_, err = instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/network/config"))
+		assert.ErrorIs(t, err, errTooManyConcurrentRequests)
+		assert.Equal(t, http.StatusTooManyRequests, getStatusCodeForAccessError(err))
+
+		instance.ReleaseRequest(result)
+		_, err = instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/network/config"))
+		assert.Nil(t, err)
+	})
+	t.Run("request above the tier's cap should not consume the quota", func(t *testing.T) {
+		t.Parallel()
+
+		numAllowCalls := 0
+		args := createArgs()
+		args.RateLimiter = &testscommon.RateLimiterStub{
+			AllowCalled: func(username string, accountType common.AccountType) common.RateLimitResult {
+				numAllowCalls++
+				return common.RateLimitResult{Allowed: true}
+			},
+		}
+		instance, _ := NewAccessChecker(args)
+
+		_, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/network/config"))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, numAllowCalls)
+
+		_, err = instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/network/config"))
+		assert.ErrorIs(t, err, errTooManyConcurrentRequests)
+		assert.Equal(t, 1, numAllowCalls)
+	})
+	t.Run("throttled request should not hold the tier's slot", func(t *testing.T) {
+		t.Parallel()
+
+		numAllowCalls := 0
+		args := createArgs()
+		args.RateLimiter = &testscommon.RateLimiterStub{
+			AllowCalled: func(username string, accountType common.AccountType) common.RateLimitResult {
+				numAllowCalls++
+				return common.RateLimitResult{Allowed: numAllowCalls > 1, Limit: 10}
+			},
+		}
+		instance, _ := NewAccessChecker(args)
+
+		_, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/network/config"))
+		assert.ErrorIs(t, err, errTooManyRequests)
+
+		_, err = instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/network/config"))
+		assert.Nil(t, err)
+		assert.Equal(t, 2, numAllowCalls)
+	})
+}
+
+func TestAccessChecker_ShouldProcessRequestWithCreditCosts(t *testing.T) {
+	t.Parallel()
+
+	createArgs := func(providedCosts *[]uint64) ArgsAccessChecker {
+		costTable, _ := NewCreditCostTable([]config.CreditCostConfig{
+			{Pattern: "/address/{address}/keys", Cost: 100},
+			{Pattern: "/transaction/send", Method: http.MethodPost, Cost: 5},
+		})
+
+		args := createMockArgsAccessChecker()
+		args.KeyAccessProvider = &testscommon.StorerStub{
+			GetKeyAccessHandler: func(key string) (common.KeyAccess, error) {
+				if key == "key1" {
+					return common.KeyAccess{Username: "user", AccountType: common.PremiumAccountType}, nil
+				}
+
+				return common.KeyAccess{}, errors.New("not authorized")
+			},
+			ConsumeKeyCreditsHandler: func(access common.KeyAccess, cost uint64, clientIP string) {
+				*providedCosts = append(*providedCosts, cost)
+			},
+		}
+		args.CreditCostTable = costTable
+
+		return args
+	}
+
+	t.Run("the request should consume the credits of its endpoint", func(t *testing.T) {
+		t.Parallel()
+
+		var providedCosts []uint64
+		instance, _ := NewAccessChecker(createArgs(&providedCosts))
+
+		result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/address/erd1/keys?onFinalBlock=true"))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(100), result.Cost)
+		instance.ConsumeCredits(result)
+
+		request := createTestRequest(context.Background(), make(http.Header), "/v1/key1/transaction/send")
+		result, err = instance.ShouldProcessRequest(request)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), result.Cost)
+		instance.ConsumeCredits(result)
+
+		request.Method = http.MethodPost
+		result, err = instance.ShouldProcessRequest(request)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(5), result.Cost)
+		instance.ConsumeCredits(result)
+
+		assert.Equal(t, []uint64{100, 1, 5}, providedCosts)
+	})
+	t.Run("encoded or dot segments should consume the credits of the decoded endpoint", func(t *testing.T) {
+		t.Parallel()
+
+		var providedCosts []uint64
+		instance, _ := NewAccessChecker(createArgs(&providedCosts))
+
+		result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/address/erd1/%6Beys"))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(100), result.Cost)
+		instance.ConsumeCredits(result)
+		assert.Equal(t, "/address/erd1/%6Beys", result.RequestURI)
+
+		result, err = instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/address/erd1/./other/../keys/"))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(100), result.Cost)
+		instance.ConsumeCredits(result)
+
+		header := make(http.Header)
+		header.Set(headerApiKey, "key1")
+		result, err = instance.ShouldProcessRequest(createTestRequest(context.Background(), header, "//address/erd1/%6beys?onFinalBlock=true"))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(100), result.Cost)
+		instance.ConsumeCredits(result)
+
+		assert.Equal(t, []uint64{100, 100, 100}, providedCosts)
+	})
+	t.Run("unauthorized key should not report a cost", func(t *testing.T) {
+		t.Parallel()
+
+		var providedCosts []uint64
+		instance, _ := NewAccessChecker(createArgs(&providedCosts))
+
+		result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/keyX/address/erd1/keys"))
+		assert.ErrorIs(t, err, errUnauthorized)
+		assert.Equal(t, uint64(0), result.Cost)
+	})
+	t.Run("throttled request should report its cost", func(t *testing.T) {
+		t.Parallel()
+
+		var providedCosts []uint64
+		args := createArgs(&providedCosts)
+		args.RateLimiter = &testscommon.RateLimiterStub{
+			AllowCalled: func(username string, accountType common.AccountType) common.RateLimitResult {
+				return common.RateLimitResult{Allowed: false, Limit: 10}
+			},
+		}
+		instance, _ := NewAccessChecker(args)
+
+		result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/key1/address/erd1/keys"))
+		assert.ErrorIs(t, err, errTooManyRequests)
+		assert.Equal(t, uint64(100), result.Cost)
+		assert.Empty(t, providedCosts)
+	})
+}
+
+func TestAccessChecker_ShouldProcessRequestWithKeyScope(t *testing.T) {
+	t.Parallel()
+
+	scope := common.KeyScope{
+		Routes:     []string{"/address"},
+		Methods:    []string{http.MethodGet},
+		EpochStart: "0",
+		EpochEnd:   "1000",
+	}
+	createArgs := func() ArgsAccessChecker {
+		args := createMockArgsAccessChecker()
+		args.KeyAccessProvider = &testscommon.StorerStub{
+			GetKeyAccessHandler: func(key string) (common.KeyAccess, error) {
+				if key == "scoped" {
+					return common.KeyAccess{Username: "partner", AccountType: common.PremiumAccountType, Scope: scope}, nil
+				}
+
+				return common.KeyAccess{Username: "user", AccountType: common.PremiumAccountType}, nil
+			},
+		}
+
+		return args
+	}
+
+	t.Run("request out of the key scope should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createArgs()
+		args.RateLimiter = &testscommon.RateLimiterStub{
+			AllowCalled: func(username string, accountType common.AccountType) common.RateLimitResult {
+				assert.Fail(t, "should have not called the rate limiter")
+				return common.RateLimitResult{}
+			},
+		}
+		instance, _ := NewAccessChecker(args)
+
+		result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/scoped/network/config"))
+		assert.ErrorIs(t, err, errKeyOutOfScope)
+		assert.Contains(t, err.Error(), "GET /network/config")
+		assert.Equal(t, common.AccessResult{Cost: 1, ClientIP: "192.0.2.1"}, result)
+
+		request := createTestRequest(context.Background(), make(http.Header), "/v1/scoped/address/erd1")
+		request.Method = http.MethodPost
+		_, err = instance.ShouldProcessRequest(request)
+		assert.ErrorIs(t, err, errKeyOutOfScope)
+		assert.Contains(t, err.Error(), "POST /address/erd1")
+
+		_, err = instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/scoped/address/../network/config"))
+		assert.ErrorIs(t, err, errKeyOutOfScope)
+		assert.Contains(t, err.Error(), "GET /network/config")
+	})
+	t.Run("request in the key scope should return the scope", func(t *testing.T) {
+		t.Parallel()
+
+		instance, _ := NewAccessChecker(createArgs())
+
+		result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/scoped/address/erd1?onFinalBlock=true"))
+		assert.Nil(t, err)
+		assert.Equal(t, "partner", result.Username)
+		assert.Equal(t, scope, result.Scope)
+
+		assert.Nil(t, instance.CheckHost(result, config.GatewayConfig{EpochStart: "0", EpochEnd: "1000"}))
+		err = instance.CheckHost(result, config.GatewayConfig{EpochStart: "1001", EpochEnd: "latest"})
+		assert.ErrorIs(t, err, errKeyOutOfScope)
+	})
+	t.Run("key without scope should reach all the hosts", func(t *testing.T) {
+		t.Parallel()
+
+		instance, _ := NewAccessChecker(createArgs())
+
+		result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/unscoped/network/config"))
+		assert.Nil(t, err)
+		assert.True(t, result.Scope.IsEmpty())
+		assert.Nil(t, instance.CheckHost(result, config.GatewayConfig{EpochStart: "1001", EpochEnd: "latest"}))
+	})
+}
+
+func TestAccessChecker_ShouldProcessRequestWithKeyRestrictions(t *testing.T) {
+	t.Parallel()
+
+	restrictions := common.KeyRestrictions{
+		AllowedCIDRs:   []string{"192.0.2.0/24"},
+		AllowedOrigins: []string{"https://*.example.com"},
+	}
+	createArgs := func(recorded *[]common.KeyViolation, numConsumed *int) ArgsAccessChecker {
+		args := createMockArgsAccessChecker()
+		args.KeyAccessProvider = &testscommon.StorerStub{
+			GetKeyAccessHandler: func(key string) (common.KeyAccess, error) {
+				return common.KeyAccess{Username: "partner", AccountType: common.PremiumAccountType, Restrictions: restrictions}, nil
+			},
+			ConsumeKeyCreditsHandler: func(access common.KeyAccess, cost uint64, clientIP string) {
+				*numConsumed++
+			},
+		}
+		args.ViolationsRecorder = &testscommon.KeyViolationsRecorderStub{
+			RecordCalled: func(violation common.KeyViolation) {
+				*recorded = append(*recorded, violation)
+			},
+		}
+
+		return args
+	}
+
+	t.Run("request matching the key restrictions should be allowed", func(t *testing.T) {
+		t.Parallel()
+
+		recorded := make([]common.KeyViolation, 0)
+		numConsumed := 0
+		instance, _ := NewAccessChecker(createArgs(&recorded, &numConsumed))
+
+		header := make(http.Header)
+		header.Set(headerOrigin, "https://app.example.com")
+		// the httptest requests come from 192.0.2.1
+		result, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), header, "/v1/restricted1234/network/config"))
+		assert.Nil(t, err)
+		assert.Equal(t, "partner", result.Username)
+		assert.Empty(t, recorded)
+		// the allowed request is only charged once routed
+		assert.Zero(t, numConsumed)
+		instance.ConsumeCredits(result)
+		assert.Equal(t, 1, numConsumed)
+	})
+	t.Run("request from another IP should be rejected and recorded", func(t *testing.T) {
+		t.Parallel()
+
+		recorded := make([]common.KeyViolation, 0)
+		numConsumed := 0
+		args := createArgs(&recorded, &numConsumed)
+		args.RateLimiter = &testscommon.RateLimiterStub{
+			AllowCalled: func(username string, accountType common.AccountType) common.RateLimitResult {
+				assert.Fail(t, "should have not called the rate limiter")
+				return common.RateLimitResult{}
+			},
+		}
+		instance, _ := NewAccessChecker(args)
+
+		header := make(http.Header)
+		header.Set(headerOrigin, "https://app.example.com")
+		request := createTestRequest(context.Background(), header, "/v1/restricted1234/network/config")
+		request.RemoteAddr = "198.51.100.7:4321"
+		result, err := instance.ShouldProcessRequest(request)
+		assert.ErrorIs(t, err, errKeyRestricted)
+		assert.Equal(t, common.AccessResult{Cost: 1, ClientIP: "198.51.100.7"}, result)
+
+		assert.Len(t, recorded, 1)
+		assert.Equal(t, "partner", recorded[0].Username)
+		assert.Equal(t, common.KeyPrefix("restricted1234"), recorded[0].KeyPrefix)
+		assert.Equal(t, "198.51.100.7", recorded[0].ClientIP)
+		assert.Equal(t, "https://app.example.com", recorded[0].Origin)
+		assert.Contains(t, recorded[0].Reason, "198.51.100.7")
+		assert.NotZero(t, recorded[0].Timestamp)
+		assert.Zero(t, numConsumed)
+	})
+	t.Run("request without origin should be rejected", func(t *testing.T) {
+		t.Parallel()
+
+		recorded := make([]common.KeyViolation, 0)
+		numConsumed := 0
+		instance, _ := NewAccessChecker(createArgs(&recorded, &numConsumed))
+
+		_, err := instance.ShouldProcessRequest(createTestRequest(context.Background(), make(http.Header), "/v1/restricted1234/network/config"))
+		assert.ErrorIs(t, err, errKeyRestricted)
+		assert.Len(t, recorded, 1)
+		assert.Empty(t, recorded[0].Origin)
+		assert.Zero(t, numConsumed)
 	})
 }
@@ -0,0 +1,94 @@
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+type bodyValuesExtractor struct {
+	maxBodySize uint64
+	paths       map[string]struct{}
+}
+
+// NewBodyValuesExtractor creates a new body values extractor able to read the routing values (block nonce, hint epoch)
+// from the JSON body of the requests sent on the configured paths
+func NewBodyValuesExtractor(cfg config.BodyRoutingConfig) (*bodyValuesExtractor, error) {
+	if len(cfg.Paths) > 0 && cfg.MaxBodySizeInBytes == 0 {
+		return nil, errZeroMaxBodySize
+	}
+
+	paths := make(map[string]struct{}, len(cfg.Paths))
+	for _, path := range cfg.Paths {
+		paths[path] = struct{}{}
+	}
+
+	return &bodyValuesExtractor{
+		maxBodySize: cfg.MaxBodySizeInBytes,
+		paths:       paths,
+	}, nil
+}
+
+// ExtractValues returns the routing values found in the JSON body of the request together with a reader that will
+// provide the complete, unchanged body. The body is inspected only if the request path is one of the configured paths
+// and the body size does not exceed the configured maximum. Malformed bodies are not inspected.
+func (extractor *bodyValuesExtractor) ExtractValues(requestPath string, body io.Reader) (map[string][]string, io.Reader, error) {
+	values := make(map[string][]string)
+	_, found := extractor.paths[requestPath]
+	if !found || body == nil {
+		return values, body, nil
+	}
+
+	buffered, err := io.ReadAll(io.LimitReader(body, int64(extractor.maxBodySize)+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if uint64(len(buffered)) > extractor.maxBodySize {
+		log.Trace("request body too large to be inspected", "path", requestPath, "max size", extractor.maxBodySize)
+		return values, io.MultiReader(bytes.NewReader(buffered), body), nil
+	}
+
+	fields := make(map[string]json.RawMessage)
+	err = json.Unmarshal(buffered, &fields)
+	if err != nil {
+		log.Trace("request body is not a JSON object", "path", requestPath, "error", err)
+		return values, bytes.NewReader(buffered), nil
+	}
+
+	for _, key := range []string{UrlParameterBlockNonce, UrlParameterHintEpoch} {
+		value, isValid := parseUint64Field(fields[key])
+		if isValid {
+			values[key] = []string{value}
+		}
+	}
+
+	return values, bytes.NewReader(buffered), nil
+}
+
+// parseUint64Field accepts both JSON numbers (123) and numeric strings ("123")
+func parseUint64Field(field json.RawMessage) (string, bool) {
+	if len(field) == 0 {
+		return "", false
+	}
+
+	var stringValue string
+	err := json.Unmarshal(field, &stringValue)
+	if err != nil {
+		stringValue = string(field)
+	}
+
+	_, err = strconv.ParseUint(stringValue, 10, 64)
+	if err != nil {
+		return "", false
+	}
+
+	return stringValue, true
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (extractor *bodyValuesExtractor) IsInterfaceNil() bool {
+	return extractor == nil
+}
@@ -0,0 +1,169 @@
+package process
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func createBodyRoutingConfig() config.BodyRoutingConfig {
+	return config.BodyRoutingConfig{
+		MaxBodySizeInBytes: 100,
+		Paths: []string{
+			"/vm-values/query",
+			"/vm-values/int",
+			"/vm-values/string",
+			"/vm-values/hex",
+		},
+	}
+}
+
+func TestNewBodyValuesExtractor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("paths with 0 max body size should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createBodyRoutingConfig()
+		cfg.MaxBodySizeInBytes = 0
+		extractor, err := NewBodyValuesExtractor(cfg)
+		assert.Equal(t, errZeroMaxBodySize, err)
+		assert.True(t, extractor.IsInterfaceNil())
+	})
+	t.Run("empty config should work", func(t *testing.T) {
+		t.Parallel()
+
+		extractor, err := NewBodyValuesExtractor(config.BodyRoutingConfig{})
+		assert.Nil(t, err)
+		assert.False(t, extractor.IsInterfaceNil())
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		extractor, err := NewBodyValuesExtractor(createBodyRoutingConfig())
+		assert.Nil(t, err)
+		assert.False(t, extractor.IsInterfaceNil())
+	})
+}
+
+func TestBodyValuesExtractor_ExtractValues(t *testing.T) {
+	t.Parallel()
+
+	extractor, _ := NewBodyValuesExtractor(createBodyRoutingConfig())
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		expectedValues map[string][]string
+	}{
+		{
+			name:           "numeric block nonce",
+			path:           "/vm-values/query",
+			body:           `{"scAddress":"erd1","funcName":"get","blockNonce":123}`,
+			expectedValues: map[string][]string{UrlParameterBlockNonce: {"123"}},
+		},
+		{
+			name:           "string block nonce",
+			path:           "/vm-values/int",
+			body:           `{"scAddress":"erd1","funcName":"get","blockNonce":"123"}`,
+			expectedValues: map[string][]string{UrlParameterBlockNonce: {"123"}},
+		},
+		{
+			name:           "hint epoch",
+			path:           "/vm-values/string",
+			body:           `{"scAddress":"erd1","hintEpoch":1400}`,
+			expectedValues: map[string][]string{UrlParameterHintEpoch: {"1400"}},
+		},
+		{
+			name:           "both values",
+			path:           "/vm-values/hex",
+			body:           `{"blockNonce":10,"hintEpoch":1}`,
+			expectedValues: map[string][]string{UrlParameterBlockNonce: {"10"}, UrlParameterHintEpoch: {"1"}},
+		},
+		{
+			name:           "negative value is ignored",
+			path:           "/vm-values/query",
+			body:           `{"blockNonce":-1}`,
+			expectedValues: map[string][]string{},
+		},
+		{
+			name:           "float value is ignored",
+			path:           "/vm-values/query",
+			body:           `{"blockNonce":1.5}`,
+			expectedValues: map[string][]string{},
+		},
+		{
+			name:           "non numeric value is ignored",
+			path:           "/vm-values/query",
+			body:           `{"blockNonce":"latest","hintEpoch":null}`,
+			expectedValues: map[string][]string{},
+		},
+		{
+			name:           "nested value is ignored",
+			path:           "/vm-values/query",
+			body:           `{"options":{"blockNonce":5}}`,
+			expectedValues: map[string][]string{},
+		},
+		{
+			name:           "malformed JSON",
+			path:           "/vm-values/query",
+			body:           `{"blockNonce":5`,
+			expectedValues: map[string][]string{},
+		},
+		{
+			name:           "JSON array",
+			path:           "/vm-values/query",
+			body:           `[{"blockNonce":5}]`,
+			expectedValues: map[string][]string{},
+		},
+		{
+			name:           "empty body",
+			path:           "/vm-values/query",
+			body:           ``,
+			expectedValues: map[string][]string{},
+		},
+		{
+			name:           "body too large",
+			path:           "/vm-values/query",
+			body:           `{"blockNonce":5,"args":["` + strings.Repeat("a", 100) + `"]}`,
+			expectedValues: map[string][]string{},
+		},
+		{
+			name:           "path not configured",
+			path:           "/transaction/cost",
+			body:           `{"blockNonce":5}`,
+			expectedValues: map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			values, body, err := extractor.ExtractValues(tt.path, strings.NewReader(tt.body))
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expectedValues, values)
+
+			forwardedBody, err := io.ReadAll(body)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.body, string(forwardedBody))
+		})
+	}
+}
+
+func TestBodyValuesExtractor_ExtractValuesReadErrorShouldError(t *testing.T) {
+	t.Parallel()
+
+	expectedErr := errors.New("expected error")
+	extractor, _ := NewBodyValuesExtractor(createBodyRoutingConfig())
+	values, body, err := extractor.ExtractValues("/vm-values/query", iotest.ErrReader(expectedErr))
+	assert.Equal(t, expectedErr, err)
+	assert.Nil(t, values)
+	assert.Nil(t, body)
+}
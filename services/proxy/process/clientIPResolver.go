@@ -0,0 +1,142 @@
+package process
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+const (
+	headerCFConnectingIP = "CF-Connecting-IP"
+	headerRealIP         = "X-Real-IP"
+)
+
+var defaultClientIPHeaders = []string{headerCFConnectingIP, headerForwardedFor, headerRealIP}
+
+type clientIPResolver struct {
+	trustedProxies []netip.Prefix
+	headers        []string
+}
+
+// NewClientIPResolver creates the component resolving the IP of the clients. The configured headers are only read
+// from the requests sent by one of the trusted proxies, so the clients can not spoof their IP.
+func NewClientIPResolver(cfg config.ClientIPConfig) (*clientIPResolver, error) {
+	resolver := &clientIPResolver{
+		trustedProxies: make([]netip.Prefix, 0, len(cfg.TrustedProxies)),
+	}
+	for _, trustedProxy := range cfg.TrustedProxies {
+		prefix, err := parseIPOrCIDR(strings.TrimSpace(trustedProxy))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errInvalidTrustedProxy, trustedProxy)
+		}
+
+		resolver.trustedProxies = append(resolver.trustedProxies, prefix)
+	}
+
+	headers := cfg.Headers
+	if len(headers) == 0 {
+		headers = defaultClientIPHeaders
+	}
+	for _, header := range headers {
+		header = strings.TrimSpace(header)
+		if len(header) == 0 {
+			return nil, errInvalidHeaderName
+		}
+
+		resolver.headers = append(resolver.headers, http.CanonicalHeaderKey(header))
+	}
+
+	return resolver, nil
+}
+
+// ResolveClientIP returns the IP of the client that sent the request. For the requests sent by a trusted proxy, the IP
+// is read from the first configured header holding a valid one. The X-Forwarded-For header is read from right to
+// left, the first IP that is not a trusted proxy being the client's. Otherwise, the connection's remote address is used.
+func (resolver *clientIPResolver) ResolveClientIP(request *http.Request) string {
+	peerIP := remoteIP(request)
+	if !resolver.isTrustedProxy(peerIP) {
+		return peerIP
+	}
+
+	for _, header := range resolver.headers {
+		clientIP, found := resolver.readHeader(request.Header, header)
+		if found {
+			return clientIP
+		}
+	}
+
+	return peerIP
+}
+
+func (resolver *clientIPResolver) readHeader(header http.Header, name string) (string, bool) {
+	if name != headerForwardedFor {
+		addr, err := netip.ParseAddr(strings.TrimSpace(header.Get(name)))
+		if err != nil {
+			return "", false
+		}
+
+		return addr.Unmap().String(), true
+	}
+
+	hops := strings.Split(strings.Join(header.Values(name), ","), ",")
+	clientIP := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// the invalid value was not written by a trusted proxy, the header can not be used
+			return "", false
+		}
+
+		clientIP = addr.Unmap().String()
+		if !resolver.isTrustedProxy(clientIP) {
+			return clientIP, true
+		}
+	}
+
+	// all the valid hops are trusted proxies, the leftmost one being the closest to the client
+	return clientIP, len(clientIP) > 0
+}
+
+func (resolver *clientIPResolver) isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range resolver.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (resolver *clientIPResolver) IsInterfaceNil() bool {
+	return resolver == nil
+}
+
+// parseIPOrCIDR returns the CIDR holding the provided IP or the provided CIDR
+func parseIPOrCIDR(value string) (netip.Prefix, error) {
+	if !strings.Contains(value, "/") {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap()
+
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	return prefix.Masked(), nil
+}
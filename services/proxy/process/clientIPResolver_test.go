@@ -0,0 +1,132 @@
+package process
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func createClientIPTestRequest(remoteAddr string, headers map[string][]string) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, "/address/erd1", nil)
+	request.RemoteAddr = remoteAddr
+	for name, values := range headers {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+
+	return request
+}
+
+func TestNewClientIPResolver(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid trusted proxy should error", func(t *testing.T) {
+		t.Parallel()
+
+		resolver, err := NewClientIPResolver(config.ClientIPConfig{
+			TrustedProxies: []string{"127.0.0.1", "10.0.0.0/33"},
+		})
+		assert.Nil(t, resolver)
+		assert.True(t, resolver.IsInterfaceNil())
+		assert.True(t, errors.Is(err, errInvalidTrustedProxy))
+		assert.Contains(t, err.Error(), "10.0.0.0/33")
+	})
+	t.Run("empty header should error", func(t *testing.T) {
+		t.Parallel()
+
+		resolver, err := NewClientIPResolver(config.ClientIPConfig{
+			Headers: []string{"X-Real-IP", " "},
+		})
+		assert.Nil(t, resolver)
+		assert.Equal(t, errInvalidHeaderName, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		resolver, err := NewClientIPResolver(config.ClientIPConfig{
+			TrustedProxies: []string{" 127.0.0.1 ", "10.0.0.0/8", "::1"},
+		})
+		assert.NotNil(t, resolver)
+		assert.False(t, resolver.IsInterfaceNil())
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"Cf-Connecting-Ip", "X-Forwarded-For", "X-Real-Ip"}, resolver.headers)
+	})
+}
+
+func TestClientIPResolver_ResolveClientIP(t *testing.T) {
+	t.Parallel()
+
+	resolver, _ := NewClientIPResolver(config.ClientIPConfig{
+		TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8", "::1"},
+	})
+
+	t.Run("untrusted peer should not be able to spoof its IP", func(t *testing.T) {
+		t.Parallel()
+
+		request := createClientIPTestRequest("203.0.113.9:1234", map[string][]string{
+			headerCFConnectingIP: {"198.51.100.1"},
+			headerForwardedFor:   {"198.51.100.2"},
+			headerRealIP:         {"198.51.100.3"},
+		})
+		assert.Equal(t, "203.0.113.9", resolver.ResolveClientIP(request))
+	})
+	t.Run("trusted peer without headers should use the remote address", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, "127.0.0.1", resolver.ResolveClientIP(createClientIPTestRequest("127.0.0.1:1234", nil)))
+		assert.Equal(t, "::1", resolver.ResolveClientIP(createClientIPTestRequest("[::1]:1234", nil)))
+	})
+	t.Run("the headers should be read in the configured order", func(t *testing.T) {
+		t.Parallel()
+
+		request := createClientIPTestRequest("127.0.0.1:1234", map[string][]string{
+			headerCFConnectingIP: {"198.51.100.1"},
+			headerForwardedFor:   {"198.51.100.2"},
+			headerRealIP:         {"198.51.100.3"},
+		})
+		assert.Equal(t, "198.51.100.1", resolver.ResolveClientIP(request))
+
+		request = createClientIPTestRequest("127.0.0.1:1234", map[string][]string{
+			headerCFConnectingIP: {"not an IP"},
+			headerRealIP:         {"198.51.100.3"},
+		})
+		assert.Equal(t, "198.51.100.3", resolver.ResolveClientIP(request))
+
+		customResolver, _ := NewClientIPResolver(config.ClientIPConfig{
+			TrustedProxies: []string{"127.0.0.1"},
+			Headers:        []string{"x-real-ip"},
+		})
+		request = createClientIPTestRequest("127.0.0.1:1234", map[string][]string{
+			headerCFConnectingIP: {"198.51.100.1"},
+			headerRealIP:         {"::ffff:198.51.100.3"},
+		})
+		assert.Equal(t, "198.51.100.3", customResolver.ResolveClientIP(request))
+	})
+	t.Run("X-Forwarded-For should be read from right to left", func(t *testing.T) {
+		t.Parallel()
+
+		// the first hop is set by the client and can not be trusted
+		request := createClientIPTestRequest("127.0.0.1:1234", map[string][]string{
+			headerForwardedFor: {"1.1.1.1, 198.51.100.2", "10.0.0.2, 10.0.0.1"},
+		})
+		assert.Equal(t, "198.51.100.2", resolver.ResolveClientIP(request))
+
+		// all the hops are trusted proxies
+		request = createClientIPTestRequest("127.0.0.1:1234", map[string][]string{
+			headerForwardedFor: {"10.0.0.3, 10.0.0.2"},
+		})
+		assert.Equal(t, "10.0.0.3", resolver.ResolveClientIP(request))
+
+		// an invalid hop makes the header unusable
+		request = createClientIPTestRequest("127.0.0.1:1234", map[string][]string{
+			headerForwardedFor: {"198.51.100.2, unknown, 10.0.0.1"},
+			headerRealIP:       {"198.51.100.3"},
+		})
+		assert.Equal(t, "198.51.100.3", resolver.ResolveClientIP(request))
+	})
+}
@@ -2,7 +2,9 @@ package process
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
+	"strings"
 
 	logger "github.com/multiversx/mx-chain-logger-go"
 )
@@ -13,12 +15,23 @@ const UrlParameterBlockNonce = "blockNonce"
 // UrlParameterHintEpoch represents the name of a URL parameter
 const UrlParameterHintEpoch = "hintEpoch"
 
+// UrlParameterAtTimestamp represents the name of a URL parameter
+const UrlParameterAtTimestamp = "atTimestamp"
+
 // ReturnCode defines the type defines to identify return codes
 type ReturnCode string
 
 const (
 	// ReturnCodeRequestError defines a request which hasn't been executed successfully due to a bad request received
 	ReturnCodeRequestError ReturnCode = "bad_request"
+	// ReturnCodeUnauthorized defines a request which hasn't been executed as the access key is missing or not valid
+	ReturnCodeUnauthorized ReturnCode = "unauthorized"
+	// ReturnCodeForbidden defines a request which hasn't been executed as the account is not allowed to call the endpoint
+	ReturnCodeForbidden ReturnCode = "forbidden"
+	// ReturnCodeTooManyRequests defines a request which hasn't been executed as the account exceeded its quota
+	ReturnCodeTooManyRequests ReturnCode = "too_many_requests"
+	// ReturnCodeInternalError defines a request which hasn't been executed successfully due to an internal error
+	ReturnCodeInternalError ReturnCode = "internal_issue"
 )
 const loggerName = "process"
 
@@ -34,16 +47,31 @@ type GenericAPIResponse struct {
 
 // RespondWithError should be called when the request cannot be satisfied due to an internal error
 func RespondWithError(writer http.ResponseWriter, err error, statusCode int) {
-	writer.WriteHeader(statusCode)
 	writeContentType(writer, jsonContentType)
+	writer.WriteHeader(statusCode)
 
-	trySendResponse(writer, err)
+	trySendResponse(writer, err, getReturnCode(statusCode))
 }
 
-func trySendResponse(writer http.ResponseWriter, err error) {
+func getReturnCode(statusCode int) ReturnCode {
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		return ReturnCodeUnauthorized
+	case statusCode == http.StatusForbidden:
+		return ReturnCodeForbidden
+	case statusCode == http.StatusTooManyRequests:
+		return ReturnCodeTooManyRequests
+	case statusCode >= http.StatusInternalServerError:
+		return ReturnCodeInternalError
+	default:
+		return ReturnCodeRequestError
+	}
+}
+
+func trySendResponse(writer http.ResponseWriter, err error, code ReturnCode) {
 	response := &GenericAPIResponse{
 		Error: err.Error(),
-		Code:  ReturnCodeRequestError,
+		Code:  code,
 	}
 
 	jsonBytes, errJson := json.Marshal(response)
@@ -61,3 +89,24 @@ func writeContentType(w http.ResponseWriter, value []string) {
 		header["Content-Type"] = value
 	}
 }
+
+// isPathUnderEndpoints returns true if the path is one of the provided endpoints or a path under one of them
+func isPathUnderEndpoints(requestPath string, endpoints []string) bool {
+	for _, endpoint := range endpoints {
+		if requestPath == endpoint || strings.HasPrefix(requestPath, endpoint+uriSeparator) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remoteIP returns the IP address of the client directly connected to the proxy
+func remoteIP(request *http.Request) string {
+	clientIP, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+
+	return clientIP
+}
@@ -0,0 +1,94 @@
+package process
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+const defaultCreditCost = uint64(1)
+
+type creditCost struct {
+	pattern pathPattern
+	method  string
+	cost    uint64
+}
+
+type creditCostTable struct {
+	costs []creditCost
+}
+
+// NewCreditCostTable creates the table deciding how many credits each request consumes. The entries are checked in
+// the configured order, the first one matching the request's method and path giving the cost.
+func NewCreditCostTable(cfg []config.CreditCostConfig) (*creditCostTable, error) {
+	costs := make([]creditCost, 0, len(cfg))
+	for i, costConfig := range cfg {
+		pattern := strings.TrimSpace(costConfig.Pattern)
+		if !strings.HasPrefix(pattern, pathSeparator) {
+			return nil, fmt.Errorf("%w for the credit cost at index %d: %s", errInvalidPathPattern, i, costConfig.Pattern)
+		}
+		if costConfig.Cost == 0 {
+			return nil, fmt.Errorf("%w: 0 cost at index %d", errInvalidCreditCost, i)
+		}
+
+		method := strings.ToUpper(strings.TrimSpace(costConfig.Method))
+		if len(method) > 0 && !common.IsKnownHTTPMethod(method) {
+			return nil, fmt.Errorf("%w: unknown method %s at index %d", errInvalidCreditCost, costConfig.Method, i)
+		}
+
+		costs = append(costs, creditCost{
+			pattern: pathPattern{
+				pattern:  pattern,
+				segments: strings.Split(pattern, pathSeparator),
+			},
+			method: method,
+			cost:   costConfig.Cost,
+		})
+	}
+
+	return &creditCostTable{
+		costs: costs,
+	}, nil
+}
+
+// GetCost returns the number of credits consumed by a request with the provided method and path
+func (table *creditCostTable) GetCost(method string, requestPath string) uint64 {
+	pathSegments := strings.Split(requestPath, pathSeparator)
+	for _, entry := range table.costs {
+		if len(entry.method) > 0 && entry.method != method {
+			continue
+		}
+		if matchesPathPattern(entry.pattern, pathSegments) {
+			return entry.cost
+		}
+	}
+
+	return defaultCreditCost
+}
+
+func matchesPathPattern(pattern pathPattern, pathSegments []string) bool {
+	if len(pattern.segments) != len(pathSegments) {
+		return false
+	}
+
+	for i, patternSegment := range pattern.segments {
+		if isPlaceholder(patternSegment) {
+			if len(pathSegments[i]) == 0 {
+				return false
+			}
+			continue
+		}
+		if patternSegment != pathSegments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (table *creditCostTable) IsInterfaceNil() bool {
+	return table == nil
+}
@@ -0,0 +1,67 @@
+package process
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCreditCostTable(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid pattern should error", func(t *testing.T) {
+		t.Parallel()
+
+		table, err := NewCreditCostTable([]config.CreditCostConfig{{Pattern: "address/{address}/keys", Cost: 10}})
+		assert.Nil(t, table)
+		assert.True(t, errors.Is(err, errInvalidPathPattern))
+		assert.Contains(t, err.Error(), "index 0")
+	})
+	t.Run("0 cost should error", func(t *testing.T) {
+		t.Parallel()
+
+		table, err := NewCreditCostTable([]config.CreditCostConfig{{Pattern: "/address/{address}/keys"}})
+		assert.Nil(t, table)
+		assert.True(t, errors.Is(err, errInvalidCreditCost))
+	})
+	t.Run("unknown method should error", func(t *testing.T) {
+		t.Parallel()
+
+		table, err := NewCreditCostTable([]config.CreditCostConfig{{Pattern: "/transaction/send", Method: "SEND", Cost: 2}})
+		assert.Nil(t, table)
+		assert.True(t, errors.Is(err, errInvalidCreditCost))
+		assert.Contains(t, err.Error(), "SEND")
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		table, err := NewCreditCostTable(nil)
+		assert.Nil(t, err)
+		assert.False(t, table.IsInterfaceNil())
+	})
+}
+
+func TestCreditCostTable_GetCost(t *testing.T) {
+	t.Parallel()
+
+	table, _ := NewCreditCostTable([]config.CreditCostConfig{
+		{Pattern: "/address/{address}/keys", Cost: 100},
+		{Pattern: "/transaction/send", Method: "post", Cost: 5},
+		{Pattern: "/transaction/{hash}", Cost: 3},
+		{Pattern: "/transaction/send", Cost: 50},
+	})
+
+	assert.Equal(t, uint64(100), table.GetCost(http.MethodGet, "/address/erd1/keys"))
+	assert.Equal(t, uint64(5), table.GetCost(http.MethodPost, "/transaction/send"))
+	// the first matching entry gives the cost
+	assert.Equal(t, uint64(3), table.GetCost(http.MethodGet, "/transaction/send"))
+	assert.Equal(t, uint64(3), table.GetCost(http.MethodGet, "/transaction/aabb"))
+
+	assert.Equal(t, defaultCreditCost, table.GetCost(http.MethodGet, "/address/erd1"))
+	assert.Equal(t, defaultCreditCost, table.GetCost(http.MethodGet, "/address//keys"))
+	assert.Equal(t, defaultCreditCost, table.GetCost(http.MethodGet, "/address/erd1/keys/aa"))
+	assert.Equal(t, defaultCreditCost, table.GetCost(http.MethodGet, "/network/config"))
+}
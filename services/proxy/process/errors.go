@@ -17,7 +17,65 @@ var errUnauthorized = errors.New("unauthorized")
 var errNilAccessChecker = errors.New("nil access checker")
 var errNilKeyAccessChecker = errors.New("nil key access checker")
 var errNilKeyCounter = errors.New("nil key counter")
-var errTooManyRequestsForFreeAccount = errors.New("too many requests for free account")
+var errTooManyRequests = errors.New("too many requests")
 var errUnexpectedStatusCode = errors.New("unexpected status code")
 var errNilStorer = errors.New("nil storer")
 var errNilCryptoClient = errors.New("nil crypto client")
+var errUnknownLoadBalancer = errors.New("unknown load balancer")
+var errEmptyReplicaURL = errors.New("empty replica URL")
+var errNilGatewaysHealthProvider = errors.New("nil gateways health provider")
+var errNilGatewayProber = errors.New("nil gateway prober")
+var errNoHealthyGateway = errors.New("no healthy gateway available")
+var errNilConfigLoader = errors.New("nil config loader")
+var errNilGatewaysTester = errors.New("nil gateways tester")
+var errNilPathValuesExtractor = errors.New("nil path values extractor")
+var errInvalidPathPattern = errors.New("invalid path pattern, should start with /")
+var errZeroRoundsPerEpoch = errors.New("the {round} placeholder requires a non-zero RoundsPerEpoch value")
+var errNoValuePlaceholder = errors.New("the path pattern should contain at least one {nonce}, {epoch} or {round} placeholder")
+var errZeroMaxBodySize = errors.New("the body routing paths require a non-zero MaxBodySizeInBytes value")
+var errNilBodyValuesExtractor = errors.New("nil body values extractor")
+var errNilHashEpochStorer = errors.New("nil hash epoch storer")
+var errNilGatewayRequester = errors.New("nil gateway requester")
+var errNilHashEpochResolver = errors.New("nil hash epoch resolver")
+var errNilEpochStartsStorer = errors.New("nil epoch starts storer")
+var errNilTimestampResolver = errors.New("nil timestamp resolver")
+var errInvalidTimestamp = errors.New("invalid timestamp")
+var errTimestampOutOfRange = errors.New("timestamp out of range")
+var errTimestampRoutingNotReady = errors.New("the epoch start timestamps were not learned yet")
+var errEpochStartNotAvailable = errors.New("the epoch start is not available")
+var errNilGatewaysDiscoverer = errors.New("nil gateways discoverer")
+var errInvalidRetryableStatusCode = errors.New("invalid retryable status code")
+var errNilRetryPolicy = errors.New("nil retry policy")
+var errUnknownFallbackGateway = errors.New("unknown fallback gateway")
+var errNoAlternativeGateway = errors.New("no alternative gateway available")
+var errNilUpstreamClient = errors.New("nil upstream client")
+var errNilResponseCache = errors.New("nil response cache")
+var errNilRequestsCoalescer = errors.New("nil requests coalescer")
+var errInvalidMaxResponseSize = errors.New("invalid maximum response size")
+var errNilHeadersPolicy = errors.New("nil headers policy")
+var errInvalidHeaderName = errors.New("invalid header name")
+var errInvalidPathRewrite = errors.New("invalid path rewrite")
+var errInvalidBasicAuth = errors.New("invalid basic auth credentials")
+var errNilRateLimiter = errors.New("nil rate limiter")
+var errUnknownRateLimiter = errors.New("unknown rate limiter")
+var errTierRateNeedsTokenBucket = errors.New("the tier rate fields are only applied by the token-bucket rate limiter")
+var errInvalidTokenBucket = errors.New("invalid token bucket")
+var errDuplicatedAccountType = errors.New("duplicated account type")
+var errNilSlowLane = errors.New("nil slow lane")
+var errInvalidSlowLaneConfig = errors.New("invalid slow lane config")
+var errSlowLaneDisabled = errors.New("slow lane is disabled")
+var errSlowLaneQueueFull = errors.New("slow lane queue is full")
+var errSlowLaneMaxWaitExceeded = errors.New("slow lane maximum wait exceeded")
+var errNilTiersPolicy = errors.New("nil tiers policy")
+var errInvalidTierConfig = errors.New("invalid tier config")
+var errDuplicatedTier = errors.New("duplicated tier")
+var errEndpointNotAllowed = errors.New("endpoint not allowed")
+var errTooManyConcurrentRequests = errors.New("too many concurrent requests")
+var errNilCreditCostTable = errors.New("nil credit cost table")
+var errInvalidCreditCost = errors.New("invalid credit cost")
+var errKeyOutOfScope = errors.New("request out of the key scope")
+var errKeyRestricted = errors.New("request rejected by the key restrictions")
+var errNilKeyViolationsRecorder = errors.New("nil key violations recorder")
+var errZeroKeyViolationsCapacity = errors.New("zero key violations capacity")
+var errInvalidTrustedProxy = errors.New("invalid trusted proxy")
+var errNilClientIPResolver = errors.New("nil client IP resolver")
@@ -0,0 +1,86 @@
+package process
+
+import (
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+type fixedWindowLimiter struct {
+	counter        KeyCounter
+	maxCalls       uint64
+	windowDuration time.Duration
+	getTimeHandler func() time.Time
+
+	mut         sync.RWMutex
+	windowStart time.Time
+}
+
+// NewFixedWindowLimiter creates a rate limiter that allows maxCalls requests for each free account in a time window.
+// The other account types are not limited. A new window starts each time Sweep is called.
+func NewFixedWindowLimiter(counter KeyCounter, maxCalls uint64, windowDuration time.Duration) (*fixedWindowLimiter, error) {
+	if check.IfNil(counter) {
+		return nil, errNilKeyCounter
+	}
+
+	return &fixedWindowLimiter{
+		counter:        counter,
+		maxCalls:       maxCalls,
+		windowDuration: windowDuration,
+		getTimeHandler: time.Now,
+		windowStart:    time.Now(),
+	}, nil
+}
+
+// Allow counts the request of a free account and allows it if the window's quota is not exceeded
+func (limiter *fixedWindowLimiter) Allow(username string, accountType common.AccountType) common.RateLimitResult {
+	if accountType != common.FreeAccountType {
+		return common.RateLimitResult{
+			Allowed: true,
+		}
+	}
+
+	currentCounter := limiter.counter.IncrementReturningCurrent(username)
+
+	limiter.mut.RLock()
+	windowEnd := limiter.windowStart.Add(limiter.windowDuration)
+	limiter.mut.RUnlock()
+
+	resetAfter := windowEnd.Sub(limiter.getTimeHandler())
+	if resetAfter < 0 {
+		resetAfter = 0
+	}
+
+	if currentCounter <= limiter.maxCalls {
+		return common.RateLimitResult{
+			Allowed:    true,
+			Limit:      limiter.maxCalls,
+			Remaining:  limiter.maxCalls - currentCounter,
+			ResetAfter: resetAfter,
+		}
+	}
+
+	return common.RateLimitResult{
+		Allowed:    false,
+		Limit:      limiter.maxCalls,
+		Remaining:  0,
+		ResetAfter: resetAfter,
+		RetryAfter: resetAfter,
+	}
+}
+
+// Sweep clears the counters, starting a new window
+func (limiter *fixedWindowLimiter) Sweep() {
+	limiter.mut.Lock()
+	limiter.windowStart = limiter.getTimeHandler()
+	limiter.mut.Unlock()
+
+	limiter.counter.Clear()
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (limiter *fixedWindowLimiter) IsInterfaceNil() bool {
+	return limiter == nil
+}
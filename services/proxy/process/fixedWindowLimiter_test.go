@@ -0,0 +1,83 @@
+package process
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFixedWindowLimiter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil key counter should error", func(t *testing.T) {
+		t.Parallel()
+
+		limiter, err := NewFixedWindowLimiter(nil, 10, time.Minute)
+		assert.Nil(t, limiter)
+		assert.Equal(t, errNilKeyCounter, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		limiter, err := NewFixedWindowLimiter(&testscommon.KeyCounterStub{}, 10, time.Minute)
+		assert.Nil(t, err)
+		assert.False(t, limiter.IsInterfaceNil())
+	})
+}
+
+func TestFixedWindowLimiter_Allow(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not free accounts should not be counted", func(t *testing.T) {
+		t.Parallel()
+
+		limiter, _ := NewFixedWindowLimiter(&testscommon.KeyCounterStub{
+			IncrementReturningCurrentHandler: func(key string) uint64 {
+				assert.Fail(t, "should have not been called")
+				return 0
+			},
+		}, 10, time.Minute)
+
+		result := limiter.Allow("user", common.PremiumAccountType)
+		assert.Equal(t, common.RateLimitResult{Allowed: true}, result)
+	})
+	t.Run("free accounts should be limited until the window ends", func(t *testing.T) {
+		t.Parallel()
+
+		clock := newTestClock()
+		limiter, _ := NewFixedWindowLimiter(common.NewKeyCounter(), 2, time.Minute)
+		limiter.getTimeHandler = clock.now
+		limiter.Sweep()
+
+		expectedResult := common.RateLimitResult{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Minute}
+		assert.Equal(t, expectedResult, limiter.Allow("user", common.FreeAccountType))
+		expectedResult.Remaining = 0
+		assert.Equal(t, expectedResult, limiter.Allow("user", common.FreeAccountType))
+
+		clock.advance(20 * time.Second)
+		result := limiter.Allow("user", common.FreeAccountType)
+		expectedResult = common.RateLimitResult{
+			Allowed:    false,
+			Limit:      2,
+			Remaining:  0,
+			ResetAfter: 40 * time.Second,
+			RetryAfter: 40 * time.Second,
+		}
+		assert.Equal(t, expectedResult, result)
+
+		// other accounts have their own counters
+		assert.True(t, limiter.Allow("user2", common.FreeAccountType).Allowed)
+
+		clock.advance(time.Minute)
+		result = limiter.Allow("user", common.FreeAccountType)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, time.Duration(0), result.ResetAfter)
+		assert.Equal(t, time.Duration(0), result.RetryAfter)
+
+		limiter.Sweep()
+		assert.True(t, limiter.Allow("user", common.FreeAccountType).Allowed)
+	})
+}
@@ -0,0 +1,353 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+const (
+	// RangeIssueGap marks a range of epochs not held by any gateway
+	RangeIssueGap = "gap"
+	// RangeIssueOverlap marks a range of epochs held by more than one gateway
+	RangeIssueOverlap = "overlap"
+	// RangeIssueUnreachable marks a gateway that could not be probed, so it is not used
+	RangeIssueUnreachable = "unreachable"
+)
+
+type gatewayRange struct {
+	cfg        config.GatewayConfig
+	epochStart uint64
+	epochEnd   uint64
+	nonceStart uint64
+	nonceEnd   uint64
+}
+
+// ArgsGatewaysDiscoverer is the DTO used to create a new gateways discoverer
+type ArgsGatewaysDiscoverer struct {
+	Enabled   bool
+	ShardID   uint32
+	Requester GatewayRequester
+}
+
+type gatewaysDiscoverer struct {
+	enabled   bool
+	shardID   uint32
+	requester GatewayRequester
+
+	mut    sync.RWMutex
+	report common.GatewaysDiscoveryReport
+}
+
+// NewGatewaysDiscoverer creates a new gateways discoverer able to complete the gateway entries that only define
+// their URL by asking the gateways which epochs and nonces they hold
+func NewGatewaysDiscoverer(args ArgsGatewaysDiscoverer) (*gatewaysDiscoverer, error) {
+	if check.IfNil(args.Requester) {
+		return nil, errNilGatewayRequester
+	}
+
+	return &gatewaysDiscoverer{
+		enabled:   args.Enabled,
+		shardID:   args.ShardID,
+		requester: args.Requester,
+		report: common.GatewaysDiscoveryReport{
+			Gateways: make([]config.GatewayConfig, 0),
+			Issues:   make([]common.GatewayRangeIssue, 0),
+		},
+	}, nil
+}
+
+// DiscoverRanges returns the gateways with their epoch and nonce ranges filled. The gateways that only define their
+// URL are probed, then all the ranges are assembled in a contiguous table. The gaps and overlaps are fixed by
+// extending or trimming the neighbouring ranges, and are logged and reported. If the discovery is disabled or no
+// gateway needs discovery, the provided gateways are returned unchanged.
+func (discoverer *gatewaysDiscoverer) DiscoverRanges(gateways []config.GatewayConfig) ([]config.GatewayConfig, error) {
+	if !discoverer.enabled || !hasDiscoveryEntries(gateways) {
+		return gateways, nil
+	}
+
+	issues := make([]common.GatewayRangeIssue, 0)
+	ranges := make([]*gatewayRange, 0, len(gateways))
+	for i, gateway := range gateways {
+		if len(gateway.Name) == 0 {
+			gateway.Name = gateway.URL
+		}
+
+		if !isDiscoveryEntry(gateway) {
+			configuredRange, err := convertConfiguredRange(gateway)
+			if err != nil {
+				return nil, fmt.Errorf("%w at index %d with URL %s", err, i, gateway.URL)
+			}
+
+			ranges = append(ranges, configuredRange)
+			continue
+		}
+
+		discoveredRange, err := discoverer.probeRange(gateway)
+		if err != nil {
+			issues = append(issues, common.GatewayRangeIssue{
+				Type:    RangeIssueUnreachable,
+				Gateway: gateway.Name,
+				Details: err.Error(),
+			})
+			continue
+		}
+
+		ranges = append(ranges, discoveredRange)
+	}
+
+	ranges, tableIssues := buildContiguousTable(ranges)
+	issues = append(issues, tableIssues...)
+
+	result := convertRangesToGateways(ranges)
+	for _, issue := range issues {
+		log.Warn("gateways discovery issue", "type", issue.Type, "gateway", issue.Gateway, "details", issue.Details)
+	}
+	for _, gateway := range result {
+		log.Debug("discovered gateway range", "gateway", gateway.Name, "URL", gateway.URL,
+			"epochs", gateway.EpochStart+" - "+gateway.EpochEnd, "nonces", gateway.NonceStart+" - "+gateway.NonceEnd)
+	}
+
+	discoverer.mut.Lock()
+	discoverer.report = common.GatewaysDiscoveryReport{
+		Timestamp: time.Now().Unix(),
+		Gateways:  result,
+		Issues:    issues,
+	}
+	discoverer.mut.Unlock()
+
+	return result, nil
+}
+
+func hasDiscoveryEntries(gateways []config.GatewayConfig) bool {
+	for _, gateway := range gateways {
+		if isDiscoveryEntry(gateway) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isDiscoveryEntry returns true if the gateway only defines its URL, without any epoch or nonce value
+func isDiscoveryEntry(gateway config.GatewayConfig) bool {
+	return len(gateway.EpochStart)+len(gateway.EpochEnd)+len(gateway.NonceStart)+len(gateway.NonceEnd) == 0
+}
+
+func convertConfiguredRange(gateway config.GatewayConfig) (*gatewayRange, error) {
+	result := &gatewayRange{
+		cfg:      gateway,
+		epochEnd: math.MaxUint64,
+		nonceEnd: math.MaxUint64,
+	}
+
+	var err error
+	result.epochStart, err = strconv.ParseUint(gateway.EpochStart, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w for epoch start", err)
+	}
+	result.nonceStart, err = strconv.ParseUint(gateway.NonceStart, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w for nonce start", err)
+	}
+
+	if strings.ToLower(gateway.EpochEnd) == latestMarker && strings.ToLower(gateway.NonceEnd) == latestMarker {
+		return result, nil
+	}
+
+	result.epochEnd, err = strconv.ParseUint(gateway.EpochEnd, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w for epoch end", err)
+	}
+	result.nonceEnd, err = strconv.ParseUint(gateway.NonceEnd, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w for nonce end", err)
+	}
+
+	return result, nil
+}
+
+// probeRange asks the gateway for its current epoch & nonce, then searches the first epoch it holds, assuming the
+// gateway holds all the epochs between the first one and the current one
+func (discoverer *gatewaysDiscoverer) probeRange(gateway config.GatewayConfig) (*gatewayRange, error) {
+	status := &networkStatusResponse{}
+	route := fmt.Sprintf("/network/status/%d", discoverer.shardID)
+	err := discoverer.requester.DoGatewayRequest(context.Background(), gateway, route, status)
+	if err != nil {
+		return nil, err
+	}
+
+	currentEpoch := status.Data.Status.EpochNumber
+	firstEpochStart, found := discoverer.fetchEpochStart(gateway, currentEpoch)
+	if !found {
+		return nil, fmt.Errorf("%w, epoch %d", errEpochStartNotAvailable, currentEpoch)
+	}
+
+	// binary search for the first epoch held by the gateway
+	firstEpoch := currentEpoch
+	low, high := uint64(0), currentEpoch
+	for low < high {
+		middle := low + (high-low)/2
+		epochStart, available := discoverer.fetchEpochStart(gateway, middle)
+		if available {
+			high = middle
+			firstEpoch = middle
+			firstEpochStart = epochStart
+			continue
+		}
+
+		low = middle + 1
+	}
+
+	return &gatewayRange{
+		cfg:        gateway,
+		epochStart: firstEpoch,
+		epochEnd:   currentEpoch,
+		nonceStart: firstEpochStart.Nonce,
+		nonceEnd:   status.Data.Status.Nonce,
+	}, nil
+}
+
+func (discoverer *gatewaysDiscoverer) fetchEpochStart(gateway config.GatewayConfig, epoch uint64) (common.EpochStartInfo, bool) {
+	response := &epochStartResponse{}
+	route := fmt.Sprintf("/network/epoch-start/%d/by-epoch/%d", discoverer.shardID, epoch)
+	err := discoverer.requester.DoGatewayRequest(context.Background(), gateway, route, response)
+	if err != nil {
+		return common.EpochStartInfo{}, false
+	}
+
+	return common.EpochStartInfo{
+		Epoch:     epoch,
+		Nonce:     response.Data.EpochStart.Nonce,
+		Timestamp: response.Data.EpochStart.Timestamp,
+	}, true
+}
+
+// buildContiguousTable orders the ranges and removes the gaps and overlaps between them: a gap is covered by the
+// previous range, an overlapping range starts after the previous one and a range fully covered by the previous one
+// is dropped. The first range is extended to start from epoch 0.
+func buildContiguousTable(ranges []*gatewayRange) ([]*gatewayRange, []common.GatewayRangeIssue) {
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].epochStart == ranges[j].epochStart {
+			return ranges[i].epochEnd < ranges[j].epochEnd
+		}
+
+		return ranges[i].epochStart < ranges[j].epochStart
+	})
+
+	issues := make([]common.GatewayRangeIssue, 0)
+	result := make([]*gatewayRange, 0, len(ranges))
+	for _, current := range ranges {
+		if len(result) == 0 {
+			if current.epochStart > 0 {
+				issues = append(issues, common.GatewayRangeIssue{
+					Type:    RangeIssueGap,
+					Gateway: current.cfg.Name,
+					Details: fmt.Sprintf("epochs 0 - %d are not held by any gateway", current.epochStart-1),
+				})
+				current.epochStart = 0
+				current.nonceStart = 0
+			}
+
+			result = append(result, current)
+			continue
+		}
+
+		previous := result[len(result)-1]
+		if current.epochEnd <= previous.epochEnd {
+			issues = append(issues, common.GatewayRangeIssue{
+				Type:    RangeIssueOverlap,
+				Gateway: current.cfg.Name,
+				Details: fmt.Sprintf("epochs %d - %d are fully held by %s, the gateway is not used",
+					current.epochStart, current.epochEnd, previous.cfg.Name),
+			})
+			continue
+		}
+
+		switch {
+		case current.epochStart > previous.epochEnd+1:
+			issues = append(issues, common.GatewayRangeIssue{
+				Type:    RangeIssueGap,
+				Gateway: current.cfg.Name,
+				Details: fmt.Sprintf("epochs %d - %d are not held by any gateway, routed to %s",
+					previous.epochEnd+1, current.epochStart-1, previous.cfg.Name),
+			})
+			previous.epochEnd = current.epochStart - 1
+		case current.epochStart <= previous.epochEnd:
+			issues = append(issues, common.GatewayRangeIssue{
+				Type:    RangeIssueOverlap,
+				Gateway: current.cfg.Name,
+				Details: fmt.Sprintf("epochs %d - %d are also held by %s, routed to %s",
+					current.epochStart, previous.epochEnd, previous.cfg.Name, previous.cfg.Name),
+			})
+			current.epochStart = previous.epochEnd + 1
+			current.nonceStart = previous.nonceEnd + 1
+		case previous.nonceEnd+1 != current.nonceStart:
+			issues = append(issues, common.GatewayRangeIssue{
+				Type:    nonceIssueType(previous, current),
+				Gateway: current.cfg.Name,
+				Details: fmt.Sprintf("the nonces of %s end at %d while the nonces of %s start at %d",
+					previous.cfg.Name, previous.nonceEnd, current.cfg.Name, current.nonceStart),
+			})
+		}
+
+		// the epoch start block of the current range is the reference for the nonces
+		previous.nonceEnd = current.nonceStart - 1
+		result = append(result, current)
+	}
+
+	return result, issues
+}
+
+func nonceIssueType(previous *gatewayRange, current *gatewayRange) string {
+	if current.nonceStart > previous.nonceEnd {
+		return RangeIssueGap
+	}
+
+	return RangeIssueOverlap
+}
+
+// convertRangesToGateways converts the ranges in gateway configs. The last range is marked as holding the latest data,
+// unless a configured gateway already holds the latest data.
+func convertRangesToGateways(ranges []*gatewayRange) []config.GatewayConfig {
+	result := make([]config.GatewayConfig, 0, len(ranges))
+	for i, gatewayRange := range ranges {
+		cfg := gatewayRange.cfg
+		cfg.EpochStart = strconv.FormatUint(gatewayRange.epochStart, 10)
+		cfg.NonceStart = strconv.FormatUint(gatewayRange.nonceStart, 10)
+		cfg.EpochEnd = strconv.FormatUint(gatewayRange.epochEnd, 10)
+		cfg.NonceEnd = strconv.FormatUint(gatewayRange.nonceEnd, 10)
+
+		isLast := i == len(ranges)-1
+		if isLast || gatewayRange.epochEnd == math.MaxUint64 {
+			cfg.EpochEnd = latestMarker
+			cfg.NonceEnd = latestMarker
+		}
+
+		result = append(result, cfg)
+	}
+
+	return result
+}
+
+// GetDiscoveryReport returns the result of the last discovery
+func (discoverer *gatewaysDiscoverer) GetDiscoveryReport() common.GatewaysDiscoveryReport {
+	discoverer.mut.RLock()
+	defer discoverer.mut.RUnlock()
+
+	return discoverer.report
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (discoverer *gatewaysDiscoverer) IsInterfaceNil() bool {
+	return discoverer == nil
+}
@@ -0,0 +1,296 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/multiversx/mx-chain-core-go/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testObserverData struct {
+	firstEpoch   uint64
+	currentEpoch uint64
+}
+
+// createObserversRequester simulates gateways holding contiguous and complete epochs, each epoch having 1000 blocks
+func createObserversRequester(observers map[string]testObserverData) *testscommon.GatewayRequesterStub {
+	return &testscommon.GatewayRequesterStub{
+		DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+			url := gateway.URL + route
+			for gatewayURL, data := range observers {
+				if !strings.HasPrefix(url, gatewayURL+"/") {
+					continue
+				}
+
+				switch response := result.(type) {
+				case *networkStatusResponse:
+					response.Data.Status.EpochNumber = data.currentEpoch
+					response.Data.Status.Nonce = data.currentEpoch*1000 + 999
+					return nil
+				case *epochStartResponse:
+					var epoch uint64
+					_, _ = fmt.Sscanf(url[strings.LastIndex(url, "/")+1:], "%d", &epoch)
+					if epoch < data.firstEpoch || epoch > data.currentEpoch {
+						return errors.New("epoch not available")
+					}
+					response.Data.EpochStart.Nonce = epoch * 1000
+					return nil
+				}
+			}
+
+			return errors.New("connection refused")
+		},
+	}
+}
+
+func createMockArgsGatewaysDiscoverer() ArgsGatewaysDiscoverer {
+	return ArgsGatewaysDiscoverer{
+		Enabled: true,
+		ShardID: core.MetachainShardId,
+		Requester: createObserversRequester(map[string]testObserverData{
+			"http://old":    {firstEpoch: 0, currentEpoch: 99},
+			"http://middle": {firstEpoch: 100, currentEpoch: 199},
+			"http://latest": {firstEpoch: 200, currentEpoch: 250},
+		}),
+	}
+}
+
+func TestNewGatewaysDiscoverer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil requester should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysDiscoverer()
+		args.Requester = nil
+		discoverer, err := NewGatewaysDiscoverer(args)
+		assert.Nil(t, discoverer)
+		assert.True(t, discoverer.IsInterfaceNil())
+		assert.Equal(t, errNilGatewayRequester, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		discoverer, err := NewGatewaysDiscoverer(createMockArgsGatewaysDiscoverer())
+		assert.NotNil(t, discoverer)
+		assert.False(t, discoverer.IsInterfaceNil())
+		assert.Nil(t, err)
+
+		report := discoverer.GetDiscoveryReport()
+		assert.Empty(t, report.Gateways)
+		assert.Empty(t, report.Issues)
+	})
+}
+
+func TestGatewaysDiscoverer_DiscoverRanges(t *testing.T) {
+	t.Parallel()
+
+	t.Run("disabled discovery should return the gateways unchanged", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysDiscoverer()
+		args.Enabled = false
+		args.Requester = &testscommon.GatewayRequesterStub{
+			DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+				require.Fail(t, "should have not probed the gateways")
+				return nil
+			},
+		}
+		discoverer, _ := NewGatewaysDiscoverer(args)
+
+		gateways := []config.GatewayConfig{{URL: "http://latest"}}
+		result, err := discoverer.DiscoverRanges(gateways)
+		assert.Nil(t, err)
+		assert.Equal(t, gateways, result)
+	})
+	t.Run("configured gateways only should be returned unchanged", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysDiscoverer()
+		args.Requester = &testscommon.GatewayRequesterStub{
+			DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+				require.Fail(t, "should have not probed the gateways")
+				return nil
+			},
+		}
+		discoverer, _ := NewGatewaysDiscoverer(args)
+
+		gateways := createTestConfigs()
+		result, err := discoverer.DiscoverRanges(gateways)
+		assert.Nil(t, err)
+		assert.Equal(t, gateways, result)
+	})
+	t.Run("should discover the ranges of the URL only gateways", func(t *testing.T) {
+		t.Parallel()
+
+		discoverer, _ := NewGatewaysDiscoverer(createMockArgsGatewaysDiscoverer())
+
+		gateways := []config.GatewayConfig{
+			{URL: "http://latest", Name: "latest"},
+			{URL: "http://old"},
+			{URL: "http://middle", Name: "middle", LoadBalancer: LoadBalancerWeighted},
+		}
+		result, err := discoverer.DiscoverRanges(gateways)
+		assert.Nil(t, err)
+
+		expected := []config.GatewayConfig{
+			{URL: "http://old", Name: "http://old", EpochStart: "0", EpochEnd: "99", NonceStart: "0", NonceEnd: "99999"},
+			{URL: "http://middle", Name: "middle", EpochStart: "100", EpochEnd: "199", NonceStart: "100000", NonceEnd: "199999", LoadBalancer: LoadBalancerWeighted},
+			{URL: "http://latest", Name: "latest", EpochStart: "200", EpochEnd: "latest", NonceStart: "200000", NonceEnd: "latest"},
+		}
+		assert.Equal(t, expected, result)
+
+		report := discoverer.GetDiscoveryReport()
+		assert.Equal(t, expected, report.Gateways)
+		assert.Empty(t, report.Issues)
+		assert.NotZero(t, report.Timestamp)
+
+		hostsFinder, err := NewHostsFinder(result, &testscommon.GatewaysHealthProviderStub{})
+		require.Nil(t, err)
+		host, _ := hostsFinder.FindHost(map[string][]string{UrlParameterBlockNonce: {"150000"}})
+		assert.Equal(t, "http://middle", host.URL)
+	})
+	t.Run("should mix configured and discovered gateways", func(t *testing.T) {
+		t.Parallel()
+
+		discoverer, _ := NewGatewaysDiscoverer(createMockArgsGatewaysDiscoverer())
+
+		gateways := []config.GatewayConfig{
+			{URL: "http://old", Name: "old", EpochStart: "0", EpochEnd: "99", NonceStart: "0", NonceEnd: "99999"},
+			{URL: "http://middle", Name: "middle"},
+			{URL: "http://latest", Name: "latest", EpochStart: "200", EpochEnd: "Latest", NonceStart: "200000", NonceEnd: "LATEST"},
+		}
+		result, err := discoverer.DiscoverRanges(gateways)
+		assert.Nil(t, err)
+
+		expected := []config.GatewayConfig{
+			{URL: "http://old", Name: "old", EpochStart: "0", EpochEnd: "99", NonceStart: "0", NonceEnd: "99999"},
+			{URL: "http://middle", Name: "middle", EpochStart: "100", EpochEnd: "199", NonceStart: "100000", NonceEnd: "199999"},
+			{URL: "http://latest", Name: "latest", EpochStart: "200", EpochEnd: "latest", NonceStart: "200000", NonceEnd: "latest"},
+		}
+		assert.Equal(t, expected, result)
+		assert.Empty(t, discoverer.GetDiscoveryReport().Issues)
+	})
+	t.Run("invalid configured gateway should error", func(t *testing.T) {
+		t.Parallel()
+
+		discoverer, _ := NewGatewaysDiscoverer(createMockArgsGatewaysDiscoverer())
+
+		gateways := []config.GatewayConfig{
+			{URL: "http://old", Name: "old", EpochStart: "0", EpochEnd: "NaN", NonceStart: "0", NonceEnd: "99999"},
+			{URL: "http://latest", Name: "latest"},
+		}
+		result, err := discoverer.DiscoverRanges(gateways)
+		assert.Nil(t, result)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "for epoch end at index 0 with URL http://old")
+	})
+	t.Run("should report and fix the gaps, overlaps and unreachable gateways", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysDiscoverer()
+		args.Requester = createObserversRequester(map[string]testObserverData{
+			"http://first":     {firstEpoch: 10, currentEpoch: 99},
+			"http://gap":       {firstEpoch: 120, currentEpoch: 199},
+			"http://overlap":   {firstEpoch: 190, currentEpoch: 299},
+			"http://contained": {firstEpoch: 200, currentEpoch: 210},
+			"http://live":      {firstEpoch: 300, currentEpoch: 350},
+		})
+		discoverer, _ := NewGatewaysDiscoverer(args)
+
+		gateways := []config.GatewayConfig{
+			{URL: "http://first", Name: "first"},
+			{URL: "http://gap", Name: "gap"},
+			{URL: "http://overlap", Name: "overlap"},
+			{URL: "http://contained", Name: "contained"},
+			{URL: "http://down", Name: "down"},
+			{URL: "http://live", Name: "live"},
+		}
+		result, err := discoverer.DiscoverRanges(gateways)
+		assert.Nil(t, err)
+
+		expected := []config.GatewayConfig{
+			{URL: "http://first", Name: "first", EpochStart: "0", EpochEnd: "119", NonceStart: "0", NonceEnd: "119999"},
+			{URL: "http://gap", Name: "gap", EpochStart: "120", EpochEnd: "199", NonceStart: "120000", NonceEnd: "199999"},
+			{URL: "http://overlap", Name: "overlap", EpochStart: "200", EpochEnd: "299", NonceStart: "200000", NonceEnd: "299999"},
+			{URL: "http://live", Name: "live", EpochStart: "300", EpochEnd: "latest", NonceStart: "300000", NonceEnd: "latest"},
+		}
+		assert.Equal(t, expected, result)
+
+		_, err = NewHostsFinder(result, &testscommon.GatewaysHealthProviderStub{})
+		assert.Nil(t, err)
+
+		issues := discoverer.GetDiscoveryReport().Issues
+		require.Equal(t, 5, len(issues))
+		assert.Equal(t, common.GatewayRangeIssue{
+			Type:    RangeIssueUnreachable,
+			Gateway: "down",
+			Details: "connection refused",
+		}, issues[0])
+		assert.Equal(t, common.GatewayRangeIssue{
+			Type:    RangeIssueGap,
+			Gateway: "first",
+			Details: "epochs 0 - 9 are not held by any gateway",
+		}, issues[1])
+		assert.Equal(t, common.GatewayRangeIssue{
+			Type:    RangeIssueGap,
+			Gateway: "gap",
+			Details: "epochs 100 - 119 are not held by any gateway, routed to first",
+		}, issues[2])
+		assert.Equal(t, common.GatewayRangeIssue{
+			Type:    RangeIssueOverlap,
+			Gateway: "overlap",
+			Details: "epochs 190 - 199 are also held by gap, routed to gap",
+		}, issues[3])
+		assert.Equal(t, common.GatewayRangeIssue{
+			Type:    RangeIssueOverlap,
+			Gateway: "contained",
+			Details: "epochs 200 - 210 are fully held by overlap, the gateway is not used",
+		}, issues[4])
+	})
+	t.Run("should report the nonces mismatch", func(t *testing.T) {
+		t.Parallel()
+
+		discoverer, _ := NewGatewaysDiscoverer(createMockArgsGatewaysDiscoverer())
+
+		gateways := []config.GatewayConfig{
+			{URL: "http://old", Name: "old", EpochStart: "0", EpochEnd: "99", NonceStart: "0", NonceEnd: "99990"},
+			{URL: "http://middle", Name: "middle"},
+		}
+		result, err := discoverer.DiscoverRanges(gateways)
+		assert.Nil(t, err)
+
+		expected := []config.GatewayConfig{
+			{URL: "http://old", Name: "old", EpochStart: "0", EpochEnd: "99", NonceStart: "0", NonceEnd: "99999"},
+			{URL: "http://middle", Name: "middle", EpochStart: "100", EpochEnd: "latest", NonceStart: "100000", NonceEnd: "latest"},
+		}
+		assert.Equal(t, expected, result)
+
+		expectedIssues := []common.GatewayRangeIssue{
+			{
+				Type:    RangeIssueGap,
+				Gateway: "middle",
+				Details: "the nonces of old end at 99990 while the nonces of middle start at 100000",
+			},
+		}
+		assert.Equal(t, expectedIssues, discoverer.GetDiscoveryReport().Issues)
+	})
+	t.Run("all gateways unreachable should return an empty list", func(t *testing.T) {
+		t.Parallel()
+
+		discoverer, _ := NewGatewaysDiscoverer(createMockArgsGatewaysDiscoverer())
+
+		result, err := discoverer.DiscoverRanges([]config.GatewayConfig{{URL: "http://down"}})
+		assert.Nil(t, err)
+		assert.Empty(t, result)
+		assert.Equal(t, 1, len(discoverer.GetDiscoveryReport().Issues))
+	})
+}
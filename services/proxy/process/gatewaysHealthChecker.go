@@ -0,0 +1,164 @@
+package process
+
+import (
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// ArgsGatewaysHealthChecker is the DTO used to create a new gateways health checker
+type ArgsGatewaysHealthChecker struct {
+	Prober             GatewayProber
+	UnhealthyThreshold uint32
+	HealthyThreshold   uint32
+}
+
+type gatewaysHealthChecker struct {
+	prober             GatewayProber
+	unhealthyThreshold uint32
+	healthyThreshold   uint32
+
+	mut    sync.RWMutex
+	states map[string]*common.GatewayHealthStatus
+	order  []string
+}
+
+// NewGatewaysHealthChecker creates a new gateways health checker. The state of a gateway changes only after the
+// configured number of consecutive probes (hysteresis) so a single failed probe does not take a gateway out of rotation.
+// Thresholds lower than 1 are treated as 1.
+func NewGatewaysHealthChecker(args ArgsGatewaysHealthChecker) (*gatewaysHealthChecker, error) {
+	if check.IfNil(args.Prober) {
+		return nil, errNilGatewayProber
+	}
+
+	return &gatewaysHealthChecker{
+		prober:             args.Prober,
+		unhealthyThreshold: max(args.UnhealthyThreshold, 1),
+		healthyThreshold:   max(args.HealthyThreshold, 1),
+		states:             make(map[string]*common.GatewayHealthStatus),
+	}, nil
+}
+
+// CheckGateways will probe all the provided gateways (including their replicas) and update their health state.
+// The gateways that are no longer provided are removed from the tracked set.
+func (checker *gatewaysHealthChecker) CheckGateways(gateways []config.GatewayConfig) {
+	targets := make([]common.GatewayHealthStatus, 0, len(gateways))
+	// the replicas are probed with the upstream settings (headers, credentials) of their gateway
+	upstreams := make([]config.GatewayConfig, 0, len(gateways))
+	for _, gateway := range gateways {
+		for _, replica := range createReplicas(gateway) {
+			targets = append(targets, common.GatewayHealthStatus{
+				Name:       replica.Name,
+				URL:        replica.URL,
+				EpochStart: gateway.EpochStart,
+				EpochEnd:   gateway.EpochEnd,
+			})
+
+			upstream := gateway
+			upstream.URL = replica.URL
+			upstream.Name = replica.Name
+			upstreams = append(upstreams, upstream)
+		}
+	}
+
+	probeResults := make([]error, len(targets))
+	wg := sync.WaitGroup{}
+	wg.Add(len(targets))
+	for i := range targets {
+		go func(index int) {
+			probeResults[index] = checker.prober.ProbeGateway(upstreams[index])
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+
+	checker.mut.Lock()
+	defer checker.mut.Unlock()
+
+	newStates := make(map[string]*common.GatewayHealthStatus, len(targets))
+	order := make([]string, 0, len(targets))
+	for i, target := range targets {
+		state, found := newStates[target.URL]
+		if found {
+			// the same URL is used by more than one range, it was already probed & updated
+			continue
+		}
+
+		state, found = checker.states[target.URL]
+		if !found {
+			state = &common.GatewayHealthStatus{
+				IsHealthy: true,
+			}
+		}
+		state.Name = target.Name
+		state.URL = target.URL
+		state.EpochStart = target.EpochStart
+		state.EpochEnd = target.EpochEnd
+
+		checker.updateState(state, probeResults[i])
+
+		newStates[target.URL] = state
+		order = append(order, target.URL)
+	}
+
+	checker.states = newStates
+	checker.order = order
+}
+
+func (checker *gatewaysHealthChecker) updateState(state *common.GatewayHealthStatus, probeErr error) {
+	state.LastCheckTimestamp = time.Now().Unix()
+
+	if probeErr == nil {
+		state.LastError = ""
+		state.ConsecutiveFailures = 0
+		state.ConsecutiveSuccesses++
+		if !state.IsHealthy && state.ConsecutiveSuccesses >= checker.healthyThreshold {
+			log.Info("gateway is healthy again", "name", state.Name, "URL", state.URL)
+			state.IsHealthy = true
+		}
+
+		return
+	}
+
+	state.LastError = probeErr.Error()
+	state.ConsecutiveSuccesses = 0
+	state.ConsecutiveFailures++
+	if state.IsHealthy && state.ConsecutiveFailures >= checker.unhealthyThreshold {
+		log.Warn("gateway marked as unhealthy", "name", state.Name, "URL", state.URL, "error", probeErr)
+		state.IsHealthy = false
+	}
+}
+
+// IsHealthy returns true if the provided gateway URL is healthy. Gateways that were not probed yet are considered healthy.
+func (checker *gatewaysHealthChecker) IsHealthy(url string) bool {
+	checker.mut.RLock()
+	defer checker.mut.RUnlock()
+
+	state, found := checker.states[url]
+	if !found {
+		return true
+	}
+
+	return state.IsHealthy
+}
+
+// GetHealthStatus returns the health state of all gateways probed during the last check
+func (checker *gatewaysHealthChecker) GetHealthStatus() []common.GatewayHealthStatus {
+	checker.mut.RLock()
+	defer checker.mut.RUnlock()
+
+	result := make([]common.GatewayHealthStatus, 0, len(checker.order))
+	for _, url := range checker.order {
+		result = append(result, *checker.states[url])
+	}
+
+	return result
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (checker *gatewaysHealthChecker) IsInterfaceNil() bool {
+	return checker == nil
+}
@@ -0,0 +1,216 @@
+package process
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/stretchr/testify/assert"
+)
+
+func createHealthCheckerTestGateways() []config.GatewayConfig {
+	return []config.GatewayConfig{
+		{
+			URL:        "URL1",
+			Name:       "gateway1",
+			EpochStart: "0",
+			EpochEnd:   "100",
+			Replicas: []config.ReplicaConfig{
+				{URL: "URL1-A", Name: "gateway1-A"},
+			},
+		},
+		{
+			URL:        "URL2",
+			Name:       "gateway2",
+			EpochStart: "101",
+			EpochEnd:   "latest",
+		},
+	}
+}
+
+func TestNewGatewaysHealthChecker(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil prober should error", func(t *testing.T) {
+		t.Parallel()
+
+		checker, err := NewGatewaysHealthChecker(ArgsGatewaysHealthChecker{})
+		assert.Equal(t, errNilGatewayProber, err)
+		assert.Nil(t, checker)
+		assert.True(t, checker.IsInterfaceNil())
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		checker, err := NewGatewaysHealthChecker(ArgsGatewaysHealthChecker{
+			Prober: &testscommon.GatewayProberStub{},
+		})
+		assert.Nil(t, err)
+		assert.False(t, checker.IsInterfaceNil())
+		assert.Equal(t, uint32(1), checker.unhealthyThreshold)
+		assert.Equal(t, uint32(1), checker.healthyThreshold)
+		assert.True(t, checker.IsHealthy("unknown URL"))
+		assert.Empty(t, checker.GetHealthStatus())
+	})
+}
+
+func TestGatewaysHealthChecker_CheckGateways(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should probe all replicas", func(t *testing.T) {
+		t.Parallel()
+
+		mut := sync.Mutex{}
+		probed := make([]string, 0)
+		checker, _ := NewGatewaysHealthChecker(ArgsGatewaysHealthChecker{
+			Prober: &testscommon.GatewayProberStub{
+				ProbeGatewayCalled: func(upstream config.GatewayConfig) error {
+					mut.Lock()
+					probed = append(probed, upstream.URL)
+					mut.Unlock()
+
+					return nil
+				},
+			},
+		})
+
+		checker.CheckGateways(createHealthCheckerTestGateways())
+		assert.ElementsMatch(t, []string{"URL1", "URL1-A", "URL2"}, probed)
+
+		status := checker.GetHealthStatus()
+		assert.Equal(t, 3, len(status))
+		assert.Equal(t, "gateway1", status[0].Name)
+		assert.Equal(t, "gateway1-A", status[1].Name)
+		assert.Equal(t, "100", status[1].EpochEnd)
+		assert.Equal(t, "gateway2", status[2].Name)
+		for _, s := range status {
+			assert.True(t, s.IsHealthy)
+			assert.Equal(t, uint32(1), s.ConsecutiveSuccesses)
+			assert.NotZero(t, s.LastCheckTimestamp)
+		}
+	})
+	t.Run("replicas should be probed with the upstream settings of their gateway", func(t *testing.T) {
+		t.Parallel()
+
+		mut := sync.Mutex{}
+		probed := make(map[string]config.GatewayConfig)
+		checker, _ := NewGatewaysHealthChecker(ArgsGatewaysHealthChecker{
+			Prober: &testscommon.GatewayProberStub{
+				ProbeGatewayCalled: func(upstream config.GatewayConfig) error {
+					mut.Lock()
+					probed[upstream.URL] = upstream
+					mut.Unlock()
+
+					return nil
+				},
+			},
+		})
+
+		gateways := createHealthCheckerTestGateways()
+		gateways[0].Headers = map[string]string{"Authorization": "Bearer token"}
+		gateways[0].BasicAuthUsername = "user"
+		checker.CheckGateways(gateways)
+
+		assert.Equal(t, 3, len(probed))
+		for _, url := range []string{"URL1", "URL1-A"} {
+			assert.Equal(t, gateways[0].Headers, probed[url].Headers)
+			assert.Equal(t, "user", probed[url].BasicAuthUsername)
+		}
+		assert.Equal(t, "gateway1-A", probed["URL1-A"].Name)
+		assert.Empty(t, probed["URL2"].Headers)
+	})
+	t.Run("hysteresis should apply", func(t *testing.T) {
+		t.Parallel()
+
+		mut := sync.Mutex{}
+		failingURLs := map[string]bool{"URL1-A": true}
+		checker, _ := NewGatewaysHealthChecker(ArgsGatewaysHealthChecker{
+			Prober: &testscommon.GatewayProberStub{
+				ProbeGatewayCalled: func(upstream config.GatewayConfig) error {
+					mut.Lock()
+					defer mut.Unlock()
+
+					if failingURLs[upstream.URL] {
+						return errors.New("connection refused")
+					}
+					return nil
+				},
+			},
+			UnhealthyThreshold: 3,
+			HealthyThreshold:   2,
+		})
+
+		gateways := createHealthCheckerTestGateways()
+		checker.CheckGateways(gateways)
+		checker.CheckGateways(gateways)
+		assert.True(t, checker.IsHealthy("URL1-A"))
+
+		checker.CheckGateways(gateways)
+		assert.False(t, checker.IsHealthy("URL1-A"))
+		assert.True(t, checker.IsHealthy("URL1"))
+		assert.True(t, checker.IsHealthy("URL2"))
+
+		status := checker.GetHealthStatus()
+		assert.Equal(t, uint32(3), status[1].ConsecutiveFailures)
+		assert.Equal(t, "connection refused", status[1].LastError)
+
+		mut.Lock()
+		failingURLs = make(map[string]bool)
+		mut.Unlock()
+
+		checker.CheckGateways(gateways)
+		assert.False(t, checker.IsHealthy("URL1-A"))
+
+		checker.CheckGateways(gateways)
+		assert.True(t, checker.IsHealthy("URL1-A"))
+
+		status = checker.GetHealthStatus()
+		assert.Equal(t, uint32(0), status[1].ConsecutiveFailures)
+		assert.Equal(t, uint32(2), status[1].ConsecutiveSuccesses)
+		assert.Empty(t, status[1].LastError)
+	})
+	t.Run("a successful probe should reset the failures counter", func(t *testing.T) {
+		t.Parallel()
+
+		numCalls := 0
+		checker, _ := NewGatewaysHealthChecker(ArgsGatewaysHealthChecker{
+			Prober: &testscommon.GatewayProberStub{
+				ProbeGatewayCalled: func(upstream config.GatewayConfig) error {
+					numCalls++
+					if numCalls%2 == 0 {
+						return nil
+					}
+					return errors.New("flaky")
+				},
+			},
+			UnhealthyThreshold: 2,
+		})
+
+		gateways := []config.GatewayConfig{{URL: "URL1"}}
+		for i := 0; i < 10; i++ {
+			checker.CheckGateways(gateways)
+			assert.True(t, checker.IsHealthy("URL1"))
+		}
+	})
+	t.Run("removed gateways should not be tracked anymore", func(t *testing.T) {
+		t.Parallel()
+
+		checker, _ := NewGatewaysHealthChecker(ArgsGatewaysHealthChecker{
+			Prober: &testscommon.GatewayProberStub{
+				ProbeGatewayCalled: func(upstream config.GatewayConfig) error {
+					return errors.New("down")
+				},
+			},
+		})
+
+		gateways := createHealthCheckerTestGateways()
+		checker.CheckGateways(gateways)
+		assert.False(t, checker.IsHealthy("URL2"))
+
+		checker.CheckGateways(gateways[:1])
+		assert.True(t, checker.IsHealthy("URL2"))
+		assert.Equal(t, 2, len(checker.GetHealthStatus()))
+	})
+}
@@ -0,0 +1,154 @@
+package process
+
+import (
+	"sync"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// ArgsGatewaysReloader is the DTO used to create a new gateways reloader
+type ArgsGatewaysReloader struct {
+	ConfigLoader       func() (config.Config, error)
+	ConfiguredGateways []config.GatewayConfig
+	HostFinder         SwappableHostFinder
+	Tester             GatewaysTester
+	HealthProvider     GatewaysHealthProvider
+	Discoverer         GatewaysDiscoverer
+}
+
+type gatewaysReloader struct {
+	mut                sync.Mutex
+	configLoader       func() (config.Config, error)
+	configuredGateways []config.GatewayConfig
+	hostFinder         SwappableHostFinder
+	tester             GatewaysTester
+	healthProvider     GatewaysHealthProvider
+	discoverer         GatewaysDiscoverer
+}
+
+// NewGatewaysReloader creates a new gateways reloader instance
+func NewGatewaysReloader(args ArgsGatewaysReloader) (*gatewaysReloader, error) {
+	if args.ConfigLoader == nil {
+		return nil, errNilConfigLoader
+	}
+	if check.IfNil(args.HostFinder) {
+		return nil, errNilHostsFinder
+	}
+	if check.IfNil(args.Tester) {
+		return nil, errNilGatewaysTester
+	}
+	if check.IfNil(args.HealthProvider) {
+		return nil, errNilGatewaysHealthProvider
+	}
+	if check.IfNil(args.Discoverer) {
+		return nil, errNilGatewaysDiscoverer
+	}
+
+	return &gatewaysReloader{
+		configLoader:       args.ConfigLoader,
+		configuredGateways: args.ConfiguredGateways,
+		hostFinder:         args.HostFinder,
+		tester:             args.Tester,
+		healthProvider:     args.HealthProvider,
+		discoverer:         args.Discoverer,
+	}, nil
+}
+
+// Reload loads the configuration, validates & probes the new gateways and, if everything is correct, swaps the
+// host finder used to serve the requests. On any error the current gateways are kept.
+func (reloader *gatewaysReloader) Reload() ([]config.GatewayConfig, error) {
+	reloader.mut.Lock()
+	defer reloader.mut.Unlock()
+
+	cfg, err := reloader.configLoader()
+	if err != nil {
+		return nil, err
+	}
+
+	loadedGateways, err := reloader.applyGateways(cfg.Gateways)
+	if err != nil {
+		return nil, err
+	}
+
+	reloader.configuredGateways = cfg.Gateways
+	log.Info("gateways reloaded", "num gateways", len(loadedGateways))
+
+	return loadedGateways, nil
+}
+
+// Rediscover runs again the ranges discovery on the currently configured gateways and swaps the host finder used to
+// serve the requests. The gateways that do not respond are left out, so they do not keep the ranges of the other
+// gateways from being updated. On any error the current gateways are kept.
+func (reloader *gatewaysReloader) Rediscover() ([]config.GatewayConfig, error) {
+	reloader.mut.Lock()
+	defer reloader.mut.Unlock()
+
+	gateways, err := reloader.discoverer.DiscoverRanges(reloader.respondingGateways(reloader.configuredGateways))
+	if err != nil {
+		return nil, err
+	}
+
+	newHostFinder, err := NewHostsFinder(gateways, reloader.healthProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	err = reloader.hostFinder.Swap(newHostFinder)
+	if err != nil {
+		return nil, err
+	}
+
+	loadedGateways := newHostFinder.LoadedGateways()
+	log.Debug("gateways rediscovered", "num gateways", len(loadedGateways))
+
+	return loadedGateways, nil
+}
+
+// respondingGateways returns the provided gateways without the ones that do not respond to the probes, including the
+// gateways with a replica that does not respond
+func (reloader *gatewaysReloader) respondingGateways(gateways []config.GatewayConfig) []config.GatewayConfig {
+	result := make([]config.GatewayConfig, 0, len(gateways))
+	for _, gateway := range gateways {
+		err := reloader.tester.TestGateways([]config.GatewayConfig{gateway})
+		if err != nil {
+			log.Warn("gateway not responding, it is left out of the rediscovered gateways",
+				"gateway", gateway.Name, "URL", gateway.URL, "error", err)
+			continue
+		}
+
+		result = append(result, gateway)
+	}
+
+	return result
+}
+
+func (reloader *gatewaysReloader) applyGateways(configuredGateways []config.GatewayConfig) ([]config.GatewayConfig, error) {
+	gateways, err := reloader.discoverer.DiscoverRanges(configuredGateways)
+	if err != nil {
+		return nil, err
+	}
+
+	newHostFinder, err := NewHostsFinder(gateways, reloader.healthProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	loadedGateways := newHostFinder.LoadedGateways()
+	err = reloader.tester.TestGateways(loadedGateways)
+	if err != nil {
+		return nil, err
+	}
+
+	err = reloader.hostFinder.Swap(newHostFinder)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadedGateways, nil
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (reloader *gatewaysReloader) IsInterfaceNil() bool {
+	return reloader == nil
+}
@@ -0,0 +1,356 @@
+package process
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/stretchr/testify/assert"
+)
+
+func createMockArgsGatewaysReloader(loadedConfig config.Config) ArgsGatewaysReloader {
+	initialHostFinder, _ := NewHostsFinder(createTestConfigs(), &testscommon.GatewaysHealthProviderStub{})
+	switchable, _ := NewSwitchableHostFinder(initialHostFinder)
+
+	return ArgsGatewaysReloader{
+		ConfigLoader: func() (config.Config, error) {
+			return loadedConfig, nil
+		},
+		ConfiguredGateways: createTestConfigs(),
+		HostFinder:         switchable,
+		Tester:             &testscommon.GatewaysTesterStub{},
+		HealthProvider:     &testscommon.GatewaysHealthProviderStub{},
+		Discoverer:         &testscommon.GatewaysDiscovererStub{},
+	}
+}
+
+func createReloadedTestConfig() config.Config {
+	return config.Config{
+		Gateways: []config.GatewayConfig{
+			{
+				URL:        "NEW-URL",
+				Name:       "new",
+				EpochStart: "0",
+				EpochEnd:   "latest",
+				NonceStart: "0",
+				NonceEnd:   "latest",
+			},
+		},
+	}
+}
+
+func TestNewGatewaysReloader(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil config loader should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(config.Config{})
+		args.ConfigLoader = nil
+		reloader, err := NewGatewaysReloader(args)
+		assert.Equal(t, errNilConfigLoader, err)
+		assert.Nil(t, reloader)
+		assert.True(t, reloader.IsInterfaceNil())
+	})
+	t.Run("nil host finder should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(config.Config{})
+		args.HostFinder = nil
+		reloader, err := NewGatewaysReloader(args)
+		assert.Equal(t, errNilHostsFinder, err)
+		assert.Nil(t, reloader)
+	})
+	t.Run("nil tester should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(config.Config{})
+		args.Tester = nil
+		reloader, err := NewGatewaysReloader(args)
+		assert.Equal(t, errNilGatewaysTester, err)
+		assert.Nil(t, reloader)
+	})
+	t.Run("nil health provider should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(config.Config{})
+		args.HealthProvider = nil
+		reloader, err := NewGatewaysReloader(args)
+		assert.Equal(t, errNilGatewaysHealthProvider, err)
+		assert.Nil(t, reloader)
+	})
+	t.Run("nil discoverer should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(config.Config{})
+		args.Discoverer = nil
+		reloader, err := NewGatewaysReloader(args)
+		assert.Equal(t, errNilGatewaysDiscoverer, err)
+		assert.Nil(t, reloader)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		reloader, err := NewGatewaysReloader(createMockArgsGatewaysReloader(config.Config{}))
+		assert.Nil(t, err)
+		assert.False(t, reloader.IsInterfaceNil())
+	})
+}
+
+func TestGatewaysReloader_Reload(t *testing.T) {
+	t.Parallel()
+
+	expectedErr := errors.New("expected error")
+	checkOldGatewaysAreKept := func(t *testing.T, args ArgsGatewaysReloader) {
+		host, err := args.HostFinder.FindHost(make(map[string][]string))
+		assert.Nil(t, err)
+		assert.Equal(t, "URL1", host.URL)
+	}
+
+	t.Run("config loader errors should keep the old gateways", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(config.Config{})
+		args.ConfigLoader = func() (config.Config, error) {
+			return config.Config{}, expectedErr
+		}
+		reloader, _ := NewGatewaysReloader(args)
+
+		gateways, err := reloader.Reload()
+		assert.Equal(t, expectedErr, err)
+		assert.Nil(t, gateways)
+		checkOldGatewaysAreKept(t, args)
+	})
+	t.Run("invalid gateways should keep the old gateways", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createReloadedTestConfig()
+		cfg.Gateways[0].EpochStart = "NaN"
+		args := createMockArgsGatewaysReloader(cfg)
+		reloader, _ := NewGatewaysReloader(args)
+
+		gateways, err := reloader.Reload()
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "epoch start at index 0")
+		assert.Nil(t, gateways)
+		checkOldGatewaysAreKept(t, args)
+	})
+	t.Run("discoverer errors should keep the old gateways", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(createReloadedTestConfig())
+		args.Discoverer = &testscommon.GatewaysDiscovererStub{
+			DiscoverRangesCalled: func(gateways []config.GatewayConfig) ([]config.GatewayConfig, error) {
+				return nil, expectedErr
+			},
+		}
+		reloader, _ := NewGatewaysReloader(args)
+
+		gateways, err := reloader.Reload()
+		assert.Equal(t, expectedErr, err)
+		assert.Nil(t, gateways)
+		checkOldGatewaysAreKept(t, args)
+	})
+	t.Run("no gateways should keep the old gateways", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(config.Config{})
+		reloader, _ := NewGatewaysReloader(args)
+
+		gateways, err := reloader.Reload()
+		assert.Equal(t, errNoGatewayDefined, err)
+		assert.Nil(t, gateways)
+		checkOldGatewaysAreKept(t, args)
+	})
+	t.Run("probing fails should keep the old gateways", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(createReloadedTestConfig())
+		args.Tester = &testscommon.GatewaysTesterStub{
+			TestGatewaysCalled: func(gateways []config.GatewayConfig) error {
+				return expectedErr
+			},
+		}
+		reloader, _ := NewGatewaysReloader(args)
+
+		gateways, err := reloader.Reload()
+		assert.Equal(t, expectedErr, err)
+		assert.Nil(t, gateways)
+		checkOldGatewaysAreKept(t, args)
+	})
+	t.Run("should swap the gateways", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(createReloadedTestConfig())
+		testedGateways := make([]config.GatewayConfig, 0)
+		args.Tester = &testscommon.GatewaysTesterStub{
+			TestGatewaysCalled: func(gateways []config.GatewayConfig) error {
+				testedGateways = gateways
+				return nil
+			},
+		}
+		reloader, _ := NewGatewaysReloader(args)
+
+		gateways, err := reloader.Reload()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(gateways))
+		assert.Equal(t, "NEW-URL", gateways[0].URL)
+		assert.Equal(t, gateways, testedGateways)
+
+		host, err := args.HostFinder.FindHost(make(map[string][]string))
+		assert.Nil(t, err)
+		assert.Equal(t, "NEW-URL", host.URL)
+		assert.Equal(t, gateways, args.HostFinder.LoadedGateways())
+	})
+}
+
+func TestGatewaysReloader_Rediscover(t *testing.T) {
+	t.Parallel()
+
+	expectedErr := errors.New("expected error")
+
+	t.Run("should rediscover the configured gateways", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(createReloadedTestConfig())
+		args.ConfiguredGateways = []config.GatewayConfig{{URL: "DISCOVERED-URL", Name: "discovered"}}
+		discoveredGateways := []config.GatewayConfig{
+			{
+				URL:        "DISCOVERED-URL",
+				Name:       "discovered",
+				EpochStart: "0",
+				EpochEnd:   "latest",
+				NonceStart: "0",
+				NonceEnd:   "latest",
+			},
+		}
+		providedGateways := make([]config.GatewayConfig, 0)
+		args.Discoverer = &testscommon.GatewaysDiscovererStub{
+			DiscoverRangesCalled: func(gateways []config.GatewayConfig) ([]config.GatewayConfig, error) {
+				providedGateways = gateways
+				return discoveredGateways, nil
+			},
+		}
+		reloader, _ := NewGatewaysReloader(args)
+
+		gateways, err := reloader.Rediscover()
+		assert.Nil(t, err)
+		assert.Equal(t, discoveredGateways, gateways)
+		assert.Equal(t, args.ConfiguredGateways, providedGateways)
+
+		host, err := args.HostFinder.FindHost(make(map[string][]string))
+		assert.Nil(t, err)
+		assert.Equal(t, "DISCOVERED-URL", host.URL)
+	})
+	t.Run("should rediscover the gateways of the last reloaded config", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createReloadedTestConfig()
+		args := createMockArgsGatewaysReloader(cfg)
+		providedGateways := make([]config.GatewayConfig, 0)
+		args.Discoverer = &testscommon.GatewaysDiscovererStub{
+			DiscoverRangesCalled: func(gateways []config.GatewayConfig) ([]config.GatewayConfig, error) {
+				providedGateways = gateways
+				return gateways, nil
+			},
+		}
+		reloader, _ := NewGatewaysReloader(args)
+
+		_, err := reloader.Reload()
+		assert.Nil(t, err)
+
+		providedGateways = make([]config.GatewayConfig, 0)
+		_, err = reloader.Rediscover()
+		assert.Nil(t, err)
+		assert.Equal(t, cfg.Gateways, providedGateways)
+	})
+	t.Run("gateways not responding should be left out", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(createReloadedTestConfig())
+		args.ConfiguredGateways = []config.GatewayConfig{
+			{URL: "UNREACHABLE-URL", Name: "unreachable"},
+			{URL: "DISCOVERED-URL", Name: "discovered"},
+		}
+		isUnreachable := true
+		args.Tester = &testscommon.GatewaysTesterStub{
+			TestGatewaysCalled: func(gateways []config.GatewayConfig) error {
+				if isUnreachable && gateways[0].URL == "UNREACHABLE-URL" {
+					return expectedErr
+				}
+
+				return nil
+			},
+		}
+		providedGateways := make([]config.GatewayConfig, 0)
+		args.Discoverer = &testscommon.GatewaysDiscovererStub{
+			DiscoverRangesCalled: func(gateways []config.GatewayConfig) ([]config.GatewayConfig, error) {
+				providedGateways = gateways
+				discovered := gateways[0]
+				discovered.EpochStart, discovered.EpochEnd = "0", "latest"
+				discovered.NonceStart, discovered.NonceEnd = "0", "latest"
+
+				return []config.GatewayConfig{discovered}, nil
+			},
+		}
+		reloader, _ := NewGatewaysReloader(args)
+
+		gateways, err := reloader.Rediscover()
+		assert.Nil(t, err)
+		assert.Equal(t, args.ConfiguredGateways[1:], providedGateways)
+		assert.Len(t, gateways, 1)
+
+		host, err := args.HostFinder.FindHost(make(map[string][]string))
+		assert.Nil(t, err)
+		assert.Equal(t, "DISCOVERED-URL", host.URL)
+
+		// the gateway is tried again on the next rediscovery
+		isUnreachable = false
+		_, _ = reloader.Rediscover()
+		assert.Equal(t, args.ConfiguredGateways, providedGateways)
+	})
+	t.Run("no gateway responding should keep the old gateways", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(config.Config{})
+		args.Tester = &testscommon.GatewaysTesterStub{
+			TestGatewaysCalled: func(gateways []config.GatewayConfig) error {
+				return expectedErr
+			},
+		}
+		args.Discoverer = &testscommon.GatewaysDiscovererStub{
+			DiscoverRangesCalled: func(gateways []config.GatewayConfig) ([]config.GatewayConfig, error) {
+				return gateways, nil
+			},
+		}
+		reloader, _ := NewGatewaysReloader(args)
+
+		gateways, err := reloader.Rediscover()
+		assert.Equal(t, errNoGatewayDefined, err)
+		assert.Nil(t, gateways)
+
+		host, err := args.HostFinder.FindHost(make(map[string][]string))
+		assert.Nil(t, err)
+		assert.Equal(t, "URL1", host.URL)
+	})
+	t.Run("discoverer errors should keep the old gateways", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsGatewaysReloader(config.Config{})
+		args.Discoverer = &testscommon.GatewaysDiscovererStub{
+			DiscoverRangesCalled: func(gateways []config.GatewayConfig) ([]config.GatewayConfig, error) {
+				return nil, expectedErr
+			},
+		}
+		reloader, _ := NewGatewaysReloader(args)
+
+		gateways, err := reloader.Rediscover()
+		assert.Equal(t, expectedErr, err)
+		assert.Nil(t, gateways)
+
+		host, err := args.HostFinder.FindHost(make(map[string][]string))
+		assert.Nil(t, err)
+		assert.Equal(t, "URL1", host.URL)
+	})
+}
@@ -0,0 +1,281 @@
+package process
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+const hashLength = 32
+
+const (
+	// MetricHashIndexHits counts the hash lookups served from the local index
+	MetricHashIndexHits = "hash_index_hits"
+	// MetricHashFanOuts counts the hash lookups that required querying the gateways
+	MetricHashFanOuts = "hash_fan_outs"
+	// MetricHashFanOutFailures counts the fan-outs that did not find the hash on any gateway
+	MetricHashFanOutFailures = "hash_fan_out_failures"
+	// MetricHashMissesCacheHits counts the hash lookups answered by the cache of the hashes recently not found
+	MetricHashMissesCacheHits = "hash_misses_cache_hits"
+)
+
+type hashLookupResponse struct {
+	Data struct {
+		Transaction *struct {
+			Epoch uint64 `json:"epoch"`
+		} `json:"transaction"`
+		Block *struct {
+			Epoch uint64 `json:"epoch"`
+		} `json:"block"`
+	} `json:"data"`
+}
+
+// ArgsHashEpochResolver is the DTO used to create a new hash epoch resolver
+type ArgsHashEpochResolver struct {
+	Enabled            bool
+	MaxParallelLookups uint32
+	MissesCacheTTL     time.Duration
+	MissesCacheSize    int
+	HostFinder         HostFinder
+	HealthProvider     GatewaysHealthProvider
+	Storer             HashEpochStorer
+	Requester          GatewayRequester
+}
+
+type hashEpochResolver struct {
+	enabled            bool
+	maxParallelLookups uint32
+	hostFinder         HostFinder
+	healthProvider     GatewaysHealthProvider
+	storer             HashEpochStorer
+	requester          GatewayRequester
+	misses             *hashMissesCache
+
+	indexHits       atomic.Uint64
+	fanOuts         atomic.Uint64
+	fanOutFailures  atomic.Uint64
+	missesCacheHits atomic.Uint64
+}
+
+// NewHashEpochResolver creates a new hash epoch resolver able to route the /transaction/{hash} and
+// /block/{shard}/by-hash/{hash} requests to the gateway holding the hash
+func NewHashEpochResolver(args ArgsHashEpochResolver) (*hashEpochResolver, error) {
+	if check.IfNil(args.HostFinder) {
+		return nil, errNilHostsFinder
+	}
+	if check.IfNil(args.HealthProvider) {
+		return nil, errNilGatewaysHealthProvider
+	}
+	if check.IfNil(args.Storer) {
+		return nil, errNilHashEpochStorer
+	}
+	if check.IfNil(args.Requester) {
+		return nil, errNilGatewayRequester
+	}
+
+	return &hashEpochResolver{
+		enabled:            args.Enabled,
+		maxParallelLookups: max(args.MaxParallelLookups, 1),
+		hostFinder:         args.HostFinder,
+		healthProvider:     args.HealthProvider,
+		storer:             args.Storer,
+		requester:          args.Requester,
+		misses:             newHashMissesCache(args.MissesCacheTTL, args.MissesCacheSize),
+	}, nil
+}
+
+// ExtractValues returns the hint epoch of the block or transaction hash found in the request path. The epoch is
+// searched in the local index first. If not found, the healthy gateways are asked in parallel, the latest one first,
+// until the first successful lookup, which is saved in the index. The lookups stop when the provided context is done.
+// Returns an empty map if the resolver is disabled, the path does not contain a hash or the hash can not be found, the
+// hashes not found being remembered for a while.
+func (resolver *hashEpochResolver) ExtractValues(ctx context.Context, requestPath string) map[string][]string {
+	values := make(map[string][]string)
+	if !resolver.enabled {
+		return values
+	}
+
+	hash, lookupRoute, found := parseHashRoute(requestPath)
+	if !found {
+		return values
+	}
+
+	epoch, found, err := resolver.storer.GetHashEpoch(hash)
+	if err != nil {
+		log.Warn("can not read the hash index", "hash", hash, "error", err)
+	}
+	if found {
+		resolver.indexHits.Add(1)
+		values[UrlParameterHintEpoch] = []string{strconv.FormatUint(epoch, 10)}
+		return values
+	}
+	if resolver.misses.has(hash) {
+		resolver.missesCacheHits.Add(1)
+		return values
+	}
+
+	resolver.fanOuts.Add(1)
+	epoch, found = resolver.fanOut(ctx, lookupRoute)
+	if !found {
+		resolver.fanOutFailures.Add(1)
+		log.Debug("hash not found on any gateway", "hash", hash)
+		if ctx.Err() == nil {
+			resolver.misses.add(hash)
+		}
+		return values
+	}
+
+	err = resolver.storer.SaveHashEpoch(hash, epoch)
+	if err != nil {
+		log.Warn("can not write in the hash index", "hash", hash, "error", err)
+	}
+
+	values[UrlParameterHintEpoch] = []string{strconv.FormatUint(epoch, 10)}
+
+	return values
+}
+
+// parseHashRoute returns the hash and the route that should be used to look up the hash on a gateway
+func parseHashRoute(requestPath string) (string, string, bool) {
+	segments := strings.Split(strings.TrimSuffix(requestPath, pathSeparator), pathSeparator)
+
+	// /transaction/{hash}
+	if len(segments) == 3 && segments[1] == "transaction" && isHash(segments[2]) {
+		return segments[2], requestPath, true
+	}
+
+	// /block/{shard}/by-hash/{hash}
+	if len(segments) == 5 && segments[1] == "block" && segments[3] == "by-hash" && isHash(segments[4]) {
+		return segments[4], requestPath, true
+	}
+
+	return "", "", false
+}
+
+func isHash(value string) bool {
+	decoded, err := hex.DecodeString(value)
+
+	return err == nil && len(decoded) == hashLength
+}
+
+// fanOut asks the healthy gateways for the hash, at most maxParallelLookups at a time, and returns the epoch found by
+// the first successful lookup. The lookups still running are canceled once the epoch is found.
+func (resolver *hashEpochResolver) fanOut(ctx context.Context, lookupRoute string) (uint64, bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	upstreams := resolver.healthyUpstreams()
+	epochs := make(chan uint64, len(upstreams))
+	slots := make(chan struct{}, resolver.maxParallelLookups)
+	wg := sync.WaitGroup{}
+	for _, upstream := range upstreams {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(gateway config.GatewayConfig) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			epoch, found := resolver.lookUp(ctx, gateway, lookupRoute)
+			if found {
+				epochs <- epoch
+				cancel()
+			}
+		}(upstream)
+	}
+	wg.Wait()
+
+	select {
+	case epoch := <-epochs:
+		return epoch, true
+	default:
+		return 0, false
+	}
+}
+
+func (resolver *hashEpochResolver) lookUp(ctx context.Context, gateway config.GatewayConfig, lookupRoute string) (uint64, bool) {
+	response := &hashLookupResponse{}
+	err := resolver.requester.DoGatewayRequest(ctx, gateway, lookupRoute, response)
+	if err != nil {
+		log.Trace("hash lookup failed", "gateway", gateway.Name, "route", lookupRoute, "error", err)
+		return 0, false
+	}
+
+	switch {
+	case response.Data.Transaction != nil:
+		return response.Data.Transaction.Epoch, true
+	case response.Data.Block != nil:
+		return response.Data.Block.Epoch, true
+	default:
+		return 0, false
+	}
+}
+
+// healthyUpstreams returns, for each loaded gateway, the gateway settings pointing to its first healthy replica (the
+// gateway itself included), the latest gateway first. The gateways without healthy replicas are skipped.
+func (resolver *hashEpochResolver) healthyUpstreams() []config.GatewayConfig {
+	gateways := sortGatewaysLatestFirst(resolver.hostFinder.LoadedGateways())
+	upstreams := make([]config.GatewayConfig, 0, len(gateways))
+	for _, gateway := range gateways {
+		for _, replica := range createReplicas(gateway) {
+			if !resolver.healthProvider.IsHealthy(replica.URL) {
+				continue
+			}
+
+			upstream := gateway
+			upstream.URL = replica.URL
+			upstream.Name = replica.Name
+			upstreams = append(upstreams, upstream)
+			break
+		}
+	}
+
+	return upstreams
+}
+
+// sortGatewaysLatestFirst returns the gateways holding the latest data followed by the rest of the gateways, in order
+func sortGatewaysLatestFirst(gateways []config.GatewayConfig) []config.GatewayConfig {
+	result := make([]config.GatewayConfig, 0, len(gateways))
+	for _, gateway := range gateways {
+		if gateway.EpochEnd == latestMarker {
+			result = append(result, gateway)
+		}
+	}
+	for _, gateway := range gateways {
+		if gateway.EpochEnd != latestMarker {
+			result = append(result, gateway)
+		}
+	}
+
+	return result
+}
+
+// GetMetrics returns the hash index metrics
+func (resolver *hashEpochResolver) GetMetrics() map[string]uint64 {
+	return map[string]uint64{
+		MetricHashIndexHits:       resolver.indexHits.Load(),
+		MetricHashFanOuts:         resolver.fanOuts.Load(),
+		MetricHashFanOutFailures:  resolver.fanOutFailures.Load(),
+		MetricHashMissesCacheHits: resolver.missesCacheHits.Load(),
+	}
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (resolver *hashEpochResolver) IsInterfaceNil() bool {
+	return resolver == nil
+}
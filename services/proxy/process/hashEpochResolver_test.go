@@ -0,0 +1,476 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testHash = "d2ba3b57c1b8b0bfa7a8b8d44eeb6a1d56a2a7a6d3cbb5a1b2c6e0ad2a2b5f1c"
+
+func createMockArgsHashEpochResolver() ArgsHashEpochResolver {
+	return ArgsHashEpochResolver{
+		Enabled: true,
+		HostFinder: &testscommon.HostsFinderStub{
+			LoadedGatewaysCalled: func() []config.GatewayConfig {
+				return []config.GatewayConfig{
+					{URL: "http://old", EpochStart: "0", EpochEnd: "999", Name: "old"},
+					{URL: "http://middle", EpochStart: "1000", EpochEnd: "1999", Name: "middle"},
+					{URL: "http://latest", EpochStart: "2000", EpochEnd: "latest", Name: "latest"},
+				}
+			},
+		},
+		HealthProvider: &testscommon.GatewaysHealthProviderStub{},
+		Storer:         &testscommon.StorerStub{},
+		Requester:      &testscommon.GatewayRequesterStub{},
+	}
+}
+
+func setLookupEpoch(result any, isTransaction bool, epoch uint64) {
+	response := result.(*hashLookupResponse)
+	if isTransaction {
+		response.Data.Transaction = &struct {
+			Epoch uint64 `json:"epoch"`
+		}{Epoch: epoch}
+		return
+	}
+
+	response.Data.Block = &struct {
+		Epoch uint64 `json:"epoch"`
+	}{Epoch: epoch}
+}
+
+func TestNewHashEpochResolver(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil host finder should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		args.HostFinder = nil
+		resolver, err := NewHashEpochResolver(args)
+		assert.Nil(t, resolver)
+		assert.True(t, resolver.IsInterfaceNil())
+		assert.Equal(t, errNilHostsFinder, err)
+	})
+	t.Run("nil health provider should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		args.HealthProvider = nil
+		resolver, err := NewHashEpochResolver(args)
+		assert.Nil(t, resolver)
+		assert.Equal(t, errNilGatewaysHealthProvider, err)
+	})
+	t.Run("nil storer should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		args.Storer = nil
+		resolver, err := NewHashEpochResolver(args)
+		assert.Nil(t, resolver)
+		assert.Equal(t, errNilHashEpochStorer, err)
+	})
+	t.Run("nil requester should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		args.Requester = nil
+		resolver, err := NewHashEpochResolver(args)
+		assert.Nil(t, resolver)
+		assert.Equal(t, errNilGatewayRequester, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		resolver, err := NewHashEpochResolver(createMockArgsHashEpochResolver())
+		assert.NotNil(t, resolver)
+		assert.False(t, resolver.IsInterfaceNil())
+		assert.Nil(t, err)
+	})
+}
+
+func TestHashEpochResolver_ExtractValues(t *testing.T) {
+	t.Parallel()
+
+	t.Run("disabled resolver should not resolve", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		args.Enabled = false
+		args.Storer = &testscommon.StorerStub{
+			GetHashEpochHandler: func(hash string) (uint64, bool, error) {
+				require.Fail(t, "should have not called the storer")
+				return 0, false, nil
+			},
+		}
+		resolver, _ := NewHashEpochResolver(args)
+
+		values := resolver.ExtractValues(context.Background(), "/transaction/"+testHash)
+		assert.Empty(t, values)
+	})
+	t.Run("paths without a hash should not resolve", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		args.Storer = &testscommon.StorerStub{
+			GetHashEpochHandler: func(hash string) (uint64, bool, error) {
+				require.Fail(t, "should have not called the storer")
+				return 0, false, nil
+			},
+		}
+		resolver, _ := NewHashEpochResolver(args)
+
+		paths := []string{
+			"",
+			"/transaction",
+			"/transaction/send",
+			"/transaction/aabb",
+			"/transaction/" + testHash + "/status",
+			"/transaction/" + strings.Replace(testHash, "d", "z", 1),
+			"/block/1/by-nonce/37",
+			"/block/1/by-hash",
+			"/address/" + testHash,
+		}
+		for _, path := range paths {
+			assert.Empty(t, resolver.ExtractValues(context.Background(), path), path)
+		}
+	})
+	t.Run("index hit should not query the gateways", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		args.Storer = &testscommon.StorerStub{
+			GetHashEpochHandler: func(hash string) (uint64, bool, error) {
+				assert.Equal(t, testHash, hash)
+				return 1500, true, nil
+			},
+		}
+		args.Requester = &testscommon.GatewayRequesterStub{
+			DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+				require.Fail(t, "should have not queried the gateways")
+				return nil
+			},
+		}
+		resolver, _ := NewHashEpochResolver(args)
+
+		values := resolver.ExtractValues(context.Background(), "/transaction/"+testHash)
+		assert.Equal(t, map[string][]string{UrlParameterHintEpoch: {"1500"}}, values)
+
+		values = resolver.ExtractValues(context.Background(), "/block/1/by-hash/"+testHash)
+		assert.Equal(t, map[string][]string{UrlParameterHintEpoch: {"1500"}}, values)
+
+		expectedMetrics := map[string]uint64{
+			MetricHashIndexHits:       2,
+			MetricHashFanOuts:         0,
+			MetricHashFanOutFailures:  0,
+			MetricHashMissesCacheHits: 0,
+		}
+		assert.Equal(t, expectedMetrics, resolver.GetMetrics())
+	})
+	t.Run("fan-out should query the latest gateway first and save the result", func(t *testing.T) {
+		t.Parallel()
+
+		savedEpochs := make(map[string]uint64)
+		args := createMockArgsHashEpochResolver()
+		args.Storer = &testscommon.StorerStub{
+			GetHashEpochHandler: func(hash string) (uint64, bool, error) {
+				return 0, false, errors.New("index error")
+			},
+			SaveHashEpochHandler: func(hash string, epoch uint64) error {
+				savedEpochs[hash] = epoch
+				return nil
+			},
+		}
+		queriedURLs := make([]string, 0)
+		args.Requester = &testscommon.GatewayRequesterStub{
+			DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+				url := gateway.URL + route
+				queriedURLs = append(queriedURLs, url)
+				if strings.HasPrefix(url, "http://middle") {
+					setLookupEpoch(result, true, 1200)
+					return nil
+				}
+
+				return errors.New("not found")
+			},
+		}
+		resolver, _ := NewHashEpochResolver(args)
+
+		values := resolver.ExtractValues(context.Background(), "/transaction/"+testHash)
+		assert.Equal(t, map[string][]string{UrlParameterHintEpoch: {"1200"}}, values)
+		expectedURLs := []string{
+			"http://latest/transaction/" + testHash,
+			"http://old/transaction/" + testHash,
+			"http://middle/transaction/" + testHash,
+		}
+		assert.Equal(t, expectedURLs, queriedURLs)
+		assert.Equal(t, map[string]uint64{testHash: 1200}, savedEpochs)
+
+		expectedMetrics := map[string]uint64{
+			MetricHashIndexHits:       0,
+			MetricHashFanOuts:         1,
+			MetricHashFanOutFailures:  0,
+			MetricHashMissesCacheHits: 0,
+		}
+		assert.Equal(t, expectedMetrics, resolver.GetMetrics())
+	})
+	t.Run("fan-out should resolve block hashes", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		queriedURLs := make([]string, 0)
+		args.Requester = &testscommon.GatewayRequesterStub{
+			DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+				url := gateway.URL + route
+				queriedURLs = append(queriedURLs, url)
+				setLookupEpoch(result, false, 2100)
+				return nil
+			},
+		}
+		resolver, _ := NewHashEpochResolver(args)
+
+		values := resolver.ExtractValues(context.Background(), "/block/4294967295/by-hash/"+testHash)
+		assert.Equal(t, map[string][]string{UrlParameterHintEpoch: {"2100"}}, values)
+		assert.Equal(t, []string{"http://latest/block/4294967295/by-hash/" + testHash}, queriedURLs)
+	})
+	t.Run("hash not found on any gateway should not resolve", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		args.Storer = &testscommon.StorerStub{
+			SaveHashEpochHandler: func(hash string, epoch uint64) error {
+				require.Fail(t, "should have not saved the hash")
+				return nil
+			},
+		}
+		numQueries := 0
+		args.Requester = &testscommon.GatewayRequesterStub{
+			DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+				numQueries++
+				if numQueries == 1 {
+					// a successful response without the requested data
+					return nil
+				}
+
+				return errors.New("not found")
+			},
+		}
+		resolver, _ := NewHashEpochResolver(args)
+
+		values := resolver.ExtractValues(context.Background(), "/transaction/"+testHash)
+		assert.Empty(t, values)
+		assert.Equal(t, 3, numQueries)
+
+		expectedMetrics := map[string]uint64{
+			MetricHashIndexHits:       0,
+			MetricHashFanOuts:         1,
+			MetricHashFanOutFailures:  1,
+			MetricHashMissesCacheHits: 0,
+		}
+		assert.Equal(t, expectedMetrics, resolver.GetMetrics())
+	})
+	t.Run("storage write error should still resolve", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		args.Storer = &testscommon.StorerStub{
+			SaveHashEpochHandler: func(hash string, epoch uint64) error {
+				return errors.New("write error")
+			},
+		}
+		args.Requester = &testscommon.GatewayRequesterStub{
+			DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+				setLookupEpoch(result, true, 2001)
+				return nil
+			},
+		}
+		resolver, _ := NewHashEpochResolver(args)
+
+		values := resolver.ExtractValues(context.Background(), "/transaction/"+testHash+"/")
+		assert.Equal(t, map[string][]string{UrlParameterHintEpoch: {"2001"}}, values)
+	})
+	t.Run("fan-out should skip the unhealthy gateways and replicas", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			LoadedGatewaysCalled: func() []config.GatewayConfig {
+				return []config.GatewayConfig{
+					{URL: "http://old", EpochStart: "0", EpochEnd: "999", Name: "old"},
+					{
+						URL:        "http://latest",
+						EpochStart: "1000",
+						EpochEnd:   "latest",
+						Name:       "latest",
+						Replicas:   []config.ReplicaConfig{{URL: "http://latest-replica"}},
+					},
+				}
+			},
+		}
+		args.HealthProvider = &testscommon.GatewaysHealthProviderStub{
+			IsHealthyCalled: func(url string) bool {
+				return url == "http://latest-replica"
+			},
+		}
+		queriedURLs := make([]string, 0)
+		args.Requester = &testscommon.GatewayRequesterStub{
+			DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+				queriedURLs = append(queriedURLs, gateway.URL+route)
+				return errors.New("not found")
+			},
+		}
+		resolver, _ := NewHashEpochResolver(args)
+
+		values := resolver.ExtractValues(context.Background(), "/transaction/"+testHash)
+		assert.Empty(t, values)
+		assert.Equal(t, []string{"http://latest-replica/transaction/" + testHash}, queriedURLs)
+	})
+	t.Run("fan-out should run the lookups in parallel and stop at the first hit", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		args.MaxParallelLookups = 2
+		args.HostFinder = &testscommon.HostsFinderStub{
+			LoadedGatewaysCalled: func() []config.GatewayConfig {
+				return []config.GatewayConfig{
+					{URL: "http://gateway0", EpochStart: "0", EpochEnd: "999"},
+					{URL: "http://gateway1", EpochStart: "1000", EpochEnd: "1999"},
+					{URL: "http://gateway2", EpochStart: "2000", EpochEnd: "2999"},
+					{URL: "http://gateway3", EpochStart: "3000", EpochEnd: "latest"},
+				}
+			},
+		}
+		mut := sync.Mutex{}
+		inFlight, maxInFlight := 0, 0
+		queriedURLs := make([]string, 0)
+		isInFlight := func(value int) bool {
+			mut.Lock()
+			defer mut.Unlock()
+
+			return inFlight == value
+		}
+		args.Requester = &testscommon.GatewayRequesterStub{
+			DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+				mut.Lock()
+				inFlight++
+				maxInFlight = max(maxInFlight, inFlight)
+				queriedURLs = append(queriedURLs, gateway.URL)
+				mut.Unlock()
+				defer func() {
+					mut.Lock()
+					inFlight--
+					mut.Unlock()
+				}()
+
+				if gateway.URL == "http://gateway0" {
+					// answers once the latest gateway's lookup is in flight too
+					for !isInFlight(2) {
+						time.Sleep(time.Millisecond)
+					}
+					setLookupEpoch(result, true, 500)
+					return nil
+				}
+
+				// the other lookups hang until they are canceled
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}
+		resolver, _ := NewHashEpochResolver(args)
+
+		values := resolver.ExtractValues(context.Background(), "/transaction/"+testHash)
+		assert.Equal(t, map[string][]string{UrlParameterHintEpoch: {"500"}}, values)
+		assert.Equal(t, 2, maxInFlight)
+		assert.ElementsMatch(t, []string{"http://gateway3", "http://gateway0"}, queriedURLs)
+	})
+	t.Run("canceled request should stop the fan-out without remembering the miss", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		args.MissesCacheTTL = time.Minute
+		args.MissesCacheSize = 10
+		numQueries := 0
+		args.Requester = &testscommon.GatewayRequesterStub{
+			DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+				numQueries++
+				return ctx.Err()
+			},
+		}
+		resolver, _ := NewHashEpochResolver(args)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		values := resolver.ExtractValues(ctx, "/transaction/"+testHash)
+		assert.Empty(t, values)
+		assert.Zero(t, numQueries)
+		assert.False(t, resolver.misses.has(testHash))
+	})
+	t.Run("hash not found should be remembered for a while", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsHashEpochResolver()
+		args.MissesCacheTTL = time.Minute
+		args.MissesCacheSize = 10
+		numQueries := 0
+		args.Requester = &testscommon.GatewayRequesterStub{
+			DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+				numQueries++
+				return errors.New("not found")
+			},
+		}
+		resolver, _ := NewHashEpochResolver(args)
+
+		values := resolver.ExtractValues(context.Background(), "/transaction/"+testHash)
+		assert.Empty(t, values)
+		values = resolver.ExtractValues(context.Background(), "/block/1/by-hash/"+testHash)
+		assert.Empty(t, values)
+		assert.Equal(t, 3, numQueries)
+
+		expectedMetrics := map[string]uint64{
+			MetricHashIndexHits:       0,
+			MetricHashFanOuts:         1,
+			MetricHashFanOutFailures:  1,
+			MetricHashMissesCacheHits: 1,
+		}
+		assert.Equal(t, expectedMetrics, resolver.GetMetrics())
+	})
+}
+
+func TestHashEpochResolver_ConcurrentOperations(t *testing.T) {
+	t.Parallel()
+
+	args := createMockArgsHashEpochResolver()
+	args.Storer = &testscommon.StorerStub{
+		GetHashEpochHandler: func(hash string) (uint64, bool, error) {
+			return 1, true, nil
+		},
+	}
+	resolver, _ := NewHashEpochResolver(args)
+
+	numCalls := 100
+	wg := sync.WaitGroup{}
+	wg.Add(numCalls)
+	for i := 0; i < numCalls; i++ {
+		go func(index int) {
+			defer wg.Done()
+
+			if index%2 == 0 {
+				_ = resolver.ExtractValues(context.Background(), "/transaction/"+testHash)
+				return
+			}
+			_ = resolver.GetMetrics()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, uint64(numCalls/2), resolver.GetMetrics()[MetricHashIndexHits])
+}
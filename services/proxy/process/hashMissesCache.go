@@ -0,0 +1,92 @@
+package process
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type hashMissEntry struct {
+	hash      string
+	expiresAt time.Time
+}
+
+// hashMissesCache remembers, for a limited time, the hashes that were not found on any gateway so the repeated
+// requests for the same unknown hash do not fan out again. It holds at most maxEntries hashes, evicting the oldest ones.
+type hashMissesCache struct {
+	mut        sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	order      *list.List
+	entries    map[string]*list.Element
+}
+
+// newHashMissesCache creates a new hashes misses cache. A 0 ttl or maxEntries value disables the cache.
+func newHashMissesCache(ttl time.Duration, maxEntries int) *hashMissesCache {
+	return &hashMissesCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (cache *hashMissesCache) isEnabled() bool {
+	return cache.ttl > 0 && cache.maxEntries > 0
+}
+
+// add remembers the provided hash as missing until its ttl passes
+func (cache *hashMissesCache) add(hash string) {
+	if !cache.isEnabled() {
+		return
+	}
+
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	element, found := cache.entries[hash]
+	if found {
+		cache.order.Remove(element)
+	}
+	cache.entries[hash] = cache.order.PushBack(&hashMissEntry{
+		hash:      hash,
+		expiresAt: time.Now().Add(cache.ttl),
+	})
+
+	for cache.order.Len() > cache.maxEntries {
+		cache.remove(cache.order.Front())
+	}
+}
+
+// has returns true if the provided hash was recently found missing
+func (cache *hashMissesCache) has(hash string) bool {
+	if !cache.isEnabled() {
+		return false
+	}
+
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	element, found := cache.entries[hash]
+	if !found {
+		return false
+	}
+	if time.Now().After(element.Value.(*hashMissEntry).expiresAt) {
+		cache.remove(element)
+		return false
+	}
+
+	return true
+}
+
+func (cache *hashMissesCache) remove(element *list.Element) {
+	cache.order.Remove(element)
+	delete(cache.entries, element.Value.(*hashMissEntry).hash)
+}
+
+func (cache *hashMissesCache) len() int {
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	return cache.order.Len()
+}
@@ -0,0 +1,53 @@
+package process
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashMissesCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("disabled cache should not remember the hashes", func(t *testing.T) {
+		t.Parallel()
+
+		cache := newHashMissesCache(0, 10)
+		cache.add("hash")
+		assert.False(t, cache.has("hash"))
+
+		cache = newHashMissesCache(time.Minute, 0)
+		cache.add("hash")
+		assert.False(t, cache.has("hash"))
+	})
+	t.Run("should remember the hashes until their ttl passes", func(t *testing.T) {
+		t.Parallel()
+
+		cache := newHashMissesCache(time.Millisecond*100, 10)
+		cache.add("hash")
+		assert.True(t, cache.has("hash"))
+		assert.False(t, cache.has("other hash"))
+
+		time.Sleep(time.Millisecond * 150)
+		assert.False(t, cache.has("hash"))
+		assert.Zero(t, cache.len())
+	})
+	t.Run("should evict the oldest hashes", func(t *testing.T) {
+		t.Parallel()
+
+		cache := newHashMissesCache(time.Minute, 3)
+		for i := 0; i < 5; i++ {
+			cache.add(fmt.Sprintf("hash%d", i))
+		}
+		cache.add("hash2")
+
+		assert.Equal(t, 3, cache.len())
+		assert.False(t, cache.has("hash0"))
+		assert.False(t, cache.has("hash1"))
+		assert.True(t, cache.has("hash2"))
+		assert.True(t, cache.has("hash3"))
+		assert.True(t, cache.has("hash4"))
+	})
+}
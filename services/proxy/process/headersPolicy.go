@@ -0,0 +1,311 @@
+package process
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+const (
+	headerConnection         = "Connection"
+	headerAuthorization      = "Authorization"
+	headerCookie             = "Cookie"
+	headerSetCookie          = "Set-Cookie"
+	headerForwardedFor       = "X-Forwarded-For"
+	headerForwardedProto     = "X-Forwarded-Proto"
+	headerRequestID          = "X-Request-Id"
+	headerRateLimitLimit     = "X-Ratelimit-Limit"
+	headerRateLimitRemaining = "X-Ratelimit-Remaining"
+	headerRateLimitReset     = "X-Ratelimit-Reset"
+	headerRetryAfter         = "Retry-After"
+	headerCreditsCost        = "X-Credits-Cost"
+	maxRequestIDLength       = 128
+	forwardedProtoHTTP       = "http"
+	forwardedProtoHTTPS      = "https"
+	forwardedForSeparator    = ", "
+)
+
+// hopByHopHeaders are only meaningful for a single connection, see RFC 9110, section 7.6.1
+var hopByHopHeaders = []string{
+	headerConnection,
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// requestCredentialHeaders carry the credentials of our customers and are never sent to the gateways
+var requestCredentialHeaders = []string{
+	headerApiKey,
+	headerAuthorization,
+	headerCookie,
+}
+
+// responseCredentialHeaders are never sent by the gateways to our customers
+var responseCredentialHeaders = []string{
+	headerSetCookie,
+}
+
+// perRequestHeaders are set by the proxy on each response, replacing the ones of the gateways or of the stored responses
+var perRequestHeaders = []string{
+	headerRequestID,
+	headerRateLimitLimit,
+	headerRateLimitRemaining,
+	headerRateLimitReset,
+	headerRetryAfter,
+	headerCreditsCost,
+}
+
+type headersFilter struct {
+	allowed  map[string]struct{}
+	denied   map[string]struct{}
+	stripped map[string]struct{}
+}
+
+type headersPolicy struct {
+	requestFilter  *headersFilter
+	responseFilter *headersFilter
+}
+
+// NewHeadersPolicy creates a new headers policy. The hop-by-hop headers are always removed, in both directions, as
+// well as the credentials (X-Api-Key, Authorization, Cookie, Set-Cookie). The remaining headers are filtered using
+// the configured allow and deny lists.
+func NewHeadersPolicy(cfg config.HeadersConfig) (*headersPolicy, error) {
+	requestFilter, err := newHeadersFilter(cfg.RequestAllowList, cfg.RequestDenyList, requestCredentialHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("%w in the request lists", err)
+	}
+
+	responseFilter, err := newHeadersFilter(cfg.ResponseAllowList, cfg.ResponseDenyList, responseCredentialHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("%w in the response lists", err)
+	}
+
+	return &headersPolicy{
+		requestFilter:  requestFilter,
+		responseFilter: responseFilter,
+	}, nil
+}
+
+func newHeadersFilter(allowList []string, denyList []string, credentialHeaders []string) (*headersFilter, error) {
+	filter := &headersFilter{
+		stripped: make(map[string]struct{}, len(hopByHopHeaders)+len(credentialHeaders)),
+	}
+
+	var err error
+	filter.allowed, err = createHeadersSet(allowList)
+	if err != nil {
+		return nil, err
+	}
+	filter.denied, err = createHeadersSet(denyList)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range hopByHopHeaders {
+		filter.stripped[http.CanonicalHeaderKey(name)] = struct{}{}
+	}
+	for _, name := range credentialHeaders {
+		filter.stripped[http.CanonicalHeaderKey(name)] = struct{}{}
+	}
+
+	return filter, nil
+}
+
+func createHeadersSet(names []string) (map[string]struct{}, error) {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			return nil, errInvalidHeaderName
+		}
+
+		set[http.CanonicalHeaderKey(name)] = struct{}{}
+	}
+
+	return set, nil
+}
+
+// copy copies the allowed headers, also dropping the ones listed in the Connection header
+func (filter *headersFilter) copy(destination http.Header, source http.Header) {
+	connectionHeaders := make(map[string]struct{})
+	for _, value := range source.Values(headerConnection) {
+		for _, name := range strings.Split(value, ",") {
+			connectionHeaders[http.CanonicalHeaderKey(strings.TrimSpace(name))] = struct{}{}
+		}
+	}
+
+	for key, values := range source {
+		canonicalKey := http.CanonicalHeaderKey(key)
+		_, isConnectionHeader := connectionHeaders[canonicalKey]
+		if isConnectionHeader || !filter.isAllowed(canonicalKey) {
+			continue
+		}
+
+		destination[key] = values
+	}
+}
+
+func (filter *headersFilter) isAllowed(canonicalKey string) bool {
+	_, isStripped := filter.stripped[canonicalKey]
+	if isStripped {
+		return false
+	}
+	if filter.isDenied(canonicalKey) {
+		return false
+	}
+	if len(filter.allowed) == 0 {
+		return true
+	}
+
+	_, isAllowed := filter.allowed[canonicalKey]
+
+	return isAllowed
+}
+
+func (filter *headersFilter) isDenied(canonicalKey string) bool {
+	_, isDenied := filter.denied[canonicalKey]
+
+	return isDenied
+}
+
+// RequestID returns the request ID provided by the client, if valid, or a new one
+func (policy *headersPolicy) RequestID(header http.Header) string {
+	requestID := header.Get(headerRequestID)
+	if isValidRequestID(requestID) {
+		return requestID
+	}
+
+	return common.GenerateKey()
+}
+
+func isValidRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, character := range requestID {
+		isAlphanumeric := (character >= 'a' && character <= 'z') ||
+			(character >= 'A' && character <= 'Z') ||
+			(character >= '0' && character <= '9')
+		if !isAlphanumeric && !strings.ContainsRune("-_.:", character) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CopyRequestHeader copies the allowed headers of the client request into the gateway request header and adds the
+// X-Forwarded-For, X-Forwarded-Proto and X-Request-Id headers, unless they are denied. The request ID is read from
+// the client request header, the caller should set it before.
+func (policy *headersPolicy) CopyRequestHeader(destination http.Header, request *http.Request) {
+	policy.requestFilter.copy(destination, request.Header)
+
+	filter := policy.requestFilter
+	if !filter.isDenied(headerForwardedFor) {
+		clientIP := remoteIP(request)
+		forwardedFor := clientIP
+		previousHops := strings.Join(destination.Values(headerForwardedFor), forwardedForSeparator)
+		if len(previousHops) > 0 {
+			forwardedFor = previousHops + forwardedForSeparator + clientIP
+		}
+		destination.Set(headerForwardedFor, forwardedFor)
+	}
+	if !filter.isDenied(headerForwardedProto) && len(destination.Get(headerForwardedProto)) == 0 {
+		// an existing value is kept as it was set by the proxy in front of us that terminated the TLS connection
+		proto := forwardedProtoHTTP
+		if request.TLS != nil {
+			proto = forwardedProtoHTTPS
+		}
+		destination.Set(headerForwardedProto, proto)
+	}
+	if !filter.isDenied(headerRequestID) {
+		destination.Set(headerRequestID, request.Header.Get(headerRequestID))
+	}
+}
+
+// CopyResponseHeader copies the allowed headers of the gateway response into the client response header. The
+// X-Request-Id header of the gateway is ignored, the client receiving the ID set by the proxy. The rate limit headers
+// already set by the proxy also replace the ones of the gateway, the gateway's Retry-After header being dropped for the
+// accounts limited by the proxy.
+func (policy *headersPolicy) CopyResponseHeader(destination http.Header, source http.Header) {
+	proxyHeaders := make(map[string][]string, len(perRequestHeaders))
+	for _, name := range perRequestHeaders {
+		values := destination.Values(name)
+		if len(values) > 0 {
+			proxyHeaders[name] = values
+		}
+	}
+
+	policy.responseFilter.copy(destination, source)
+
+	destination.Del(headerRequestID)
+	_, limitedByProxy := proxyHeaders[headerRateLimitLimit]
+	if limitedByProxy {
+		destination.Del(headerRetryAfter)
+	}
+	for name, values := range proxyHeaders {
+		destination[name] = values
+	}
+}
+
+func isPerRequestHeader(canonicalKey string) bool {
+	for _, name := range perRequestHeaders {
+		if canonicalKey == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setRateLimitHeaders sets the X-RateLimit-* headers, in seconds, from the rate limiter's decision, adding the
+// Retry-After header only for the rejected requests. No header is set for the accounts that are not limited.
+func setRateLimitHeaders(header http.Header, result common.RateLimitResult) {
+	if result.Allowed && result.Limit == 0 {
+		return
+	}
+
+	header.Set(headerRateLimitLimit, strconv.FormatUint(result.Limit, 10))
+	header.Set(headerRateLimitRemaining, strconv.FormatUint(result.Remaining, 10))
+	header.Set(headerRateLimitReset, formatSeconds(result.ResetAfter))
+	if !result.Allowed {
+		header.Set(headerRetryAfter, formatSeconds(result.RetryAfter))
+	}
+}
+
+// setCreditsCostHeader sets the number of credits consumed by the request. No header is set if the request was not
+// charged.
+func setCreditsCostHeader(header http.Header, cost uint64) {
+	if cost == 0 {
+		return
+	}
+
+	header.Set(headerCreditsCost, strconv.FormatUint(cost, 10))
+}
+
+// formatSeconds returns the duration in whole seconds, rounded up, so a client waiting for it is not rejected again
+func formatSeconds(duration time.Duration) string {
+	seconds := int64(math.Ceil(duration.Seconds()))
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	return strconv.FormatInt(seconds, 10)
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (policy *headersPolicy) IsInterfaceNil() bool {
+	return policy == nil
+}
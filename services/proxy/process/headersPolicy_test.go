@@ -0,0 +1,274 @@
+package process
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHeadersPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty request header name should error", func(t *testing.T) {
+		t.Parallel()
+
+		policy, err := NewHeadersPolicy(config.HeadersConfig{
+			RequestDenyList: []string{"X-Test", " "},
+		})
+		assert.Nil(t, policy)
+		assert.True(t, errors.Is(err, errInvalidHeaderName))
+		assert.Contains(t, err.Error(), "request")
+	})
+	t.Run("empty response header name should error", func(t *testing.T) {
+		t.Parallel()
+
+		policy, err := NewHeadersPolicy(config.HeadersConfig{
+			ResponseAllowList: []string{""},
+		})
+		assert.Nil(t, policy)
+		assert.True(t, errors.Is(err, errInvalidHeaderName))
+		assert.Contains(t, err.Error(), "response")
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		policy, err := NewHeadersPolicy(config.HeadersConfig{})
+		assert.Nil(t, err)
+		assert.False(t, policy.IsInterfaceNil())
+	})
+}
+
+func TestHeadersPolicy_RequestID(t *testing.T) {
+	t.Parallel()
+
+	policy, _ := NewHeadersPolicy(config.HeadersConfig{})
+
+	t.Run("valid client request ID should be kept", func(t *testing.T) {
+		t.Parallel()
+
+		header := http.Header{}
+		header.Set(headerRequestID, "client-ID_1.2:3")
+		assert.Equal(t, "client-ID_1.2:3", policy.RequestID(header))
+	})
+	t.Run("missing or invalid client request ID should be replaced", func(t *testing.T) {
+		t.Parallel()
+
+		invalidIDs := []string{
+			"",
+			"with space",
+			"new\nline",
+			strings.Repeat("a", maxRequestIDLength+1),
+		}
+		for _, invalidID := range invalidIDs {
+			header := http.Header{}
+			header.Set(headerRequestID, invalidID)
+
+			requestID := policy.RequestID(header)
+			assert.NotEqual(t, invalidID, requestID)
+			assert.True(t, isValidRequestID(requestID))
+		}
+	})
+	t.Run("generated request IDs should be unique", func(t *testing.T) {
+		t.Parallel()
+
+		assert.NotEqual(t, policy.RequestID(http.Header{}), policy.RequestID(http.Header{}))
+	})
+}
+
+func TestHeadersPolicy_CopyRequestHeader(t *testing.T) {
+	t.Parallel()
+
+	createRequest := func() *http.Request {
+		request := httptest.NewRequest(http.MethodGet, "/address/erd1", nil)
+		request.RemoteAddr = "10.0.0.1:4321"
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Accept-Encoding", "gzip")
+		request.Header.Set("X-Custom", "custom")
+		request.Header.Set(headerApiKey, "secret key")
+		request.Header.Set(headerAuthorization, "Bearer token")
+		request.Header.Set(headerCookie, "session=1")
+		request.Header.Set("Proxy-Authorization", "Basic abc")
+		request.Header.Set("Keep-Alive", "timeout=5")
+		request.Header.Set("Upgrade", "websocket")
+		request.Header.Set(headerConnection, "keep-alive, X-Connection-Scoped")
+		request.Header.Set("X-Connection-Scoped", "value")
+		request.Header.Set(headerRequestID, "request-id")
+
+		return request
+	}
+
+	t.Run("should strip the hop-by-hop and credential headers and add the forwarding ones", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewHeadersPolicy(config.HeadersConfig{})
+		destination := http.Header{}
+		policy.CopyRequestHeader(destination, createRequest())
+
+		expected := http.Header{
+			"Content-Type":       []string{"application/json"},
+			"Accept-Encoding":    []string{"gzip"},
+			"X-Custom":           []string{"custom"},
+			headerForwardedFor:   []string{"10.0.0.1"},
+			headerForwardedProto: []string{forwardedProtoHTTP},
+			headerRequestID:      []string{"request-id"},
+		}
+		assert.Equal(t, expected, destination)
+	})
+	t.Run("should append the client to the existing forwarded chain and keep the forwarded proto", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewHeadersPolicy(config.HeadersConfig{})
+		request := createRequest()
+		request.Header.Add(headerForwardedFor, "1.1.1.1")
+		request.Header.Add(headerForwardedFor, "2.2.2.2, 3.3.3.3")
+		request.Header.Set(headerForwardedProto, forwardedProtoHTTPS)
+
+		destination := http.Header{}
+		policy.CopyRequestHeader(destination, request)
+		assert.Equal(t, "1.1.1.1, 2.2.2.2, 3.3.3.3, 10.0.0.1", destination.Get(headerForwardedFor))
+		assert.Equal(t, forwardedProtoHTTPS, destination.Get(headerForwardedProto))
+	})
+	t.Run("TLS request should set the https proto", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewHeadersPolicy(config.HeadersConfig{})
+		request := createRequest()
+		request.TLS = &tls.ConnectionState{}
+		request.RemoteAddr = "no port"
+
+		destination := http.Header{}
+		policy.CopyRequestHeader(destination, request)
+		assert.Equal(t, forwardedProtoHTTPS, destination.Get(headerForwardedProto))
+		assert.Equal(t, "no port", destination.Get(headerForwardedFor))
+	})
+	t.Run("allow list should only pass the listed headers", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewHeadersPolicy(config.HeadersConfig{
+			RequestAllowList: []string{"content-type", headerApiKey},
+		})
+		destination := http.Header{}
+		policy.CopyRequestHeader(destination, createRequest())
+
+		expected := http.Header{
+			"Content-Type":       []string{"application/json"},
+			headerForwardedFor:   []string{"10.0.0.1"},
+			headerForwardedProto: []string{forwardedProtoHTTP},
+			headerRequestID:      []string{"request-id"},
+		}
+		assert.Equal(t, expected, destination)
+	})
+	t.Run("deny list should remove the listed headers, including the forwarding ones", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewHeadersPolicy(config.HeadersConfig{
+			RequestAllowList: []string{"Content-Type", "X-Custom"},
+			RequestDenyList:  []string{"x-custom", headerForwardedFor, headerForwardedProto, headerRequestID},
+		})
+		destination := http.Header{}
+		policy.CopyRequestHeader(destination, createRequest())
+
+		expected := http.Header{
+			"Content-Type": []string{"application/json"},
+		}
+		assert.Equal(t, expected, destination)
+	})
+}
+
+func TestHeadersPolicy_CopyResponseHeader(t *testing.T) {
+	t.Parallel()
+
+	createSource := func() http.Header {
+		source := http.Header{}
+		source.Set("Content-Type", "application/json")
+		source.Set("X-Custom", "custom")
+		source.Set(headerSetCookie, "session=1")
+		source.Set("Transfer-Encoding", "chunked")
+		source.Set(headerConnection, "X-Connection-Scoped")
+		source.Set("X-Connection-Scoped", "value")
+		source.Set(headerRequestID, "gateway request id")
+
+		return source
+	}
+
+	t.Run("should strip the hop-by-hop and credential headers and keep the request ID", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewHeadersPolicy(config.HeadersConfig{})
+		destination := http.Header{}
+		destination.Set(headerRequestID, "request-id")
+		policy.CopyResponseHeader(destination, createSource())
+
+		expected := http.Header{
+			"Content-Type":  []string{"application/json"},
+			"X-Custom":      []string{"custom"},
+			headerRequestID: []string{"request-id"},
+		}
+		assert.Equal(t, expected, destination)
+	})
+	t.Run("should not pass the gateway request ID", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewHeadersPolicy(config.HeadersConfig{})
+		destination := http.Header{}
+		policy.CopyResponseHeader(destination, createSource())
+
+		assert.Empty(t, destination.Values(headerRequestID))
+	})
+	t.Run("should keep the rate limit headers set by the proxy", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewHeadersPolicy(config.HeadersConfig{})
+		source := createSource()
+		source.Set(headerRateLimitLimit, "1000")
+		source.Set(headerRateLimitRemaining, "999")
+		source.Set(headerRetryAfter, "30")
+
+		destination := http.Header{}
+		destination.Set(headerRateLimitLimit, "10")
+		destination.Set(headerRateLimitRemaining, "9")
+		policy.CopyResponseHeader(destination, source)
+
+		assert.Equal(t, "10", destination.Get(headerRateLimitLimit))
+		assert.Equal(t, "9", destination.Get(headerRateLimitRemaining))
+		// the account is limited by the proxy, the gateway value is dropped
+		assert.Empty(t, destination.Values(headerRetryAfter))
+	})
+	t.Run("should pass the gateway rate limit headers if the proxy did not set them", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewHeadersPolicy(config.HeadersConfig{})
+		source := createSource()
+		source.Set(headerRateLimitLimit, "1000")
+		source.Set(headerRetryAfter, "30")
+
+		destination := http.Header{}
+		policy.CopyResponseHeader(destination, source)
+
+		assert.Equal(t, "1000", destination.Get(headerRateLimitLimit))
+		assert.Equal(t, "30", destination.Get(headerRetryAfter))
+	})
+	t.Run("allow and deny lists should apply", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewHeadersPolicy(config.HeadersConfig{
+			ResponseAllowList: []string{"Content-Type", "X-Custom"},
+			ResponseDenyList:  []string{"X-Custom"},
+			// the request lists should not apply on the responses
+			RequestDenyList: []string{"Content-Type"},
+		})
+		destination := http.Header{}
+		policy.CopyResponseHeader(destination, createSource())
+
+		expected := http.Header{
+			"Content-Type": []string{"application/json"},
+		}
+		assert.Equal(t, expected, destination)
+	})
+}
@@ -3,11 +3,13 @@ package process
 import (
 	"fmt"
 	"math"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
 )
 
 const latestMarker = "latest"
@@ -20,16 +22,25 @@ type gatewayConfig struct {
 	nonceStart    uint64
 	nonceEnd      uint64
 	hasLatestData bool
+	balancer      loadBalancer
+	fallback      *gatewayConfig
 }
 
 type hostsFinder struct {
 	gateways          []gatewayConfig
 	latestDataGateway *gatewayConfig
+	inFlight          *inFlightCounter
+	healthProvider    GatewaysHealthProvider
 }
 
 // NewHostsFinder will create a new hosts finder instance
-func NewHostsFinder(configGateways []config.GatewayConfig) (*hostsFinder, error) {
-	gateways, latestDataGateway, err := convertAndCheckGateways(configGateways)
+func NewHostsFinder(configGateways []config.GatewayConfig, healthProvider GatewaysHealthProvider) (*hostsFinder, error) {
+	if check.IfNil(healthProvider) {
+		return nil, errNilGatewaysHealthProvider
+	}
+
+	inFlight := newInFlightCounter()
+	gateways, latestDataGateway, err := convertAndCheckGateways(configGateways, inFlight)
 	if err != nil {
 		return nil, err
 	}
@@ -37,15 +48,17 @@ func NewHostsFinder(configGateways []config.GatewayConfig) (*hostsFinder, error)
 	return &hostsFinder{
 		gateways:          gateways,
 		latestDataGateway: latestDataGateway,
+		inFlight:          inFlight,
+		healthProvider:    healthProvider,
 	}, nil
 }
 
-func convertAndCheckGateways(configGateways []config.GatewayConfig) ([]gatewayConfig, *gatewayConfig, error) {
+func convertAndCheckGateways(configGateways []config.GatewayConfig, inFlight *inFlightCounter) ([]gatewayConfig, *gatewayConfig, error) {
 	if len(configGateways) == 0 {
 		return nil, nil, errNoGatewayDefined
 	}
 
-	gatewayConfigs, latestDataConfig, err := convertGateways(configGateways)
+	gatewayConfigs, latestDataConfig, err := convertGateways(configGateways, inFlight)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -59,15 +72,37 @@ func convertAndCheckGateways(configGateways []config.GatewayConfig) ([]gatewayCo
 		return nil, nil, err
 	}
 
+	err = resolveFallbacks(gatewayConfigs, latestDataConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	return gatewayConfigs, latestDataConfig, nil
 }
 
-func convertGateways(configGateways []config.GatewayConfig) ([]gatewayConfig, *gatewayConfig, error) {
+func convertGateways(configGateways []config.GatewayConfig, inFlight *inFlightCounter) ([]gatewayConfig, *gatewayConfig, error) {
 	gatewayConfigs := make([]gatewayConfig, len(configGateways))
 	var latestDataConfig *gatewayConfig
 	for i, cfg := range configGateways {
 		gatewayConfigs[i].GatewayConfig = cfg
 
+		for j, replica := range cfg.Replicas {
+			if len(replica.URL) == 0 {
+				return nil, nil, fmt.Errorf("%w for replica %d of the gateway at index %d with URL %s", errEmptyReplicaURL, j, i, cfg.URL)
+			}
+		}
+
+		err := checkUpstreamSettings(cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w at index %d with URL %s", err, i, cfg.URL)
+		}
+
+		balancer, err := createLoadBalancer(cfg, inFlight)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w at index %d with URL %s", err, i, cfg.URL)
+		}
+		gatewayConfigs[i].balancer = balancer
+
 		val, err := strconv.Atoi(cfg.EpochStart)
 		if err != nil {
 			return nil, nil, fmt.Errorf("%w for epoch start at index %d with URL %s", err, i, cfg.URL)
@@ -120,6 +155,21 @@ func convertGateways(configGateways []config.GatewayConfig) ([]gatewayConfig, *g
 	return gatewayConfigs, latestDataConfig, nil
 }
 
+// checkUpstreamSettings checks the path rewrites and the basic auth credentials of a gateway
+func checkUpstreamSettings(cfg config.GatewayConfig) error {
+	for _, pathRewrite := range cfg.PathRewrites {
+		if !strings.HasPrefix(pathRewrite.Prefix, "/") || !strings.HasPrefix(pathRewrite.Replacement, "/") {
+			return fmt.Errorf("%w, the prefix (%s) and the replacement (%s) should start with /",
+				errInvalidPathRewrite, pathRewrite.Prefix, pathRewrite.Replacement)
+		}
+	}
+	if len(cfg.BasicAuthPassword) > 0 && len(cfg.BasicAuthUsername) == 0 {
+		return fmt.Errorf("%w, the password is set without a username", errInvalidBasicAuth)
+	}
+
+	return nil
+}
+
 func checkStartAndEndIntervals(gatewayConfigs []gatewayConfig) error {
 	currentEpoch := -1
 	currentNonce := -1
@@ -139,47 +189,140 @@ func checkStartAndEndIntervals(gatewayConfigs []gatewayConfig) error {
 	return nil
 }
 
-// FindHost tries to find a matching host based on the URL values. Errors if it can not find a suitable host
+// resolveFallbacks links each gateway with the gateway named by its Fallback value. The fallback gateway is trusted to
+// also hold the data of the gateway it backs up.
+func resolveFallbacks(gatewayConfigs []gatewayConfig, latestDataConfig *gatewayConfig) error {
+	for i := range gatewayConfigs {
+		cfg := &gatewayConfigs[i]
+		if len(cfg.Fallback) == 0 {
+			continue
+		}
+
+		for j := range gatewayConfigs {
+			if i != j && gatewayConfigs[j].Name == cfg.Fallback {
+				cfg.fallback = &gatewayConfigs[j]
+				break
+			}
+		}
+		if cfg.fallback == nil {
+			return fmt.Errorf("%w %s for the gateway %s with URL %s", errUnknownFallbackGateway, cfg.Fallback, cfg.Name, cfg.URL)
+		}
+
+		if cfg.hasLatestData && latestDataConfig != nil {
+			latestDataConfig.fallback = cfg.fallback
+		}
+	}
+
+	return nil
+}
+
+// FindHost tries to find a matching host based on the URL values. Errors if it can not find a suitable host.
+// The returned config holds the URL and the name of the healthy replica that should serve the request. The caller should
+// call ReleaseHost after the request is served.
 func (finder *hostsFinder) FindHost(urlValues map[string][]string) (config.GatewayConfig, error) {
+	gateway, err := finder.findGateway(urlValues)
+	if err != nil {
+		return config.GatewayConfig{}, err
+	}
+
+	replica, found := gateway.balancer.next(finder.healthProvider.IsHealthy)
+	if !found {
+		return config.GatewayConfig{}, fmt.Errorf("%w for the gateway %s, epochs %s - %s",
+			errNoHealthyGateway, gateway.Name, gateway.EpochStart, gateway.EpochEnd)
+	}
+	finder.inFlight.increment(replica.URL)
+
+	return createHost(gateway, replica), nil
+}
+
+// FindAlternativeHost returns another host able to serve the request, skipping the already tried URLs: a healthy
+// replica of the same gateway or, if none is left, a healthy replica of the gateway's fallback. The caller should call
+// ReleaseHost after the request is served.
+func (finder *hostsFinder) FindAlternativeHost(urlValues map[string][]string, triedURLs []string) (config.GatewayConfig, error) {
+	gateway, err := finder.findGateway(urlValues)
+	if err != nil {
+		return config.GatewayConfig{}, err
+	}
+
+	isAvailable := func(url string) bool {
+		return finder.healthProvider.IsHealthy(url) && !slices.Contains(triedURLs, url)
+	}
+
+	candidates := []*gatewayConfig{gateway}
+	if gateway.fallback != nil {
+		candidates = append(candidates, gateway.fallback)
+	}
+
+	for _, candidate := range candidates {
+		replica, found := candidate.balancer.next(isAvailable)
+		if !found {
+			continue
+		}
+		finder.inFlight.increment(replica.URL)
+
+		return createHost(candidate, replica), nil
+	}
+
+	return config.GatewayConfig{}, fmt.Errorf("%w for the gateway %s, epochs %s - %s",
+		errNoAlternativeGateway, gateway.Name, gateway.EpochStart, gateway.EpochEnd)
+}
+
+func createHost(gateway *gatewayConfig, replica config.ReplicaConfig) config.GatewayConfig {
+	result := gateway.GatewayConfig
+	result.URL = replica.URL
+	result.Name = replica.Name
+	result.Weight = replica.Weight
+
+	return result
+}
+
+// ReleaseHost marks that the request previously routed to the provided host was served
+func (finder *hostsFinder) ReleaseHost(host config.GatewayConfig) {
+	finder.inFlight.decrement(host.URL)
+}
+
+func (finder *hostsFinder) findGateway(urlValues map[string][]string) (*gatewayConfig, error) {
 	if urlValues == nil {
-		return config.GatewayConfig{}, fmt.Errorf("%w: %s", errCanNotDetermineSuitableHost, errNilMap.Error())
+		return nil, fmt.Errorf("%w: %s", errCanNotDetermineSuitableHost, errNilMap.Error())
 	}
 
 	nonce, nonceFound, err := finder.parseToUint64(urlValues, UrlParameterBlockNonce)
 	if err != nil {
-		return config.GatewayConfig{}, err
+		return nil, err
 	}
 
 	epoch, epochFound, err := finder.parseToUint64(urlValues, UrlParameterHintEpoch)
 	if err != nil {
-		return config.GatewayConfig{}, err
+		return nil, err
 	}
 
 	if !nonceFound && !epochFound {
 		if finder.latestDataGateway == nil {
-			return config.GatewayConfig{}, errNoLatestDataGatewayDefined
+			return nil, errNoLatestDataGatewayDefined
 		}
 
-		return finder.latestDataGateway.GatewayConfig, nil
+		return finder.latestDataGateway, nil
 	}
 
 	if nonceFound {
-		for _, cfg := range finder.gateways {
+		for i := range finder.gateways {
+			cfg := &finder.gateways[i]
 			if cfg.nonceStart <= nonce && nonce <= cfg.nonceEnd {
-				return cfg.GatewayConfig, nil
+				return cfg, nil
 			}
 		}
 
-		return config.GatewayConfig{}, fmt.Errorf("%w for nonce %d", errNoGatewayDefined, nonce)
+		return nil, fmt.Errorf("%w for nonce %d", errNoGatewayDefined, nonce)
 	}
 
-	for _, cfg := range finder.gateways {
+	for i := range finder.gateways {
+		cfg := &finder.gateways[i]
 		if cfg.epochStart <= epoch && epoch <= cfg.epochEnd {
-			return cfg.GatewayConfig, nil
+			return cfg, nil
 		}
 	}
 
-	return config.GatewayConfig{}, fmt.Errorf("%w for epoch %d", errNoGatewayDefined, epoch)
+	return nil, fmt.Errorf("%w for epoch %d", errNoGatewayDefined, epoch)
 }
 
 func (finder *hostsFinder) parseToUint64(urlValues map[string][]string, key string) (uint64, bool, error) {
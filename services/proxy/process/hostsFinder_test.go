@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,10 +38,17 @@ func createTestConfigs() []config.GatewayConfig {
 func TestNewHostsFinder(t *testing.T) {
 	t.Parallel()
 
+	t.Run("nil gateways health provider should error", func(t *testing.T) {
+		t.Parallel()
+
+		finder, err := NewHostsFinder(createTestConfigs(), nil)
+		assert.Equal(t, errNilGatewaysHealthProvider, err)
+		assert.Nil(t, finder)
+	})
 	t.Run("no gateways defined, should error", func(t *testing.T) {
 		t.Parallel()
 
-		finder, err := NewHostsFinder(make([]config.GatewayConfig, 0))
+		finder, err := NewHostsFinder(make([]config.GatewayConfig, 0), &testscommon.GatewaysHealthProviderStub{})
 		assert.Equal(t, errNoGatewayDefined, err)
 		assert.Nil(t, finder)
 		assert.True(t, finder.IsInterfaceNil())
@@ -50,7 +58,7 @@ func TestNewHostsFinder(t *testing.T) {
 
 		cfg := createTestConfigs()
 		cfg[1].EpochStart = "NaN"
-		finder, err := NewHostsFinder(cfg)
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
 		assert.Contains(t, err.Error(), "epoch start at index 1")
 		assert.Nil(t, finder)
 		assert.True(t, finder.IsInterfaceNil())
@@ -60,7 +68,7 @@ func TestNewHostsFinder(t *testing.T) {
 
 		cfg := createTestConfigs()
 		cfg[1].NonceStart = "NaN"
-		finder, err := NewHostsFinder(cfg)
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
 		assert.Contains(t, err.Error(), "nonce start at index 1")
 		assert.Nil(t, finder)
 		assert.True(t, finder.IsInterfaceNil())
@@ -70,7 +78,7 @@ func TestNewHostsFinder(t *testing.T) {
 
 		cfg := createTestConfigs()
 		cfg = append(cfg, cfg[0])
-		finder, err := NewHostsFinder(cfg)
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
 		assert.ErrorIs(t, err, errMoreThanOneLatestDataGatewayFound)
 		assert.Nil(t, finder)
 		assert.True(t, finder.IsInterfaceNil())
@@ -80,7 +88,7 @@ func TestNewHostsFinder(t *testing.T) {
 
 		cfg := createTestConfigs()
 		cfg[1].EpochEnd = "NaN"
-		finder, err := NewHostsFinder(cfg)
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
 		assert.Contains(t, err.Error(), "epoch end at index 1")
 		assert.Nil(t, finder)
 		assert.True(t, finder.IsInterfaceNil())
@@ -90,7 +98,7 @@ func TestNewHostsFinder(t *testing.T) {
 
 		cfg := createTestConfigs()
 		cfg[1].NonceEnd = "NaN"
-		finder, err := NewHostsFinder(cfg)
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
 		assert.Contains(t, err.Error(), "nonce end at index 1")
 		assert.Nil(t, finder)
 		assert.True(t, finder.IsInterfaceNil())
@@ -100,7 +108,7 @@ func TestNewHostsFinder(t *testing.T) {
 
 		cfg := createTestConfigs()
 		cfg[1].EpochStart = "50"
-		finder, err := NewHostsFinder(cfg)
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
 		assert.ErrorIs(t, err, errBadGatewayInterval)
 		assert.Contains(t, err.Error(), "when checking epoch end & epoch start at index 1")
 		assert.Nil(t, finder)
@@ -111,7 +119,7 @@ func TestNewHostsFinder(t *testing.T) {
 
 		cfg := createTestConfigs()
 		cfg[1].NonceStart = "5000"
-		finder, err := NewHostsFinder(cfg)
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
 		assert.ErrorIs(t, err, errBadGatewayInterval)
 		assert.Contains(t, err.Error(), "when checking nonce end & nonce start at index 1")
 		assert.Nil(t, finder)
@@ -122,7 +130,7 @@ func TestNewHostsFinder(t *testing.T) {
 
 		cfg := createTestConfigs()
 		cfg[1].NonceEnd = "4998"
-		finder, err := NewHostsFinder(cfg)
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
 		assert.ErrorIs(t, err, errUnexpectedIntervalStart)
 		assert.Contains(t, err.Error(), "EpochStart: 50, current epoch: 49, NonceStart: 5000, current nonce: 4998")
 		assert.Nil(t, finder)
@@ -131,7 +139,7 @@ func TestNewHostsFinder(t *testing.T) {
 	t.Run("should work", func(t *testing.T) {
 		t.Parallel()
 
-		finder, err := NewHostsFinder(createTestConfigs())
+		finder, err := NewHostsFinder(createTestConfigs(), &testscommon.GatewaysHealthProviderStub{})
 		assert.Nil(t, err)
 		assert.NotNil(t, finder)
 		assert.False(t, finder.IsInterfaceNil())
@@ -181,9 +189,115 @@ func TestNewHostsFinder(t *testing.T) {
 			},
 		}
 		assert.NotNil(t, finder.latestDataGateway)
+		assert.NotNil(t, finder.latestDataGateway.balancer)
+		assert.True(t, finder.latestDataGateway.balancer == finder.gateways[2].balancer) // same instance
+
+		latestDataGateway := *finder.latestDataGateway
+		latestDataGateway.balancer = nil
+		for i := range finder.gateways {
+			assert.NotNil(t, finder.gateways[i].balancer)
+			finder.gateways[i].balancer = nil
+		}
 
 		assert.Equal(t, expectedGateways, finder.gateways)
-		assert.Equal(t, expectedGateways[2], *finder.latestDataGateway)
+		assert.Equal(t, expectedGateways[2], latestDataGateway)
+	})
+	t.Run("unknown load balancer should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createTestConfigs()
+		cfg[2].LoadBalancer = "random"
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
+		assert.ErrorIs(t, err, errUnknownLoadBalancer)
+		assert.Contains(t, err.Error(), "random at index 2 with URL URL3")
+		assert.Nil(t, finder)
+	})
+	t.Run("empty replica URL should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createTestConfigs()
+		cfg[1].Replicas = []config.ReplicaConfig{
+			{
+				URL: "URL2-A",
+			},
+			{
+				URL: "",
+			},
+		}
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
+		assert.ErrorIs(t, err, errEmptyReplicaURL)
+		assert.Contains(t, err.Error(), "for replica 1 of the gateway at index 1 with URL URL2")
+		assert.Nil(t, finder)
+	})
+	t.Run("invalid path rewrite should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createTestConfigs()
+		cfg[1].PathRewrites = []config.PathRewriteConfig{
+			{
+				Prefix:      "/",
+				Replacement: "/mainnet/",
+			},
+			{
+				Prefix:      "network",
+				Replacement: "/mainnet/network",
+			},
+		}
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
+		assert.ErrorIs(t, err, errInvalidPathRewrite)
+		assert.Contains(t, err.Error(), "prefix (network) and the replacement (/mainnet/network) should start with / at index 1 with URL URL2")
+		assert.Nil(t, finder)
+	})
+	t.Run("basic auth password without username should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createTestConfigs()
+		cfg[2].BasicAuthPassword = "pass"
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
+		assert.ErrorIs(t, err, errInvalidBasicAuth)
+		assert.Contains(t, err.Error(), "at index 2 with URL URL3")
+		assert.Nil(t, finder)
+	})
+	t.Run("replicas should inherit the upstream settings of their gateway", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createTestConfigs()
+		cfg[1].Headers = map[string]string{"Authorization": "Bearer token"}
+		cfg[1].BasicAuthUsername = "user"
+		cfg[1].PathRewrites = []config.PathRewriteConfig{{Prefix: "/", Replacement: "/mainnet/"}}
+		cfg[1].Replicas = []config.ReplicaConfig{{URL: "URL2-A"}}
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
+		assert.Nil(t, err)
+
+		for _, expectedURL := range []string{"URL2", "URL2-A"} {
+			host, errFind := finder.FindHost(map[string][]string{UrlParameterHintEpoch: {"5"}})
+			assert.Nil(t, errFind)
+			assert.Equal(t, expectedURL, host.URL)
+			assert.Equal(t, cfg[1].Headers, host.Headers)
+			assert.Equal(t, "user", host.BasicAuthUsername)
+			assert.Equal(t, cfg[1].PathRewrites, host.PathRewrites)
+		}
+	})
+	t.Run("unknown fallback gateway should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createTestConfigs()
+		cfg[1].Name = "archive"
+		cfg[1].Fallback = "missing"
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
+		assert.ErrorIs(t, err, errUnknownFallbackGateway)
+		assert.Contains(t, err.Error(), "missing for the gateway archive with URL URL2")
+		assert.Nil(t, finder)
+	})
+	t.Run("fallback on the same gateway should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createTestConfigs()
+		cfg[1].Name = "archive"
+		cfg[1].Fallback = "archive"
+		finder, err := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
+		assert.ErrorIs(t, err, errUnknownFallbackGateway)
+		assert.Nil(t, finder)
 	})
 }
 
@@ -193,7 +307,7 @@ func TestHostsFinder_FindHost(t *testing.T) {
 	t.Run("nil url values map should error", func(t *testing.T) {
 		t.Parallel()
 
-		finder, _ := NewHostsFinder(createTestConfigs())
+		finder, _ := NewHostsFinder(createTestConfigs(), &testscommon.GatewaysHealthProviderStub{})
 		cfg, err := finder.FindHost(nil)
 		assert.Empty(t, cfg.URL)
 		assert.ErrorIs(t, err, errCanNotDetermineSuitableHost)
@@ -202,7 +316,7 @@ func TestHostsFinder_FindHost(t *testing.T) {
 	t.Run("nil url values map should error", func(t *testing.T) {
 		t.Parallel()
 
-		finder, _ := NewHostsFinder(createTestConfigs())
+		finder, _ := NewHostsFinder(createTestConfigs(), &testscommon.GatewaysHealthProviderStub{})
 		cfg, err := finder.FindHost(nil)
 		assert.Empty(t, cfg.URL)
 		assert.ErrorIs(t, err, errCanNotDetermineSuitableHost)
@@ -211,7 +325,7 @@ func TestHostsFinder_FindHost(t *testing.T) {
 	t.Run("no nonce or epoch provided should return the latest URL", func(t *testing.T) {
 		t.Parallel()
 
-		finder, _ := NewHostsFinder(createTestConfigs())
+		finder, _ := NewHostsFinder(createTestConfigs(), &testscommon.GatewaysHealthProviderStub{})
 		cfg, err := finder.FindHost(make(map[string][]string))
 		assert.Nil(t, err)
 		assert.Equal(t, "URL1", cfg.URL)
@@ -222,7 +336,7 @@ func TestHostsFinder_FindHost(t *testing.T) {
 		configs := createTestConfigs()
 		configs[0].EpochEnd = "900"
 		configs[0].NonceEnd = "90000"
-		finder, _ := NewHostsFinder(configs)
+		finder, _ := NewHostsFinder(configs, &testscommon.GatewaysHealthProviderStub{})
 		cfg, err := finder.FindHost(make(map[string][]string))
 		assert.Empty(t, cfg.URL)
 		assert.ErrorIs(t, err, errNoLatestDataGatewayDefined)
@@ -230,7 +344,7 @@ func TestHostsFinder_FindHost(t *testing.T) {
 	t.Run("no value for the nonce key should error", func(t *testing.T) {
 		t.Parallel()
 
-		finder, _ := NewHostsFinder(createTestConfigs())
+		finder, _ := NewHostsFinder(createTestConfigs(), &testscommon.GatewaysHealthProviderStub{})
 		urlValues := map[string][]string{
 			UrlParameterBlockNonce: nil,
 		}
@@ -242,7 +356,7 @@ func TestHostsFinder_FindHost(t *testing.T) {
 	t.Run("not a number on index 0 for the nonce key should error", func(t *testing.T) {
 		t.Parallel()
 
-		finder, _ := NewHostsFinder(createTestConfigs())
+		finder, _ := NewHostsFinder(createTestConfigs(), &testscommon.GatewaysHealthProviderStub{})
 		urlValues := map[string][]string{
 			UrlParameterBlockNonce: {"NaN"},
 		}
@@ -253,7 +367,7 @@ func TestHostsFinder_FindHost(t *testing.T) {
 	t.Run("no value for the epoch key should error", func(t *testing.T) {
 		t.Parallel()
 
-		finder, _ := NewHostsFinder(createTestConfigs())
+		finder, _ := NewHostsFinder(createTestConfigs(), &testscommon.GatewaysHealthProviderStub{})
 		urlValues := map[string][]string{
 			UrlParameterHintEpoch: nil,
 		}
@@ -265,7 +379,7 @@ func TestHostsFinder_FindHost(t *testing.T) {
 	t.Run("not a number on index 0 for the epoch key should error", func(t *testing.T) {
 		t.Parallel()
 
-		finder, _ := NewHostsFinder(createTestConfigs())
+		finder, _ := NewHostsFinder(createTestConfigs(), &testscommon.GatewaysHealthProviderStub{})
 		urlValues := map[string][]string{
 			UrlParameterHintEpoch: {"NaN"},
 		}
@@ -280,7 +394,7 @@ func TestHostsFinder_FindHost(t *testing.T) {
 		configs[0].NonceEnd = "90000"
 		configs[0].EpochEnd = "900"
 
-		finder, _ := NewHostsFinder(configs)
+		finder, _ := NewHostsFinder(configs, &testscommon.GatewaysHealthProviderStub{})
 
 		t.Run("with providing out of bound nonce", func(t *testing.T) {
 			t.Parallel()
@@ -321,7 +435,7 @@ func TestHostsFinder_FindHost(t *testing.T) {
 	t.Run("should work", func(t *testing.T) {
 		t.Parallel()
 
-		finder, _ := NewHostsFinder(createTestConfigs())
+		finder, _ := NewHostsFinder(createTestConfigs(), &testscommon.GatewaysHealthProviderStub{})
 
 		t.Run("with providing nonce", func(t *testing.T) {
 			t.Parallel()
@@ -456,11 +570,215 @@ func TestHostsFinder_FindHost(t *testing.T) {
 	})
 }
 
+func TestHostsFinder_FindHostWithReplicas(t *testing.T) {
+	t.Parallel()
+
+	createConfigsWithReplicas := func(loadBalancer string) []config.GatewayConfig {
+		cfg := createTestConfigs()
+		cfg[0].Name = "latest"
+		cfg[0].LoadBalancer = loadBalancer
+		cfg[0].Weight = 3
+		cfg[0].Replicas = []config.ReplicaConfig{
+			{
+				URL:  "URL1-A",
+				Name: "latest-A",
+			},
+			{
+				URL: "URL1-B",
+			},
+		}
+
+		return cfg
+	}
+	findHosts := func(finder *hostsFinder, numCalls int) []string {
+		names := make([]string, 0, numCalls)
+		for i := 0; i < numCalls; i++ {
+			host, err := finder.FindHost(make(map[string][]string))
+			assert.Nil(t, err)
+			names = append(names, host.Name)
+		}
+
+		return names
+	}
+
+	t.Run("round-robin", func(t *testing.T) {
+		t.Parallel()
+
+		finder, err := NewHostsFinder(createConfigsWithReplicas(""), &testscommon.GatewaysHealthProviderStub{})
+		assert.Nil(t, err)
+
+		expectedNames := []string{"latest", "latest-A", "latest#2", "latest", "latest-A", "latest#2"}
+		assert.Equal(t, expectedNames, findHosts(finder, len(expectedNames)))
+	})
+	t.Run("weighted", func(t *testing.T) {
+		t.Parallel()
+
+		finder, err := NewHostsFinder(createConfigsWithReplicas("WEIGHTED"), &testscommon.GatewaysHealthProviderStub{})
+		assert.Nil(t, err)
+
+		expectedNames := []string{"latest", "latest-A", "latest", "latest#2", "latest", "latest", "latest-A", "latest", "latest#2", "latest"}
+		assert.Equal(t, expectedNames, findHosts(finder, len(expectedNames)))
+	})
+	t.Run("least-outstanding", func(t *testing.T) {
+		t.Parallel()
+
+		finder, err := NewHostsFinder(createConfigsWithReplicas(LoadBalancerLeastOutstanding), &testscommon.GatewaysHealthProviderStub{})
+		assert.Nil(t, err)
+
+		host1, _ := finder.FindHost(make(map[string][]string))
+		host2, _ := finder.FindHost(make(map[string][]string))
+		host3, _ := finder.FindHost(make(map[string][]string))
+		assert.ElementsMatch(t, []string{"URL1", "URL1-A", "URL1-B"}, []string{host1.URL, host2.URL, host3.URL})
+
+		finder.ReleaseHost(host2)
+		for i := 0; i < 5; i++ {
+			host, _ := finder.FindHost(make(map[string][]string))
+			assert.Equal(t, host2.URL, host.URL)
+			finder.ReleaseHost(host)
+		}
+	})
+	t.Run("range data is kept", func(t *testing.T) {
+		t.Parallel()
+
+		finder, _ := NewHostsFinder(createConfigsWithReplicas(""), &testscommon.GatewaysHealthProviderStub{})
+		_, _ = finder.FindHost(make(map[string][]string))
+		host, err := finder.FindHost(make(map[string][]string))
+		assert.Nil(t, err)
+		assert.Equal(t, "URL1-A", host.URL)
+		assert.Equal(t, "latest-A", host.Name)
+		assert.Equal(t, "10000", host.NonceStart)
+		assert.Equal(t, "100", host.EpochStart)
+
+		urlValues := map[string][]string{
+			UrlParameterHintEpoch: {"0"},
+		}
+		host, err = finder.FindHost(urlValues)
+		assert.Nil(t, err)
+		assert.Equal(t, "URL2", host.URL)
+	})
+	t.Run("unhealthy replicas are skipped", func(t *testing.T) {
+		t.Parallel()
+
+		healthProvider := &testscommon.GatewaysHealthProviderStub{
+			IsHealthyCalled: func(url string) bool {
+				return url != "URL1-A"
+			},
+		}
+		finder, _ := NewHostsFinder(createConfigsWithReplicas(""), healthProvider)
+
+		expectedNames := []string{"latest", "latest#2", "latest#2", "latest", "latest#2", "latest#2"}
+		assert.Equal(t, expectedNames, findHosts(finder, len(expectedNames)))
+	})
+	t.Run("no healthy replica should error", func(t *testing.T) {
+		t.Parallel()
+
+		healthProvider := &testscommon.GatewaysHealthProviderStub{
+			IsHealthyCalled: func(url string) bool {
+				return false
+			},
+		}
+		finder, _ := NewHostsFinder(createConfigsWithReplicas(""), healthProvider)
+
+		host, err := finder.FindHost(make(map[string][]string))
+		assert.ErrorIs(t, err, errNoHealthyGateway)
+		assert.Contains(t, err.Error(), "latest")
+		assert.Empty(t, host.URL)
+	})
+}
+
+func TestHostsFinder_FindAlternativeHost(t *testing.T) {
+	t.Parallel()
+
+	createConfigsWithFallback := func() []config.GatewayConfig {
+		cfg := createTestConfigs()
+		cfg[0].Name = "latest"
+		cfg[1].Name = "archive"
+		cfg[1].Fallback = "latest"
+		cfg[1].Replicas = []config.ReplicaConfig{
+			{
+				URL:  "URL2-A",
+				Name: "archive-A",
+			},
+		}
+		cfg[2].Name = "middle"
+
+		return cfg
+	}
+	urlValues := map[string][]string{
+		UrlParameterHintEpoch: {"10"},
+	}
+
+	t.Run("invalid url values should error", func(t *testing.T) {
+		t.Parallel()
+
+		finder, _ := NewHostsFinder(createConfigsWithFallback(), &testscommon.GatewaysHealthProviderStub{})
+		host, err := finder.FindAlternativeHost(nil, []string{"URL2"})
+		assert.ErrorIs(t, err, errCanNotDetermineSuitableHost)
+		assert.Empty(t, host.URL)
+	})
+	t.Run("should return the replicas, then the fallback gateway", func(t *testing.T) {
+		t.Parallel()
+
+		finder, _ := NewHostsFinder(createConfigsWithFallback(), &testscommon.GatewaysHealthProviderStub{})
+		host, err := finder.FindAlternativeHost(urlValues, []string{"URL2"})
+		assert.Nil(t, err)
+		assert.Equal(t, "URL2-A", host.URL)
+		assert.Equal(t, "archive-A", host.Name)
+		assert.Equal(t, "0", host.EpochStart)
+
+		host, err = finder.FindAlternativeHost(urlValues, []string{"URL2", "URL2-A"})
+		assert.Nil(t, err)
+		assert.Equal(t, "URL1", host.URL)
+		assert.Equal(t, "latest", host.Name)
+		assert.Equal(t, "100", host.EpochStart)
+
+		host, err = finder.FindAlternativeHost(urlValues, []string{"URL2", "URL2-A", "URL1"})
+		assert.ErrorIs(t, err, errNoAlternativeGateway)
+		assert.Contains(t, err.Error(), "archive")
+		assert.Empty(t, host.URL)
+	})
+	t.Run("unhealthy hosts are skipped", func(t *testing.T) {
+		t.Parallel()
+
+		healthProvider := &testscommon.GatewaysHealthProviderStub{
+			IsHealthyCalled: func(url string) bool {
+				return url != "URL2-A"
+			},
+		}
+		finder, _ := NewHostsFinder(createConfigsWithFallback(), healthProvider)
+		host, err := finder.FindAlternativeHost(urlValues, []string{"URL2"})
+		assert.Nil(t, err)
+		assert.Equal(t, "URL1", host.URL)
+	})
+	t.Run("gateway without fallback should error when all replicas were tried", func(t *testing.T) {
+		t.Parallel()
+
+		finder, _ := NewHostsFinder(createConfigsWithFallback(), &testscommon.GatewaysHealthProviderStub{})
+		values := map[string][]string{
+			UrlParameterHintEpoch: {"60"},
+		}
+		host, err := finder.FindAlternativeHost(values, []string{"URL3"})
+		assert.ErrorIs(t, err, errNoAlternativeGateway)
+		assert.Contains(t, err.Error(), "middle")
+		assert.Empty(t, host.URL)
+	})
+	t.Run("latest data gateway should use its fallback", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createConfigsWithFallback()
+		cfg[0].Fallback = "middle"
+		finder, _ := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
+		host, err := finder.FindAlternativeHost(make(map[string][]string), []string{"URL1"})
+		assert.Nil(t, err)
+		assert.Equal(t, "URL3", host.URL)
+	})
+}
+
 func TestHostsFinder_LoadedGateways(t *testing.T) {
 	t.Parallel()
 
 	cfg := createTestConfigs()
-	finder, _ := NewHostsFinder(cfg)
+	finder, _ := NewHostsFinder(cfg, &testscommon.GatewaysHealthProviderStub{})
 	expectedResult := []config.GatewayConfig{
 		cfg[1],
 		cfg[2],
@@ -1,10 +1,14 @@
 package process
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 )
 
 const xServiceApiKey = "X-Service-Api-Key"
@@ -33,6 +37,21 @@ func (r *httpRequester) DoRequest(method string, url string, apiKey string, resu
 		request.Header.Add(xServiceApiKey, apiKey)
 	}
 
+	return r.do(request, result)
+}
+
+// DoGatewayRequest executes a GET request on the provided gateway route, applying the gateway's path rewrites, headers
+// and credentials
+func (r *httpRequester) DoGatewayRequest(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+	request, err := common.NewUpstreamRequest(ctx, http.MethodGet, gateway, route, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	return r.do(request, result)
+}
+
+func (r *httpRequester) do(request *http.Request, result any) error {
 	resp, err := r.client.Do(request)
 	if err != nil {
 		return err
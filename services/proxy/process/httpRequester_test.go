@@ -1,6 +1,7 @@
 package process
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -114,6 +116,84 @@ func TestHttpRequester_DoRequest(t *testing.T) {
 	})
 }
 
+func TestHttpRequester_DoGatewayRequest(t *testing.T) {
+	t.Parallel()
+
+	type responseStruct struct {
+		Field string `json:"field"`
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "pass" || r.Header.Get("X-Squad") != "archive" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/mainnet/network/config" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(responseStruct{Field: "value"})
+	})
+
+	gateway := config.GatewayConfig{
+		Headers:           map[string]string{"X-Squad": "archive"},
+		BasicAuthUsername: "user",
+		BasicAuthPassword: "pass",
+		PathRewrites:      []config.PathRewriteConfig{{Prefix: "/network/", Replacement: "/mainnet/network/"}},
+	}
+
+	t.Run("should apply the gateway's rewrites, headers and credentials", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		gatewayWithURL := gateway
+		gatewayWithURL.URL = server.URL
+
+		requester := NewHttpRequester(time.Second)
+		var result responseStruct
+		err := requester.DoGatewayRequest(context.Background(), gatewayWithURL, "/network/config", &result)
+		assert.NoError(t, err)
+		assert.Equal(t, "value", result.Field)
+	})
+	t.Run("without credentials the gateway should reject the request", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		gatewayWithoutAuth := gateway
+		gatewayWithoutAuth.URL = server.URL
+		gatewayWithoutAuth.BasicAuthUsername = ""
+		gatewayWithoutAuth.BasicAuthPassword = ""
+
+		requester := NewHttpRequester(time.Second)
+		var result responseStruct
+		err := requester.DoGatewayRequest(context.Background(), gatewayWithoutAuth, "/network/config", &result)
+		assert.True(t, errors.Is(err, errUnexpectedStatusCode))
+	})
+	t.Run("canceled context should error", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		gatewayWithURL := gateway
+		gatewayWithURL.URL = server.URL
+
+		requester := NewHttpRequester(time.Second)
+		err := requester.DoGatewayRequest(ctx, gatewayWithURL, "/network/config", nil)
+		assert.True(t, errors.Is(err, context.Canceled))
+	})
+}
+
 func TestHttpRequester_IsInterfaceNil(t *testing.T) {
 	t.Parallel()
 
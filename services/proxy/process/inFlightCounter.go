@@ -0,0 +1,46 @@
+package process
+
+import (
+	"sync"
+)
+
+// inFlightCounter keeps track of how many requests are currently being served by each upstream URL
+type inFlightCounter struct {
+	mut      sync.RWMutex
+	counters map[string]int64
+}
+
+func newInFlightCounter() *inFlightCounter {
+	return &inFlightCounter{
+		counters: make(map[string]int64),
+	}
+}
+
+func (counter *inFlightCounter) increment(url string) {
+	counter.mut.Lock()
+	counter.counters[url]++
+	counter.mut.Unlock()
+}
+
+func (counter *inFlightCounter) decrement(url string) {
+	counter.mut.Lock()
+	defer counter.mut.Unlock()
+
+	value, found := counter.counters[url]
+	if !found {
+		return
+	}
+	if value <= 1 {
+		delete(counter.counters, url)
+		return
+	}
+
+	counter.counters[url] = value - 1
+}
+
+func (counter *inFlightCounter) get(url string) int64 {
+	counter.mut.RLock()
+	defer counter.mut.RUnlock()
+
+	return counter.counters[url]
+}
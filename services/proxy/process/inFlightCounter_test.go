@@ -0,0 +1,60 @@
+package process
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInFlightCounter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		counter := newInFlightCounter()
+		assert.Equal(t, int64(0), counter.get("URL1"))
+
+		counter.increment("URL1")
+		counter.increment("URL1")
+		counter.increment("URL2")
+		assert.Equal(t, int64(2), counter.get("URL1"))
+		assert.Equal(t, int64(1), counter.get("URL2"))
+
+		counter.decrement("URL1")
+		counter.decrement("URL2")
+		assert.Equal(t, int64(1), counter.get("URL1"))
+		assert.Equal(t, int64(0), counter.get("URL2"))
+		assert.Equal(t, 1, len(counter.counters))
+	})
+	t.Run("decrement on missing URL should not go below 0", func(t *testing.T) {
+		t.Parallel()
+
+		counter := newInFlightCounter()
+		counter.decrement("URL1")
+		assert.Equal(t, int64(0), counter.get("URL1"))
+
+		counter.increment("URL1")
+		assert.Equal(t, int64(1), counter.get("URL1"))
+	})
+	t.Run("concurrent operations should work", func(t *testing.T) {
+		t.Parallel()
+
+		counter := newInFlightCounter()
+		numCalls := 1000
+		wg := sync.WaitGroup{}
+		wg.Add(numCalls)
+		for i := 0; i < numCalls; i++ {
+			go func() {
+				counter.increment("URL")
+				_ = counter.get("URL")
+				counter.decrement("URL")
+				wg.Done()
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int64(0), counter.get("URL"))
+	})
+}
@@ -1,7 +1,10 @@
 package process
 
 import (
+	"context"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
@@ -10,19 +13,154 @@ import (
 // HostFinder is able to return a valid host based on a search criteria
 type HostFinder interface {
 	FindHost(urlValues map[string][]string) (config.GatewayConfig, error)
+	FindAlternativeHost(urlValues map[string][]string, triedURLs []string) (config.GatewayConfig, error)
+	ReleaseHost(host config.GatewayConfig)
 	LoadedGateways() []config.GatewayConfig
 	IsInterfaceNil() bool
 }
 
+// SwappableHostFinder is a HostFinder that can replace its underlying gateways at runtime
+type SwappableHostFinder interface {
+	HostFinder
+	Swap(hostFinder HostFinder) error
+}
+
+// GatewaysTester is able to probe a set of gateways, returning an error if one gateway is not responding
+type GatewaysTester interface {
+	TestGateways(gateways []config.GatewayConfig) error
+	IsInterfaceNil() bool
+}
+
+// GatewaysHealthProvider is able to tell if a gateway URL is healthy or not
+type GatewaysHealthProvider interface {
+	IsHealthy(url string) bool
+	IsInterfaceNil() bool
+}
+
+// GatewaysDiscoverer is able to fill the epoch and nonce ranges of the gateways that only define their URL
+type GatewaysDiscoverer interface {
+	DiscoverRanges(gateways []config.GatewayConfig) ([]config.GatewayConfig, error)
+	IsInterfaceNil() bool
+}
+
+// GatewayProber is able to probe a gateway upstream, returning an error if the gateway is not responding correctly
+type GatewayProber interface {
+	ProbeGateway(upstream config.GatewayConfig) error
+	IsInterfaceNil() bool
+}
+
+// PathValuesExtractor is able to extract the routing values (block nonce, hint epoch) embedded in a request path
+type PathValuesExtractor interface {
+	ExtractValues(requestPath string) map[string][]string
+	IsInterfaceNil() bool
+}
+
+// BodyValuesExtractor is able to extract the routing values (block nonce, hint epoch) from a request body
+type BodyValuesExtractor interface {
+	ExtractValues(requestPath string, body io.Reader) (map[string][]string, io.Reader, error)
+	IsInterfaceNil() bool
+}
+
+// HashEpochResolver is able to resolve the epoch of the block or transaction hash found in a request path
+type HashEpochResolver interface {
+	ExtractValues(ctx context.Context, requestPath string) map[string][]string
+	GetMetrics() map[string]uint64
+	IsInterfaceNil() bool
+}
+
+// HashEpochStorer is able to persist the epochs of the resolved block or transaction hashes
+type HashEpochStorer interface {
+	GetHashEpoch(hash string) (uint64, bool, error)
+	SaveHashEpoch(hash string, epoch uint64) error
+	IsInterfaceNil() bool
+}
+
+// TimestampResolver is able to convert a date & time into a block nonce
+type TimestampResolver interface {
+	ResolveNonce(timestamp string) (uint64, bool, error)
+	IsInterfaceNil() bool
+}
+
+// EpochStartsStorer is able to persist the epoch starts learned from the gateways
+type EpochStartsStorer interface {
+	GetEpochStarts(shard uint32) ([]common.EpochStartInfo, error)
+	SaveEpochStart(info common.EpochStartInfo) error
+	IsInterfaceNil() bool
+}
+
+// GatewayRequester is able to query a gateway route, applying the gateway's path rewrites, headers and credentials
+type GatewayRequester interface {
+	DoGatewayRequest(ctx context.Context, gateway config.GatewayConfig, route string, result any) error
+	IsInterfaceNil() bool
+}
+
+// RetryPolicy decides if a failed request should be sent again and how long to wait before doing so
+type RetryPolicy interface {
+	ShouldRetry(method string, attempts uint32, statusCode int, err error) bool
+	Backoff(attempts uint32) time.Duration
+	IsInterfaceNil() bool
+}
+
+// UpstreamClient is able to send the requests to the gateways and stream back their responses
+type UpstreamClient interface {
+	Do(request *http.Request, timeout time.Duration) (*http.Response, error)
+	CopyBody(destination io.Writer, source io.Reader) (int64, error)
+	IsInterfaceNil() bool
+}
+
+// ResponseCache is able to store the gateway responses of the requests for immutable data
+type ResponseCache interface {
+	Get(key string) (common.CachedResponse, bool)
+	Put(key string, response common.CachedResponse)
+	IsEnabled() bool
+	MaxEntrySize() uint64
+	IsInterfaceNil() bool
+}
+
+// RequestsCoalescer is able to merge the identical requests that are in flight at the same time, so only one of them
+// is sent to the gateway
+type RequestsCoalescer interface {
+	Do(ctx context.Context, key string, handler func() (common.CachedResponse, bool)) (response common.CachedResponse, shared bool, executed bool)
+	IsEnabled() bool
+	MaxResponseSize() uint64
+	GetMetrics() map[string]uint64
+	IsInterfaceNil() bool
+}
+
+// HeadersPolicy decides which headers are passed between the clients and the gateways
+type HeadersPolicy interface {
+	RequestID(header http.Header) string
+	CopyRequestHeader(destination http.Header, request *http.Request)
+	CopyResponseHeader(destination http.Header, source http.Header)
+	IsInterfaceNil() bool
+}
+
 // AccessChecker is able to check if the request should be processed or not
 type AccessChecker interface {
-	ShouldProcessRequest(header http.Header, requestURI string) (string, error)
+	ShouldProcessRequest(request *http.Request) (common.AccessResult, error)
+	CheckHost(result common.AccessResult, host config.GatewayConfig) error
+	ConsumeCredits(result common.AccessResult)
+	ReleaseRequest(result common.AccessResult)
 	IsInterfaceNil() bool
 }
 
 // KeyAccessProvider can decide if a provided key has or not query access
 type KeyAccessProvider interface {
-	IsKeyAllowed(key string) (string, common.AccountType, error)
+	GetKeyAccess(key string) (common.KeyAccess, error)
+	ConsumeKeyCredits(access common.KeyAccess, cost uint64, clientIP string)
+	IsInterfaceNil() bool
+}
+
+// ClientIPResolver is able to resolve the IP of the client that sent a request
+type ClientIPResolver interface {
+	ResolveClientIP(request *http.Request) string
+	IsInterfaceNil() bool
+}
+
+// KeyViolationsRecorder keeps the requests rejected by the restrictions of their access keys
+type KeyViolationsRecorder interface {
+	Record(violation common.KeyViolation)
+	GetViolations() []common.KeyViolation
 	IsInterfaceNil() bool
 }
 
@@ -33,6 +171,37 @@ type KeyCounter interface {
 	IsInterfaceNil() bool
 }
 
+// RateLimiter decides if an account can send one more request
+type RateLimiter interface {
+	Allow(username string, accountType common.AccountType) common.RateLimitResult
+	Sweep()
+	IsInterfaceNil() bool
+}
+
+// SlowLane is able to delay the throttled requests of a user, releasing them at a fixed rate
+type SlowLane interface {
+	Wait(ctx context.Context, username string) error
+	IsEnabled() bool
+	Sweep()
+	GetMetrics() map[string]uint64
+	IsInterfaceNil() bool
+}
+
+// TiersPolicy applies the limits of the subscription tiers that are not handled by the rate limiter
+type TiersPolicy interface {
+	IsEndpointAllowed(accountType common.AccountType, requestPath string) bool
+	AcquireRequest(username string, accountType common.AccountType) bool
+	ReleaseRequest(username string)
+	GetMetrics() map[string]uint64
+	IsInterfaceNil() bool
+}
+
+// CreditCostTable decides how many credits a request consumes
+type CreditCostTable interface {
+	GetCost(method string, requestPath string) uint64
+	IsInterfaceNil() bool
+}
+
 // PerformanceMonitor is able to store performance metrics
 type PerformanceMonitor interface {
 	AddPerformanceMetricAsync(label string)
@@ -0,0 +1,105 @@
+package process
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+)
+
+const headerOrigin = "Origin"
+const headerReferer = "Referer"
+const originWildcardPrefix = "*."
+
+// checkKeyRestrictions returns an error if the client IP or the request's origin do not match the key's restrictions.
+// The origin is read from the Origin header or, if missing, from the Referer header. A key restricted to some origins
+// rejects the requests not providing any of the two headers.
+func checkKeyRestrictions(restrictions common.KeyRestrictions, clientIP string, header http.Header) error {
+	if len(restrictions.AllowedCIDRs) > 0 && !isIPInCIDRs(clientIP, restrictions.AllowedCIDRs) {
+		return fmt.Errorf("%w: the client IP %s is not allowed", errKeyRestricted, clientIP)
+	}
+	if len(restrictions.AllowedOrigins) == 0 {
+		return nil
+	}
+
+	origin := requestOrigin(header)
+	if len(origin) == 0 {
+		return fmt.Errorf("%w: the request does not provide its origin", errKeyRestricted)
+	}
+	if !isOriginAllowed(origin, restrictions.AllowedOrigins) {
+		return fmt.Errorf("%w: the origin %s is not allowed", errKeyRestricted, origin)
+	}
+
+	return nil
+}
+
+// requestOrigin returns the request's Origin header or the scheme and the host of its Referer header
+func requestOrigin(header http.Header) string {
+	origin := strings.TrimSpace(header.Get(headerOrigin))
+	if len(origin) > 0 && origin != "null" {
+		return origin
+	}
+
+	referer, err := url.Parse(strings.TrimSpace(header.Get(headerReferer)))
+	if err != nil || len(referer.Scheme) == 0 || len(referer.Host) == 0 {
+		return ""
+	}
+
+	return referer.Scheme + "://" + referer.Host
+}
+
+func isIPInCIDRs(clientIP string, cidrs []string) bool {
+	addr, err := netip.ParseAddr(clientIP)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, cidr := range cidrs {
+		prefix, errParse := netip.ParsePrefix(cidr)
+		if errParse != nil {
+			continue
+		}
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isOriginAllowed returns true if the origin matches one of the scheme://host[:port] patterns. A pattern host starting
+// with *. matches all the subdomains of the remaining domain.
+func isOriginAllowed(origin string, patterns []string) bool {
+	originURL, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range patterns {
+		patternURL, errParse := url.Parse(pattern)
+		if errParse != nil {
+			continue
+		}
+		if !strings.EqualFold(originURL.Scheme, patternURL.Scheme) || originURL.Port() != patternURL.Port() {
+			continue
+		}
+		if isHostMatching(strings.ToLower(originURL.Hostname()), strings.ToLower(patternURL.Hostname())) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isHostMatching(host string, patternHost string) bool {
+	domain, isWildcard := strings.CutPrefix(patternHost, originWildcardPrefix)
+	if !isWildcard {
+		return host == patternHost
+	}
+
+	return strings.HasSuffix(host, "."+domain)
+}
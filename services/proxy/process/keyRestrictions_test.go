@@ -0,0 +1,102 @@
+package process
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestHeader(values map[string]string) http.Header {
+	header := make(http.Header)
+	for name, value := range values {
+		header.Set(name, value)
+	}
+
+	return header
+}
+
+func TestCheckKeyRestrictions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty restrictions should allow all the requests", func(t *testing.T) {
+		t.Parallel()
+
+		err := checkKeyRestrictions(common.KeyRestrictions{}, "1.2.3.4", make(http.Header))
+		assert.Nil(t, err)
+	})
+	t.Run("CIDRs should restrict the client IP", func(t *testing.T) {
+		t.Parallel()
+
+		restrictions := common.KeyRestrictions{
+			AllowedCIDRs: []string{"invalid", "10.0.0.0/8", "2001:db8::/32"},
+		}
+		assert.Nil(t, checkKeyRestrictions(restrictions, "10.1.2.3", make(http.Header)))
+		assert.Nil(t, checkKeyRestrictions(restrictions, "::ffff:10.1.2.3", make(http.Header)))
+		assert.Nil(t, checkKeyRestrictions(restrictions, "2001:db8::1", make(http.Header)))
+
+		err := checkKeyRestrictions(restrictions, "11.1.2.3", make(http.Header))
+		assert.True(t, errors.Is(err, errKeyRestricted))
+		assert.Contains(t, err.Error(), "11.1.2.3")
+
+		err = checkKeyRestrictions(restrictions, "not an IP", make(http.Header))
+		assert.True(t, errors.Is(err, errKeyRestricted))
+	})
+	t.Run("origins should restrict the Origin or the Referer header", func(t *testing.T) {
+		t.Parallel()
+
+		restrictions := common.KeyRestrictions{
+			AllowedOrigins: []string{"https://app.example.com", "https://*.example.org", "http://localhost:3000"},
+		}
+		allowedHeaders := []map[string]string{
+			{headerOrigin: "https://app.example.com"},
+			{headerOrigin: "HTTPS://App.Example.com"},
+			{headerOrigin: "https://a.b.example.org"},
+			{headerOrigin: "http://localhost:3000"},
+			{headerReferer: "https://app.example.com/page?a=b"},
+			{headerOrigin: "null", headerReferer: "https://wallet.example.org/"},
+		}
+		for _, values := range allowedHeaders {
+			assert.Nil(t, checkKeyRestrictions(restrictions, "1.2.3.4", createTestHeader(values)), values)
+		}
+
+		rejectedHeaders := []map[string]string{
+			{},
+			{headerOrigin: "http://app.example.com"},
+			{headerOrigin: "https://app.example.com:8443"},
+			{headerOrigin: "https://example.org"},
+			{headerOrigin: "https://evilexample.org"},
+			{headerOrigin: "http://localhost"},
+			{headerReferer: "/relative/path"},
+			{headerOrigin: "https://other.com", headerReferer: "https://app.example.com/"},
+		}
+		for _, values := range rejectedHeaders {
+			err := checkKeyRestrictions(restrictions, "1.2.3.4", createTestHeader(values))
+			assert.True(t, errors.Is(err, errKeyRestricted), values)
+		}
+	})
+	t.Run("both restrictions should be checked", func(t *testing.T) {
+		t.Parallel()
+
+		restrictions := common.KeyRestrictions{
+			AllowedCIDRs:   []string{"10.0.0.0/8"},
+			AllowedOrigins: []string{"https://app.example.com"},
+		}
+		header := createTestHeader(map[string]string{headerOrigin: "https://app.example.com"})
+		assert.Nil(t, checkKeyRestrictions(restrictions, "10.0.0.1", header))
+
+		err := checkKeyRestrictions(restrictions, "192.168.0.1", header)
+		assert.True(t, errors.Is(err, errKeyRestricted))
+	})
+}
+
+func TestRequestOrigin(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", requestOrigin(make(http.Header)))
+	assert.Equal(t, "https://a.com", requestOrigin(createTestHeader(map[string]string{headerOrigin: " https://a.com "})))
+	assert.Equal(t, "https://b.com:8080", requestOrigin(createTestHeader(map[string]string{headerReferer: "https://b.com:8080/path?query=1"})))
+	assert.Equal(t, "", requestOrigin(createTestHeader(map[string]string{headerReferer: "b.com/path"})))
+}
@@ -0,0 +1,84 @@
+package process
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+// isRequestInScope returns true if the key's scope allows the request's method and path. A route also allows all the
+// paths under it.
+func isRequestInScope(scope common.KeyScope, method string, requestPath string) bool {
+	if len(scope.Methods) > 0 && !containsMethod(scope.Methods, method) {
+		return false
+	}
+	if len(scope.Routes) > 0 && !isPathUnderEndpoints(requestPath, scope.Routes) {
+		return false
+	}
+
+	return true
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, allowedMethod := range methods {
+		if strings.EqualFold(allowedMethod, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkHostScope returns an error if the epochs range of the host is not inside the epochs range of the key's scope
+func checkHostScope(scope common.KeyScope, host config.GatewayConfig) error {
+	if len(scope.EpochStart) == 0 && isLatestEpoch(scope.EpochEnd) {
+		return nil
+	}
+
+	keyStart, keyEnd, keyHasLatest, err := parseEpochsRange(scope.EpochStart, scope.EpochEnd)
+	if err != nil {
+		return fmt.Errorf("%w: %s in the key's epochs range", errKeyOutOfScope, err.Error())
+	}
+	hostStart, hostEnd, hostHasLatest, err := parseEpochsRange(host.EpochStart, host.EpochEnd)
+	if err != nil {
+		return fmt.Errorf("%w: %s in the gateway's epochs range", errKeyOutOfScope, err.Error())
+	}
+
+	isInScope := hostStart >= keyStart && (keyHasLatest || (!hostHasLatest && hostEnd <= keyEnd))
+	if !isInScope {
+		return fmt.Errorf("%w: the request is served by a gateway holding the epochs %s - %s, the key is limited to the epochs %s - %s",
+			errKeyOutOfScope, host.EpochStart, host.EpochEnd, scope.EpochStart, scope.EpochEnd)
+	}
+
+	return nil
+}
+
+func isLatestEpoch(epoch string) bool {
+	return len(epoch) == 0 || strings.EqualFold(epoch, latestMarker)
+}
+
+// parseEpochsRange returns the start and the end of the range, an empty start meaning epoch 0. The returned flag is
+// true if the range ends with the latest epoch.
+func parseEpochsRange(epochStart string, epochEnd string) (uint64, uint64, bool, error) {
+	start := uint64(0)
+	if len(epochStart) > 0 {
+		var err error
+		start, err = strconv.ParseUint(epochStart, 10, 64)
+		if err != nil {
+			return 0, 0, false, fmt.Errorf("invalid start epoch %s", epochStart)
+		}
+	}
+	if isLatestEpoch(epochEnd) {
+		return start, 0, true, nil
+	}
+
+	end, err := strconv.ParseUint(epochEnd, 10, 64)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("invalid end epoch %s", epochEnd)
+	}
+
+	return start, end, false, nil
+}
@@ -0,0 +1,99 @@
+package process
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRequestInScope(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty scope should allow all requests", func(t *testing.T) {
+		t.Parallel()
+
+		assert.True(t, isRequestInScope(common.KeyScope{}, http.MethodPost, "/transaction/send"))
+		assert.True(t, isRequestInScope(common.KeyScope{EpochEnd: "100"}, http.MethodGet, "/address/erd1"))
+	})
+	t.Run("should check the methods", func(t *testing.T) {
+		t.Parallel()
+
+		scope := common.KeyScope{Methods: []string{http.MethodGet}}
+		assert.True(t, isRequestInScope(scope, http.MethodGet, "/transaction/send"))
+		assert.True(t, isRequestInScope(scope, "get", "/transaction/send"))
+		assert.False(t, isRequestInScope(scope, http.MethodPost, "/transaction/send"))
+	})
+	t.Run("should check the routes", func(t *testing.T) {
+		t.Parallel()
+
+		scope := common.KeyScope{Routes: []string{"/address", "/network/config"}}
+		assert.True(t, isRequestInScope(scope, http.MethodGet, "/address"))
+		assert.True(t, isRequestInScope(scope, http.MethodGet, "/address/erd1/balance"))
+		assert.True(t, isRequestInScope(scope, http.MethodGet, "/network/config"))
+		assert.False(t, isRequestInScope(scope, http.MethodGet, "/addresses"))
+		assert.False(t, isRequestInScope(scope, http.MethodGet, "/network/status/0"))
+		assert.False(t, isRequestInScope(scope, http.MethodGet, "/block/0/by-nonce/1"))
+	})
+	t.Run("should check both methods and routes", func(t *testing.T) {
+		t.Parallel()
+
+		scope := common.KeyScope{Routes: []string{"/address"}, Methods: []string{http.MethodGet}}
+		assert.True(t, isRequestInScope(scope, http.MethodGet, "/address/erd1"))
+		assert.False(t, isRequestInScope(scope, http.MethodPost, "/address/erd1"))
+		assert.False(t, isRequestInScope(scope, http.MethodGet, "/block/0/by-nonce/1"))
+	})
+}
+
+func TestCheckHostScope(t *testing.T) {
+	t.Parallel()
+
+	archiveHost := config.GatewayConfig{Name: "archive", EpochStart: "0", EpochEnd: "1000"}
+	middleHost := config.GatewayConfig{Name: "middle", EpochStart: "1001", EpochEnd: "2000"}
+	latestHost := config.GatewayConfig{Name: "latest", EpochStart: "2001", EpochEnd: "latest"}
+
+	t.Run("scope without epochs should allow all hosts", func(t *testing.T) {
+		t.Parallel()
+
+		scope := common.KeyScope{Methods: []string{http.MethodGet}}
+		assert.Nil(t, checkHostScope(scope, archiveHost))
+		assert.Nil(t, checkHostScope(scope, latestHost))
+		assert.Nil(t, checkHostScope(common.KeyScope{EpochEnd: "LATEST"}, latestHost))
+	})
+	t.Run("should allow only the hosts inside the epochs range", func(t *testing.T) {
+		t.Parallel()
+
+		scope := common.KeyScope{EpochStart: "0", EpochEnd: "2000"}
+		assert.Nil(t, checkHostScope(scope, archiveHost))
+		assert.Nil(t, checkHostScope(scope, middleHost))
+
+		err := checkHostScope(scope, latestHost)
+		assert.ErrorIs(t, err, errKeyOutOfScope)
+		assert.Contains(t, err.Error(), "the request is served by a gateway holding the epochs 2001 - latest, the key is limited to the epochs 0 - 2000")
+
+		scope = common.KeyScope{EpochStart: "500", EpochEnd: "2000"}
+		assert.ErrorIs(t, checkHostScope(scope, archiveHost), errKeyOutOfScope)
+		assert.Nil(t, checkHostScope(scope, middleHost))
+	})
+	t.Run("open ended range should allow the latest host", func(t *testing.T) {
+		t.Parallel()
+
+		scope := common.KeyScope{EpochStart: "1001"}
+		assert.ErrorIs(t, checkHostScope(scope, archiveHost), errKeyOutOfScope)
+		assert.Nil(t, checkHostScope(scope, middleHost))
+		assert.Nil(t, checkHostScope(scope, latestHost))
+	})
+	t.Run("invalid epochs should reject the host", func(t *testing.T) {
+		t.Parallel()
+
+		err := checkHostScope(common.KeyScope{EpochStart: "a"}, archiveHost)
+		assert.ErrorIs(t, err, errKeyOutOfScope)
+		assert.Contains(t, err.Error(), "invalid start epoch a in the key's epochs range")
+
+		err = checkHostScope(common.KeyScope{EpochEnd: "100"}, config.GatewayConfig{EpochStart: "0", EpochEnd: "b"})
+		assert.ErrorIs(t, err, errKeyOutOfScope)
+		assert.Contains(t, err.Error(), "invalid end epoch b in the gateway's epochs range")
+	})
+}
@@ -0,0 +1,62 @@
+package process
+
+import (
+	"sync"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+)
+
+type keyViolationsRecorder struct {
+	mut        sync.RWMutex
+	violations []common.KeyViolation
+	next       int
+	isFull     bool
+}
+
+// NewKeyViolationsRecorder creates a recorder keeping the last capacity requests rejected by the keys restrictions
+func NewKeyViolationsRecorder(capacity int) (*keyViolationsRecorder, error) {
+	if capacity <= 0 {
+		return nil, errZeroKeyViolationsCapacity
+	}
+
+	return &keyViolationsRecorder{
+		violations: make([]common.KeyViolation, capacity),
+	}, nil
+}
+
+// Record stores the violation, replacing the oldest one if the recorder is full
+func (recorder *keyViolationsRecorder) Record(violation common.KeyViolation) {
+	recorder.mut.Lock()
+	defer recorder.mut.Unlock()
+
+	recorder.violations[recorder.next] = violation
+	recorder.next++
+	if recorder.next == len(recorder.violations) {
+		recorder.next = 0
+		recorder.isFull = true
+	}
+}
+
+// GetViolations returns the recorded violations, the newest first
+func (recorder *keyViolationsRecorder) GetViolations() []common.KeyViolation {
+	recorder.mut.RLock()
+	defer recorder.mut.RUnlock()
+
+	numViolations := recorder.next
+	if recorder.isFull {
+		numViolations = len(recorder.violations)
+	}
+
+	result := make([]common.KeyViolation, 0, numViolations)
+	for i := 1; i <= numViolations; i++ {
+		index := (recorder.next - i + len(recorder.violations)) % len(recorder.violations)
+		result = append(result, recorder.violations[index])
+	}
+
+	return result
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (recorder *keyViolationsRecorder) IsInterfaceNil() bool {
+	return recorder == nil
+}
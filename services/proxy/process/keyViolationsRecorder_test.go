@@ -0,0 +1,79 @@
+package process
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKeyViolationsRecorder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("zero capacity should error", func(t *testing.T) {
+		t.Parallel()
+
+		recorder, err := NewKeyViolationsRecorder(0)
+		assert.Nil(t, recorder)
+		assert.True(t, recorder.IsInterfaceNil())
+		assert.Equal(t, errZeroKeyViolationsCapacity, err)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		recorder, err := NewKeyViolationsRecorder(1)
+		assert.NotNil(t, recorder)
+		assert.False(t, recorder.IsInterfaceNil())
+		assert.Nil(t, err)
+		assert.Empty(t, recorder.GetViolations())
+	})
+}
+
+func TestKeyViolationsRecorder_Record(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should return the violations newest first", func(t *testing.T) {
+		t.Parallel()
+
+		recorder, _ := NewKeyViolationsRecorder(3)
+		recorder.Record(common.KeyViolation{Timestamp: 1})
+		recorder.Record(common.KeyViolation{Timestamp: 2})
+
+		expected := []common.KeyViolation{{Timestamp: 2}, {Timestamp: 1}}
+		assert.Equal(t, expected, recorder.GetViolations())
+	})
+	t.Run("a full recorder should replace the oldest violations", func(t *testing.T) {
+		t.Parallel()
+
+		recorder, _ := NewKeyViolationsRecorder(3)
+		for i := int64(1); i <= 5; i++ {
+			recorder.Record(common.KeyViolation{Timestamp: i})
+		}
+
+		expected := []common.KeyViolation{{Timestamp: 5}, {Timestamp: 4}, {Timestamp: 3}}
+		assert.Equal(t, expected, recorder.GetViolations())
+	})
+	t.Run("concurrent calls should not panic", func(t *testing.T) {
+		t.Parallel()
+
+		recorder, _ := NewKeyViolationsRecorder(10)
+		wg := sync.WaitGroup{}
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func(index int) {
+				defer wg.Done()
+
+				if index%2 == 0 {
+					recorder.Record(common.KeyViolation{Username: fmt.Sprintf("user%d", index)})
+					return
+				}
+				_ = recorder.GetViolations()
+			}(i)
+		}
+		wg.Wait()
+
+		assert.Len(t, recorder.GetViolations(), 10)
+	})
+}
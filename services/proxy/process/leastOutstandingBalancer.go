@@ -0,0 +1,45 @@
+package process
+
+import (
+	"sync/atomic"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+type leastOutstandingBalancer struct {
+	replicas []config.ReplicaConfig
+	counter  *inFlightCounter
+	offset   uint64
+}
+
+func newLeastOutstandingBalancer(replicas []config.ReplicaConfig, counter *inFlightCounter) *leastOutstandingBalancer {
+	return &leastOutstandingBalancer{
+		replicas: replicas,
+		counter:  counter,
+	}
+}
+
+func (balancer *leastOutstandingBalancer) next(isAvailable func(url string) bool) (config.ReplicaConfig, bool) {
+	// the starting point is rotated so the ties are not always resolved in the favor of the first replica
+	offset := atomic.AddUint64(&balancer.offset, 1) - 1
+	numReplicas := uint64(len(balancer.replicas))
+
+	found := false
+	selected := config.ReplicaConfig{}
+	minimum := int64(0)
+	for i := uint64(0); i < numReplicas; i++ {
+		replica := balancer.replicas[(offset+i)%numReplicas]
+		if !isAvailable(replica.URL) {
+			continue
+		}
+
+		value := balancer.counter.get(replica.URL)
+		if !found || value < minimum {
+			selected = replica
+			minimum = value
+			found = true
+		}
+	}
+
+	return selected, found
+}
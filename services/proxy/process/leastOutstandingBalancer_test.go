@@ -0,0 +1,71 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeastOutstandingBalancer_Next(t *testing.T) {
+	t.Parallel()
+
+	replicas := []config.ReplicaConfig{
+		{URL: "URL1"},
+		{URL: "URL2"},
+		{URL: "URL3"},
+	}
+
+	t.Run("no in-flight requests should rotate", func(t *testing.T) {
+		t.Parallel()
+
+		balancer := newLeastOutstandingBalancer(replicas, newInFlightCounter())
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, replicas[i%len(replicas)], nextReplica(t, balancer))
+		}
+	})
+	t.Run("should pick the replica with the fewest in-flight requests", func(t *testing.T) {
+		t.Parallel()
+
+		counter := newInFlightCounter()
+		counter.increment("URL1")
+		counter.increment("URL1")
+		counter.increment("URL2")
+		counter.increment("URL3")
+		counter.increment("URL3")
+		balancer := newLeastOutstandingBalancer(replicas, counter)
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, "URL2", nextReplica(t, balancer).URL)
+		}
+
+		counter.increment("URL2")
+		counter.increment("URL2")
+		counter.decrement("URL1")
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, "URL1", nextReplica(t, balancer).URL)
+		}
+	})
+	t.Run("unavailable replicas should be skipped", func(t *testing.T) {
+		t.Parallel()
+
+		balancer := newLeastOutstandingBalancer(replicas, newInFlightCounter())
+		isAvailable := func(url string) bool {
+			return url != "URL2"
+		}
+		for i := 0; i < 10; i++ {
+			replica, found := balancer.next(isAvailable)
+			assert.True(t, found)
+			assert.NotEqual(t, "URL2", replica.URL)
+		}
+	})
+	t.Run("no available replica should return false", func(t *testing.T) {
+		t.Parallel()
+
+		balancer := newLeastOutstandingBalancer(replicas, newInFlightCounter())
+		replica, found := balancer.next(func(url string) bool {
+			return false
+		})
+		assert.False(t, found)
+		assert.Empty(t, replica.URL)
+	})
+}
@@ -0,0 +1,62 @@
+package process
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+const (
+	// LoadBalancerRoundRobin cycles through the gateway's replicas in order
+	LoadBalancerRoundRobin = "round-robin"
+	// LoadBalancerWeighted distributes the requests proportionally with the replicas' weights
+	LoadBalancerWeighted = "weighted"
+	// LoadBalancerLeastOutstanding picks the replica with the fewest in-flight requests
+	LoadBalancerLeastOutstanding = "least-outstanding"
+)
+
+const defaultReplicaWeight = 1
+
+type loadBalancer interface {
+	next(isAvailable func(url string) bool) (config.ReplicaConfig, bool)
+}
+
+func createLoadBalancer(cfg config.GatewayConfig, counter *inFlightCounter) (loadBalancer, error) {
+	replicas := createReplicas(cfg)
+
+	switch strings.ToLower(cfg.LoadBalancer) {
+	case "", LoadBalancerRoundRobin:
+		return newRoundRobinBalancer(replicas), nil
+	case LoadBalancerWeighted:
+		return newWeightedBalancer(replicas), nil
+	case LoadBalancerLeastOutstanding:
+		return newLeastOutstandingBalancer(replicas, counter), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownLoadBalancer, cfg.LoadBalancer)
+	}
+}
+
+// createReplicas returns the gateway's own URL followed by all defined replicas
+func createReplicas(cfg config.GatewayConfig) []config.ReplicaConfig {
+	replicas := make([]config.ReplicaConfig, 0, len(cfg.Replicas)+1)
+	replicas = append(replicas, config.ReplicaConfig{
+		URL:    cfg.URL,
+		Name:   cfg.Name,
+		Weight: cfg.Weight,
+	})
+	for i, replica := range cfg.Replicas {
+		if len(replica.Name) == 0 {
+			replica.Name = fmt.Sprintf("%s#%d", cfg.Name, i+1)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	for i := range replicas {
+		if replicas[i].Weight == 0 {
+			replicas[i].Weight = defaultReplicaWeight
+		}
+	}
+
+	return replicas
+}
@@ -0,0 +1,122 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func allReplicasAvailable(_ string) bool {
+	return true
+}
+
+func nextReplica(t *testing.T, balancer loadBalancer) config.ReplicaConfig {
+	replica, found := balancer.next(allReplicasAvailable)
+	assert.True(t, found)
+
+	return replica
+}
+
+func TestCreateLoadBalancer(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.GatewayConfig{
+		URL:  "URL",
+		Name: "gateway",
+	}
+
+	t.Run("unknown load balancer should error", func(t *testing.T) {
+		t.Parallel()
+
+		localCfg := cfg
+		localCfg.LoadBalancer = "unknown"
+		balancer, err := createLoadBalancer(localCfg, newInFlightCounter())
+		assert.ErrorIs(t, err, errUnknownLoadBalancer)
+		assert.Contains(t, err.Error(), "unknown")
+		assert.Nil(t, balancer)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		localCfg := cfg
+		balancer, err := createLoadBalancer(localCfg, newInFlightCounter())
+		assert.Nil(t, err)
+		assert.IsType(t, &roundRobinBalancer{}, balancer)
+
+		localCfg.LoadBalancer = "Round-Robin"
+		balancer, err = createLoadBalancer(localCfg, newInFlightCounter())
+		assert.Nil(t, err)
+		assert.IsType(t, &roundRobinBalancer{}, balancer)
+
+		localCfg.LoadBalancer = LoadBalancerWeighted
+		balancer, err = createLoadBalancer(localCfg, newInFlightCounter())
+		assert.Nil(t, err)
+		assert.IsType(t, &weightedBalancer{}, balancer)
+
+		localCfg.LoadBalancer = LoadBalancerLeastOutstanding
+		balancer, err = createLoadBalancer(localCfg, newInFlightCounter())
+		assert.Nil(t, err)
+		assert.IsType(t, &leastOutstandingBalancer{}, balancer)
+	})
+}
+
+func TestCreateReplicas(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no replicas defined should return the gateway", func(t *testing.T) {
+		t.Parallel()
+
+		replicas := createReplicas(config.GatewayConfig{
+			URL:  "URL",
+			Name: "gateway",
+		})
+
+		expectedReplicas := []config.ReplicaConfig{
+			{
+				URL:    "URL",
+				Name:   "gateway",
+				Weight: defaultReplicaWeight,
+			},
+		}
+		assert.Equal(t, expectedReplicas, replicas)
+	})
+	t.Run("with replicas should add them after the gateway", func(t *testing.T) {
+		t.Parallel()
+
+		replicas := createReplicas(config.GatewayConfig{
+			URL:    "URL",
+			Name:   "gateway",
+			Weight: 5,
+			Replicas: []config.ReplicaConfig{
+				{
+					URL:    "URL-A",
+					Name:   "replica-A",
+					Weight: 2,
+				},
+				{
+					URL: "URL-B",
+				},
+			},
+		})
+
+		expectedReplicas := []config.ReplicaConfig{
+			{
+				URL:    "URL",
+				Name:   "gateway",
+				Weight: 5,
+			},
+			{
+				URL:    "URL-A",
+				Name:   "replica-A",
+				Weight: 2,
+			},
+			{
+				URL:    "URL-B",
+				Name:   "gateway#2",
+				Weight: defaultReplicaWeight,
+			},
+		}
+		assert.Equal(t, expectedReplicas, replicas)
+	})
+}
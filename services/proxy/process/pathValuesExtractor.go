@@ -0,0 +1,154 @@
+package process
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+const (
+	// PathPlaceholderNonce marks the path segment holding a block nonce
+	PathPlaceholderNonce = "{nonce}"
+	// PathPlaceholderEpoch marks the path segment holding an epoch
+	PathPlaceholderEpoch = "{epoch}"
+	// PathPlaceholderRound marks the path segment holding a round
+	PathPlaceholderRound = "{round}"
+)
+
+const pathSeparator = "/"
+
+type pathPattern struct {
+	pattern  string
+	segments []string
+}
+
+type pathValuesExtractor struct {
+	patterns       []pathPattern
+	roundsPerEpoch uint64
+}
+
+// NewPathValuesExtractor creates a new path values extractor. Each pattern is a path in which the {nonce}, {epoch}
+// and {round} placeholders mark the segments holding the routing values. Any other {name} placeholder matches any segment.
+// Since the gateways are split by nonce and epoch ranges, a round is converted into an epoch using the
+// configured RoundsPerEpoch value. This is an approximation, the epoch change can occur a few rounds later.
+func NewPathValuesExtractor(cfg config.PathRoutingConfig) (*pathValuesExtractor, error) {
+	patterns := make([]pathPattern, 0, len(cfg.Patterns))
+	for i, pattern := range cfg.Patterns {
+		converted, err := convertPathPattern(pattern, cfg.RoundsPerEpoch)
+		if err != nil {
+			return nil, fmt.Errorf("%w for the path pattern at index %d: %s", err, i, pattern)
+		}
+
+		patterns = append(patterns, converted)
+	}
+
+	return &pathValuesExtractor{
+		patterns:       patterns,
+		roundsPerEpoch: cfg.RoundsPerEpoch,
+	}, nil
+}
+
+func convertPathPattern(pattern string, roundsPerEpoch uint64) (pathPattern, error) {
+	if !strings.HasPrefix(pattern, pathSeparator) {
+		return pathPattern{}, errInvalidPathPattern
+	}
+
+	segments := strings.Split(pattern, pathSeparator)
+	numValuePlaceholders := 0
+	for _, segment := range segments {
+		switch segment {
+		case PathPlaceholderNonce, PathPlaceholderEpoch:
+			numValuePlaceholders++
+		case PathPlaceholderRound:
+			if roundsPerEpoch == 0 {
+				return pathPattern{}, errZeroRoundsPerEpoch
+			}
+			numValuePlaceholders++
+		}
+	}
+	if numValuePlaceholders == 0 {
+		return pathPattern{}, errNoValuePlaceholder
+	}
+
+	return pathPattern{
+		pattern:  pattern,
+		segments: segments,
+	}, nil
+}
+
+// ExtractValues returns the routing values found in the provided request path using the first matching pattern.
+// Returns an empty map if no pattern matches.
+func (extractor *pathValuesExtractor) ExtractValues(requestPath string) map[string][]string {
+	pathSegments := strings.Split(requestPath, pathSeparator)
+	for _, pattern := range extractor.patterns {
+		values, matched := extractor.match(pattern, pathSegments)
+		if matched {
+			log.Trace("path values extracted", "path", requestPath, "pattern", pattern.pattern)
+			return values
+		}
+	}
+
+	return make(map[string][]string)
+}
+
+func (extractor *pathValuesExtractor) match(pattern pathPattern, pathSegments []string) (map[string][]string, bool) {
+	if len(pattern.segments) != len(pathSegments) {
+		return nil, false
+	}
+
+	values := make(map[string][]string)
+	for i, patternSegment := range pattern.segments {
+		pathSegment := pathSegments[i]
+		if !isPlaceholder(patternSegment) {
+			if patternSegment != pathSegment {
+				return nil, false
+			}
+			continue
+		}
+		if len(pathSegment) == 0 {
+			return nil, false
+		}
+
+		if !extractor.addValue(values, patternSegment, pathSegment) {
+			return nil, false
+		}
+	}
+
+	return values, true
+}
+
+func (extractor *pathValuesExtractor) addValue(values map[string][]string, placeholder string, pathSegment string) bool {
+	switch placeholder {
+	case PathPlaceholderNonce, PathPlaceholderEpoch, PathPlaceholderRound:
+	default:
+		// wildcard placeholder (shard, hash, format, etc.)
+		return true
+	}
+
+	value, err := strconv.ParseUint(pathSegment, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	switch placeholder {
+	case PathPlaceholderNonce:
+		values[UrlParameterBlockNonce] = []string{pathSegment}
+	case PathPlaceholderEpoch:
+		values[UrlParameterHintEpoch] = []string{pathSegment}
+	case PathPlaceholderRound:
+		values[UrlParameterHintEpoch] = []string{strconv.FormatUint(value/extractor.roundsPerEpoch, 10)}
+	}
+
+	return true
+}
+
+func isPlaceholder(segment string) bool {
+	return len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (extractor *pathValuesExtractor) IsInterfaceNil() bool {
+	return extractor == nil
+}
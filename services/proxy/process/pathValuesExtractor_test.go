@@ -0,0 +1,193 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func createMultiversXPathRoutingConfig() config.PathRoutingConfig {
+	return config.PathRoutingConfig{
+		RoundsPerEpoch: 14400,
+		Patterns: []string{
+			"/block/{shard}/by-nonce/{nonce}",
+			"/block/{shard}/altered-accounts/by-nonce/{nonce}",
+			"/hyperblock/by-nonce/{nonce}",
+			"/internal/{format}/shardblock/by-nonce/{nonce}",
+			"/internal/{format}/metablock/by-nonce/{nonce}",
+			"/internal/{format}/shardblock/by-round/{round}",
+			"/internal/{format}/metablock/by-round/{round}",
+			"/blocks/by-round/{round}",
+			"/network/epoch-start/{shard}/by-epoch/{epoch}",
+		},
+	}
+}
+
+func TestNewPathValuesExtractor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		cfg           config.PathRoutingConfig
+		expectedErr   error
+		errorContains string
+	}{
+		{
+			name: "empty config should work",
+			cfg:  config.PathRoutingConfig{},
+		},
+		{
+			name: "MultiversX routes should work",
+			cfg:  createMultiversXPathRoutingConfig(),
+		},
+		{
+			name: "pattern without leading separator should error",
+			cfg: config.PathRoutingConfig{
+				Patterns: []string{"/block/{shard}/by-nonce/{nonce}", "hyperblock/by-nonce/{nonce}"},
+			},
+			expectedErr:   errInvalidPathPattern,
+			errorContains: "index 1",
+		},
+		{
+			name: "pattern without a value placeholder should error",
+			cfg: config.PathRoutingConfig{
+				Patterns: []string{"/block/{shard}/by-hash/{hash}"},
+			},
+			expectedErr:   errNoValuePlaceholder,
+			errorContains: "index 0",
+		},
+		{
+			name: "round placeholder with 0 rounds per epoch should error",
+			cfg: config.PathRoutingConfig{
+				Patterns: []string{"/blocks/by-round/{round}"},
+			},
+			expectedErr:   errZeroRoundsPerEpoch,
+			errorContains: "/blocks/by-round/{round}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			extractor, err := NewPathValuesExtractor(tt.cfg)
+			if tt.expectedErr == nil {
+				assert.Nil(t, err)
+				assert.False(t, extractor.IsInterfaceNil())
+				return
+			}
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Contains(t, err.Error(), tt.errorContains)
+			assert.True(t, extractor.IsInterfaceNil())
+		})
+	}
+}
+
+func TestPathValuesExtractor_ExtractValues(t *testing.T) {
+	t.Parallel()
+
+	extractor, err := NewPathValuesExtractor(createMultiversXPathRoutingConfig())
+	assert.Nil(t, err)
+
+	tests := []struct {
+		path           string
+		expectedValues map[string][]string
+	}{
+		{
+			path:           "/block/0/by-nonce/123456",
+			expectedValues: map[string][]string{UrlParameterBlockNonce: {"123456"}},
+		},
+		{
+			path:           "/block/4294967295/by-nonce/7",
+			expectedValues: map[string][]string{UrlParameterBlockNonce: {"7"}},
+		},
+		{
+			path:           "/block/1/altered-accounts/by-nonce/99",
+			expectedValues: map[string][]string{UrlParameterBlockNonce: {"99"}},
+		},
+		{
+			path:           "/hyperblock/by-nonce/20175802",
+			expectedValues: map[string][]string{UrlParameterBlockNonce: {"20175802"}},
+		},
+		{
+			path:           "/internal/json/shardblock/by-nonce/15",
+			expectedValues: map[string][]string{UrlParameterBlockNonce: {"15"}},
+		},
+		{
+			path:           "/internal/raw/metablock/by-nonce/16",
+			expectedValues: map[string][]string{UrlParameterBlockNonce: {"16"}},
+		},
+		{
+			path:           "/internal/json/shardblock/by-round/28800",
+			expectedValues: map[string][]string{UrlParameterHintEpoch: {"2"}},
+		},
+		{
+			path:           "/internal/raw/metablock/by-round/14399",
+			expectedValues: map[string][]string{UrlParameterHintEpoch: {"0"}},
+		},
+		{
+			path:           "/blocks/by-round/1440000",
+			expectedValues: map[string][]string{UrlParameterHintEpoch: {"100"}},
+		},
+		{
+			path:           "/network/epoch-start/metachain/by-epoch/1400",
+			expectedValues: map[string][]string{UrlParameterHintEpoch: {"1400"}},
+		},
+		{
+			path:           "/hyperblock/by-hash/aabbcc",
+			expectedValues: map[string][]string{},
+		},
+		{
+			path:           "/block/0/by-nonce/latest",
+			expectedValues: map[string][]string{},
+		},
+		{
+			path:           "/block/0/by-nonce/-1",
+			expectedValues: map[string][]string{},
+		},
+		{
+			path:           "/block//by-nonce/5",
+			expectedValues: map[string][]string{},
+		},
+		{
+			path:           "/block/0/by-nonce/5/extra",
+			expectedValues: map[string][]string{},
+		},
+		{
+			path:           "/BLOCK/0/by-nonce/5",
+			expectedValues: map[string][]string{},
+		},
+		{
+			path:           "/network/config",
+			expectedValues: map[string][]string{},
+		},
+		{
+			path:           "",
+			expectedValues: map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expectedValues, extractor.ExtractValues(tt.path))
+		})
+	}
+}
+
+func TestPathValuesExtractor_ExtractValuesShouldUseTheFirstMatchingPattern(t *testing.T) {
+	t.Parallel()
+
+	extractor, _ := NewPathValuesExtractor(config.PathRoutingConfig{
+		Patterns: []string{
+			"/custom/{nonce}/{any}",
+			"/custom/{any}/{epoch}",
+		},
+	})
+
+	assert.Equal(t, map[string][]string{UrlParameterBlockNonce: {"10"}}, extractor.ExtractValues("/custom/10/20"))
+	assert.Equal(t, map[string][]string{UrlParameterHintEpoch: {"20"}}, extractor.ExtractValues("/custom/abc/20"))
+}
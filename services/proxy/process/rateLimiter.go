@@ -0,0 +1,81 @@
+package process
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+const (
+	// RateLimiterFixedWindow counts the requests of the free accounts in fixed time windows
+	RateLimiterFixedWindow = "fixed-window"
+	// RateLimiterTokenBucket uses a token bucket for each account, with the rate and burst set per account type
+	RateLimiterTokenBucket = "token-bucket"
+)
+
+// ArgsRateLimiter is the DTO used to create the configured rate limiter
+type ArgsRateLimiter struct {
+	Config      config.RateLimiterConfig
+	FreeAccount config.FreeAccountConfig
+	Tiers       []config.TierConfig
+	KeyCounter  KeyCounter
+}
+
+// NewRateLimiter creates the rate limiter defined in the configuration. The token bucket limiter also gets a bucket for
+// each tier with a rate set. The fixed window limiter can not apply the tiers' rates, so setting them is an error.
+func NewRateLimiter(args ArgsRateLimiter) (RateLimiter, error) {
+	switch strings.ToLower(args.Config.Type) {
+	case "", RateLimiterFixedWindow:
+		err := checkTiersWithoutRates(args.Tiers)
+		if err != nil {
+			return nil, err
+		}
+
+		windowDuration := time.Duration(args.FreeAccount.ClearPeriodInSeconds) * time.Second
+		limiter, err := NewFixedWindowLimiter(args.KeyCounter, args.FreeAccount.MaxCalls, windowDuration)
+		if err != nil {
+			return nil, err
+		}
+
+		return limiter, nil
+	case RateLimiterTokenBucket:
+		limiter, err := NewTokenBucketLimiter(appendTiersBuckets(args.Config.TokenBuckets, args.Tiers))
+		if err != nil {
+			return nil, err
+		}
+
+		return limiter, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownRateLimiter, args.Config.Type)
+	}
+}
+
+func checkTiersWithoutRates(tiers []config.TierConfig) error {
+	for _, tier := range tiers {
+		if tier.RatePerSecond != 0 || tier.Burst != 0 {
+			return fmt.Errorf("%w, tier %s sets them", errTierRateNeedsTokenBucket, tier.Name)
+		}
+	}
+
+	return nil
+}
+
+func appendTiersBuckets(buckets []config.TokenBucketConfig, tiers []config.TierConfig) []config.TokenBucketConfig {
+	result := make([]config.TokenBucketConfig, 0, len(buckets)+len(tiers))
+	result = append(result, buckets...)
+	for _, tier := range tiers {
+		if tier.RatePerSecond == 0 {
+			continue
+		}
+
+		result = append(result, config.TokenBucketConfig{
+			AccountType:   tier.Name,
+			RatePerSecond: tier.RatePerSecond,
+			Burst:         tier.Burst,
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,129 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unknown type should error", func(t *testing.T) {
+		t.Parallel()
+
+		limiter, err := NewRateLimiter(ArgsRateLimiter{
+			Config:     config.RateLimiterConfig{Type: "sliding-window"},
+			KeyCounter: &testscommon.KeyCounterStub{},
+		})
+		assert.Nil(t, limiter)
+		assert.True(t, errors.Is(err, errUnknownRateLimiter))
+		assert.Contains(t, err.Error(), "sliding-window")
+	})
+	t.Run("fixed window limiter with nil key counter should error", func(t *testing.T) {
+		t.Parallel()
+
+		limiter, err := NewRateLimiter(ArgsRateLimiter{})
+		assert.Nil(t, limiter)
+		assert.Equal(t, errNilKeyCounter, err)
+	})
+	t.Run("fixed window limiter with tiers setting rates should error", func(t *testing.T) {
+		t.Parallel()
+
+		tiers := [][]config.TierConfig{
+			{{Name: "silver"}, {Name: "gold", RatePerSecond: 10, Burst: 20}},
+			{{Name: "gold", Burst: 20}},
+		}
+		for _, limiterType := range []string{"", RateLimiterFixedWindow} {
+			for _, tiersConfig := range tiers {
+				limiter, err := NewRateLimiter(ArgsRateLimiter{
+					Config:     config.RateLimiterConfig{Type: limiterType},
+					Tiers:      tiersConfig,
+					KeyCounter: &testscommon.KeyCounterStub{},
+				})
+				assert.Nil(t, limiter)
+				assert.True(t, errors.Is(err, errTierRateNeedsTokenBucket))
+				assert.Contains(t, err.Error(), "gold")
+			}
+		}
+	})
+	t.Run("fixed window limiter with tiers without rates should work", func(t *testing.T) {
+		t.Parallel()
+
+		limiter, err := NewRateLimiter(ArgsRateLimiter{
+			Tiers:      []config.TierConfig{{Name: "silver", MaxConcurrentRequests: 2}},
+			KeyCounter: &testscommon.KeyCounterStub{},
+		})
+		assert.Nil(t, err)
+		assert.NotNil(t, limiter)
+	})
+	t.Run("invalid token bucket should error", func(t *testing.T) {
+		t.Parallel()
+
+		limiter, err := NewRateLimiter(ArgsRateLimiter{
+			Config: config.RateLimiterConfig{
+				Type:         RateLimiterTokenBucket,
+				TokenBuckets: []config.TokenBucketConfig{{AccountType: "free"}},
+			},
+		})
+		assert.Nil(t, limiter)
+		assert.True(t, errors.Is(err, errInvalidTokenBucket))
+	})
+	t.Run("should create the configured limiter", func(t *testing.T) {
+		t.Parallel()
+
+		types := map[string]string{
+			"":                     "*process.fixedWindowLimiter",
+			RateLimiterFixedWindow: "*process.fixedWindowLimiter",
+			"Token-Bucket":         "*process.tokenBucketLimiter",
+		}
+		for limiterType, expectedType := range types {
+			limiter, err := NewRateLimiter(ArgsRateLimiter{
+				Config:     config.RateLimiterConfig{Type: limiterType},
+				KeyCounter: &testscommon.KeyCounterStub{},
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, expectedType, fmt.Sprintf("%T", limiter))
+		}
+	})
+	t.Run("token bucket limiter should also size the tiers", func(t *testing.T) {
+		t.Parallel()
+
+		limiter, err := NewRateLimiter(ArgsRateLimiter{
+			Config: config.RateLimiterConfig{
+				Type:         RateLimiterTokenBucket,
+				TokenBuckets: []config.TokenBucketConfig{{AccountType: "free", RatePerSecond: 1, Burst: 1}},
+			},
+			Tiers: []config.TierConfig{
+				{Name: "gold", RatePerSecond: 10, Burst: 20},
+				{Name: "silver"},
+			},
+		})
+		assert.Nil(t, err)
+
+		result := limiter.Allow("user", "gold")
+		assert.True(t, result.Allowed)
+		assert.Equal(t, uint64(20), result.Limit)
+
+		result = limiter.Allow("user", "silver")
+		assert.Equal(t, common.RateLimitResult{Allowed: true}, result)
+	})
+	t.Run("tier with the same name as a token bucket should error", func(t *testing.T) {
+		t.Parallel()
+
+		limiter, err := NewRateLimiter(ArgsRateLimiter{
+			Config: config.RateLimiterConfig{
+				Type:         RateLimiterTokenBucket,
+				TokenBuckets: []config.TokenBucketConfig{{AccountType: "gold", RatePerSecond: 1, Burst: 1}},
+			},
+			Tiers: []config.TierConfig{{Name: "gold", RatePerSecond: 10, Burst: 20}},
+		})
+		assert.Nil(t, limiter)
+		assert.True(t, errors.Is(err, errDuplicatedAccountType))
+	})
+}
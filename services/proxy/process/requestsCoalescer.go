@@ -0,0 +1,150 @@
+package process
+
+import (
+	"context"
+	"sync"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+const (
+	coalescingUpstreamCalls = "upstream_calls"
+	coalescingMerged        = "merged_requests"
+	coalescingNotShared     = "not_shared_requests"
+	coalescingInFlight      = "in_flight_calls"
+)
+
+type coalescedCall struct {
+	done       chan struct{}
+	response   common.CachedResponse
+	shared     bool
+	numWaiting int
+}
+
+type requestsCoalescer struct {
+	mut             sync.Mutex
+	enabled         bool
+	maxResponseSize uint64
+	calls           map[string]*coalescedCall
+	numUpstream     uint64
+	numMerged       uint64
+	numNotShared    uint64
+}
+
+// NewRequestsCoalescer creates a new requests coalescer. A disabled coalescer is never used by the requests processor.
+func NewRequestsCoalescer(cfg config.CoalescingConfig) (*requestsCoalescer, error) {
+	if cfg.Enabled && cfg.MaxResponseSizeInBytes == 0 {
+		return nil, errInvalidMaxResponseSize
+	}
+
+	return &requestsCoalescer{
+		enabled:         cfg.Enabled,
+		maxResponseSize: cfg.MaxResponseSizeInBytes,
+		calls:           make(map[string]*coalescedCall),
+	}, nil
+}
+
+// Do executes the handler for the first request with the provided key, the leader, that will get executed=true. The
+// requests with the same key arriving while the handler runs wait for it and receive its response with shared=true,
+// if the handler returned one that can be shared. Otherwise, or if the context is done before the handler finishes,
+// both shared and executed are false and the caller should serve the request on its own.
+func (coalescer *requestsCoalescer) Do(
+	ctx context.Context,
+	key string,
+	handler func() (common.CachedResponse, bool),
+) (common.CachedResponse, bool, bool) {
+	coalescer.mut.Lock()
+	call, found := coalescer.calls[key]
+	if !found {
+		call = &coalescedCall{
+			done: make(chan struct{}),
+		}
+		coalescer.calls[key] = call
+		coalescer.numUpstream++
+		coalescer.mut.Unlock()
+
+		coalescer.execute(key, call, handler)
+
+		return common.CachedResponse{}, false, true
+	}
+	call.numWaiting++
+	coalescer.mut.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+	}
+
+	coalescer.mut.Lock()
+	defer coalescer.mut.Unlock()
+
+	call.numWaiting--
+	select {
+	case <-call.done:
+		if call.shared {
+			coalescer.numMerged++
+			return call.response, true, false
+		}
+	default:
+	}
+
+	coalescer.numNotShared++
+
+	return common.CachedResponse{}, false, false
+}
+
+func (coalescer *requestsCoalescer) execute(key string, call *coalescedCall, handler func() (common.CachedResponse, bool)) {
+	defer func() {
+		// also releases the waiting requests if the handler panics
+		coalescer.mut.Lock()
+		delete(coalescer.calls, key)
+		coalescer.mut.Unlock()
+
+		close(call.done)
+	}()
+
+	call.response, call.shared = handler()
+}
+
+// numWaiting returns how many requests wait for the call with the provided key
+func (coalescer *requestsCoalescer) numWaiting(key string) int {
+	coalescer.mut.Lock()
+	defer coalescer.mut.Unlock()
+
+	call, found := coalescer.calls[key]
+	if !found {
+		return 0
+	}
+
+	return call.numWaiting
+}
+
+// IsEnabled returns true if the identical requests should be merged
+func (coalescer *requestsCoalescer) IsEnabled() bool {
+	return coalescer.enabled
+}
+
+// MaxResponseSize returns the maximum size of a response body that can be shared
+func (coalescer *requestsCoalescer) MaxResponseSize() uint64 {
+	return coalescer.maxResponseSize
+}
+
+// GetMetrics returns the number of upstream calls, of the requests that received a shared response and of the ones
+// that had to be sent on their own
+func (coalescer *requestsCoalescer) GetMetrics() map[string]uint64 {
+	coalescer.mut.Lock()
+	defer coalescer.mut.Unlock()
+
+	return map[string]uint64{
+		coalescingUpstreamCalls: coalescer.numUpstream,
+		coalescingMerged:        coalescer.numMerged,
+		coalescingNotShared:     coalescer.numNotShared,
+		coalescingInFlight:      uint64(len(coalescer.calls)),
+	}
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (coalescer *requestsCoalescer) IsInterfaceNil() bool {
+	return coalescer == nil
+}
@@ -0,0 +1,286 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitForWaitingRequests(t *testing.T, coalescer *requestsCoalescer, key string, numWaiting int) {
+	require.Eventually(t, func() bool {
+		return coalescer.numWaiting(key) == numWaiting
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestNewRequestsCoalescer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("enabled coalescer with 0 maximum response size should error", func(t *testing.T) {
+		t.Parallel()
+
+		coalescer, err := NewRequestsCoalescer(config.CoalescingConfig{
+			Enabled: true,
+		})
+		assert.Nil(t, coalescer)
+		assert.Equal(t, errInvalidMaxResponseSize, err)
+	})
+	t.Run("disabled coalescer should work", func(t *testing.T) {
+		t.Parallel()
+
+		coalescer, err := NewRequestsCoalescer(config.CoalescingConfig{})
+		assert.Nil(t, err)
+		assert.False(t, coalescer.IsInterfaceNil())
+		assert.False(t, coalescer.IsEnabled())
+	})
+	t.Run("enabled coalescer should work", func(t *testing.T) {
+		t.Parallel()
+
+		coalescer, err := NewRequestsCoalescer(config.CoalescingConfig{
+			Enabled:                true,
+			MaxResponseSizeInBytes: 1024,
+		})
+		assert.Nil(t, err)
+		assert.True(t, coalescer.IsEnabled())
+		assert.Equal(t, uint64(1024), coalescer.MaxResponseSize())
+	})
+}
+
+func TestRequestsCoalescer_Do(t *testing.T) {
+	t.Parallel()
+
+	testResponse := common.CachedResponse{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       []byte("response"),
+	}
+
+	t.Run("sequential calls should all be executed", func(t *testing.T) {
+		t.Parallel()
+
+		coalescer, _ := NewRequestsCoalescer(config.CoalescingConfig{Enabled: true, MaxResponseSizeInBytes: 1024})
+		numCalls := 0
+		for i := 0; i < 3; i++ {
+			_, shared, executed := coalescer.Do(context.Background(), "key", func() (common.CachedResponse, bool) {
+				numCalls++
+				return testResponse, true
+			})
+			assert.False(t, shared)
+			assert.True(t, executed)
+		}
+
+		assert.Equal(t, 3, numCalls)
+		assert.Equal(t, map[string]uint64{
+			coalescingUpstreamCalls: 3,
+			coalescingMerged:        0,
+			coalescingNotShared:     0,
+			coalescingInFlight:      0,
+		}, coalescer.GetMetrics())
+	})
+	t.Run("concurrent identical calls should share the response", func(t *testing.T) {
+		t.Parallel()
+
+		coalescer, _ := NewRequestsCoalescer(config.CoalescingConfig{Enabled: true, MaxResponseSizeInBytes: 1024})
+		release := make(chan struct{})
+		numHandlerCalls := uint32(0)
+		handler := func() (common.CachedResponse, bool) {
+			atomic.AddUint32(&numHandlerCalls, 1)
+			<-release
+			return testResponse, true
+		}
+
+		numRequests := 500
+		numExecuted := uint32(0)
+		numShared := uint32(0)
+		wg := sync.WaitGroup{}
+		wg.Add(numRequests)
+		for i := 0; i < numRequests; i++ {
+			go func() {
+				defer wg.Done()
+
+				response, shared, executed := coalescer.Do(context.Background(), "key", handler)
+				if executed {
+					atomic.AddUint32(&numExecuted, 1)
+					return
+				}
+				if shared {
+					assert.Equal(t, testResponse, response)
+					atomic.AddUint32(&numShared, 1)
+				}
+			}()
+		}
+
+		waitForWaitingRequests(t, coalescer, "key", numRequests-1)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, uint32(1), atomic.LoadUint32(&numHandlerCalls))
+		assert.Equal(t, uint32(1), atomic.LoadUint32(&numExecuted))
+		assert.Equal(t, uint32(numRequests-1), atomic.LoadUint32(&numShared))
+		assert.Equal(t, map[string]uint64{
+			coalescingUpstreamCalls: 1,
+			coalescingMerged:        uint64(numRequests - 1),
+			coalescingNotShared:     0,
+			coalescingInFlight:      0,
+		}, coalescer.GetMetrics())
+	})
+	t.Run("concurrent calls with different keys should not be merged", func(t *testing.T) {
+		t.Parallel()
+
+		coalescer, _ := NewRequestsCoalescer(config.CoalescingConfig{Enabled: true, MaxResponseSizeInBytes: 1024})
+		release := make(chan struct{})
+		numKeys := 10
+		numRequestsPerKey := 50
+		handlerCalls := make(map[string]int)
+		mut := sync.Mutex{}
+
+		wg := sync.WaitGroup{}
+		wg.Add(numKeys * numRequestsPerKey)
+		for i := 0; i < numKeys; i++ {
+			key := fmt.Sprintf("key%d", i)
+			for j := 0; j < numRequestsPerKey; j++ {
+				go func() {
+					defer wg.Done()
+
+					response, shared, executed := coalescer.Do(context.Background(), key, func() (common.CachedResponse, bool) {
+						mut.Lock()
+						handlerCalls[key]++
+						mut.Unlock()
+
+						<-release
+						return common.CachedResponse{Body: []byte(key)}, true
+					})
+					if !executed {
+						assert.True(t, shared)
+						assert.Equal(t, key, string(response.Body))
+					}
+				}()
+			}
+		}
+
+		for i := 0; i < numKeys; i++ {
+			waitForWaitingRequests(t, coalescer, fmt.Sprintf("key%d", i), numRequestsPerKey-1)
+		}
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, numKeys, len(handlerCalls))
+		for key, numCalls := range handlerCalls {
+			assert.Equal(t, 1, numCalls, key)
+		}
+
+		metrics := coalescer.GetMetrics()
+		assert.Equal(t, uint64(numKeys), metrics[coalescingUpstreamCalls])
+		assert.Equal(t, uint64(numKeys*(numRequestsPerKey-1)), metrics[coalescingMerged])
+	})
+	t.Run("not shareable response should let the waiting requests continue on their own", func(t *testing.T) {
+		t.Parallel()
+
+		coalescer, _ := NewRequestsCoalescer(config.CoalescingConfig{Enabled: true, MaxResponseSizeInBytes: 1024})
+		release := make(chan struct{})
+		numRequests := 100
+		numNotShared := uint32(0)
+
+		wg := sync.WaitGroup{}
+		wg.Add(numRequests)
+		for i := 0; i < numRequests; i++ {
+			go func() {
+				defer wg.Done()
+
+				_, shared, executed := coalescer.Do(context.Background(), "key", func() (common.CachedResponse, bool) {
+					<-release
+					return common.CachedResponse{}, false
+				})
+				if !executed && !shared {
+					atomic.AddUint32(&numNotShared, 1)
+				}
+			}()
+		}
+
+		waitForWaitingRequests(t, coalescer, "key", numRequests-1)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, uint32(numRequests-1), atomic.LoadUint32(&numNotShared))
+		metrics := coalescer.GetMetrics()
+		assert.Equal(t, uint64(1), metrics[coalescingUpstreamCalls])
+		assert.Equal(t, uint64(0), metrics[coalescingMerged])
+		assert.Equal(t, uint64(numRequests-1), metrics[coalescingNotShared])
+	})
+	t.Run("canceled waiting request should return immediately", func(t *testing.T) {
+		t.Parallel()
+
+		coalescer, _ := NewRequestsCoalescer(config.CoalescingConfig{Enabled: true, MaxResponseSizeInBytes: 1024})
+		release := make(chan struct{})
+		leaderDone := make(chan struct{})
+		go func() {
+			_, _, _ = coalescer.Do(context.Background(), "key", func() (common.CachedResponse, bool) {
+				<-release
+				return testResponse, true
+			})
+			close(leaderDone)
+		}()
+
+		require.Eventually(t, func() bool {
+			return coalescer.GetMetrics()[coalescingInFlight] == 1
+		}, 5*time.Second, time.Millisecond)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			waitForWaitingRequests(t, coalescer, "key", 1)
+			cancel()
+		}()
+
+		_, shared, executed := coalescer.Do(ctx, "key", func() (common.CachedResponse, bool) {
+			assert.Fail(t, "should have not been called")
+			return common.CachedResponse{}, false
+		})
+		assert.False(t, shared)
+		assert.False(t, executed)
+		assert.Equal(t, 0, coalescer.numWaiting("key"))
+
+		close(release)
+		<-leaderDone
+		assert.Equal(t, uint64(1), coalescer.GetMetrics()[coalescingNotShared])
+	})
+	t.Run("panicking handler should release the waiting requests", func(t *testing.T) {
+		t.Parallel()
+
+		coalescer, _ := NewRequestsCoalescer(config.CoalescingConfig{Enabled: true, MaxResponseSizeInBytes: 1024})
+		release := make(chan struct{})
+		go func() {
+			defer func() {
+				_ = recover()
+			}()
+
+			_, _, _ = coalescer.Do(context.Background(), "key", func() (common.CachedResponse, bool) {
+				<-release
+				panic("handler failure")
+			})
+		}()
+
+		require.Eventually(t, func() bool {
+			return coalescer.GetMetrics()[coalescingInFlight] == 1
+		}, 5*time.Second, time.Millisecond)
+
+		go func() {
+			waitForWaitingRequests(t, coalescer, "key", 1)
+			close(release)
+		}()
+
+		_, shared, executed := coalescer.Do(context.Background(), "key", func() (common.CachedResponse, bool) {
+			return testResponse, true
+		})
+		assert.False(t, shared)
+		assert.False(t, executed)
+		assert.Equal(t, uint64(0), coalescer.GetMetrics()[coalescingInFlight])
+	})
+}
@@ -1,54 +1,127 @@
 package process
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/multiversx/mx-chain-core-go/core/check"
 	logger "github.com/multiversx/mx-chain-logger-go"
 )
 
-const origin = "Origin"
+const (
+	origin               = "Origin"
+	xCacheHeader         = "X-Cache"
+	acceptEncodingHeader = "Accept-Encoding"
+	cacheHit             = "HIT"
+	cacheMiss            = "MISS"
+)
+
+// ArgsRequestsProcessor is the DTO used to create a new requests processor
+type ArgsRequestsProcessor struct {
+	HostFinder          HostFinder
+	AccessChecker       AccessChecker
+	PerformanceMonitor  PerformanceMonitor
+	PathValuesExtractor PathValuesExtractor
+	BodyValuesExtractor BodyValuesExtractor
+	HashEpochResolver   HashEpochResolver
+	TimestampResolver   TimestampResolver
+	RetryPolicy         RetryPolicy
+	UpstreamClient      UpstreamClient
+	ResponseCache       ResponseCache
+	RequestsCoalescer   RequestsCoalescer
+	HeadersPolicy       HeadersPolicy
+	ClosedEndpoints     []string
+}
 
 type requestsProcessor struct {
-	hostFinder         HostFinder
-	accessChecker      AccessChecker
-	performanceMonitor PerformanceMonitor
-	closedEndpoints    []string
+	hostFinder          HostFinder
+	accessChecker       AccessChecker
+	performanceMonitor  PerformanceMonitor
+	pathValuesExtractor PathValuesExtractor
+	bodyValuesExtractor BodyValuesExtractor
+	hashEpochResolver   HashEpochResolver
+	timestampResolver   TimestampResolver
+	retryPolicy         RetryPolicy
+	upstreamClient      UpstreamClient
+	responseCache       ResponseCache
+	requestsCoalescer   RequestsCoalescer
+	headersPolicy       HeadersPolicy
+	closedEndpoints     []string
 }
 
 // NewRequestsProcessor creates a new requests processor
-func NewRequestsProcessor(
-	hostFinder HostFinder,
-	accessChecker AccessChecker,
-	performanceMonitor PerformanceMonitor,
-	closedEndpoints []string,
-) (*requestsProcessor, error) {
-	if check.IfNil(hostFinder) {
+func NewRequestsProcessor(args ArgsRequestsProcessor) (*requestsProcessor, error) {
+	if check.IfNil(args.HostFinder) {
 		return nil, errNilHostsFinder
 	}
-	if check.IfNil(accessChecker) {
+	if check.IfNil(args.AccessChecker) {
 		return nil, errNilAccessChecker
 	}
-	if check.IfNil(performanceMonitor) {
+	if check.IfNil(args.PerformanceMonitor) {
 		return nil, fmt.Errorf("nil performance monitor")
 	}
+	if check.IfNil(args.PathValuesExtractor) {
+		return nil, errNilPathValuesExtractor
+	}
+	if check.IfNil(args.BodyValuesExtractor) {
+		return nil, errNilBodyValuesExtractor
+	}
+	if check.IfNil(args.HashEpochResolver) {
+		return nil, errNilHashEpochResolver
+	}
+	if check.IfNil(args.TimestampResolver) {
+		return nil, errNilTimestampResolver
+	}
+	if check.IfNil(args.RetryPolicy) {
+		return nil, errNilRetryPolicy
+	}
+	if check.IfNil(args.UpstreamClient) {
+		return nil, errNilUpstreamClient
+	}
+	if check.IfNil(args.ResponseCache) {
+		return nil, errNilResponseCache
+	}
+	if check.IfNil(args.RequestsCoalescer) {
+		return nil, errNilRequestsCoalescer
+	}
+	if check.IfNil(args.HeadersPolicy) {
+		return nil, errNilHeadersPolicy
+	}
 
 	return &requestsProcessor{
-		hostFinder:         hostFinder,
-		accessChecker:      accessChecker,
-		performanceMonitor: performanceMonitor,
-		closedEndpoints:    closedEndpoints,
+		hostFinder:          args.HostFinder,
+		accessChecker:       args.AccessChecker,
+		performanceMonitor:  args.PerformanceMonitor,
+		pathValuesExtractor: args.PathValuesExtractor,
+		bodyValuesExtractor: args.BodyValuesExtractor,
+		hashEpochResolver:   args.HashEpochResolver,
+		timestampResolver:   args.TimestampResolver,
+		retryPolicy:         args.RetryPolicy,
+		upstreamClient:      args.UpstreamClient,
+		responseCache:       args.ResponseCache,
+		requestsCoalescer:   args.RequestsCoalescer,
+		headersPolicy:       args.HeadersPolicy,
+		closedEndpoints:     args.ClosedEndpoints,
 	}, nil
 }
 
 // ServeHTTP will serve the http requests
 func (processor *requestsProcessor) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	// the request ID is also set on the client request, so it is passed to the gateway on each attempt
+	requestID := processor.headersPolicy.RequestID(request.Header)
+	request.Header.Set(headerRequestID, requestID)
+	writer.Header().Set(headerRequestID, requestID)
+
 	values, err := url.ParseQuery(request.URL.RawQuery)
 	if err != nil {
 		RespondWithError(writer, fmt.Errorf("%w while parsing query", err), http.StatusBadRequest)
@@ -63,12 +136,40 @@ func (processor *requestsProcessor) ServeHTTP(writer http.ResponseWriter, reques
 	)
 
 	start := time.Now()
-	newRequestURI, err := processor.accessChecker.ShouldProcessRequest(request.Header, request.RequestURI)
+	accessResult, err := processor.accessChecker.ShouldProcessRequest(request)
 	if err != nil {
 		log.Trace("can not process request",
+			"client IP", accessResult.ClientIP,
+			"error", err,
+		)
+		if errors.Is(err, errTooManyRequests) {
+			setRateLimitHeaders(writer.Header(), accessResult.RateLimit)
+		}
+		RespondWithError(writer, err, getStatusCodeForAccessError(err))
+		return
+	}
+	defer processor.accessChecker.ReleaseRequest(accessResult)
+	setRateLimitHeaders(writer.Header(), accessResult.RateLimit)
+
+	newRequestURI := accessResult.RequestURI
+
+	requestPath, _, _ := strings.Cut(newRequestURI, "?")
+	processor.addPathValues(values, requestPath)
+	newRequestURI, err = processor.addTimestampValues(values, newRequestURI)
+	if err != nil {
+		log.Trace("can not resolve the timestamp",
 			"error", err,
 		)
-		RespondWithError(writer, err, http.StatusUnauthorized)
+		RespondWithError(writer, fmt.Errorf("%w while resolving the %s parameter", err, UrlParameterAtTimestamp), getStatusCodeForTimestampError(err))
+		return
+	}
+	processor.addHashValues(request.Context(), values, requestPath)
+	body, err := processor.addBodyValues(values, request, requestPath)
+	if err != nil {
+		log.Trace("can not read request body",
+			"error", err,
+		)
+		RespondWithError(writer, fmt.Errorf("%w while reading the request body", err), http.StatusBadRequest)
 		return
 	}
 
@@ -77,47 +178,89 @@ func (processor *requestsProcessor) ServeHTTP(writer http.ResponseWriter, reques
 		log.Trace("host not found",
 			"error", err,
 		)
-		RespondWithError(writer, err, http.StatusInternalServerError)
+		RespondWithError(writer, err, getStatusCodeForHostFinderError(err))
 		return
 	}
+	defer func() {
+		// the host might be replaced by the retries
+		processor.hostFinder.ReleaseHost(newHost)
+	}()
 
-	urlPath := newHost.URL + newRequestURI
+	err = processor.accessChecker.CheckHost(accessResult, newHost)
+	if err != nil {
+		log.Trace("host out of the key scope",
+			"error", err,
+		)
+		RespondWithError(writer, err, http.StatusForbidden)
+		return
+	}
 
-	if processor.isEndpointClosed(urlPath) {
+	if processor.isEndpointClosed(newHost.URL + newRequestURI) {
 		log.Trace("endpoint is closed")
 		http.NotFound(writer, request)
 		return
 	}
 
-	req, err := http.NewRequest(request.Method, urlPath, request.Body)
-	if err != nil {
-		log.Error("can not create request",
-			"target host", newHost,
-			"URI", newRequestURI,
-			"remote address", request.RemoteAddr,
-			"error", err,
-		)
-		RespondWithError(writer, err, http.StatusInternalServerError)
-		return
+	// only the requests that passed the access and the routing checks are charged
+	processor.accessChecker.ConsumeCredits(accessResult)
+	setCreditsCostHeader(writer.Header(), accessResult.Cost)
+
+	cacheKey := ""
+	if processor.isCacheable(request, values, newHost) {
+		cacheKey = createRequestKey(request, newRequestURI)
+		cachedResponse, found := processor.responseCache.Get(cacheKey)
+		if found {
+			processor.performanceMonitor.AddPerformanceMetricAsync(common.ConvertTimeToInterval(time.Since(start)))
+			cachedResponse.Header = cachedResponse.Header.Clone()
+			cachedResponse.Header.Set(xCacheHeader, cacheHit)
+			writeResponse(writer, cachedResponse)
+			return
+		}
 	}
 
-	// pass through the header attributes
-	for key, value := range request.Header {
-		req.Header[key] = value
+	if processor.isCoalescable(request, body) {
+		coalescingKey := createCoalescingKey(request, newRequestURI, newHost)
+		sharedResponse, shared, executed := processor.requestsCoalescer.Do(request.Context(), coalescingKey, func() (common.CachedResponse, bool) {
+			var forwardedResponse common.CachedResponse
+			var complete bool
+			newHost, forwardedResponse, complete = processor.forwardRequest(writer, request, accessResult, values, newHost, newRequestURI, body, start, cacheKey, true)
+
+			return forwardedResponse, complete
+		})
+		if executed {
+			return
+		}
+		if shared {
+			processor.performanceMonitor.AddPerformanceMetricAsync(common.ConvertTimeToInterval(time.Since(start)))
+			writeResponse(writer, sharedResponse)
+			return
+		}
 	}
 
-	response, err := http.DefaultClient.Do(req)
+	newHost, _, _ = processor.forwardRequest(writer, request, accessResult, values, newHost, newRequestURI, body, start, cacheKey, false)
+}
+
+// forwardRequest sends the request to the gateway and streams the response to the client. If the request is
+// coalesced, the response is also returned together with a true value, as long as it was completely sent and it does
+// not exceed the maximum size of a shared response. Returns the host that provided the response.
+func (processor *requestsProcessor) forwardRequest(
+	writer http.ResponseWriter,
+	request *http.Request,
+	accessResult common.AccessResult,
+	values url.Values,
+	host config.GatewayConfig,
+	requestURI string,
+	body io.Reader,
+	start time.Time,
+	cacheKey string,
+	coalesced bool,
+) (config.GatewayConfig, common.CachedResponse, bool) {
+	response, host, err := processor.sendRequest(request, accessResult, values, host, requestURI, body)
 	duration := time.Since(start)
 
 	if err != nil {
-		log.Error("can not do request",
-			"target host", newHost,
-			"URI", newRequestURI,
-			"remote address", request.RemoteAddr,
-			"error", err,
-		)
 		RespondWithError(writer, err, http.StatusInternalServerError)
-		return
+		return host, common.CachedResponse{}, false
 	}
 	defer func() {
 		_ = response.Body.Close()
@@ -126,22 +269,325 @@ func (processor *requestsProcessor) ServeHTTP(writer http.ResponseWriter, reques
 	label := common.ConvertTimeToInterval(duration)
 	processor.performanceMonitor.AddPerformanceMetricAsync(label)
 
-	// pass through the response header attributes
+	processor.headersPolicy.CopyResponseHeader(writer.Header(), response.Header)
+	writer.Header()[origin] = []string{host.Name}
+
+	captureSize := uint64(0)
+	if len(cacheKey) > 0 {
+		writer.Header().Set(xCacheHeader, cacheMiss)
+		if response.StatusCode == http.StatusOK {
+			captureSize = processor.responseCache.MaxEntrySize()
+		}
+	}
+	if coalesced {
+		captureSize = max(captureSize, processor.requestsCoalescer.MaxResponseSize())
+	}
+
+	var capture *responseCapture
+	destination := io.Writer(writer)
+	if captureSize > 0 {
+		capture = newResponseCapture(writer, captureSize)
+		destination = capture
+	}
+
+	writer.WriteHeader(response.StatusCode)
+
+	// the body is streamed as it arrives, a failure can only be logged as the status code was already sent
+	written, err := processor.upstreamClient.CopyBody(destination, response.Body)
+	if err != nil {
+		log.Debug("can not stream the response body",
+			"target host", host.Name,
+			"URI", requestURI,
+			"remote address", request.RemoteAddr,
+			"client IP", accessResult.ClientIP,
+			"written bytes", written,
+			"error", err,
+		)
+		return host, common.CachedResponse{}, false
+	}
+
+	log.Trace("response generated", "written bytes", written)
+
+	if capture == nil || capture.isOverflown() {
+		return host, common.CachedResponse{}, false
+	}
+
+	forwardedResponse := common.CachedResponse{
+		StatusCode: response.StatusCode,
+		Header:     writer.Header().Clone(),
+		Body:       capture.bytes(),
+	}
+	if len(cacheKey) > 0 && response.StatusCode == http.StatusOK {
+		processor.responseCache.Put(cacheKey, forwardedResponse)
+	}
+
+	return host, forwardedResponse, coalesced
+}
+
+// isCacheable returns true for the GET requests pinned on a block nonce or an epoch served by a gateway holding
+// historical data, as their responses never change
+func (processor *requestsProcessor) isCacheable(request *http.Request, values url.Values, host config.GatewayConfig) bool {
+	if !processor.responseCache.IsEnabled() || request.Method != http.MethodGet {
+		return false
+	}
+	if !values.Has(UrlParameterBlockNonce) && !values.Has(UrlParameterHintEpoch) {
+		return false
+	}
+
+	return !strings.EqualFold(host.EpochEnd, latestMarker)
+}
+
+// isCoalescable returns true for the GET requests without a body, if the coalescing is enabled
+func (processor *requestsProcessor) isCoalescable(request *http.Request, body io.Reader) bool {
+	if !processor.requestsCoalescer.IsEnabled() || request.Method != http.MethodGet {
+		return false
+	}
+
+	return body == nil || body == http.NoBody
+}
+
+// createRequestKey returns the key of a request, made of the method, the normalized path, the sorted query parameters
+// and the accepted encoding, as the gateway might compress the response
+func createRequestKey(request *http.Request, requestURI string) string {
+	requestPath, rawQuery, _ := strings.Cut(requestURI, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err == nil {
+		rawQuery = query.Encode()
+	}
+
+	return request.Method + " " + normalizePath(requestPath) + "?" + rawQuery + " " + request.Header.Get(acceptEncodingHeader)
+}
+
+// createCoalescingKey returns the key of a request that also contains the ranges of the chosen gateway, so the
+// requests sent to any of the gateway's replicas are merged
+func createCoalescingKey(request *http.Request, requestURI string, host config.GatewayConfig) string {
+	return fmt.Sprintf("%s %s-%s/%s-%s", createRequestKey(request, requestURI), host.EpochStart, host.EpochEnd, host.NonceStart, host.NonceEnd)
+}
+
+// normalizePath removes the duplicated slashes and the dot segments of the provided path, keeping the trailing slash
+func normalizePath(requestPath string) string {
+	if len(requestPath) == 0 {
+		return "/"
+	}
+
+	normalized := path.Clean(requestPath)
+	if strings.HasSuffix(requestPath, "/") && normalized != "/" {
+		normalized += "/"
+	}
+
+	return normalized
+}
+
+// writeResponse writes a stored or a shared response, keeping the request ID of the current request
+func writeResponse(writer http.ResponseWriter, response common.CachedResponse) {
 	for key, value := range response.Header {
+		if isPerRequestHeader(http.CanonicalHeaderKey(key)) {
+			continue
+		}
+
 		writer.Header()[key] = value
 	}
-	writer.Header()[origin] = []string{newHost.Name}
+	writer.WriteHeader(response.StatusCode)
+
+	_, _ = writer.Write(response.Body)
+}
+
+// sendRequest forwards the request to the provided host. A request that fails or receives a retryable status code is
+// sent again, after the backoff, to another replica of the gateway or to the gateway's fallback, as long as the retry
+// policy allows it and the new host is in the scope of the request's key. The requests carrying a body are never retried
+// as the body can not be replayed.
+// Returns the response and the host that provided it, the replaced hosts being already released.
+func (processor *requestsProcessor) sendRequest(
+	request *http.Request,
+	accessResult common.AccessResult,
+	values url.Values,
+	host config.GatewayConfig,
+	requestURI string,
+	body io.Reader,
+) (*http.Response, config.GatewayConfig, error) {
+	canRetry := body == nil || body == http.NoBody
+	triedURLs := make([]string, 0, 1)
+	for attempts := uint32(1); ; attempts++ {
+		triedURLs = append(triedURLs, host.URL)
+		response, err := processor.doRequest(request, host, requestURI, body)
+		if err != nil {
+			log.Error("can not do request",
+				"target host", host.Name,
+				"target URL", host.URL,
+				"URI", requestURI,
+				"remote address", request.RemoteAddr,
+				"client IP", accessResult.ClientIP,
+				"attempt", attempts,
+				"error", err,
+			)
+		}
+
+		statusCode := 0
+		if response != nil {
+			statusCode = response.StatusCode
+		}
+		if !canRetry || !processor.retryPolicy.ShouldRetry(request.Method, attempts, statusCode, err) {
+			return response, host, err
+		}
+
+		alternativeHost, errFind := processor.hostFinder.FindAlternativeHost(values, triedURLs)
+		if errFind != nil {
+			log.Debug("can not retry request", "URI", requestURI, "error", errFind)
+			return response, host, err
+		}
+		errScope := processor.accessChecker.CheckHost(accessResult, alternativeHost)
+		if errScope != nil {
+			log.Debug("can not retry request", "URI", requestURI, "error", errScope)
+			processor.hostFinder.ReleaseHost(alternativeHost)
+			return response, host, err
+		}
+
+		log.Debug("retrying request",
+			"URI", requestURI,
+			"failed host", host.Name,
+			"status code", statusCode,
+			"next host", alternativeHost.Name,
+		)
+		if response != nil {
+			_ = response.Body.Close()
+		}
+		processor.hostFinder.ReleaseHost(host)
+		host = alternativeHost
+
+		err = waitBackoff(request.Context(), processor.retryPolicy.Backoff(attempts))
+		if err != nil {
+			return nil, host, err
+		}
+	}
+}
 
-	bodyBytes, err := io.ReadAll(response.Body)
+// doRequest sends the request to the provided host, applying the host's path rewrites, headers and credentials. The
+// upstream call is canceled if the client disconnects.
+func (processor *requestsProcessor) doRequest(request *http.Request, host config.GatewayConfig, requestURI string, body io.Reader) (*http.Response, error) {
+	header := make(http.Header)
+	processor.headersPolicy.CopyRequestHeader(header, request)
+
+	req, err := common.NewUpstreamRequest(request.Context(), request.Method, host, requestURI, header, body)
 	if err != nil {
-		RespondWithError(writer, err, http.StatusInternalServerError)
+		return nil, err
+	}
+
+	return processor.upstreamClient.Do(req, time.Duration(host.TimeoutInSeconds)*time.Second)
+}
+
+func waitBackoff(ctx context.Context, backoff time.Duration) error {
+	if backoff <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// addPathValues adds the values embedded in the request path (nonce, epoch, etc.) to the query values. The values
+// extracted from the path take precedence as they describe the requested data.
+func (processor *requestsProcessor) addPathValues(values url.Values, requestPath string) {
+	for key, pathValues := range processor.pathValuesExtractor.ExtractValues(requestPath) {
+		values[key] = pathValues
+	}
+}
+
+// addTimestampValues converts the atTimestamp parameter into a block nonce, if the request does not already carry one.
+// The nonce is added to the query values and replaces the atTimestamp parameter in the returned request URI.
+func (processor *requestsProcessor) addTimestampValues(values url.Values, requestURI string) (string, error) {
+	timestamp := values.Get(UrlParameterAtTimestamp)
+	if len(timestamp) == 0 || values.Has(UrlParameterBlockNonce) {
+		return requestURI, nil
+	}
+
+	nonce, found, err := processor.timestampResolver.ResolveNonce(timestamp)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return requestURI, nil
+	}
+
+	requestPath, rawQuery, _ := strings.Cut(requestURI, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", err
+	}
+
+	nonceValue := strconv.FormatUint(nonce, 10)
+	values.Set(UrlParameterBlockNonce, nonceValue)
+	query.Del(UrlParameterAtTimestamp)
+	query.Set(UrlParameterBlockNonce, nonceValue)
+
+	return requestPath + "?" + query.Encode(), nil
+}
+
+// addHashValues adds the epoch of the block or transaction hash found in the request path, only if the request does
+// not already carry a routing value
+func (processor *requestsProcessor) addHashValues(ctx context.Context, values url.Values, requestPath string) {
+	if values.Has(UrlParameterBlockNonce) || values.Has(UrlParameterHintEpoch) {
 		return
 	}
 
-	log.Trace("response generated")
-	writer.WriteHeader(response.StatusCode)
+	for key, hashValues := range processor.hashEpochResolver.ExtractValues(ctx, requestPath) {
+		values[key] = hashValues
+	}
+}
+
+// addBodyValues adds the values found in the JSON body of the POST requests to the query values, without overwriting
+// the existing ones. Returns the body that should be forwarded, with the same content as the original one.
+func (processor *requestsProcessor) addBodyValues(values url.Values, request *http.Request, requestPath string) (io.Reader, error) {
+	if request.Method != http.MethodPost || request.Body == nil || request.Body == http.NoBody {
+		return request.Body, nil
+	}
+
+	bodyValues, body, err := processor.bodyValuesExtractor.ExtractValues(requestPath, request.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, bodyValue := range bodyValues {
+		_, exists := values[key]
+		if !exists {
+			values[key] = bodyValue
+		}
+	}
+
+	return body, nil
+}
+
+func getStatusCodeForTimestampError(err error) int {
+	if errors.Is(err, errTimestampRoutingNotReady) {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusBadRequest
+}
+
+func getStatusCodeForAccessError(err error) int {
+	if errors.Is(err, errTooManyRequests) || errors.Is(err, errTooManyConcurrentRequests) {
+		return http.StatusTooManyRequests
+	}
+	if errors.Is(err, errEndpointNotAllowed) || errors.Is(err, errKeyOutOfScope) || errors.Is(err, errKeyRestricted) {
+		return http.StatusForbidden
+	}
+
+	return http.StatusUnauthorized
+}
+
+func getStatusCodeForHostFinderError(err error) int {
+	if errors.Is(err, errNoHealthyGateway) {
+		return http.StatusServiceUnavailable
+	}
 
-	_, _ = writer.Write(bodyBytes)
+	return http.StatusInternalServerError
 }
 
 func (processor *requestsProcessor) isEndpointClosed(url string) bool {
@@ -1,12 +1,21 @@
 package process
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
 	logger "github.com/multiversx/mx-chain-logger-go"
@@ -14,18 +23,33 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func createMockArgsRequestsProcessor() ArgsRequestsProcessor {
+	return ArgsRequestsProcessor{
+		HostFinder:          &testscommon.HostsFinderStub{},
+		AccessChecker:       &testscommon.AccessCheckerStub{},
+		PerformanceMonitor:  &testscommon.PerformanceMonitorStub{},
+		PathValuesExtractor: &testscommon.PathValuesExtractorStub{},
+		BodyValuesExtractor: &testscommon.BodyValuesExtractorStub{},
+		HashEpochResolver:   &testscommon.HashEpochResolverStub{},
+		TimestampResolver:   &testscommon.TimestampResolverStub{},
+		RetryPolicy:         &testscommon.RetryPolicyStub{},
+		UpstreamClient:      NewUpstreamClient(config.ForwardingConfig{}),
+		ResponseCache:       &testscommon.ResponseCacheStub{},
+		RequestsCoalescer:   &testscommon.RequestsCoalescerStub{},
+		HeadersPolicy:       &testscommon.HeadersPolicyStub{},
+		ClosedEndpoints:     make([]string, 0),
+	}
+}
+
 func TestNewRequestsProcessor(t *testing.T) {
 	t.Parallel()
 
 	t.Run("nil hosts finder should error", func(t *testing.T) {
 		t.Parallel()
 
-		processor, err := NewRequestsProcessor(
-			nil,
-			&testscommon.AccessCheckerStub{},
-			&testscommon.PerformanceMonitorStub{},
-			make([]string, 0),
-		)
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = nil
+		processor, err := NewRequestsProcessor(args)
 		assert.Nil(t, processor)
 		assert.True(t, processor.IsInterfaceNil())
 		assert.Equal(t, errNilHostsFinder, err)
@@ -33,25 +57,99 @@ func TestNewRequestsProcessor(t *testing.T) {
 	t.Run("nil access checker should error", func(t *testing.T) {
 		t.Parallel()
 
-		processor, err := NewRequestsProcessor(
-			&testscommon.HostsFinderStub{},
-			nil,
-			&testscommon.PerformanceMonitorStub{},
-			make([]string, 0),
-		)
+		args := createMockArgsRequestsProcessor()
+		args.AccessChecker = nil
+		processor, err := NewRequestsProcessor(args)
 		assert.Nil(t, processor)
 		assert.True(t, processor.IsInterfaceNil())
 		assert.Equal(t, errNilAccessChecker, err)
 	})
+	t.Run("nil path values extractor should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.PathValuesExtractor = nil
+		processor, err := NewRequestsProcessor(args)
+		assert.Nil(t, processor)
+		assert.Equal(t, errNilPathValuesExtractor, err)
+	})
+	t.Run("nil body values extractor should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.BodyValuesExtractor = nil
+		processor, err := NewRequestsProcessor(args)
+		assert.Nil(t, processor)
+		assert.Equal(t, errNilBodyValuesExtractor, err)
+	})
+	t.Run("nil hash epoch resolver should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.HashEpochResolver = nil
+		processor, err := NewRequestsProcessor(args)
+		assert.Nil(t, processor)
+		assert.Equal(t, errNilHashEpochResolver, err)
+	})
+	t.Run("nil timestamp resolver should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.TimestampResolver = nil
+		processor, err := NewRequestsProcessor(args)
+		assert.Nil(t, processor)
+		assert.Equal(t, errNilTimestampResolver, err)
+	})
+	t.Run("nil response cache should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.ResponseCache = nil
+		processor, err := NewRequestsProcessor(args)
+		assert.Nil(t, processor)
+		assert.Equal(t, errNilResponseCache, err)
+	})
+	t.Run("nil requests coalescer should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.RequestsCoalescer = nil
+		processor, err := NewRequestsProcessor(args)
+		assert.Nil(t, processor)
+		assert.Equal(t, errNilRequestsCoalescer, err)
+	})
+	t.Run("nil headers policy should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.HeadersPolicy = nil
+		processor, err := NewRequestsProcessor(args)
+		assert.Nil(t, processor)
+		assert.Equal(t, errNilHeadersPolicy, err)
+	})
+	t.Run("nil upstream client should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.UpstreamClient = nil
+		processor, err := NewRequestsProcessor(args)
+		assert.Nil(t, processor)
+		assert.Equal(t, errNilUpstreamClient, err)
+	})
+	t.Run("nil retry policy should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.RetryPolicy = nil
+		processor, err := NewRequestsProcessor(args)
+		assert.Nil(t, processor)
+		assert.Equal(t, errNilRetryPolicy, err)
+	})
 	t.Run("should work", func(t *testing.T) {
 		t.Parallel()
 
-		processor, err := NewRequestsProcessor(
-			&testscommon.HostsFinderStub{},
-			&testscommon.AccessCheckerStub{},
-			&testscommon.PerformanceMonitorStub{},
-			make([]string, 0),
-		)
+		args := createMockArgsRequestsProcessor()
+		processor, err := NewRequestsProcessor(args)
 		assert.NotNil(t, processor)
 		assert.False(t, processor.IsInterfaceNil())
 		assert.Nil(t, err)
@@ -71,12 +169,8 @@ func TestRequestsProcessor_ServeHTTP(t *testing.T) {
 	t.Run("parse query errors, should error", func(t *testing.T) {
 		t.Parallel()
 
-		processor, _ := NewRequestsProcessor(
-			&testscommon.HostsFinderStub{},
-			&testscommon.AccessCheckerStub{},
-			&testscommon.PerformanceMonitorStub{},
-			make([]string, 0),
-		)
+		args := createMockArgsRequestsProcessor()
+		processor, _ := NewRequestsProcessor(args)
 
 		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
 		request.URL.RawQuery = "a=b;c=d"
@@ -91,20 +185,19 @@ func TestRequestsProcessor_ServeHTTP(t *testing.T) {
 
 		log.SetLevel(logger.LogTrace)
 
-		processor, _ := NewRequestsProcessor(
-			&testscommon.HostsFinderStub{
-				FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
-					require.Fail(t, "should have not called the host finder")
-					return config.GatewayConfig{}, nil
-				},
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				require.Fail(t, "should have not called the host finder")
+				return config.GatewayConfig{}, nil
 			},
-			&testscommon.AccessCheckerStub{
-				ShouldProcessRequestHandler: func(header http.Header, requestURI string) (string, error) {
-					return "", expectedErr
-				},
+		}
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+				return common.AccessResult{}, expectedErr
 			},
-			&testscommon.PerformanceMonitorStub{},
-			make([]string, 0))
+		}
+		processor, _ := NewRequestsProcessor(args)
 
 		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
 		request.URL.RawQuery = "a=b"
@@ -115,18 +208,436 @@ func TestRequestsProcessor_ServeHTTP(t *testing.T) {
 		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
 		assert.Contains(t, recorder.Body.String(), "expected error")
 	})
-	t.Run("hosts finder errors, should error", func(t *testing.T) {
+	t.Run("tiers errors should respond with 403 and 429", func(t *testing.T) {
 		t.Parallel()
 
-		processor, _ := NewRequestsProcessor(
-			&testscommon.HostsFinderStub{
-				FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
-					return config.GatewayConfig{}, expectedErr
+		statusCodes := map[error]int{
+			errEndpointNotAllowed:        http.StatusForbidden,
+			errKeyOutOfScope:             http.StatusForbidden,
+			errKeyRestricted:             http.StatusForbidden,
+			errTooManyConcurrentRequests: http.StatusTooManyRequests,
+		}
+		for errTier, expectedStatusCode := range statusCodes {
+			args := createMockArgsRequestsProcessor()
+			args.AccessChecker = &testscommon.AccessCheckerStub{
+				ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+					return common.AccessResult{}, errTier
 				},
+				ReleaseRequestHandler: func(result common.AccessResult) {
+					assert.Fail(t, "should have not released a rejected request")
+				},
+			}
+			processor, _ := NewRequestsProcessor(args)
+
+			request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
+			recorder := httptest.NewRecorder()
+			processor.ServeHTTP(recorder, request)
+
+			assert.Equal(t, expectedStatusCode, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), errTier.Error())
+			assert.Empty(t, recorder.Header().Get(headerRateLimitLimit))
+		}
+	})
+	t.Run("allowed request should be released once", func(t *testing.T) {
+		t.Parallel()
+
+		accessResult := common.AccessResult{
+			RequestURI:  "/test/aa",
+			Username:    "user",
+			AccountType: "gold",
+		}
+		var releasedResults []common.AccessResult
+		args := createMockArgsRequestsProcessor()
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+				return accessResult, nil
+			},
+			ReleaseRequestHandler: func(result common.AccessResult) {
+				releasedResults = append(releasedResults, result)
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				assert.Empty(t, releasedResults)
+				return config.GatewayConfig{}, expectedErr
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, []common.AccessResult{accessResult}, releasedResults)
+	})
+	t.Run("routed request should be charged once", func(t *testing.T) {
+		t.Parallel()
+
+		testHttp := httptest.NewServer(&testscommon.HttpHandlerStub{
+			ServeHTTPCalled: func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusOK)
+			},
+		})
+		defer testHttp.Close()
+
+		accessResult := common.AccessResult{RequestURI: "/address/erd1/keys", Cost: 100}
+		chargedResults := make([]common.AccessResult, 0)
+		args := createMockArgsRequestsProcessor()
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+				return accessResult, nil
+			},
+			ConsumeCreditsHandler: func(result common.AccessResult) {
+				chargedResults = append(chargedResults, result)
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: testHttp.URL}, nil
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/address/erd1/keys", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, []common.AccessResult{accessResult}, chargedResults)
+	})
+	t.Run("the credits cost should be reported in a response header", func(t *testing.T) {
+		t.Parallel()
+
+		testHttp := httptest.NewServer(&testscommon.HttpHandlerStub{
+			ServeHTTPCalled: func(writer http.ResponseWriter, request *http.Request) {
+				assert.Empty(t, request.Header.Get(headerCreditsCost))
+				writer.WriteHeader(http.StatusOK)
+			},
+		})
+		defer testHttp.Close()
+
+		args := createMockArgsRequestsProcessor()
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+				return common.AccessResult{RequestURI: "/address/erd1/keys", Cost: 100}, nil
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: testHttp.URL}, nil
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/address/erd1/keys", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "100", recorder.Header().Get(headerCreditsCost))
+
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+				return common.AccessResult{Cost: 100}, errTooManyRequests
+			},
+		}
+		processor, _ = NewRequestsProcessor(args)
+
+		recorder = httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+		// the rejected request was not charged
+		assert.Empty(t, recorder.Header().Values(headerCreditsCost))
+	})
+	t.Run("host out of the key scope should respond with 403", func(t *testing.T) {
+		t.Parallel()
+
+		accessResult := common.AccessResult{
+			RequestURI: "/test/aa",
+			Username:   "user",
+			Cost:       5,
+			Scope:      common.KeyScope{EpochEnd: "100"},
+		}
+		host := config.GatewayConfig{URL: "http://latest.invalid", Name: "latest", EpochStart: "101", EpochEnd: "latest"}
+		releasedHosts := make([]config.GatewayConfig, 0)
+		args := createMockArgsRequestsProcessor()
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+				return accessResult, nil
+			},
+			CheckHostHandler: func(result common.AccessResult, providedHost config.GatewayConfig) error {
+				assert.Equal(t, accessResult, result)
+				assert.Equal(t, host, providedHost)
+				return fmt.Errorf("%w: gateway out of range", errKeyOutOfScope)
+			},
+			ConsumeCreditsHandler: func(result common.AccessResult) {
+				assert.Fail(t, "should have not charged the request")
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return host, nil
+			},
+			ReleaseHostCalled: func(providedHost config.GatewayConfig) {
+				releasedHosts = append(releasedHosts, providedHost)
+			},
+		}
+		args.UpstreamClient = &testscommon.UpstreamClientStub{
+			DoCalled: func(request *http.Request, timeout time.Duration) (*http.Response, error) {
+				assert.Fail(t, "should have not forwarded the request")
+				return nil, expectedErr
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), errKeyOutOfScope.Error())
+		assert.Contains(t, recorder.Body.String(), string(ReturnCodeForbidden))
+		assert.Empty(t, recorder.Header().Values(headerCreditsCost))
+		assert.Equal(t, []config.GatewayConfig{host}, releasedHosts)
+	})
+	t.Run("path values should be used when finding the host", func(t *testing.T) {
+		t.Parallel()
+
+		expectedErrLocal := errors.New("stop here")
+		var providedValues map[string][]string
+		args := createMockArgsRequestsProcessor()
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+				return common.AccessResult{RequestURI: "/block/1/by-nonce/37?withTxs=true"}, nil
+			},
+		}
+		args.PathValuesExtractor = &testscommon.PathValuesExtractorStub{
+			ExtractValuesCalled: func(requestPath string) map[string][]string {
+				assert.Equal(t, "/block/1/by-nonce/37", requestPath)
+				return map[string][]string{
+					UrlParameterBlockNonce: {"37"},
+				}
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				providedValues = urlValues
+				return config.GatewayConfig{}, expectedErrLocal
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/v1/key/block/1/by-nonce/37?withTxs=true&blockNonce=99", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.Equal(t, []string{"37"}, providedValues[UrlParameterBlockNonce])
+		assert.Equal(t, []string{"true"}, providedValues["withTxs"])
+	})
+	t.Run("hash values should be used when finding the host", func(t *testing.T) {
+		t.Parallel()
+
+		expectedErrLocal := errors.New("stop here")
+		var providedValues map[string][]string
+		args := createMockArgsRequestsProcessor()
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+				return common.AccessResult{RequestURI: "/transaction/aabb?withResults=true"}, nil
+			},
+		}
+		args.HashEpochResolver = &testscommon.HashEpochResolverStub{
+			ExtractValuesCalled: func(ctx context.Context, requestPath string) map[string][]string {
+				assert.Equal(t, "/transaction/aabb", requestPath)
+				return map[string][]string{
+					UrlParameterHintEpoch: {"1200"},
+				}
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				providedValues = urlValues
+				return config.GatewayConfig{}, expectedErrLocal
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/v1/key/transaction/aabb?withResults=true", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.Equal(t, []string{"1200"}, providedValues[UrlParameterHintEpoch])
+		assert.Equal(t, []string{"true"}, providedValues["withResults"])
+	})
+	t.Run("hash resolver should not be called if the request carries a routing value", func(t *testing.T) {
+		t.Parallel()
+
+		expectedErrLocal := errors.New("stop here")
+		var providedValues map[string][]string
+		args := createMockArgsRequestsProcessor()
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+				return common.AccessResult{RequestURI: "/transaction/aabb?hintEpoch=5"}, nil
+			},
+		}
+		args.HashEpochResolver = &testscommon.HashEpochResolverStub{
+			ExtractValuesCalled: func(ctx context.Context, requestPath string) map[string][]string {
+				require.Fail(t, "should have not called the hash epoch resolver")
+				return nil
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				providedValues = urlValues
+				return config.GatewayConfig{}, expectedErrLocal
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/v1/key/transaction/aabb?hintEpoch=5", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.Equal(t, []string{"5"}, providedValues[UrlParameterHintEpoch])
+	})
+	t.Run("timestamp should be converted into a block nonce", func(t *testing.T) {
+		t.Parallel()
+
+		receivedQuery := ""
+		testHttp := httptest.NewServer(&testscommon.HttpHandlerStub{
+			ServeHTTPCalled: func(writer http.ResponseWriter, request *http.Request) {
+				receivedQuery = request.URL.RawQuery
+				writer.WriteHeader(http.StatusOK)
+			},
+		})
+		defer testHttp.Close()
+
+		var providedValues map[string][]string
+		args := createMockArgsRequestsProcessor()
+		args.TimestampResolver = &testscommon.TimestampResolverStub{
+			ResolveNonceCalled: func(timestamp string) (uint64, bool, error) {
+				assert.Equal(t, "2023-06-01 12:00", timestamp)
+				return 15678, true, nil
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				providedValues = urlValues
+				return config.GatewayConfig{
+					URL: testHttp.URL,
+				}, nil
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/address/erd1qqq?atTimestamp=2023-06-01+12%3A00&withGuardianInfo=true", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, []string{"15678"}, providedValues[UrlParameterBlockNonce])
+		assert.Equal(t, "blockNonce=15678&withGuardianInfo=true", receivedQuery)
+	})
+	t.Run("timestamp after the latest known point should be forwarded as it is", func(t *testing.T) {
+		t.Parallel()
+
+		expectedErrLocal := errors.New("stop here")
+		var providedValues map[string][]string
+		args := createMockArgsRequestsProcessor()
+		args.TimestampResolver = &testscommon.TimestampResolverStub{
+			ResolveNonceCalled: func(timestamp string) (uint64, bool, error) {
+				return 0, false, nil
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				providedValues = urlValues
+				return config.GatewayConfig{}, expectedErrLocal
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/address/erd1qqq?atTimestamp=1900000000", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.False(t, url.Values(providedValues).Has(UrlParameterBlockNonce))
+	})
+	t.Run("timestamp should not be resolved if the request carries a block nonce", func(t *testing.T) {
+		t.Parallel()
+
+		expectedErrLocal := errors.New("stop here")
+		var providedValues map[string][]string
+		args := createMockArgsRequestsProcessor()
+		args.TimestampResolver = &testscommon.TimestampResolverStub{
+			ResolveNonceCalled: func(timestamp string) (uint64, bool, error) {
+				require.Fail(t, "should have not called the timestamp resolver")
+				return 0, false, nil
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				providedValues = urlValues
+				return config.GatewayConfig{}, expectedErrLocal
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/address/erd1qqq?atTimestamp=1700000000&blockNonce=37", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.Equal(t, []string{"37"}, providedValues[UrlParameterBlockNonce])
+	})
+	t.Run("timestamp resolver errors, should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.TimestampResolver = &testscommon.TimestampResolverStub{
+			ResolveNonceCalled: func(timestamp string) (uint64, bool, error) {
+				return 0, false, errInvalidTimestamp
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/address/erd1qqq?atTimestamp=yesterday", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "invalid timestamp while resolving the atTimestamp parameter")
+	})
+	t.Run("timestamp routing not ready, should return service unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.TimestampResolver = &testscommon.TimestampResolverStub{
+			ResolveNonceCalled: func(timestamp string) (uint64, bool, error) {
+				return 0, false, errTimestampRoutingNotReady
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/address/erd1qqq?atTimestamp=1700000000", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	})
+	t.Run("hosts finder errors, should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{}, expectedErr
 			},
-			&testscommon.AccessCheckerStub{},
-			&testscommon.PerformanceMonitorStub{},
-			make([]string, 0))
+		}
+		processor, _ := NewRequestsProcessor(args)
 
 		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
 		request.URL.RawQuery = "a=b"
@@ -136,21 +647,36 @@ func TestRequestsProcessor_ServeHTTP(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
 		assert.Contains(t, recorder.Body.String(), "expected error")
 	})
+	t.Run("no healthy gateway, should return service unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{}, fmt.Errorf("%w for the gateway test", errNoHealthyGateway)
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), errNoHealthyGateway.Error())
+	})
 	t.Run("can not assemble request, should error", func(t *testing.T) {
 		t.Parallel()
 
-		processor, _ := NewRequestsProcessor(
-			&testscommon.HostsFinderStub{
-				FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
-					return config.GatewayConfig{
-						URL: "AAAA",
-					}, nil
-				},
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{
+					URL: "AAAA",
+				}, nil
 			},
-			&testscommon.AccessCheckerStub{},
-			&testscommon.PerformanceMonitorStub{},
-			make([]string, 0),
-		)
+		}
+		processor, _ := NewRequestsProcessor(args)
 
 		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
 		request.Method = "invalid method"
@@ -164,18 +690,15 @@ func TestRequestsProcessor_ServeHTTP(t *testing.T) {
 	t.Run("request fails, should error", func(t *testing.T) {
 		t.Parallel()
 
-		processor, _ := NewRequestsProcessor(
-			&testscommon.HostsFinderStub{
-				FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
-					return config.GatewayConfig{
-						URL: "unknown host",
-					}, nil
-				},
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{
+					URL: "unknown host",
+				}, nil
 			},
-			&testscommon.AccessCheckerStub{},
-			&testscommon.PerformanceMonitorStub{},
-			make([]string, 0),
-		)
+		}
+		processor, _ := NewRequestsProcessor(args)
 
 		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
 		request.Header["c"] = []string{"d"}
@@ -195,18 +718,16 @@ func TestRequestsProcessor_ServeHTTP(t *testing.T) {
 		})
 		defer testHttp.Close()
 
-		processor, _ := NewRequestsProcessor(
-			&testscommon.HostsFinderStub{
-				FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
-					return config.GatewayConfig{
-						URL: testHttp.URL,
-					}, nil
-				},
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{
+					URL: testHttp.URL,
+				}, nil
 			},
-			&testscommon.AccessCheckerStub{},
-			&testscommon.PerformanceMonitorStub{},
-			[]string{"/test/"},
-		)
+		}
+		args.ClosedEndpoints = []string{"/test/"}
+		processor, _ := NewRequestsProcessor(args)
 
 		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
 		request.Header["c"] = []string{"d"}
@@ -233,18 +754,20 @@ func TestRequestsProcessor_ServeHTTP(t *testing.T) {
 		})
 		defer testHttp.Close()
 
-		processor, _ := NewRequestsProcessor(
-			&testscommon.HostsFinderStub{
-				FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
-					return config.GatewayConfig{
-						URL: testHttp.URL,
-					}, nil
-				},
+		releasedHosts := make([]config.GatewayConfig, 0)
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{
+					URL:  testHttp.URL,
+					Name: "replica-A",
+				}, nil
+			},
+			ReleaseHostCalled: func(host config.GatewayConfig) {
+				releasedHosts = append(releasedHosts, host)
 			},
-			&testscommon.AccessCheckerStub{},
-			&testscommon.PerformanceMonitorStub{},
-			make([]string, 0),
-		)
+		}
+		processor, _ := NewRequestsProcessor(args)
 
 		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
 		request.Header["c"] = []string{"d"}
@@ -254,5 +777,1326 @@ func TestRequestsProcessor_ServeHTTP(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, recorder.Code)
 		assert.Equal(t, string(expectedResponseMarshalled), recorder.Body.String())
+		assert.Equal(t, "replica-A", recorder.Header().Get(origin))
+		assert.Equal(t, []config.GatewayConfig{{URL: testHttp.URL, Name: "replica-A"}}, releasedHosts)
+	})
+	t.Run("should route by the JSON body values and forward the body unchanged", func(t *testing.T) {
+		t.Parallel()
+
+		requestBody := `{"scAddress":"erd1qqq","funcName":"getSum","args":[],"blockNonce":12345}`
+		receivedBody := ""
+		testHttp := httptest.NewServer(&testscommon.HttpHandlerStub{
+			ServeHTTPCalled: func(writer http.ResponseWriter, request *http.Request) {
+				buff, _ := io.ReadAll(request.Body)
+				receivedBody = string(buff)
+				writer.WriteHeader(http.StatusOK)
+			},
+		})
+		defer testHttp.Close()
+
+		var providedValues map[string][]string
+		args := createMockArgsRequestsProcessor()
+		args.BodyValuesExtractor, _ = NewBodyValuesExtractor(config.BodyRoutingConfig{
+			MaxBodySizeInBytes: 1024,
+			Paths:              []string{"/vm-values/query"},
+		})
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+				return common.AccessResult{RequestURI: request.RequestURI}, nil
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				providedValues = urlValues
+				return config.GatewayConfig{
+					URL: testHttp.URL,
+				}, nil
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodPost, "/vm-values/query", strings.NewReader(requestBody))
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, []string{"12345"}, providedValues[UrlParameterBlockNonce])
+		assert.Equal(t, requestBody, receivedBody)
+	})
+	t.Run("query values should take precedence over the body values", func(t *testing.T) {
+		t.Parallel()
+
+		var providedValues map[string][]string
+		args := createMockArgsRequestsProcessor()
+		args.BodyValuesExtractor = &testscommon.BodyValuesExtractorStub{
+			ExtractValuesCalled: func(requestPath string, body io.Reader) (map[string][]string, io.Reader, error) {
+				return map[string][]string{
+					UrlParameterBlockNonce: {"1"},
+					UrlParameterHintEpoch:  {"2"},
+				}, body, nil
+			},
+		}
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+				return common.AccessResult{RequestURI: request.RequestURI}, nil
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				providedValues = urlValues
+				return config.GatewayConfig{}, expectedErr
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodPost, "/vm-values/query?blockNonce=37", strings.NewReader("{}"))
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, []string{"37"}, providedValues[UrlParameterBlockNonce])
+		assert.Equal(t, []string{"2"}, providedValues[UrlParameterHintEpoch])
+	})
+	t.Run("body read errors, should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.BodyValuesExtractor = &testscommon.BodyValuesExtractorStub{
+			ExtractValuesCalled: func(requestPath string, body io.Reader) (map[string][]string, io.Reader, error) {
+				return nil, nil, expectedErr
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				require.Fail(t, "should have not called the host finder")
+				return config.GatewayConfig{}, nil
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodPost, "/vm-values/query", strings.NewReader("{}"))
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "expected error while reading the request body")
+	})
+	t.Run("GET requests should not inspect the body", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.BodyValuesExtractor = &testscommon.BodyValuesExtractorStub{
+			ExtractValuesCalled: func(requestPath string, body io.Reader) (map[string][]string, io.Reader, error) {
+				require.Fail(t, "should have not called the body values extractor")
+				return nil, nil, nil
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{}, expectedErr
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/vm-values/query", strings.NewReader("{}"))
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	})
+}
+
+func TestRequestsProcessor_ServeHTTPRefusedRequestsAreNotCharged(t *testing.T) {
+	t.Parallel()
+
+	expectedErr := errors.New("expected error")
+	createArgs := func(t *testing.T) ArgsRequestsProcessor {
+		args := createMockArgsRequestsProcessor()
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ConsumeCreditsHandler: func(result common.AccessResult) {
+				assert.Fail(t, "should have not charged the request")
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: "http://gateway.invalid"}, nil
+			},
+		}
+		args.UpstreamClient = &testscommon.UpstreamClientStub{
+			DoCalled: func(request *http.Request, timeout time.Duration) (*http.Response, error) {
+				assert.Fail(t, "should have not forwarded the request")
+				return nil, expectedErr
+			},
+		}
+
+		return args
+	}
+
+	t.Run("unresolved timestamp should not charge the request", func(t *testing.T) {
+		t.Parallel()
+
+		args := createArgs(t)
+		args.TimestampResolver = &testscommon.TimestampResolverStub{
+			ResolveNonceCalled: func(timestamp string) (uint64, bool, error) {
+				return 0, false, expectedErr
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/address/erd1?atTimestamp=1685620800", nil))
+
+		assert.NotEqual(t, http.StatusOK, recorder.Code)
+	})
+	t.Run("unreadable body should not charge the request", func(t *testing.T) {
+		t.Parallel()
+
+		args := createArgs(t)
+		args.BodyValuesExtractor = &testscommon.BodyValuesExtractorStub{
+			ExtractValuesCalled: func(requestPath string, body io.Reader) (map[string][]string, io.Reader, error) {
+				return nil, nil, expectedErr
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/vm-values/query", strings.NewReader("{}")))
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+	t.Run("host not found should not charge the request", func(t *testing.T) {
+		t.Parallel()
+
+		args := createArgs(t)
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{}, errNoHealthyGateway
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/address/erd1", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	})
+	t.Run("closed endpoint should not charge the request", func(t *testing.T) {
+		t.Parallel()
+
+		args := createArgs(t)
+		args.ClosedEndpoints = []string{"/transaction/send"}
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/transaction/send", nil))
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
+
+func TestRequestsProcessor_ServeHTTPWithRetries(t *testing.T) {
+	t.Parallel()
+
+	createServer := func(statusCode int, numCalls *int) *httptest.Server {
+		return httptest.NewServer(&testscommon.HttpHandlerStub{
+			ServeHTTPCalled: func(writer http.ResponseWriter, request *http.Request) {
+				*numCalls++
+				writer.WriteHeader(statusCode)
+				_, _ = writer.Write([]byte(http.StatusText(statusCode)))
+			},
+		})
+	}
+	retryPolicy := &testscommon.RetryPolicyStub{
+		ShouldRetryCalled: func(method string, attempts uint32, statusCode int, err error) bool {
+			return method == http.MethodGet && attempts < 3 && (err != nil || statusCode == http.StatusServiceUnavailable)
+		},
+	}
+
+	t.Run("should retry on the alternative host", func(t *testing.T) {
+		t.Parallel()
+
+		numFailedCalls := 0
+		failingServer := createServer(http.StatusServiceUnavailable, &numFailedCalls)
+		defer failingServer.Close()
+
+		numCalls := 0
+		workingServer := createServer(http.StatusOK, &numCalls)
+		defer workingServer.Close()
+
+		releasedHosts := make([]string, 0)
+		providedTriedURLs := make([]string, 0)
+		args := createMockArgsRequestsProcessor()
+		args.RetryPolicy = retryPolicy
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: failingServer.URL, Name: "archive"}, nil
+			},
+			FindAlternativeHostCalled: func(urlValues map[string][]string, triedURLs []string) (config.GatewayConfig, error) {
+				assert.Equal(t, []string{"10"}, urlValues[UrlParameterHintEpoch])
+				providedTriedURLs = append(providedTriedURLs, triedURLs...)
+				return config.GatewayConfig{URL: workingServer.URL, Name: "fallback"}, nil
+			},
+			ReleaseHostCalled: func(host config.GatewayConfig) {
+				releasedHosts = append(releasedHosts, host.Name)
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/test/aa?hintEpoch=10", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, http.StatusText(http.StatusOK), recorder.Body.String())
+		assert.Equal(t, "fallback", recorder.Header().Get(origin))
+		assert.Equal(t, 1, numFailedCalls)
+		assert.Equal(t, 1, numCalls)
+		assert.Equal(t, []string{failingServer.URL}, providedTriedURLs)
+		assert.Equal(t, []string{"archive", "fallback"}, releasedHosts)
+	})
+	t.Run("should retry on request errors", func(t *testing.T) {
+		t.Parallel()
+
+		numCalls := 0
+		workingServer := createServer(http.StatusOK, &numCalls)
+		defer workingServer.Close()
+
+		args := createMockArgsRequestsProcessor()
+		args.RetryPolicy = retryPolicy
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: "unknown host"}, nil
+			},
+			FindAlternativeHostCalled: func(urlValues map[string][]string, triedURLs []string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: workingServer.URL, Name: "replica"}, nil
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "replica", recorder.Header().Get(origin))
+		assert.Equal(t, 1, numCalls)
+	})
+	t.Run("should stop after the maximum number of attempts", func(t *testing.T) {
+		t.Parallel()
+
+		numFailedCalls := 0
+		failingServer := createServer(http.StatusServiceUnavailable, &numFailedCalls)
+		defer failingServer.Close()
+
+		numBackoffs := 0
+		args := createMockArgsRequestsProcessor()
+		args.RetryPolicy = &testscommon.RetryPolicyStub{
+			ShouldRetryCalled: retryPolicy.ShouldRetryCalled,
+			BackoffCalled: func(attempts uint32) time.Duration {
+				numBackoffs++
+				return time.Millisecond
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: failingServer.URL}, nil
+			},
+			FindAlternativeHostCalled: func(urlValues map[string][]string, triedURLs []string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: failingServer.URL}, nil
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Equal(t, http.StatusText(http.StatusServiceUnavailable), recorder.Body.String())
+		assert.Equal(t, 3, numFailedCalls)
+		assert.Equal(t, 2, numBackoffs)
+	})
+	t.Run("no alternative host should return the failed response", func(t *testing.T) {
+		t.Parallel()
+
+		numFailedCalls := 0
+		failingServer := createServer(http.StatusServiceUnavailable, &numFailedCalls)
+		defer failingServer.Close()
+
+		args := createMockArgsRequestsProcessor()
+		args.RetryPolicy = retryPolicy
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: failingServer.URL, Name: "archive"}, nil
+			},
+			FindAlternativeHostCalled: func(urlValues map[string][]string, triedURLs []string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{}, errNoAlternativeGateway
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Equal(t, http.StatusText(http.StatusServiceUnavailable), recorder.Body.String())
+		assert.Equal(t, "archive", recorder.Header().Get(origin))
+		assert.Equal(t, 1, numFailedCalls)
+	})
+	t.Run("alternative host out of the key scope should not be used", func(t *testing.T) {
+		t.Parallel()
+
+		numFailedCalls := 0
+		failingServer := createServer(http.StatusServiceUnavailable, &numFailedCalls)
+		defer failingServer.Close()
+
+		releasedHosts := make([]string, 0)
+		args := createMockArgsRequestsProcessor()
+		args.RetryPolicy = retryPolicy
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			CheckHostHandler: func(result common.AccessResult, host config.GatewayConfig) error {
+				if host.Name == "fallback" {
+					return errKeyOutOfScope
+				}
+
+				return nil
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: failingServer.URL, Name: "archive"}, nil
+			},
+			FindAlternativeHostCalled: func(urlValues map[string][]string, triedURLs []string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: "http://fallback.invalid", Name: "fallback"}, nil
+			},
+			ReleaseHostCalled: func(host config.GatewayConfig) {
+				releasedHosts = append(releasedHosts, host.Name)
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Equal(t, "archive", recorder.Header().Get(origin))
+		assert.Equal(t, 1, numFailedCalls)
+		assert.Equal(t, []string{"fallback", "archive"}, releasedHosts)
+	})
+	t.Run("requests with a body should not be retried", func(t *testing.T) {
+		t.Parallel()
+
+		numFailedCalls := 0
+		failingServer := createServer(http.StatusServiceUnavailable, &numFailedCalls)
+		defer failingServer.Close()
+
+		args := createMockArgsRequestsProcessor()
+		args.RetryPolicy = &testscommon.RetryPolicyStub{
+			ShouldRetryCalled: func(method string, attempts uint32, statusCode int, err error) bool {
+				assert.Fail(t, "should not be called")
+				return true
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: failingServer.URL}, nil
+			},
+			FindAlternativeHostCalled: func(urlValues map[string][]string, triedURLs []string) (config.GatewayConfig, error) {
+				assert.Fail(t, "should not be called")
+				return config.GatewayConfig{}, nil
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodPost, "/vm-values/query", strings.NewReader(`{"blockNonce":10}`))
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Equal(t, 1, numFailedCalls)
+	})
+	t.Run("client gone during the backoff should error", func(t *testing.T) {
+		t.Parallel()
+
+		numFailedCalls := 0
+		failingServer := createServer(http.StatusServiceUnavailable, &numFailedCalls)
+		defer failingServer.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		args := createMockArgsRequestsProcessor()
+		args.RetryPolicy = &testscommon.RetryPolicyStub{
+			ShouldRetryCalled: retryPolicy.ShouldRetryCalled,
+			BackoffCalled: func(attempts uint32) time.Duration {
+				cancel()
+				return time.Minute
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: failingServer.URL}, nil
+			},
+			FindAlternativeHostCalled: func(urlValues map[string][]string, triedURLs []string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: failingServer.URL}, nil
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil).WithContext(ctx)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), context.Canceled.Error())
+		assert.Equal(t, 1, numFailedCalls)
+	})
+}
+
+func TestRequestsProcessor_ServeHTTPStreaming(t *testing.T) {
+	t.Parallel()
+
+	expectedErr := errors.New("expected error")
+
+	t.Run("should forward the gateway timeout and the client context", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: "http://gateway", TimeoutInSeconds: 7}, nil
+			},
+		}
+		args.UpstreamClient = &testscommon.UpstreamClientStub{
+			DoCalled: func(request *http.Request, timeout time.Duration) (*http.Response, error) {
+				assert.Equal(t, 7*time.Second, timeout)
+				assert.Equal(t, "http://gateway/test/aa?a=b", request.URL.String())
+				assert.Equal(t, ctx, request.Context())
+
+				return &http.Response{
+					StatusCode: http.StatusAccepted,
+					Header:     http.Header{"X-Test": []string{"value"}},
+					Body:       io.NopCloser(strings.NewReader("response")),
+				}, nil
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/test/aa?a=b", nil).WithContext(ctx)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusAccepted, recorder.Code)
+		assert.Equal(t, "value", recorder.Header().Get("X-Test"))
+		assert.Equal(t, "response", recorder.Body.String())
+	})
+	t.Run("should stream large responses", func(t *testing.T) {
+		t.Parallel()
+
+		largeResponse := strings.Repeat("0123456789abcdef", 1<<16)
+		testHttp := httptest.NewServer(&testscommon.HttpHandlerStub{
+			ServeHTTPCalled: func(writer http.ResponseWriter, request *http.Request) {
+				_, _ = writer.Write([]byte(largeResponse))
+			},
+		})
+		defer testHttp.Close()
+
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: testHttp.URL}, nil
+			},
+		}
+		args.UpstreamClient = NewUpstreamClient(config.ForwardingConfig{CopyBufferSizeInBytes: 4096})
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/account/storage", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, largeResponse, recorder.Body.String())
+		assert.True(t, recorder.Flushed)
+	})
+	t.Run("streaming errors should keep the already sent status code", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: "http://gateway"}, nil
+			},
+		}
+		args.UpstreamClient = &testscommon.UpstreamClientStub{
+			DoCalled: func(request *http.Request, timeout time.Duration) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader("partial response")),
+				}, nil
+			},
+			CopyBodyCalled: func(destination io.Writer, source io.Reader) (int64, error) {
+				n, _ := destination.Write([]byte("partial"))
+				return int64(n), expectedErr
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "partial", recorder.Body.String())
+	})
+	t.Run("client disconnect should cancel the upstream request", func(t *testing.T) {
+		t.Parallel()
+
+		upstreamCanceled := make(chan struct{})
+		testHttp := httptest.NewServer(&testscommon.HttpHandlerStub{
+			ServeHTTPCalled: func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusOK)
+				_, _ = writer.Write([]byte("first chunk"))
+				writer.(http.Flusher).Flush()
+
+				select {
+				case <-request.Context().Done():
+					close(upstreamCanceled)
+				case <-time.After(time.Minute):
+				}
+			},
+		})
+		defer testHttp.Close()
+
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: testHttp.URL}, nil
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+
+		request := httptest.NewRequest(http.MethodGet, "/test/aa", nil).WithContext(ctx)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		select {
+		case <-upstreamCanceled:
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "the upstream request was not canceled")
+		}
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "first chunk", recorder.Body.String())
+	})
+}
+
+func TestRequestsProcessor_ServeHTTPWithResponseCache(t *testing.T) {
+	t.Parallel()
+
+	createServer := func(statusCode int, response string, numCalls *int) *httptest.Server {
+		return httptest.NewServer(&testscommon.HttpHandlerStub{
+			ServeHTTPCalled: func(writer http.ResponseWriter, request *http.Request) {
+				*numCalls++
+				writer.Header().Set("Content-Type", "application/json")
+				writer.WriteHeader(statusCode)
+				_, _ = writer.Write([]byte(response))
+			},
+		})
+	}
+	createHostFinder := func(url string, epochEnd string) *testscommon.HostsFinderStub {
+		return &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: url, Name: "archive", EpochEnd: epochEnd}, nil
+			},
+		}
+	}
+	createMapCache := func(maxEntrySize uint64) (*testscommon.ResponseCacheStub, map[string]common.CachedResponse) {
+		cachedResponses := make(map[string]common.CachedResponse)
+		return &testscommon.ResponseCacheStub{
+			IsEnabledCalled: func() bool {
+				return true
+			},
+			MaxEntrySizeCalled: func() uint64 {
+				return maxEntrySize
+			},
+			GetCalled: func(key string) (common.CachedResponse, bool) {
+				response, found := cachedResponses[key]
+				return response, found
+			},
+			PutCalled: func(key string, response common.CachedResponse) {
+				cachedResponses[key] = response
+			},
+		}, cachedResponses
+	}
+	serve := func(processor *requestsProcessor, method string, uri string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, uri, nil)
+		request.Header.Set("Accept-Encoding", "gzip")
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("pinned requests should be cached", func(t *testing.T) {
+		t.Parallel()
+
+		numCalls := 0
+		server := createServer(http.StatusOK, "response", &numCalls)
+		defer server.Close()
+
+		cache, cachedResponses := createMapCache(100)
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = createHostFinder(server.URL, "99")
+		args.ResponseCache = cache
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := serve(processor, http.MethodGet, "/address/erd1?hintEpoch=10&blockNonce=1000")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "response", recorder.Body.String())
+		assert.Equal(t, cacheMiss, recorder.Header().Get(xCacheHeader))
+		assert.Equal(t, 1, numCalls)
+
+		expectedKey := "GET /address/erd1?blockNonce=1000&hintEpoch=10 gzip"
+		require.Contains(t, cachedResponses, expectedKey)
+		assert.Equal(t, "response", string(cachedResponses[expectedKey].Body))
+		assert.Equal(t, "archive", cachedResponses[expectedKey].Header.Get(origin))
+
+		// same request with the query parameters in a different order
+		recorder = serve(processor, http.MethodGet, "/address/erd1?blockNonce=1000&hintEpoch=10")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "response", recorder.Body.String())
+		assert.Equal(t, cacheHit, recorder.Header().Get(xCacheHeader))
+		assert.Equal(t, "archive", recorder.Header().Get(origin))
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+		assert.Equal(t, 1, numCalls)
+	})
+	t.Run("requests without pinned coordinates should not be cached", func(t *testing.T) {
+		t.Parallel()
+
+		numCalls := 0
+		server := createServer(http.StatusOK, "response", &numCalls)
+		defer server.Close()
+
+		cache, cachedResponses := createMapCache(100)
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = createHostFinder(server.URL, "99")
+		args.ResponseCache = cache
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := serve(processor, http.MethodGet, "/address/erd1")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get(xCacheHeader))
+		assert.Empty(t, cachedResponses)
+	})
+	t.Run("requests served by the latest gateway should not be cached", func(t *testing.T) {
+		t.Parallel()
+
+		numCalls := 0
+		server := createServer(http.StatusOK, "response", &numCalls)
+		defer server.Close()
+
+		cache, cachedResponses := createMapCache(100)
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = createHostFinder(server.URL, "latest")
+		args.ResponseCache = cache
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := serve(processor, http.MethodGet, "/address/erd1?blockNonce=1000")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get(xCacheHeader))
+		assert.Empty(t, cachedResponses)
+	})
+	t.Run("disabled cache should not be used", func(t *testing.T) {
+		t.Parallel()
+
+		numCalls := 0
+		server := createServer(http.StatusOK, "response", &numCalls)
+		defer server.Close()
+
+		cache, cachedResponses := createMapCache(100)
+		cache.IsEnabledCalled = func() bool {
+			return false
+		}
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = createHostFinder(server.URL, "99")
+		args.ResponseCache = cache
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := serve(processor, http.MethodGet, "/address/erd1?blockNonce=1000")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get(xCacheHeader))
+		assert.Empty(t, cachedResponses)
+	})
+	t.Run("POST requests should not be cached", func(t *testing.T) {
+		t.Parallel()
+
+		numCalls := 0
+		server := createServer(http.StatusOK, "response", &numCalls)
+		defer server.Close()
+
+		cache, cachedResponses := createMapCache(100)
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = createHostFinder(server.URL, "99")
+		args.ResponseCache = cache
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := serve(processor, http.MethodPost, "/vm-values/query?blockNonce=1000")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, cachedResponses)
+	})
+	t.Run("error responses should not be cached", func(t *testing.T) {
+		t.Parallel()
+
+		numCalls := 0
+		server := createServer(http.StatusNotFound, "not found", &numCalls)
+		defer server.Close()
+
+		cache, cachedResponses := createMapCache(100)
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = createHostFinder(server.URL, "99")
+		args.ResponseCache = cache
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := serve(processor, http.MethodGet, "/address/erd1?blockNonce=1000")
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+		assert.Equal(t, cacheMiss, recorder.Header().Get(xCacheHeader))
+		assert.Empty(t, cachedResponses)
+	})
+	t.Run("responses larger than the maximum entry size should not be cached", func(t *testing.T) {
+		t.Parallel()
+
+		numCalls := 0
+		largeResponse := strings.Repeat("0123456789", 100)
+		server := createServer(http.StatusOK, largeResponse, &numCalls)
+		defer server.Close()
+
+		cache, cachedResponses := createMapCache(999)
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = createHostFinder(server.URL, "99")
+		args.ResponseCache = cache
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := serve(processor, http.MethodGet, "/address/erd1?blockNonce=1000")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, largeResponse, recorder.Body.String())
+		assert.Empty(t, cachedResponses)
+	})
+}
+
+func TestRequestsProcessor_ServeHTTPWithRequestsCoalescer(t *testing.T) {
+	t.Parallel()
+
+	type blockingServer struct {
+		*httptest.Server
+		numCalls uint32
+		release  chan struct{}
+	}
+	createBlockingServer := func(response string) *blockingServer {
+		server := &blockingServer{
+			release: make(chan struct{}),
+		}
+		server.Server = httptest.NewServer(&testscommon.HttpHandlerStub{
+			ServeHTTPCalled: func(writer http.ResponseWriter, request *http.Request) {
+				atomic.AddUint32(&server.numCalls, 1)
+				<-server.release
+				writer.Header().Set("Content-Type", "application/json")
+				_, _ = writer.Write([]byte(response))
+			},
+		})
+
+		return server
+	}
+	createProcessor := func(url string, maxResponseSize uint64) (*requestsProcessor, *requestsCoalescer) {
+		coalescer, _ := NewRequestsCoalescer(config.CoalescingConfig{
+			Enabled:                true,
+			MaxResponseSizeInBytes: maxResponseSize,
+		})
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: url, Name: "gateway", EpochStart: "0", EpochEnd: "latest"}, nil
+			},
+		}
+		args.RequestsCoalescer = coalescer
+		processor, _ := NewRequestsProcessor(args)
+
+		return processor, coalescer
+	}
+	serveConcurrently := func(processor *requestsProcessor, method string, uris []string) []*httptest.ResponseRecorder {
+		recorders := make([]*httptest.ResponseRecorder, len(uris))
+		wg := sync.WaitGroup{}
+		wg.Add(len(uris))
+		for i, uri := range uris {
+			recorders[i] = httptest.NewRecorder()
+			go func(recorder *httptest.ResponseRecorder, uri string) {
+				defer wg.Done()
+
+				processor.ServeHTTP(recorder, httptest.NewRequest(method, uri, nil))
+			}(recorders[i], uri)
+		}
+		wg.Wait()
+
+		return recorders
+	}
+	expectedKey := "GET /address/erd1?withGuardianInfo=true&x=1  0-latest/-"
+
+	t.Run("concurrent identical requests should be sent once", func(t *testing.T) {
+		t.Parallel()
+
+		server := createBlockingServer("response")
+		defer server.Close()
+
+		processor, coalescer := createProcessor(server.URL, 1024)
+		numRequests := 200
+		uris := make([]string, 0, numRequests)
+		for i := 0; i < numRequests; i++ {
+			// same request, written in different ways
+			switch i % 3 {
+			case 0:
+				uris = append(uris, "/address/erd1?withGuardianInfo=true&x=1")
+			case 1:
+				uris = append(uris, "/address/erd1?x=1&withGuardianInfo=true")
+			default:
+				uris = append(uris, "//address/./erd1?x=1&withGuardianInfo=true")
+			}
+		}
+
+		go func() {
+			waitForWaitingRequests(t, coalescer, expectedKey, numRequests-1)
+			close(server.release)
+		}()
+		recorders := serveConcurrently(processor, http.MethodGet, uris)
+
+		assert.Equal(t, uint32(1), atomic.LoadUint32(&server.numCalls))
+		for _, recorder := range recorders {
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, "response", recorder.Body.String())
+			assert.Equal(t, "gateway", recorder.Header().Get(origin))
+			assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+		}
+
+		metrics := coalescer.GetMetrics()
+		assert.Equal(t, uint64(1), metrics[coalescingUpstreamCalls])
+		assert.Equal(t, uint64(numRequests-1), metrics[coalescingMerged])
+	})
+	t.Run("responses larger than the maximum size should not be shared", func(t *testing.T) {
+		t.Parallel()
+
+		largeResponse := strings.Repeat("0123456789", 20)
+		server := createBlockingServer(largeResponse)
+		defer server.Close()
+
+		processor, coalescer := createProcessor(server.URL, 100)
+		numRequests := 20
+		uris := make([]string, 0, numRequests)
+		for i := 0; i < numRequests; i++ {
+			uris = append(uris, "/address/erd1?withGuardianInfo=true&x=1")
+		}
+
+		go func() {
+			waitForWaitingRequests(t, coalescer, expectedKey, numRequests-1)
+			close(server.release)
+		}()
+		recorders := serveConcurrently(processor, http.MethodGet, uris)
+
+		assert.Equal(t, uint32(numRequests), atomic.LoadUint32(&server.numCalls))
+		for _, recorder := range recorders {
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, largeResponse, recorder.Body.String())
+		}
+
+		metrics := coalescer.GetMetrics()
+		assert.Equal(t, uint64(0), metrics[coalescingMerged])
+		assert.Equal(t, uint64(numRequests-1), metrics[coalescingNotShared])
+	})
+	t.Run("POST requests should not be merged", func(t *testing.T) {
+		t.Parallel()
+
+		server := createBlockingServer("response")
+		close(server.release)
+		defer server.Close()
+
+		processor, coalescer := createProcessor(server.URL, 1024)
+		numRequests := 20
+		uris := make([]string, 0, numRequests)
+		for i := 0; i < numRequests; i++ {
+			uris = append(uris, "/vm-values/query")
+		}
+
+		recorders := serveConcurrently(processor, http.MethodPost, uris)
+		assert.Equal(t, uint32(numRequests), atomic.LoadUint32(&server.numCalls))
+		for _, recorder := range recorders {
+			assert.Equal(t, "response", recorder.Body.String())
+		}
+		assert.Equal(t, uint64(0), coalescer.GetMetrics()[coalescingUpstreamCalls])
+	})
+	t.Run("disabled coalescer should not be used", func(t *testing.T) {
+		t.Parallel()
+
+		server := createBlockingServer("response")
+		close(server.release)
+		defer server.Close()
+
+		args := createMockArgsRequestsProcessor()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: server.URL, Name: "gateway"}, nil
+			},
+		}
+		args.RequestsCoalescer = &testscommon.RequestsCoalescerStub{
+			DoCalled: func(ctx context.Context, key string, handler func() (common.CachedResponse, bool)) (common.CachedResponse, bool, bool) {
+				assert.Fail(t, "should have not been called")
+				return common.CachedResponse{}, false, false
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/address/erd1", nil))
+		assert.Equal(t, "response", recorder.Body.String())
+	})
+}
+
+func TestNormalizePath(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "/", normalizePath(""))
+	assert.Equal(t, "/", normalizePath("/"))
+	assert.Equal(t, "/", normalizePath("//"))
+	assert.Equal(t, "/address/erd1", normalizePath("/address/erd1"))
+	assert.Equal(t, "/address/erd1", normalizePath("//address/./erd1"))
+	assert.Equal(t, "/address/erd1/", normalizePath("/address/erd1//"))
+	assert.Equal(t, "/erd1", normalizePath("/address/../erd1"))
+}
+
+func TestRequestsProcessor_ServeHTTPHeaders(t *testing.T) {
+	t.Parallel()
+
+	var upstreamHeader http.Header
+	server := httptest.NewServer(&testscommon.HttpHandlerStub{
+		ServeHTTPCalled: func(writer http.ResponseWriter, request *http.Request) {
+			upstreamHeader = request.Header.Clone()
+			writer.Header().Set("Content-Type", "application/json")
+			writer.Header().Set("Set-Cookie", "observer=1")
+			writer.Header().Set(headerRequestID, "gateway request id")
+			writer.WriteHeader(http.StatusOK)
+		},
+	})
+	defer server.Close()
+
+	headersPolicy, _ := NewHeadersPolicy(config.HeadersConfig{})
+	args := createMockArgsRequestsProcessor()
+	args.HeadersPolicy = headersPolicy
+	args.HostFinder = &testscommon.HostsFinderStub{
+		FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+			return config.GatewayConfig{URL: server.URL, Name: "gateway"}, nil
+		},
+	}
+	processor, _ := NewRequestsProcessor(args)
+
+	t.Run("should strip the credentials and add the forwarding headers", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/address/erd1", nil)
+		request.RemoteAddr = "10.0.0.1:4321"
+		request.Header.Set(headerApiKey, "secret key")
+		request.Header.Set(headerAuthorization, "Bearer token")
+		request.Header.Set("X-Custom", "custom")
+		request.Header.Set(headerRequestID, "client-request-id")
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, upstreamHeader.Get(headerApiKey))
+		assert.Empty(t, upstreamHeader.Get(headerAuthorization))
+		assert.Equal(t, "custom", upstreamHeader.Get("X-Custom"))
+		assert.Equal(t, "10.0.0.1", upstreamHeader.Get(headerForwardedFor))
+		assert.Equal(t, forwardedProtoHTTP, upstreamHeader.Get(headerForwardedProto))
+		assert.Equal(t, "client-request-id", upstreamHeader.Get(headerRequestID))
+
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+		assert.Empty(t, recorder.Header().Get("Set-Cookie"))
+		assert.Equal(t, "client-request-id", recorder.Header().Get(headerRequestID))
+	})
+	t.Run("should generate the request ID if the client did not provide one", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/address/erd1", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		requestID := recorder.Header().Get(headerRequestID)
+		assert.NotEmpty(t, requestID)
+		assert.Equal(t, requestID, upstreamHeader.Get(headerRequestID))
+	})
+	t.Run("rejected requests should also carry the request ID", func(t *testing.T) {
+		args := createMockArgsRequestsProcessor()
+		args.HeadersPolicy = headersPolicy
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+				return common.AccessResult{}, errors.New("not allowed")
+			},
+		}
+		rejectingProcessor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/address/erd1", nil)
+		request.Header.Set(headerRequestID, "client-request-id")
+		recorder := httptest.NewRecorder()
+		rejectingProcessor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.Equal(t, "client-request-id", recorder.Header().Get(headerRequestID))
+	})
+	t.Run("cached responses should carry the current request ID", func(t *testing.T) {
+		args := createMockArgsRequestsProcessor()
+		args.HeadersPolicy = headersPolicy
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: server.URL, Name: "gateway", EpochEnd: "9"}, nil
+			},
+		}
+		args.ResponseCache = &testscommon.ResponseCacheStub{
+			IsEnabledCalled: func() bool {
+				return true
+			},
+			GetCalled: func(key string) (common.CachedResponse, bool) {
+				return common.CachedResponse{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{headerRequestID: []string{"previous-request-id"}},
+					Body:       []byte("cached"),
+				}, true
+			},
+		}
+		cachingProcessor, _ := NewRequestsProcessor(args)
+
+		request := httptest.NewRequest(http.MethodGet, "/address/erd1?blockNonce=1", nil)
+		request.Header.Set(headerRequestID, "client-request-id")
+		recorder := httptest.NewRecorder()
+		cachingProcessor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, "cached", recorder.Body.String())
+		assert.Equal(t, "client-request-id", recorder.Header().Get(headerRequestID))
+	})
+}
+
+func TestRequestsProcessor_ServeHTTPRateLimitHeaders(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(&testscommon.HttpHandlerStub{
+		ServeHTTPCalled: func(writer http.ResponseWriter, request *http.Request) {
+			writer.Header().Set(headerRateLimitLimit, "1000")
+			writer.Header().Set(headerRetryAfter, "100")
+			writer.WriteHeader(http.StatusOK)
+			_, _ = writer.Write([]byte("response"))
+		},
+	})
+	defer server.Close()
+
+	limitedResult := common.RateLimitResult{
+		Allowed:    true,
+		Limit:      10,
+		Remaining:  4,
+		ResetAfter: 2500 * time.Millisecond,
+	}
+	createArgs := func(result common.RateLimitResult, err error) ArgsRequestsProcessor {
+		headersPolicy, _ := NewHeadersPolicy(config.HeadersConfig{})
+		args := createMockArgsRequestsProcessor()
+		args.HeadersPolicy = headersPolicy
+		args.AccessChecker = &testscommon.AccessCheckerStub{
+			ShouldProcessRequestHandler: func(request *http.Request) (common.AccessResult, error) {
+				return common.AccessResult{RequestURI: request.RequestURI, RateLimit: result}, err
+			},
+		}
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				return config.GatewayConfig{URL: server.URL, Name: "gateway", EpochEnd: "9"}, nil
+			},
+		}
+
+		return args
+	}
+
+	t.Run("throttled request should respond with 429 and the rate limit headers", func(t *testing.T) {
+		result := common.RateLimitResult{
+			Allowed:    false,
+			Limit:      10,
+			Remaining:  0,
+			ResetAfter: time.Minute,
+			RetryAfter: 1200 * time.Millisecond,
+		}
+		args := createArgs(result, &tooManyRequestsError{accountType: common.FreeAccountType, result: result})
+		args.HostFinder = &testscommon.HostsFinderStub{
+			FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+				require.Fail(t, "should have not called the host finder")
+				return config.GatewayConfig{}, nil
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/address/erd1", nil))
+
+		assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+		assert.Equal(t, "10", recorder.Header().Get(headerRateLimitLimit))
+		assert.Equal(t, "0", recorder.Header().Get(headerRateLimitRemaining))
+		assert.Equal(t, "60", recorder.Header().Get(headerRateLimitReset))
+		assert.Equal(t, "2", recorder.Header().Get(headerRetryAfter))
+		assert.Equal(t, jsonContentType, recorder.Header().Values("Content-Type"))
+
+		response := GenericAPIResponse{}
+		err := json.Unmarshal(recorder.Body.Bytes(), &response)
+		require.Nil(t, err)
+		assert.Equal(t, ReturnCodeTooManyRequests, response.Code)
+		assert.Equal(t, "too many requests for free account: maximum per quota: 10, retry after: 1.2s", response.Error)
+	})
+	t.Run("unauthorized request should respond with 401 and the unauthorized code", func(t *testing.T) {
+		processor, _ := NewRequestsProcessor(createArgs(common.RateLimitResult{}, errUnauthorized))
+
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/address/erd1", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.Empty(t, recorder.Header().Get(headerRateLimitLimit))
+
+		response := GenericAPIResponse{}
+		err := json.Unmarshal(recorder.Body.Bytes(), &response)
+		require.Nil(t, err)
+		assert.Equal(t, ReturnCodeUnauthorized, response.Code)
+	})
+	t.Run("proxied response should carry the limiter's headers instead of the gateway ones", func(t *testing.T) {
+		processor, _ := NewRequestsProcessor(createArgs(limitedResult, nil))
+
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/address/erd1", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "10", recorder.Header().Get(headerRateLimitLimit))
+		assert.Equal(t, "4", recorder.Header().Get(headerRateLimitRemaining))
+		assert.Equal(t, "3", recorder.Header().Get(headerRateLimitReset))
+		assert.Empty(t, recorder.Header().Values(headerRetryAfter))
+	})
+	t.Run("request released by the slow lane should get the admitted request's headers", func(t *testing.T) {
+		releasedResult := common.RateLimitResult{
+			Allowed:    true,
+			Limit:      10,
+			Remaining:  0,
+			ResetAfter: 30 * time.Second,
+		}
+		processor, _ := NewRequestsProcessor(createArgs(releasedResult, nil))
+
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/address/erd1", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "10", recorder.Header().Get(headerRateLimitLimit))
+		assert.Equal(t, "0", recorder.Header().Get(headerRateLimitRemaining))
+		assert.Equal(t, "30", recorder.Header().Get(headerRateLimitReset))
+		assert.Empty(t, recorder.Header().Values(headerRetryAfter))
+	})
+	t.Run("not limited account should not get rate limit headers", func(t *testing.T) {
+		processor, _ := NewRequestsProcessor(createArgs(common.RateLimitResult{Allowed: true}, nil))
+
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/address/erd1", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		// the gateway headers pass as the proxy did not set its own
+		assert.Equal(t, "1000", recorder.Header().Get(headerRateLimitLimit))
+		assert.Empty(t, recorder.Header().Get(headerRateLimitRemaining))
+	})
+	t.Run("cached response should carry the current limiter's headers", func(t *testing.T) {
+		args := createArgs(limitedResult, nil)
+		args.ResponseCache = &testscommon.ResponseCacheStub{
+			IsEnabledCalled: func() bool {
+				return true
+			},
+			GetCalled: func(key string) (common.CachedResponse, bool) {
+				return common.CachedResponse{
+					StatusCode: http.StatusOK,
+					Header: http.Header{
+						headerRateLimitLimit:     []string{"5"},
+						headerRateLimitRemaining: []string{"1"},
+						headerRateLimitReset:     []string{"7"},
+						headerRetryAfter:         []string{"0"},
+					},
+					Body: []byte("cached"),
+				}, true
+			},
+		}
+		processor, _ := NewRequestsProcessor(args)
+
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/address/erd1?blockNonce=1", nil))
+
+		assert.Equal(t, "cached", recorder.Body.String())
+		assert.Equal(t, "10", recorder.Header().Get(headerRateLimitLimit))
+		assert.Equal(t, "4", recorder.Header().Get(headerRateLimitRemaining))
+		assert.Equal(t, "3", recorder.Header().Get(headerRateLimitReset))
+	})
+}
+
+func TestRequestsProcessor_ServeHTTPUpstreamSettings(t *testing.T) {
+	t.Parallel()
+
+	var upstreamRequest *http.Request
+	server := httptest.NewServer(&testscommon.HttpHandlerStub{
+		ServeHTTPCalled: func(writer http.ResponseWriter, request *http.Request) {
+			upstreamRequest = request
+			writer.WriteHeader(http.StatusOK)
+		},
+	})
+	defer server.Close()
+
+	headersPolicy, _ := NewHeadersPolicy(config.HeadersConfig{})
+	args := createMockArgsRequestsProcessor()
+	args.HeadersPolicy = headersPolicy
+	args.HostFinder = &testscommon.HostsFinderStub{
+		FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+			return config.GatewayConfig{
+				URL:  server.URL + "/squad",
+				Name: "archive",
+				Headers: map[string]string{
+					"X-Squad-Token": "token",
+				},
+				BasicAuthUsername: "user",
+				BasicAuthPassword: "pass",
+				PathRewrites: []config.PathRewriteConfig{
+					{
+						Prefix:      "/network/",
+						Replacement: "/mainnet/v2/network/",
+					},
+					{
+						Prefix:      "/",
+						Replacement: "/mainnet/",
+					},
+				},
+			}, nil
+		},
+	}
+	processor, _ := NewRequestsProcessor(args)
+
+	t.Run("should rewrite the path and set the upstream credentials", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/address/erd1?blockNonce=1", nil)
+		request.Header.Set(headerAuthorization, "Bearer client token")
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "/squad/mainnet/address/erd1", upstreamRequest.URL.Path)
+		assert.Equal(t, "blockNonce=1", upstreamRequest.URL.RawQuery)
+		assert.Equal(t, "token", upstreamRequest.Header.Get("X-Squad-Token"))
+
+		username, password, ok := upstreamRequest.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", username)
+		assert.Equal(t, "pass", password)
+	})
+	t.Run("the first matching rewrite rule should apply", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/network/status/4294967295", nil)
+		recorder := httptest.NewRecorder()
+		processor.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "/squad/mainnet/v2/network/status/4294967295", upstreamRequest.URL.Path)
 	})
 }
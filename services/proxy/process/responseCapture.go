@@ -0,0 +1,56 @@
+package process
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// responseCapture forwards the written data to the wrapped writer, keeping a copy as long as it does not exceed the
+// maximum size
+type responseCapture struct {
+	writer    io.Writer
+	flusher   http.Flusher
+	buffer    bytes.Buffer
+	maxSize   uint64
+	overflown bool
+}
+
+func newResponseCapture(writer io.Writer, maxSize uint64) *responseCapture {
+	capture := &responseCapture{
+		writer:  writer,
+		maxSize: maxSize,
+	}
+	capture.flusher, _ = writer.(http.Flusher)
+
+	return capture
+}
+
+// Write writes the data to the wrapped writer and keeps a copy of it
+func (capture *responseCapture) Write(data []byte) (int, error) {
+	if !capture.overflown {
+		if uint64(capture.buffer.Len()+len(data)) > capture.maxSize {
+			capture.overflown = true
+			capture.buffer = bytes.Buffer{}
+		} else {
+			capture.buffer.Write(data)
+		}
+	}
+
+	return capture.writer.Write(data)
+}
+
+// Flush flushes the wrapped writer, if it allows it
+func (capture *responseCapture) Flush() {
+	if capture.flusher != nil {
+		capture.flusher.Flush()
+	}
+}
+
+func (capture *responseCapture) isOverflown() bool {
+	return capture.overflown
+}
+
+func (capture *responseCapture) bytes() []byte {
+	return capture.buffer.Bytes()
+}
@@ -0,0 +1,98 @@
+package process
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+const (
+	minStatusCode = 100
+	maxStatusCode = 599
+)
+
+type retryPolicy struct {
+	maxAttempts          uint32
+	backoff              time.Duration
+	maxBackoff           time.Duration
+	retryableStatusCodes map[int]struct{}
+}
+
+// NewRetryPolicy creates a new retry policy instance. A MaxAttempts value of 0 or 1 disables the retries.
+func NewRetryPolicy(cfg config.RetryConfig) (*retryPolicy, error) {
+	policy := &retryPolicy{
+		maxAttempts:          cfg.MaxAttempts,
+		backoff:              time.Duration(cfg.BackoffInMilliseconds) * time.Millisecond,
+		maxBackoff:           time.Duration(cfg.MaxBackoffInMilliseconds) * time.Millisecond,
+		retryableStatusCodes: make(map[int]struct{}, len(cfg.RetryableStatusCodes)),
+	}
+
+	for _, statusCode := range cfg.RetryableStatusCodes {
+		if statusCode < minStatusCode || statusCode > maxStatusCode {
+			return nil, fmt.Errorf("%w: %d", errInvalidRetryableStatusCode, statusCode)
+		}
+
+		policy.retryableStatusCodes[statusCode] = struct{}{}
+	}
+
+	return policy, nil
+}
+
+// ShouldRetry returns true if a request that was already sent attempts times should be sent again. Only the safe
+// methods are retried, when the request failed or the gateway answered with one of the retryable status codes.
+// The status code is ignored if the provided error is not nil.
+func (policy *retryPolicy) ShouldRetry(method string, attempts uint32, statusCode int, err error) bool {
+	if attempts >= policy.maxAttempts {
+		return false
+	}
+	if !isSafeMethod(method) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+
+	_, isRetryable := policy.retryableStatusCodes[statusCode]
+
+	return isRetryable
+}
+
+// Backoff returns the duration to wait before sending again a request that was already sent attempts times. The
+// duration doubles with each attempt and it is capped by the maximum backoff, if set.
+func (policy *retryPolicy) Backoff(attempts uint32) time.Duration {
+	backoff := policy.backoff
+	for i := uint32(1); i < attempts; i++ {
+		if policy.maxBackoff > 0 && backoff >= policy.maxBackoff {
+			break
+		}
+		if backoff > math.MaxInt64/2 {
+			break
+		}
+
+		backoff *= 2
+	}
+
+	if policy.maxBackoff > 0 && backoff > policy.maxBackoff {
+		return policy.maxBackoff
+	}
+
+	return backoff
+}
+
+// isSafeMethod returns true for the HTTP methods that do not alter the state of the server, as they can be replayed
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (policy *retryPolicy) IsInterfaceNil() bool {
+	return policy == nil
+}
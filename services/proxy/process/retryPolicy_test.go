@@ -0,0 +1,117 @@
+package process
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestRetryConfig() config.RetryConfig {
+	return config.RetryConfig{
+		MaxAttempts:              3,
+		BackoffInMilliseconds:    100,
+		MaxBackoffInMilliseconds: 300,
+		RetryableStatusCodes:     []int{http.StatusBadGateway, http.StatusServiceUnavailable},
+	}
+}
+
+func TestNewRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid retryable status code should error", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createTestRetryConfig()
+		cfg.RetryableStatusCodes = append(cfg.RetryableStatusCodes, 600)
+		policy, err := NewRetryPolicy(cfg)
+		assert.ErrorIs(t, err, errInvalidRetryableStatusCode)
+		assert.Contains(t, err.Error(), "600")
+		assert.Nil(t, policy)
+		assert.True(t, policy.IsInterfaceNil())
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		policy, err := NewRetryPolicy(createTestRetryConfig())
+		assert.Nil(t, err)
+		assert.False(t, policy.IsInterfaceNil())
+	})
+}
+
+func TestRetryPolicy_ShouldRetry(t *testing.T) {
+	t.Parallel()
+
+	errRequest := errors.New("request error")
+
+	t.Run("unsafe methods should not be retried", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewRetryPolicy(createTestRetryConfig())
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			assert.False(t, policy.ShouldRetry(method, 1, 0, errRequest), method)
+			assert.False(t, policy.ShouldRetry(method, 1, http.StatusBadGateway, nil), method)
+		}
+	})
+	t.Run("safe methods should be retried on errors and retryable status codes", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewRetryPolicy(createTestRetryConfig())
+		for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace} {
+			assert.True(t, policy.ShouldRetry(method, 1, 0, errRequest), method)
+			assert.True(t, policy.ShouldRetry(method, 1, http.StatusBadGateway, nil), method)
+			assert.True(t, policy.ShouldRetry(method, 2, http.StatusServiceUnavailable, nil), method)
+			assert.False(t, policy.ShouldRetry(method, 1, http.StatusInternalServerError, nil), method)
+			assert.False(t, policy.ShouldRetry(method, 1, http.StatusOK, nil), method)
+		}
+	})
+	t.Run("should not retry after the maximum number of attempts", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewRetryPolicy(createTestRetryConfig())
+		assert.False(t, policy.ShouldRetry(http.MethodGet, 3, 0, errRequest))
+		assert.False(t, policy.ShouldRetry(http.MethodGet, 4, http.StatusBadGateway, nil))
+	})
+	t.Run("disabled retries should not retry", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewRetryPolicy(config.RetryConfig{})
+		assert.False(t, policy.ShouldRetry(http.MethodGet, 1, 0, errRequest))
+
+		policy, _ = NewRetryPolicy(config.RetryConfig{MaxAttempts: 1})
+		assert.False(t, policy.ShouldRetry(http.MethodGet, 1, 0, errRequest))
+	})
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should double and be capped", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewRetryPolicy(createTestRetryConfig())
+		assert.Equal(t, 100*time.Millisecond, policy.Backoff(1))
+		assert.Equal(t, 200*time.Millisecond, policy.Backoff(2))
+		assert.Equal(t, 300*time.Millisecond, policy.Backoff(3))
+		assert.Equal(t, 300*time.Millisecond, policy.Backoff(100))
+	})
+	t.Run("no maximum should only double", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := createTestRetryConfig()
+		cfg.MaxBackoffInMilliseconds = 0
+		policy, _ := NewRetryPolicy(cfg)
+		assert.Equal(t, 100*time.Millisecond, policy.Backoff(1))
+		assert.Equal(t, 400*time.Millisecond, policy.Backoff(3))
+	})
+	t.Run("no backoff", func(t *testing.T) {
+		t.Parallel()
+
+		policy, _ := NewRetryPolicy(config.RetryConfig{MaxAttempts: 2})
+		assert.Equal(t, time.Duration(0), policy.Backoff(1))
+		assert.Equal(t, time.Duration(0), policy.Backoff(10))
+	})
+}
@@ -0,0 +1,32 @@
+package process
+
+import (
+	"sync/atomic"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+type roundRobinBalancer struct {
+	replicas []config.ReplicaConfig
+	index    uint64
+}
+
+func newRoundRobinBalancer(replicas []config.ReplicaConfig) *roundRobinBalancer {
+	return &roundRobinBalancer{
+		replicas: replicas,
+	}
+}
+
+func (balancer *roundRobinBalancer) next(isAvailable func(url string) bool) (config.ReplicaConfig, bool) {
+	index := atomic.AddUint64(&balancer.index, 1) - 1
+	numReplicas := uint64(len(balancer.replicas))
+
+	for i := uint64(0); i < numReplicas; i++ {
+		replica := balancer.replicas[(index+i)%numReplicas]
+		if isAvailable(replica.URL) {
+			return replica, true
+		}
+	}
+
+	return config.ReplicaConfig{}, false
+}
@@ -0,0 +1,75 @@
+package process
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundRobinBalancer_Next(t *testing.T) {
+	t.Parallel()
+
+	replicas := []config.ReplicaConfig{
+		{URL: "URL1"},
+		{URL: "URL2"},
+		{URL: "URL3"},
+	}
+
+	t.Run("should cycle through replicas", func(t *testing.T) {
+		t.Parallel()
+
+		balancer := newRoundRobinBalancer(replicas)
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, replicas[i%len(replicas)], nextReplica(t, balancer))
+		}
+	})
+	t.Run("concurrent calls should distribute evenly", func(t *testing.T) {
+		t.Parallel()
+
+		balancer := newRoundRobinBalancer(replicas)
+		numCalls := 3000
+		mut := sync.Mutex{}
+		counters := make(map[string]int)
+		wg := sync.WaitGroup{}
+		wg.Add(numCalls)
+		for i := 0; i < numCalls; i++ {
+			go func() {
+				replica := nextReplica(t, balancer)
+
+				mut.Lock()
+				counters[replica.URL]++
+				mut.Unlock()
+
+				wg.Done()
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, map[string]int{"URL1": 1000, "URL2": 1000, "URL3": 1000}, counters)
+	})
+	t.Run("unavailable replicas should be skipped", func(t *testing.T) {
+		t.Parallel()
+
+		balancer := newRoundRobinBalancer(replicas)
+		isAvailable := func(url string) bool {
+			return url != "URL2"
+		}
+		for i := 0; i < 10; i++ {
+			replica, found := balancer.next(isAvailable)
+			assert.True(t, found)
+			assert.NotEqual(t, "URL2", replica.URL)
+		}
+	})
+	t.Run("no available replica should return false", func(t *testing.T) {
+		t.Parallel()
+
+		balancer := newRoundRobinBalancer(replicas)
+		replica, found := balancer.next(func(url string) bool {
+			return false
+		})
+		assert.False(t, found)
+		assert.Empty(t, replica.URL)
+	})
+}
@@ -0,0 +1,167 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+const (
+	slowLaneQueued   = "queued_requests"
+	slowLaneReleased = "released_requests"
+	slowLaneRejected = "rejected_requests"
+	slowLaneUsers    = "users"
+)
+
+type userLane struct {
+	numQueued   uint64
+	lastRelease time.Time
+}
+
+type slowLane struct {
+	enabled        bool
+	interval       time.Duration
+	maxQueueSize   uint64
+	maxWait        time.Duration
+	getTimeHandler func() time.Time
+	waitHandler    func(ctx context.Context, duration time.Duration) error
+
+	mut         sync.Mutex
+	lanes       map[string]*userLane
+	numReleased uint64
+	numRejected uint64
+}
+
+// NewSlowLane creates a new slow lane. The throttled requests of a user wait in the user's queue and are released one
+// at a time, at the configured rate. A disabled slow lane rejects all the requests.
+func NewSlowLane(cfg config.SlowLaneConfig) (*slowLane, error) {
+	lane := &slowLane{
+		enabled:        cfg.Enabled,
+		getTimeHandler: time.Now,
+		waitHandler:    waitBackoff,
+		lanes:          make(map[string]*userLane),
+	}
+	if !cfg.Enabled {
+		return lane, nil
+	}
+
+	if cfg.RatePerSecond <= 0 || math.IsInf(cfg.RatePerSecond, 0) || math.IsNaN(cfg.RatePerSecond) {
+		return nil, fmt.Errorf("%w: invalid rate", errInvalidSlowLaneConfig)
+	}
+	if cfg.MaxQueueSizePerUser == 0 {
+		return nil, fmt.Errorf("%w: 0 maximum queue size", errInvalidSlowLaneConfig)
+	}
+	if cfg.MaxWaitInMilliseconds == 0 {
+		return nil, fmt.Errorf("%w: 0 maximum wait", errInvalidSlowLaneConfig)
+	}
+
+	lane.interval = time.Duration(float64(time.Second) / cfg.RatePerSecond)
+	lane.maxQueueSize = cfg.MaxQueueSizePerUser
+	lane.maxWait = time.Duration(cfg.MaxWaitInMilliseconds) * time.Millisecond
+
+	return lane, nil
+}
+
+// Wait blocks until the user's request is released. The request is rejected right away if the user's queue is full or
+// if it would wait more than the maximum wait, and stops waiting if the context is done.
+func (lane *slowLane) Wait(ctx context.Context, username string) error {
+	if !lane.enabled {
+		return errSlowLaneDisabled
+	}
+
+	lane.mut.Lock()
+	user, found := lane.lanes[username]
+	if !found {
+		user = &userLane{}
+		lane.lanes[username] = user
+	}
+
+	if user.numQueued >= lane.maxQueueSize {
+		lane.numRejected++
+		lane.mut.Unlock()
+
+		return errSlowLaneQueueFull
+	}
+
+	now := lane.getTimeHandler()
+	release := user.lastRelease.Add(lane.interval)
+	if release.Before(now) {
+		release = now
+	}
+	delay := release.Sub(now)
+	if delay > lane.maxWait {
+		lane.numRejected++
+		lane.mut.Unlock()
+
+		return fmt.Errorf("%w: %v", errSlowLaneMaxWaitExceeded, delay)
+	}
+
+	user.lastRelease = release
+	user.numQueued++
+	lane.mut.Unlock()
+
+	err := lane.waitHandler(ctx, delay)
+
+	lane.mut.Lock()
+	defer lane.mut.Unlock()
+
+	user.numQueued--
+	if err != nil {
+		if user.lastRelease.Equal(release) {
+			// no other request was queued after this one, the release slot is given back
+			user.lastRelease = release.Add(-lane.interval)
+		}
+		lane.numRejected++
+
+		return err
+	}
+
+	lane.numReleased++
+
+	return nil
+}
+
+// IsEnabled returns true if the throttled requests should wait in the slow lane
+func (lane *slowLane) IsEnabled() bool {
+	return lane.enabled
+}
+
+// Sweep removes the queues of the users without waiting requests that can already send a new one
+func (lane *slowLane) Sweep() {
+	lane.mut.Lock()
+	defer lane.mut.Unlock()
+
+	now := lane.getTimeHandler()
+	for username, user := range lane.lanes {
+		if user.numQueued == 0 && !user.lastRelease.Add(lane.interval).After(now) {
+			delete(lane.lanes, username)
+		}
+	}
+}
+
+// GetMetrics returns the number of waiting, released and rejected requests and the number of users with a queue
+func (lane *slowLane) GetMetrics() map[string]uint64 {
+	lane.mut.Lock()
+	defer lane.mut.Unlock()
+
+	numQueued := uint64(0)
+	for _, user := range lane.lanes {
+		numQueued += user.numQueued
+	}
+
+	return map[string]uint64{
+		slowLaneQueued:   numQueued,
+		slowLaneReleased: lane.numReleased,
+		slowLaneRejected: lane.numRejected,
+		slowLaneUsers:    uint64(len(lane.lanes)),
+	}
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (lane *slowLane) IsInterfaceNil() bool {
+	return lane == nil
+}
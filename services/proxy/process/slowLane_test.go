@@ -0,0 +1,193 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestSlowLane(clock *testClock) *slowLane {
+	lane, _ := NewSlowLane(config.SlowLaneConfig{
+		Enabled:               true,
+		RatePerSecond:         2,
+		MaxQueueSizePerUser:   3,
+		MaxWaitInMilliseconds: 1000,
+	})
+	lane.getTimeHandler = clock.now
+
+	return lane
+}
+
+func TestNewSlowLane(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid config should error", func(t *testing.T) {
+		t.Parallel()
+
+		invalidConfigs := map[string]config.SlowLaneConfig{
+			"0 rate":         {Enabled: true, RatePerSecond: 0, MaxQueueSizePerUser: 1, MaxWaitInMilliseconds: 1},
+			"negative rate":  {Enabled: true, RatePerSecond: -1, MaxQueueSizePerUser: 1, MaxWaitInMilliseconds: 1},
+			"0 queue size":   {Enabled: true, RatePerSecond: 1, MaxQueueSizePerUser: 0, MaxWaitInMilliseconds: 1},
+			"0 maximum wait": {Enabled: true, RatePerSecond: 1, MaxQueueSizePerUser: 1, MaxWaitInMilliseconds: 0},
+		}
+		for name, cfg := range invalidConfigs {
+			lane, err := NewSlowLane(cfg)
+			assert.Nil(t, lane, name)
+			assert.True(t, errors.Is(err, errInvalidSlowLaneConfig), name)
+		}
+	})
+	t.Run("disabled slow lane should reject all requests", func(t *testing.T) {
+		t.Parallel()
+
+		lane, err := NewSlowLane(config.SlowLaneConfig{})
+		assert.Nil(t, err)
+		assert.False(t, lane.IsInterfaceNil())
+		assert.False(t, lane.IsEnabled())
+		assert.Equal(t, errSlowLaneDisabled, lane.Wait(context.Background(), "user"))
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		lane := createTestSlowLane(newTestClock())
+		assert.True(t, lane.IsEnabled())
+		assert.Equal(t, 500*time.Millisecond, lane.interval)
+		assert.Equal(t, time.Second, lane.maxWait)
+	})
+}
+
+func TestSlowLane_Wait(t *testing.T) {
+	t.Parallel()
+
+	t.Run("requests should be released at the configured rate", func(t *testing.T) {
+		t.Parallel()
+
+		clock := newTestClock()
+		lane := createTestSlowLane(clock)
+		delays := make([]time.Duration, 0)
+		lane.waitHandler = func(ctx context.Context, duration time.Duration) error {
+			delays = append(delays, duration)
+			return nil
+		}
+
+		for i := 0; i < 3; i++ {
+			assert.Nil(t, lane.Wait(context.Background(), "user"))
+		}
+		// the third request would wait 1s, the fourth one 1.5s which is too much
+		err := lane.Wait(context.Background(), "user")
+		assert.True(t, errors.Is(err, errSlowLaneMaxWaitExceeded))
+
+		// other users have their own queues
+		assert.Nil(t, lane.Wait(context.Background(), "user2"))
+
+		clock.advance(time.Second)
+		assert.Nil(t, lane.Wait(context.Background(), "user"))
+
+		assert.Equal(t, []time.Duration{0, 500 * time.Millisecond, time.Second, 0, 500 * time.Millisecond}, delays)
+		assert.Equal(t, map[string]uint64{
+			slowLaneQueued:   0,
+			slowLaneReleased: 5,
+			slowLaneRejected: 1,
+			slowLaneUsers:    2,
+		}, lane.GetMetrics())
+	})
+	t.Run("full queue should reject the request", func(t *testing.T) {
+		t.Parallel()
+
+		clock := newTestClock()
+		lane, _ := NewSlowLane(config.SlowLaneConfig{
+			Enabled:               true,
+			RatePerSecond:         1,
+			MaxQueueSizePerUser:   2,
+			MaxWaitInMilliseconds: 60000,
+		})
+		lane.getTimeHandler = clock.now
+		release := make(chan struct{})
+		lane.waitHandler = func(ctx context.Context, duration time.Duration) error {
+			<-release
+			return nil
+		}
+
+		wg := sync.WaitGroup{}
+		wg.Add(2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				defer wg.Done()
+				assert.Nil(t, lane.Wait(context.Background(), "user"))
+			}()
+		}
+		require.Eventually(t, func() bool {
+			return lane.GetMetrics()[slowLaneQueued] == 2
+		}, 5*time.Second, time.Millisecond)
+
+		assert.Equal(t, errSlowLaneQueueFull, lane.Wait(context.Background(), "user"))
+
+		close(release)
+		wg.Wait()
+		assert.Equal(t, uint64(0), lane.GetMetrics()[slowLaneQueued])
+	})
+	t.Run("canceled request should give back its release slot", func(t *testing.T) {
+		t.Parallel()
+
+		clock := newTestClock()
+		lane := createTestSlowLane(clock)
+		delays := make([]time.Duration, 0)
+		lane.waitHandler = func(ctx context.Context, duration time.Duration) error {
+			delays = append(delays, duration)
+			return ctx.Err()
+		}
+
+		assert.Nil(t, lane.Wait(context.Background(), "user"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Equal(t, context.Canceled, lane.Wait(ctx, "user"))
+
+		assert.Nil(t, lane.Wait(context.Background(), "user"))
+		assert.Equal(t, []time.Duration{0, 500 * time.Millisecond, 500 * time.Millisecond}, delays)
+		assert.Equal(t, uint64(1), lane.GetMetrics()[slowLaneRejected])
+	})
+	t.Run("real wait should delay the request", func(t *testing.T) {
+		t.Parallel()
+
+		lane, _ := NewSlowLane(config.SlowLaneConfig{
+			Enabled:               true,
+			RatePerSecond:         20,
+			MaxQueueSizePerUser:   2,
+			MaxWaitInMilliseconds: 1000,
+		})
+
+		start := time.Now()
+		assert.Nil(t, lane.Wait(context.Background(), "user"))
+		assert.Nil(t, lane.Wait(context.Background(), "user"))
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+}
+
+func TestSlowLane_Sweep(t *testing.T) {
+	t.Parallel()
+
+	clock := newTestClock()
+	lane := createTestSlowLane(clock)
+	lane.waitHandler = func(ctx context.Context, duration time.Duration) error {
+		return nil
+	}
+
+	_ = lane.Wait(context.Background(), "user1")
+	clock.advance(400 * time.Millisecond)
+	_ = lane.Wait(context.Background(), "user2")
+
+	// user1 can send a new request right away, user2 only after 400ms
+	clock.advance(100 * time.Millisecond)
+	lane.Sweep()
+	assert.Equal(t, uint64(1), lane.GetMetrics()[slowLaneUsers])
+
+	clock.advance(400 * time.Millisecond)
+	lane.Sweep()
+	assert.Equal(t, uint64(0), lane.GetMetrics()[slowLaneUsers])
+}
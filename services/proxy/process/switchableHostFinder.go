@@ -0,0 +1,171 @@
+package process
+
+import (
+	"sync"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// finderGeneration is a host finder instance wrapped by the switchable host finder together with the number of
+// hosts it issued that were not released yet, counted by their URLs, and the number of finds still in progress on it
+type finderGeneration struct {
+	hostFinder HostFinder
+	inFlight   map[string]int64
+	finding    int64
+}
+
+func (generation *finderGeneration) isIdle() bool {
+	return len(generation.inFlight) == 0 && generation.finding == 0
+}
+
+// switchableHostFinder is a HostFinder wrapper that allows replacing the wrapped instance at runtime. Each wrapped
+// instance is a generation that keeps count of the hosts it issued, so they are released on the same instance, the
+// replaced generations being dropped once all their hosts are released.
+type switchableHostFinder struct {
+	mut         sync.Mutex
+	generation  uint64
+	generations map[uint64]*finderGeneration
+}
+
+// NewSwitchableHostFinder creates a new switchable host finder instance that initially wraps the provided host finder
+func NewSwitchableHostFinder(hostFinder HostFinder) (*switchableHostFinder, error) {
+	if check.IfNil(hostFinder) {
+		return nil, errNilHostsFinder
+	}
+
+	return &switchableHostFinder{
+		generations: map[uint64]*finderGeneration{
+			0: newFinderGeneration(hostFinder),
+		},
+	}, nil
+}
+
+func newFinderGeneration(hostFinder HostFinder) *finderGeneration {
+	return &finderGeneration{
+		hostFinder: hostFinder,
+		inFlight:   make(map[string]int64),
+	}
+}
+
+// Swap atomically replaces the wrapped host finder. The requests already in progress will finish on the old gateways.
+func (switchable *switchableHostFinder) Swap(hostFinder HostFinder) error {
+	if check.IfNil(hostFinder) {
+		return errNilHostsFinder
+	}
+
+	switchable.mut.Lock()
+	defer switchable.mut.Unlock()
+
+	if switchable.generations[switchable.generation].isIdle() {
+		delete(switchable.generations, switchable.generation)
+	}
+	switchable.generation++
+	switchable.generations[switchable.generation] = newFinderGeneration(hostFinder)
+
+	return nil
+}
+
+// FindHost calls the FindHost method of the currently wrapped host finder
+func (switchable *switchableHostFinder) FindHost(urlValues map[string][]string) (config.GatewayConfig, error) {
+	generation, hostFinder := switchable.startFind()
+	host, err := hostFinder.FindHost(urlValues)
+	switchable.endFind(generation, host, err)
+
+	return host, err
+}
+
+// FindAlternativeHost calls the FindAlternativeHost method of the currently wrapped host finder
+func (switchable *switchableHostFinder) FindAlternativeHost(urlValues map[string][]string, triedURLs []string) (config.GatewayConfig, error) {
+	generation, hostFinder := switchable.startFind()
+	host, err := hostFinder.FindAlternativeHost(urlValues, triedURLs)
+	switchable.endFind(generation, host, err)
+
+	return host, err
+}
+
+// ReleaseHost calls the ReleaseHost method of the host finder that issued the provided host, even if a swap occurred
+// in the meantime. The host finders count the hosts by their URLs, so if more generations issued the same URL, the
+// host is released on the oldest one, letting it be dropped sooner. A host that was not issued is ignored.
+func (switchable *switchableHostFinder) ReleaseHost(host config.GatewayConfig) {
+	hostFinder := switchable.release(host.URL)
+	if check.IfNil(hostFinder) {
+		return
+	}
+
+	hostFinder.ReleaseHost(host)
+}
+
+// LoadedGateways returns the gateways of the currently wrapped host finder
+func (switchable *switchableHostFinder) LoadedGateways() []config.GatewayConfig {
+	switchable.mut.Lock()
+	defer switchable.mut.Unlock()
+
+	return switchable.generations[switchable.generation].hostFinder.LoadedGateways()
+}
+
+// startFind returns the current generation and its host finder, keeping the generation until the find ends
+func (switchable *switchableHostFinder) startFind() (uint64, HostFinder) {
+	switchable.mut.Lock()
+	defer switchable.mut.Unlock()
+
+	current := switchable.generations[switchable.generation]
+	current.finding++
+
+	return switchable.generation, current.hostFinder
+}
+
+// endFind counts the host issued by the provided generation, if the find succeeded
+func (switchable *switchableHostFinder) endFind(generation uint64, host config.GatewayConfig, err error) {
+	switchable.mut.Lock()
+	defer switchable.mut.Unlock()
+
+	issuer := switchable.generations[generation]
+	issuer.finding--
+	if err == nil {
+		issuer.inFlight[host.URL]++
+	}
+
+	switchable.dropIfReplacedAndIdle(generation)
+}
+
+// release returns the host finder of the oldest generation that has a host with the provided URL to release, dropping
+// the replaced generation once its last host is released
+func (switchable *switchableHostFinder) release(url string) HostFinder {
+	switchable.mut.Lock()
+	defer switchable.mut.Unlock()
+
+	issuerGeneration := uint64(0)
+	var issuer *finderGeneration
+	for generation, candidate := range switchable.generations {
+		if candidate.inFlight[url] == 0 {
+			continue
+		}
+		if issuer == nil || generation < issuerGeneration {
+			issuerGeneration = generation
+			issuer = candidate
+		}
+	}
+	if issuer == nil {
+		return nil
+	}
+
+	issuer.inFlight[url]--
+	if issuer.inFlight[url] == 0 {
+		delete(issuer.inFlight, url)
+	}
+	switchable.dropIfReplacedAndIdle(issuerGeneration)
+
+	return issuer.hostFinder
+}
+
+func (switchable *switchableHostFinder) dropIfReplacedAndIdle(generation uint64) {
+	if generation != switchable.generation && switchable.generations[generation].isIdle() {
+		delete(switchable.generations, generation)
+	}
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (switchable *switchableHostFinder) IsInterfaceNil() bool {
+	return switchable == nil
+}
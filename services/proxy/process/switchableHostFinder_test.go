@@ -0,0 +1,211 @@
+package process
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/stretchr/testify/assert"
+)
+
+func createHostsFinderStub(name string) *testscommon.HostsFinderStub {
+	return &testscommon.HostsFinderStub{
+		FindHostCalled: func(urlValues map[string][]string) (config.GatewayConfig, error) {
+			return config.GatewayConfig{Name: name, URL: "http://" + name}, nil
+		},
+		LoadedGatewaysCalled: func() []config.GatewayConfig {
+			return []config.GatewayConfig{{Name: name}}
+		},
+	}
+}
+
+func TestNewSwitchableHostFinder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil host finder should error", func(t *testing.T) {
+		t.Parallel()
+
+		switchable, err := NewSwitchableHostFinder(nil)
+		assert.Equal(t, errNilHostsFinder, err)
+		assert.Nil(t, switchable)
+		assert.True(t, switchable.IsInterfaceNil())
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		switchable, err := NewSwitchableHostFinder(&testscommon.HostsFinderStub{})
+		assert.Nil(t, err)
+		assert.False(t, switchable.IsInterfaceNil())
+	})
+}
+
+func TestSwitchableHostFinder_Swap(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil host finder should error and keep the old instance", func(t *testing.T) {
+		t.Parallel()
+
+		switchable, _ := NewSwitchableHostFinder(createHostsFinderStub("old"))
+		err := switchable.Swap(nil)
+		assert.Equal(t, errNilHostsFinder, err)
+
+		host, _ := switchable.FindHost(nil)
+		assert.Equal(t, "old", host.Name)
+	})
+	t.Run("should delegate to the new instance", func(t *testing.T) {
+		t.Parallel()
+
+		releasedOnOld := false
+		oldFinder := createHostsFinderStub("old")
+		oldFinder.ReleaseHostCalled = func(host config.GatewayConfig) {
+			releasedOnOld = true
+		}
+		releasedOnNew := false
+		newFinder := createHostsFinderStub("new")
+		newFinder.ReleaseHostCalled = func(host config.GatewayConfig) {
+			releasedOnNew = true
+		}
+
+		switchable, _ := NewSwitchableHostFinder(oldFinder)
+		host, _ := switchable.FindHost(nil)
+		assert.Equal(t, "old", host.Name)
+		assert.Equal(t, "old", switchable.LoadedGateways()[0].Name)
+
+		err := switchable.Swap(newFinder)
+		assert.Nil(t, err)
+
+		host, _ = switchable.FindHost(nil)
+		assert.Equal(t, "new", host.Name)
+		assert.Equal(t, "new", switchable.LoadedGateways()[0].Name)
+
+		switchable.ReleaseHost(host)
+		assert.False(t, releasedOnOld)
+		assert.True(t, releasedOnNew)
+	})
+	t.Run("hosts in flight during a swap should be released on the finder that issued them", func(t *testing.T) {
+		t.Parallel()
+
+		releasedOnOld := make([]string, 0)
+		oldFinder := createHostsFinderStub("old")
+		oldFinder.FindAlternativeHostCalled = func(urlValues map[string][]string, triedURLs []string) (config.GatewayConfig, error) {
+			return config.GatewayConfig{Name: "old alternative", URL: "http://old-alternative"}, nil
+		}
+		oldFinder.ReleaseHostCalled = func(host config.GatewayConfig) {
+			releasedOnOld = append(releasedOnOld, host.Name)
+		}
+		releasedOnNew := make([]string, 0)
+		newFinder := createHostsFinderStub("new")
+		newFinder.ReleaseHostCalled = func(host config.GatewayConfig) {
+			releasedOnNew = append(releasedOnNew, host.Name)
+		}
+
+		switchable, _ := NewSwitchableHostFinder(oldFinder)
+		oldHost, _ := switchable.FindHost(nil)
+		oldAlternativeHost, _ := switchable.FindAlternativeHost(nil, nil)
+
+		err := switchable.Swap(newFinder)
+		assert.Nil(t, err)
+		newHost, _ := switchable.FindHost(nil)
+
+		switchable.ReleaseHost(newHost)
+		switchable.ReleaseHost(oldHost)
+		assert.Equal(t, []string{"old"}, releasedOnOld)
+		assert.Equal(t, []string{"new"}, releasedOnNew)
+		assert.Len(t, switchable.generations, 2)
+
+		// the old finder is dropped once its last host is released
+		switchable.ReleaseHost(oldAlternativeHost)
+		assert.Equal(t, []string{"old", "old alternative"}, releasedOnOld)
+		assert.Len(t, switchable.generations, 1)
+
+		// a host can only be released once
+		switchable.ReleaseHost(oldHost)
+		switchable.ReleaseHost(newHost)
+		assert.Len(t, releasedOnOld, 2)
+		assert.Len(t, releasedOnNew, 1)
+	})
+	t.Run("hosts with the same URL should be released on the oldest finder that issued them", func(t *testing.T) {
+		t.Parallel()
+
+		releasedOnOld := 0
+		oldFinder := createHostsFinderStub("gateway")
+		oldFinder.ReleaseHostCalled = func(host config.GatewayConfig) {
+			releasedOnOld++
+		}
+		releasedOnNew := 0
+		newFinder := createHostsFinderStub("gateway")
+		newFinder.ReleaseHostCalled = func(host config.GatewayConfig) {
+			releasedOnNew++
+		}
+
+		switchable, _ := NewSwitchableHostFinder(oldFinder)
+		oldHost, _ := switchable.FindHost(nil)
+		_ = switchable.Swap(newFinder)
+		newHost, _ := switchable.FindHost(nil)
+
+		switchable.ReleaseHost(newHost)
+		assert.Equal(t, 1, releasedOnOld)
+		assert.Equal(t, 0, releasedOnNew)
+		assert.Len(t, switchable.generations, 1)
+
+		switchable.ReleaseHost(oldHost)
+		assert.Equal(t, 1, releasedOnOld)
+		assert.Equal(t, 1, releasedOnNew)
+	})
+	t.Run("hosts that were not issued should not be released", func(t *testing.T) {
+		t.Parallel()
+
+		finder := createHostsFinderStub("gateway")
+		finder.ReleaseHostCalled = func(host config.GatewayConfig) {
+			assert.Fail(t, "should not be called")
+		}
+
+		switchable, _ := NewSwitchableHostFinder(finder)
+		_ = switchable.Swap(createHostsFinderStub("new"))
+		switchable.ReleaseHost(config.GatewayConfig{URL: "http://gateway"})
+		switchable.ReleaseHost(config.GatewayConfig{URL: "http://unknown"})
+	})
+	t.Run("failed finds should not keep the old finder", func(t *testing.T) {
+		t.Parallel()
+
+		oldFinder := createHostsFinderStub("old")
+		oldFinder.FindHostCalled = func(urlValues map[string][]string) (config.GatewayConfig, error) {
+			return config.GatewayConfig{}, errNoHealthyGateway
+		}
+
+		switchable, _ := NewSwitchableHostFinder(oldFinder)
+		_, err := switchable.FindHost(nil)
+		assert.Equal(t, errNoHealthyGateway, err)
+
+		_ = switchable.Swap(createHostsFinderStub("new"))
+		assert.Len(t, switchable.generations, 1)
+	})
+	t.Run("concurrent swaps and finds should not panic", func(t *testing.T) {
+		t.Parallel()
+
+		switchable, _ := NewSwitchableHostFinder(createHostsFinderStub("initial"))
+		numCalls := 1000
+		wg := sync.WaitGroup{}
+		wg.Add(numCalls)
+		for i := 0; i < numCalls; i++ {
+			go func(index int) {
+				defer wg.Done()
+
+				if index%10 == 0 {
+					_ = switchable.Swap(createHostsFinderStub("swapped"))
+					return
+				}
+
+				host, err := switchable.FindHost(nil)
+				assert.Nil(t, err)
+				switchable.ReleaseHost(host)
+				_ = switchable.LoadedGateways()
+			}(i)
+		}
+		wg.Wait()
+
+		// all the hosts were released, only the current finder is kept
+		assert.Len(t, switchable.generations, 1)
+	})
+}
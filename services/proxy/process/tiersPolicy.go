@@ -0,0 +1,146 @@
+package process
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+const (
+	tiersInFlight           = "in_flight_requests"
+	tiersRejectedConcurrent = "rejected_concurrent_requests"
+	tiersRejectedEndpoint   = "rejected_endpoint_requests"
+)
+
+type tierLimits struct {
+	maxConcurrentRequests uint64
+	allowedEndpoints      []string
+}
+
+type tiersPolicy struct {
+	tiers map[common.AccountType]tierLimits
+
+	mut                sync.Mutex
+	inFlight           map[string]uint64
+	numRejectedInUse   uint64
+	numRejectedForPath uint64
+}
+
+// NewTiersPolicy creates the component that applies the concurrency caps and the endpoints allow-lists of the
+// configured tiers. The account types without a tier are not restricted.
+func NewTiersPolicy(cfg []config.TierConfig) (*tiersPolicy, error) {
+	tiers := make(map[common.AccountType]tierLimits, len(cfg))
+	for _, tierConfig := range cfg {
+		name := common.AccountType(strings.TrimSpace(tierConfig.Name))
+		if len(name) == 0 {
+			return nil, fmt.Errorf("%w: empty name", errInvalidTierConfig)
+		}
+		if tierConfig.RatePerSecond < 0 || math.IsInf(tierConfig.RatePerSecond, 0) || math.IsNaN(tierConfig.RatePerSecond) {
+			return nil, fmt.Errorf("%w: invalid rate for tier %s", errInvalidTierConfig, name)
+		}
+		_, found := tiers[name]
+		if found {
+			return nil, fmt.Errorf("%w: %s", errDuplicatedTier, name)
+		}
+
+		allowedEndpoints := make([]string, 0, len(tierConfig.AllowedEndpoints))
+		for _, endpoint := range tierConfig.AllowedEndpoints {
+			endpoint = strings.Trim(strings.TrimSpace(endpoint), uriSeparator)
+			if len(endpoint) == 0 {
+				return nil, fmt.Errorf("%w: empty endpoint for tier %s", errInvalidTierConfig, name)
+			}
+
+			allowedEndpoints = append(allowedEndpoints, uriSeparator+endpoint)
+		}
+
+		tiers[name] = tierLimits{
+			maxConcurrentRequests: tierConfig.MaxConcurrentRequests,
+			allowedEndpoints:      allowedEndpoints,
+		}
+	}
+
+	return &tiersPolicy{
+		tiers:    tiers,
+		inFlight: make(map[string]uint64),
+	}, nil
+}
+
+// IsEndpointAllowed returns true if the account type can call the provided path. A tier without allowed endpoints can
+// call all of them. An allowed endpoint also allows all the paths under it.
+func (policy *tiersPolicy) IsEndpointAllowed(accountType common.AccountType, requestPath string) bool {
+	limits, found := policy.tiers[accountType]
+	if !found || len(limits.allowedEndpoints) == 0 {
+		return true
+	}
+
+	if isPathUnderEndpoints(requestPath, limits.allowedEndpoints) {
+		return true
+	}
+
+	policy.mut.Lock()
+	policy.numRejectedForPath++
+	policy.mut.Unlock()
+
+	return false
+}
+
+// AcquireRequest counts a new in-flight request of the user, returning false if the user's tier does not allow one
+// more concurrent request. Each acquired request must be released.
+func (policy *tiersPolicy) AcquireRequest(username string, accountType common.AccountType) bool {
+	policy.mut.Lock()
+	defer policy.mut.Unlock()
+
+	limits := policy.tiers[accountType]
+	numInFlight := policy.inFlight[username]
+	if limits.maxConcurrentRequests > 0 && numInFlight >= limits.maxConcurrentRequests {
+		policy.numRejectedInUse++
+		return false
+	}
+
+	policy.inFlight[username] = numInFlight + 1
+
+	return true
+}
+
+// ReleaseRequest marks one of the user's requests as done
+func (policy *tiersPolicy) ReleaseRequest(username string) {
+	policy.mut.Lock()
+	defer policy.mut.Unlock()
+
+	numInFlight, found := policy.inFlight[username]
+	if !found {
+		return
+	}
+	if numInFlight <= 1 {
+		delete(policy.inFlight, username)
+		return
+	}
+
+	policy.inFlight[username] = numInFlight - 1
+}
+
+// GetMetrics returns the number of in-flight requests and the number of requests rejected by the tiers limits
+func (policy *tiersPolicy) GetMetrics() map[string]uint64 {
+	policy.mut.Lock()
+	defer policy.mut.Unlock()
+
+	numInFlight := uint64(0)
+	for _, value := range policy.inFlight {
+		numInFlight += value
+	}
+
+	return map[string]uint64{
+		tiersInFlight:           numInFlight,
+		tiersRejectedConcurrent: policy.numRejectedInUse,
+		tiersRejectedEndpoint:   policy.numRejectedForPath,
+	}
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (policy *tiersPolicy) IsInterfaceNil() bool {
+	return policy == nil
+}
@@ -0,0 +1,102 @@
+package process
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTiersPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid tiers should error", func(t *testing.T) {
+		t.Parallel()
+
+		invalidTiers := map[string]config.TierConfig{
+			"empty name":     {Name: " "},
+			"negative rate":  {Name: "gold", RatePerSecond: -1},
+			"empty endpoint": {Name: "gold", AllowedEndpoints: []string{"/address", " / "}},
+		}
+		for name, tier := range invalidTiers {
+			policy, err := NewTiersPolicy([]config.TierConfig{tier})
+			assert.Nil(t, policy, name)
+			assert.True(t, errors.Is(err, errInvalidTierConfig), name)
+		}
+	})
+	t.Run("duplicated tier should error", func(t *testing.T) {
+		t.Parallel()
+
+		policy, err := NewTiersPolicy([]config.TierConfig{{Name: "gold"}, {Name: " gold"}})
+		assert.Nil(t, policy)
+		assert.True(t, errors.Is(err, errDuplicatedTier))
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		policy, err := NewTiersPolicy(nil)
+		assert.Nil(t, err)
+		assert.False(t, policy.IsInterfaceNil())
+	})
+}
+
+func TestTiersPolicy_IsEndpointAllowed(t *testing.T) {
+	t.Parallel()
+
+	policy, _ := NewTiersPolicy([]config.TierConfig{
+		{Name: "gold"},
+		{Name: "silver", AllowedEndpoints: []string{"/address/", "network/config"}},
+	})
+
+	assert.True(t, policy.IsEndpointAllowed(common.PremiumAccountType, "/transaction/aa"))
+	assert.True(t, policy.IsEndpointAllowed("gold", "/transaction/aa"))
+
+	assert.True(t, policy.IsEndpointAllowed("silver", "/address"))
+	assert.True(t, policy.IsEndpointAllowed("silver", "/address/erd1/balance"))
+	assert.True(t, policy.IsEndpointAllowed("silver", "/network/config"))
+	assert.False(t, policy.IsEndpointAllowed("silver", "/addresses"))
+	assert.False(t, policy.IsEndpointAllowed("silver", "/network/status/0"))
+	assert.False(t, policy.IsEndpointAllowed("silver", "/transaction/aa"))
+
+	assert.Equal(t, uint64(3), policy.GetMetrics()[tiersRejectedEndpoint])
+}
+
+func TestTiersPolicy_AcquireRequest(t *testing.T) {
+	t.Parallel()
+
+	policy, _ := NewTiersPolicy([]config.TierConfig{
+		{Name: "gold", MaxConcurrentRequests: 2},
+	})
+
+	assert.True(t, policy.AcquireRequest("user1", "gold"))
+	assert.True(t, policy.AcquireRequest("user1", "gold"))
+	assert.False(t, policy.AcquireRequest("user1", "gold"))
+
+	// other users have their own counters and the accounts without a cap are not limited
+	assert.True(t, policy.AcquireRequest("user2", "gold"))
+	for i := 0; i < 10; i++ {
+		assert.True(t, policy.AcquireRequest("user3", common.PremiumAccountType))
+	}
+
+	expectedMetrics := map[string]uint64{
+		tiersInFlight:           13,
+		tiersRejectedConcurrent: 1,
+		tiersRejectedEndpoint:   0,
+	}
+	assert.Equal(t, expectedMetrics, policy.GetMetrics())
+
+	policy.ReleaseRequest("user1")
+	assert.True(t, policy.AcquireRequest("user1", "gold"))
+
+	policy.ReleaseRequest("user1")
+	policy.ReleaseRequest("user1")
+	policy.ReleaseRequest("user1")
+	policy.ReleaseRequest("user2")
+	for i := 0; i < 10; i++ {
+		policy.ReleaseRequest("user3")
+	}
+	assert.Equal(t, uint64(0), policy.GetMetrics()[tiersInFlight])
+	assert.Empty(t, policy.inFlight)
+}
@@ -0,0 +1,310 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/multiversx/mx-chain-core-go/core/check"
+)
+
+// timestampLayouts defines the accepted date & time formats, besides the unix timestamp in seconds. The values without
+// a time zone are considered UTC.
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+type networkStatusResponse struct {
+	Data struct {
+		Status struct {
+			EpochNumber uint64 `json:"erd_epoch_number"`
+			Nonce       uint64 `json:"erd_nonce"`
+		} `json:"status"`
+	} `json:"data"`
+}
+
+type epochStartResponse struct {
+	Data struct {
+		EpochStart struct {
+			Nonce     uint64 `json:"nonce"`
+			Timestamp int64  `json:"timestamp"`
+		} `json:"epochStart"`
+	} `json:"data"`
+}
+
+type chainPoint struct {
+	nonce     uint64
+	timestamp int64
+}
+
+// ArgsTimestampResolver is the DTO used to create a new timestamp resolver
+type ArgsTimestampResolver struct {
+	Enabled    bool
+	ShardID    uint32
+	HostFinder HostFinder
+	Storer     EpochStartsStorer
+	Requester  GatewayRequester
+}
+
+type timestampResolver struct {
+	enabled        bool
+	shardID        uint32
+	hostFinder     HostFinder
+	storer         EpochStartsStorer
+	requester      GatewayRequester
+	getTimeHandler func() time.Time
+
+	mut         sync.RWMutex
+	epochStarts map[string]map[uint64]common.EpochStartInfo
+	latestPoint *chainPoint
+}
+
+// NewTimestampResolver creates a new timestamp resolver able to convert a date & time into a block nonce. The
+// resolver uses a per-gateway table of epoch start timestamps, learned by probing the gateways and cached in storage.
+func NewTimestampResolver(args ArgsTimestampResolver) (*timestampResolver, error) {
+	if check.IfNil(args.HostFinder) {
+		return nil, errNilHostsFinder
+	}
+	if check.IfNil(args.Storer) {
+		return nil, errNilEpochStartsStorer
+	}
+	if check.IfNil(args.Requester) {
+		return nil, errNilGatewayRequester
+	}
+
+	resolver := &timestampResolver{
+		enabled:        args.Enabled,
+		shardID:        args.ShardID,
+		hostFinder:     args.HostFinder,
+		storer:         args.Storer,
+		requester:      args.Requester,
+		getTimeHandler: time.Now,
+		epochStarts:    make(map[string]map[uint64]common.EpochStartInfo),
+	}
+
+	if !args.Enabled {
+		return resolver, nil
+	}
+
+	cachedEpochStarts, err := args.Storer.GetEpochStarts(args.ShardID)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range cachedEpochStarts {
+		resolver.addEpochStart(info)
+	}
+
+	return resolver, nil
+}
+
+// LearnEpochStarts probes all loaded gateways for the epoch starts within their ranges that are not yet known.
+// The latest gateway is also asked for its current nonce, used to resolve the timestamps in the current epoch.
+func (resolver *timestampResolver) LearnEpochStarts() {
+	if !resolver.enabled {
+		return
+	}
+
+	for _, gateway := range resolver.hostFinder.LoadedGateways() {
+		resolver.learnGatewayEpochStarts(gateway)
+	}
+}
+
+func (resolver *timestampResolver) learnGatewayEpochStarts(gateway config.GatewayConfig) {
+	epochStart, err := strconv.ParseUint(gateway.EpochStart, 10, 64)
+	if err != nil {
+		log.Warn("can not learn the epoch starts, invalid epoch start", "gateway", gateway.Name, "error", err)
+		return
+	}
+
+	epochEnd, err := resolver.getGatewayEpochEnd(gateway)
+	if err != nil {
+		log.Warn("can not learn the epoch starts, unknown epoch end", "gateway", gateway.Name, "error", err)
+		return
+	}
+
+	for epoch := epochStart; epoch <= epochEnd; epoch++ {
+		if resolver.isEpochStartKnown(gateway.Name, epoch) {
+			continue
+		}
+
+		response := &epochStartResponse{}
+		route := fmt.Sprintf("/network/epoch-start/%d/by-epoch/%d", resolver.shardID, epoch)
+		err = resolver.requester.DoGatewayRequest(context.Background(), gateway, route, response)
+		if err != nil {
+			log.Debug("can not fetch the epoch start", "gateway", gateway.Name, "epoch", epoch, "error", err)
+			continue
+		}
+
+		info := common.EpochStartInfo{
+			Gateway:   gateway.Name,
+			Shard:     resolver.shardID,
+			Epoch:     epoch,
+			Nonce:     response.Data.EpochStart.Nonce,
+			Timestamp: response.Data.EpochStart.Timestamp,
+		}
+		err = resolver.storer.SaveEpochStart(info)
+		if err != nil {
+			log.Warn("can not save the epoch start", "gateway", gateway.Name, "epoch", epoch, "error", err)
+		}
+
+		resolver.addEpochStart(info)
+	}
+}
+
+// getGatewayEpochEnd returns the last epoch served by the gateway. For the latest gateway, the current epoch is
+// fetched from the network status and the current nonce is recorded as the latest known point of the chain.
+func (resolver *timestampResolver) getGatewayEpochEnd(gateway config.GatewayConfig) (uint64, error) {
+	if gateway.EpochEnd != latestMarker {
+		return strconv.ParseUint(gateway.EpochEnd, 10, 64)
+	}
+
+	response := &networkStatusResponse{}
+	route := fmt.Sprintf("/network/status/%d", resolver.shardID)
+	err := resolver.requester.DoGatewayRequest(context.Background(), gateway, route, response)
+	if err != nil {
+		return 0, err
+	}
+
+	resolver.mut.Lock()
+	resolver.latestPoint = &chainPoint{
+		nonce:     response.Data.Status.Nonce,
+		timestamp: resolver.getTimeHandler().Unix(),
+	}
+	resolver.mut.Unlock()
+
+	return response.Data.Status.EpochNumber, nil
+}
+
+func (resolver *timestampResolver) isEpochStartKnown(gateway string, epoch uint64) bool {
+	resolver.mut.RLock()
+	defer resolver.mut.RUnlock()
+
+	_, found := resolver.epochStarts[gateway][epoch]
+
+	return found
+}
+
+func (resolver *timestampResolver) addEpochStart(info common.EpochStartInfo) {
+	resolver.mut.Lock()
+	defer resolver.mut.Unlock()
+
+	gatewayEpochStarts, found := resolver.epochStarts[info.Gateway]
+	if !found {
+		gatewayEpochStarts = make(map[uint64]common.EpochStartInfo)
+		resolver.epochStarts[info.Gateway] = gatewayEpochStarts
+	}
+	gatewayEpochStarts[info.Epoch] = info
+}
+
+// ResolveNonce returns the block nonce produced at the provided date & time. The nonce is interpolated between the
+// two surrounding known points of the chain. Returns false if the timestamp is after the latest known point, in
+// which case the request should be served with the latest data.
+func (resolver *timestampResolver) ResolveNonce(timestamp string) (uint64, bool, error) {
+	if !resolver.enabled {
+		return 0, false, nil
+	}
+
+	unixTimestamp, err := parseTimestamp(timestamp)
+	if err != nil {
+		return 0, false, err
+	}
+
+	points := resolver.getChainPoints()
+	if len(points) == 0 {
+		return 0, false, errTimestampRoutingNotReady
+	}
+	if unixTimestamp < points[0].timestamp {
+		return 0, false, fmt.Errorf("%w, the first known epoch start is at %d", errTimestampOutOfRange, points[0].timestamp)
+	}
+
+	index := sort.Search(len(points), func(i int) bool {
+		return points[i].timestamp > unixTimestamp
+	}) - 1
+	if index == len(points)-1 {
+		return 0, false, nil
+	}
+
+	return interpolateNonce(points[index], points[index+1], unixTimestamp), true, nil
+}
+
+// getChainPoints returns the known epoch starts, from all gateways, ordered by epoch, followed by the latest point
+func (resolver *timestampResolver) getChainPoints() []chainPoint {
+	resolver.mut.RLock()
+	defer resolver.mut.RUnlock()
+
+	epochStarts := make(map[uint64]common.EpochStartInfo)
+	for _, gatewayEpochStarts := range resolver.epochStarts {
+		for epoch, info := range gatewayEpochStarts {
+			epochStarts[epoch] = info
+		}
+	}
+
+	epochs := make([]uint64, 0, len(epochStarts))
+	for epoch := range epochStarts {
+		epochs = append(epochs, epoch)
+	}
+	sort.Slice(epochs, func(i, j int) bool {
+		return epochs[i] < epochs[j]
+	})
+
+	points := make([]chainPoint, 0, len(epochs)+1)
+	for _, epoch := range epochs {
+		info := epochStarts[epoch]
+		points = append(points, chainPoint{
+			nonce:     info.Nonce,
+			timestamp: info.Timestamp,
+		})
+	}
+
+	if resolver.latestPoint == nil {
+		return points
+	}
+	if len(points) == 0 || resolver.latestPoint.timestamp > points[len(points)-1].timestamp {
+		points = append(points, *resolver.latestPoint)
+	}
+
+	return points
+}
+
+func interpolateNonce(from chainPoint, to chainPoint, timestamp int64) uint64 {
+	if to.timestamp <= from.timestamp || to.nonce <= from.nonce {
+		return from.nonce
+	}
+
+	elapsed := uint64(timestamp - from.timestamp)
+	duration := uint64(to.timestamp - from.timestamp)
+
+	return from.nonce + (to.nonce-from.nonce)*elapsed/duration
+}
+
+// parseTimestamp accepts a unix timestamp in seconds or one of the timestampLayouts formats
+func parseTimestamp(timestamp string) (int64, error) {
+	unixTimestamp, err := strconv.ParseInt(timestamp, 10, 64)
+	if err == nil {
+		return unixTimestamp, nil
+	}
+
+	for _, layout := range timestampLayouts {
+		parsed, errParse := time.Parse(layout, timestamp)
+		if errParse == nil {
+			return parsed.Unix(), nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %s", errInvalidTimestamp, timestamp)
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (resolver *timestampResolver) IsInterfaceNil() bool {
+	return resolver == nil
+}
@@ -0,0 +1,384 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/multiversx/mx-chain-core-go/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createMockArgsTimestampResolver() ArgsTimestampResolver {
+	return ArgsTimestampResolver{
+		Enabled:    true,
+		ShardID:    core.MetachainShardId,
+		HostFinder: &testscommon.HostsFinderStub{},
+		Storer:     &testscommon.StorerStub{},
+		Requester:  &testscommon.GatewayRequesterStub{},
+	}
+}
+
+func createEpochStarts(gateway string, epochs ...uint64) []common.EpochStartInfo {
+	infos := make([]common.EpochStartInfo, 0, len(epochs))
+	for _, epoch := range epochs {
+		infos = append(infos, common.EpochStartInfo{
+			Gateway:   gateway,
+			Shard:     core.MetachainShardId,
+			Epoch:     epoch,
+			Nonce:     epoch * 1000,
+			Timestamp: 1700000000 + int64(epoch)*6000,
+		})
+	}
+
+	return infos
+}
+
+func TestNewTimestampResolver(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil host finder should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsTimestampResolver()
+		args.HostFinder = nil
+		resolver, err := NewTimestampResolver(args)
+		assert.Nil(t, resolver)
+		assert.True(t, resolver.IsInterfaceNil())
+		assert.Equal(t, errNilHostsFinder, err)
+	})
+	t.Run("nil storer should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsTimestampResolver()
+		args.Storer = nil
+		resolver, err := NewTimestampResolver(args)
+		assert.Nil(t, resolver)
+		assert.Equal(t, errNilEpochStartsStorer, err)
+	})
+	t.Run("nil requester should error", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsTimestampResolver()
+		args.Requester = nil
+		resolver, err := NewTimestampResolver(args)
+		assert.Nil(t, resolver)
+		assert.Equal(t, errNilGatewayRequester, err)
+	})
+	t.Run("storer errors should error", func(t *testing.T) {
+		t.Parallel()
+
+		expectedErr := errors.New("expected error")
+		args := createMockArgsTimestampResolver()
+		args.Storer = &testscommon.StorerStub{
+			GetEpochStartsHandler: func(shard uint32) ([]common.EpochStartInfo, error) {
+				return nil, expectedErr
+			},
+		}
+		resolver, err := NewTimestampResolver(args)
+		assert.Nil(t, resolver)
+		assert.Equal(t, expectedErr, err)
+	})
+	t.Run("disabled resolver should not read the storage", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsTimestampResolver()
+		args.Enabled = false
+		args.Storer = &testscommon.StorerStub{
+			GetEpochStartsHandler: func(shard uint32) ([]common.EpochStartInfo, error) {
+				require.Fail(t, "should have not read the storage")
+				return nil, nil
+			},
+		}
+		resolver, err := NewTimestampResolver(args)
+		assert.NotNil(t, resolver)
+		assert.Nil(t, err)
+	})
+	t.Run("should work and load the cached epoch starts", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsTimestampResolver()
+		args.Storer = &testscommon.StorerStub{
+			GetEpochStartsHandler: func(shard uint32) ([]common.EpochStartInfo, error) {
+				assert.Equal(t, core.MetachainShardId, shard)
+				return createEpochStarts("gw", 1, 2), nil
+			},
+		}
+		resolver, err := NewTimestampResolver(args)
+		assert.NotNil(t, resolver)
+		assert.False(t, resolver.IsInterfaceNil())
+		assert.Nil(t, err)
+		assert.True(t, resolver.isEpochStartKnown("gw", 1))
+		assert.True(t, resolver.isEpochStartKnown("gw", 2))
+		assert.False(t, resolver.isEpochStartKnown("gw", 3))
+		assert.False(t, resolver.isEpochStartKnown("other gw", 1))
+	})
+}
+
+func TestTimestampResolver_LearnEpochStarts(t *testing.T) {
+	t.Parallel()
+
+	t.Run("disabled resolver should not probe", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsTimestampResolver()
+		args.Enabled = false
+		args.HostFinder = &testscommon.HostsFinderStub{
+			LoadedGatewaysCalled: func() []config.GatewayConfig {
+				require.Fail(t, "should have not fetched the gateways")
+				return nil
+			},
+		}
+		resolver, _ := NewTimestampResolver(args)
+		resolver.LearnEpochStarts()
+	})
+	t.Run("should probe the unknown epoch starts of all gateways", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsTimestampResolver()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			LoadedGatewaysCalled: func() []config.GatewayConfig {
+				return []config.GatewayConfig{
+					{URL: "http://invalid", EpochStart: "x", EpochEnd: "1", Name: "invalid"},
+					{URL: "http://old", EpochStart: "0", EpochEnd: "2", Name: "old"},
+					{URL: "http://latest", EpochStart: "3", EpochEnd: "latest", Name: "latest"},
+				}
+			},
+		}
+		savedInfos := make([]common.EpochStartInfo, 0)
+		args.Storer = &testscommon.StorerStub{
+			GetEpochStartsHandler: func(shard uint32) ([]common.EpochStartInfo, error) {
+				return createEpochStarts("old", 1), nil
+			},
+			SaveEpochStartHandler: func(info common.EpochStartInfo) error {
+				savedInfos = append(savedInfos, info)
+				return nil
+			},
+		}
+		queriedURLs := make([]string, 0)
+		args.Requester = &testscommon.GatewayRequesterStub{
+			DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+				url := gateway.URL + route
+				queriedURLs = append(queriedURLs, url)
+				switch response := result.(type) {
+				case *networkStatusResponse:
+					response.Data.Status.EpochNumber = 4
+					response.Data.Status.Nonce = 4500
+				case *epochStartResponse:
+					if strings.HasSuffix(url, "/by-epoch/0") {
+						return errors.New("genesis epoch start not available")
+					}
+
+					var epoch uint64
+					_, _ = fmt.Sscanf(url[strings.LastIndex(url, "/")+1:], "%d", &epoch)
+					info := createEpochStarts("", epoch)[0]
+					response.Data.EpochStart.Nonce = info.Nonce
+					response.Data.EpochStart.Timestamp = info.Timestamp
+				}
+
+				return nil
+			},
+		}
+		resolver, _ := NewTimestampResolver(args)
+		resolver.getTimeHandler = func() time.Time {
+			return time.Unix(1700027000, 0)
+		}
+
+		resolver.LearnEpochStarts()
+
+		expectedURLs := []string{
+			"http://old/network/epoch-start/4294967295/by-epoch/0",
+			"http://old/network/epoch-start/4294967295/by-epoch/2",
+			"http://latest/network/status/4294967295",
+			"http://latest/network/epoch-start/4294967295/by-epoch/3",
+			"http://latest/network/epoch-start/4294967295/by-epoch/4",
+		}
+		assert.Equal(t, expectedURLs, queriedURLs)
+
+		expectedSaved := append(createEpochStarts("old", 2), createEpochStarts("latest", 3, 4)...)
+		assert.Equal(t, expectedSaved, savedInfos)
+		assert.Equal(t, &chainPoint{nonce: 4500, timestamp: 1700027000}, resolver.latestPoint)
+
+		// the known epoch starts should not be probed again
+		queriedURLs = make([]string, 0)
+		resolver.LearnEpochStarts()
+		expectedURLs = []string{
+			"http://old/network/epoch-start/4294967295/by-epoch/0",
+			"http://latest/network/status/4294967295",
+		}
+		assert.Equal(t, expectedURLs, queriedURLs)
+	})
+	t.Run("network status errors should not probe the latest gateway", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsTimestampResolver()
+		args.HostFinder = &testscommon.HostsFinderStub{
+			LoadedGatewaysCalled: func() []config.GatewayConfig {
+				return []config.GatewayConfig{
+					{URL: "http://latest", EpochStart: "0", EpochEnd: "latest", Name: "latest"},
+				}
+			},
+		}
+		numRequests := 0
+		args.Requester = &testscommon.GatewayRequesterStub{
+			DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+				numRequests++
+				return errors.New("gateway down")
+			},
+		}
+		resolver, _ := NewTimestampResolver(args)
+
+		resolver.LearnEpochStarts()
+		assert.Equal(t, 1, numRequests)
+		assert.Nil(t, resolver.latestPoint)
+	})
+}
+
+func TestTimestampResolver_ResolveNonce(t *testing.T) {
+	t.Parallel()
+
+	createResolver := func(epochs ...uint64) *timestampResolver {
+		args := createMockArgsTimestampResolver()
+		args.Storer = &testscommon.StorerStub{
+			GetEpochStartsHandler: func(shard uint32) ([]common.EpochStartInfo, error) {
+				return createEpochStarts("gw", epochs...), nil
+			},
+		}
+		resolver, _ := NewTimestampResolver(args)
+
+		return resolver
+	}
+
+	t.Run("disabled resolver should not resolve", func(t *testing.T) {
+		t.Parallel()
+
+		args := createMockArgsTimestampResolver()
+		args.Enabled = false
+		resolver, _ := NewTimestampResolver(args)
+
+		nonce, found, err := resolver.ResolveNonce("1700000000")
+		assert.Nil(t, err)
+		assert.False(t, found)
+		assert.Zero(t, nonce)
+	})
+	t.Run("invalid timestamp should error", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := createResolver(1, 2)
+		_, _, err := resolver.ResolveNonce("yesterday")
+		assert.True(t, errors.Is(err, errInvalidTimestamp))
+	})
+	t.Run("no epoch starts learned should error", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := createResolver()
+		_, _, err := resolver.ResolveNonce("1700000000")
+		assert.Equal(t, errTimestampRoutingNotReady, err)
+	})
+	t.Run("timestamp before the first epoch start should error", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := createResolver(1, 2)
+		_, _, err := resolver.ResolveNonce("1700005999")
+		assert.True(t, errors.Is(err, errTimestampOutOfRange))
+		assert.Contains(t, err.Error(), "1700006000")
+	})
+	t.Run("should interpolate between the epoch starts", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := createResolver(1, 2, 4, 5)
+		testCases := map[string]uint64{
+			"1700006000":           1000,
+			"1700009000":           1500,
+			"1700011999":           1999,
+			"1700012000":           2000,
+			"1700018000":           3000, // epoch 3 is missing, interpolated between epochs 2 and 4
+			"2023-11-15T03:13:20Z": 3000,
+			"2023-11-15 03:13:20":  3000,
+			"2023-11-15T03:13":     2996,
+		}
+		for timestamp, expectedNonce := range testCases {
+			nonce, found, err := resolver.ResolveNonce(timestamp)
+			assert.Nil(t, err, timestamp)
+			assert.True(t, found, timestamp)
+			assert.Equal(t, expectedNonce, nonce, timestamp)
+		}
+	})
+	t.Run("timestamp after the latest known point should not resolve", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := createResolver(1, 2)
+		nonce, found, err := resolver.ResolveNonce("1700012000")
+		assert.Nil(t, err)
+		assert.False(t, found)
+		assert.Zero(t, nonce)
+	})
+	t.Run("should use the latest point for the current epoch", func(t *testing.T) {
+		t.Parallel()
+
+		resolver := createResolver(1, 2)
+		resolver.latestPoint = &chainPoint{nonce: 2600, timestamp: 1700015000}
+
+		nonce, found, err := resolver.ResolveNonce("1700013500")
+		assert.Nil(t, err)
+		assert.True(t, found)
+		assert.Equal(t, uint64(2300), nonce)
+
+		nonce, found, err = resolver.ResolveNonce("1700015000")
+		assert.Nil(t, err)
+		assert.False(t, found)
+		assert.Zero(t, nonce)
+	})
+}
+
+func TestTimestampResolver_ConcurrentOperations(t *testing.T) {
+	t.Parallel()
+
+	args := createMockArgsTimestampResolver()
+	args.HostFinder = &testscommon.HostsFinderStub{
+		LoadedGatewaysCalled: func() []config.GatewayConfig {
+			return []config.GatewayConfig{
+				{URL: "http://latest", EpochStart: "0", EpochEnd: "latest", Name: "latest"},
+			}
+		},
+	}
+	args.Requester = &testscommon.GatewayRequesterStub{
+		DoGatewayRequestHandler: func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+			switch response := result.(type) {
+			case *networkStatusResponse:
+				response.Data.Status.EpochNumber = 3
+				response.Data.Status.Nonce = 3500
+			case *epochStartResponse:
+				response.Data.EpochStart.Nonce = 1000
+				response.Data.EpochStart.Timestamp = 1700000000
+			}
+
+			return nil
+		},
+	}
+	resolver, _ := NewTimestampResolver(args)
+
+	numCalls := 100
+	wg := sync.WaitGroup{}
+	wg.Add(numCalls)
+	for i := 0; i < numCalls; i++ {
+		go func(index int) {
+			defer wg.Done()
+
+			if index%2 == 0 {
+				resolver.LearnEpochStarts()
+				return
+			}
+			_, _, _ = resolver.ResolveNonce("1700000000")
+		}(i)
+	}
+	wg.Wait()
+}
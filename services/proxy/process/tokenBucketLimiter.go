@@ -0,0 +1,149 @@
+package process
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+const bucketKeySeparator = "/"
+
+type bucketLimit struct {
+	ratePerSecond float64
+	burst         float64
+}
+
+type tokenBucket struct {
+	limit      bucketLimit
+	tokens     float64
+	lastRefill time.Time
+}
+
+type tokenBucketLimiter struct {
+	limits         map[common.AccountType]bucketLimit
+	getTimeHandler func() time.Time
+
+	mut     sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewTokenBucketLimiter creates a rate limiter that keeps a token bucket for each account. The bucket's rate and
+// burst are set by the account type, the account types without a configured bucket are not limited.
+func NewTokenBucketLimiter(cfg []config.TokenBucketConfig) (*tokenBucketLimiter, error) {
+	limits := make(map[common.AccountType]bucketLimit, len(cfg))
+	for _, bucketConfig := range cfg {
+		accountType := common.AccountType(strings.TrimSpace(bucketConfig.AccountType))
+		if len(accountType) == 0 {
+			return nil, fmt.Errorf("%w: empty account type", errInvalidTokenBucket)
+		}
+		if bucketConfig.RatePerSecond <= 0 || math.IsInf(bucketConfig.RatePerSecond, 0) || math.IsNaN(bucketConfig.RatePerSecond) {
+			return nil, fmt.Errorf("%w: invalid rate for account type %s", errInvalidTokenBucket, accountType)
+		}
+		if bucketConfig.Burst == 0 {
+			return nil, fmt.Errorf("%w: 0 burst for account type %s", errInvalidTokenBucket, accountType)
+		}
+
+		_, found := limits[accountType]
+		if found {
+			return nil, fmt.Errorf("%w: %s", errDuplicatedAccountType, accountType)
+		}
+
+		limits[accountType] = bucketLimit{
+			ratePerSecond: bucketConfig.RatePerSecond,
+			burst:         float64(bucketConfig.Burst),
+		}
+	}
+
+	return &tokenBucketLimiter{
+		limits:         limits,
+		getTimeHandler: time.Now,
+		buckets:        make(map[string]*tokenBucket),
+	}, nil
+}
+
+// Allow consumes a token from the account's bucket. A request finding the bucket empty is rejected and the result
+// holds the time after which the next token is available.
+func (limiter *tokenBucketLimiter) Allow(username string, accountType common.AccountType) common.RateLimitResult {
+	limit, found := limiter.limits[accountType]
+	if !found {
+		return common.RateLimitResult{
+			Allowed: true,
+		}
+	}
+
+	limiter.mut.Lock()
+	defer limiter.mut.Unlock()
+
+	now := limiter.getTimeHandler()
+	key := string(accountType) + bucketKeySeparator + username
+	bucket, found := limiter.buckets[key]
+	if !found {
+		bucket = &tokenBucket{
+			limit:      limit,
+			tokens:     limit.burst,
+			lastRefill: now,
+		}
+		limiter.buckets[key] = bucket
+	}
+	bucket.refill(now)
+
+	result := common.RateLimitResult{
+		Limit: uint64(limit.burst),
+	}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+		result.Remaining = uint64(math.Floor(bucket.tokens))
+	} else {
+		result.RetryAfter = bucket.timeToRefill(1 - bucket.tokens)
+	}
+	result.ResetAfter = bucket.timeToRefill(limit.burst - bucket.tokens)
+
+	return result
+}
+
+func (bucket *tokenBucket) timeToRefill(missingTokens float64) time.Duration {
+	return time.Duration(math.Ceil(missingTokens / bucket.limit.ratePerSecond * float64(time.Second)))
+}
+
+func (bucket *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(bucket.lastRefill)
+	if elapsed <= 0 {
+		return
+	}
+
+	bucket.tokens = math.Min(bucket.limit.burst, bucket.tokens+elapsed.Seconds()*bucket.limit.ratePerSecond)
+	bucket.lastRefill = now
+}
+
+// Sweep removes the full buckets as they are equivalent to the ones created for the accounts seen for the first time
+func (limiter *tokenBucketLimiter) Sweep() {
+	limiter.mut.Lock()
+	defer limiter.mut.Unlock()
+
+	now := limiter.getTimeHandler()
+	for key, bucket := range limiter.buckets {
+		bucket.refill(now)
+		if bucket.tokens >= bucket.limit.burst {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+// numBuckets returns how many buckets are kept in memory
+func (limiter *tokenBucketLimiter) numBuckets() int {
+	limiter.mut.Lock()
+	defer limiter.mut.Unlock()
+
+	return len(limiter.buckets)
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (limiter *tokenBucketLimiter) IsInterfaceNil() bool {
+	return limiter == nil
+}
@@ -0,0 +1,243 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type testClock struct {
+	mut         sync.Mutex
+	currentTime time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{
+		currentTime: time.Unix(1700000000, 0),
+	}
+}
+
+func (clock *testClock) now() time.Time {
+	clock.mut.Lock()
+	defer clock.mut.Unlock()
+
+	return clock.currentTime
+}
+
+func (clock *testClock) advance(duration time.Duration) {
+	clock.mut.Lock()
+	clock.currentTime = clock.currentTime.Add(duration)
+	clock.mut.Unlock()
+}
+
+func createTestTokenBucketLimiter(clock *testClock) *tokenBucketLimiter {
+	limiter, _ := NewTokenBucketLimiter([]config.TokenBucketConfig{
+		{
+			AccountType:   string(common.FreeAccountType),
+			RatePerSecond: 2,
+			Burst:         3,
+		},
+		{
+			AccountType:   string(common.PremiumAccountType),
+			RatePerSecond: 100,
+			Burst:         200,
+		},
+	})
+	limiter.getTimeHandler = clock.now
+
+	return limiter
+}
+
+func TestNewTokenBucketLimiter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid buckets should error", func(t *testing.T) {
+		t.Parallel()
+
+		invalidBuckets := map[string]config.TokenBucketConfig{
+			"empty account type": {AccountType: " ", RatePerSecond: 1, Burst: 1},
+			"0 rate":             {AccountType: "free", RatePerSecond: 0, Burst: 1},
+			"negative rate":      {AccountType: "free", RatePerSecond: -1, Burst: 1},
+			"0 burst":            {AccountType: "free", RatePerSecond: 1, Burst: 0},
+		}
+		for name, bucket := range invalidBuckets {
+			limiter, err := NewTokenBucketLimiter([]config.TokenBucketConfig{bucket})
+			assert.Nil(t, limiter, name)
+			assert.True(t, errors.Is(err, errInvalidTokenBucket), name)
+		}
+	})
+	t.Run("duplicated account type should error", func(t *testing.T) {
+		t.Parallel()
+
+		limiter, err := NewTokenBucketLimiter([]config.TokenBucketConfig{
+			{AccountType: "free", RatePerSecond: 1, Burst: 1},
+			{AccountType: "free", RatePerSecond: 2, Burst: 2},
+		})
+		assert.Nil(t, limiter)
+		assert.True(t, errors.Is(err, errDuplicatedAccountType))
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		limiter, err := NewTokenBucketLimiter(nil)
+		assert.Nil(t, err)
+		assert.False(t, limiter.IsInterfaceNil())
+	})
+}
+
+func TestTokenBucketLimiter_Allow(t *testing.T) {
+	t.Parallel()
+
+	t.Run("account type without a bucket should not be limited", func(t *testing.T) {
+		t.Parallel()
+
+		limiter, _ := NewTokenBucketLimiter([]config.TokenBucketConfig{
+			{AccountType: "free", RatePerSecond: 1, Burst: 1},
+		})
+		for i := 0; i < 100; i++ {
+			result := limiter.Allow("user", common.PremiumAccountType)
+			assert.Equal(t, common.RateLimitResult{Allowed: true}, result)
+		}
+		assert.Equal(t, 0, limiter.numBuckets())
+	})
+	t.Run("should allow the burst then reject until a token is refilled", func(t *testing.T) {
+		t.Parallel()
+
+		clock := newTestClock()
+		limiter := createTestTokenBucketLimiter(clock)
+
+		for i := 0; i < 3; i++ {
+			result := limiter.Allow("user", common.FreeAccountType)
+			assert.Equal(t, common.RateLimitResult{
+				Allowed:    true,
+				Limit:      3,
+				Remaining:  uint64(2 - i),
+				ResetAfter: time.Duration(i+1) * 500 * time.Millisecond,
+			}, result)
+		}
+
+		result := limiter.Allow("user", common.FreeAccountType)
+		assert.Equal(t, common.RateLimitResult{
+			Allowed:    false,
+			Limit:      3,
+			Remaining:  0,
+			ResetAfter: 1500 * time.Millisecond,
+			RetryAfter: 500 * time.Millisecond,
+		}, result)
+
+		clock.advance(200 * time.Millisecond)
+		result = limiter.Allow("user", common.FreeAccountType)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 300*time.Millisecond, result.RetryAfter)
+
+		clock.advance(300 * time.Millisecond)
+		result = limiter.Allow("user", common.FreeAccountType)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, uint64(0), result.Remaining)
+
+		result = limiter.Allow("user", common.FreeAccountType)
+		assert.False(t, result.Allowed)
+	})
+	t.Run("bucket should not be refilled above the burst", func(t *testing.T) {
+		t.Parallel()
+
+		clock := newTestClock()
+		limiter := createTestTokenBucketLimiter(clock)
+		_ = limiter.Allow("user", common.FreeAccountType)
+
+		clock.advance(time.Hour)
+		numAllowed := 0
+		for i := 0; i < 10; i++ {
+			if limiter.Allow("user", common.FreeAccountType).Allowed {
+				numAllowed++
+			}
+		}
+		assert.Equal(t, 3, numAllowed)
+	})
+	t.Run("clock going backwards should not add tokens", func(t *testing.T) {
+		t.Parallel()
+
+		clock := newTestClock()
+		limiter := createTestTokenBucketLimiter(clock)
+		for i := 0; i < 3; i++ {
+			_ = limiter.Allow("user", common.FreeAccountType)
+		}
+
+		clock.advance(-time.Hour)
+		assert.False(t, limiter.Allow("user", common.FreeAccountType).Allowed)
+	})
+	t.Run("accounts and account types should have separate buckets", func(t *testing.T) {
+		t.Parallel()
+
+		clock := newTestClock()
+		limiter := createTestTokenBucketLimiter(clock)
+		for i := 0; i < 3; i++ {
+			assert.True(t, limiter.Allow("user1", common.FreeAccountType).Allowed)
+		}
+		assert.False(t, limiter.Allow("user1", common.FreeAccountType).Allowed)
+
+		assert.True(t, limiter.Allow("user2", common.FreeAccountType).Allowed)
+		result := limiter.Allow("user1", common.PremiumAccountType)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, uint64(200), result.Limit)
+		assert.Equal(t, uint64(199), result.Remaining)
+		assert.Equal(t, 3, limiter.numBuckets())
+	})
+	t.Run("concurrent calls should not exceed the burst", func(t *testing.T) {
+		t.Parallel()
+
+		clock := newTestClock()
+		limiter := createTestTokenBucketLimiter(clock)
+		numAllowed := 0
+		mut := sync.Mutex{}
+		wg := sync.WaitGroup{}
+		numCalls := 1000
+		wg.Add(numCalls)
+		for i := 0; i < numCalls; i++ {
+			go func(idx int) {
+				defer wg.Done()
+
+				result := limiter.Allow(fmt.Sprintf("user%d", idx%2), common.PremiumAccountType)
+				if result.Allowed {
+					mut.Lock()
+					numAllowed++
+					mut.Unlock()
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		assert.Equal(t, 400, numAllowed)
+	})
+}
+
+func TestTokenBucketLimiter_Sweep(t *testing.T) {
+	t.Parallel()
+
+	clock := newTestClock()
+	limiter := createTestTokenBucketLimiter(clock)
+	_ = limiter.Allow("user1", common.FreeAccountType)
+	for i := 0; i < 3; i++ {
+		_ = limiter.Allow("user2", common.FreeAccountType)
+	}
+	assert.Equal(t, 2, limiter.numBuckets())
+
+	// user1 gets its token back, user2 only two of them
+	clock.advance(time.Second)
+	limiter.Sweep()
+	assert.Equal(t, 1, limiter.numBuckets())
+
+	result := limiter.Allow("user2", common.FreeAccountType)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, uint64(1), result.Remaining)
+
+	clock.advance(time.Hour)
+	limiter.Sweep()
+	assert.Equal(t, 0, limiter.numBuckets())
+}
@@ -0,0 +1,25 @@
+package process
+
+import (
+	"fmt"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+)
+
+// tooManyRequestsError is returned for the accounts that exceeded their quota, it matches errTooManyRequests
+type tooManyRequestsError struct {
+	username    string
+	accountType common.AccountType
+	result      common.RateLimitResult
+}
+
+// Error returns the error message
+func (err *tooManyRequestsError) Error() string {
+	return fmt.Sprintf("%s for %s account: maximum per quota: %d, retry after: %v",
+		errTooManyRequests.Error(), err.accountType, err.result.Limit, err.result.RetryAfter)
+}
+
+// Is returns true for errTooManyRequests
+func (err *tooManyRequestsError) Is(target error) bool {
+	return target == errTooManyRequests
+}
@@ -0,0 +1,148 @@
+package process
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+const (
+	defaultCopyBufferSize = 32 * 1024
+	dialKeepAlive         = 30 * time.Second
+)
+
+type upstreamClient struct {
+	client         *http.Client
+	defaultTimeout time.Duration
+	buffers        sync.Pool
+}
+
+// NewUpstreamClient creates a new upstream client that forwards the requests to the gateways using a dedicated HTTP
+// transport. The zero values in the provided config keep the defaults of the standard library transport.
+func NewUpstreamClient(cfg config.ForwardingConfig) *upstreamClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.MaxIdleConns > 0 {
+		transport.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = cfg.MaxConnsPerHost
+	}
+	if cfg.IdleConnTimeoutInSeconds > 0 {
+		transport.IdleConnTimeout = time.Duration(cfg.IdleConnTimeoutInSeconds) * time.Second
+	}
+	if cfg.TLSHandshakeTimeoutInSeconds > 0 {
+		transport.TLSHandshakeTimeout = time.Duration(cfg.TLSHandshakeTimeoutInSeconds) * time.Second
+	}
+	if cfg.ResponseHeaderTimeoutInSeconds > 0 {
+		transport.ResponseHeaderTimeout = time.Duration(cfg.ResponseHeaderTimeoutInSeconds) * time.Second
+	}
+	if cfg.DialTimeoutInSeconds > 0 {
+		dialer := &net.Dialer{
+			Timeout:   time.Duration(cfg.DialTimeoutInSeconds) * time.Second,
+			KeepAlive: dialKeepAlive,
+		}
+		transport.DialContext = dialer.DialContext
+	}
+
+	bufferSize := int(cfg.CopyBufferSizeInBytes)
+	if bufferSize == 0 {
+		bufferSize = defaultCopyBufferSize
+	}
+
+	return &upstreamClient{
+		client: &http.Client{
+			Transport: transport,
+		},
+		defaultTimeout: time.Duration(cfg.TimeoutInSeconds) * time.Second,
+		buffers: sync.Pool{
+			New: func() any {
+				buffer := make([]byte, bufferSize)
+				return &buffer
+			},
+		},
+	}
+}
+
+// Do sends the request to the gateway. The call, including the reading of the response body, is limited by the
+// provided timeout or, if 0, by the default timeout. The request is also canceled when its context is done, so the
+// caller should create it with the client's request context. The caller should close the response body.
+func (client *upstreamClient) Do(request *http.Request, timeout time.Duration) (*http.Response, error) {
+	if timeout == 0 {
+		timeout = client.defaultTimeout
+	}
+	if timeout == 0 {
+		return client.client.Do(request)
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), timeout)
+	response, err := client.client.Do(request.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	response.Body = &cancelOnCloseReader{
+		ReadCloser: response.Body,
+		cancel:     cancel,
+	}
+
+	return response, nil
+}
+
+// CopyBody streams the source into the destination using one of the pooled, fixed size buffers. If the destination
+// is an http.Flusher, each chunk is flushed to the client as soon as it is written.
+func (client *upstreamClient) CopyBody(destination io.Writer, source io.Reader) (int64, error) {
+	buffer := client.buffers.Get().(*[]byte)
+	defer client.buffers.Put(buffer)
+
+	// the wrapper also hides the ReaderFrom implementation of the destination, if any, so the pooled buffer is used
+	writer := &flushWriter{
+		writer: destination,
+	}
+	writer.flusher, _ = destination.(http.Flusher)
+
+	return io.CopyBuffer(writer, source, *buffer)
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (client *upstreamClient) IsInterfaceNil() bool {
+	return client == nil
+}
+
+// cancelOnCloseReader releases the context of the request when the response body is closed
+type cancelOnCloseReader struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the wrapped body and releases the request's context
+func (reader *cancelOnCloseReader) Close() error {
+	err := reader.ReadCloser.Close()
+	reader.cancel()
+
+	return err
+}
+
+// flushWriter flushes the written data, if the wrapped writer allows it
+type flushWriter struct {
+	writer  io.Writer
+	flusher http.Flusher
+}
+
+// Write writes the data and flushes it
+func (writer *flushWriter) Write(data []byte) (int, error) {
+	n, err := writer.writer.Write(data)
+	if writer.flusher != nil {
+		writer.flusher.Flush()
+	}
+
+	return n, err
+}
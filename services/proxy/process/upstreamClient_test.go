@@ -0,0 +1,169 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/testscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUpstreamClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty config should keep the defaults", func(t *testing.T) {
+		t.Parallel()
+
+		client := NewUpstreamClient(config.ForwardingConfig{})
+		assert.False(t, client.IsInterfaceNil())
+
+		defaultTransport := http.DefaultTransport.(*http.Transport)
+		transport := client.client.Transport.(*http.Transport)
+		assert.Equal(t, defaultTransport.MaxIdleConns, transport.MaxIdleConns)
+		assert.Equal(t, defaultTransport.MaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+		assert.Equal(t, defaultTransport.IdleConnTimeout, transport.IdleConnTimeout)
+		assert.Equal(t, time.Duration(0), client.defaultTimeout)
+		assert.Len(t, *client.buffers.Get().(*[]byte), defaultCopyBufferSize)
+	})
+	t.Run("should apply the config", func(t *testing.T) {
+		t.Parallel()
+
+		client := NewUpstreamClient(config.ForwardingConfig{
+			MaxIdleConns:                   500,
+			MaxIdleConnsPerHost:            64,
+			MaxConnsPerHost:                128,
+			IdleConnTimeoutInSeconds:       90,
+			DialTimeoutInSeconds:           5,
+			TLSHandshakeTimeoutInSeconds:   6,
+			ResponseHeaderTimeoutInSeconds: 30,
+			TimeoutInSeconds:               60,
+			CopyBufferSizeInBytes:          1024,
+		})
+
+		transport := client.client.Transport.(*http.Transport)
+		assert.Equal(t, 500, transport.MaxIdleConns)
+		assert.Equal(t, 64, transport.MaxIdleConnsPerHost)
+		assert.Equal(t, 128, transport.MaxConnsPerHost)
+		assert.Equal(t, 90*time.Second, transport.IdleConnTimeout)
+		assert.Equal(t, 6*time.Second, transport.TLSHandshakeTimeout)
+		assert.Equal(t, 30*time.Second, transport.ResponseHeaderTimeout)
+		assert.NotNil(t, transport.DialContext)
+		assert.Equal(t, 60*time.Second, client.defaultTimeout)
+		assert.Len(t, *client.buffers.Get().(*[]byte), 1024)
+	})
+}
+
+func TestUpstreamClient_Do(t *testing.T) {
+	t.Parallel()
+
+	createSlowServer := func(delay time.Duration) *httptest.Server {
+		return httptest.NewServer(&testscommon.HttpHandlerStub{
+			ServeHTTPCalled: func(writer http.ResponseWriter, request *http.Request) {
+				select {
+				case <-time.After(delay):
+				case <-request.Context().Done():
+					return
+				}
+				_, _ = writer.Write([]byte("response"))
+			},
+		})
+	}
+
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		server := createSlowServer(0)
+		defer server.Close()
+
+		client := NewUpstreamClient(config.ForwardingConfig{})
+		request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		response, err := client.Do(request, time.Second)
+		require.Nil(t, err)
+
+		body, err := io.ReadAll(response.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, "response", string(body))
+		assert.Nil(t, response.Body.Close())
+	})
+	t.Run("the provided timeout should be applied", func(t *testing.T) {
+		t.Parallel()
+
+		server := createSlowServer(time.Minute)
+		defer server.Close()
+
+		client := NewUpstreamClient(config.ForwardingConfig{TimeoutInSeconds: 60})
+		request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		response, err := client.Do(request, 50*time.Millisecond)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, response)
+	})
+	t.Run("the default timeout should be applied", func(t *testing.T) {
+		t.Parallel()
+
+		server := createSlowServer(5 * time.Second)
+		defer server.Close()
+
+		client := NewUpstreamClient(config.ForwardingConfig{TimeoutInSeconds: 1})
+		request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		start := time.Now()
+		response, err := client.Do(request, 0)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, response)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+	t.Run("canceled request context should cancel the call", func(t *testing.T) {
+		t.Parallel()
+
+		server := createSlowServer(time.Minute)
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		client := NewUpstreamClient(config.ForwardingConfig{})
+		request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		response, err := client.Do(request, 0)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, response)
+	})
+}
+
+func TestUpstreamClient_CopyBody(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should stream and flush each chunk", func(t *testing.T) {
+		t.Parallel()
+
+		client := NewUpstreamClient(config.ForwardingConfig{CopyBufferSizeInBytes: 4})
+		data := strings.Repeat("0123456789", 100)
+		recorder := httptest.NewRecorder()
+
+		written, err := client.CopyBody(recorder, strings.NewReader(data))
+		assert.Nil(t, err)
+		assert.Equal(t, int64(len(data)), written)
+		assert.Equal(t, data, recorder.Body.String())
+		assert.True(t, recorder.Flushed)
+	})
+	t.Run("source errors should be returned", func(t *testing.T) {
+		t.Parallel()
+
+		expectedErr := errors.New("read error")
+		client := NewUpstreamClient(config.ForwardingConfig{})
+		source := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(expectedErr))
+		recorder := httptest.NewRecorder()
+
+		written, err := client.CopyBody(recorder, source)
+		assert.Equal(t, expectedErr, err)
+		assert.Equal(t, int64(len("partial")), written)
+		assert.Equal(t, "partial", recorder.Body.String())
+	})
+}
@@ -0,0 +1,48 @@
+package process
+
+import (
+	"sync"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+// weightedBalancer implements the smooth weighted round-robin algorithm: the replicas are selected proportionally
+// with their weights while avoiding consecutive bursts on the heaviest replica
+type weightedBalancer struct {
+	mut          sync.Mutex
+	replicas     []config.ReplicaConfig
+	currentScore []int64
+}
+
+func newWeightedBalancer(replicas []config.ReplicaConfig) *weightedBalancer {
+	return &weightedBalancer{
+		replicas:     replicas,
+		currentScore: make([]int64, len(replicas)),
+	}
+}
+
+func (balancer *weightedBalancer) next(isAvailable func(url string) bool) (config.ReplicaConfig, bool) {
+	balancer.mut.Lock()
+	defer balancer.mut.Unlock()
+
+	selected := -1
+	totalWeight := int64(0)
+	for i, replica := range balancer.replicas {
+		if !isAvailable(replica.URL) {
+			continue
+		}
+
+		totalWeight += int64(replica.Weight)
+		balancer.currentScore[i] += int64(replica.Weight)
+		if selected < 0 || balancer.currentScore[i] > balancer.currentScore[selected] {
+			selected = i
+		}
+	}
+	if selected < 0 {
+		return config.ReplicaConfig{}, false
+	}
+
+	balancer.currentScore[selected] -= totalWeight
+
+	return balancer.replicas[selected], true
+}
@@ -0,0 +1,70 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeightedBalancer_Next(t *testing.T) {
+	t.Parallel()
+
+	t.Run("equal weights should behave as round-robin", func(t *testing.T) {
+		t.Parallel()
+
+		replicas := []config.ReplicaConfig{
+			{URL: "URL1", Weight: 1},
+			{URL: "URL2", Weight: 1},
+		}
+		balancer := newWeightedBalancer(replicas)
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, replicas[i%len(replicas)], nextReplica(t, balancer))
+		}
+	})
+	t.Run("should distribute proportionally without bursts", func(t *testing.T) {
+		t.Parallel()
+
+		replicas := []config.ReplicaConfig{
+			{URL: "URL1", Weight: 5},
+			{URL: "URL2", Weight: 1},
+			{URL: "URL3", Weight: 1},
+		}
+		balancer := newWeightedBalancer(replicas)
+
+		selected := make([]string, 0, 7)
+		for i := 0; i < 7; i++ {
+			selected = append(selected, nextReplica(t, balancer).URL)
+		}
+		assert.Equal(t, []string{"URL1", "URL1", "URL2", "URL1", "URL3", "URL1", "URL1"}, selected)
+
+		counters := make(map[string]int)
+		for i := 0; i < 700; i++ {
+			counters[nextReplica(t, balancer).URL]++
+		}
+		assert.Equal(t, map[string]int{"URL1": 500, "URL2": 100, "URL3": 100}, counters)
+	})
+	t.Run("unavailable replicas should not accumulate score", func(t *testing.T) {
+		t.Parallel()
+
+		replicas := []config.ReplicaConfig{
+			{URL: "URL1", Weight: 5},
+			{URL: "URL2", Weight: 1},
+		}
+		balancer := newWeightedBalancer(replicas)
+		onlyURL2 := func(url string) bool {
+			return url == "URL2"
+		}
+		for i := 0; i < 10; i++ {
+			replica, found := balancer.next(onlyURL2)
+			assert.True(t, found)
+			assert.Equal(t, "URL2", replica.URL)
+		}
+
+		replica, found := balancer.next(func(url string) bool {
+			return false
+		})
+		assert.False(t, found)
+		assert.Empty(t, replica.URL)
+	})
+}
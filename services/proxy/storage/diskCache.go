@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+)
+
+const (
+	diskCacheFileExtension = ".cache"
+	diskCacheDirMode       = 0750
+	diskCacheFileMode      = 0640
+)
+
+type diskCacheFile struct {
+	name string
+	size uint64
+}
+
+type diskCacheEntry struct {
+	Key      string                `json:"Key"`
+	Response common.CachedResponse `json:"Response"`
+}
+
+// diskCache is a size limited LRU storing each entry in its own file. It is not concurrent safe, the caller should
+// protect the calls.
+type diskCache struct {
+	path    string
+	maxSize uint64
+	size    uint64
+	order   *list.List
+	files   map[string]*list.Element
+}
+
+// newDiskCache creates the disk cache, indexing the entries already present in the provided directory. The least
+// recently modified files are the first to be evicted.
+func newDiskCache(path string, maxSize uint64) (*diskCache, error) {
+	err := os.MkdirAll(path, diskCacheDirMode)
+	if err != nil {
+		return nil, err
+	}
+
+	dirEntries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	type existingFile struct {
+		diskCacheFile
+		modTime time.Time
+	}
+	existingFiles := make([]existingFile, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), diskCacheFileExtension) {
+			continue
+		}
+
+		info, errInfo := dirEntry.Info()
+		if errInfo != nil {
+			continue
+		}
+
+		existingFiles = append(existingFiles, existingFile{
+			diskCacheFile: diskCacheFile{
+				name: dirEntry.Name(),
+				size: uint64(info.Size()),
+			},
+			modTime: info.ModTime(),
+		})
+	}
+
+	sort.SliceStable(existingFiles, func(i, j int) bool {
+		return existingFiles[i].modTime.After(existingFiles[j].modTime)
+	})
+
+	cache := &diskCache{
+		path:    path,
+		maxSize: maxSize,
+		order:   list.New(),
+		files:   make(map[string]*list.Element),
+	}
+	for _, file := range existingFiles {
+		cache.files[file.name] = cache.order.PushBack(&diskCacheFile{
+			name: file.name,
+			size: file.size,
+		})
+		cache.size += file.size
+	}
+	cache.evict()
+
+	return cache, nil
+}
+
+// get loads the entry of the provided key, if present
+func (cache *diskCache) get(key string) (common.CachedResponse, bool) {
+	name := diskCacheFileName(key)
+	element, found := cache.files[name]
+	if !found {
+		return common.CachedResponse{}, false
+	}
+
+	filePath := filepath.Join(cache.path, name)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		log.Debug("can not read the disk cache file", "file", filePath, "error", err)
+		cache.remove(element)
+		return common.CachedResponse{}, false
+	}
+
+	entry := &diskCacheEntry{}
+	err = json.Unmarshal(data, entry)
+	if err != nil || entry.Key != key {
+		log.Debug("invalid disk cache file", "file", filePath, "error", err)
+		cache.remove(element)
+		return common.CachedResponse{}, false
+	}
+
+	cache.order.MoveToFront(element)
+	now := time.Now()
+	_ = os.Chtimes(filePath, now, now)
+
+	return entry.Response, true
+}
+
+// has returns true if the provided key is stored
+func (cache *diskCache) has(key string) bool {
+	_, found := cache.files[diskCacheFileName(key)]
+
+	return found
+}
+
+// put writes the entry in its own file, evicting the least recently used files if the maximum size is exceeded
+func (cache *diskCache) put(key string, response common.CachedResponse) {
+	data, err := json.Marshal(&diskCacheEntry{
+		Key:      key,
+		Response: response,
+	})
+	if err != nil {
+		log.Debug("can not marshal the disk cache entry", "error", err)
+		return
+	}
+	if uint64(len(data)) > cache.maxSize {
+		return
+	}
+
+	name := diskCacheFileName(key)
+	err = os.WriteFile(filepath.Join(cache.path, name), data, diskCacheFileMode)
+	if err != nil {
+		log.Debug("can not write the disk cache file", "file", name, "error", err)
+		return
+	}
+
+	element, found := cache.files[name]
+	if found {
+		file := element.Value.(*diskCacheFile)
+		cache.size -= file.size
+		file.size = uint64(len(data))
+		cache.order.MoveToFront(element)
+	} else {
+		cache.files[name] = cache.order.PushFront(&diskCacheFile{
+			name: name,
+			size: uint64(len(data)),
+		})
+	}
+	cache.size += uint64(len(data))
+
+	cache.evict()
+}
+
+// purge removes all the files, returning their number
+func (cache *diskCache) purge() int {
+	numFiles := len(cache.files)
+	for cache.order.Len() > 0 {
+		cache.remove(cache.order.Back())
+	}
+
+	return numFiles
+}
+
+func (cache *diskCache) evict() {
+	for cache.size > cache.maxSize && cache.order.Len() > 0 {
+		cache.remove(cache.order.Back())
+	}
+}
+
+func (cache *diskCache) remove(element *list.Element) {
+	file := element.Value.(*diskCacheFile)
+	cache.order.Remove(element)
+	delete(cache.files, file.name)
+	cache.size -= file.size
+
+	err := os.Remove(filepath.Join(cache.path, file.name))
+	if err != nil && !os.IsNotExist(err) {
+		log.Debug("can not remove the disk cache file", "file", file.name, "error", err)
+	}
+}
+
+func diskCacheFileName(key string) string {
+	hash := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(hash[:]) + diskCacheFileExtension
+}
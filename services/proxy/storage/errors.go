@@ -5,3 +5,12 @@ import "errors"
 var errKeyIsEmpty = errors.New("key is empty")
 var errNilCountersCache = errors.New("nil counters cache")
 var errInvalidTTL = errors.New("invalid TTL")
+var errHashIsEmpty = errors.New("hash is empty")
+var errGatewayIsEmpty = errors.New("empty gateway")
+var errInvalidCacheSize = errors.New("the response cache requires non-zero size limits")
+var errInvalidKeyScope = errors.New("invalid key scope")
+var errInvalidKeyRestrictions = errors.New("invalid key restrictions")
+var errEmptyKeysHashKey = errors.New("empty keys hash key")
+var errKeyExpired = errors.New("the provided key expired")
+var errKeyAlreadyRotated = errors.New("the key was already rotated")
+var errEmptyKeyUpdate = errors.New("nothing to update on the key")
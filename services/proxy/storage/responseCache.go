@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+const (
+	responseCacheMemoryHits    = "memory_hits"
+	responseCacheDiskHits      = "disk_hits"
+	responseCacheMisses        = "misses"
+	responseCacheMemoryEntries = "memory_entries"
+	responseCacheMemoryBytes   = "memory_bytes"
+	responseCacheDiskEntries   = "disk_entries"
+	responseCacheDiskBytes     = "disk_bytes"
+)
+
+type memoryCacheEntry struct {
+	key      string
+	response common.CachedResponse
+	size     uint64
+}
+
+type responseCache struct {
+	mut          sync.Mutex
+	enabled      bool
+	maxSize      uint64
+	maxEntrySize uint64
+	size         uint64
+	order        *list.List
+	entries      map[string]*list.Element
+	disk         *diskCache
+	memoryHits   uint64
+	diskHits     uint64
+	misses       uint64
+}
+
+// NewResponseCache creates a new response cache: an in-memory LRU limited by MaxMemorySizeInBytes and, if DiskPath is
+// set, a second tier on disk limited by MaxDiskSizeInBytes that receives the entries evicted from memory.
+// A disabled cache never stores anything.
+func NewResponseCache(cfg config.ResponseCacheConfig) (*responseCache, error) {
+	cache := &responseCache{
+		enabled:      cfg.Enabled,
+		maxSize:      cfg.MaxMemorySizeInBytes,
+		maxEntrySize: cfg.MaxEntrySizeInBytes,
+		order:        list.New(),
+		entries:      make(map[string]*list.Element),
+	}
+	if !cfg.Enabled {
+		return cache, nil
+	}
+	if cfg.MaxMemorySizeInBytes == 0 || cfg.MaxEntrySizeInBytes == 0 {
+		return nil, errInvalidCacheSize
+	}
+	if len(cfg.DiskPath) == 0 {
+		return cache, nil
+	}
+	if cfg.MaxDiskSizeInBytes == 0 {
+		return nil, errInvalidCacheSize
+	}
+
+	var err error
+	cache.disk, err = newDiskCache(cfg.DiskPath, cfg.MaxDiskSizeInBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache, nil
+}
+
+// IsEnabled returns true if the cache stores the responses
+func (cache *responseCache) IsEnabled() bool {
+	return cache.enabled
+}
+
+// MaxEntrySize returns the maximum size of a response body that can be cached
+func (cache *responseCache) MaxEntrySize() uint64 {
+	return cache.maxEntrySize
+}
+
+// Get returns the response stored for the provided key, searching the memory first and then the disk. An entry found
+// on disk is also brought in memory.
+func (cache *responseCache) Get(key string) (common.CachedResponse, bool) {
+	if !cache.enabled {
+		return common.CachedResponse{}, false
+	}
+
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	element, found := cache.entries[key]
+	if found {
+		cache.memoryHits++
+		cache.order.MoveToFront(element)
+		return element.Value.(*memoryCacheEntry).response, true
+	}
+
+	if cache.disk != nil {
+		response, foundOnDisk := cache.disk.get(key)
+		if foundOnDisk {
+			cache.diskHits++
+			cache.putInMemory(key, response)
+			return response, true
+		}
+	}
+
+	cache.misses++
+
+	return common.CachedResponse{}, false
+}
+
+// Put stores the response for the provided key. The responses larger than the maximum entry size are ignored.
+func (cache *responseCache) Put(key string, response common.CachedResponse) {
+	if !cache.enabled || uint64(len(response.Body)) > cache.maxEntrySize {
+		return
+	}
+
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	cache.putInMemory(key, response)
+}
+
+func (cache *responseCache) putInMemory(key string, response common.CachedResponse) {
+	element, found := cache.entries[key]
+	if found {
+		cache.removeFromMemory(element)
+	}
+
+	entry := &memoryCacheEntry{
+		key:      key,
+		response: response,
+		size:     computeEntrySize(key, response),
+	}
+	cache.entries[key] = cache.order.PushFront(entry)
+	cache.size += entry.size
+
+	for cache.size > cache.maxSize && cache.order.Len() > 0 {
+		evicted := cache.order.Back()
+		cache.removeFromMemory(evicted)
+
+		evictedEntry := evicted.Value.(*memoryCacheEntry)
+		if cache.disk != nil && !cache.disk.has(evictedEntry.key) {
+			cache.disk.put(evictedEntry.key, evictedEntry.response)
+		}
+	}
+}
+
+func (cache *responseCache) removeFromMemory(element *list.Element) {
+	entry := element.Value.(*memoryCacheEntry)
+	cache.order.Remove(element)
+	delete(cache.entries, entry.key)
+	cache.size -= entry.size
+}
+
+// Purge removes all the cached responses, from memory and from disk, returning the number of removed entries
+func (cache *responseCache) Purge() int {
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	numRemoved := len(cache.entries)
+	cache.order.Init()
+	cache.entries = make(map[string]*list.Element)
+	cache.size = 0
+
+	if cache.disk != nil {
+		numRemoved += cache.disk.purge()
+	}
+
+	log.Info("response cache purged", "removed entries", numRemoved)
+
+	return numRemoved
+}
+
+// GetMetrics returns the hits, misses and the size of each cache tier
+func (cache *responseCache) GetMetrics() map[string]uint64 {
+	cache.mut.Lock()
+	defer cache.mut.Unlock()
+
+	metrics := map[string]uint64{
+		responseCacheMemoryHits:    cache.memoryHits,
+		responseCacheDiskHits:      cache.diskHits,
+		responseCacheMisses:        cache.misses,
+		responseCacheMemoryEntries: uint64(len(cache.entries)),
+		responseCacheMemoryBytes:   cache.size,
+	}
+	if cache.disk != nil {
+		metrics[responseCacheDiskEntries] = uint64(len(cache.disk.files))
+		metrics[responseCacheDiskBytes] = cache.disk.size
+	}
+
+	return metrics
+}
+
+// IsInterfaceNil returns true if the value under the interface is nil
+func (cache *responseCache) IsInterfaceNil() bool {
+	return cache == nil
+}
+
+func computeEntrySize(key string, response common.CachedResponse) uint64 {
+	size := len(key) + len(response.Body)
+	for name, values := range response.Header {
+		size += len(name)
+		for _, value := range values {
+			size += len(value)
+		}
+	}
+
+	return uint64(size)
+}
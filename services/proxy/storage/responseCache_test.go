@@ -0,0 +1,296 @@
+package storage
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestCachedResponse(body string) common.CachedResponse {
+	return common.CachedResponse{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type": []string{"application/json"},
+		},
+		Body: []byte(body),
+	}
+}
+
+func TestNewResponseCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("disabled cache should not validate the config", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := NewResponseCache(config.ResponseCacheConfig{})
+		assert.Nil(t, err)
+		assert.False(t, cache.IsInterfaceNil())
+		assert.False(t, cache.IsEnabled())
+	})
+	t.Run("zero memory size should error", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := NewResponseCache(config.ResponseCacheConfig{
+			Enabled:             true,
+			MaxEntrySizeInBytes: 100,
+		})
+		assert.Equal(t, errInvalidCacheSize, err)
+		assert.True(t, cache.IsInterfaceNil())
+	})
+	t.Run("zero entry size should error", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := NewResponseCache(config.ResponseCacheConfig{
+			Enabled:              true,
+			MaxMemorySizeInBytes: 100,
+		})
+		assert.Equal(t, errInvalidCacheSize, err)
+		assert.Nil(t, cache)
+	})
+	t.Run("zero disk size should error", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := NewResponseCache(config.ResponseCacheConfig{
+			Enabled:              true,
+			MaxMemorySizeInBytes: 100,
+			MaxEntrySizeInBytes:  100,
+			DiskPath:             t.TempDir(),
+		})
+		assert.Equal(t, errInvalidCacheSize, err)
+		assert.Nil(t, cache)
+	})
+	t.Run("invalid disk path should error", func(t *testing.T) {
+		t.Parallel()
+
+		filePath := filepath.Join(t.TempDir(), "file")
+		require.Nil(t, os.WriteFile(filePath, []byte("data"), 0640))
+
+		cache, err := NewResponseCache(config.ResponseCacheConfig{
+			Enabled:              true,
+			MaxMemorySizeInBytes: 100,
+			MaxEntrySizeInBytes:  100,
+			DiskPath:             filePath,
+			MaxDiskSizeInBytes:   100,
+		})
+		assert.NotNil(t, err)
+		assert.Nil(t, cache)
+	})
+	t.Run("should work", func(t *testing.T) {
+		t.Parallel()
+
+		cache, err := NewResponseCache(config.ResponseCacheConfig{
+			Enabled:              true,
+			MaxMemorySizeInBytes: 100,
+			MaxEntrySizeInBytes:  50,
+		})
+		assert.Nil(t, err)
+		assert.True(t, cache.IsEnabled())
+		assert.Equal(t, uint64(50), cache.MaxEntrySize())
+	})
+}
+
+func TestResponseCache_MemoryTier(t *testing.T) {
+	t.Parallel()
+
+	// each entry below has 1 (key) + 10 (body) + 28 (header) = 39 bytes
+	createCache := func() *responseCache {
+		cache, _ := NewResponseCache(config.ResponseCacheConfig{
+			Enabled:              true,
+			MaxMemorySizeInBytes: 100,
+			MaxEntrySizeInBytes:  20,
+		})
+
+		return cache
+	}
+
+	t.Run("disabled cache should not store", func(t *testing.T) {
+		t.Parallel()
+
+		cache, _ := NewResponseCache(config.ResponseCacheConfig{})
+		cache.Put("a", createTestCachedResponse("0123456789"))
+		_, found := cache.Get("a")
+		assert.False(t, found)
+	})
+	t.Run("should store and return", func(t *testing.T) {
+		t.Parallel()
+
+		cache := createCache()
+		_, found := cache.Get("a")
+		assert.False(t, found)
+
+		cache.Put("a", createTestCachedResponse("0123456789"))
+		response, found := cache.Get("a")
+		assert.True(t, found)
+		assert.Equal(t, createTestCachedResponse("0123456789"), response)
+
+		expectedMetrics := map[string]uint64{
+			responseCacheMemoryHits:    1,
+			responseCacheDiskHits:      0,
+			responseCacheMisses:        1,
+			responseCacheMemoryEntries: 1,
+			responseCacheMemoryBytes:   39,
+		}
+		assert.Equal(t, expectedMetrics, cache.GetMetrics())
+	})
+	t.Run("entries larger than the limit should be ignored", func(t *testing.T) {
+		t.Parallel()
+
+		cache := createCache()
+		cache.Put("a", createTestCachedResponse(strings.Repeat("0", 21)))
+		_, found := cache.Get("a")
+		assert.False(t, found)
+	})
+	t.Run("should evict the least recently used entries", func(t *testing.T) {
+		t.Parallel()
+
+		cache := createCache()
+		cache.Put("a", createTestCachedResponse("0123456789"))
+		cache.Put("b", createTestCachedResponse("0123456789"))
+		_, _ = cache.Get("a")
+		cache.Put("c", createTestCachedResponse("0123456789"))
+
+		_, found := cache.Get("a")
+		assert.True(t, found)
+		_, found = cache.Get("b")
+		assert.False(t, found)
+		_, found = cache.Get("c")
+		assert.True(t, found)
+		assert.Equal(t, uint64(78), cache.GetMetrics()[responseCacheMemoryBytes])
+	})
+	t.Run("put on an existing key should replace the entry", func(t *testing.T) {
+		t.Parallel()
+
+		cache := createCache()
+		cache.Put("a", createTestCachedResponse("0123456789"))
+		cache.Put("a", createTestCachedResponse("9876543210"))
+
+		response, _ := cache.Get("a")
+		assert.Equal(t, "9876543210", string(response.Body))
+		assert.Equal(t, uint64(39), cache.GetMetrics()[responseCacheMemoryBytes])
+	})
+	t.Run("purge should remove all the entries", func(t *testing.T) {
+		t.Parallel()
+
+		cache := createCache()
+		cache.Put("a", createTestCachedResponse("0123456789"))
+		cache.Put("b", createTestCachedResponse("0123456789"))
+
+		assert.Equal(t, 2, cache.Purge())
+		_, found := cache.Get("a")
+		assert.False(t, found)
+		assert.Equal(t, uint64(0), cache.GetMetrics()[responseCacheMemoryBytes])
+		assert.Equal(t, uint64(0), cache.GetMetrics()[responseCacheMemoryEntries])
+	})
+}
+
+func TestResponseCache_DiskTier(t *testing.T) {
+	t.Parallel()
+
+	createCache := func(diskPath string, maxDiskSize uint64) *responseCache {
+		cache, err := NewResponseCache(config.ResponseCacheConfig{
+			Enabled:              true,
+			MaxMemorySizeInBytes: 50,
+			MaxEntrySizeInBytes:  20,
+			DiskPath:             diskPath,
+			MaxDiskSizeInBytes:   maxDiskSize,
+		})
+		require.Nil(t, err)
+
+		return cache
+	}
+	countFiles := func(diskPath string) int {
+		entries, _ := os.ReadDir(diskPath)
+		return len(entries)
+	}
+
+	t.Run("evicted entries should be moved to disk and brought back", func(t *testing.T) {
+		t.Parallel()
+
+		diskPath := t.TempDir()
+		cache := createCache(diskPath, 10000)
+		cache.Put("a", createTestCachedResponse("0123456789"))
+		cache.Put("b", createTestCachedResponse("abcdefghij"))
+		assert.Equal(t, 1, countFiles(diskPath))
+
+		response, found := cache.Get("a")
+		assert.True(t, found)
+		assert.Equal(t, createTestCachedResponse("0123456789"), response)
+
+		response, found = cache.Get("b")
+		assert.True(t, found)
+		assert.Equal(t, createTestCachedResponse("abcdefghij"), response)
+		assert.Equal(t, 2, countFiles(diskPath))
+
+		metrics := cache.GetMetrics()
+		assert.Equal(t, uint64(2), metrics[responseCacheDiskHits])
+		assert.Equal(t, uint64(0), metrics[responseCacheMemoryHits])
+		assert.Equal(t, uint64(2), metrics[responseCacheDiskEntries])
+	})
+	t.Run("disk entries should survive a restart", func(t *testing.T) {
+		t.Parallel()
+
+		diskPath := t.TempDir()
+		cache := createCache(diskPath, 10000)
+		cache.Put("a", createTestCachedResponse("0123456789"))
+		cache.Put("b", createTestCachedResponse("abcdefghij"))
+
+		cache = createCache(diskPath, 10000)
+		assert.Equal(t, uint64(1), cache.GetMetrics()[responseCacheDiskEntries])
+		response, found := cache.Get("a")
+		assert.True(t, found)
+		assert.Equal(t, createTestCachedResponse("0123456789"), response)
+		_, found = cache.Get("b")
+		assert.False(t, found)
+	})
+	t.Run("disk size should be limited", func(t *testing.T) {
+		t.Parallel()
+
+		diskPath := t.TempDir()
+		cache := createCache(diskPath, 200)
+		for _, key := range []string{"a", "b", "c", "d", "e"} {
+			cache.Put(key, createTestCachedResponse("0123456789"))
+		}
+
+		assert.Equal(t, 1, countFiles(diskPath))
+		assert.LessOrEqual(t, cache.GetMetrics()[responseCacheDiskBytes], uint64(200))
+		_, found := cache.Get("d")
+		assert.True(t, found)
+		_, found = cache.Get("a")
+		assert.False(t, found)
+	})
+	t.Run("invalid disk files should be removed", func(t *testing.T) {
+		t.Parallel()
+
+		diskPath := t.TempDir()
+		cache := createCache(diskPath, 10000)
+		cache.Put("a", createTestCachedResponse("0123456789"))
+		cache.Put("b", createTestCachedResponse("abcdefghij"))
+
+		filePath := filepath.Join(diskPath, diskCacheFileName("a"))
+		require.Nil(t, os.WriteFile(filePath, []byte("not a json"), 0640))
+
+		_, found := cache.Get("a")
+		assert.False(t, found)
+		assert.Equal(t, 0, countFiles(diskPath))
+	})
+	t.Run("purge should remove the disk files", func(t *testing.T) {
+		t.Parallel()
+
+		diskPath := t.TempDir()
+		cache := createCache(diskPath, 10000)
+		cache.Put("a", createTestCachedResponse("0123456789"))
+		cache.Put("b", createTestCachedResponse("abcdefghij"))
+
+		assert.Equal(t, 2, cache.Purge())
+		assert.Equal(t, 0, countFiles(diskPath))
+		_, found := cache.Get("a")
+		assert.False(t, found)
+	})
+}
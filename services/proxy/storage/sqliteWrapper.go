@@ -1,14 +1,18 @@
 package storage
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
 	_ "github.com/mattn/go-sqlite3"
@@ -26,13 +30,26 @@ type sqliteWrapper struct {
 	db                     *sql.DB
 	pendingWritesWaitGroup *sync.WaitGroup
 	counters               CountersCache
+	keysHashKey            []byte
+	keysUsageMut           sync.Mutex
+	keysUsage              map[string]keyUsage
 }
 
-// NewSQLiteWrapper creates a new instance of SQLiteWrapper
-func NewSQLiteWrapper(dbPath string, counters CountersCache) (*sqliteWrapper, error) {
+// keyUsage holds the last use of an access key, not yet written in the DB
+type keyUsage struct {
+	lastUsedAt int64
+	lastIP     string
+}
+
+// NewSQLiteWrapper creates a new instance of SQLiteWrapper. The access keys are stored as HMAC-SHA256 hashes computed
+// with the provided keysHashKey, so the same value should be provided on each start.
+func NewSQLiteWrapper(dbPath string, counters CountersCache, keysHashKey string) (*sqliteWrapper, error) {
 	if check.IfNil(counters) {
 		return nil, errNilCountersCache
 	}
+	if len(keysHashKey) == 0 {
+		return nil, errEmptyKeysHashKey
+	}
 
 	err := prepareDirectories(dbPath)
 	if err != nil {
@@ -69,6 +86,8 @@ func NewSQLiteWrapper(dbPath string, counters CountersCache) (*sqliteWrapper, er
 		db:                     db,
 		counters:               counters,
 		pendingWritesWaitGroup: &sync.WaitGroup{},
+		keysHashKey:            []byte(keysHashKey),
+		keysUsage:              make(map[string]keyUsage),
 	}
 	err = wrapper.initializeTables()
 	if err != nil {
@@ -98,7 +117,8 @@ func (wrapper *sqliteWrapper) initializeTables() error {
 		pending_email TEXT DEFAULT '',
 		change_email_token TEXT DEFAULT '',
 		crypto_payment_id INTEGER DEFAULT NULL,
-		sc_max_requests INTEGER DEFAULT 0
+		sc_max_requests INTEGER DEFAULT 0,
+		tier TEXT DEFAULT ''
 	);`
 	_, err := wrapper.db.Exec(usersTable)
 	if err != nil {
@@ -113,12 +133,23 @@ func (wrapper *sqliteWrapper) initializeTables() error {
 	_, _ = wrapper.db.Exec("ALTER TABLE users ADD COLUMN change_email_token TEXT DEFAULT '';")
 	_, _ = wrapper.db.Exec("ALTER TABLE users ADD COLUMN crypto_payment_id INTEGER DEFAULT NULL;")
 	_, _ = wrapper.db.Exec("ALTER TABLE users ADD COLUMN sc_max_requests INTEGER DEFAULT 0;")
+	_, _ = wrapper.db.Exec("ALTER TABLE users ADD COLUMN tier TEXT DEFAULT '';")
 
 	keysTable := `
 	CREATE TABLE IF NOT EXISTS access_keys (
 		key TEXT PRIMARY KEY,
 		username TEXT,
 		request_count INTEGER DEFAULT 0,
+		scope TEXT DEFAULT '',
+		key_prefix TEXT DEFAULT NULL,
+		expires_at INTEGER DEFAULT 0,
+		rotated_to TEXT DEFAULT '',
+		grace_request_count INTEGER DEFAULT 0,
+		label TEXT DEFAULT '',
+		created_at INTEGER DEFAULT 0,
+		last_used_at INTEGER DEFAULT 0,
+		last_ip TEXT DEFAULT '',
+		restrictions TEXT DEFAULT '',
 		FOREIGN KEY(username) REFERENCES users(username)
 	);`
 	_, err = wrapper.db.Exec(keysTable)
@@ -126,6 +157,22 @@ func (wrapper *sqliteWrapper) initializeTables() error {
 		return fmt.Errorf("failed to create access_keys table: %w", err)
 	}
 
+	_, _ = wrapper.db.Exec("ALTER TABLE access_keys ADD COLUMN scope TEXT DEFAULT '';")
+	_, _ = wrapper.db.Exec("ALTER TABLE access_keys ADD COLUMN expires_at INTEGER DEFAULT 0;")
+	_, _ = wrapper.db.Exec("ALTER TABLE access_keys ADD COLUMN rotated_to TEXT DEFAULT '';")
+	_, _ = wrapper.db.Exec("ALTER TABLE access_keys ADD COLUMN grace_request_count INTEGER DEFAULT 0;")
+	_, _ = wrapper.db.Exec("ALTER TABLE access_keys ADD COLUMN label TEXT DEFAULT '';")
+	_, _ = wrapper.db.Exec("ALTER TABLE access_keys ADD COLUMN created_at INTEGER DEFAULT 0;")
+	_, _ = wrapper.db.Exec("ALTER TABLE access_keys ADD COLUMN last_used_at INTEGER DEFAULT 0;")
+	_, _ = wrapper.db.Exec("ALTER TABLE access_keys ADD COLUMN last_ip TEXT DEFAULT '';")
+	_, _ = wrapper.db.Exec("ALTER TABLE access_keys ADD COLUMN restrictions TEXT DEFAULT '';")
+	// the keys stored before the key_prefix column was added are raw keys, hashed by the migration below
+	_, _ = wrapper.db.Exec("ALTER TABLE access_keys ADD COLUMN key_prefix TEXT DEFAULT NULL;")
+	err = wrapper.migrateRawKeys()
+	if err != nil {
+		return err
+	}
+
 	indexQuery := `CREATE INDEX IF NOT EXISTS idx_access_keys_username ON access_keys(username);`
 	_, err = wrapper.db.Exec(indexQuery)
 	if err != nil {
@@ -154,9 +201,91 @@ func (wrapper *sqliteWrapper) initializeTables() error {
 		return fmt.Errorf("failed to create performance table: %w", err)
 	}
 
+	hashEpochsTable := `
+	CREATE TABLE IF NOT EXISTS hash_epochs (
+		hash TEXT PRIMARY KEY,
+		epoch INTEGER NOT NULL
+	);`
+	_, err = wrapper.db.Exec(hashEpochsTable)
+	if err != nil {
+		return fmt.Errorf("failed to create hash_epochs table: %w", err)
+	}
+
+	epochStartsTable := `
+	CREATE TABLE IF NOT EXISTS epoch_starts (
+		gateway TEXT NOT NULL,
+		shard INTEGER NOT NULL,
+		epoch INTEGER NOT NULL,
+		nonce INTEGER NOT NULL,
+		timestamp INTEGER NOT NULL,
+		PRIMARY KEY (gateway, shard, epoch)
+	);`
+	_, err = wrapper.db.Exec(epochStartsTable)
+	if err != nil {
+		return fmt.Errorf("failed to create epoch_starts table: %w", err)
+	}
+
 	return nil
 }
 
+// migrateRawKeys replaces the raw keys with their hashes and display prefixes. Runs once, the migrated keys having a
+// non-NULL prefix.
+func (wrapper *sqliteWrapper) migrateRawKeys() error {
+	rows, err := wrapper.db.Query(`SELECT key FROM access_keys WHERE key_prefix IS NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to read the raw access keys: %w", err)
+	}
+
+	rawKeys := make([]string, 0)
+	for rows.Next() {
+		var key string
+		err = rows.Scan(&key)
+		if err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to read the raw access keys: %w", err)
+		}
+
+		rawKeys = append(rawKeys, key)
+	}
+	_ = rows.Close()
+	if len(rawKeys) == 0 {
+		return rows.Err()
+	}
+
+	tx, err := wrapper.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `UPDATE access_keys SET key = ?, key_prefix = ? WHERE key = ?`
+	for _, key := range rawKeys {
+		_, err = tx.Exec(query, wrapper.hashKey(key), common.KeyPrefix(key), key)
+		if err != nil {
+			return fmt.Errorf("failed to hash the access key %s: %w", common.AnonymizeKey(key), err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	log.Info("hashed the raw access keys", "num keys", len(rawKeys))
+
+	return nil
+}
+
+// hashKey returns the value stored for the provided key, which is also the key's ID
+func (wrapper *sqliteWrapper) hashKey(key string) string {
+	hasher := hmac.New(sha256.New, wrapper.keysHashKey)
+	_, _ = hasher.Write([]byte(key))
+
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
 func processKey(key string) (string, error) {
 	key = strings.ToLower(key)
 	key = strings.Trim(key, " \t\r\n")
@@ -229,8 +358,9 @@ func (wrapper *sqliteWrapper) RemoveUser(username string) error {
 	return tx.Commit()
 }
 
-// UpdateUser updates the user's details
-func (wrapper *sqliteWrapper) UpdateUser(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool) error {
+// UpdateUser updates the user's details. The user's tier is also replaced if a tier is provided, an empty tier removing
+// the user from its tier.
+func (wrapper *sqliteWrapper) UpdateUser(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, tier *string) error {
 	tx, err := wrapper.db.Begin()
 	if err != nil {
 		return err
@@ -261,16 +391,47 @@ func (wrapper *sqliteWrapper) UpdateUser(username string, password string, isAdm
 		}
 	}
 
+	if tier != nil {
+		query := `UPDATE users SET tier = ? WHERE username = ?`
+		_, err = tx.Exec(query, *tier, username)
+		if err != nil {
+			return fmt.Errorf("failed to set the tier: %w", err)
+		}
+	}
+
 	return tx.Commit()
 }
 
-// AddKey adds a new access key without checking user's credentials (trusted caller)
+// AddKey adds a new access key without checking user's credentials (trusted caller). Only the key's hash and display
+// prefix are stored.
 func (wrapper *sqliteWrapper) AddKey(username string, key string) error {
+	return wrapper.AddKeyWithSettings(username, key, common.KeySettings{})
+}
+
+// AddKeyWithSettings adds a new access key together with its scope, expiry, label and client restrictions, in the same
+// transaction, without checking user's credentials (trusted caller)
+func (wrapper *sqliteWrapper) AddKeyWithSettings(username string, key string, settings common.KeySettings) error {
 	key, err := processKey(key)
 	if err != nil {
 		return err
 	}
 
+	scopeString, err := marshalKeyScope(settings.Scope)
+	if err != nil {
+		return err
+	}
+	restrictionsString := ""
+	if settings.Restrictions != nil {
+		restrictionsString, err = marshalKeyRestrictions(*settings.Restrictions)
+		if err != nil {
+			return err
+		}
+	}
+	label := ""
+	if settings.Label != nil {
+		label = *settings.Label
+	}
+
 	tx, err := wrapper.db.Begin()
 	if err != nil {
 		return err
@@ -279,16 +440,26 @@ func (wrapper *sqliteWrapper) AddKey(username string, key string) error {
 		_ = tx.Rollback()
 	}()
 
-	query := `INSERT INTO access_keys (key, username) VALUES (?, ?)`
-	_, err = tx.Exec(query, key, username)
+	err = wrapper.insertKey(tx, username, key, scopeString, restrictionsString, label, settings.ExpiresAt)
 	if err != nil {
-		return fmt.Errorf("failed to insert key: %w", err)
+		return err
 	}
 
 	return tx.Commit()
 }
 
-// RemoveKey removes the provided access key without checking user's credentials (trusted caller)
+func (wrapper *sqliteWrapper) insertKey(tx *sql.Tx, username string, key string, scopeString string, restrictionsString string, label string, expiresAt int64) error {
+	query := `INSERT INTO access_keys (key, username, key_prefix, scope, restrictions, label, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := tx.Exec(query, wrapper.hashKey(key), username, common.KeyPrefix(key), scopeString, restrictionsString, label, expiresAt, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("failed to insert key: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveKey removes the provided access key, identified by its value or by its ID, without checking user's credentials
+// (trusted caller)
 func (wrapper *sqliteWrapper) RemoveKey(username string, key string) error {
 	key, err := processKey(key)
 	if err != nil {
@@ -303,8 +474,8 @@ func (wrapper *sqliteWrapper) RemoveKey(username string, key string) error {
 		_ = tx.Rollback()
 	}()
 
-	query := `DELETE FROM access_keys WHERE key = ? and username = ?`
-	_, err = tx.Exec(query, strings.ToLower(key), username)
+	query := `DELETE FROM access_keys WHERE key IN (?, ?) and username = ?`
+	_, err = tx.Exec(query, wrapper.hashKey(key), key, username)
 	if err != nil {
 		return fmt.Errorf("failed to remove key: %w", err)
 	}
@@ -312,52 +483,119 @@ func (wrapper *sqliteWrapper) RemoveKey(username string, key string) error {
 	return tx.Commit()
 }
 
-// IsKeyAllowed returns true if the key is allowed to do requests and false otherwise
-func (wrapper *sqliteWrapper) IsKeyAllowed(key string) (string, common.AccountType, error) {
+// GetKeyAccess returns the access details of the provided key without consuming any credits. The expired keys are not
+// allowed.
+func (wrapper *sqliteWrapper) GetKeyAccess(key string) (common.KeyAccess, error) {
 	key, err := processKey(key)
 	if err != nil {
-		return "", "", err
+		return common.KeyAccess{}, err
 	}
 
 	// Get User limits via Key
 	query := `
-		SELECT u.max_requests, u.request_count, u.username, u.is_premium
+		SELECT u.max_requests, u.request_count, u.username, u.is_premium, u.tier, k.scope, k.restrictions, k.expires_at
 		FROM users u
 		JOIN access_keys k ON u.username = k.username
 		WHERE k.key = ?
 	`
 	var maxRequests, requestCount uint64
-	var username string
+	var username, tier, scopeString, restrictionsString string
 	var isPremium bool
+	var expiresAt int64
 
-	err = wrapper.db.QueryRow(query, key).Scan(&maxRequests, &requestCount, &username, &isPremium)
+	keyID := wrapper.hashKey(key)
+	err = wrapper.db.QueryRow(query, keyID).Scan(&maxRequests, &requestCount, &username, &isPremium, &tier, &scopeString, &restrictionsString, &expiresAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", "", fmt.Errorf("the provided key is not allowed (no rows)")
+			return common.KeyAccess{}, fmt.Errorf("the provided key is not allowed (no rows)")
 		}
 
-		return "", "", fmt.Errorf("error querying if is allowed: %w", err)
+		return common.KeyAccess{}, fmt.Errorf("error querying if is allowed: %w", err)
 	}
 
-	userCounter := wrapper.counters.Get(username)
+	if isKeyExpired(expiresAt, time.Now()) {
+		return common.KeyAccess{}, errKeyExpired
+	}
+
+	scope, err := parseKeyScope(scopeString)
+	if err != nil {
+		return common.KeyAccess{}, err
+	}
+	restrictions, err := parseKeyRestrictions(restrictionsString)
+	if err != nil {
+		return common.KeyAccess{}, err
+	}
 
 	// Determine account type return
 	userDetails := &common.UsersDetails{
 		IsPremium:     isPremium,
+		Tier:          tier,
 		MaxRequests:   maxRequests,
-		GlobalCounter: max(userCounter, requestCount),
+		GlobalCounter: max(wrapper.counters.Get(username), requestCount),
 	}
 	common.ProcessUserDetails(userDetails)
-	wrapper.counters.Set(username, userDetails.GlobalCounter+1)
+
+	return common.KeyAccess{
+		KeyID:         keyID,
+		Username:      username,
+		AccountType:   userDetails.ProcessedAccountType,
+		Scope:         scope,
+		Restrictions:  restrictions,
+		GlobalCounter: userDetails.GlobalCounter,
+	}, nil
+}
+
+// ConsumeKeyCredits charges the request done with the provided key with cost credits, on both the user's and the key's
+// counters. The key's last use and the client's IP are kept in memory until the next FlushKeysUsage call.
+func (wrapper *sqliteWrapper) ConsumeKeyCredits(access common.KeyAccess, cost uint64, clientIP string) {
+	wrapper.counters.Set(access.Username, max(wrapper.counters.Get(access.Username), access.GlobalCounter)+cost)
 
 	wrapper.pendingWritesWaitGroup.Add(2)
-	go wrapper.incrementCountersOnUsers(username)
-	go wrapper.incrementCountersOnKeys(key)
+	go wrapper.incrementCountersOnUsers(access.Username, cost)
+	go wrapper.incrementCountersOnKeys(access.KeyID, cost)
+	wrapper.recordKeyUsage(access.KeyID, clientIP)
+}
 
-	return username, userDetails.ProcessedAccountType, nil
+func (wrapper *sqliteWrapper) recordKeyUsage(keyID string, clientIP string) {
+	wrapper.keysUsageMut.Lock()
+	wrapper.keysUsage[keyID] = keyUsage{
+		lastUsedAt: time.Now().Unix(),
+		lastIP:     clientIP,
+	}
+	wrapper.keysUsageMut.Unlock()
 }
 
-func (wrapper *sqliteWrapper) incrementCountersOnUsers(username string) {
+// FlushKeysUsage writes in the DB, in a single transaction, the last use of the keys used since the previous call
+func (wrapper *sqliteWrapper) FlushKeysUsage() error {
+	wrapper.keysUsageMut.Lock()
+	keysUsage := wrapper.keysUsage
+	wrapper.keysUsage = make(map[string]keyUsage)
+	wrapper.keysUsageMut.Unlock()
+
+	if len(keysUsage) == 0 {
+		return nil
+	}
+
+	tx, err := wrapper.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `UPDATE access_keys SET last_used_at = ?, last_ip = ? WHERE key = ?`
+	for keyID, usage := range keysUsage {
+		_, err = tx.Exec(query, usage.lastUsedAt, usage.lastIP, keyID)
+		if err != nil {
+			return fmt.Errorf("failed to write the keys usage: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (wrapper *sqliteWrapper) incrementCountersOnUsers(username string, cost uint64) {
 	tx, err := wrapper.db.Begin()
 	if err != nil {
 		log.Error("error creating transaction (update in users)", "username", username, "error", err)
@@ -367,8 +605,8 @@ func (wrapper *sqliteWrapper) incrementCountersOnUsers(username string) {
 		_ = tx.Rollback()
 	}()
 
-	query := `UPDATE users SET request_count = request_count + 1 WHERE username = ?`
-	_, err = tx.Exec(query, username)
+	query := `UPDATE users SET request_count = request_count + ? WHERE username = ?`
+	_, err = tx.Exec(query, cost, username)
 	if err != nil {
 		log.Error("error updating the request counter (update in users)", "username", username, "error", err)
 	}
@@ -387,9 +625,14 @@ func (wrapper *sqliteWrapper) incrementCountersOnUsers(username string) {
 	wrapper.pendingWritesWaitGroup.Done()
 }
 
-func (wrapper *sqliteWrapper) incrementCountersOnKeys(key string) {
-	query := `UPDATE access_keys SET request_count = request_count + 1 WHERE key = ?`
-	_, err := wrapper.db.Exec(query, key)
+func (wrapper *sqliteWrapper) incrementCountersOnKeys(key string, cost uint64) {
+	// the requests done with a rotated key are in its grace period, so they are also counted separately
+	query := `
+		UPDATE access_keys
+		SET request_count = request_count + ?,
+			grace_request_count = grace_request_count + (CASE WHEN rotated_to != '' THEN ? ELSE 0 END)
+		WHERE key = ?`
+	_, err := wrapper.db.Exec(query, cost, cost, key)
 	if err != nil {
 		log.Error("error updating the request counter (update in keys)", "key", common.AnonymizeKey(key), "error", err)
 	}
@@ -432,10 +675,10 @@ func (wrapper *sqliteWrapper) GetUser(username string) (*common.UsersDetails, er
 }
 
 func (wrapper *sqliteWrapper) getUserDetails(username string) (*common.UsersDetails, error) {
-	query := `SELECT max_requests, request_count, username, hashed_password, is_admin, is_premium, is_active, crypto_payment_id, sc_max_requests FROM users WHERE username = ?`
+	query := `SELECT max_requests, request_count, username, hashed_password, is_admin, is_premium, is_active, crypto_payment_id, sc_max_requests, tier FROM users WHERE username = ?`
 	var details common.UsersDetails
 	var cryptoPaymentID sql.NullInt64
-	err := wrapper.db.QueryRow(query, username).Scan(&details.MaxRequests, &details.GlobalCounter, &details.Username, &details.HashedPassword, &details.IsAdmin, &details.IsPremium, &details.IsActive, &cryptoPaymentID, &details.SCMaxRequests)
+	err := wrapper.db.QueryRow(query, username).Scan(&details.MaxRequests, &details.GlobalCounter, &details.Username, &details.HashedPassword, &details.IsAdmin, &details.IsPremium, &details.IsActive, &cryptoPaymentID, &details.SCMaxRequests, &details.Tier)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("user not found")
@@ -452,20 +695,20 @@ func (wrapper *sqliteWrapper) getUserDetails(username string) (*common.UsersDeta
 	return &details, nil
 }
 
-// GetAllKeys returns all access keys and their details
+// GetAllKeys returns all access keys and their details, mapped by the keys' IDs as the raw keys are not stored
 func (wrapper *sqliteWrapper) GetAllKeys(username string) (map[string]common.AccessKeyDetails, error) {
 	var rows *sql.Rows
 	var err error
 	if username == "" {
 		query := `
-		SELECT k.key, u.max_requests, u.request_count AS global_counter, k.request_count as key_counter, u.username, u.hashed_password, u.is_admin 
+		SELECT k.key, k.key_prefix, u.max_requests, u.request_count AS global_counter, k.request_count as key_counter, u.username, u.hashed_password, u.is_admin, k.scope, k.expires_at, k.rotated_to, k.grace_request_count, k.label, k.created_at, k.last_used_at, k.last_ip, k.restrictions
 		FROM access_keys k
 		JOIN users u ON k.username = u.username
 	`
 		rows, err = wrapper.db.Query(query)
 	} else {
 		query := `
-		SELECT k.key, u.max_requests, u.request_count AS global_counter, k.request_count as key_counter, u.username, u.hashed_password, u.is_admin 
+		SELECT k.key, k.key_prefix, u.max_requests, u.request_count AS global_counter, k.request_count as key_counter, u.username, u.hashed_password, u.is_admin, k.scope, k.expires_at, k.rotated_to, k.grace_request_count, k.label, k.created_at, k.last_used_at, k.last_ip, k.restrictions
 		FROM access_keys k
 		JOIN users u ON k.username = u.username
 		WHERE u.username = ?
@@ -480,18 +723,36 @@ func (wrapper *sqliteWrapper) GetAllKeys(username string) (map[string]common.Acc
 		_ = rows.Close()
 	}()
 
+	wrapper.keysUsageMut.Lock()
+	defer wrapper.keysUsageMut.Unlock()
+
 	result := make(map[string]common.AccessKeyDetails)
 	for rows.Next() {
-		var key string
+		var scopeString, restrictionsString string
 		var details common.AccessKeyDetails
-		err = rows.Scan(&key, &details.MaxRequests, &details.GlobalCounter, &details.KeyCounter, &details.Username, &details.HashedPassword, &details.IsAdmin)
+		err = rows.Scan(&details.ID, &details.KeyPrefix, &details.MaxRequests, &details.GlobalCounter, &details.KeyCounter, &details.Username, &details.HashedPassword, &details.IsAdmin, &scopeString, &details.ExpiresAt, &details.RotatedTo, &details.GraceCounter, &details.Label, &details.CreatedAt, &details.LastUsedAt, &details.LastIP, &restrictionsString)
+		if err != nil {
+			return nil, err
+		}
+
+		details.Scope, err = parseKeyScope(scopeString)
+		if err != nil {
+			return nil, err
+		}
+		details.Restrictions, err = parseKeyRestrictions(restrictionsString)
 		if err != nil {
 			return nil, err
 		}
 
 		details.GlobalCounter = max(details.GlobalCounter, wrapper.counters.Get(details.Username))
+		// the last use not yet written in the DB is the most recent one
+		usage, found := wrapper.keysUsage[details.ID]
+		if found {
+			details.LastUsedAt = usage.lastUsedAt
+			details.LastIP = usage.lastIP
+		}
 
-		result[strings.ToLower(key)] = details
+		result[strings.ToLower(details.ID)] = details
 	}
 	return result, rows.Err()
 }
@@ -499,7 +760,7 @@ func (wrapper *sqliteWrapper) GetAllKeys(username string) (map[string]common.Acc
 // GetAllUsers returns all access keys and their details
 func (wrapper *sqliteWrapper) GetAllUsers() (map[string]common.UsersDetails, error) {
 	query := `
-		SELECT max_requests, request_count, username, hashed_password, is_admin, is_premium, is_active, crypto_payment_id, sc_max_requests
+		SELECT max_requests, request_count, username, hashed_password, is_admin, is_premium, is_active, crypto_payment_id, sc_max_requests, tier
 		FROM users
 	`
 	rows, err := wrapper.db.Query(query)
@@ -514,7 +775,7 @@ func (wrapper *sqliteWrapper) GetAllUsers() (map[string]common.UsersDetails, err
 	for rows.Next() {
 		var details common.UsersDetails
 		var cryptoPaymentID sql.NullInt64
-		err = rows.Scan(&details.MaxRequests, &details.GlobalCounter, &details.Username, &details.HashedPassword, &details.IsAdmin, &details.IsPremium, &details.IsActive, &cryptoPaymentID, &details.SCMaxRequests)
+		err = rows.Scan(&details.MaxRequests, &details.GlobalCounter, &details.Username, &details.HashedPassword, &details.IsAdmin, &details.IsPremium, &details.IsActive, &cryptoPaymentID, &details.SCMaxRequests, &details.Tier)
 		if err != nil {
 			return nil, err
 		}
@@ -718,13 +979,13 @@ func (wrapper *sqliteWrapper) ConfirmEmailChange(token string) (string, error) {
 	}()
 
 	// 1. Find user with this token
-	querySelect := `SELECT username, pending_email, hashed_password, is_admin, max_requests, request_count, is_premium, is_active, crypto_payment_id FROM users WHERE change_email_token = ?`
-	var oldUsername, newEmail, hashedPassword string
+	querySelect := `SELECT username, pending_email, hashed_password, is_admin, max_requests, request_count, is_premium, is_active, crypto_payment_id, tier FROM users WHERE change_email_token = ?`
+	var oldUsername, newEmail, hashedPassword, tier string
 	var isAdmin, isActive, isPremium bool
 	var maxRequests, requestCount uint64
 	var cryptoPaymentID sql.NullInt64
 
-	err = tx.QueryRow(querySelect, token).Scan(&oldUsername, &newEmail, &hashedPassword, &isAdmin, &maxRequests, &requestCount, &isPremium, &isActive, &cryptoPaymentID)
+	err = tx.QueryRow(querySelect, token).Scan(&oldUsername, &newEmail, &hashedPassword, &isAdmin, &maxRequests, &requestCount, &isPremium, &isActive, &cryptoPaymentID, &tier)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return "", fmt.Errorf("invalid or expired token")
@@ -749,10 +1010,10 @@ func (wrapper *sqliteWrapper) ConfirmEmailChange(token string) (string, error) {
 	}
 
 	insertQueryFull := `
-	INSERT INTO users (username, hashed_password, is_admin, max_requests, request_count, is_premium, is_active, activation_token, pending_email, change_email_token, crypto_payment_id) 
-	VALUES (?, ?, ?, ?, ?, ?, ?, '', '', '', ?)
+	INSERT INTO users (username, hashed_password, is_admin, max_requests, request_count, is_premium, is_active, activation_token, pending_email, change_email_token, crypto_payment_id, tier) 
+	VALUES (?, ?, ?, ?, ?, ?, ?, '', '', '', ?, ?)
 	`
-	_, err = tx.Exec(insertQueryFull, newEmail, hashedPassword, isAdmin, maxRequests, requestCount, isPremium, isActive, cryptoPaymentID)
+	_, err = tx.Exec(insertQueryFull, newEmail, hashedPassword, isAdmin, maxRequests, requestCount, isPremium, isActive, cryptoPaymentID, tier)
 	if err != nil {
 		return "", fmt.Errorf("failed to create new user entry: %w", err)
 	}
@@ -806,6 +1067,299 @@ func (wrapper *sqliteWrapper) SetCryptoPaymentID(username string, paymentID uint
 	return tx.Commit()
 }
 
+// SetUserTier assigns the user to the provided subscription tier. An empty tier removes the user from its tier
+func (wrapper *sqliteWrapper) SetUserTier(username string, tier string) error {
+	tx, err := wrapper.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `UPDATE users SET tier = ? WHERE username = ?`
+	result, err := tx.Exec(query, tier, username)
+	if err != nil {
+		return fmt.Errorf("failed to set the tier: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return tx.Commit()
+}
+
+// SetKeyScope replaces the scope of the provided access key, identified by its value or by its ID. An empty scope
+// removes the key's restrictions.
+func (wrapper *sqliteWrapper) SetKeyScope(key string, scope common.KeyScope) error {
+	return wrapper.UpdateKey(key, common.KeyUpdate{Scope: &scope})
+}
+
+// SetKeyExpiry sets the unix timestamp, in seconds, after which the provided access key, identified by its value or by
+// its ID, is no longer allowed. A 0 value removes the key's expiry.
+func (wrapper *sqliteWrapper) SetKeyExpiry(key string, expiresAt int64) error {
+	return wrapper.UpdateKey(key, common.KeyUpdate{ExpiresAt: &expiresAt})
+}
+
+// UpdateKey replaces, in a single statement, the provided scope and expiry of the access key, identified by its value
+// or by its ID. The fields that are not provided are left unchanged.
+func (wrapper *sqliteWrapper) UpdateKey(key string, update common.KeyUpdate) error {
+	key, err := processKey(key)
+	if err != nil {
+		return err
+	}
+
+	assignments := make([]string, 0, 2)
+	args := make([]interface{}, 0, 4)
+	if update.Scope != nil {
+		scopeString, errMarshal := marshalKeyScope(*update.Scope)
+		if errMarshal != nil {
+			return errMarshal
+		}
+		assignments = append(assignments, "scope = ?")
+		args = append(args, scopeString)
+	}
+	if update.ExpiresAt != nil {
+		assignments = append(assignments, "expires_at = ?")
+		args = append(args, *update.ExpiresAt)
+	}
+	if len(assignments) == 0 {
+		return errEmptyKeyUpdate
+	}
+
+	tx, err := wrapper.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `UPDATE access_keys SET ` + strings.Join(assignments, ", ") + ` WHERE key IN (?, ?)`
+	args = append(args, wrapper.hashKey(key), key)
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update the key: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("key not found")
+	}
+
+	return tx.Commit()
+}
+
+// SetKeyLabel sets the label of the user's provided access key, identified by its value or by its ID
+func (wrapper *sqliteWrapper) SetKeyLabel(username string, key string, label string) error {
+	key, err := processKey(key)
+	if err != nil {
+		return err
+	}
+
+	tx, err := wrapper.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `UPDATE access_keys SET label = ? WHERE key IN (?, ?) and username = ?`
+	result, err := tx.Exec(query, label, wrapper.hashKey(key), key, username)
+	if err != nil {
+		return fmt.Errorf("failed to set the key label: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("key not found")
+	}
+
+	return tx.Commit()
+}
+
+// SetKeyRestrictions replaces the client restrictions of the user's provided access key, identified by its value or by
+// its ID. Empty restrictions remove the key's client restrictions.
+func (wrapper *sqliteWrapper) SetKeyRestrictions(username string, key string, restrictions common.KeyRestrictions) error {
+	key, err := processKey(key)
+	if err != nil {
+		return err
+	}
+
+	restrictionsString, err := marshalKeyRestrictions(restrictions)
+	if err != nil {
+		return err
+	}
+
+	tx, err := wrapper.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `UPDATE access_keys SET restrictions = ? WHERE key IN (?, ?) and username = ?`
+	result, err := tx.Exec(query, restrictionsString, wrapper.hashKey(key), key, username)
+	if err != nil {
+		return fmt.Errorf("failed to set the key restrictions: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("key not found")
+	}
+
+	return tx.Commit()
+}
+
+// RotateKey adds newKey, with the provided settings, as the successor of the user's access key, identified by its
+// value or by its ID, in the same transaction. The successor inherits the key's scope, client restrictions and label
+// that are not provided in the settings. The rotated key stays allowed until graceExpiresAt (or its own, earlier,
+// expiry), its requests in the grace period being counted separately. A key can only be rotated once.
+func (wrapper *sqliteWrapper) RotateKey(username string, key string, newKey string, graceExpiresAt int64, settings common.KeySettings) error {
+	key, err := processKey(key)
+	if err != nil {
+		return err
+	}
+	newKey, err = processKey(newKey)
+	if err != nil {
+		return err
+	}
+
+	tx, err := wrapper.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var keyID, scopeString, restrictionsString, rotatedTo, label string
+	query := `SELECT key, scope, restrictions, rotated_to, label FROM access_keys WHERE key IN (?, ?) and username = ?`
+	err = tx.QueryRow(query, wrapper.hashKey(key), key, username).Scan(&keyID, &scopeString, &restrictionsString, &rotatedTo, &label)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("key not found")
+		}
+
+		return fmt.Errorf("error querying the key to rotate: %w", err)
+	}
+	if len(rotatedTo) > 0 {
+		return errKeyAlreadyRotated
+	}
+
+	if !settings.Scope.IsEmpty() {
+		scopeString, err = marshalKeyScope(settings.Scope)
+		if err != nil {
+			return err
+		}
+	}
+	if settings.Restrictions != nil {
+		restrictionsString, err = marshalKeyRestrictions(*settings.Restrictions)
+		if err != nil {
+			return err
+		}
+	}
+	if settings.Label != nil {
+		label = *settings.Label
+	}
+
+	err = wrapper.insertKey(tx, username, newKey, scopeString, restrictionsString, label, settings.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	newKeyID := wrapper.hashKey(newKey)
+	query = `
+		UPDATE access_keys
+		SET rotated_to = ?,
+			expires_at = (CASE WHEN expires_at = 0 OR expires_at > ? THEN ? ELSE expires_at END)
+		WHERE key = ?`
+	_, err = tx.Exec(query, newKeyID, graceExpiresAt, graceExpiresAt, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to rotate the key: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func isKeyExpired(expiresAt int64, now time.Time) bool {
+	return expiresAt > 0 && now.Unix() >= expiresAt
+}
+
+func parseKeyRestrictions(restrictionsString string) (common.KeyRestrictions, error) {
+	restrictions := common.KeyRestrictions{}
+	if len(restrictionsString) == 0 {
+		return restrictions, nil
+	}
+
+	err := json.Unmarshal([]byte(restrictionsString), &restrictions)
+	if err != nil {
+		return common.KeyRestrictions{}, fmt.Errorf("%w: %s", errInvalidKeyRestrictions, err.Error())
+	}
+
+	return restrictions, nil
+}
+
+// marshalKeyRestrictions returns the stored form of the provided restrictions, empty for the restrictions that do not
+// restrict the key
+func marshalKeyRestrictions(restrictions common.KeyRestrictions) (string, error) {
+	if restrictions.IsEmpty() {
+		return "", nil
+	}
+
+	buff, err := json.Marshal(restrictions)
+	if err != nil {
+		return "", err
+	}
+
+	return string(buff), nil
+}
+
+// marshalKeyScope returns the stored form of the provided scope, empty for the scope that does not restrict the key
+func marshalKeyScope(scope common.KeyScope) (string, error) {
+	if scope.IsEmpty() {
+		return "", nil
+	}
+
+	buff, err := json.Marshal(scope)
+	if err != nil {
+		return "", err
+	}
+
+	return string(buff), nil
+}
+
+func parseKeyScope(scopeString string) (common.KeyScope, error) {
+	scope := common.KeyScope{}
+	if len(scopeString) == 0 {
+		return scope, nil
+	}
+
+	err := json.Unmarshal([]byte(scopeString), &scope)
+	if err != nil {
+		return common.KeyScope{}, fmt.Errorf("%w: %s", errInvalidKeyScope, err.Error())
+	}
+
+	return scope, nil
+}
+
 // UpdateMaxRequests updates the user's max requests
 func (wrapper *sqliteWrapper) UpdateMaxRequests(username string, maxRequests uint64) error {
 	tx, err := wrapper.db.Begin()
@@ -870,6 +1424,76 @@ func (wrapper *sqliteWrapper) UpdateUserMaxRequestsFromContract(username string,
 	return tx.Commit()
 }
 
+// GetHashEpoch returns the epoch previously saved for the provided block or transaction hash
+func (wrapper *sqliteWrapper) GetHashEpoch(hash string) (uint64, bool, error) {
+	var epoch uint64
+	query := `SELECT epoch FROM hash_epochs WHERE hash = ?`
+	err := wrapper.db.QueryRow(query, strings.ToLower(hash)).Scan(&epoch)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get hash epoch: %w", err)
+	}
+
+	return epoch, true, nil
+}
+
+// SaveHashEpoch saves the epoch of the provided block or transaction hash
+func (wrapper *sqliteWrapper) SaveHashEpoch(hash string, epoch uint64) error {
+	if len(hash) == 0 {
+		return errHashIsEmpty
+	}
+
+	query := `INSERT OR REPLACE INTO hash_epochs (hash, epoch) VALUES (?, ?)`
+	_, err := wrapper.db.Exec(query, strings.ToLower(hash), epoch)
+	if err != nil {
+		return fmt.Errorf("failed to save hash epoch: %w", err)
+	}
+
+	return nil
+}
+
+// GetEpochStarts returns the epoch starts of the provided shard, learned from all gateways, ordered by epoch
+func (wrapper *sqliteWrapper) GetEpochStarts(shard uint32) ([]common.EpochStartInfo, error) {
+	query := `SELECT gateway, shard, epoch, nonce, timestamp FROM epoch_starts WHERE shard = ? ORDER BY epoch, gateway`
+	rows, err := wrapper.db.Query(query, shard)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get epoch starts: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	result := make([]common.EpochStartInfo, 0)
+	for rows.Next() {
+		var info common.EpochStartInfo
+		err = rows.Scan(&info.Gateway, &info.Shard, &info.Epoch, &info.Nonce, &info.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, info)
+	}
+
+	return result, rows.Err()
+}
+
+// SaveEpochStart saves the epoch start learned from a gateway
+func (wrapper *sqliteWrapper) SaveEpochStart(info common.EpochStartInfo) error {
+	if len(info.Gateway) == 0 {
+		return errGatewayIsEmpty
+	}
+
+	query := `INSERT OR REPLACE INTO epoch_starts (gateway, shard, epoch, nonce, timestamp) VALUES (?, ?, ?, ?, ?)`
+	_, err := wrapper.db.Exec(query, info.Gateway, info.Shard, info.Epoch, info.Nonce, info.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to save epoch start: %w", err)
+	}
+
+	return nil
+}
+
 // Close closes the database connection
 func (wrapper *sqliteWrapper) Close() error {
 	// allow all pending updates to finish before closing the db connection
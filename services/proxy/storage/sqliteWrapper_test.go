@@ -7,13 +7,28 @@ import (
 	"time"
 
 	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/multiversx/mx-chain-core-go/core"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+const testKeysHashKey = "keys hash key"
+
+// useKey checks the provided key, consuming its credits as the access checker does for an allowed request
+func useKey(wrapper *sqliteWrapper, key string, cost uint64, clientIP string) (common.KeyAccess, error) {
+	access, err := wrapper.GetKeyAccess(key)
+	if err != nil {
+		return common.KeyAccess{}, err
+	}
+
+	wrapper.ConsumeKeyCredits(access, cost, clientIP)
+
+	return access, nil
+}
+
 func createTestDB(tb testing.TB) *sqliteWrapper {
 	counters, _ := NewCountersCache(time.Minute)
-	wrapper, err := NewSQLiteWrapper(path.Join(tb.TempDir(), "data", "sqlite.db"), counters)
+	wrapper, err := NewSQLiteWrapper(path.Join(tb.TempDir(), "data", "sqlite.db"), counters, testKeysHashKey)
 	require.NoError(tb, err)
 
 	return wrapper
@@ -27,12 +42,19 @@ func TestNewSQLiteWrapper(t *testing.T) {
 	t.Parallel()
 
 	t.Run("nil counters cache should error", func(t *testing.T) {
-		wrapper, err := NewSQLiteWrapper(path.Join(t.TempDir(), "data", "sqlite.db"), nil)
+		wrapper, err := NewSQLiteWrapper(path.Join(t.TempDir(), "data", "sqlite.db"), nil, testKeysHashKey)
 
 		assert.Equal(t, errNilCountersCache, err)
 		assert.Nil(t, wrapper)
 		assert.True(t, wrapper.IsInterfaceNil())
 	})
+	t.Run("empty keys hash key should error", func(t *testing.T) {
+		counters, _ := NewCountersCache(time.Minute)
+		wrapper, err := NewSQLiteWrapper(path.Join(t.TempDir(), "data", "sqlite.db"), counters, "")
+
+		assert.Equal(t, errEmptyKeysHashKey, err)
+		assert.Nil(t, wrapper)
+	})
 	t.Run("should create new wrapper and db file", func(t *testing.T) {
 		wrapper := createTestDB(t)
 		defer closeWrapper(wrapper)
@@ -182,7 +204,7 @@ func TestSQLiteWrapper_AddKey(t *testing.T) {
 			JOIN access_keys k ON u.username = k.username
 			WHERE k.key = ?`
 
-		err = wrapper.db.QueryRow(query, "key0").
+		err = wrapper.db.QueryRow(query, wrapper.hashKey("key0")).
 			Scan(&maxRequests, &username, &hashedPassword, &isAdmin)
 		assert.NoError(t, err)
 		assert.Equal(t, uint64(0), maxRequests)
@@ -216,7 +238,7 @@ func TestSQLiteWrapper_AddKey(t *testing.T) {
 			JOIN access_keys k ON u.username = k.username
 			WHERE k.key = ?`
 
-		err = wrapper.db.QueryRow(query, "key1").
+		err = wrapper.db.QueryRow(query, wrapper.hashKey("key1")).
 			Scan(&maxRequests, &username, &hashedPassword, &isAdmin)
 		assert.NoError(t, err)
 		assert.Equal(t, uint64(0), maxRequests)
@@ -224,6 +246,77 @@ func TestSQLiteWrapper_AddKey(t *testing.T) {
 		assert.NotEmpty(t, hashedPassword)
 		assert.Equal(t, false, isAdmin)
 	})
+
+	t.Run("should store only the key's hash and prefix", func(t *testing.T) {
+		err := wrapper.AddKey("user", "KeY-hashed-at-rest")
+		assert.NoError(t, err)
+
+		var count int
+		err = wrapper.db.QueryRow("SELECT count(*) FROM access_keys WHERE key = ?", "key-hashed-at-rest").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		var keyPrefix string
+		err = wrapper.db.QueryRow("SELECT key_prefix FROM access_keys WHERE key = ?", wrapper.hashKey("key-hashed-at-rest")).Scan(&keyPrefix)
+		assert.NoError(t, err)
+		assert.Equal(t, "key-", keyPrefix)
+	})
+}
+
+func TestSQLiteWrapper_AddKeyWithSettings(t *testing.T) {
+	t.Parallel()
+
+	wrapper := createTestDB(t)
+	defer closeWrapper(wrapper)
+
+	_ = wrapper.AddUser("user", "pass", false, 0, true, true, "")
+
+	label := "indexer"
+	settings := common.KeySettings{
+		Scope:        common.KeyScope{Methods: []string{"GET"}},
+		ExpiresAt:    time.Now().Add(time.Hour).Unix(),
+		Label:        &label,
+		Restrictions: &common.KeyRestrictions{AllowedCIDRs: []string{"10.0.0.0/8"}},
+	}
+
+	t.Run("should add the key together with its settings", func(t *testing.T) {
+		err := wrapper.AddKeyWithSettings("user", "KEY_settings", settings)
+		require.NoError(t, err)
+
+		keys, err := wrapper.GetAllKeys("user")
+		require.NoError(t, err)
+		details := keys[wrapper.hashKey("key_settings")]
+		assert.Equal(t, settings.Scope, details.Scope)
+		assert.Equal(t, settings.ExpiresAt, details.ExpiresAt)
+		assert.Equal(t, label, details.Label)
+		assert.Equal(t, *settings.Restrictions, details.Restrictions)
+		assert.NotZero(t, details.CreatedAt)
+	})
+
+	t.Run("failed insert should not change the existing key", func(t *testing.T) {
+		err := wrapper.AddKey("user", "key_plain")
+		require.NoError(t, err)
+
+		err = wrapper.AddKeyWithSettings("user", "key_plain", settings)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to insert key")
+
+		keys, err := wrapper.GetAllKeys("user")
+		require.NoError(t, err)
+		details := keys[wrapper.hashKey("key_plain")]
+		assert.True(t, details.Scope.IsEmpty())
+		assert.Zero(t, details.ExpiresAt)
+		assert.Empty(t, details.Label)
+		assert.True(t, details.Restrictions.IsEmpty())
+	})
+
+	t.Run("failed insert for an unknown user should not add the key", func(t *testing.T) {
+		err := wrapper.AddKeyWithSettings("unknown", "key_orphan", settings)
+		assert.Error(t, err)
+
+		_, err = wrapper.GetKeyAccess("key_orphan")
+		assert.Error(t, err)
+	})
 }
 
 func TestSQLiteWrapper_RemoveKey(t *testing.T) {
@@ -255,7 +348,7 @@ func TestSQLiteWrapper_RemoveKey(t *testing.T) {
 		assert.NoError(t, err)
 
 		var count int
-		err = wrapper.db.QueryRow("SELECT count(*) FROM access_keys WHERE key = ?", "key1-user1").Scan(&count)
+		err = wrapper.db.QueryRow("SELECT count(*) FROM access_keys WHERE key = ?", wrapper.hashKey("key1-user1")).Scan(&count)
 		assert.NoError(t, err)
 		assert.Equal(t, 1, count)
 	})
@@ -265,7 +358,7 @@ func TestSQLiteWrapper_RemoveKey(t *testing.T) {
 		assert.NoError(t, err)
 
 		var count int
-		err = wrapper.db.QueryRow("SELECT count(*) FROM access_keys WHERE key = ?", "key1-user1").Scan(&count)
+		err = wrapper.db.QueryRow("SELECT count(*) FROM access_keys WHERE key = ?", wrapper.hashKey("key1-user1")).Scan(&count)
 		assert.NoError(t, err)
 		assert.Equal(t, 0, count)
 	})
@@ -274,25 +367,72 @@ func TestSQLiteWrapper_RemoveKey(t *testing.T) {
 		err := wrapper.RemoveKey("user1", "non-existent")
 		assert.NoError(t, err)
 	})
+
+	t.Run("should remove key by its ID", func(t *testing.T) {
+		_ = wrapper.AddKey("user1", "key2-user1")
+
+		err := wrapper.RemoveKey("user1", wrapper.hashKey("key2-user1"))
+		assert.NoError(t, err)
+
+		var count int
+		err = wrapper.db.QueryRow("SELECT count(*) FROM access_keys WHERE key = ?", wrapper.hashKey("key2-user1")).Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
 }
 
-func TestSQLiteWrapper_IsKeyAllowed(t *testing.T) {
+func TestSQLiteWrapper_MigrateRawKeys(t *testing.T) {
+	t.Parallel()
+
+	dbPath := path.Join(t.TempDir(), "data", "sqlite.db")
+	counters, _ := NewCountersCache(time.Minute)
+	wrapper, err := NewSQLiteWrapper(dbPath, counters, testKeysHashKey)
+	require.NoError(t, err)
+
+	_ = wrapper.AddUser("user", "pass", false, 0, true, true, "")
+	_ = wrapper.AddKey("user", "key-hashed")
+	// simulate a key stored before the keys were hashed
+	_, err = wrapper.db.Exec(`INSERT INTO access_keys (key, username, key_prefix) VALUES (?, ?, NULL)`, "key-legacy", "user")
+	require.NoError(t, err)
+	_ = wrapper.Close()
+
+	// reopening the DB twice should migrate the raw key only once
+	for i := 0; i < 2; i++ {
+		wrapper, err = NewSQLiteWrapper(dbPath, counters, testKeysHashKey)
+		require.NoError(t, err)
+
+		keys, errGet := wrapper.GetAllKeys("user")
+		require.NoError(t, errGet)
+		assert.Len(t, keys, 2)
+		assert.Equal(t, common.KeyPrefix("key-legacy"), keys[wrapper.hashKey("key-legacy")].KeyPrefix)
+		assert.Equal(t, common.KeyPrefix("key-hashed"), keys[wrapper.hashKey("key-hashed")].KeyPrefix)
+
+		_, errAllowed := useKey(wrapper, "key-legacy", 1, "")
+		assert.NoError(t, errAllowed)
+		_, errAllowed = useKey(wrapper, "key-hashed", 1, "")
+		assert.NoError(t, errAllowed)
+
+		_ = wrapper.Close()
+	}
+}
+
+func TestSQLiteWrapper_GetKeyAccess(t *testing.T) {
 	t.Parallel()
 
 	wrapper := createTestDB(t)
 	defer closeWrapper(wrapper)
 
 	t.Run("key is empty", func(t *testing.T) {
-		_, _, err := wrapper.IsKeyAllowed("   ")
+		_, err := useKey(wrapper, "   ", 1, "")
 		assert.ErrorIs(t, err, errKeyIsEmpty)
 
-		_, _, err = wrapper.IsKeyAllowed("")
+		_, err = useKey(wrapper, "", 1, "")
 		assert.ErrorIs(t, err, errKeyIsEmpty)
 
-		_, _, err = wrapper.IsKeyAllowed("\n")
+		_, err = useKey(wrapper, "\n", 1, "")
 		assert.ErrorIs(t, err, errKeyIsEmpty)
 
-		_, _, err = wrapper.IsKeyAllowed("\t")
+		_, err = useKey(wrapper, "\t", 1, "")
 		assert.ErrorIs(t, err, errKeyIsEmpty)
 	})
 
@@ -301,11 +441,11 @@ func TestSQLiteWrapper_IsKeyAllowed(t *testing.T) {
 		_ = wrapper.AddKey("admin1", "kEy1")
 
 		// First request
-		_, _, err := wrapper.IsKeyAllowed("keY1")
+		_, err := useKey(wrapper, "keY1", 1, "")
 		assert.NoError(t, err)
 
 		// Second request
-		_, _, err = wrapper.IsKeyAllowed("keY1")
+		_, err = useKey(wrapper, "keY1", 1, "")
 		assert.NoError(t, err)
 
 		assert.Equal(t, uint64(2), wrapper.GetCacheCounterForUser("admin1")) // the counter should be up to date already
@@ -317,7 +457,7 @@ func TestSQLiteWrapper_IsKeyAllowed(t *testing.T) {
 		err = wrapper.db.QueryRow(`SELECT u.request_count global_counter, k.request_count as key_counter 
 	FROM users u 
     JOIN access_keys k ON u.username = k.username 
-	WHERE k.key = ?`, "key1").Scan(&globalCounter, &keyCounter)
+	WHERE k.key = ?`, wrapper.hashKey("key1")).Scan(&globalCounter, &keyCounter)
 		assert.NoError(t, err)
 		assert.Equal(t, uint64(2), globalCounter)
 		assert.Equal(t, uint64(2), keyCounter)
@@ -328,11 +468,11 @@ func TestSQLiteWrapper_IsKeyAllowed(t *testing.T) {
 		_ = wrapper.AddKey("admin2", "kEy2")
 
 		// First request - ok
-		_, _, err := wrapper.IsKeyAllowed("keY2")
+		_, err := useKey(wrapper, "keY2", 1, "")
 		assert.NoError(t, err)
 
 		// Second request - still ok
-		_, _, err = wrapper.IsKeyAllowed("keY2")
+		_, err = useKey(wrapper, "keY2", 1, "")
 		assert.NoError(t, err)
 
 		assert.Equal(t, uint64(2), wrapper.GetCacheCounterForUser("admin2")) // the counter should be up to date already
@@ -344,13 +484,13 @@ func TestSQLiteWrapper_IsKeyAllowed(t *testing.T) {
 		err = wrapper.db.QueryRow(`SELECT u.request_count global_counter, k.request_count as key_counter 
 	FROM users u 
     JOIN access_keys k ON u.username = k.username 
-	WHERE k.key = ?`, "key2").Scan(&globalCounter, &keyCounter)
+	WHERE k.key = ?`, wrapper.hashKey("key2")).Scan(&globalCounter, &keyCounter)
 		assert.NoError(t, err)
 		assert.Equal(t, uint64(2), globalCounter)
 		assert.Equal(t, uint64(2), keyCounter)
 
 		// Third request - still ok
-		_, _, err = wrapper.IsKeyAllowed("keY2")
+		_, err = useKey(wrapper, "keY2", 1, "")
 		assert.NoError(t, err)
 
 		assert.Equal(t, uint64(3), wrapper.GetCacheCounterForUser("admin2")) // the counter should be up to date already
@@ -360,18 +500,73 @@ func TestSQLiteWrapper_IsKeyAllowed(t *testing.T) {
 		err = wrapper.db.QueryRow(`SELECT u.request_count global_counter, k.request_count as key_counter 
 	FROM users u 
     JOIN access_keys k ON u.username = k.username 
-	WHERE k.key = ?`, "key2").Scan(&globalCounter, &keyCounter)
+	WHERE k.key = ?`, wrapper.hashKey("key2")).Scan(&globalCounter, &keyCounter)
 		assert.NoError(t, err)
 		assert.Equal(t, uint64(3), globalCounter)
 		assert.Equal(t, uint64(3), keyCounter)
 	})
 
+	t.Run("should consume the provided cost", func(t *testing.T) {
+		_ = wrapper.AddUser("admin4", "pass", true, 100, false, true, "")
+		_ = wrapper.AddKey("admin4", "kEy4")
+
+		access, err := useKey(wrapper, "keY4", 60, "")
+		assert.NoError(t, err)
+		assert.Equal(t, common.PremiumAccountType, access.AccountType)
+
+		access, err = useKey(wrapper, "keY4", 60, "")
+		assert.NoError(t, err)
+		assert.Equal(t, common.PremiumAccountType, access.AccountType)
+
+		assert.Equal(t, uint64(120), wrapper.GetCacheCounterForUser("admin4")) // the counter should be up to date already
+		time.Sleep(time.Second * 2)                                            // allow async counters write
+
+		// Verify count
+		var globalCounter uint64
+		var keyCounter uint64
+		err = wrapper.db.QueryRow(`SELECT u.request_count global_counter, k.request_count as key_counter 
+	FROM users u 
+    JOIN access_keys k ON u.username = k.username 
+	WHERE k.key = ?`, wrapper.hashKey("key4")).Scan(&globalCounter, &keyCounter)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(120), globalCounter)
+		assert.Equal(t, uint64(120), keyCounter)
+
+		// the credits are depleted
+		access, err = useKey(wrapper, "keY4", 1, "")
+		assert.NoError(t, err)
+		assert.Equal(t, common.FreeAccountType, access.AccountType)
+	})
+
+	t.Run("should not consume credits nor record the use without ConsumeKeyCredits", func(t *testing.T) {
+		err := wrapper.AddUser("admin5", "pass", true, 100, false, true, "")
+		require.NoError(t, err)
+		err = wrapper.AddKey("admin5", "kEy5")
+		require.NoError(t, err)
+
+		access, err := wrapper.GetKeyAccess("keY5")
+		assert.NoError(t, err)
+		assert.Equal(t, "admin5", access.Username)
+		assert.Equal(t, wrapper.hashKey("key5"), access.KeyID)
+
+		assert.Zero(t, wrapper.GetCacheCounterForUser("admin5"))
+		time.Sleep(time.Second) // allow async counters write, if any
+
+		keys, err := wrapper.GetAllKeys("admin5")
+		require.NoError(t, err)
+		details := keys[wrapper.hashKey("key5")]
+		assert.Zero(t, details.KeyCounter)
+		assert.Zero(t, details.GlobalCounter)
+		assert.Zero(t, details.LastUsedAt)
+		assert.Empty(t, details.LastIP)
+	})
+
 	t.Run("should allow unlimited requests if max_requests is 0", func(t *testing.T) {
 		_ = wrapper.AddUser("admin3", "pass", true, 0, true, true, "")
 		_ = wrapper.AddKey("admin3", "kEy3")
 
 		for i := 0; i < 5000; i++ {
-			_, _, err := wrapper.IsKeyAllowed("keY3")
+			_, err := useKey(wrapper, "keY3", 1, "")
 			assert.NoError(t, err)
 		}
 
@@ -379,7 +574,7 @@ func TestSQLiteWrapper_IsKeyAllowed(t *testing.T) {
 	})
 
 	t.Run("should return error for non-existent key", func(t *testing.T) {
-		_, _, err := wrapper.IsKeyAllowed("unknown")
+		_, err := useKey(wrapper, "unknown", 1, "")
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "no rows")
 	})
@@ -408,24 +603,24 @@ func TestSQLiteWrapper_GetAllKeys(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Len(t, keys, 2)
 
-		assert.Equal(t, uint64(100), keys["key1-user1"].MaxRequests)
-		assert.Equal(t, "user1", keys["key1-user1"].Username)
-		assert.NotEmpty(t, keys["key1-user1"].HashedPassword)
-		assert.False(t, keys["key1-user1"].IsAdmin)
+		assert.Equal(t, uint64(100), keys[wrapper.hashKey("key1-user1")].MaxRequests)
+		assert.Equal(t, "user1", keys[wrapper.hashKey("key1-user1")].Username)
+		assert.NotEmpty(t, keys[wrapper.hashKey("key1-user1")].HashedPassword)
+		assert.False(t, keys[wrapper.hashKey("key1-user1")].IsAdmin)
 
-		assert.Equal(t, uint64(100), keys["key2-user1"].MaxRequests)
-		assert.Equal(t, "user1", keys["key2-user1"].Username)
-		assert.NotEmpty(t, keys["key2-user1"].HashedPassword)
-		assert.False(t, keys["key2-user1"].IsAdmin)
+		assert.Equal(t, uint64(100), keys[wrapper.hashKey("key2-user1")].MaxRequests)
+		assert.Equal(t, "user1", keys[wrapper.hashKey("key2-user1")].Username)
+		assert.NotEmpty(t, keys[wrapper.hashKey("key2-user1")].HashedPassword)
+		assert.False(t, keys[wrapper.hashKey("key2-user1")].IsAdmin)
 
 		keys, err = wrapper.GetAllKeys("user2")
 		assert.NoError(t, err)
 		assert.Len(t, keys, 1)
 
-		assert.Equal(t, uint64(0), keys["key1-user2"].MaxRequests)
-		assert.Equal(t, "user2", keys["key1-user2"].Username)
-		assert.NotEmpty(t, keys["key1-user2"].HashedPassword)
-		assert.False(t, keys["key1-user2"].IsAdmin)
+		assert.Equal(t, uint64(0), keys[wrapper.hashKey("key1-user2")].MaxRequests)
+		assert.Equal(t, "user2", keys[wrapper.hashKey("key1-user2")].Username)
+		assert.NotEmpty(t, keys[wrapper.hashKey("key1-user2")].HashedPassword)
+		assert.False(t, keys[wrapper.hashKey("key1-user2")].IsAdmin)
 
 		keys, err = wrapper.GetAllKeys("admin")
 		assert.NoError(t, err)
@@ -446,20 +641,20 @@ func TestSQLiteWrapper_GetAllKeys(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Len(t, keys, 3)
 
-		assert.Equal(t, uint64(100), keys["key1-user1"].MaxRequests)
-		assert.Equal(t, "user1", keys["key1-user1"].Username)
-		assert.NotEmpty(t, keys["key1-user1"].HashedPassword)
-		assert.False(t, keys["key1-user1"].IsAdmin)
+		assert.Equal(t, uint64(100), keys[wrapper.hashKey("key1-user1")].MaxRequests)
+		assert.Equal(t, "user1", keys[wrapper.hashKey("key1-user1")].Username)
+		assert.NotEmpty(t, keys[wrapper.hashKey("key1-user1")].HashedPassword)
+		assert.False(t, keys[wrapper.hashKey("key1-user1")].IsAdmin)
 
-		assert.Equal(t, uint64(100), keys["key2-user1"].MaxRequests)
-		assert.Equal(t, "user1", keys["key2-user1"].Username)
-		assert.NotEmpty(t, keys["key2-user1"].HashedPassword)
-		assert.False(t, keys["key2-user1"].IsAdmin)
+		assert.Equal(t, uint64(100), keys[wrapper.hashKey("key2-user1")].MaxRequests)
+		assert.Equal(t, "user1", keys[wrapper.hashKey("key2-user1")].Username)
+		assert.NotEmpty(t, keys[wrapper.hashKey("key2-user1")].HashedPassword)
+		assert.False(t, keys[wrapper.hashKey("key2-user1")].IsAdmin)
 
-		assert.Equal(t, uint64(0), keys["key1-user2"].MaxRequests)
-		assert.Equal(t, "user2", keys["key1-user2"].Username)
-		assert.NotEmpty(t, keys["key1-user2"].HashedPassword)
-		assert.False(t, keys["key1-user2"].IsAdmin)
+		assert.Equal(t, uint64(0), keys[wrapper.hashKey("key1-user2")].MaxRequests)
+		assert.Equal(t, "user2", keys[wrapper.hashKey("key1-user2")].Username)
+		assert.NotEmpty(t, keys[wrapper.hashKey("key1-user2")].HashedPassword)
+		assert.False(t, keys[wrapper.hashKey("key1-user2")].IsAdmin)
 	})
 
 	t.Run("should return empty map if no keys", func(t *testing.T) {
@@ -592,7 +787,7 @@ func TestSQLiteWrapper_UpdateUser(t *testing.T) {
 	require.NoError(t, err)
 
 	t.Run("should update details without password", func(t *testing.T) {
-		err = wrapper.UpdateUser(username, "", true, 1000, true)
+		err = wrapper.UpdateUser(username, "", true, 1000, true, nil)
 		assert.NoError(t, err)
 
 		// Verify Update
@@ -604,7 +799,7 @@ func TestSQLiteWrapper_UpdateUser(t *testing.T) {
 
 	t.Run("should update details with new password", func(t *testing.T) {
 		newPass := "newPass456"
-		err = wrapper.UpdateUser(username, newPass, false, 2000, false)
+		err = wrapper.UpdateUser(username, newPass, false, 2000, false, nil)
 		assert.NoError(t, err)
 
 		// Verify Old Password Fails
@@ -620,7 +815,7 @@ func TestSQLiteWrapper_UpdateUser(t *testing.T) {
 
 	t.Run("should fail on long password", func(t *testing.T) {
 		longPass := strings.Repeat("a", 73)
-		err = wrapper.UpdateUser(username, longPass, false, 2000, false)
+		err = wrapper.UpdateUser(username, longPass, false, 2000, false, nil)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "password is too long")
 	})
@@ -631,7 +826,7 @@ func TestSQLiteWrapper_UpdateUser(t *testing.T) {
 		require.NoError(t, err)
 
 		// Update to Premium with 0 max requests
-		err = wrapper.UpdateUser(regularUser, "", false, 0, true)
+		err = wrapper.UpdateUser(regularUser, "", false, 0, true, nil)
 		require.NoError(t, err)
 
 		// Verify with GetUser
@@ -654,12 +849,12 @@ func TestSQLiteWrapper_UpdateUser(t *testing.T) {
 		require.NoError(t, err)
 
 		// create 2 requests:
-		_, tier, errCheck := wrapper.IsKeyAllowed(key)
-		assert.Equal(t, common.PremiumAccountType, tier)
+		access, errCheck := useKey(wrapper, key, 1, "")
+		assert.Equal(t, common.PremiumAccountType, access.AccountType)
 		assert.NoError(t, errCheck)
 
-		_, tier, errCheck = wrapper.IsKeyAllowed(key)
-		assert.Equal(t, common.PremiumAccountType, tier)
+		access, errCheck = useKey(wrapper, key, 1, "")
+		assert.Equal(t, common.PremiumAccountType, access.AccountType)
 		assert.NoError(t, errCheck)
 
 		// Verify with GetUser
@@ -669,6 +864,39 @@ func TestSQLiteWrapper_UpdateUser(t *testing.T) {
 		assert.Equal(t, uint64(2), details.GlobalCounter)
 		assert.Equal(t, common.FreeAccountType, details.ProcessedAccountType)
 	})
+
+	t.Run("should keep the tier if not provided", func(t *testing.T) {
+		tieredUser := "tiered_user_test"
+		err = wrapper.AddUser(tieredUser, "pass", false, 0, true, true, "")
+		require.NoError(t, err)
+		err = wrapper.SetUserTier(tieredUser, "gold")
+		require.NoError(t, err)
+
+		err = wrapper.UpdateUser(tieredUser, "", true, 0, true, nil)
+		require.NoError(t, err)
+
+		details, errGet := wrapper.GetUser(tieredUser)
+		require.NoError(t, errGet)
+		assert.Equal(t, "gold", details.Tier)
+		assert.True(t, details.IsAdmin)
+
+		emptyTier := ""
+		err = wrapper.UpdateUser(tieredUser, "", true, 0, true, &emptyTier)
+		require.NoError(t, err)
+
+		details, errGet = wrapper.GetUser(tieredUser)
+		require.NoError(t, errGet)
+		assert.Empty(t, details.Tier)
+
+		silverTier := "silver"
+		err = wrapper.UpdateUser(tieredUser, "", false, 0, true, &silverTier)
+		require.NoError(t, err)
+
+		details, errGet = wrapper.GetUser(tieredUser)
+		require.NoError(t, errGet)
+		assert.Equal(t, "silver", details.Tier)
+		assert.False(t, details.IsAdmin)
+	})
 }
 
 func TestSqliteWrapper_CheckUserCredentials(t *testing.T) {
@@ -713,7 +941,7 @@ func TestSqliteWrapper_CheckUserCredentials(t *testing.T) {
 	})
 }
 
-func BenchmarkSQLiteWrapper_IsKeyAllowed(b *testing.B) {
+func BenchmarkSQLiteWrapper_GetKeyAccess(b *testing.B) {
 	wrapper := createTestDB(b)
 	defer closeWrapper(wrapper)
 	_ = wrapper.AddUser("admin3", "pass", true, 0, true, true, "")
@@ -721,7 +949,7 @@ func BenchmarkSQLiteWrapper_IsKeyAllowed(b *testing.B) {
 
 	for i := 0; i < b.N; i++ {
 		b.StartTimer()
-		_, _, err := wrapper.IsKeyAllowed("keY3")
+		_, err := useKey(wrapper, "keY3", 1, "")
 		b.StopTimer()
 		assert.NoError(b, err)
 	}
@@ -916,6 +1144,8 @@ func TestSqliteWrapper_RequestAndConfirmEmailChange(t *testing.T) {
 		initialEmail := username
 		err := wrapper.AddUser(username, "pass", false, 100, false, true, "")
 		require.NoError(t, err)
+		err = wrapper.SetUserTier(username, "gold")
+		require.NoError(t, err)
 
 		// Add a key to verify migration
 		err = wrapper.AddKey(username, "key_migration_test")
@@ -949,12 +1179,13 @@ func TestSqliteWrapper_RequestAndConfirmEmailChange(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, newEmail, newUser.Username)
 		assert.Equal(t, uint64(100), newUser.MaxRequests)
+		assert.Equal(t, "gold", newUser.Tier)
 
 		// Verify Access Key Migrated
 		keys, err := wrapper.GetAllKeys(newEmail)
 		assert.NoError(t, err)
 		assert.Len(t, keys, 1)
-		_, exists := keys["key_migration_test"]
+		_, exists := keys[wrapper.hashKey("key_migration_test")]
 		assert.True(t, exists)
 	})
 
@@ -976,6 +1207,55 @@ func TestSqliteWrapper_RequestAndConfirmEmailChange(t *testing.T) {
 	})
 }
 
+func TestSQLiteWrapper_SetUserTier(t *testing.T) {
+	t.Parallel()
+
+	wrapper := createTestDB(t)
+	defer closeWrapper(wrapper)
+
+	t.Run("should set the tier", func(t *testing.T) {
+		username := "user_tier"
+		err := wrapper.AddUser(username, "pass", false, 100, false, true, "")
+		require.NoError(t, err)
+		err = wrapper.AddKey(username, "key_tier")
+		require.NoError(t, err)
+
+		user, err := wrapper.GetUser(username)
+		require.NoError(t, err)
+		assert.Equal(t, "", user.Tier)
+		assert.Equal(t, common.PremiumAccountType, user.ProcessedAccountType)
+
+		err = wrapper.SetUserTier(username, "gold")
+		assert.NoError(t, err)
+
+		user, err = wrapper.GetUser(username)
+		require.NoError(t, err)
+		assert.Equal(t, "gold", user.Tier)
+		assert.Equal(t, common.AccountType("gold"), user.ProcessedAccountType)
+
+		users, err := wrapper.GetAllUsers()
+		require.NoError(t, err)
+		assert.Equal(t, "gold", users[username].Tier)
+
+		access, err := useKey(wrapper, "key_tier", 1, "")
+		assert.NoError(t, err)
+		assert.Equal(t, common.AccountType("gold"), access.AccountType)
+
+		err = wrapper.SetUserTier(username, "")
+		assert.NoError(t, err)
+
+		access, err = useKey(wrapper, "key_tier", 1, "")
+		assert.NoError(t, err)
+		assert.Equal(t, common.PremiumAccountType, access.AccountType)
+	})
+
+	t.Run("should error if user not found", func(t *testing.T) {
+		err := wrapper.SetUserTier("non_existent", "gold")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "user not found")
+	})
+}
+
 func TestSqliteWrapper_UpdateMaxRequests(t *testing.T) {
 	t.Parallel()
 
@@ -1076,3 +1356,498 @@ func TestSQLiteWrapper_UpdateUserMaxRequestsFromContract(t *testing.T) {
 		assert.Contains(t, err.Error(), "user not found")
 	})
 }
+
+func TestSQLiteWrapper_HashEpochs(t *testing.T) {
+	t.Parallel()
+
+	wrapper := createTestDB(t)
+	defer closeWrapper(wrapper)
+
+	t.Run("empty hash should error", func(t *testing.T) {
+		err := wrapper.SaveHashEpoch("", 1)
+		assert.Equal(t, errHashIsEmpty, err)
+	})
+
+	t.Run("unknown hash should not be found", func(t *testing.T) {
+		epoch, found, err := wrapper.GetHashEpoch("aabb")
+		assert.Nil(t, err)
+		assert.False(t, found)
+		assert.Zero(t, epoch)
+	})
+
+	t.Run("should save and get", func(t *testing.T) {
+		err := wrapper.SaveHashEpoch("AABBCC", 1400)
+		assert.Nil(t, err)
+
+		epoch, found, err := wrapper.GetHashEpoch("aabbcc")
+		assert.Nil(t, err)
+		assert.True(t, found)
+		assert.Equal(t, uint64(1400), epoch)
+
+		err = wrapper.SaveHashEpoch("aabbcc", 1401)
+		assert.Nil(t, err)
+
+		epoch, found, err = wrapper.GetHashEpoch("AABBCC")
+		assert.Nil(t, err)
+		assert.True(t, found)
+		assert.Equal(t, uint64(1401), epoch)
+	})
+}
+
+func TestSQLiteWrapper_EpochStarts(t *testing.T) {
+	t.Parallel()
+
+	wrapper := createTestDB(t)
+	defer closeWrapper(wrapper)
+
+	t.Run("empty gateway should error", func(t *testing.T) {
+		err := wrapper.SaveEpochStart(common.EpochStartInfo{Epoch: 1})
+		assert.Equal(t, errGatewayIsEmpty, err)
+	})
+
+	t.Run("should save and get", func(t *testing.T) {
+		infos, err := wrapper.GetEpochStarts(core.MetachainShardId)
+		assert.Nil(t, err)
+		assert.Empty(t, infos)
+
+		epoch2 := common.EpochStartInfo{Gateway: "gw-b", Shard: core.MetachainShardId, Epoch: 2, Nonce: 28000, Timestamp: 1700086400}
+		epoch1 := common.EpochStartInfo{Gateway: "gw-a", Shard: core.MetachainShardId, Epoch: 1, Nonce: 14000, Timestamp: 1700000000}
+		otherShard := common.EpochStartInfo{Gateway: "gw-a", Shard: 0, Epoch: 1, Nonce: 14010, Timestamp: 1700000006}
+		for _, info := range []common.EpochStartInfo{epoch2, epoch1, otherShard} {
+			err = wrapper.SaveEpochStart(info)
+			assert.Nil(t, err)
+		}
+
+		infos, err = wrapper.GetEpochStarts(core.MetachainShardId)
+		assert.Nil(t, err)
+		assert.Equal(t, []common.EpochStartInfo{epoch1, epoch2}, infos)
+
+		epoch1.Nonce = 14001
+		err = wrapper.SaveEpochStart(epoch1)
+		assert.Nil(t, err)
+
+		infos, err = wrapper.GetEpochStarts(core.MetachainShardId)
+		assert.Nil(t, err)
+		assert.Equal(t, []common.EpochStartInfo{epoch1, epoch2}, infos)
+
+		infos, err = wrapper.GetEpochStarts(0)
+		assert.Nil(t, err)
+		assert.Equal(t, []common.EpochStartInfo{otherShard}, infos)
+	})
+}
+
+func TestSQLiteWrapper_SetKeyScope(t *testing.T) {
+	t.Parallel()
+
+	wrapper := createTestDB(t)
+	defer closeWrapper(wrapper)
+
+	t.Run("should set the scope", func(t *testing.T) {
+		username := "partner"
+		err := wrapper.AddUser(username, "pass", false, 0, true, true, "")
+		require.NoError(t, err)
+		err = wrapper.AddKey(username, "key_scope")
+		require.NoError(t, err)
+
+		access, err := useKey(wrapper, "key_scope", 1, "")
+		assert.NoError(t, err)
+		assert.True(t, access.Scope.IsEmpty())
+
+		expectedScope := common.KeyScope{
+			Routes:     []string{"/address", "/network"},
+			Methods:    []string{"GET"},
+			EpochStart: "0",
+			EpochEnd:   "1000",
+		}
+		err = wrapper.SetKeyScope("KEY_scope", expectedScope)
+		assert.NoError(t, err)
+
+		access, err = useKey(wrapper, "key_scope", 1, "")
+		assert.NoError(t, err)
+		assert.Equal(t, expectedScope, access.Scope)
+
+		keys, err := wrapper.GetAllKeys(username)
+		require.NoError(t, err)
+		assert.Equal(t, expectedScope, keys[wrapper.hashKey("key_scope")].Scope)
+
+		err = wrapper.SetKeyScope("key_scope", common.KeyScope{})
+		assert.NoError(t, err)
+
+		access, err = useKey(wrapper, "key_scope", 1, "")
+		assert.NoError(t, err)
+		assert.Equal(t, common.KeyScope{}, access.Scope)
+	})
+
+	t.Run("should error if key not found", func(t *testing.T) {
+		err := wrapper.SetKeyScope("non_existent", common.KeyScope{Methods: []string{"GET"}})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "key not found")
+	})
+
+	t.Run("corrupted scope should not allow the key", func(t *testing.T) {
+		err := wrapper.AddUser("corrupted", "pass", false, 0, true, true, "")
+		require.NoError(t, err)
+		err = wrapper.AddKey("corrupted", "key_corrupted")
+		require.NoError(t, err)
+
+		_, err = wrapper.db.Exec(`UPDATE access_keys SET scope = ? WHERE key = ?`, "{", wrapper.hashKey("key_corrupted"))
+		require.NoError(t, err)
+
+		_, err = useKey(wrapper, "key_corrupted", 1, "")
+		assert.ErrorIs(t, err, errInvalidKeyScope)
+	})
+}
+
+func TestSQLiteWrapper_SetKeyExpiry(t *testing.T) {
+	t.Parallel()
+
+	wrapper := createTestDB(t)
+	defer closeWrapper(wrapper)
+
+	_ = wrapper.AddUser("user", "pass", false, 0, true, true, "")
+	_ = wrapper.AddKey("user", "key_expiry")
+
+	t.Run("should not allow the expired key", func(t *testing.T) {
+		err := wrapper.SetKeyExpiry("KEY_expiry", time.Now().Add(time.Hour).Unix())
+		assert.NoError(t, err)
+
+		_, err = useKey(wrapper, "key_expiry", 1, "")
+		assert.NoError(t, err)
+
+		err = wrapper.SetKeyExpiry(wrapper.hashKey("key_expiry"), time.Now().Add(-time.Second).Unix())
+		assert.NoError(t, err)
+
+		_, err = useKey(wrapper, "key_expiry", 1, "")
+		assert.ErrorIs(t, err, errKeyExpired)
+
+		keys, err := wrapper.GetAllKeys("user")
+		require.NoError(t, err)
+		assert.NotZero(t, keys[wrapper.hashKey("key_expiry")].ExpiresAt)
+	})
+
+	t.Run("0 expiry should remove the expiry", func(t *testing.T) {
+		err := wrapper.SetKeyExpiry("key_expiry", 0)
+		assert.NoError(t, err)
+
+		_, err = useKey(wrapper, "key_expiry", 1, "")
+		assert.NoError(t, err)
+	})
+
+	t.Run("should error if key not found", func(t *testing.T) {
+		err := wrapper.SetKeyExpiry("non_existent", 0)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "key not found")
+	})
+}
+
+func TestSQLiteWrapper_UpdateKey(t *testing.T) {
+	t.Parallel()
+
+	wrapper := createTestDB(t)
+	defer closeWrapper(wrapper)
+
+	_ = wrapper.AddUser("user", "pass", false, 0, true, true, "")
+	_ = wrapper.AddKey("user", "key_update")
+	scope := common.KeyScope{Routes: []string{"/address"}}
+	_ = wrapper.SetKeyScope("key_update", scope)
+
+	t.Run("only the expiry should keep the scope", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour).Unix()
+		err := wrapper.UpdateKey("KEY_update", common.KeyUpdate{ExpiresAt: &expiresAt})
+		assert.NoError(t, err)
+
+		keys, err := wrapper.GetAllKeys("user")
+		require.NoError(t, err)
+		assert.Equal(t, scope, keys[wrapper.hashKey("key_update")].Scope)
+		assert.Equal(t, expiresAt, keys[wrapper.hashKey("key_update")].ExpiresAt)
+	})
+
+	t.Run("should write the scope and the expiry together", func(t *testing.T) {
+		newScope := common.KeyScope{Methods: []string{"GET"}}
+		noExpiry := int64(0)
+		err := wrapper.UpdateKey(wrapper.hashKey("key_update"), common.KeyUpdate{Scope: &newScope, ExpiresAt: &noExpiry})
+		assert.NoError(t, err)
+
+		keys, err := wrapper.GetAllKeys("user")
+		require.NoError(t, err)
+		assert.Equal(t, newScope, keys[wrapper.hashKey("key_update")].Scope)
+		assert.Zero(t, keys[wrapper.hashKey("key_update")].ExpiresAt)
+	})
+
+	t.Run("nothing to update should error", func(t *testing.T) {
+		err := wrapper.UpdateKey("key_update", common.KeyUpdate{})
+		assert.ErrorIs(t, err, errEmptyKeyUpdate)
+	})
+
+	t.Run("should error if key not found", func(t *testing.T) {
+		err := wrapper.UpdateKey("non_existent", common.KeyUpdate{Scope: &common.KeyScope{}})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "key not found")
+	})
+}
+
+func TestSQLiteWrapper_RotateKey(t *testing.T) {
+	t.Parallel()
+
+	wrapper := createTestDB(t)
+	defer closeWrapper(wrapper)
+
+	_ = wrapper.AddUser("user", "pass", false, 0, true, true, "")
+	_ = wrapper.AddUser("other", "pass", false, 0, true, true, "")
+
+	t.Run("should issue the successor and keep the rotated key in its grace period", func(t *testing.T) {
+		_ = wrapper.AddKey("user", "key_old")
+		scope := common.KeyScope{Methods: []string{"GET"}}
+		_ = wrapper.SetKeyScope("key_old", scope)
+
+		graceExpiresAt := time.Now().Add(time.Hour).Unix()
+		err := wrapper.RotateKey("user", "KEY_old", "KEY_new", graceExpiresAt, common.KeySettings{})
+		require.NoError(t, err)
+
+		_, err = useKey(wrapper, "key_old", 2, "")
+		assert.NoError(t, err)
+		access, err := useKey(wrapper, "key_new", 1, "")
+		assert.NoError(t, err)
+		assert.Equal(t, scope, access.Scope)
+
+		time.Sleep(time.Second) // allow async counters write
+
+		keys, err := wrapper.GetAllKeys("user")
+		require.NoError(t, err)
+		oldDetails := keys[wrapper.hashKey("key_old")]
+		assert.Equal(t, graceExpiresAt, oldDetails.ExpiresAt)
+		assert.Equal(t, wrapper.hashKey("key_new"), oldDetails.RotatedTo)
+		assert.Equal(t, uint64(2), oldDetails.GraceCounter)
+
+		newDetails := keys[wrapper.hashKey("key_new")]
+		assert.Equal(t, common.KeyPrefix("key_new"), newDetails.KeyPrefix)
+		assert.Zero(t, newDetails.ExpiresAt)
+		assert.Empty(t, newDetails.RotatedTo)
+		assert.Zero(t, newDetails.GraceCounter)
+	})
+
+	t.Run("should not rotate a key twice", func(t *testing.T) {
+		err := wrapper.RotateKey("user", wrapper.hashKey("key_old"), "key_new2", time.Now().Unix(), common.KeySettings{})
+		assert.ErrorIs(t, err, errKeyAlreadyRotated)
+
+		_, err = useKey(wrapper, "key_new2", 1, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("provided settings should replace the inherited ones", func(t *testing.T) {
+		_ = wrapper.AddKey("user", "key_to_override")
+		_ = wrapper.SetKeyScope("key_to_override", common.KeyScope{Methods: []string{"GET"}})
+		_ = wrapper.SetKeyLabel("user", "key_to_override", "old label")
+
+		label := "new label"
+		settings := common.KeySettings{
+			Scope:     common.KeyScope{Methods: []string{"POST"}},
+			ExpiresAt: time.Now().Add(time.Hour * 2).Unix(),
+			Label:     &label,
+		}
+		err := wrapper.RotateKey("user", "key_to_override", "key_overridden", time.Now().Add(time.Hour).Unix(), settings)
+		require.NoError(t, err)
+
+		keys, err := wrapper.GetAllKeys("user")
+		require.NoError(t, err)
+		details := keys[wrapper.hashKey("key_overridden")]
+		assert.Equal(t, settings.Scope, details.Scope)
+		assert.Equal(t, settings.ExpiresAt, details.ExpiresAt)
+		assert.Equal(t, label, details.Label)
+	})
+
+	t.Run("failed successor insert should not rotate the key", func(t *testing.T) {
+		_ = wrapper.AddKey("user", "key_to_rotate")
+		_ = wrapper.AddKey("user", "key_taken")
+
+		err := wrapper.RotateKey("user", "key_to_rotate", "key_taken", time.Now().Unix(), common.KeySettings{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to insert key")
+
+		keys, err := wrapper.GetAllKeys("user")
+		require.NoError(t, err)
+		details := keys[wrapper.hashKey("key_to_rotate")]
+		assert.Empty(t, details.RotatedTo)
+		assert.Zero(t, details.ExpiresAt)
+	})
+
+	t.Run("should not rotate the key of another user", func(t *testing.T) {
+		_ = wrapper.AddKey("other", "key_other")
+
+		err := wrapper.RotateKey("user", "key_other", "key_other_new", time.Now().Unix(), common.KeySettings{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "key not found")
+	})
+
+	t.Run("should keep the earlier expiry of the rotated key", func(t *testing.T) {
+		_ = wrapper.AddKey("user", "key_expiring")
+		expiresAt := time.Now().Add(time.Minute).Unix()
+		_ = wrapper.SetKeyExpiry("key_expiring", expiresAt)
+
+		err := wrapper.RotateKey("user", "key_expiring", "key_expiring_new", time.Now().Add(time.Hour).Unix(), common.KeySettings{})
+		require.NoError(t, err)
+
+		keys, err := wrapper.GetAllKeys("user")
+		require.NoError(t, err)
+		assert.Equal(t, expiresAt, keys[wrapper.hashKey("key_expiring")].ExpiresAt)
+	})
+
+	t.Run("the rotated key should expire after its grace period", func(t *testing.T) {
+		_ = wrapper.AddKey("user", "key_no_grace")
+
+		err := wrapper.RotateKey("user", "key_no_grace", "key_no_grace_new", time.Now().Unix(), common.KeySettings{})
+		require.NoError(t, err)
+
+		_, err = useKey(wrapper, "key_no_grace", 1, "")
+		assert.ErrorIs(t, err, errKeyExpired)
+		_, err = useKey(wrapper, "key_no_grace_new", 1, "")
+		assert.NoError(t, err)
+	})
+}
+
+func TestSQLiteWrapper_KeysMetadata(t *testing.T) {
+	t.Parallel()
+
+	wrapper := createTestDB(t)
+	defer closeWrapper(wrapper)
+
+	_ = wrapper.AddUser("user", "pass", false, 0, true, true, "")
+	_ = wrapper.AddUser("other", "pass", false, 0, true, true, "")
+
+	t.Run("should record the creation time and the label", func(t *testing.T) {
+		startTime := time.Now().Unix()
+		_ = wrapper.AddKey("user", "key_meta")
+
+		err := wrapper.SetKeyLabel("user", "KEY_meta", "indexer")
+		assert.NoError(t, err)
+
+		keys, err := wrapper.GetAllKeys("user")
+		require.NoError(t, err)
+		details := keys[wrapper.hashKey("key_meta")]
+		assert.Equal(t, wrapper.hashKey("key_meta"), details.ID)
+		assert.Equal(t, "indexer", details.Label)
+		assert.GreaterOrEqual(t, details.CreatedAt, startTime)
+		assert.Zero(t, details.LastUsedAt)
+		assert.Empty(t, details.LastIP)
+	})
+
+	t.Run("should not set the label of another user's key", func(t *testing.T) {
+		err := wrapper.SetKeyLabel("other", wrapper.hashKey("key_meta"), "stolen")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "key not found")
+	})
+
+	t.Run("should record the last use after the flush", func(t *testing.T) {
+		startTime := time.Now().Unix()
+		_, err := useKey(wrapper, "key_meta", 1, "10.0.0.1")
+		assert.NoError(t, err)
+		_, err = useKey(wrapper, "key_meta", 1, "10.0.0.2")
+		assert.NoError(t, err)
+
+		// the last use not yet written is listed
+		keys, err := wrapper.GetAllKeys("user")
+		require.NoError(t, err)
+		assert.Equal(t, "10.0.0.2", keys[wrapper.hashKey("key_meta")].LastIP)
+		assert.GreaterOrEqual(t, keys[wrapper.hashKey("key_meta")].LastUsedAt, startTime)
+
+		var lastUsedAt int64
+		var lastIP string
+		query := `SELECT last_used_at, last_ip FROM access_keys WHERE key = ?`
+		err = wrapper.db.QueryRow(query, wrapper.hashKey("key_meta")).Scan(&lastUsedAt, &lastIP)
+		require.NoError(t, err)
+		assert.Zero(t, lastUsedAt)
+
+		err = wrapper.FlushKeysUsage()
+		assert.NoError(t, err)
+
+		err = wrapper.db.QueryRow(query, wrapper.hashKey("key_meta")).Scan(&lastUsedAt, &lastIP)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, lastUsedAt, startTime)
+		assert.Equal(t, "10.0.0.2", lastIP)
+
+		// nothing left to write
+		err = wrapper.FlushKeysUsage()
+		assert.NoError(t, err)
+	})
+
+	t.Run("the rotated key's successor should inherit the label", func(t *testing.T) {
+		err := wrapper.RotateKey("user", "key_meta", "key_meta_new", time.Now().Add(time.Hour).Unix(), common.KeySettings{})
+		require.NoError(t, err)
+
+		keys, err := wrapper.GetAllKeys("user")
+		require.NoError(t, err)
+		details := keys[wrapper.hashKey("key_meta_new")]
+		assert.Equal(t, "indexer", details.Label)
+		assert.NotZero(t, details.CreatedAt)
+		assert.Zero(t, details.LastUsedAt)
+	})
+}
+
+func TestSQLiteWrapper_SetKeyRestrictions(t *testing.T) {
+	t.Parallel()
+
+	wrapper := createTestDB(t)
+	defer closeWrapper(wrapper)
+
+	_ = wrapper.AddUser("user", "pass", false, 0, true, true, "")
+	_ = wrapper.AddUser("other", "pass", false, 0, true, true, "")
+	_ = wrapper.AddKey("user", "key_restricted")
+
+	restrictions := common.KeyRestrictions{
+		AllowedCIDRs:   []string{"10.0.0.0/8"},
+		AllowedOrigins: []string{"https://*.example.com"},
+	}
+
+	t.Run("key without restrictions should not be restricted", func(t *testing.T) {
+		access, err := useKey(wrapper, "key_restricted", 1, "")
+		assert.NoError(t, err)
+		assert.True(t, access.Restrictions.IsEmpty())
+	})
+
+	t.Run("should set the restrictions", func(t *testing.T) {
+		err := wrapper.SetKeyRestrictions("user", "KEY_restricted", restrictions)
+		assert.NoError(t, err)
+
+		access, err := useKey(wrapper, "key_restricted", 1, "")
+		assert.NoError(t, err)
+		assert.Equal(t, restrictions, access.Restrictions)
+
+		keys, err := wrapper.GetAllKeys("user")
+		require.NoError(t, err)
+		assert.Equal(t, restrictions, keys[wrapper.hashKey("key_restricted")].Restrictions)
+	})
+
+	t.Run("should not set the restrictions of another user's key", func(t *testing.T) {
+		err := wrapper.SetKeyRestrictions("other", wrapper.hashKey("key_restricted"), common.KeyRestrictions{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "key not found")
+	})
+
+	t.Run("the rotated key's successor should inherit the restrictions", func(t *testing.T) {
+		err := wrapper.RotateKey("user", "key_restricted", "key_restricted_new", time.Now().Add(time.Hour).Unix(), common.KeySettings{})
+		require.NoError(t, err)
+
+		access, err := useKey(wrapper, "key_restricted_new", 1, "")
+		assert.NoError(t, err)
+		assert.Equal(t, restrictions, access.Restrictions)
+	})
+
+	t.Run("empty restrictions should remove the restrictions", func(t *testing.T) {
+		err := wrapper.SetKeyRestrictions("user", "key_restricted_new", common.KeyRestrictions{})
+		assert.NoError(t, err)
+
+		var restrictionsString string
+		query := `SELECT restrictions FROM access_keys WHERE key = ?`
+		err = wrapper.db.QueryRow(query, wrapper.hashKey("key_restricted_new")).Scan(&restrictionsString)
+		require.NoError(t, err)
+		assert.Empty(t, restrictionsString)
+	})
+
+	t.Run("corrupted restrictions should error", func(t *testing.T) {
+		_, err := wrapper.db.Exec(`UPDATE access_keys SET restrictions = ? WHERE key = ?`, "{", wrapper.hashKey("key_restricted_new"))
+		require.NoError(t, err)
+
+		_, err = useKey(wrapper, "key_restricted_new", 1, "")
+		assert.ErrorIs(t, err, errInvalidKeyRestrictions)
+	})
+}
@@ -2,20 +2,52 @@ package testscommon
 
 import (
 	"net/http"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
 )
 
 // AccessCheckerStub -
 type AccessCheckerStub struct {
-	ShouldProcessRequestHandler func(header http.Header, requestURI string) (string, error)
+	ShouldProcessRequestHandler func(request *http.Request) (common.AccessResult, error)
+	CheckHostHandler            func(result common.AccessResult, host config.GatewayConfig) error
+	ConsumeCreditsHandler       func(result common.AccessResult)
+	ReleaseRequestHandler       func(result common.AccessResult)
 }
 
 // ShouldProcessRequest -
-func (stub *AccessCheckerStub) ShouldProcessRequest(header http.Header, requestURI string) (string, error) {
+func (stub *AccessCheckerStub) ShouldProcessRequest(request *http.Request) (common.AccessResult, error) {
 	if stub.ShouldProcessRequestHandler == nil {
-		return requestURI, nil
+		return common.AccessResult{
+			RequestURI: request.RequestURI,
+			RateLimit:  common.RateLimitResult{Allowed: true},
+		}, nil
+	}
+
+	return stub.ShouldProcessRequestHandler(request)
+}
+
+// CheckHost -
+func (stub *AccessCheckerStub) CheckHost(result common.AccessResult, host config.GatewayConfig) error {
+	if stub.CheckHostHandler != nil {
+		return stub.CheckHostHandler(result, host)
 	}
 
-	return stub.ShouldProcessRequestHandler(header, requestURI)
+	return nil
+}
+
+// ConsumeCredits -
+func (stub *AccessCheckerStub) ConsumeCredits(result common.AccessResult) {
+	if stub.ConsumeCreditsHandler != nil {
+		stub.ConsumeCreditsHandler(result)
+	}
+}
+
+// ReleaseRequest -
+func (stub *AccessCheckerStub) ReleaseRequest(result common.AccessResult) {
+	if stub.ReleaseRequestHandler != nil {
+		stub.ReleaseRequestHandler(result)
+	}
 }
 
 // IsInterfaceNil -
@@ -0,0 +1,22 @@
+package testscommon
+
+import "io"
+
+// BodyValuesExtractorStub -
+type BodyValuesExtractorStub struct {
+	ExtractValuesCalled func(requestPath string, body io.Reader) (map[string][]string, io.Reader, error)
+}
+
+// ExtractValues -
+func (stub *BodyValuesExtractorStub) ExtractValues(requestPath string, body io.Reader) (map[string][]string, io.Reader, error) {
+	if stub.ExtractValuesCalled != nil {
+		return stub.ExtractValuesCalled(requestPath, body)
+	}
+
+	return make(map[string][]string), body, nil
+}
+
+// IsInterfaceNil -
+func (stub *BodyValuesExtractorStub) IsInterfaceNil() bool {
+	return stub == nil
+}
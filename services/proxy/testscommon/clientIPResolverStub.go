@@ -0,0 +1,22 @@
+package testscommon
+
+import "net/http"
+
+// ClientIPResolverStub -
+type ClientIPResolverStub struct {
+	ResolveClientIPCalled func(request *http.Request) string
+}
+
+// ResolveClientIP -
+func (stub *ClientIPResolverStub) ResolveClientIP(request *http.Request) string {
+	if stub.ResolveClientIPCalled != nil {
+		return stub.ResolveClientIPCalled(request)
+	}
+
+	return ""
+}
+
+// IsInterfaceNil -
+func (stub *ClientIPResolverStub) IsInterfaceNil() bool {
+	return stub == nil
+}
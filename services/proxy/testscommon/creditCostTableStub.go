@@ -0,0 +1,20 @@
+package testscommon
+
+// CreditCostTableStub -
+type CreditCostTableStub struct {
+	GetCostCalled func(method string, requestPath string) uint64
+}
+
+// GetCost -
+func (stub *CreditCostTableStub) GetCost(method string, requestPath string) uint64 {
+	if stub.GetCostCalled != nil {
+		return stub.GetCostCalled(method, requestPath)
+	}
+
+	return 1
+}
+
+// IsInterfaceNil -
+func (stub *CreditCostTableStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -0,0 +1,22 @@
+package testscommon
+
+import "github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+
+// GatewayProberStub -
+type GatewayProberStub struct {
+	ProbeGatewayCalled func(upstream config.GatewayConfig) error
+}
+
+// ProbeGateway -
+func (stub *GatewayProberStub) ProbeGateway(upstream config.GatewayConfig) error {
+	if stub.ProbeGatewayCalled != nil {
+		return stub.ProbeGatewayCalled(upstream)
+	}
+
+	return nil
+}
+
+// IsInterfaceNil -
+func (stub *GatewayProberStub) IsInterfaceNil() bool {
+	return stub == nil
+}
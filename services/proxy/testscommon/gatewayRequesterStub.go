@@ -0,0 +1,26 @@
+package testscommon
+
+import (
+	"context"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+// GatewayRequesterStub -
+type GatewayRequesterStub struct {
+	DoGatewayRequestHandler func(ctx context.Context, gateway config.GatewayConfig, route string, result any) error
+}
+
+// DoGatewayRequest -
+func (stub *GatewayRequesterStub) DoGatewayRequest(ctx context.Context, gateway config.GatewayConfig, route string, result any) error {
+	if stub.DoGatewayRequestHandler != nil {
+		return stub.DoGatewayRequestHandler(ctx, gateway, route, result)
+	}
+
+	return nil
+}
+
+// IsInterfaceNil -
+func (stub *GatewayRequesterStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -0,0 +1,35 @@
+package testscommon
+
+import (
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+)
+
+// GatewaysDiscovererStub -
+type GatewaysDiscovererStub struct {
+	DiscoverRangesCalled     func(gateways []config.GatewayConfig) ([]config.GatewayConfig, error)
+	GetDiscoveryReportCalled func() common.GatewaysDiscoveryReport
+}
+
+// DiscoverRanges -
+func (stub *GatewaysDiscovererStub) DiscoverRanges(gateways []config.GatewayConfig) ([]config.GatewayConfig, error) {
+	if stub.DiscoverRangesCalled != nil {
+		return stub.DiscoverRangesCalled(gateways)
+	}
+
+	return gateways, nil
+}
+
+// GetDiscoveryReport -
+func (stub *GatewaysDiscovererStub) GetDiscoveryReport() common.GatewaysDiscoveryReport {
+	if stub.GetDiscoveryReportCalled != nil {
+		return stub.GetDiscoveryReportCalled()
+	}
+
+	return common.GatewaysDiscoveryReport{}
+}
+
+// IsInterfaceNil -
+func (stub *GatewaysDiscovererStub) IsInterfaceNil() bool {
+	return stub == nil
+}
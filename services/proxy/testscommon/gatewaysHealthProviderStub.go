@@ -0,0 +1,32 @@
+package testscommon
+
+import "github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+
+// GatewaysHealthProviderStub -
+type GatewaysHealthProviderStub struct {
+	IsHealthyCalled       func(url string) bool
+	GetHealthStatusCalled func() []common.GatewayHealthStatus
+}
+
+// IsHealthy -
+func (stub *GatewaysHealthProviderStub) IsHealthy(url string) bool {
+	if stub.IsHealthyCalled != nil {
+		return stub.IsHealthyCalled(url)
+	}
+
+	return true
+}
+
+// GetHealthStatus -
+func (stub *GatewaysHealthProviderStub) GetHealthStatus() []common.GatewayHealthStatus {
+	if stub.GetHealthStatusCalled != nil {
+		return stub.GetHealthStatusCalled()
+	}
+
+	return make([]common.GatewayHealthStatus, 0)
+}
+
+// IsInterfaceNil -
+func (stub *GatewaysHealthProviderStub) IsInterfaceNil() bool {
+	return stub == nil
+}
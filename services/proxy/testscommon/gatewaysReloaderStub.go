@@ -0,0 +1,32 @@
+package testscommon
+
+import "github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+
+// GatewaysReloaderStub -
+type GatewaysReloaderStub struct {
+	ReloadCalled     func() ([]config.GatewayConfig, error)
+	RediscoverCalled func() ([]config.GatewayConfig, error)
+}
+
+// Reload -
+func (stub *GatewaysReloaderStub) Reload() ([]config.GatewayConfig, error) {
+	if stub.ReloadCalled != nil {
+		return stub.ReloadCalled()
+	}
+
+	return make([]config.GatewayConfig, 0), nil
+}
+
+// Rediscover -
+func (stub *GatewaysReloaderStub) Rediscover() ([]config.GatewayConfig, error) {
+	if stub.RediscoverCalled != nil {
+		return stub.RediscoverCalled()
+	}
+
+	return make([]config.GatewayConfig, 0), nil
+}
+
+// IsInterfaceNil -
+func (stub *GatewaysReloaderStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -0,0 +1,22 @@
+package testscommon
+
+import "github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/config"
+
+// GatewaysTesterStub -
+type GatewaysTesterStub struct {
+	TestGatewaysCalled func(gateways []config.GatewayConfig) error
+}
+
+// TestGateways -
+func (stub *GatewaysTesterStub) TestGateways(gateways []config.GatewayConfig) error {
+	if stub.TestGatewaysCalled != nil {
+		return stub.TestGatewaysCalled(gateways)
+	}
+
+	return nil
+}
+
+// IsInterfaceNil -
+func (stub *GatewaysTesterStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -0,0 +1,32 @@
+package testscommon
+
+import "context"
+
+// HashEpochResolverStub -
+type HashEpochResolverStub struct {
+	ExtractValuesCalled func(ctx context.Context, requestPath string) map[string][]string
+	GetMetricsCalled    func() map[string]uint64
+}
+
+// ExtractValues -
+func (stub *HashEpochResolverStub) ExtractValues(ctx context.Context, requestPath string) map[string][]string {
+	if stub.ExtractValuesCalled != nil {
+		return stub.ExtractValuesCalled(ctx, requestPath)
+	}
+
+	return make(map[string][]string)
+}
+
+// GetMetrics -
+func (stub *HashEpochResolverStub) GetMetrics() map[string]uint64 {
+	if stub.GetMetricsCalled != nil {
+		return stub.GetMetricsCalled()
+	}
+
+	return make(map[string]uint64)
+}
+
+// IsInterfaceNil -
+func (stub *HashEpochResolverStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -0,0 +1,48 @@
+package testscommon
+
+import "net/http"
+
+// HeadersPolicyStub -
+type HeadersPolicyStub struct {
+	RequestIDCalled          func(header http.Header) string
+	CopyRequestHeaderCalled  func(destination http.Header, request *http.Request)
+	CopyResponseHeaderCalled func(destination http.Header, source http.Header)
+}
+
+// RequestID -
+func (stub *HeadersPolicyStub) RequestID(header http.Header) string {
+	if stub.RequestIDCalled != nil {
+		return stub.RequestIDCalled(header)
+	}
+
+	return "request-id"
+}
+
+// CopyRequestHeader -
+func (stub *HeadersPolicyStub) CopyRequestHeader(destination http.Header, request *http.Request) {
+	if stub.CopyRequestHeaderCalled != nil {
+		stub.CopyRequestHeaderCalled(destination, request)
+		return
+	}
+
+	for key, values := range request.Header {
+		destination[key] = values
+	}
+}
+
+// CopyResponseHeader -
+func (stub *HeadersPolicyStub) CopyResponseHeader(destination http.Header, source http.Header) {
+	if stub.CopyResponseHeaderCalled != nil {
+		stub.CopyResponseHeaderCalled(destination, source)
+		return
+	}
+
+	for key, values := range source {
+		destination[key] = values
+	}
+}
+
+// IsInterfaceNil -
+func (stub *HeadersPolicyStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -8,8 +8,10 @@ import (
 
 // HostsFinderStub -
 type HostsFinderStub struct {
-	FindHostCalled       func(urlValues map[string][]string) (config.GatewayConfig, error)
-	LoadedGatewaysCalled func() []config.GatewayConfig
+	FindHostCalled            func(urlValues map[string][]string) (config.GatewayConfig, error)
+	FindAlternativeHostCalled func(urlValues map[string][]string, triedURLs []string) (config.GatewayConfig, error)
+	ReleaseHostCalled         func(host config.GatewayConfig)
+	LoadedGatewaysCalled      func() []config.GatewayConfig
 }
 
 // FindHost -
@@ -21,6 +23,22 @@ func (stub *HostsFinderStub) FindHost(urlValues map[string][]string) (config.Gat
 	return config.GatewayConfig{}, errors.New("not implemented")
 }
 
+// FindAlternativeHost -
+func (stub *HostsFinderStub) FindAlternativeHost(urlValues map[string][]string, triedURLs []string) (config.GatewayConfig, error) {
+	if stub.FindAlternativeHostCalled != nil {
+		return stub.FindAlternativeHostCalled(urlValues, triedURLs)
+	}
+
+	return config.GatewayConfig{}, errors.New("not implemented")
+}
+
+// ReleaseHost -
+func (stub *HostsFinderStub) ReleaseHost(host config.GatewayConfig) {
+	if stub.ReleaseHostCalled != nil {
+		stub.ReleaseHostCalled(host)
+	}
+}
+
 // LoadedGateways -
 func (stub *HostsFinderStub) LoadedGateways() []config.GatewayConfig {
 	if stub.LoadedGatewaysCalled != nil {
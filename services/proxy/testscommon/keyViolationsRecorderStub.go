@@ -0,0 +1,30 @@
+package testscommon
+
+import "github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+
+// KeyViolationsRecorderStub -
+type KeyViolationsRecorderStub struct {
+	RecordCalled        func(violation common.KeyViolation)
+	GetViolationsCalled func() []common.KeyViolation
+}
+
+// Record -
+func (stub *KeyViolationsRecorderStub) Record(violation common.KeyViolation) {
+	if stub.RecordCalled != nil {
+		stub.RecordCalled(violation)
+	}
+}
+
+// GetViolations -
+func (stub *KeyViolationsRecorderStub) GetViolations() []common.KeyViolation {
+	if stub.GetViolationsCalled != nil {
+		return stub.GetViolationsCalled()
+	}
+
+	return make([]common.KeyViolation, 0)
+}
+
+// IsInterfaceNil -
+func (stub *KeyViolationsRecorderStub) IsInterfaceNil() bool {
+	return stub == nil
+}
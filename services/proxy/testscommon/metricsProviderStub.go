@@ -0,0 +1,20 @@
+package testscommon
+
+// MetricsProviderStub -
+type MetricsProviderStub struct {
+	GetMetricsCalled func() map[string]uint64
+}
+
+// GetMetrics -
+func (stub *MetricsProviderStub) GetMetrics() map[string]uint64 {
+	if stub.GetMetricsCalled != nil {
+		return stub.GetMetricsCalled()
+	}
+
+	return make(map[string]uint64)
+}
+
+// IsInterfaceNil -
+func (stub *MetricsProviderStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -0,0 +1,20 @@
+package testscommon
+
+// PathValuesExtractorStub -
+type PathValuesExtractorStub struct {
+	ExtractValuesCalled func(requestPath string) map[string][]string
+}
+
+// ExtractValues -
+func (stub *PathValuesExtractorStub) ExtractValues(requestPath string) map[string][]string {
+	if stub.ExtractValuesCalled != nil {
+		return stub.ExtractValuesCalled(requestPath)
+	}
+
+	return make(map[string][]string)
+}
+
+// IsInterfaceNil -
+func (stub *PathValuesExtractorStub) IsInterfaceNil() bool {
+	return stub == nil
+}
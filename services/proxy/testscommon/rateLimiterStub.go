@@ -0,0 +1,32 @@
+package testscommon
+
+import "github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+
+// RateLimiterStub -
+type RateLimiterStub struct {
+	AllowCalled func(username string, accountType common.AccountType) common.RateLimitResult
+	SweepCalled func()
+}
+
+// Allow -
+func (stub *RateLimiterStub) Allow(username string, accountType common.AccountType) common.RateLimitResult {
+	if stub.AllowCalled != nil {
+		return stub.AllowCalled(username, accountType)
+	}
+
+	return common.RateLimitResult{
+		Allowed: true,
+	}
+}
+
+// Sweep -
+func (stub *RateLimiterStub) Sweep() {
+	if stub.SweepCalled != nil {
+		stub.SweepCalled()
+	}
+}
+
+// IsInterfaceNil -
+func (stub *RateLimiterStub) IsInterfaceNil() bool {
+	return stub == nil
+}
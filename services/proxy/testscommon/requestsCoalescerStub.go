@@ -0,0 +1,56 @@
+package testscommon
+
+import (
+	"context"
+
+	"github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+)
+
+// RequestsCoalescerStub -
+type RequestsCoalescerStub struct {
+	DoCalled              func(ctx context.Context, key string, handler func() (common.CachedResponse, bool)) (common.CachedResponse, bool, bool)
+	IsEnabledCalled       func() bool
+	MaxResponseSizeCalled func() uint64
+	GetMetricsCalled      func() map[string]uint64
+}
+
+// Do -
+func (stub *RequestsCoalescerStub) Do(ctx context.Context, key string, handler func() (common.CachedResponse, bool)) (common.CachedResponse, bool, bool) {
+	if stub.DoCalled != nil {
+		return stub.DoCalled(ctx, key, handler)
+	}
+
+	return common.CachedResponse{}, false, false
+}
+
+// IsEnabled -
+func (stub *RequestsCoalescerStub) IsEnabled() bool {
+	if stub.IsEnabledCalled != nil {
+		return stub.IsEnabledCalled()
+	}
+
+	return false
+}
+
+// MaxResponseSize -
+func (stub *RequestsCoalescerStub) MaxResponseSize() uint64 {
+	if stub.MaxResponseSizeCalled != nil {
+		return stub.MaxResponseSizeCalled()
+	}
+
+	return 0
+}
+
+// GetMetrics -
+func (stub *RequestsCoalescerStub) GetMetrics() map[string]uint64 {
+	if stub.GetMetricsCalled != nil {
+		return stub.GetMetricsCalled()
+	}
+
+	return make(map[string]uint64)
+}
+
+// IsInterfaceNil -
+func (stub *RequestsCoalescerStub) IsInterfaceNil() bool {
+	return stub == nil
+}
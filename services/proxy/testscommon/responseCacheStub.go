@@ -0,0 +1,60 @@
+package testscommon
+
+import "github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+
+// ResponseCacheStub -
+type ResponseCacheStub struct {
+	GetCalled          func(key string) (common.CachedResponse, bool)
+	PutCalled          func(key string, response common.CachedResponse)
+	IsEnabledCalled    func() bool
+	MaxEntrySizeCalled func() uint64
+	PurgeCalled        func() int
+}
+
+// Get -
+func (stub *ResponseCacheStub) Get(key string) (common.CachedResponse, bool) {
+	if stub.GetCalled != nil {
+		return stub.GetCalled(key)
+	}
+
+	return common.CachedResponse{}, false
+}
+
+// Put -
+func (stub *ResponseCacheStub) Put(key string, response common.CachedResponse) {
+	if stub.PutCalled != nil {
+		stub.PutCalled(key, response)
+	}
+}
+
+// IsEnabled -
+func (stub *ResponseCacheStub) IsEnabled() bool {
+	if stub.IsEnabledCalled != nil {
+		return stub.IsEnabledCalled()
+	}
+
+	return false
+}
+
+// MaxEntrySize -
+func (stub *ResponseCacheStub) MaxEntrySize() uint64 {
+	if stub.MaxEntrySizeCalled != nil {
+		return stub.MaxEntrySizeCalled()
+	}
+
+	return 0
+}
+
+// Purge -
+func (stub *ResponseCacheStub) Purge() int {
+	if stub.PurgeCalled != nil {
+		return stub.PurgeCalled()
+	}
+
+	return 0
+}
+
+// IsInterfaceNil -
+func (stub *ResponseCacheStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -0,0 +1,32 @@
+package testscommon
+
+import "time"
+
+// RetryPolicyStub -
+type RetryPolicyStub struct {
+	ShouldRetryCalled func(method string, attempts uint32, statusCode int, err error) bool
+	BackoffCalled     func(attempts uint32) time.Duration
+}
+
+// ShouldRetry -
+func (stub *RetryPolicyStub) ShouldRetry(method string, attempts uint32, statusCode int, err error) bool {
+	if stub.ShouldRetryCalled != nil {
+		return stub.ShouldRetryCalled(method, attempts, statusCode, err)
+	}
+
+	return false
+}
+
+// Backoff -
+func (stub *RetryPolicyStub) Backoff(attempts uint32) time.Duration {
+	if stub.BackoffCalled != nil {
+		return stub.BackoffCalled(attempts)
+	}
+
+	return 0
+}
+
+// IsInterfaceNil -
+func (stub *RetryPolicyStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -0,0 +1,50 @@
+package testscommon
+
+import "context"
+
+// SlowLaneStub -
+type SlowLaneStub struct {
+	WaitCalled       func(ctx context.Context, username string) error
+	IsEnabledCalled  func() bool
+	SweepCalled      func()
+	GetMetricsCalled func() map[string]uint64
+}
+
+// Wait -
+func (stub *SlowLaneStub) Wait(ctx context.Context, username string) error {
+	if stub.WaitCalled != nil {
+		return stub.WaitCalled(ctx, username)
+	}
+
+	return nil
+}
+
+// IsEnabled -
+func (stub *SlowLaneStub) IsEnabled() bool {
+	if stub.IsEnabledCalled != nil {
+		return stub.IsEnabledCalled()
+	}
+
+	return false
+}
+
+// Sweep -
+func (stub *SlowLaneStub) Sweep() {
+	if stub.SweepCalled != nil {
+		stub.SweepCalled()
+	}
+}
+
+// GetMetrics -
+func (stub *SlowLaneStub) GetMetrics() map[string]uint64 {
+	if stub.GetMetricsCalled != nil {
+		return stub.GetMetricsCalled()
+	}
+
+	return make(map[string]uint64)
+}
+
+// IsInterfaceNil -
+func (stub *SlowLaneStub) IsInterfaceNil() bool {
+	return stub == nil
+}
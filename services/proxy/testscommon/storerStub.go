@@ -5,13 +5,20 @@ import "github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
 // StorerStub -
 type StorerStub struct {
 	RemoveUserHandler                        func(username string) error
-	UpdateUserHandler                        func(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool) error
+	UpdateUserHandler                        func(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, tier *string) error
+	SetUserTierHandler                       func(username string, tier string) error
+	UpdateKeyHandler                         func(key string, update common.KeyUpdate) error
+	SetKeyLabelHandler                       func(username string, key string, label string) error
+	SetKeyRestrictionsHandler                func(username string, key string, restrictions common.KeyRestrictions) error
+	RotateKeyHandler                         func(username string, key string, newKey string, graceExpiresAt int64, settings common.KeySettings) error
 	AddUserHandler                           func(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, isActive bool, activationToken string) error
 	AddKeyHandler                            func(username string, key string) error
+	AddKeyWithSettingsHandler                func(username string, key string, settings common.KeySettings) error
 	RemoveKeyHandler                         func(username string, key string) error
 	GetAllKeysHandler                        func(username string) (map[string]common.AccessKeyDetails, error)
 	GetAllUsersHandler                       func() (map[string]common.UsersDetails, error)
-	IsKeyAllowedHandler                      func(key string) (string, common.AccountType, error)
+	GetKeyAccessHandler                      func(key string) (common.KeyAccess, error)
+	ConsumeKeyCreditsHandler                 func(access common.KeyAccess, cost uint64, clientIP string)
 	CloseHandler                             func() error
 	CheckUserCredentialsHandler              func(username string, password string) (*common.UsersDetails, error)
 	GetUserHandler                           func(username string) (*common.UsersDetails, error)
@@ -23,6 +30,10 @@ type StorerStub struct {
 	SetCryptoPaymentIDHandler                func(username string, paymentID uint64) error
 	UpdateMaxRequestsHandler                 func(username string, maxRequests uint64) error
 	UpdateUserMaxRequestsFromContractHandler func(username string, contractMaxRequests uint64) error
+	GetHashEpochHandler                      func(hash string) (uint64, bool, error)
+	SaveHashEpochHandler                     func(hash string, epoch uint64) error
+	GetEpochStartsHandler                    func(shard uint32) ([]common.EpochStartInfo, error)
+	SaveEpochStartHandler                    func(info common.EpochStartInfo) error
 }
 
 func (stub *StorerStub) UpdateUserMaxRequestsFromContract(username string, contractMaxRequests uint64) error {
@@ -53,9 +64,48 @@ func (stub *StorerStub) RemoveUser(username string) error {
 	return nil
 }
 
-func (stub *StorerStub) UpdateUser(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool) error {
+func (stub *StorerStub) UpdateUser(username string, password string, isAdmin bool, maxRequests uint64, isPremium bool, tier *string) error {
 	if stub.UpdateUserHandler != nil {
-		return stub.UpdateUserHandler(username, password, isAdmin, maxRequests, isPremium)
+		return stub.UpdateUserHandler(username, password, isAdmin, maxRequests, isPremium, tier)
+	}
+	return nil
+}
+
+func (stub *StorerStub) SetUserTier(username string, tier string) error {
+	if stub.SetUserTierHandler != nil {
+		return stub.SetUserTierHandler(username, tier)
+	}
+	return nil
+}
+
+// UpdateKey -
+func (stub *StorerStub) UpdateKey(key string, update common.KeyUpdate) error {
+	if stub.UpdateKeyHandler != nil {
+		return stub.UpdateKeyHandler(key, update)
+	}
+	return nil
+}
+
+// SetKeyRestrictions -
+func (stub *StorerStub) SetKeyRestrictions(username string, key string, restrictions common.KeyRestrictions) error {
+	if stub.SetKeyRestrictionsHandler != nil {
+		return stub.SetKeyRestrictionsHandler(username, key, restrictions)
+	}
+	return nil
+}
+
+// SetKeyLabel -
+func (stub *StorerStub) SetKeyLabel(username string, key string, label string) error {
+	if stub.SetKeyLabelHandler != nil {
+		return stub.SetKeyLabelHandler(username, key, label)
+	}
+	return nil
+}
+
+// RotateKey -
+func (stub *StorerStub) RotateKey(username string, key string, newKey string, graceExpiresAt int64, settings common.KeySettings) error {
+	if stub.RotateKeyHandler != nil {
+		return stub.RotateKeyHandler(username, key, newKey, graceExpiresAt, settings)
 	}
 	return nil
 }
@@ -75,6 +125,15 @@ func (stub *StorerStub) AddKey(username string, key string) error {
 	return nil
 }
 
+// AddKeyWithSettings -
+func (stub *StorerStub) AddKeyWithSettings(username string, key string, settings common.KeySettings) error {
+	if stub.AddKeyWithSettingsHandler != nil {
+		return stub.AddKeyWithSettingsHandler(username, key, settings)
+	}
+
+	return nil
+}
+
 // RemoveKey -
 func (stub *StorerStub) RemoveKey(username string, key string) error {
 	if stub.RemoveKeyHandler != nil {
@@ -84,13 +143,20 @@ func (stub *StorerStub) RemoveKey(username string, key string) error {
 	return nil
 }
 
-// IsKeyAllowed -
-func (stub *StorerStub) IsKeyAllowed(key string) (string, common.AccountType, error) {
-	if stub.IsKeyAllowedHandler != nil {
-		return stub.IsKeyAllowedHandler(key)
+// GetKeyAccess -
+func (stub *StorerStub) GetKeyAccess(key string) (common.KeyAccess, error) {
+	if stub.GetKeyAccessHandler != nil {
+		return stub.GetKeyAccessHandler(key)
 	}
 
-	return "", "", nil
+	return common.KeyAccess{}, nil
+}
+
+// ConsumeKeyCredits -
+func (stub *StorerStub) ConsumeKeyCredits(access common.KeyAccess, cost uint64, clientIP string) {
+	if stub.ConsumeKeyCreditsHandler != nil {
+		stub.ConsumeKeyCreditsHandler(access, cost, clientIP)
+	}
 }
 
 // GetAllKeys -
@@ -145,6 +211,42 @@ func (stub *StorerStub) GetPerformanceMetrics() (map[string]uint64, error) {
 	return nil, nil
 }
 
+// GetHashEpoch -
+func (stub *StorerStub) GetHashEpoch(hash string) (uint64, bool, error) {
+	if stub.GetHashEpochHandler != nil {
+		return stub.GetHashEpochHandler(hash)
+	}
+
+	return 0, false, nil
+}
+
+// SaveHashEpoch -
+func (stub *StorerStub) SaveHashEpoch(hash string, epoch uint64) error {
+	if stub.SaveHashEpochHandler != nil {
+		return stub.SaveHashEpochHandler(hash, epoch)
+	}
+
+	return nil
+}
+
+// GetEpochStarts -
+func (stub *StorerStub) GetEpochStarts(shard uint32) ([]common.EpochStartInfo, error) {
+	if stub.GetEpochStartsHandler != nil {
+		return stub.GetEpochStartsHandler(shard)
+	}
+
+	return make([]common.EpochStartInfo, 0), nil
+}
+
+// SaveEpochStart -
+func (stub *StorerStub) SaveEpochStart(info common.EpochStartInfo) error {
+	if stub.SaveEpochStartHandler != nil {
+		return stub.SaveEpochStartHandler(info)
+	}
+
+	return nil
+}
+
 // IsInterfaceNil -
 func (stub *StorerStub) IsInterfaceNil() bool {
 	return stub == nil
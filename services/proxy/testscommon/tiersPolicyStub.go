@@ -0,0 +1,50 @@
+package testscommon
+
+import "github.com/iulianpascalau/mx-epoch-proxy-go/services/proxy/common"
+
+// TiersPolicyStub -
+type TiersPolicyStub struct {
+	IsEndpointAllowedCalled func(accountType common.AccountType, requestPath string) bool
+	AcquireRequestCalled    func(username string, accountType common.AccountType) bool
+	ReleaseRequestCalled    func(username string)
+	GetMetricsCalled        func() map[string]uint64
+}
+
+// IsEndpointAllowed -
+func (stub *TiersPolicyStub) IsEndpointAllowed(accountType common.AccountType, requestPath string) bool {
+	if stub.IsEndpointAllowedCalled != nil {
+		return stub.IsEndpointAllowedCalled(accountType, requestPath)
+	}
+
+	return true
+}
+
+// AcquireRequest -
+func (stub *TiersPolicyStub) AcquireRequest(username string, accountType common.AccountType) bool {
+	if stub.AcquireRequestCalled != nil {
+		return stub.AcquireRequestCalled(username, accountType)
+	}
+
+	return true
+}
+
+// ReleaseRequest -
+func (stub *TiersPolicyStub) ReleaseRequest(username string) {
+	if stub.ReleaseRequestCalled != nil {
+		stub.ReleaseRequestCalled(username)
+	}
+}
+
+// GetMetrics -
+func (stub *TiersPolicyStub) GetMetrics() map[string]uint64 {
+	if stub.GetMetricsCalled != nil {
+		return stub.GetMetricsCalled()
+	}
+
+	return make(map[string]uint64)
+}
+
+// IsInterfaceNil -
+func (stub *TiersPolicyStub) IsInterfaceNil() bool {
+	return stub == nil
+}
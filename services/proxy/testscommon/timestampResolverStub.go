@@ -0,0 +1,20 @@
+package testscommon
+
+// TimestampResolverStub -
+type TimestampResolverStub struct {
+	ResolveNonceCalled func(timestamp string) (uint64, bool, error)
+}
+
+// ResolveNonce -
+func (stub *TimestampResolverStub) ResolveNonce(timestamp string) (uint64, bool, error) {
+	if stub.ResolveNonceCalled != nil {
+		return stub.ResolveNonceCalled(timestamp)
+	}
+
+	return 0, false, nil
+}
+
+// IsInterfaceNil -
+func (stub *TimestampResolverStub) IsInterfaceNil() bool {
+	return stub == nil
+}
@@ -0,0 +1,37 @@
+package testscommon
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// UpstreamClientStub -
+type UpstreamClientStub struct {
+	DoCalled       func(request *http.Request, timeout time.Duration) (*http.Response, error)
+	CopyBodyCalled func(destination io.Writer, source io.Reader) (int64, error)
+}
+
+// Do -
+func (stub *UpstreamClientStub) Do(request *http.Request, timeout time.Duration) (*http.Response, error) {
+	if stub.DoCalled != nil {
+		return stub.DoCalled(request, timeout)
+	}
+
+	return nil, errors.New("not implemented")
+}
+
+// CopyBody -
+func (stub *UpstreamClientStub) CopyBody(destination io.Writer, source io.Reader) (int64, error) {
+	if stub.CopyBodyCalled != nil {
+		return stub.CopyBodyCalled(destination, source)
+	}
+
+	return io.Copy(destination, source)
+}
+
+// IsInterfaceNil -
+func (stub *UpstreamClientStub) IsInterfaceNil() bool {
+	return stub == nil
+}